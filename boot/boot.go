@@ -430,7 +430,7 @@ func updateManagedBootConfigForBootloader(dev Device, mode, gadgetSnapOrDir stri
 		return false, err
 	}
 	// boot config update can lead to a change of kernel command line
-	_, err = observeCommandLineUpdate(dev.Model(), commandLineUpdateReasonSnapd, gadgetSnapOrDir)
+	_, err = observeCommandLineUpdate(dev.Model(), commandLineUpdateReasonSnapd, gadgetSnapOrDir, "")
 	if err != nil {
 		return false, err
 	}
@@ -442,6 +442,19 @@ func updateManagedBootConfigForBootloader(dev Device, mode, gadgetSnapOrDir stri
 // change in command line has been observed and a reboot is needed. The reboot,
 // if needed, should be requested at the the earliest possible occasion.
 func UpdateCommandLineForGadgetComponent(dev Device, gadgetSnapOrDir string) (needsReboot bool, err error) {
+	return updateCommandLine(dev, commandLineUpdateReasonGadget, gadgetSnapOrDir, "")
+}
+
+// UpdateCommandLineAppend handles a change of the arguments appended to the
+// kernel command line of the run system through the
+// system.kernel.cmdline-append option. Returns true when a change in command
+// line has been observed and a reboot is needed. The reboot, if needed, should
+// be requested at the the earliest possible occasion.
+func UpdateCommandLineAppend(dev Device, gadgetSnapOrDir, cmdlineAppend string) (needsReboot bool, err error) {
+	return updateCommandLine(dev, commandLineUpdateReasonAppend, gadgetSnapOrDir, cmdlineAppend)
+}
+
+func updateCommandLine(dev Device, reason commandLineUpdateReason, gadgetSnapOrDir, cmdlineAppend string) (needsReboot bool, err error) {
 	if !dev.HasModeenv() {
 		// only UC20 devices are supported
 		return false, fmt.Errorf("internal error: command line component cannot be updated on non UC20 devices")
@@ -459,17 +472,22 @@ func UpdateCommandLineForGadgetComponent(dev Device, gadgetSnapOrDir string) (ne
 		}
 		return false, err
 	}
-	// gadget update can lead to a change of kernel command line
-	cmdlineChange, err := observeCommandLineUpdate(dev.Model(), commandLineUpdateReasonGadget, gadgetSnapOrDir)
+	// gadget update or appended arguments can lead to a change of kernel
+	// command line
+	cmdlineChange, err := observeCommandLineUpdate(dev.Model(), reason, gadgetSnapOrDir, cmdlineAppend)
 	if err != nil {
 		return false, err
 	}
 	if !cmdlineChange {
 		return false, nil
 	}
+	m, err := loadModeenv()
+	if err != nil {
+		return false, err
+	}
 	// update the bootloader environment, maybe clearing the relevant
 	// variables
-	cmdlineVars, err := bootVarsForTrustedCommandLineFromGadget(gadgetSnapOrDir)
+	cmdlineVars, err := bootVarsForTrustedCommandLineFromGadget(gadgetSnapOrDir, m.KernelCommandLineAppend)
 	if err != nil {
 		return false, fmt.Errorf("cannot prepare bootloader variables for kernel command line: %v", err)
 	}
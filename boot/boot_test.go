@@ -3635,6 +3635,91 @@ func (s *bootKernelCommandLineSuite) TestCommandLineUpdateUC20ArgsSwitch(c *C) {
 	})
 }
 
+func (s *bootKernelCommandLineSuite) TestCommandLineUpdateUC20Append(c *C) {
+	s.stampSealedKeys(c, dirs.GlobalRootDir)
+
+	sf := snaptest.MakeTestSnapWithFiles(c, gadgetSnapYaml, [][]string{
+		{"cmdline.extra", "from-gadget"},
+	})
+
+	s.modeenvWithEncryption.CurrentKernelCommandLines = []string{"snapd_recovery_mode=run static mocked panic=-1 from-gadget"}
+	c.Assert(s.modeenvWithEncryption.WriteTo(""), IsNil)
+	err := s.bootloader.SetBootVars(map[string]string{
+		"snapd_extra_cmdline_args": "from-gadget",
+		"snapd_full_cmdline_args":  "",
+	})
+	c.Assert(err, IsNil)
+	s.bootloader.SetBootVarsCalls = 0
+
+	reboot, err := boot.UpdateCommandLineAppend(s.uc20dev, sf, "quiet isolcpus=1")
+	c.Assert(err, IsNil)
+	c.Assert(reboot, Equals, true)
+
+	// reseal was applied
+	c.Check(s.resealCalls, Equals, 1)
+	c.Check(s.resealCommandLines, DeepEquals, [][]string{{
+		"snapd_recovery_mode=run static mocked panic=-1 from-gadget",
+		"snapd_recovery_mode=run static mocked panic=-1 from-gadget quiet isolcpus=1",
+	}})
+
+	newM, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(newM.CurrentKernelCommandLines, DeepEquals, boot.BootCommandLines{
+		"snapd_recovery_mode=run static mocked panic=-1 from-gadget",
+		"snapd_recovery_mode=run static mocked panic=-1 from-gadget quiet isolcpus=1",
+	})
+	c.Check(newM.KernelCommandLineAppend, Equals, "quiet isolcpus=1")
+	args, err := s.bootloader.GetBootVars("snapd_extra_cmdline_args", "snapd_full_cmdline_args")
+	c.Assert(err, IsNil)
+	c.Check(args, DeepEquals, map[string]string{
+		"snapd_extra_cmdline_args": "from-gadget quiet isolcpus=1",
+		"snapd_full_cmdline_args":  "",
+	})
+
+	// pretend we rebooted with the new command line
+	newM.CurrentKernelCommandLines = boot.BootCommandLines{
+		"snapd_recovery_mode=run static mocked panic=-1 from-gadget quiet isolcpus=1",
+	}
+	c.Assert(newM.Write(), IsNil)
+
+	// the appended arguments are kept over a gadget update
+	sfChanged := snaptest.MakeTestSnapWithFiles(c, gadgetSnapYaml, [][]string{
+		{"cmdline.extra", "changed"},
+	})
+	reboot, err = boot.UpdateCommandLineForGadgetComponent(s.uc20dev, sfChanged)
+	c.Assert(err, IsNil)
+	c.Assert(reboot, Equals, true)
+
+	newM, err = boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(newM.CurrentKernelCommandLines, DeepEquals, boot.BootCommandLines{
+		"snapd_recovery_mode=run static mocked panic=-1 from-gadget quiet isolcpus=1",
+		"snapd_recovery_mode=run static mocked panic=-1 changed quiet isolcpus=1",
+	})
+	c.Check(newM.KernelCommandLineAppend, Equals, "quiet isolcpus=1")
+	args, err = s.bootloader.GetBootVars("snapd_extra_cmdline_args", "snapd_full_cmdline_args")
+	c.Assert(err, IsNil)
+	c.Check(args, DeepEquals, map[string]string{
+		"snapd_extra_cmdline_args": "changed quiet isolcpus=1",
+		"snapd_full_cmdline_args":  "",
+	})
+
+	// and dropping them goes back to the gadget ones only
+	newM.CurrentKernelCommandLines = boot.BootCommandLines{
+		"snapd_recovery_mode=run static mocked panic=-1 changed quiet isolcpus=1",
+	}
+	c.Assert(newM.Write(), IsNil)
+	reboot, err = boot.UpdateCommandLineAppend(s.uc20dev, sfChanged, "")
+	c.Assert(err, IsNil)
+	c.Assert(reboot, Equals, true)
+	newM, err = boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(newM.KernelCommandLineAppend, Equals, "")
+	args, err = s.bootloader.GetBootVars("snapd_extra_cmdline_args", "snapd_full_cmdline_args")
+	c.Assert(err, IsNil)
+	c.Check(args["snapd_extra_cmdline_args"], Equals, "changed")
+}
+
 func (s *bootKernelCommandLineSuite) TestCommandLineUpdateUC20UnencryptedArgsRemoved(c *C) {
 	s.stampSealedKeys(c, dirs.GlobalRootDir)
 
@@ -99,17 +99,30 @@ func getBootloaderManagingItsAssets(where string, opts *bootloader.Options) (boo
 	return mbl, nil
 }
 
+// appendCommandLineArgs returns the kernel command line arguments with more
+// arguments appended.
+func appendCommandLineArgs(args, more string) string {
+	if args == "" {
+		return more
+	}
+	if more == "" {
+		return args
+	}
+	return args + " " + more
+}
+
 // bootVarsForTrustedCommandLineFromGadget returns a set of boot variables that
-// carry the command line arguments requested by the gadget. This is only useful
-// if snapd is managing the boot config.
-func bootVarsForTrustedCommandLineFromGadget(gadgetDirOrSnapPath string) (map[string]string, error) {
+// carry the command line arguments requested by the gadget, followed by those
+// appended through the system.kernel.cmdline-append option. This is only
+// useful if snapd is managing the boot config.
+func bootVarsForTrustedCommandLineFromGadget(gadgetDirOrSnapPath, cmdlineAppend string) (map[string]string, error) {
 	extraOrFull, full, err := gadget.KernelCommandLineFromGadget(gadgetDirOrSnapPath)
 	if err != nil {
 		if err == gadget.ErrNoKernelCommandline {
 			// nothing set by the gadget, but we could have had
 			// arguments before, so make sure those are cleared now
 			clear := map[string]string{
-				"snapd_extra_cmdline_args": "",
+				"snapd_extra_cmdline_args": cmdlineAppend,
 				"snapd_full_cmdline_args":  "",
 			}
 			return clear, nil
@@ -122,9 +135,9 @@ func bootVarsForTrustedCommandLineFromGadget(gadgetDirOrSnapPath string) (map[st
 		"snapd_full_cmdline_args":  "",
 	}
 	if full {
-		args["snapd_full_cmdline_args"] = extraOrFull
+		args["snapd_full_cmdline_args"] = appendCommandLineArgs(extraOrFull, cmdlineAppend)
 	} else {
-		args["snapd_extra_cmdline_args"] = extraOrFull
+		args["snapd_extra_cmdline_args"] = appendCommandLineArgs(extraOrFull, cmdlineAppend)
 	}
 	return args, nil
 }
@@ -134,7 +147,7 @@ const (
 	candidateEdition
 )
 
-func composeCommandLine(currentOrCandidate int, mode, system, gadgetDirOrSnapPath string) (string, error) {
+func composeCommandLine(currentOrCandidate int, mode, system, gadgetDirOrSnapPath, cmdlineAppend string) (string, error) {
 	if mode != ModeRun && mode != ModeRecover {
 		return "", fmt.Errorf("internal error: unsupported command line mode %q", mode)
 	}
@@ -181,6 +194,13 @@ func composeCommandLine(currentOrCandidate int, mode, system, gadgetDirOrSnapPat
 			}
 		}
 	}
+	if cmdlineAppend != "" {
+		if components.FullArgs != "" {
+			components.FullArgs = appendCommandLineArgs(components.FullArgs, cmdlineAppend)
+		} else {
+			components.ExtraArgs = appendCommandLineArgs(components.ExtraArgs, cmdlineAppend)
+		}
+	}
 	if currentOrCandidate == currentEdition {
 		return mbl.CommandLine(components)
 	} else {
@@ -194,7 +214,7 @@ func ComposeRecoveryCommandLine(model *asserts.Model, system, gadgetDirOrSnapPat
 	if model.Grade() == asserts.ModelGradeUnset {
 		return "", nil
 	}
-	return composeCommandLine(currentEdition, ModeRecover, system, gadgetDirOrSnapPath)
+	return composeCommandLine(currentEdition, ModeRecover, system, gadgetDirOrSnapPath, "")
 }
 
 // ComposeCommandLine composes the kernel command line used when booting the
@@ -203,7 +223,7 @@ func ComposeCommandLine(model *asserts.Model, gadgetDirOrSnapPath string) (strin
 	if model.Grade() == asserts.ModelGradeUnset {
 		return "", nil
 	}
-	return composeCommandLine(currentEdition, ModeRun, "", gadgetDirOrSnapPath)
+	return composeCommandLine(currentEdition, ModeRun, "", gadgetDirOrSnapPath, "")
 }
 
 // ComposeCandidateCommandLine composes the kernel command line used when
@@ -213,7 +233,7 @@ func ComposeCandidateCommandLine(model *asserts.Model, gadgetDirOrSnapPath strin
 	if model.Grade() == asserts.ModelGradeUnset {
 		return "", nil
 	}
-	return composeCommandLine(candidateEdition, ModeRun, "", gadgetDirOrSnapPath)
+	return composeCommandLine(candidateEdition, ModeRun, "", gadgetDirOrSnapPath, "")
 }
 
 // ComposeCandidateRecoveryCommandLine composes the kernel command line used
@@ -223,7 +243,7 @@ func ComposeCandidateRecoveryCommandLine(model *asserts.Model, system, gadgetDir
 	if model.Grade() == asserts.ModelGradeUnset {
 		return "", nil
 	}
-	return composeCommandLine(candidateEdition, ModeRecover, system, gadgetDirOrSnapPath)
+	return composeCommandLine(candidateEdition, ModeRecover, system, gadgetDirOrSnapPath, "")
 }
 
 // observeSuccessfulCommandLine observes a successful boot with a command line
@@ -313,13 +333,17 @@ type commandLineUpdateReason int
 const (
 	commandLineUpdateReasonSnapd commandLineUpdateReason = iota
 	commandLineUpdateReasonGadget
+	commandLineUpdateReasonAppend
 )
 
 // observeCommandLineUpdate observes a pending kernel command line change caused
-// by an update of boot config or the gadget snap. When needed, the modeenv is
+// by an update of boot config, the gadget snap or the arguments appended
+// through the system.kernel.cmdline-append option. When needed, the modeenv is
 // updated with a candidate command line and the encryption keys are resealed.
 // This helper should be called right before updating the managed boot config.
-func observeCommandLineUpdate(model *asserts.Model, reason commandLineUpdateReason, gadgetSnapOrDir string) (updated bool, err error) {
+// The cmdlineAppend arguments are only considered for
+// commandLineUpdateReasonAppend, the ones in the modeenv are kept otherwise.
+func observeCommandLineUpdate(model *asserts.Model, reason commandLineUpdateReason, gadgetSnapOrDir, cmdlineAppend string) (updated bool, err error) {
 	// TODO:UC20: consider updating a recovery system command line
 
 	m, err := loadModeenv()
@@ -335,16 +359,22 @@ func observeCommandLineUpdate(model *asserts.Model, reason commandLineUpdateReas
 	cmdline := m.CurrentKernelCommandLines[0]
 	// this is the new expected command line
 	var candidateCmdline string
-	switch reason {
-	case commandLineUpdateReasonSnapd:
-		// pending boot config update
-		candidateCmdline, err = ComposeCandidateCommandLine(model, gadgetSnapOrDir)
-	case commandLineUpdateReasonGadget:
-		// pending gadget update
-		candidateCmdline, err = ComposeCommandLine(model, gadgetSnapOrDir)
-	}
-	if err != nil {
-		return false, err
+	if model.Grade() != asserts.ModelGradeUnset {
+		switch reason {
+		case commandLineUpdateReasonSnapd:
+			// pending boot config update
+			candidateCmdline, err = composeCommandLine(candidateEdition, ModeRun, "", gadgetSnapOrDir, m.KernelCommandLineAppend)
+		case commandLineUpdateReasonGadget:
+			// pending gadget update
+			candidateCmdline, err = composeCommandLine(currentEdition, ModeRun, "", gadgetSnapOrDir, m.KernelCommandLineAppend)
+		case commandLineUpdateReasonAppend:
+			// pending change of the appended arguments
+			m.KernelCommandLineAppend = cmdlineAppend
+			candidateCmdline, err = composeCommandLine(currentEdition, ModeRun, "", gadgetSnapOrDir, cmdlineAppend)
+		}
+		if err != nil {
+			return false, err
+		}
 	}
 	if cmdline == candidateCmdline {
 		// command line is the same or no actual change in modeenv
@@ -376,7 +406,7 @@ func kernelCommandLinesForResealWithFallback(modeenv *Modeenv) (cmdlines []strin
 	// there would be no kernel command lines arguments coming from the
 	// gadget either
 	gadgetDir := ""
-	cmdline, err := composeCommandLine(currentEdition, ModeRun, "", gadgetDir)
+	cmdline, err := composeCommandLine(currentEdition, ModeRun, "", gadgetDir, "")
 	if err != nil {
 		return nil, err
 	}
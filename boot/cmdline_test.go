@@ -359,9 +359,10 @@ func (s *kernelCommandLineSuite) TestComposeRecoveryCommandLineWithGadget(c *C)
 
 func (s *kernelCommandLineSuite) TestBootVarsForGadgetCommandLine(c *C) {
 	for _, tc := range []struct {
-		errMsg       string
-		files        [][]string
-		expectedVars map[string]string
+		errMsg        string
+		files         [][]string
+		cmdlineAppend string
+		expectedVars  map[string]string
 	}{{
 		files: [][]string{
 			{"cmdline.extra", "foo bar baz"},
@@ -398,11 +399,37 @@ func (s *kernelCommandLineSuite) TestBootVarsForGadgetCommandLine(c *C) {
 			"snapd_extra_cmdline_args": "",
 			"snapd_full_cmdline_args":  "",
 		},
+	}, {
+		files: [][]string{
+			{"cmdline.extra", "foo bar baz"},
+		},
+		cmdlineAppend: "quiet isolcpus=1",
+		expectedVars: map[string]string{
+			"snapd_extra_cmdline_args": "foo bar baz quiet isolcpus=1",
+			"snapd_full_cmdline_args":  "",
+		},
+	}, {
+		files: [][]string{
+			{"cmdline.full", "full foo bar baz"},
+		},
+		cmdlineAppend: "quiet",
+		expectedVars: map[string]string{
+			"snapd_extra_cmdline_args": "",
+			"snapd_full_cmdline_args":  "full foo bar baz quiet",
+		},
+	}, {
+		// appended arguments alone
+		files:         [][]string{},
+		cmdlineAppend: "quiet",
+		expectedVars: map[string]string{
+			"snapd_extra_cmdline_args": "quiet",
+			"snapd_full_cmdline_args":  "",
+		},
 	}} {
 		sf := snaptest.MakeTestSnapWithFiles(c, gadgetSnapYaml, append([][]string{
 			{"meta/snap.yaml", gadgetSnapYaml},
 		}, tc.files...))
-		vars, err := boot.BootVarsForTrustedCommandLineFromGadget(sf)
+		vars, err := boot.BootVarsForTrustedCommandLineFromGadget(sf, tc.cmdlineAppend)
 		if tc.errMsg == "" {
 			c.Assert(err, IsNil)
 			c.Assert(vars, DeepEquals, tc.expectedVars)
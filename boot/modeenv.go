@@ -97,6 +97,10 @@ type Modeenv struct {
 	// element for normal operations, but may contain two elements during
 	// update scenarios.
 	CurrentKernelCommandLines bootCommandLines `key:"current_kernel_command_lines"`
+	// KernelCommandLineAppend holds the arguments appended to the kernel
+	// command line of the run system through the
+	// system.kernel.cmdline-append option.
+	KernelCommandLineAppend string `key:"kernel_command_line_append"`
 	// TODO:UC20 add a per recovery system list of kernel command lines
 
 	// read is set to true when a modenv was read successfully
@@ -199,6 +203,7 @@ func ReadModeenv(rootdir string) (*Modeenv, error) {
 	unmarshalModeenvValueFromCfg(cfg, "current_trusted_boot_assets", &m.CurrentTrustedBootAssets)
 	unmarshalModeenvValueFromCfg(cfg, "current_trusted_recovery_boot_assets", &m.CurrentTrustedRecoveryBootAssets)
 	unmarshalModeenvValueFromCfg(cfg, "current_kernel_command_lines", &m.CurrentKernelCommandLines)
+	unmarshalModeenvValueFromCfg(cfg, "kernel_command_line_append", &m.KernelCommandLineAppend)
 
 	// save all the rest of the keys we don't understand
 	keys, err := cfg.Options("")
@@ -310,6 +315,7 @@ func (m *Modeenv) WriteTo(rootdir string) error {
 	marshalModeenvEntryTo(buf, "current_trusted_boot_assets", m.CurrentTrustedBootAssets)
 	marshalModeenvEntryTo(buf, "current_trusted_recovery_boot_assets", m.CurrentTrustedRecoveryBootAssets)
 	marshalModeenvEntryTo(buf, "current_kernel_command_lines", m.CurrentKernelCommandLines)
+	marshalModeenvEntryTo(buf, "kernel_command_line_append", m.KernelCommandLineAppend)
 
 	// write all the extra keys at the end
 	// sort them for test convenience
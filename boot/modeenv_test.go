@@ -73,6 +73,7 @@ func (s *modeenvSuite) TestKnownKnown(c *C) {
 		"try_model_sign_key_id": true,
 		// keep this comment to make old go fmt happy
 		"current_kernel_command_lines":         true,
+		"kernel_command_line_append":           true,
 		"current_trusted_boot_assets":          true,
 		"current_trusted_recovery_boot_assets": true,
 	})
@@ -776,6 +777,7 @@ func (s *modeenvSuite) TestMarshalKernelCommandLines(c *C) {
 			`snapd_recovery_mode=run panic=-1 console=ttyS0,io,9600n8`,
 			`snapd_recovery_mode=run candidate panic=-1 console=ttyS0,io,9600n8`,
 		},
+		KernelCommandLineAppend: "quiet isolcpus=1",
 	}
 	err := modeenv.WriteTo(s.tmpdir)
 	c.Assert(err, IsNil)
@@ -783,6 +785,7 @@ func (s *modeenvSuite) TestMarshalKernelCommandLines(c *C) {
 	c.Assert(s.mockModeenvPath, testutil.FileEquals, `mode=run
 recovery_system=20191128
 current_kernel_command_lines=["snapd_recovery_mode=run panic=-1 console=ttyS0,io,9600n8","snapd_recovery_mode=run candidate panic=-1 console=ttyS0,io,9600n8"]
+kernel_command_line_append=quiet isolcpus=1
 `)
 
 	modeenvRead, err := boot.ReadModeenv(s.tmpdir)
@@ -791,6 +794,7 @@ current_kernel_command_lines=["snapd_recovery_mode=run panic=-1 console=ttyS0,io
 		`snapd_recovery_mode=run panic=-1 console=ttyS0,io,9600n8`,
 		`snapd_recovery_mode=run candidate panic=-1 console=ttyS0,io,9600n8`,
 	})
+	c.Assert(modeenvRead.KernelCommandLineAppend, Equals, "quiet isolcpus=1")
 }
 
 func (s *modeenvSuite) TestModeenvWithModelGradeSignKeyID(c *C) {
@@ -535,7 +535,7 @@ func recoveryBootChainsForSystems(systems []string, trbl bootloader.TrustedAsset
 		}
 
 		// get the command line
-		cmdline, err := composeCommandLine(currentEdition, ModeRecover, system, seedGadget.Path)
+		cmdline, err := composeCommandLine(currentEdition, ModeRecover, system, seedGadget.Path, "")
 		if err != nil {
 			return nil, fmt.Errorf("cannot obtain recovery kernel command line: %v", err)
 		}
@@ -0,0 +1,80 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// ApplyAction is a step in bringing the system to the state described by
+// a manifest.
+type ApplyAction struct {
+	Kind    string `json:"kind"`
+	Target  string `json:"target"`
+	Summary string `json:"summary"`
+}
+
+// ApplyOptions holds the options of Apply.
+type ApplyOptions struct {
+	// DryRun is whether to only return the plan, without acting on it
+	DryRun bool
+}
+
+// Apply asks snapd to bring the system to the state described by the given
+// manifest, in YAML or JSON. It returns the actions needed to do so, and
+// the ID of the change performing them, if any.
+func (client *Client) Apply(manifest []byte, opts *ApplyOptions) (plan []*ApplyAction, changeID string, err error) {
+	if opts == nil {
+		opts = &ApplyOptions{}
+	}
+	q := url.Values{}
+	if opts.DryRun {
+		q.Set("dry-run", "true")
+	}
+	headers := map[string]string{"Content-Type": "application/x-yaml"}
+
+	var rsp response
+	statusCode, err := client.do("POST", "/v2/apply", q, headers, bytes.NewReader(manifest), &rsp, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := rsp.err(client, statusCode); err != nil {
+		return nil, "", err
+	}
+	switch rsp.Type {
+	case "sync":
+		// nothing to do, or a dry run
+	case "async":
+		changeID = rsp.Change
+	default:
+		return nil, "", fmt.Errorf("unexpected response type %q", rsp.Type)
+	}
+
+	var result struct {
+		Plan []*ApplyAction `json:"plan"`
+	}
+	if err := json.Unmarshal(rsp.Result, &result); err != nil {
+		return nil, "", fmt.Errorf("cannot unmarshal apply plan: %v", err)
+	}
+	return result.Plan, changeID, nil
+}
@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientApply(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42",
+		"result": {"plan": [{"kind": "install", "target": "foo", "summary": "Install \"foo\" snap"}]}
+	}`
+	plan, changeID, err := cs.cli.Apply([]byte("snaps: [{name: foo}]"), nil)
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "42")
+	c.Check(plan, check.DeepEquals, []*client.ApplyAction{
+		{Kind: "install", Target: "foo", Summary: `Install "foo" snap`},
+	})
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/apply")
+	c.Check(cs.req.URL.Query(), check.HasLen, 0)
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, "snaps: [{name: foo}]")
+}
+
+func (cs *clientSuite) TestClientApplyDryRun(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {"plan": [{"kind": "refresh", "target": "foo", "summary": "Refresh \"foo\" snap"}]}
+	}`
+	plan, changeID, err := cs.cli.Apply([]byte("snaps: [{name: foo}]"), &client.ApplyOptions{DryRun: true})
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "")
+	c.Check(plan, check.DeepEquals, []*client.ApplyAction{
+		{Kind: "refresh", Target: "foo", Summary: `Refresh "foo" snap`},
+	})
+	c.Check(cs.req.URL.Query().Get("dry-run"), check.Equals, "true")
+}
+
+func (cs *clientSuite) TestClientApplyError(c *check.C) {
+	cs.status = 400
+	cs.rsp = `{
+		"type": "error",
+		"status-code": 400,
+		"result": {"message": "invalid manifest: boom"}
+	}`
+	_, _, err := cs.cli.Apply([]byte("snaps: 1"), nil)
+	c.Check(err, check.ErrorMatches, "invalid manifest: boom")
+}
@@ -633,6 +633,43 @@ type RefreshInfo struct {
 	Last     string `json:"last,omitempty"`
 	Hold     string `json:"hold,omitempty"`
 	Next     string `json:"next,omitempty"`
+	// Windows contains the refresh windows restricting when some
+	// snaps are auto-refreshed.
+	Windows []RefreshWindowInfo `json:"windows,omitempty"`
+	// Coordinator contains the outcome of the last consultation of
+	// the refresh coordinator, if one is configured.
+	Coordinator *RefreshCoordinatorInfo `json:"coordinator,omitempty"`
+}
+
+// RefreshWindowInfo contains information about a refresh window.
+type RefreshWindowInfo struct {
+	Name  string   `json:"name"`
+	Timer string   `json:"timer"`
+	Snaps []string `json:"snaps"`
+	// Open is true if the snaps can currently be auto-refreshed.
+	Open bool `json:"open,omitempty"`
+	// Next is when the snaps are next eligible for auto-refresh.
+	Next string `json:"next,omitempty"`
+}
+
+// RefreshCoordinatorInfo contains information about the refresh
+// coordinator consulted before auto-refreshes.
+type RefreshCoordinatorInfo struct {
+	URL string `json:"url"`
+	// Last is when the coordinator was last consulted.
+	Last  string                                `json:"last,omitempty"`
+	Error string                                `json:"error,omitempty"`
+	Snaps map[string]RefreshCoordinatorDecision `json:"snaps,omitempty"`
+}
+
+// RefreshCoordinatorDecision is what the refresh coordinator decided
+// about the auto-refresh of a snap.
+type RefreshCoordinatorDecision struct {
+	// Action is one of "approve", "delay" or "pin".
+	Action   string `json:"action"`
+	Revision string `json:"revision,omitempty"`
+	Until    string `json:"until,omitempty"`
+	Reason   string `json:"reason,omitempty"`
 }
 
 // SysInfo holds system information
@@ -24,6 +24,9 @@ import (
 	"encoding/json"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/snap"
 )
 
 // SetConf requests a snap to apply the provided patch to the configuration.
@@ -35,6 +38,19 @@ func (client *Client) SetConf(snapName string, patch map[string]interface{}) (ch
 	return client.doAsync("PUT", "/v2/snaps/"+snapName+"/conf", nil, nil, bytes.NewReader(b))
 }
 
+// SetSecretConf requests a snap to apply the provided patch to the
+// configuration, with the values being secrets: they are stored encrypted
+// and only revealed to the snap itself. The values must be strings.
+func (client *Client) SetSecretConf(snapName string, patch map[string]interface{}) (changeID string, err error) {
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("secret", "true")
+	return client.doAsync("PUT", "/v2/snaps/"+snapName+"/conf", query, nil, bytes.NewReader(b))
+}
+
 // Conf asks for a snap's current configuration.
 //
 // Note that the configuration may include json.Numbers.
@@ -50,3 +66,112 @@ func (client *Client) Conf(snapName string, keys []string) (configuration map[st
 
 	return configuration, nil
 }
+
+// ConfSchema asks for the configuration schema declared by a snap.
+//
+// Note that the default and enum values may include json.Numbers.
+func (client *Client) ConfSchema(snapName string) (snap.ConfigSchema, error) {
+	query := url.Values{}
+	query.Set("schema", "true")
+
+	var schema snap.ConfigSchema
+	if _, err := client.doSync("GET", "/v2/snaps/"+snapName+"/conf", query, nil, nil, &schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// ConfChange is the change of the value of a configuration option. A nil
+// value means the option was unset.
+type ConfChange struct {
+	Key string      `json:"key"`
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// ConfHistoryEntry records a change of the configuration of a snap.
+type ConfHistoryEntry struct {
+	ID       int          `json:"id"`
+	Time     time.Time    `json:"time"`
+	User     string       `json:"user,omitempty"`
+	ChangeID string       `json:"change-id,omitempty"`
+	Changes  []ConfChange `json:"changes"`
+}
+
+// ConfHistory asks for the recorded configuration changes of a snap,
+// oldest first.
+//
+// Note that the values may include json.Numbers.
+func (client *Client) ConfHistory(snapName string) ([]ConfHistoryEntry, error) {
+	var history []ConfHistoryEntry
+	if _, err := client.doSync("GET", "/v2/snaps/"+snapName+"/conf-history", nil, nil, nil, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// RevertConf requests the configuration of a snap to be reverted to what it
+// was after the given change in its configuration history.
+func (client *Client) RevertConf(snapName string, id int) (changeID string, err error) {
+	b, err := json.Marshal(map[string]interface{}{
+		"action": "revert",
+		"id":     id,
+	})
+	if err != nil {
+		return "", err
+	}
+	return client.doAsync("POST", "/v2/snaps/"+snapName+"/conf-history", nil, nil, bytes.NewReader(b))
+}
+
+// ExportConfOptions holds the options for exporting configuration.
+type ExportConfOptions struct {
+	// Snaps are the snaps, or "system", whose configuration to export;
+	// all of them if empty
+	Snaps []string `json:"snaps,omitempty"`
+	// PrivateKey is the ASCII-armored OpenPGP private key to sign the
+	// document with, if any, unlocked with Passphrase if needed
+	PrivateKey string `json:"private-key,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// ExportConf asks for a document of the configuration of the system and of
+// snaps, that can be imported with ImportConf. Secret values are left out
+// of it.
+func (client *Client) ExportConf(opts *ExportConfOptions) (document string, err error) {
+	if opts == nil {
+		opts = &ExportConfOptions{}
+	}
+	b, err := json.Marshal(struct {
+		Action string `json:"action"`
+		*ExportConfOptions
+	}{Action: "export", ExportConfOptions: opts})
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Document string `json:"document"`
+	}
+	if _, err := client.doSync("POST", "/v2/config", nil, nil, bytes.NewReader(b), &result); err != nil {
+		return "", err
+	}
+	return result.Document, nil
+}
+
+// ImportConf requests the configuration of the given document, as
+// returned by ExportConf, to be applied. A signed document is verified
+// with the given ASCII-armored OpenPGP public key.
+//
+// The configure hook of each snap whose configuration changes is run in a
+// single change; the "config-tasks" data of the change holds the ID of the
+// task of each snap, to tell which snaps failed to be configured.
+func (client *Client) ImportConf(document, publicKey string) (changeID string, err error) {
+	b, err := json.Marshal(struct {
+		Action    string `json:"action"`
+		Document  string `json:"document"`
+		PublicKey string `json:"public-key,omitempty"`
+	}{Action: "import", Document: document, PublicKey: publicKey})
+	if err != nil {
+		return "", err
+	}
+	return client.doAsync("POST", "/v2/config", nil, nil, bytes.NewReader(b))
+}
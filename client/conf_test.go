@@ -21,8 +21,13 @@ package client_test
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"time"
 
 	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 )
 
 func (cs *clientSuite) TestClientSetConfCallsEndpoint(c *check.C) {
@@ -31,6 +36,13 @@ func (cs *clientSuite) TestClientSetConfCallsEndpoint(c *check.C) {
 	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf")
 }
 
+func (cs *clientSuite) TestClientSetSecretConfCallsEndpoint(c *check.C) {
+	cs.cli.SetSecretConf("snap-name", map[string]interface{}{"key": "value"})
+	c.Check(cs.req.Method, check.Equals, "PUT")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf")
+	c.Check(cs.req.URL.Query().Get("secret"), check.Equals, "true")
+}
+
 func (cs *clientSuite) TestClientGetConfCallsEndpoint(c *check.C) {
 	cs.cli.Conf("snap-name", []string{"test-key"})
 	c.Check(cs.req.Method, check.Equals, "GET")
@@ -103,3 +115,115 @@ func (cs *clientSuite) TestClientGetConfMultipleKeys(c *check.C) {
 		"test-key2": "test-value2",
 	})
 }
+
+func (cs *clientSuite) TestClientConfSchema(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"port": {"type": "int", "max": 65535, "default": 80},
+			"mode": {"type": "string", "enum": ["fast", "slow"], "required": true}
+		}
+	}`
+	schema, err := cs.cli.ConfSchema("snap-name")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf")
+	c.Check(cs.req.URL.Query().Get("schema"), check.Equals, "true")
+	max := 65535.0
+	c.Check(schema, check.DeepEquals, snap.ConfigSchema{
+		"port": {Type: "int", Max: &max, Default: json.Number("80")},
+		"mode": {Type: "string", Enum: []interface{}{"fast", "slow"}, Required: true},
+	})
+}
+
+func (cs *clientSuite) TestClientConfHistory(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{
+			"id": 1,
+			"time": "2021-05-01T10:00:00Z",
+			"user": "root",
+			"change-id": "42",
+			"changes": [{"key": "foo", "new": "bar"}, {"key": "port", "old": 80, "new": 8080}]
+		}]
+	}`
+	history, err := cs.cli.ConfHistory("snap-name")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf-history")
+	c.Check(history, check.DeepEquals, []client.ConfHistoryEntry{{
+		ID:       1,
+		Time:     time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC),
+		User:     "root",
+		ChangeID: "42",
+		Changes: []client.ConfChange{
+			{Key: "foo", New: "bar"},
+			{Key: "port", Old: json.Number("80"), New: json.Number("8080")},
+		},
+	}})
+}
+
+func (cs *clientSuite) TestClientRevertConf(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.RevertConf("snap-name", 3)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf-history")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"revert","id":3}`)
+}
+
+func (cs *clientSuite) TestClientExportConf(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {"document": "{\"type\": \"config\"}\n"}
+	}`
+	doc, err := cs.cli.ExportConf(&client.ExportConfOptions{Snaps: []string{"system", "foo"}, PrivateKey: "key", Passphrase: "pass"})
+	c.Assert(err, check.IsNil)
+	c.Check(doc, check.Equals, "{\"type\": \"config\"}\n")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/config")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"export","snaps":["system","foo"],"private-key":"key","passphrase":"pass"}`)
+}
+
+func (cs *clientSuite) TestClientExportConfAll(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {"document": "{}"}
+	}`
+	_, err := cs.cli.ExportConf(nil)
+	c.Assert(err, check.IsNil)
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"export"}`)
+}
+
+func (cs *clientSuite) TestClientImportConf(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.ImportConf(`{"type":"config"}`, "key")
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/config")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"import","document":"{\"type\":\"config\"}","public-key":"key"}`)
+}
@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event is something that happened in snapd, as streamed by Events.
+type Event struct {
+	// Type is one of "change-status", "task-status", "task-progress"
+	// and "warning".
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	// ChangeID and ChangeKind identify the change of change events, or
+	// of the task of task events.
+	ChangeID   string `json:"change-id,omitempty"`
+	ChangeKind string `json:"change-kind,omitempty"`
+
+	// Status, Ready and Err are the new status of the change of
+	// change-status events, whether it is ready, and its error if any.
+	Status string `json:"status,omitempty"`
+	Ready  bool   `json:"ready,omitempty"`
+	Err    string `json:"err,omitempty"`
+
+	// Task is the task of task events.
+	Task *Task `json:"task,omitempty"`
+
+	// Message is the message of warning events.
+	Message string `json:"message,omitempty"`
+}
+
+// EventsOptions holds the filters of the events to stream.
+type EventsOptions struct {
+	// ChangeID only streams the events of the change with this id.
+	ChangeID string
+	// ChangeKind only streams the events of changes of this kind.
+	ChangeKind string
+	// Types only streams the events of these types.
+	Types []string
+}
+
+// Events streams events as they happen in snapd, until the given context
+// is cancelled or the connection to snapd is closed, at which point the
+// returned channel is closed.
+func (client *Client) Events(ctx context.Context, opts *EventsOptions) (<-chan Event, error) {
+	query := url.Values{}
+	if opts != nil {
+		if opts.ChangeID != "" {
+			query.Set("change-id", opts.ChangeID)
+		}
+		if opts.ChangeKind != "" {
+			query.Set("change-kind", opts.ChangeKind)
+		}
+		if len(opts.Types) > 0 {
+			query.Set("types", strings.Join(opts.Types, ","))
+		}
+	}
+
+	rsp, err := client.raw(ctx, "GET", "/v2/events", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != 200 {
+		var r response
+		defer rsp.Body.Close()
+		if err := decodeInto(rsp.Body, &r); err != nil {
+			return nil, err
+		}
+		return nil, r.err(client, rsp.StatusCode)
+	}
+
+	ch := make(chan Event, 20)
+	go func() {
+		defer close(ch)
+		defer rsp.Body.Close()
+		// events come in application/json-seq, like logs do
+		scanner := bufio.NewScanner(rsp.Body)
+		for scanner.Scan() {
+			buf := scanner.Bytes()
+			idx := bytes.IndexByte(buf, 0x1E)
+			if idx < 0 {
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal(buf[idx+1:], &ev); err != nil {
+				continue
+			}
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
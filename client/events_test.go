@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientEvents(c *check.C) {
+	cs.rsp = "\x1e" + `{"type":"task-progress","time":"2021-04-21T01:02:03Z","change-id":"42","change-kind":"install-snap","task":{"id":"1","kind":"download-snap","summary":"Download","status":"Doing","progress":{"label":"foo","done":1,"total":2}}}` + "\n" +
+		"junk without RS\n" +
+		"\x1e" + `{"type":"change-status","time":"2021-04-21T01:02:04Z","change-id":"42","change-kind":"install-snap","status":"Error","ready":true,"err":"boom"}` + "\n" +
+		"\x1e" + `{"type":"warning","time":"2021-04-21T01:02:05Z","message":"hello"}` + "\n"
+
+	ch, err := cs.cli.Events(context.Background(), &client.EventsOptions{
+		ChangeID:   "42",
+		ChangeKind: "install-snap",
+		Types:      []string{"change-status", "task-progress"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/events")
+	// events cannot have a deadline as they are streamed
+	_, ok := cs.req.Context().Deadline()
+	c.Check(ok, check.Equals, false)
+	query := cs.req.URL.Query()
+	c.Check(query, check.HasLen, 3)
+	c.Check(query.Get("change-id"), check.Equals, "42")
+	c.Check(query.Get("change-kind"), check.Equals, "install-snap")
+	c.Check(query.Get("types"), check.Equals, "change-status,task-progress")
+
+	var events []client.Event
+	for ev := range ch {
+		events = append(events, ev)
+	}
+	c.Check(events, check.DeepEquals, []client.Event{
+		{
+			Type:       "task-progress",
+			Time:       time.Date(2021, 4, 21, 1, 2, 3, 0, time.UTC),
+			ChangeID:   "42",
+			ChangeKind: "install-snap",
+			Task: &client.Task{
+				ID:       "1",
+				Kind:     "download-snap",
+				Summary:  "Download",
+				Status:   "Doing",
+				Progress: client.TaskProgress{Label: "foo", Done: 1, Total: 2},
+			},
+		}, {
+			Type:       "change-status",
+			Time:       time.Date(2021, 4, 21, 1, 2, 4, 0, time.UTC),
+			ChangeID:   "42",
+			ChangeKind: "install-snap",
+			Status:     "Error",
+			Ready:      true,
+			Err:        "boom",
+		}, {
+			Type:    "warning",
+			Time:    time.Date(2021, 4, 21, 1, 2, 5, 0, time.UTC),
+			Message: "hello",
+		},
+	})
+}
+
+func (cs *clientSuite) TestClientEventsNoOptions(c *check.C) {
+	ch, err := cs.cli.Events(context.Background(), nil)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/events")
+	c.Check(cs.req.URL.Query(), check.HasLen, 0)
+	for range ch {
+	}
+}
+
+func (cs *clientSuite) TestClientEventsError(c *check.C) {
+	cs.status = 404
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "cannot find change with id \"42\"", "kind": "not-found"}}`
+
+	_, err := cs.cli.Events(context.Background(), &client.EventsOptions{ChangeID: "42"})
+	c.Check(err, check.ErrorMatches, `cannot find change with id "42"`)
+}
@@ -0,0 +1,49 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SnapHealthHistory holds the health of a snap, together with the history
+// of its health transitions.
+type SnapHealthHistory struct {
+	Snap string `json:"snap"`
+	// CheckInterval is how often the check-health hook of the snap is
+	// run periodically, if at all
+	CheckInterval string        `json:"check-interval,omitempty"`
+	Health        *SnapHealth   `json:"health,omitempty"`
+	History       []*SnapHealth `json:"history,omitempty"`
+}
+
+// Health returns the health of the given snaps, or of all of them if no
+// names are given, with the history of their health transitions.
+func (client *Client) Health(names []string) ([]*SnapHealthHistory, error) {
+	q := make(url.Values)
+	if len(names) > 0 {
+		q.Add("snaps", strings.Join(names, ","))
+	}
+
+	var healths []*SnapHealthHistory
+	_, err := client.doSync("GET", "/v2/health", q, nil, nil, &healths)
+	return healths, err
+}
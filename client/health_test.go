@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (cs *clientSuite) TestClientHealth(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{
+			"snap": "foo",
+			"check-interval": "10m0s",
+			"health": {"revision": "2", "timestamp": "2021-01-01T12:30:00Z", "status": "error", "message": "broken"},
+			"history": [
+				{"revision": "2", "timestamp": "2021-01-01T12:00:00Z", "status": "okay"},
+				{"revision": "2", "timestamp": "2021-01-01T12:20:00Z", "status": "error", "message": "broken"}
+			]
+		}]
+	}`
+	healths, err := cs.cli.Health([]string{"foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/health")
+	c.Check(cs.req.URL.Query().Get("snaps"), check.Equals, "foo,bar")
+
+	t0 := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.Check(healths, check.DeepEquals, []*client.SnapHealthHistory{{
+		Snap:          "foo",
+		CheckInterval: "10m0s",
+		Health: &client.SnapHealth{
+			Revision:  snap.R(2),
+			Timestamp: t0.Add(30 * time.Minute),
+			Status:    "error",
+			Message:   "broken",
+		},
+		History: []*client.SnapHealth{
+			{Revision: snap.R(2), Timestamp: t0, Status: "okay"},
+			{Revision: snap.R(2), Timestamp: t0.Add(20 * time.Minute), Status: "error", Message: "broken"},
+		},
+	}})
+}
+
+func (cs *clientSuite) TestClientHealthAll(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": []}`
+	healths, err := cs.cli.Health(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Query(), check.HasLen, 0)
+	c.Check(healths, check.HasLen, 0)
+}
@@ -23,30 +23,62 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"golang.org/x/xerrors"
 )
 
+// QuotaCPUValues are the CPU limits of a quota group.
+type QuotaCPUValues struct {
+	// Count is the number of CPUs the percentage applies to.
+	Count int `json:"count,omitempty"`
+	// Percentage is the percentage of a single CPU allowed per CPU in Count.
+	Percentage int `json:"percentage,omitempty"`
+	// CPUs is the set of CPUs the snaps in the group are allowed to run on.
+	CPUs []int `json:"cpus,omitempty"`
+}
+
+// QuotaJournalValues are the journal namespace limits of a quota group.
+type QuotaJournalValues struct {
+	Size            uint64        `json:"size,omitempty"`
+	RateLimitCount  int           `json:"rate-limit-count,omitempty"`
+	RateLimitPeriod time.Duration `json:"rate-limit-period,omitempty"`
+}
+
+// QuotaValues are the resource limits of a quota group. Unset (zero) values
+// are not limited when creating a group and left unchanged when updating one.
+type QuotaValues struct {
+	MaxMemory  uint64              `json:"max-memory,omitempty"`
+	CPU        *QuotaCPUValues     `json:"cpu,omitempty"`
+	MaxThreads int                 `json:"max-threads,omitempty"`
+	Journal    *QuotaJournalValues `json:"journal,omitempty"`
+}
+
 type postQuotaData struct {
 	Action    string   `json:"action"`
 	GroupName string   `json:"group-name"`
 	Parent    string   `json:"parent,omitempty"`
 	Snaps     []string `json:"snaps,omitempty"`
-	MaxMemory uint64   `json:"max-memory,omitempty"`
+	QuotaValues
 }
 
 type QuotaGroupResult struct {
-	GroupName     string   `json:"group-name"`
-	Parent        string   `json:"parent,omitempty"`
-	Subgroups     []string `json:"subgroups,omitempty"`
-	Snaps         []string `json:"snaps,omitempty"`
-	MaxMemory     uint64   `json:"max-memory"`
-	CurrentMemory uint64   `json:"current-memory"`
+	GroupName      string              `json:"group-name"`
+	Parent         string              `json:"parent,omitempty"`
+	Subgroups      []string            `json:"subgroups,omitempty"`
+	Snaps          []string            `json:"snaps,omitempty"`
+	MaxMemory      uint64              `json:"max-memory"`
+	CPU            *QuotaCPUValues     `json:"cpu,omitempty"`
+	MaxThreads     int                 `json:"max-threads,omitempty"`
+	Journal        *QuotaJournalValues `json:"journal,omitempty"`
+	CurrentMemory  uint64              `json:"current-memory"`
+	CurrentThreads int                 `json:"current-threads,omitempty"`
 }
 
 // EnsureQuota creates a quota group or updates an existing group.
-// The list of snaps can be empty.
-func (client *Client) EnsureQuota(groupName string, parent string, snaps []string, maxMemory uint64) error {
+// The list of snaps can be empty, as well as the limits when only snaps are
+// added to an existing group.
+func (client *Client) EnsureQuota(groupName string, parent string, snaps []string, limits *QuotaValues) error {
 	if groupName == "" {
 		return xerrors.Errorf("cannot create or update quota group without a name")
 	}
@@ -57,7 +89,9 @@ func (client *Client) EnsureQuota(groupName string, parent string, snaps []strin
 		GroupName: groupName,
 		Parent:    parent,
 		Snaps:     snaps,
-		MaxMemory: maxMemory,
+	}
+	if limits != nil {
+		data.QuotaValues = *limits
 	}
 
 	var body bytes.Buffer
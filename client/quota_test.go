@@ -22,6 +22,7 @@ package client_test
 import (
 	"encoding/json"
 	"io/ioutil"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -29,7 +30,7 @@ import (
 )
 
 func (cs *clientSuite) TestCreateQuotaGroupInvalidName(c *check.C) {
-	err := cs.cli.EnsureQuota("", "", nil, 0)
+	err := cs.cli.EnsureQuota("", "", nil, nil)
 	c.Check(err, check.ErrorMatches, `cannot create or update quota group without a name`)
 }
 
@@ -39,7 +40,7 @@ func (cs *clientSuite) TestEnsureQuotaGroup(c *check.C) {
 		"status-code": 200
 	}`
 
-	c.Assert(cs.cli.EnsureQuota("foo", "bar", []string{"snap-a", "snap-b"}, 1001), check.IsNil)
+	c.Assert(cs.cli.EnsureQuota("foo", "bar", []string{"snap-a", "snap-b"}, &client.QuotaValues{MaxMemory: 1001}), check.IsNil)
 	c.Check(cs.req.Method, check.Equals, "POST")
 	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas")
 	body, err := ioutil.ReadAll(cs.req.Body)
@@ -56,10 +57,56 @@ func (cs *clientSuite) TestEnsureQuotaGroup(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestEnsureQuotaGroupAllLimits(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200
+	}`
+
+	limits := &client.QuotaValues{
+		MaxMemory: 1001,
+		CPU: &client.QuotaCPUValues{
+			Count:      2,
+			Percentage: 50,
+			CPUs:       []int{0, 1},
+		},
+		MaxThreads: 64,
+		Journal: &client.QuotaJournalValues{
+			Size:            1024,
+			RateLimitCount:  10,
+			RateLimitPeriod: time.Second,
+		},
+	}
+	c.Assert(cs.cli.EnsureQuota("foo", "", nil, limits), check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req, check.DeepEquals, map[string]interface{}{
+		"action":     "ensure",
+		"group-name": "foo",
+		"max-memory": float64(1001),
+		"cpu": map[string]interface{}{
+			"count":      float64(2),
+			"percentage": float64(50),
+			"cpus":       []interface{}{float64(0), float64(1)},
+		},
+		"max-threads": float64(64),
+		"journal": map[string]interface{}{
+			"size":              float64(1024),
+			"rate-limit-count":  float64(10),
+			"rate-limit-period": float64(time.Second),
+		},
+	})
+}
+
 func (cs *clientSuite) TestEnsureQuotaGroupError(c *check.C) {
 	cs.status = 500
 	cs.rsp = `{"type": "error"}`
-	err := cs.cli.EnsureQuota("foo", "bar", []string{"snap-a"}, 1)
+	err := cs.cli.EnsureQuota("foo", "bar", []string{"snap-a"}, &client.QuotaValues{MaxMemory: 1})
 	c.Check(err, check.ErrorMatches, `cannot create or update quota group: server error: "Internal Server Error"`)
 }
 
@@ -46,6 +46,8 @@ type SnapOptions struct {
 	Amend            bool   `json:"amend,omitempty"`
 
 	Users []string `json:"users,omitempty"`
+	// Passphrase, if set, is used to encrypt the snapshots taken
+	Passphrase string `json:"passphrase,omitempty"`
 }
 
 func writeFieldBool(mw *multipart.Writer, key string, val bool) error {
@@ -89,9 +91,11 @@ type actionData struct {
 }
 
 type multiActionData struct {
-	Action string   `json:"action"`
-	Snaps  []string `json:"snaps,omitempty"`
-	Users  []string `json:"users,omitempty"`
+	Action     string   `json:"action"`
+	Snaps      []string `json:"snaps,omitempty"`
+	Users      []string `json:"users,omitempty"`
+	Passphrase string   `json:"passphrase,omitempty"`
+	FromDir    string   `json:"from-dir,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -123,6 +127,19 @@ func (client *Client) RefreshMany(names []string, options *SnapOptions) (changeI
 	return client.doMultiSnapAction("refresh", names, options)
 }
 
+// RefreshManyFromDir refreshes the given snaps (all, if names is empty)
+// using the snaps and assertions found in the given directory instead of
+// the store. The directory needs to be an absolute path accessible to
+// snapd, and can go away as soon as this returns.
+func (client *Client) RefreshManyFromDir(names []string, dir string) (changeID string, err error) {
+	_, changeID, err = client.postMultiSnapAction(&multiActionData{
+		Action:  "refresh",
+		Snaps:   names,
+		FromDir: dir,
+	})
+	return changeID, err
+}
+
 func (client *Client) Enable(name string, options *SnapOptions) (changeID string, err error) {
 	return client.doSnapAction("enable", name, options)
 }
@@ -143,7 +160,14 @@ func (client *Client) Switch(name string, options *SnapOptions) (changeID string
 
 // SnapshotMany snapshots many snaps (all, if names empty) for many users (all, if users is empty).
 func (client *Client) SnapshotMany(names []string, users []string) (setID uint64, changeID string, err error) {
-	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, &SnapOptions{Users: users})
+	return client.SnapshotManyWithOptions(names, &SnapOptions{Users: users})
+}
+
+// SnapshotManyWithOptions is like SnapshotMany, with the users given in
+// the options, which can also ask for the snapshots to be encrypted with
+// a passphrase.
+func (client *Client) SnapshotManyWithOptions(names []string, options *SnapOptions) (setID uint64, changeID string, err error) {
+	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, options)
 	if err != nil {
 		return 0, "", err
 	}
@@ -198,8 +222,13 @@ func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, o
 	}
 	if options != nil {
 		action.Users = options.Users
+		action.Passphrase = options.Passphrase
 	}
-	data, err := json.Marshal(&action)
+	return client.postMultiSnapAction(&action)
+}
+
+func (client *Client) postMultiSnapAction(action *multiActionData) (result json.RawMessage, changeID string, err error) {
+	data, err := json.Marshal(action)
 	if err != nil {
 		return nil, "", fmt.Errorf("cannot marshal multi-snap action: %s", err)
 	}
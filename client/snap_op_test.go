@@ -186,6 +186,31 @@ func (cs *clientSuite) TestClientMultiOpSnap(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientRefreshManyFromDir(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	id, err := cs.cli.RefreshManyFromDir([]string{pkgName}, "/media/usb/snaps")
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Assert(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]interface{})
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":   "refresh",
+		"snaps":    []interface{}{pkgName},
+		"from-dir": "/media/usb/snaps",
+	})
+}
+
 func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	// Note body is essentially the same as TestClientMultiOpSnap; keep in sync
 	cs.status = 202
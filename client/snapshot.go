@@ -42,6 +42,7 @@ const SnapshotExportMediaType = "application/x.snapd.snapshot"
 var (
 	ErrSnapshotSetNotFound   = errors.New("no snapshot set with the given ID")
 	ErrSnapshotSnapsNotFound = errors.New("no snapshot for the requested snaps found in the set with the given ID")
+	ErrSnapshotEncrypted     = errors.New("snapshot set is encrypted: a passphrase or private key is needed to restore it")
 )
 
 // A snapshotAction is used to request an operation on a snapshot.
@@ -50,6 +51,21 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+
+	Passphrase string `json:"passphrase,omitempty"`
+	PrivateKey string `json:"private-key,omitempty"`
+
+	Name string `json:"name,omitempty"`
+}
+
+// SnapshotSecrets holds what is needed to restore encrypted snapshots.
+type SnapshotSecrets struct {
+	// Passphrase the snapshots were encrypted with, or that unlocks
+	// PrivateKey
+	Passphrase string
+	// PrivateKey is an ASCII-armored OpenPGP private key, for snapshots
+	// encrypted with the matching public key
+	PrivateKey string
 }
 
 // A Snapshot is a collection of archives with a simple metadata json file
@@ -68,15 +84,28 @@ type Snapshot struct {
 	Summary  string        `json:"summary"`
 	Version  string        `json:"version"`
 
-	// the snap's configuration at snapshot time
+	// the snap's configuration at snapshot time; for encrypted
+	// snapshots this is stored encrypted and only available on restore
 	Conf map[string]interface{} `json:"conf,omitempty"`
 
 	// the hash of the archives' data, keyed by archive path
 	// (either 'archive.tgz' for the system archive, or
-	// user/<username>.tgz for each user)
+	// user/<username>.tgz for each user; 'archive.tar' and
+	// user/<username>.tar for incremental snapshots)
 	SHA3_384 map[string]string `json:"sha3-384"`
 	// the sum of the archive sizes
 	Size int64 `json:"size,omitempty"`
+	// set if the archives are stored in the chunk store shared by all
+	// incremental snapshots, instead of in the snapshot itself
+	Incremental bool `json:"incremental,omitempty"`
+	// the disk space used by an incremental snapshot, not counting
+	// data already stored by older snapshots; this is not stored in
+	// the snapshot but computed for snapshots returned by List().
+	DiskSize int64 `json:"disk-size,omitempty"`
+	// how the archives and configuration are encrypted, if they are:
+	// "passphrase" or "public-key"; the hashes of encrypted snapshots
+	// are those of the encrypted data.
+	Encryption string `json:"encryption,omitempty"`
 	// if the snapshot failed to open this will be the reason why
 	Broken string `json:"broken,omitempty"`
 
@@ -85,6 +114,10 @@ type Snapshot struct {
 	// newer snapd just updates this flag on the fly for snapshots
 	// returned by List().
 	Auto bool `json:"auto,omitempty"`
+	// set if the snapshot was taken according to the snapshots.schedule
+	// system option; this is not stored in the snapshot but updated on
+	// the fly for snapshots returned by List().
+	Scheduled bool `json:"scheduled,omitempty"`
 }
 
 // IsValid checks whether the snapshot is missing information that
@@ -100,6 +133,8 @@ func (sh *Snapshot) ContentHash() ([]byte, error) {
 	sh2.SetID = 0
 	sh2.Time = time.Time{}
 	sh2.Auto = false
+	sh2.Scheduled = false
+	sh2.DiskSize = 0
 	h := sha256.New()
 	enc := json.NewEncoder(h)
 	if err := enc.Encode(&sh2); err != nil {
@@ -205,11 +240,43 @@ func (client *Client) CheckSnapshots(setID uint64, snaps []string, users []strin
 // If snaps or users are non-empty, limit to checking only those
 // archives of the snapshot.
 func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
-	return client.snapshotAction(&snapshotAction{
+	return client.RestoreSnapshotsWithSecrets(setID, snaps, users, nil)
+}
+
+// RestoreSnapshotsWithSecrets is like RestoreSnapshots, for snapshot sets
+// that are encrypted.
+func (client *Client) RestoreSnapshotsWithSecrets(setID uint64, snaps []string, users []string, secrets *SnapshotSecrets) (changeID string, err error) {
+	action := &snapshotAction{
 		SetID:  setID,
 		Action: "restore",
 		Snaps:  snaps,
 		Users:  users,
+	}
+	if secrets != nil {
+		action.Passphrase = secrets.Passphrase
+		action.PrivateKey = secrets.PrivateKey
+	}
+	return client.snapshotAction(action)
+}
+
+// PushSnapshots pushes an export of the given snapshot set to the remote
+// configured with the snapshots.remote.* options, as name. If name is
+// empty, snapd picks one based on the set ID.
+func (client *Client) PushSnapshots(setID uint64, name string) (changeID string, err error) {
+	return client.snapshotAction(&snapshotAction{
+		SetID:  setID,
+		Action: "push",
+		Name:   name,
+	})
+}
+
+// PullSnapshots pulls the snapshot set exported as name from the remote,
+// and imports it. The ID of the imported set is given by the "set-id" of
+// the data of the change once it is ready.
+func (client *Client) PullSnapshots(name string) (changeID string, err error) {
+	return client.snapshotAction(&snapshotAction{
+		Action: "pull",
+		Name:   name,
 	})
 }
 
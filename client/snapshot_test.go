@@ -143,6 +143,46 @@ func (cs *clientSuite) TestClientRestoreSnapshots(c *check.C) {
 	cs.testClientSnapshotAction(c, "restore", cs.cli.RestoreSnapshots)
 }
 
+func (cs *clientSuite) TestClientPushSnapshots(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"status-code": 202,
+		"type": "async",
+		"change": "1too3"
+	}`
+	id, err := cs.cli.PushSnapshots(42, "backup")
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "1too3")
+
+	act, err := client.UnmarshalSnapshotAction(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(act.SetID, check.Equals, uint64(42))
+	c.Check(act.Action, check.Equals, "push")
+	c.Check(act.Name, check.Equals, "backup")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+}
+
+func (cs *clientSuite) TestClientPullSnapshots(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"status-code": 202,
+		"type": "async",
+		"change": "1too3"
+	}`
+	id, err := cs.cli.PullSnapshots("backup")
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "1too3")
+
+	act, err := client.UnmarshalSnapshotAction(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(act.SetID, check.Equals, uint64(0))
+	c.Check(act.Action, check.Equals, "pull")
+	c.Check(act.Name, check.Equals, "backup")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+}
+
 func (cs *clientSuite) TestClientExportSnapshot(c *check.C) {
 	type tableT struct {
 		content     string
@@ -245,6 +285,12 @@ func (cs *clientSuite) TestClientSnapshotContentHash(c *check.C) {
 	c.Assert(err, check.IsNil)
 	c.Check(h1, check.DeepEquals, h2)
 
+	// same except disk size and scheduled means same hash
+	sh2_1 := &client.Snapshot{SetID: 1, Time: now, Snap: "asnap", Revision: revno, SHA3_384: sums, DiskSize: 1234, Scheduled: true}
+	h2_1, err := sh2_1.ContentHash()
+	c.Assert(err, check.IsNil)
+	c.Check(h1, check.DeepEquals, h2_1)
+
 	// sh3 is actually different
 	sh3 := &client.Snapshot{SetID: 1, Time: now, Snap: "other-snap", Revision: revno, SHA3_384: sums}
 	h3, err := sh3.ContentHash()
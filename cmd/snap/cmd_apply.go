@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var (
+	shortApplyHelp = i18n.G("Bring the system to the state of a manifest")
+	longApplyHelp  = i18n.G(`
+The apply command brings the system to the state described by the given
+manifest, in YAML or JSON: the snaps it lists are installed or refreshed to
+the given channel or revision and configured, the connections it lists are
+made and the quota groups it lists are created or updated, all in a single
+change. Whatever the manifest does not mention is left alone.
+
+With --dry-run, the actions that would be performed are shown, and nothing
+is changed.
+`)
+)
+
+type cmdApply struct {
+	waitMixin
+	DryRun     bool `long:"dry-run"`
+	Positional struct {
+		Manifest flags.Filename
+	} `positional-args:"true" required:"true"`
+}
+
+func init() {
+	addCommand("apply", shortApplyHelp, longApplyHelp, func() flags.Commander {
+		return &cmdApply{}
+	}, waitDescs.also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"dry-run": i18n.G("Only show what would be done"),
+	}), []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<manifest>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("The manifest describing the desired state"),
+	}})
+}
+
+func (x *cmdApply) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	manifest, err := ioutil.ReadFile(string(x.Positional.Manifest))
+	if err != nil {
+		return err
+	}
+
+	plan, changeID, err := x.client.Apply(manifest, &client.ApplyOptions{DryRun: x.DryRun})
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("The system is already in the state of the manifest."))
+		return nil
+	}
+	for _, action := range plan {
+		fmt.Fprintln(Stdout, action.Summary)
+	}
+	if x.DryRun {
+		return nil
+	}
+
+	if _, err := x.wait(changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const applyTestManifest = `snaps: [{name: foo, channel: edge}]`
+
+func (s *SnapSuite) writeApplyManifest(c *C) string {
+	path := filepath.Join(c.MkDir(), "manifest.yaml")
+	c.Assert(ioutil.WriteFile(path, []byte(applyTestManifest), 0644), IsNil)
+	return path
+}
+
+func (s *SnapSuite) TestApply(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch r.URL.Path {
+		case "/v2/apply":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Query(), HasLen, 0)
+			body, err := ioutil.ReadAll(r.Body)
+			c.Check(err, IsNil)
+			c.Check(string(body), Equals, applyTestManifest)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42", "result": {"plan": [
+				{"kind": "install", "target": "foo", "summary": "Install \"foo\" snap from channel \"edge\""},
+				{"kind": "configure", "target": "foo", "summary": "Set bar of \"foo\" snap"}
+			]}}`)
+		case "/v2/changes/42":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"apply", s.writeApplyManifest(c)})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `Install "foo" snap from channel "edge"
+Set bar of "foo" snap
+`)
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 2)
+}
+
+func (s *SnapSuite) TestApplyDryRun(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/apply")
+		c.Check(r.URL.Query().Get("dry-run"), Equals, "true")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"plan": [
+			{"kind": "refresh", "target": "foo", "summary": "Refresh \"foo\" snap from channel \"edge\""}
+		]}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"apply", "--dry-run", s.writeApplyManifest(c)})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Refresh \"foo\" snap from channel \"edge\"\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestApplyNothingToDo(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/apply")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"plan": null}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"apply", s.writeApplyManifest(c)})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "The system is already in the state of the manifest.\n")
+}
+
+func (s *SnapSuite) TestApplyError(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 400, "result": {"message": "invalid manifest: boom"}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"apply", s.writeApplyManifest(c)})
+	c.Assert(err, ErrorMatches, "invalid manifest: boom")
+}
@@ -44,12 +44,33 @@ import (
 	"github.com/snapcore/snapd/snapdenv"
 )
 
-const autoImportsName = "auto-import.assert"
+const (
+	autoImportsName = "auto-import.assert"
+	// autoImportSnapsDirName is the directory holding snaps and their
+	// assertions to refresh from
+	autoImportSnapsDirName = "auto-import-snaps"
+)
 
 var mountInfoPath = "/proc/self/mountinfo"
 
 func autoImportCandidates() ([]string, error) {
+	mountPoints, err := autoImportMountPoints()
+	if err != nil {
+		return nil, err
+	}
+
 	var cands []string
+	for _, mountPoint := range mountPoints {
+		cand := filepath.Join(mountPoint, autoImportsName)
+		if osutil.FileExists(cand) {
+			cands = append(cands, cand)
+		}
+	}
+	return cands, nil
+}
+
+func autoImportMountPoints() ([]string, error) {
+	var mountPoints []string
 
 	// see https://www.kernel.org/doc/Documentation/filesystems/proc.txt,
 	// sec. 3.5
@@ -115,13 +136,10 @@ func autoImportCandidates() ([]string, error) {
 			continue
 		}
 
-		cand := filepath.Join(mountPoint, autoImportsName)
-		if osutil.FileExists(cand) {
-			cands = append(cands, cand)
-		}
+		mountPoints = append(mountPoints, mountPoint)
 	}
 
-	return cands, scanner.Err()
+	return mountPoints, scanner.Err()
 }
 
 func queueFile(src string) error {
@@ -207,6 +225,33 @@ func autoImportFromAllMounts(cli *client.Client) (int, error) {
 	return added, nil
 }
 
+// autoRefreshFromAllMounts refreshes from the auto-import-snaps directory
+// of the mounted devices that have one. snapd copies the snaps over before
+// the request returns, so the devices can be unmounted right after.
+func autoRefreshFromAllMounts(cli *client.Client) error {
+	mountPoints, err := autoImportMountPoints()
+	if err != nil {
+		return err
+	}
+
+	for _, mountPoint := range mountPoints {
+		dir := filepath.Join(mountPoint, autoImportSnapsDirName)
+		if !osutil.IsDirectory(dir) {
+			continue
+		}
+		// unlike assertions these are not queued for later, snaps
+		// are too big for that
+		changeID, err := cli.RefreshManyFromDir(nil, dir)
+		if err != nil {
+			logger.Noticef("error: cannot refresh from %s: %s", dir, err)
+			continue
+		}
+		logger.Noticef("refreshing from %s in change %s", dir, changeID)
+	}
+
+	return nil
+}
+
 var ioutilTempDir = ioutil.TempDir
 
 func tryMount(deviceName string) (string, error) {
@@ -261,6 +306,10 @@ consider all available mounted devices for inspection.
 
 Assertions to be imported must be made available in the auto-import.assert file
 in the root of the filesystem.
+
+Snaps found in the auto-import-snaps directory in the root of the filesystem,
+along with their assertions in .assert files, are used to refresh the installed
+snaps.
 `)
 
 func init() {
@@ -388,6 +437,10 @@ func (x *cmdAutoImport) Execute(args []string) error {
 		return err
 	}
 
+	if err := autoRefreshFromAllMounts(x.client); err != nil {
+		return err
+	}
+
 	if added1+added2 > 0 {
 		return x.autoAddUsers()
 	}
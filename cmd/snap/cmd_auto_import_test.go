@@ -101,6 +101,49 @@ func (s *SnapSuite) TestAutoImportAssertsHappy(c *C) {
 	c.Check(n, Equals, total)
 }
 
+func (s *SnapSuite) TestAutoImportRefreshFromSnapsDir(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	mountPoint := c.MkDir()
+	snapsDir := filepath.Join(mountPoint, "auto-import-snaps")
+	c.Assert(os.Mkdir(snapsDir, 0755), IsNil)
+
+	n := 0
+	total := 1
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/snaps")
+			postData, err := ioutil.ReadAll(r.Body)
+			c.Assert(err, IsNil)
+			c.Check(string(postData), Equals, fmt.Sprintf(`{"action":"refresh","from-dir":%q}`, snapsDir))
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+			n++
+		default:
+			c.Fatalf("unexpected request: %v (expected %d got %d)", r, total, n)
+		}
+	})
+
+	mockMountInfoFmt := `
+24 0 8:18 / %s rw,relatime shared:1 - ext4 /dev/sdb2 rw,errors=remount-ro,data=ordered`
+	content := fmt.Sprintf(mockMountInfoFmt, mountPoint)
+	restore = snap.MockMountInfoPath(makeMockMountInfo(c, content))
+	defer restore()
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"auto-import"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(logbuf.String(), Matches, fmt.Sprintf("(?ms).*refreshing from %s in change 42\n", snapsDir))
+	c.Check(n, Equals, total)
+}
+
 func (s *SnapSuite) TestAutoImportAssertsNotImportedFromLoop(c *C) {
 	restore := release.MockOnClassic(false)
 	defer restore()
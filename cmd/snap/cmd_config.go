@@ -0,0 +1,228 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdConfig struct{}
+
+var shortConfigHelp = i18n.G("Export and import configuration")
+var longConfigHelp = i18n.G(`
+The config command contains sub-commands to export the configuration of the
+system and of snaps to a document, and to import such a document on the same
+or another device.
+`)
+
+var (
+	shortConfigExportHelp = i18n.G("Export the configuration of the system and snaps")
+	longConfigExportHelp  = i18n.G(`
+The export command writes a document of the configuration of the system and
+of all snaps, or only of the given snaps ("system" being the system
+configuration), to the standard output or to the file given with --output.
+Secret values are left out of the document.
+
+With --sign-with, the document is followed by its signature made with the
+given ASCII-armored OpenPGP private key, and --passphrase asks for the
+passphrase protecting the key if any.
+`)
+
+	shortConfigImportHelp = i18n.G("Import configuration of the system and snaps")
+	longConfigImportHelp  = i18n.G(`
+The import command applies the configuration of a document written by
+"snap config export", read from the given file or from the standard input
+if the file is "-". The configure hooks of the snaps whose configuration
+changes are run in a single change: the system is configured first, then
+the snaps providing slots before the snaps whose plugs are connected to
+them. When the configuration of a snap fails, the snaps that depend on it
+are not configured; the outcome for each snap is shown.
+
+A signed document is only imported if its signature is verified with the
+ASCII-armored OpenPGP public key given with --verify-with.
+`)
+)
+
+type cmdConfigExport struct {
+	clientMixin
+	SignWith   string `long:"sign-with" value-name:"<key file>"`
+	Passphrase bool   `long:"passphrase"`
+	Output     string `long:"output" short:"o" value-name:"<file>"`
+	Positional struct {
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+type cmdConfigImport struct {
+	waitMixin
+	VerifyWith string `long:"verify-with" value-name:"<key file>"`
+	Positional struct {
+		File flags.Filename `positional-arg-name:"<file>" required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addConfigCommand("export", shortConfigExportHelp, longConfigExportHelp, func() flags.Commander {
+		return &cmdConfigExport{}
+	}, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"sign-with": i18n.G("Sign the document with the private key in the given file"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"passphrase": i18n.G("Ask for the passphrase of the private key"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"output": i18n.G("Write the document to the given file"),
+	}, []argDesc{{
+		name: "<snap>",
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("The snaps whose configuration to export"),
+	}})
+	addConfigCommand("import", shortConfigImportHelp, longConfigImportHelp, func() flags.Commander {
+		return &cmdConfigImport{}
+	}, waitDescs.also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"verify-with": i18n.G("Verify the signature of the document with the public key in the given file"),
+	}), []argDesc{{
+		name: "<file>",
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("The document to import"),
+	}})
+}
+
+func (x *cmdConfigExport) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if x.Passphrase && x.SignWith == "" {
+		return fmt.Errorf(i18n.G("cannot use --passphrase without --sign-with"))
+	}
+
+	opts := &client.ExportConfOptions{
+		Snaps: installedSnapNames(x.Positional.Snaps),
+	}
+	if x.SignWith != "" {
+		key, err := ioutil.ReadFile(x.SignWith)
+		if err != nil {
+			return fmt.Errorf(i18n.G("cannot read private key: %v"), err)
+		}
+		opts.PrivateKey = string(key)
+		if x.Passphrase {
+			fmt.Fprint(Stdout, i18n.G("Passphrase: "))
+			passphrase, err := ReadPassword(0)
+			fmt.Fprint(Stdout, "\n")
+			if err != nil {
+				return err
+			}
+			opts.Passphrase = string(passphrase)
+		}
+	}
+
+	doc, err := x.client.ExportConf(opts)
+	if err != nil {
+		return err
+	}
+	if x.Output == "" {
+		fmt.Fprint(Stdout, doc)
+		return nil
+	}
+	// the document can hold sensitive configuration
+	return ioutil.WriteFile(x.Output, []byte(doc), 0600)
+}
+
+// configImportStatus returns what happened to the configuration of a snap
+// given the status of its configure task.
+func configImportStatus(status string) string {
+	switch status {
+	case "Done", "Undone":
+		// configure hooks cannot be undone, the configuration of
+		// the snap stays imported
+		return i18n.G("imported")
+	case "Error":
+		return i18n.G("failed")
+	default:
+		return i18n.G("skipped")
+	}
+}
+
+func (x *cmdConfigImport) showImportStatus(chg *client.Change) {
+	var configTasks map[string]string
+	if err := chg.Get("config-tasks", &configTasks); err != nil {
+		return
+	}
+	statuses := make(map[string]string, len(chg.Tasks))
+	for _, t := range chg.Tasks {
+		statuses[t.ID] = t.Status
+	}
+	names := make([]string, 0, len(configTasks))
+	for name := range configTasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Snap\tConfiguration"))
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, configImportStatus(statuses[configTasks[name]]))
+	}
+	w.Flush()
+}
+
+func (x *cmdConfigImport) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	var doc []byte
+	var err error
+	if x.Positional.File == "-" {
+		doc, err = ioutil.ReadAll(Stdin)
+	} else {
+		doc, err = ioutil.ReadFile(string(x.Positional.File))
+	}
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read configuration document: %v"), err)
+	}
+	var publicKey []byte
+	if x.VerifyWith != "" {
+		publicKey, err = ioutil.ReadFile(x.VerifyWith)
+		if err != nil {
+			return fmt.Errorf(i18n.G("cannot read public key: %v"), err)
+		}
+	}
+
+	changeID, err := x.client.ImportConf(string(doc), string(publicKey))
+	if err != nil {
+		return err
+	}
+	chg, err := x.wait(changeID)
+	if err == noWait {
+		return nil
+	}
+	if chg != nil {
+		x.showImportStatus(chg)
+	}
+	return err
+}
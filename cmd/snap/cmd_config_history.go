@@ -0,0 +1,145 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var (
+	shortConfigHistoryHelp = i18n.G("Show the configuration history of a snap")
+	longConfigHistoryHelp  = i18n.G(`
+The config-history command shows the recent changes of the configuration of
+the given snap: when they were made, by whom, in which change, and which
+options they changed. With --verbose, the old and new value of each option
+is shown as well.
+
+With --revert, the configuration of the snap is reverted to what it was after
+the change with the given ID, and its configure hook is run again.
+`)
+)
+
+type cmdConfigHistory struct {
+	waitMixin
+	timeMixin
+	Verbose    bool `long:"verbose"`
+	Revert     int  `long:"revert" value-name:"<id>"`
+	Positional struct {
+		Snap installedSnapName `required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addCommand("config-history", shortConfigHistoryHelp, longConfigHistoryHelp, func() flags.Commander {
+		return &cmdConfigHistory{}
+	}, waitDescs.also(timeDescs).also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"verbose": i18n.G("Show the old and new values of the options"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"revert": i18n.G("Revert the configuration to what it was after the given change"),
+	}), []argDesc{{
+		name: "<snap>",
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("The snap whose configuration history to show"),
+	}})
+}
+
+// fmtConfValue returns the value of an option as shown in the history, or
+// "-" for an unset option.
+func fmtConfValue(value interface{}) string {
+	if value == nil {
+		return "-"
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(b)
+}
+
+func (x *cmdConfigHistory) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	snapName := string(x.Positional.Snap)
+
+	if x.Revert != 0 {
+		changeID, err := x.client.RevertConf(snapName, x.Revert)
+		if err != nil {
+			return err
+		}
+		if _, err := x.wait(changeID); err != nil {
+			if err == noWait {
+				return nil
+			}
+			return err
+		}
+		// TRANSLATORS: the first %q is the snap name, the %d the ID of the change in its configuration history
+		fmt.Fprintf(Stdout, i18n.G("Configuration of snap %q reverted to change %d\n"), snapName, x.Revert)
+		return nil
+	}
+
+	history, err := x.client.ConfHistory(snapName)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		// TRANSLATORS: %q is the snap name
+		fmt.Fprintf(Stderr, i18n.G("No configuration changes of snap %q found.\n"), snapName)
+		return nil
+	}
+
+	w := tabWriter()
+	if x.Verbose {
+		fmt.Fprintln(w, i18n.G("ID\tTime\tUser\tChange\tKey\tOld\tNew"))
+	} else {
+		fmt.Fprintln(w, i18n.G("ID\tTime\tUser\tChange\tKeys"))
+	}
+	for _, entry := range history {
+		user := entry.User
+		if user == "" {
+			user = "-"
+		}
+		changeID := entry.ChangeID
+		if changeID == "" {
+			changeID = "-"
+		}
+		prefix := fmt.Sprintf("%d\t%s\t%s\t%s", entry.ID, x.fmtTime(entry.Time), user, changeID)
+		if !x.Verbose {
+			keys := make([]string, len(entry.Changes))
+			for i, change := range entry.Changes {
+				keys[i] = change.Key
+			}
+			fmt.Fprintf(w, "%s\t%s\n", prefix, strings.Join(keys, ","))
+			continue
+		}
+		for _, change := range entry.Changes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", prefix, change.Key, fmtConfValue(change.Old), fmtConfValue(change.New))
+		}
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const configHistoryJSON = `{"type": "sync", "status-code": 200, "result": [
+	{"id": 1, "time": "2021-05-01T10:00:00Z", "user": "root", "change-id": "42", "changes": [
+		{"key": "foo", "new": "bar"},
+		{"key": "port", "old": 80, "new": 8080}
+	]},
+	{"id": 2, "time": "2021-05-01T11:00:00Z", "changes": [
+		{"key": "foo", "old": "bar"}
+	]}
+]}`
+
+func (s *SnapSuite) TestConfigHistory(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/snaps/foo/conf-history")
+		fmt.Fprintln(w, configHistoryJSON)
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"config-history", "--abs-time", "foo"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `ID   Time                  User  Change  Keys
+1    2021-05-01T10:00:00Z  root  42      foo,port
+2    2021-05-01T11:00:00Z  -     -       foo
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConfigHistoryVerbose(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, configHistoryJSON)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"config-history", "--abs-time", "--verbose", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, `ID   Time                  User  Change  Key   Old    New
+1    2021-05-01T10:00:00Z  root  42      foo   -      "bar"
+1    2021-05-01T10:00:00Z  root  42      port  80     8080
+2    2021-05-01T11:00:00Z  -     -       foo   "bar"  -
+`)
+}
+
+func (s *SnapSuite) TestConfigHistoryNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"config-history", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No configuration changes of snap \"foo\" found.\n")
+}
+
+func (s *SnapSuite) TestConfigHistoryRevert(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch r.URL.Path {
+		case "/v2/snaps/foo/conf-history":
+			c.Check(r.Method, Equals, "POST")
+			body, err := ioutil.ReadAll(r.Body)
+			c.Check(err, IsNil)
+			c.Check(string(body), Equals, `{"action":"revert","id":1}`)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "43"}`)
+		case "/v2/changes/43":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"config-history", "--revert=1", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Configuration of snap \"foo\" reverted to change 1\n")
+	c.Check(n, Equals, 2)
+}
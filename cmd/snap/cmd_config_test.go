@@ -0,0 +1,158 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestConfigExport(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/config")
+		body, err := ioutil.ReadAll(r.Body)
+		c.Check(err, IsNil)
+		c.Check(string(body), Equals, `{"action":"export","snaps":["system","foo"]}`)
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"document": "{\"type\": \"config\"}\n"}}`)
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"config", "export", "system", "foo"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "{\"type\": \"config\"}\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConfigExportSignedToFile(c *C) {
+	dir := c.MkDir()
+	keyFile := filepath.Join(dir, "key.asc")
+	c.Assert(ioutil.WriteFile(keyFile, []byte("private key"), 0600), IsNil)
+	output := filepath.Join(dir, "config.json")
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		c.Check(err, IsNil)
+		c.Check(string(body), Equals, `{"action":"export","private-key":"private key"}`)
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"document": "signed\n"}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"config", "export", "--sign-with", keyFile, "-o", output})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	data, err := ioutil.ReadFile(output)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "signed\n")
+	fi, err := os.Stat(output)
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().Perm(), Equals, os.FileMode(0600))
+}
+
+func (s *SnapSuite) TestConfigExportPassphraseWithoutKey(c *C) {
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"config", "export", "--passphrase"})
+	c.Assert(err, ErrorMatches, "cannot use --passphrase without --sign-with")
+}
+
+const configImportChangeJSON = `{"type": "sync", "result": {
+	"ready": true,
+	"status": "%s",
+	"err": "%s",
+	"tasks": [
+		{"id": "1", "status": "Done"},
+		{"id": "2", "status": "Error"},
+		{"id": "3", "status": "Hold"},
+		{"id": "4", "status": "Undone"}
+	],
+	"data": {"config-tasks": {"system": "1", "provider": "2", "consumer": "3", "lonely": "4"}}
+}}`
+
+func (s *SnapSuite) TestConfigImport(c *C) {
+	docFile := filepath.Join(c.MkDir(), "config.json")
+	c.Assert(ioutil.WriteFile(docFile, []byte(`{"type":"config"}`), 0600), IsNil)
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch r.URL.Path {
+		case "/v2/config":
+			c.Check(r.Method, Equals, "POST")
+			body, err := ioutil.ReadAll(r.Body)
+			c.Check(err, IsNil)
+			c.Check(string(body), Equals, `{"action":"import","document":"{\"type\":\"config\"}"}`)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42"}`)
+		case "/v2/changes/42":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintf(w, configImportChangeJSON, "Error", `cannot perform the following tasks:\n- Run configure hook of \"provider\" snap (run hook \"configure\": boom)`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"config", "import", docFile})
+	c.Assert(err, ErrorMatches, `cannot perform the following tasks:
+- Run configure hook of "provider" snap \(run hook "configure": boom\)`)
+	c.Check(s.Stdout(), Equals, `Snap      Configuration
+consumer  skipped
+lonely    imported
+provider  failed
+system    imported
+`)
+	c.Check(n, Equals, 2)
+}
+
+func (s *SnapSuite) TestConfigImportFromStdinVerified(c *C) {
+	keyFile := filepath.Join(c.MkDir(), "key.asc")
+	c.Assert(ioutil.WriteFile(keyFile, []byte("public key"), 0600), IsNil)
+	s.stdin.WriteString("signed document")
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/config":
+			body, err := ioutil.ReadAll(r.Body)
+			c.Check(err, IsNil)
+			c.Check(string(body), Equals, `{"action":"import","document":"signed document","public-key":"public key"}`)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42"}`)
+		case "/v2/changes/42":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "tasks": [{"id": "1", "status": "Done"}], "data": {"config-tasks": {"foo": "1"}}}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"config", "import", "--verify-with", keyFile, "-"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, `Snap  Configuration
+foo   imported
+`)
+}
+
+func (s *SnapSuite) TestConfigImportMissingFile(c *C) {
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"config", "import", filepath.Join(c.MkDir(), "missing")})
+	c.Assert(err, ErrorMatches, "cannot read configuration document: .*")
+}
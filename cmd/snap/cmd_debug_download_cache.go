@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/strutil"
+)
+
+type cmdDebugDownloadCache struct {
+	clientMixin
+	Prefill string `long:"prefill" value-name:"<dir>"`
+}
+
+func init() {
+	cmd := addDebugCommand("download-cache",
+		"(internal) obtain download cache statistics",
+		"(internal) obtain download cache statistics, or prefill it from a directory",
+		func() flags.Commander {
+			return &cmdDebugDownloadCache{}
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"prefill": i18n.G("Add the snaps in the given seed or download cache directory to the cache"),
+		}, nil)
+	cmd.hidden = true
+}
+
+func (x *cmdDebugDownloadCache) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	if x.Prefill != "" {
+		return x.prefill()
+	}
+
+	var resp struct {
+		Items       int    `json:"items"`
+		Size        int64  `json:"size"`
+		PinnedItems int    `json:"pinned-items"`
+		PinnedSize  int64  `json:"pinned-size"`
+		MaxItems    int    `json:"max-items"`
+		MaxSize     int64  `json:"max-size"`
+		MaxAge      string `json:"max-age"`
+	}
+	if err := x.client.DebugGet("download-cache", &resp, nil); err != nil {
+		return err
+	}
+
+	w := tabWriter()
+	fmt.Fprintf(w, "items:\t%d\n", resp.Items)
+	fmt.Fprintf(w, "size:\t%s\n", strutil.SizeToStr(resp.Size))
+	fmt.Fprintf(w, "pinned-items:\t%d\n", resp.PinnedItems)
+	fmt.Fprintf(w, "pinned-size:\t%s\n", strutil.SizeToStr(resp.PinnedSize))
+	fmt.Fprintf(w, "max-items:\t%d\n", resp.MaxItems)
+	if resp.MaxSize > 0 {
+		fmt.Fprintf(w, "max-size:\t%s\n", strutil.SizeToStr(resp.MaxSize))
+	}
+	if resp.MaxAge != "" {
+		fmt.Fprintf(w, "max-age:\t%s\n", resp.MaxAge)
+	}
+	return w.Flush()
+}
+
+func (x *cmdDebugDownloadCache) prefill() error {
+	// snapd needs an absolute path
+	dir, err := filepath.Abs(x.Prefill)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Added int `json:"added"`
+	}
+	params := map[string]string{"dir": dir}
+	if err := x.client.Debug("prefill-download-cache", params, &resp); err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, i18n.G("Added %d snaps to the download cache.\n"), resp.Added)
+	return nil
+}
@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDebugDownloadCache(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/debug")
+		c.Check(r.URL.Query().Get("aspect"), Equals, "download-cache")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {
+			"items": 3, "size": 2000000, "pinned-items": 1, "pinned-size": 1500000,
+			"max-items": 5, "max-size": 4000000000, "max-age": "720h0m0s"}}`)
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "download-cache"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `
+items:         3
+size:          2MB
+pinned-items:  1
+pinned-size:   1MB
+max-items:     5
+max-size:      4GB
+max-age:       720h0m0s
+`[1:])
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 1)
+}
+
+func (s *SnapSuite) TestDebugDownloadCachePrefill(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v2/debug")
+		c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+			"action": "prefill-download-cache",
+			"params": map[string]interface{}{"dir": "/var/lib/snapd/seed/snaps"},
+		})
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"added": 4}}`)
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "download-cache", "--prefill=/var/lib/snapd/seed/snaps"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Added 4 snaps to the download cache.\n")
+	c.Check(n, Equals, 1)
+}
@@ -48,6 +48,12 @@ Nested values may be retrieved via a dotted path:
 
     $ snap get snap-name author.name
     frank
+
+The default values of the options declared in the configuration schema of
+the snap may be retrieved with --defaults:
+
+    $ snap get --defaults snap-name server.port
+    8080
 `)
 
 type cmdGet struct {
@@ -60,6 +66,7 @@ type cmdGet struct {
 	Typed    bool `short:"t"`
 	Document bool `short:"d"`
 	List     bool `short:"l"`
+	Defaults bool `long:"defaults"`
 }
 
 func init() {
@@ -71,6 +78,8 @@ func init() {
 			"l": i18n.G("Always return list, even with single key"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"t": i18n.G("Strict typing with nulls and quoted strings"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"defaults": i18n.G("Return the default values from the configuration schema"),
 		}, []argDesc{
 			{
 				name: "<snap>",
@@ -225,6 +234,28 @@ func (x *cmdGet) outputDefault(conf map[string]interface{}, snapName string, con
 
 }
 
+// defaultConf returns the default values of the given options, or of all
+// the options that have one, from the configuration schema of the snap.
+func (x *cmdGet) defaultConf(snapName string, confKeys []string) (map[string]interface{}, error) {
+	schema, err := x.client.ConfSchema(snapName)
+	if err != nil {
+		return nil, err
+	}
+	defaults := schema.Defaults()
+	if rootRequested(confKeys) {
+		return defaults, nil
+	}
+	conf := make(map[string]interface{}, len(confKeys))
+	for _, key := range confKeys {
+		value, ok := defaults[key]
+		if !ok {
+			return nil, fmt.Errorf(i18n.G("snap %q has no default value for option %q"), snapName, key)
+		}
+		conf[key] = value
+	}
+	return conf, nil
+}
+
 func (x *cmdGet) Execute(args []string) error {
 	if len(args) > 0 {
 		// TRANSLATORS: the %s is the list of extra arguments
@@ -242,7 +273,13 @@ func (x *cmdGet) Execute(args []string) error {
 	snapName := string(x.Positional.Snap)
 	confKeys := x.Positional.Keys
 
-	conf, err := x.client.Conf(snapName, confKeys)
+	var conf map[string]interface{}
+	var err error
+	if x.Defaults {
+		conf, err = x.defaultConf(snapName, confKeys)
+	} else {
+		conf, err = x.client.Conf(snapName, confKeys)
+	}
 	if err != nil {
 		return err
 	}
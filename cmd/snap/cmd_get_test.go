@@ -144,6 +144,31 @@ func (s *SnapSuite) TestSnapGetNoConfiguration(c *C) {
 	s.runTests(getNoConfigTests, c)
 }
 
+var getDefaultsTests = []getCmdArgs{{
+	args:   "get --defaults snapname port",
+	stdout: "80\n",
+}, {
+	args:   "get --defaults -d snapname",
+	stdout: "{\n\t\"mode\": \"fast\",\n\t\"port\": 80\n}\n",
+}, {
+	args:  "get --defaults snapname token",
+	error: `snap "snapname" has no default value for option "token"`,
+}}
+
+func (s *SnapSuite) TestSnapGetDefaults(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/snaps/snapname/conf")
+		c.Check(r.URL.Query().Get("schema"), Equals, "true")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {
+			"port": {"type": "int", "default": 80},
+			"mode": {"type": "string", "default": "fast"},
+			"token": {"type": "string", "secret": true}
+		}}`)
+	})
+	s.runTests(getDefaultsTests, c)
+}
+
 func (s *SnapSuite) TestSortByPath(c *C) {
 	values := []snapset.ConfigValue{
 		{Path: "test-key3.b"},
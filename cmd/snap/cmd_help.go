@@ -208,7 +208,7 @@ var helpCategories = []helpCategory{
 	}, {
 		Label:       i18n.G("Configuration"),
 		Description: i18n.G("system administration and configuration"),
-		Commands:    []string{"get", "set", "unset", "wait"},
+		Commands:    []string{"get", "set", "unset", "wait", "apply", "config-history", "config"},
 	}, {
 		Label:       i18n.G("App Aliases"),
 		Description: i18n.G("manage aliases"),
@@ -221,7 +221,7 @@ var helpCategories = []helpCategory{
 		Label:           i18n.G("Snapshots"),
 		Description:     i18n.G("archives of snap data"),
 		Commands:        []string{"saved", "save", "check-snapshot", "restore", "forget"},
-		AllOnlyCommands: []string{"export-snapshot", "import-snapshot"},
+		AllOnlyCommands: []string{"export-snapshot", "import-snapshot", "push-snapshot", "pull-snapshot"},
 	}, {
 		Label:       i18n.G("Device"),
 		Description: i18n.G("manage device"),
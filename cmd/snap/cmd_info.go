@@ -50,6 +50,7 @@ type infoCmd struct {
 	timeMixin
 
 	Verbose    bool `long:"verbose"`
+	Health     bool `long:"health"`
 	Positional struct {
 		Snaps []anySnapName `positional-arg-name:"<snap>" required:"1"`
 	} `positional-args:"yes" required:"yes"`
@@ -74,6 +75,8 @@ func init() {
 		}, colorDescs.also(timeDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"verbose": i18n.G("Include more details on the snap (expanded notes, base, etc.)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"health": i18n.G("Include the health check interval and the history of health transitions of the snap"),
 		}), nil)
 }
 
@@ -83,7 +86,7 @@ func (iw *infoWriter) maybePrintHealth() {
 	}
 	health := iw.localSnap.Health
 	if health == nil {
-		if !iw.verbose {
+		if !iw.verbose && iw.healthHistory == nil {
 			return
 		}
 		health = &client.SnapHealth{
@@ -91,7 +94,7 @@ func (iw *infoWriter) maybePrintHealth() {
 			Message: "health has not been set",
 		}
 	}
-	if health.Status == "okay" && !iw.verbose {
+	if health.Status == "okay" && !iw.verbose && iw.healthHistory == nil {
 		return
 	}
 
@@ -109,6 +112,21 @@ func (iw *infoWriter) maybePrintHealth() {
 	if !health.Revision.Unset() {
 		fmt.Fprintf(iw, "  revision:\t%s\n", health.Revision)
 	}
+	if hh := iw.healthHistory; hh != nil {
+		if hh.CheckInterval != "" {
+			fmt.Fprintf(iw, "  check-interval:\t%s\n", hh.CheckInterval)
+		}
+		if len(hh.History) > 0 {
+			fmt.Fprintln(iw, "  history:")
+		}
+		for _, h := range hh.History {
+			fmt.Fprintf(iw, "    - %s %s (revision %s)", iw.fmtTime(h.Timestamp), h.Status, h.Revision)
+			if h.Message != "" {
+				fmt.Fprintf(iw, ": %s", h.Message)
+			}
+			fmt.Fprintln(iw)
+		}
+	}
 	iw.Flush()
 }
 
@@ -279,6 +297,8 @@ type infoWriter struct {
 	remoteSnap *client.Snap
 	resInfo    *client.ResultInfo
 	path       string
+	// healthHistory is only set when asked for with --health
+	healthHistory *client.SnapHealthHistory
 	// fields that don't change and so can be set once
 	writeflusher
 	esc       *escapes
@@ -290,6 +310,7 @@ type infoWriter struct {
 
 func (iw *infoWriter) setupDiskSnap(path string, diskSnap *client.Snap) {
 	iw.localSnap, iw.remoteSnap, iw.resInfo = nil, nil, nil
+	iw.healthHistory = nil
 	iw.path = path
 	iw.diskSnap = diskSnap
 	iw.theSnap = diskSnap
@@ -297,6 +318,7 @@ func (iw *infoWriter) setupDiskSnap(path string, diskSnap *client.Snap) {
 
 func (iw *infoWriter) setupSnap(localSnap, remoteSnap *client.Snap, resInfo *client.ResultInfo) {
 	iw.path, iw.diskSnap = "", nil
+	iw.healthHistory = nil
 	iw.localSnap = localSnap
 	iw.remoteSnap = remoteSnap
 	iw.resInfo = resInfo
@@ -711,6 +733,16 @@ func (x *infoCmd) Execute([]string) error {
 			remoteSnap, resInfo, _ := x.client.FindOne(snap.InstanceSnap(snapName))
 			localSnap, _, _ := x.client.Snap(snapName)
 			iw.setupSnap(localSnap, remoteSnap, resInfo)
+			if x.Health && localSnap != nil {
+				healths, err := x.client.Health([]string{snapName})
+				if err != nil {
+					w.Flush()
+					return err
+				}
+				if len(healths) == 1 {
+					iw.healthHistory = healths[0]
+				}
+			}
 		}
 		// note diskSnap == nil, or localSnap == nil and remoteSnap == nil
 
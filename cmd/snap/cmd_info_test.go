@@ -712,6 +712,60 @@ installed:    2.10 (1) 1kB disabled,blocked
 	c.Check(s.Stderr(), check.Equals, "")
 }
 
+func (s *infoSuite) TestInfoWithHealth(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			fmt.Fprintln(w, mockInfoJSON)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/hello")
+			fmt.Fprintln(w, mockInfoJSONOtherLicense)
+		case 2:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/health")
+			c.Check(r.URL.Query().Get("snaps"), check.Equals, "hello")
+			fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": [{
+				"snap": "hello",
+				"check-interval": "1h0m0s",
+				"history": [{"revision": "1", "status": "blocked", "message": "please configure the grawflit", "timestamp": "2019-05-13T16:20:00+01:00"}]
+			}]}`)
+		default:
+			c.Fatalf("expected to get 3 requests, now on %d (%v)", n+1, r)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"info", "--abs-time", "--health", "hello"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `
+name:    hello
+summary: The GNU Hello snap
+health:
+  status:         blocked
+  message:        please configure the grawflit
+  checked:        2019-05-13T16:27:01+01:00
+  revision:       1
+  check-interval: 1h0m0s
+  history:
+    - 2019-05-13T16:20:00+01:00 blocked (revision 1): please configure the grawflit
+publisher: Canonical*
+license:   BSD-3
+description: |
+  GNU hello prints a friendly greeting. This is part of the snapcraft tour at
+  https://snapcraft.io/
+snap-id:      mVyGrEwiqSi5PugCwyH7WgpoQLemtTd6
+tracking:     beta
+refresh-date: 2006-01-02T22:04:07Z
+installed:    2.10 (1) 1kB disabled,blocked
+`[1:])
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
 func (s *infoSuite) TestInfoNotFound(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -1040,6 +1094,40 @@ func (infoSuite) TestMaybePrintHealth(c *check.C) {
 	}
 }
 
+func (infoSuite) TestMaybePrintHealthHistory(c *check.C) {
+	t0 := time.Date(1970, 1, 1, 10, 24, 0, 0, time.UTC)
+	hh := &client.SnapHealthHistory{
+		Snap:          "foo",
+		CheckInterval: "10m0s",
+		History: []*client.SnapHealth{
+			{Status: "okay", Revision: snaplib.R("42"), Timestamp: t0},
+			{Status: "error", Message: "broken", Revision: snaplib.R("42"), Timestamp: t0.Add(time.Hour)},
+		},
+	}
+
+	var buf flushBuffer
+	iw := snap.NewInfoWriter(&buf)
+	defer snap.MockIsStdoutTTY(false)()
+
+	// the health is shown when asked for, even if okay
+	snap.SetupSnap(iw, &client.Snap{Health: &client.SnapHealth{Status: "okay"}}, nil, nil)
+	snap.SetHealthHistory(iw, hh)
+	snap.MaybePrintHealth(iw)
+	c.Check(buf.String(), check.Equals, `health:
+  status:	okay
+  check-interval:	10m0s
+  history:
+    - 10:24AM okay (revision 42)
+    - 11:24AM error (revision 42): broken
+`)
+
+	// and is not once the snap changes
+	buf.Reset()
+	snap.SetupSnap(iw, &client.Snap{Health: &client.SnapHealth{Status: "okay"}}, nil, nil)
+	snap.MaybePrintHealth(iw)
+	c.Check(buf.String(), check.Equals, "")
+}
+
 func (infoSuite) TestWrapCornerCase(c *check.C) {
 	// this particular corner case isn't currently reachable from
 	// printDescr nor printSummary, but best to have it covered
@@ -22,7 +22,9 @@ package main
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 
@@ -57,9 +59,15 @@ var longSetQuotaHelp = i18n.G(`
 The set-quota command updates or creates a quota group with the specified set of
 snaps.
 
-A quota group sets resource limits (currently maximum memory only) on the set of
-snaps that belong to it. Snaps can be at most in one quota group. Quota groups
-can be nested.
+A quota group sets resource limits on the set of snaps that belong to it. The
+supported limits are the maximum memory (--memory), the CPU time as a
+percentage of a number of CPUs, for example 2x50% (--cpu), the set of CPUs the
+snaps are allowed to run on (--cpu-set), the maximum number of threads
+(--threads) and a journal namespace with its maximum size and rate limit
+(--journal-size, --journal-rate-limit). Snaps can be at most in one quota group.
+Quota groups can be nested, in which case the limits of the sub-groups must fit
+within the limits of the parent group. Journal namespaces can only be set on
+groups without a parent.
 
 All snaps provided are appended to the group; to remove a snap from a
 quota group the entire group must be removed with remove-quota and recreated 
@@ -97,19 +105,149 @@ func init() {
 type cmdSetQuota struct {
 	clientMixin
 
-	MemoryMax  string `long:"memory" optional:"true"`
-	Parent     string `long:"parent" optional:"true"`
-	Positional struct {
+	MemoryMax        string `long:"memory" optional:"true"`
+	CPUMax           string `long:"cpu" optional:"true"`
+	CPUSet           string `long:"cpu-set" optional:"true"`
+	ThreadsMax       string `long:"threads" optional:"true"`
+	JournalSizeMax   string `long:"journal-size" optional:"true"`
+	JournalRateLimit string `long:"journal-rate-limit" optional:"true"`
+	Parent           string `long:"parent" optional:"true"`
+	Positional       struct {
 		GroupName string              `positional-arg-name:"<group-name>" required:"true"`
 		Snaps     []installedSnapName `positional-arg-name:"<snap>" optional:"true"`
 	} `positional-args:"yes"`
 }
 
+// parseCPUQuota parses a CPU quota of the form <count>x<percentage>% or
+// <percentage>%, i.e. "2x50%" or "50%".
+func parseCPUQuota(cpuMax string) (count int, percentage int, err error) {
+	countStr := ""
+	percentageStr := cpuMax
+	if idx := strings.IndexByte(cpuMax, 'x'); idx >= 0 {
+		countStr = cpuMax[:idx]
+		percentageStr = cpuMax[idx+1:]
+	}
+	if !strings.HasSuffix(percentageStr, "%") {
+		return 0, 0, fmt.Errorf("cannot parse cpu quota %q: expected <count>x<percentage>%% or <percentage>%%", cpuMax)
+	}
+	percentage, err = strconv.Atoi(strings.TrimSuffix(percentageStr, "%"))
+	if err != nil || percentage <= 0 {
+		return 0, 0, fmt.Errorf("cannot parse cpu quota %q: invalid percentage", cpuMax)
+	}
+	if countStr != "" {
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count <= 0 {
+			return 0, 0, fmt.Errorf("cannot parse cpu quota %q: invalid cpu count", cpuMax)
+		}
+	}
+	return count, percentage, nil
+}
+
+// parseCPUSet parses a comma separated list of CPU numbers, i.e. "0,1".
+func parseCPUSet(cpuSet string) ([]int, error) {
+	var cpus []int
+	for _, cpuStr := range strings.Split(cpuSet, ",") {
+		cpu, err := strconv.Atoi(strings.TrimSpace(cpuStr))
+		if err != nil || cpu < 0 {
+			return nil, fmt.Errorf("cannot parse cpu set %q: invalid cpu %q", cpuSet, cpuStr)
+		}
+		cpus = append(cpus, cpu)
+	}
+	return cpus, nil
+}
+
+// parseJournalRateLimit parses a journal rate limit of the form
+// <count>/<period>, i.e. "100/10s".
+func parseJournalRateLimit(rateLimit string) (count int, period time.Duration, err error) {
+	parts := strings.Split(rateLimit, "/")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("cannot parse journal rate limit %q: expected <count>/<period>", rateLimit)
+	}
+	count, err = strconv.Atoi(parts[0])
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("cannot parse journal rate limit %q: invalid count", rateLimit)
+	}
+	period, err = time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return 0, 0, fmt.Errorf("cannot parse journal rate limit %q: invalid period", rateLimit)
+	}
+	return count, period, nil
+}
+
+// parseQuotaValues returns the quota limits set with the options or nil if
+// no limits were set.
+func (x *cmdSetQuota) parseQuotaValues() (*client.QuotaValues, error) {
+	values := &client.QuotaValues{}
+	hasLimits := false
+
+	if x.MemoryMax != "" {
+		mem, err := strutil.ParseByteSize(x.MemoryMax)
+		if err != nil {
+			return nil, err
+		}
+		values.MaxMemory = uint64(mem)
+		hasLimits = true
+	}
+
+	if x.CPUMax != "" || x.CPUSet != "" {
+		values.CPU = &client.QuotaCPUValues{}
+		hasLimits = true
+	}
+	if x.CPUMax != "" {
+		count, percentage, err := parseCPUQuota(x.CPUMax)
+		if err != nil {
+			return nil, err
+		}
+		values.CPU.Count = count
+		values.CPU.Percentage = percentage
+	}
+	if x.CPUSet != "" {
+		cpus, err := parseCPUSet(x.CPUSet)
+		if err != nil {
+			return nil, err
+		}
+		values.CPU.CPUs = cpus
+	}
+
+	if x.ThreadsMax != "" {
+		threads, err := strconv.Atoi(x.ThreadsMax)
+		if err != nil || threads <= 0 {
+			return nil, fmt.Errorf("cannot parse threads limit %q: must be a positive number", x.ThreadsMax)
+		}
+		values.MaxThreads = threads
+		hasLimits = true
+	}
+
+	if x.JournalSizeMax != "" || x.JournalRateLimit != "" {
+		values.Journal = &client.QuotaJournalValues{}
+		hasLimits = true
+	}
+	if x.JournalSizeMax != "" {
+		size, err := strutil.ParseByteSize(x.JournalSizeMax)
+		if err != nil {
+			return nil, err
+		}
+		values.Journal.Size = uint64(size)
+	}
+	if x.JournalRateLimit != "" {
+		count, period, err := parseJournalRateLimit(x.JournalRateLimit)
+		if err != nil {
+			return nil, err
+		}
+		values.Journal.RateLimitCount = count
+		values.Journal.RateLimitPeriod = period
+	}
+
+	if !hasLimits {
+		return nil, nil
+	}
+	return values, nil
+}
+
 func (x *cmdSetQuota) Execute(args []string) (err error) {
-	var maxMemory string
-	switch {
-	case x.MemoryMax != "":
-		maxMemory = x.MemoryMax
+	limits, err := x.parseQuotaValues()
+	if err != nil {
+		return err
 	}
 
 	names := installedSnapNames(x.Positional.Snaps)
@@ -121,19 +259,19 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 	}
 
 	switch {
-	case maxMemory == "" && x.Parent == "" && len(x.Positional.Snaps) == 0:
-		// no snaps were specified, no memory limit was specified, and no parent
+	case limits == nil && x.Parent == "" && len(x.Positional.Snaps) == 0:
+		// no snaps were specified, no limits were specified, and no parent
 		// was specified, so just the group name was provided - this is not
 		// supported since there is nothing to change/create
 
 		if groupExists {
 			return fmt.Errorf("no options set to change quota group")
 		}
-		return fmt.Errorf("cannot create quota group without memory limit")
+		return fmt.Errorf("cannot create quota group without any limits")
 
-	case maxMemory == "" && x.Parent != "" && len(x.Positional.Snaps) == 0:
+	case limits == nil && x.Parent != "" && len(x.Positional.Snaps) == 0:
 		// this is either trying to create a new group with a parent and forgot
-		// to specify the memory limit for the new group, or the user is trying
+		// to specify the limits for the new group, or the user is trying
 		// to re-parent a group, i.e. move it from the current parent to a
 		// different one, which is currently unsupported
 
@@ -143,27 +281,22 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 			// it's a noop?
 			return fmt.Errorf("cannot move a quota group to a new parent")
 		}
-		return fmt.Errorf("cannot create quota group without memory limit")
+		return fmt.Errorf("cannot create quota group without any limits")
 
-	case maxMemory != "":
-		// we have a memory limit to set for this group, so specify that along
+	case limits != nil:
+		// we have limits to set for this group, so specify that along
 		// with whatever snaps may have been provided and whatever parent may
 		// have been specified
 
-		mem, err := strutil.ParseByteSize(maxMemory)
-		if err != nil {
-			return err
-		}
-
 		// note that the group could currently exist with a parent, and we could
 		// be specifying x.Parent as "" here - in the future that may mean to
 		// orphan a sub-group to no longer have a parent, but currently it just
 		// means leave the group with whatever parent it has, or if it doesn't
 		// currently exist, create the group without a parent group
-		return x.client.EnsureQuota(x.Positional.GroupName, x.Parent, names, uint64(mem))
+		return x.client.EnsureQuota(x.Positional.GroupName, x.Parent, names, limits)
 
 	case len(x.Positional.Snaps) != 0:
-		// there are snaps specified for this group but no memory limit, so the
+		// there are snaps specified for this group but no limits, so the
 		// group must already exist and we must be adding the specified snaps to
 		// the group
 
@@ -172,7 +305,7 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 		// currently support that, so currently all snaps specified here are
 		// just added to the group
 
-		return x.client.EnsureQuota(x.Positional.GroupName, x.Parent, names, 0)
+		return x.client.EnsureQuota(x.Positional.GroupName, x.Parent, names, nil)
 
 	default:
 		// should be logically impossible to reach here
@@ -206,9 +339,35 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 		fmt.Fprintf(w, "parent:\t%s\n", group.Parent)
 	}
 	fmt.Fprintf(w, "constraints:\n")
-	fmt.Fprintf(w, "  memory:\t%s\n", strings.TrimSpace(fmtSize(int64(group.MaxMemory))))
+	if group.MaxMemory != 0 {
+		fmt.Fprintf(w, "  memory:\t%s\n", strings.TrimSpace(fmtSize(int64(group.MaxMemory))))
+	}
+	if group.CPU != nil {
+		if group.CPU.Percentage != 0 {
+			fmt.Fprintf(w, "  cpu:\t%s\n", fmtCPUQuota(group.CPU))
+		}
+		if len(group.CPU.CPUs) != 0 {
+			fmt.Fprintf(w, "  cpu-set:\t%s\n", fmtCPUSet(group.CPU.CPUs))
+		}
+	}
+	if group.MaxThreads != 0 {
+		fmt.Fprintf(w, "  threads:\t%d\n", group.MaxThreads)
+	}
+	if group.Journal != nil {
+		if group.Journal.Size != 0 {
+			fmt.Fprintf(w, "  journal-size:\t%s\n", strings.TrimSpace(fmtSize(int64(group.Journal.Size))))
+		}
+		if group.Journal.RateLimitCount != 0 {
+			fmt.Fprintf(w, "  journal-rate:\t%d/%s\n", group.Journal.RateLimitCount, group.Journal.RateLimitPeriod)
+		}
+	}
 	fmt.Fprintf(w, "current:\n")
-	fmt.Fprintf(w, "  memory:\t%s\n", strings.TrimSpace(fmtSize(int64(group.CurrentMemory))))
+	if group.MaxMemory != 0 {
+		fmt.Fprintf(w, "  memory:\t%s\n", strings.TrimSpace(fmtSize(int64(group.CurrentMemory))))
+	}
+	if group.MaxThreads != 0 {
+		fmt.Fprintf(w, "  threads:\t%d\n", group.CurrentThreads)
+	}
 	if len(group.Subgroups) > 0 {
 		fmt.Fprint(w, "subgroups:\n")
 		for _, name := range group.Subgroups {
@@ -253,17 +412,34 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 	w := tabWriter()
 	fmt.Fprintf(w, "Quota\tParent\tConstraints\tCurrent\n")
 	err = processQuotaGroupsTree(res, func(q *client.QuotaGroupResult) {
-		constraintMem := ""
+		var constraints []string
 		if q.MaxMemory != 0 {
-			constraintMem = "memory=" + strings.TrimSpace(fmtSize(int64(q.MaxMemory)))
+			constraints = append(constraints, "memory="+strings.TrimSpace(fmtSize(int64(q.MaxMemory))))
+		}
+		if q.CPU != nil {
+			if q.CPU.Percentage != 0 {
+				constraints = append(constraints, "cpu="+fmtCPUQuota(q.CPU))
+			}
+			if len(q.CPU.CPUs) != 0 {
+				constraints = append(constraints, "cpu-set="+fmtCPUSet(q.CPU.CPUs))
+			}
+		}
+		if q.MaxThreads != 0 {
+			constraints = append(constraints, fmt.Sprintf("threads=%d", q.MaxThreads))
+		}
+		if q.Journal != nil {
+			constraints = append(constraints, "journal")
 		}
 
-		currentMem := ""
+		var current []string
 		if q.CurrentMemory != 0 {
-			currentMem = "memory=" + strings.TrimSpace(fmtSize(int64(q.CurrentMemory)))
+			current = append(current, "memory="+strings.TrimSpace(fmtSize(int64(q.CurrentMemory))))
+		}
+		if q.CurrentThreads != 0 {
+			current = append(current, fmt.Sprintf("threads=%d", q.CurrentThreads))
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", q.GroupName, q.Parent, constraintMem, currentMem)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", q.GroupName, q.Parent, strings.Join(constraints, " "), strings.Join(current, " "))
 	})
 	if err != nil {
 		return err
@@ -272,6 +448,21 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 	return nil
 }
 
+func fmtCPUQuota(cpu *client.QuotaCPUValues) string {
+	if cpu.Count == 0 {
+		return fmt.Sprintf("%d%%", cpu.Percentage)
+	}
+	return fmt.Sprintf("%dx%d%%", cpu.Count, cpu.Percentage)
+}
+
+func fmtCPUSet(cpus []int) string {
+	cpuStrs := make([]string, 0, len(cpus))
+	for _, cpu := range cpus {
+		cpuStrs = append(cpuStrs, strconv.Itoa(cpu))
+	}
+	return strings.Join(cpuStrs, ",")
+}
+
 type quotaGroup struct {
 	res       *client.QuotaGroupResult
 	subGroups []*quotaGroup
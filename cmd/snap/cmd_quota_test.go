@@ -24,9 +24,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/client"
 	main "github.com/snapcore/snapd/cmd/snap"
 )
 
@@ -98,15 +100,21 @@ type fakeQuotaGroupPostHandlerOpts struct {
 	parentName    string
 	snaps         []string
 	maxMemory     int64
+	cpu           *client.QuotaCPUValues
+	maxThreads    int
+	journal       *client.QuotaJournalValues
 	currentMemory int64
 }
 
 type quotasEnsureBody struct {
-	Action     string   `json:"action"`
-	GroupName  string   `json:"group-name,omitempty"`
-	ParentName string   `json:"parent,omitempty"`
-	Snaps      []string `json:"snaps,omitempty"`
-	MaxMemory  int64    `json:"max-memory,omitempty"`
+	Action     string                     `json:"action"`
+	GroupName  string                     `json:"group-name,omitempty"`
+	ParentName string                     `json:"parent,omitempty"`
+	Snaps      []string                   `json:"snaps,omitempty"`
+	MaxMemory  int64                      `json:"max-memory,omitempty"`
+	CPU        *client.QuotaCPUValues     `json:"cpu,omitempty"`
+	MaxThreads int                        `json:"max-threads,omitempty"`
+	Journal    *client.QuotaJournalValues `json:"journal,omitempty"`
 }
 
 func makeFakeQuotaPostHandler(c *check.C, opts fakeQuotaGroupPostHandlerOpts) func(w http.ResponseWriter, r *http.Request) {
@@ -132,6 +140,9 @@ func makeFakeQuotaPostHandler(c *check.C, opts fakeQuotaGroupPostHandlerOpts) fu
 				ParentName: opts.parentName,
 				Snaps:      opts.snaps,
 				MaxMemory:  opts.maxMemory,
+				CPU:        opts.cpu,
+				MaxThreads: opts.maxThreads,
+				Journal:    opts.journal,
 			}
 
 			postJSON := quotasEnsureBody{}
@@ -155,6 +166,14 @@ func (s *quotaSuite) TestSetQuotaInvalidArgs(c *check.C) {
 		{[]string{"set-quota", "--memory=99B"}, "the required argument `<group-name>` was not provided"},
 		{[]string{"set-quota", "--memory=99", "foo"}, `cannot parse "99": need a number with a unit as input`},
 		{[]string{"set-quota", "--memory=888X", "foo"}, `cannot parse "888X\": try 'kB' or 'MB'`},
+		{[]string{"set-quota", "--cpu=50", "foo"}, `cannot parse cpu quota "50": expected <count>x<percentage>% or <percentage>%`},
+		{[]string{"set-quota", "--cpu=0x50%", "foo"}, `cannot parse cpu quota "0x50%": invalid cpu count`},
+		{[]string{"set-quota", "--cpu=2xfoo%", "foo"}, `cannot parse cpu quota "2xfoo%": invalid percentage`},
+		{[]string{"set-quota", "--cpu-set=0,x", "foo"}, `cannot parse cpu set "0,x": invalid cpu "x"`},
+		{[]string{"set-quota", "--threads=-1", "foo"}, `cannot parse threads limit "-1": must be a positive number`},
+		{[]string{"set-quota", "--journal-size=10", "foo"}, `cannot parse "10": need a number with a unit as input`},
+		{[]string{"set-quota", "--journal-rate-limit=10", "foo"}, `cannot parse journal rate limit "10": expected <count>/<period>`},
+		{[]string{"set-quota", "--journal-rate-limit=10/x", "foo"}, `cannot parse journal rate limit "10/x": invalid period`},
 		// remove-quota command
 		{[]string{"remove-quota"}, "the required argument `<group-name>` was not provided"},
 	} {
@@ -246,6 +265,112 @@ current:
 	c.Check(s.Stdout(), check.Equals, fmt.Sprintf(outputTemplate, 500))
 }
 
+func (s *quotaSuite) TestGetQuotaGroupAllLimits(c *check.C) {
+	restore := main.MockIsStdinTTY(true)
+	defer restore()
+
+	const json = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"max-memory":1000,
+			"cpu":{"count":2,"percentage":50,"cpus":[0,1]},
+			"max-threads":32,
+			"journal":{"size":64000000,"rate-limit-count":100,"rate-limit-period":10000000000},
+			"current-memory":900,
+			"current-threads":16
+		}
+	}`
+
+	s.RedirectClientToTestServer(makeFakeGetQuotaGroupHandler(c, json))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+name:  foo
+constraints:
+  memory:        1000B
+  cpu:           2x50%
+  cpu-set:       0,1
+  threads:       32
+  journal-size:  64.0MB
+  journal-rate:  100/10s
+current:
+  memory:   900B
+  threads:  16
+`[1:])
+}
+
+func (s *quotaSuite) TestSetQuotaGroupCreateNewAllLimits(c *check.C) {
+	const postJSON = `{"type": "sync", "status-code": 200, "result": []}`
+	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
+		action:    "ensure",
+		body:      postJSON,
+		groupName: "foo",
+		snaps:     []string{"snap-a"},
+		maxMemory: 999,
+		cpu: &client.QuotaCPUValues{
+			Count:      2,
+			Percentage: 50,
+			CPUs:       []int{0, 1},
+		},
+		maxThreads: 32,
+		journal: &client.QuotaJournalValues{
+			Size:            64000000,
+			RateLimitCount:  100,
+			RateLimitPeriod: 10 * time.Second,
+		},
+	}
+
+	routes := map[string]http.HandlerFunc{
+		"/v2/quotas": makeFakeQuotaPostHandler(
+			c,
+			fakeHandlerOpts,
+		),
+		// the foo quota group is not found since it doesn't exist yet
+		"/v2/quotas/foo": makeFakeGetQuotaGroupNotFoundHandler(c, "foo"),
+	}
+
+	s.RedirectClientToTestServer(dispatchFakeHandlers(c, routes))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"set-quota", "foo", "--memory=999B", "--cpu=2x50%", "--cpu-set=0,1", "--threads=32", "--journal-size=64MB", "--journal-rate-limit=100/10s", "snap-a"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
+func (s *quotaSuite) TestSetQuotaGroupCreateNewCPUOnly(c *check.C) {
+	const postJSON = `{"type": "sync", "status-code": 200, "result": []}`
+	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
+		action:    "ensure",
+		body:      postJSON,
+		groupName: "foo",
+		cpu: &client.QuotaCPUValues{
+			Percentage: 20,
+		},
+	}
+
+	routes := map[string]http.HandlerFunc{
+		"/v2/quotas": makeFakeQuotaPostHandler(
+			c,
+			fakeHandlerOpts,
+		),
+		"/v2/quotas/foo": makeFakeGetQuotaGroupNotFoundHandler(c, "foo"),
+	}
+
+	s.RedirectClientToTestServer(dispatchFakeHandlers(c, routes))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"set-quota", "foo", "--cpu=20%"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
 func (s *quotaSuite) TestSetQuotaGroupCreateNew(c *check.C) {
 	const postJSON = `{"type": "sync", "status-code": 200, "result": []}`
 	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
@@ -282,12 +407,12 @@ func (s *quotaSuite) TestSetQuotaGroupUpdateExistingUnhappy(c *check.C) {
 
 func (s *quotaSuite) TestSetQuotaGroupCreateNewUnhappy(c *check.C) {
 	const exists = false
-	s.testSetQuotaGroupUpdateExistingUnhappy(c, "cannot create quota group without memory limit", exists)
+	s.testSetQuotaGroupUpdateExistingUnhappy(c, "cannot create quota group without any limits", exists)
 }
 
 func (s *quotaSuite) TestSetQuotaGroupCreateNewUnhappyWithParent(c *check.C) {
 	const exists = false
-	s.testSetQuotaGroupUpdateExistingUnhappy(c, "cannot create quota group without memory limit", exists, "--parent=bar")
+	s.testSetQuotaGroupUpdateExistingUnhappy(c, "cannot create quota group without any limits", exists, "--parent=bar")
 }
 
 func (s *quotaSuite) TestSetQuotaGroupUpdateExistingUnhappyWithParent(c *check.C) {
@@ -431,6 +556,27 @@ bbb      zzz     memory=1000B  memory=400B
 `[1:])
 }
 
+func (s *quotaSuite) TestGetAllQuotaGroupsAllLimits(c *check.C) {
+	restore := main.MockIsStdinTTY(true)
+	defer restore()
+
+	s.RedirectClientToTestServer(makeFakeGetQuotaGroupsHandler(c,
+		`{"type": "sync", "status-code": 200, "result": [
+			{"group-name":"aaa","subgroups":["bbb"],"max-memory":1000,"cpu":{"count":2,"percentage":50,"cpus":[0,1]},"max-threads":32,"journal":{"size":1000},"current-memory":400,"current-threads":3},
+			{"group-name":"bbb","parent":"aaa","cpu":{"percentage":50}}
+			]}`))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quotas"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+Quota  Parent  Constraints                                            Current
+aaa            memory=1000B cpu=2x50% cpu-set=0,1 threads=32 journal  memory=400B threads=3
+bbb    aaa     cpu=50%                                                
+`[1:])
+}
+
 func (s *quotaSuite) TestGetAllQuotaGroupsInconsistencyError(c *check.C) {
 	restore := main.MockIsStdinTTY(true)
 	defer restore()
@@ -44,10 +44,16 @@ Nested values may be modified via a dotted path:
 
 Configuration option may be unset with exclamation mark:
     $ snap set snap-name author!
+
+With --secret, the values are stored encrypted and are only ever shown to
+the snap itself:
+
+    $ snap set --secret snap-name api-token=$TOKEN
 `)
 
 type cmdSet struct {
 	waitMixin
+	Secret     bool `long:"secret"`
 	Positional struct {
 		Snap       installedSnapName
 		ConfValues []string `required:"1"`
@@ -55,7 +61,10 @@ type cmdSet struct {
 }
 
 func init() {
-	addCommand("set", shortSetHelp, longSetHelp, func() flags.Commander { return &cmdSet{} }, waitDescs, []argDesc{
+	addCommand("set", shortSetHelp, longSetHelp, func() flags.Commander { return &cmdSet{} }, waitDescs.also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"secret": i18n.G("Store the values as secrets, only shown to the snap itself"),
+	}), []argDesc{
 		{
 			name: "<snap>",
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -80,6 +89,11 @@ func (x *cmdSet) Execute(args []string) error {
 		if len(parts) != 2 {
 			return fmt.Errorf(i18n.G("invalid configuration: %q (want key=value)"), patchValue)
 		}
+		if x.Secret {
+			// secrets are always strings
+			patchValues[parts[0]] = parts[1]
+			continue
+		}
 		var value interface{}
 		if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
 			// Not valid JSON-- just save the string as-is.
@@ -90,7 +104,11 @@ func (x *cmdSet) Execute(args []string) error {
 	}
 
 	snapName := string(x.Positional.Snap)
-	id, err := x.client.SetConf(snapName, patchValues)
+	setConf := x.client.SetConf
+	if x.Secret {
+		setConf = x.client.SetSecretConf
+	}
+	id, err := setConf(snapName, patchValues)
 	if err != nil {
 		return err
 	}
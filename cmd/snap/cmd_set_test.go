@@ -59,6 +59,31 @@ func (s *snapSetSuite) TestSnapSetIntegrationString(c *check.C) {
 	c.Check(s.setConfApiCalls, check.Equals, 1)
 }
 
+func (s *snapSetSuite) TestSnapSetSecret(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps/snapname/conf":
+			c.Check(r.Method, check.Equals, "PUT")
+			c.Check(r.URL.Query().Get("secret"), check.Equals, "true")
+			// values are not decoded as JSON
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"key": "123",
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+			s.setConfApiCalls += 1
+		case "/v2/changes/zzz":
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := snapset.Parser(snapset.Client()).ParseArgs([]string{"set", "--secret", "snapname", "key=123"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.setConfApiCalls, check.Equals, 1)
+}
+
 func (s *snapSetSuite) TestSnapSetIntegrationNumber(c *check.C) {
 	// and mock the server
 	s.mockSetConfigServer(c, json.Number("1.2"))
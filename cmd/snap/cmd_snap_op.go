@@ -92,6 +92,11 @@ have developer access to the snap, either directly or through the
 store's collaboration feature, and to be logged in (see 'snap help login').
 
 Note a later refresh will typically undo a revision override.
+
+With --from-dir the snaps are refreshed from the snap files found in the given
+directory instead of the store, for example from removable media on systems
+without network access. The directory must also hold the assertions for the
+snaps in .assert files, as generated by 'snap download'.
 `)
 
 var longTryHelp = i18n.G(`
@@ -640,6 +645,7 @@ type cmdRefresh struct {
 	Time             bool   `long:"time"`
 	IgnoreValidation bool   `long:"ignore-validation"`
 	IgnoreRunning    bool   `long:"ignore-running" hidden:"yes"`
+	FromDir          string `long:"from-dir"`
 	Positional       struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -650,7 +656,23 @@ func (x *cmdRefresh) refreshMany(snaps []string, opts *client.SnapOptions) error
 	if err != nil {
 		return err
 	}
+	return x.waitRefreshMany(changeID, opts)
+}
 
+func (x *cmdRefresh) refreshManyFromDir(snaps []string, dir string) error {
+	// snapd needs an absolute path
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	changeID, err := x.client.RefreshManyFromDir(snaps, dir)
+	if err != nil {
+		return err
+	}
+	return x.waitRefreshMany(changeID, nil)
+}
+
+func (x *cmdRefresh) waitRefreshMany(changeID string, opts *client.SnapOptions) error {
 	chg, err := x.wait(changeID)
 	if err != nil {
 		if err == noWait {
@@ -741,9 +763,65 @@ func (x *cmdRefresh) showRefreshTimes() error {
 	} else {
 		fmt.Fprintf(Stdout, "next: n/a\n")
 	}
+	if len(sysinfo.Refresh.Windows) > 0 {
+		// snaps in a refresh window are only auto-refreshed while
+		// it is open, all others whenever the timer fires
+		fmt.Fprintf(Stdout, "windows:\n")
+		for _, w := range sysinfo.Refresh.Windows {
+			fmt.Fprintf(Stdout, "  %s:\n", w.Name)
+			fmt.Fprintf(Stdout, "    timer: %s\n", w.Timer)
+			fmt.Fprintf(Stdout, "    snaps: %s\n", strings.Join(w.Snaps, ", "))
+			if w.Open {
+				fmt.Fprintf(Stdout, "    eligible: now\n")
+			} else if next := parseSysinfoTime(w.Next); !next.IsZero() {
+				fmt.Fprintf(Stdout, "    eligible: %s\n", x.fmtTime(next))
+			}
+		}
+	}
+	if coord := sysinfo.Refresh.Coordinator; coord != nil {
+		x.showRefreshCoordinator(coord)
+	}
 	return nil
 }
 
+func (x *cmdRefresh) showRefreshCoordinator(coord *client.RefreshCoordinatorInfo) {
+	fmt.Fprintf(Stdout, "coordinator:\n")
+	fmt.Fprintf(Stdout, "  url: %s\n", coord.URL)
+	if last := parseSysinfoTime(coord.Last); !last.IsZero() {
+		fmt.Fprintf(Stdout, "  last: %s\n", x.fmtTime(last))
+	} else {
+		fmt.Fprintf(Stdout, "  last: n/a\n")
+	}
+	if coord.Error != "" {
+		fmt.Fprintf(Stdout, "  error: %s\n", coord.Error)
+	}
+	if len(coord.Snaps) == 0 {
+		return
+	}
+	names := make([]string, 0, len(coord.Snaps))
+	for name := range coord.Snaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(Stdout, "  snaps:\n")
+	for _, name := range names {
+		d := coord.Snaps[name]
+		decision := d.Action
+		switch d.Action {
+		case "delay":
+			if until := parseSysinfoTime(d.Until); !until.IsZero() {
+				decision = fmt.Sprintf("delay until %s", x.fmtTime(until))
+			}
+		case "pin":
+			decision = fmt.Sprintf("pin to revision %s", d.Revision)
+		}
+		if d.Reason != "" {
+			decision = fmt.Sprintf("%s (%s)", decision, d.Reason)
+		}
+		fmt.Fprintf(Stdout, "    %s: %s\n", name, decision)
+	}
+}
+
 func (x *cmdRefresh) listRefresh() error {
 	snaps, _, err := x.client.Find(&client.FindOptions{
 		Refresh: true,
@@ -800,6 +878,12 @@ func (x *cmdRefresh) Execute([]string) error {
 	}
 
 	names := installedSnapNames(x.Positional.Snaps)
+	if x.FromDir != "" {
+		if x.asksForMode() || x.asksForChannel() || x.Revision != "" || x.Cohort != "" || x.LeaveCohort || x.Amend || x.IgnoreValidation || x.IgnoreRunning {
+			return errors.New(i18n.G("--from-dir does not take other refresh options"))
+		}
+		return x.refreshManyFromDir(names, x.FromDir)
+	}
 	if len(names) == 1 {
 		opts := &client.SnapOptions{
 			Amend:            x.Amend,
@@ -1127,6 +1211,8 @@ func init() {
 			"cohort": i18n.G("Refresh the snap into the given cohort"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"leave-cohort": i18n.G("Refresh the snap out of its cohort"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"from-dir": i18n.G("Refresh from the snaps and assertions in the given directory instead of the store"),
 		}), nil)
 	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs), nil)
 	addCommand("enable", shortEnableHelp, longEnableHelp, func() flags.Commander { return &cmdEnable{} }, waitDescs, nil)
@@ -1142,6 +1142,77 @@ next: 2017-04-26T00:58:00+02:00
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshTimerWindows(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/system-info")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"timer": "0:00-24:00/4", "last": "2017-04-25T17:35:00+02:00", "next": "2017-04-26T00:58:00+02:00", "windows": [
+{"name": "apps", "timer": "0:00-24:00", "snaps": ["foo"], "open": true, "next": "2017-04-25T18:00:00+02:00"},
+{"name": "db", "timer": "sun,02:00-04:00", "snaps": ["mysql", "postgres"], "next": "2017-04-30T02:00:00+02:00"}]}}}`)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--time", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `timer: 0:00-24:00/4
+last: 2017-04-25T17:35:00+02:00
+next: 2017-04-26T00:58:00+02:00
+windows:
+  apps:
+    timer: 0:00-24:00
+    snaps: foo
+    eligible: now
+  db:
+    timer: sun,02:00-04:00
+    snaps: mysql, postgres
+    eligible: 2017-04-30T02:00:00+02:00
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestRefreshTimerCoordinator(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/system-info")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"timer": "0:00-24:00/4", "last": "2017-04-25T17:35:00+02:00", "next": "2017-04-26T00:58:00+02:00", "coordinator": {
+"url": "http://coordinator.example.com/refresh", "last": "2017-04-25T17:35:00+02:00", "snaps": {
+"foo": {"action": "delay", "until": "2017-04-26T10:00:00+02:00", "reason": "canary failing"},
+"bar": {"action": "pin", "revision": "9", "reason": "stage 2"},
+"baz": {"action": "approve"}}}}}}`)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--time", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `timer: 0:00-24:00/4
+last: 2017-04-25T17:35:00+02:00
+next: 2017-04-26T00:58:00+02:00
+coordinator:
+  url: http://coordinator.example.com/refresh
+  last: 2017-04-25T17:35:00+02:00
+  snaps:
+    bar: pin to revision 9 (stage 2)
+    baz: approve
+    foo: delay until 2017-04-26T10:00:00+02:00 (canary failing)
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestRefreshTimerCoordinatorError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"timer": "0:00-24:00/4", "last": "2017-04-25T17:35:00+02:00", "coordinator": {
+"url": "http://coordinator.example.com/refresh", "last": "2017-04-25T17:35:00+02:00", "error": "unexpected status 500"}}}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--time", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `timer: 0:00-24:00/4
+last: 2017-04-25T17:35:00+02:00
+next: n/a
+coordinator:
+  url: http://coordinator.example.com/refresh
+  last: 2017-04-25T17:35:00+02:00
+  error: unexpected status 500
+`)
+}
+
 func (s *SnapSuite) TestRefreshHold(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -1425,6 +1496,56 @@ func (s *SnapOpSuite) TestRefreshAllModeFlags(c *check.C) {
 	c.Assert(err, check.ErrorMatches, `a single snap name is needed to specify mode or channel flags`)
 }
 
+func (s *SnapOpSuite) TestRefreshFromDir(c *check.C) {
+	// relative paths are made absolute for snapd
+	expectedDir, err := filepath.Abs("snaps")
+	c.Assert(err, check.IsNil)
+
+	total := 3
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":   "refresh",
+				"snaps":    []interface{}{"one", "two"},
+				"from-dir": expectedDir,
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"status": "Doing"}}`)
+		case 2:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get %d requests, now on %d", total, n+1)
+		}
+
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--from-dir", "snaps", "one", "two"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stderr(), check.Equals, "All snaps up to date.\n")
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, total)
+}
+
+func (s *SnapOpSuite) TestRefreshFromDirOtherOptions(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	for _, opt := range []string{"--beta", "--devmode", "--revision=1", "--cohort=what", "--leave-cohort", "--amend", "--ignore-validation"} {
+		_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--from-dir=/media/usb", opt, "one"})
+		c.Check(err, check.ErrorMatches, `--from-dir does not take other refresh options`, check.Commentf("%s", opt))
+	}
+}
+
 func (s *SnapOpSuite) TestRefreshOneAmend(c *check.C) {
 	s.RedirectClientToTestServer(s.srv.handle)
 	s.srv.checker = func(r *http.Request) {
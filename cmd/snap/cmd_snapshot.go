@@ -20,14 +20,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/strutil/quantity"
@@ -45,6 +48,8 @@ var (
 	shortRestoreHelp        = i18n.G("Restore a snapshot")
 	shortExportSnapshotHelp = i18n.G("Export a snapshot")
 	shortImportSnapshotHelp = i18n.G("Import a snapshot")
+	shortPushSnapshotHelp   = i18n.G("Push a snapshot to the remote")
+	shortPullSnapshotHelp   = i18n.G("Pull a snapshot from the remote")
 )
 
 var longSavedHelp = i18n.G(`
@@ -62,6 +67,22 @@ for which users, or a combination of these.
 If a snap is included in a save operation, excluding its system and
 configuration data from the snapshot is not currently possible. This
 restriction may be lifted in the future.
+
+If the snapshots.incremental core option is set to true, the data is
+stored in a deduplicated form shared with other incremental snapshots,
+so that unchanged files only take up disk space once.
+
+Snapshots can also be taken periodically by setting the snapshots.schedule
+core option, using the same syntax as refresh.timer. Scheduled snapshots
+include the snaps listed in snapshots.scheduled-snaps, or all snaps, and
+old ones are forgotten according to the snapshots.keep-last,
+snapshots.keep-daily and snapshots.keep-weekly core options.
+
+With --encrypt, the snapshot is encrypted with a passphrase that is asked
+for. Snapshots are also encrypted, for the holders of the matching private
+keys, if the snapshots.encryption.public-key core option is set to an
+ASCII-armored OpenPGP public key. Encrypted snapshots cannot be
+incremental.
 `)
 var longForgetHelp = i18n.G(`
 The forget command deletes a snapshot. This operation can not be
@@ -78,7 +99,8 @@ The check-snapshot command verifies the user, system and configuration
 data of the snaps included in the specified snapshot.
 
 The check operation runs the same data integrity verification that is
-performed when a snapshot is restored.
+performed when a snapshot is restored. Encrypted snapshots are checked
+without being decrypted.
 
 By default, this command checks all the data in a snapshot.
 Alternatively, you can specify the data of which snaps to check, or
@@ -100,6 +122,11 @@ for which users, or a combination of these.
 If a snap is included in a restore operation, excluding its system and
 configuration data from the restore is not currently possible. This
 restriction may be lifted in the future.
+
+Restoring an encrypted snapshot needs either the passphrase it was
+encrypted with, asked for with --passphrase, or the private key matching
+the public key it was encrypted to, given with --private-key (along with
+--passphrase if the key itself is protected by one).
 `)
 
 var longExportSnapshotHelp = i18n.G(`
@@ -111,6 +138,22 @@ Import an exported snapshot set to the system. The snapshot is imported
 with a new snapshot ID and can be restored using the restore command.
 `)
 
+var longPushSnapshotHelp = i18n.G(`
+The push-snapshot command exports a snapshot, and stores it on the
+remote set with the snapshots.remote.* system options: an S3-compatible
+object store, or an SFTP server.
+
+By default, the snapshot is stored as <id>.snapshot. Transfers that fail
+because of network or server errors are retried.
+`)
+
+var longPullSnapshotHelp = i18n.G(`
+The pull-snapshot command fetches a snapshot stored with push-snapshot
+from the remote set with the snapshots.remote.* system options, and
+imports it. The snapshot is imported with a new snapshot ID and can be
+restored using the restore command.
+`)
+
 type savedCmd struct {
 	clientMixin
 	durationMixin
@@ -139,10 +182,21 @@ func (x *savedCmd) Execute([]string) error {
 		return nil
 	}
 
+	// the on-disk size only differs from the size for incremental
+	// snapshots, only show it if there are any
+	showDiskSize := false
+	for _, sg := range list {
+		for _, sh := range sg.Snapshots {
+			if sh.Incremental {
+				showDiskSize = true
+			}
+		}
+	}
+
 	w := tabWriter()
 	defer w.Flush()
 
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t",
 		// TRANSLATORS: 'Set' as in group or bag of things
 		i18n.G("Set"),
 		"Snap",
@@ -151,15 +205,28 @@ func (x *savedCmd) Execute([]string) error {
 		i18n.G("Version"),
 		// TRANSLATORS: 'Rev' is an abbreviation of 'Revision'
 		i18n.G("Rev"),
-		i18n.G("Size"),
-		// TRANSLATORS: 'Notes' as in 'Comments'
-		i18n.G("Notes"))
+		i18n.G("Size"))
+	if showDiskSize {
+		// TRANSLATORS: 'Disk' as in the disk space actually used
+		fmt.Fprintf(w, "%s\t", i18n.G("Disk"))
+	}
+	// TRANSLATORS: 'Notes' as in 'Comments'
+	fmt.Fprintln(w, i18n.G("Notes"))
 	for _, sg := range list {
 		for _, sh := range sg.Snapshots {
 			notes := []string{}
 			if sh.Auto {
 				notes = append(notes, "auto")
 			}
+			if sh.Scheduled {
+				notes = append(notes, "scheduled")
+			}
+			if sh.Incremental {
+				notes = append(notes, "incremental")
+			}
+			if sh.Encryption != "" {
+				notes = append(notes, "encrypted")
+			}
 			if sh.Broken != "" {
 				notes = append(notes, "broken: "+sh.Broken)
 			}
@@ -169,7 +236,15 @@ func (x *savedCmd) Execute([]string) error {
 			}
 			size := fmtSize(sh.Size)
 			age := x.fmtDuration(sh.Time)
-			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", sg.ID, sh.Snap, age, sh.Version, sh.Revision, size, note)
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t", sg.ID, sh.Snap, age, sh.Version, sh.Revision, size)
+			if showDiskSize {
+				diskSize := sh.DiskSize
+				if !sh.Incremental {
+					diskSize = sh.Size
+				}
+				fmt.Fprintf(w, "%s\t", fmtSize(diskSize))
+			}
+			fmt.Fprintln(w, note)
 		}
 	}
 	return nil
@@ -179,6 +254,7 @@ type saveCmd struct {
 	waitMixin
 	durationMixin
 	Users      string `long:"users"`
+	Encrypt    bool   `long:"encrypt"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -187,7 +263,15 @@ type saveCmd struct {
 func (x *saveCmd) Execute([]string) error {
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	setID, changeID, err := x.client.SnapshotMany(snaps, users)
+	opts := &client.SnapOptions{Users: users}
+	if x.Encrypt {
+		passphrase, err := readNewSnapshotPassphrase()
+		if err != nil {
+			return err
+		}
+		opts.Passphrase = passphrase
+	}
+	setID, changeID, err := x.client.SnapshotManyWithOptions(snaps, opts)
 	if err != nil {
 		return err
 	}
@@ -283,6 +367,8 @@ func (x *checkSnapshotCmd) Execute([]string) error {
 type restoreCmd struct {
 	waitMixin
 	Users      string `long:"users"`
+	Passphrase bool   `long:"passphrase"`
+	PrivateKey string `long:"private-key" value-name:"<file>"`
 	Positional struct {
 		ID    snapshotID          `positional-arg-name:"<id>"`
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
@@ -296,7 +382,25 @@ func (x *restoreCmd) Execute([]string) error {
 	}
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	changeID, err := x.client.RestoreSnapshots(setID, snaps, users)
+	var secrets *client.SnapshotSecrets
+	if x.Passphrase || x.PrivateKey != "" {
+		secrets = &client.SnapshotSecrets{}
+		if x.PrivateKey != "" {
+			key, err := ioutil.ReadFile(x.PrivateKey)
+			if err != nil {
+				return fmt.Errorf(i18n.G("cannot read private key: %v"), err)
+			}
+			secrets.PrivateKey = string(key)
+		}
+		if x.Passphrase {
+			passphrase, err := readSnapshotPassphrase(i18n.G("Passphrase: "))
+			if err != nil {
+				return err
+			}
+			secrets.Passphrase = passphrase
+		}
+	}
+	changeID, err := x.client.RestoreSnapshotsWithSecrets(setID, snaps, users, secrets)
 	if err != nil {
 		return err
 	}
@@ -319,6 +423,36 @@ func (x *restoreCmd) Execute([]string) error {
 	return nil
 }
 
+func readSnapshotPassphrase(prompt string) (string, error) {
+	fmt.Fprint(Stdout, prompt)
+	passphrase, err := ReadPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return "", err
+	}
+	return string(passphrase), nil
+}
+
+// readNewSnapshotPassphrase asks for a passphrase to encrypt snapshots with,
+// twice to guard against typos.
+func readNewSnapshotPassphrase() (string, error) {
+	passphrase, err := readSnapshotPassphrase(i18n.G("Passphrase: "))
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New(i18n.G("passphrase cannot be empty"))
+	}
+	confirmPassphrase, err := readSnapshotPassphrase(i18n.G("Confirm passphrase: "))
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirmPassphrase {
+		return "", errors.New(i18n.G("passphrases do not match"))
+	}
+	return passphrase, nil
+}
+
 func init() {
 	addCommand("saved",
 		shortSavedHelp,
@@ -340,6 +474,8 @@ func init() {
 		}, durationDescs.also(waitDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Snapshot data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"encrypt": i18n.G("Encrypt the snapshot with a passphrase, which is asked for"),
 		}), nil)
 
 	addCommand("restore",
@@ -350,6 +486,10 @@ func init() {
 		}, waitDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Restore data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"passphrase": i18n.G("Ask for the passphrase of an encrypted snapshot, or of the private key"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"private-key": i18n.G("File with the ASCII-armored private key to decrypt an encrypted snapshot with"),
 		}), []argDesc{
 			{
 				name: "<id>",
@@ -418,6 +558,38 @@ func init() {
 			},
 		})
 
+	addCommand("push-snapshot",
+		shortPushSnapshotHelp,
+		longPushSnapshotHelp,
+		func() flags.Commander {
+			return &pushSnapshotCmd{}
+		}, waitDescs, []argDesc{
+			{
+				name: "<id>",
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("Set id of snapshot to push"),
+			}, {
+				// TRANSLATORS: This should retain < ... >. The name is what a snapshot is stored as on the remote.
+				name: i18n.G("<name>"),
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("The name to store the snapshot as on the remote"),
+			},
+		})
+
+	addCommand("pull-snapshot",
+		shortPullSnapshotHelp,
+		longPullSnapshotHelp,
+		func() flags.Commander {
+			return &pullSnapshotCmd{}
+		}, durationDescs.also(waitDescs), []argDesc{
+			{
+				// TRANSLATORS: This should retain < ... >. The name is what a snapshot is stored as on the remote.
+				name: i18n.G("<name>"),
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("The name of the snapshot on the remote"),
+			},
+		})
+
 	addCommand("import-snapshot",
 		shortImportSnapshotHelp,
 		longImportSnapshotHelp,
@@ -521,3 +693,68 @@ func (x *importSnapshotCmd) Execute([]string) error {
 	}
 	return y.Execute(nil)
 }
+
+type pushSnapshotCmd struct {
+	waitMixin
+	Positional struct {
+		ID   snapshotID `positional-arg-name:"<id>" required:"true"`
+		Name string     `positional-arg-name:"<name>"`
+	} `positional-args:"yes"`
+}
+
+func (x *pushSnapshotCmd) Execute([]string) error {
+	setID, err := x.Positional.ID.ToUint()
+	if err != nil {
+		return err
+	}
+	changeID, err := x.client.PushSnapshots(setID, x.Positional.Name)
+	if err != nil {
+		return err
+	}
+	_, err = x.wait(changeID)
+	if err == noWait {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Pushed snapshot #%s.\n"), x.Positional.ID)
+	return nil
+}
+
+type pullSnapshotCmd struct {
+	waitMixin
+	durationMixin
+	Positional struct {
+		Name string `positional-arg-name:"<name>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *pullSnapshotCmd) Execute([]string) error {
+	changeID, err := x.client.PullSnapshots(x.Positional.Name)
+	if err != nil {
+		return err
+	}
+	chg, err := x.wait(changeID)
+	if err == noWait {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var setID uint64
+	if err := chg.Get("set-id", &setID); err != nil {
+		return fmt.Errorf(i18n.G("cannot get the ID of the pulled snapshot: %v"), err)
+	}
+	// TRANSLATORS: the first argument is the name of the snapshot on the remote, the second one its new identifier.
+	fmt.Fprintf(Stdout, i18n.G("Pulled snapshot %q as #%d\n"), x.Positional.Name, setID)
+	// display the details about this snapshot like import-snapshot does
+	y := &savedCmd{
+		clientMixin:   x.clientMixin,
+		durationMixin: x.durationMixin,
+		ID:            snapshotID(strconv.FormatUint(setID, 10)),
+	}
+	return y.Execute(nil)
+}
@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -43,10 +44,13 @@ var snapshotsTests = []getCmdArgs{{
 	error: `invalid argument for snapshot set id: expected a non-negative integer argument \(see 'snap help saved'\)`,
 }, {
 	args:   "saved --id=3",
-	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n3    htop  .*  2        1168      1B  auto\n",
+	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n3    htop  .*  2        1168      1B  auto, encrypted\n",
 }, {
 	args:   "saved",
 	stdout: "Set  Snap  Age    Version  Rev   Size    Notes\n1    htop  .*  2        1168      1B  -\n",
+}, {
+	args:   "saved --id=5",
+	stdout: "Set  Snap  Age    Version  Rev    Size    Disk    Notes\n5    htop  .*  2        1168   30.0kB  10.0kB  incremental\n5    core  .*  16-2.48  10000  20.0kB  20.0kB  scheduled\n",
 }, {
 	args:  "forget x",
 	error: `invalid argument for snapshot set id: expected a non-negative integer argument \(see 'snap help saved'\)`,
@@ -74,6 +78,15 @@ var snapshotsTests = []getCmdArgs{{
 }, {
 	args:  "export-snapshot 1",
 	error: "the required argument `<filename>` was not provided",
+}, {
+	args:  "push-snapshot x",
+	error: `invalid argument for snapshot set id: expected a non-negative integer argument \(see 'snap help saved'\)`,
+}, {
+	args:   "push-snapshot 1",
+	stdout: "Pushed snapshot #1.\n",
+}, {
+	args:  "pull-snapshot",
+	error: "the required argument `<name>` was not provided",
 }}
 
 func (s *SnapSuite) TestSnapSnaphotsTest(c *C) {
@@ -120,8 +133,12 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 			if r.Method == "GET" {
 				// simulate a 1-month old snapshot
 				snapshotTime := time.Now().AddDate(0, -1, 0).Format(time.RFC3339)
+				if r.URL.Query().Get("set") == "5" {
+					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":5,"snapshots":[{"set":5,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tar":""},"size":30000,"incremental":true,"disk-size":10000},{"set":5,"time":%q,"snap":"core","revision":"10000","snap-id":"Y","epoch":{"read":[0],"write":[0]},"summary":"","version":"16-2.48","sha3-384":{"archive.tgz":""},"size":20000,"scheduled":true}]}]}`, snapshotTime, snapshotTime)
+					return
+				}
 				if r.URL.Query().Get("set") == "3" {
-					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":3,"snapshots":[{"set":3,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","auto":true,"epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
+					fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":3,"snapshots":[{"set":3,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","auto":true,"encryption":"passphrase","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
 					return
 				}
 				fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":1,"snapshots":[{"set":1,"time":%q,"snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`, snapshotTime)
@@ -146,6 +163,109 @@ func (s *SnapSuite) mockSnapshotsServer(c *C) {
 	})
 }
 
+func (s *SnapSuite) TestSnapshotEncrypted(c *C) {
+	var actions []map[string]interface{}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			if r.Method == "GET" {
+				c.Check(r.URL.Query().Get("set"), Equals, "3")
+				fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":3,"snapshots":[{"set":3,"time":"2021-03-04T05:06:07Z","snap":"foo","revision":"1","snap-id":"Z","encryption":"passphrase","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`)
+				return
+			}
+			fallthrough
+		case "/v2/snaps":
+			c.Check(r.Method, Equals, "POST")
+			var action map[string]interface{}
+			c.Check(json.NewDecoder(r.Body).Decode(&action), IsNil)
+			actions = append(actions, action)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9", "result": {"set-id": 3}}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+	s.password = "sekrit"
+
+	keyFile := filepath.Join(c.MkDir(), "key.asc")
+	c.Assert(ioutil.WriteFile(keyFile, []byte("an armored key"), 0600), IsNil)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "foo"})
+	c.Assert(err, IsNil)
+	// the saved set is the one given by the server
+	c.Check(s.Stdout(), Matches, `(?ms).*^3 +foo +.*`)
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"restore", "--passphrase", "--private-key", keyFile, "3"})
+	c.Assert(err, IsNil)
+
+	c.Check(actions, DeepEquals, []map[string]interface{}{{
+		"action":     "snapshot",
+		"snaps":      []interface{}{"foo"},
+		"passphrase": "sekrit",
+	}, {
+		"set":         3.0,
+		"action":      "restore",
+		"passphrase":  "sekrit",
+		"private-key": "an armored key",
+	}})
+}
+
+func (s *SnapSuite) TestSnapshotPushPull(c *C) {
+	var actions []map[string]interface{}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			if r.Method == "GET" {
+				c.Check(r.URL.Query().Get("set"), Equals, "7")
+				fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":7,"snapshots":[{"set":7,"time":"2021-03-04T05:06:07Z","snap":"htop","revision":"1168","snap-id":"Z","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1}]}]}`)
+				return
+			}
+			var action map[string]interface{}
+			c.Check(json.NewDecoder(r.Body).Decode(&action), IsNil)
+			actions = append(actions, action)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9"}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {"set-id": 7, "snap-names": ["htop"]}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"push-snapshot", "3", "backup"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Pushed snapshot #3.\n")
+	s.stdout.Truncate(0)
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"pull-snapshot", "backup"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Matches, `Pulled snapshot "backup" as #7
+Set  Snap  Age .*
+7    htop  .*
+`)
+
+	c.Check(actions, DeepEquals, []map[string]interface{}{{
+		"set":    3.0,
+		"action": "push",
+		"name":   "backup",
+	}, {
+		"set":    0.0,
+		"action": "pull",
+		"name":   "backup",
+	}})
+}
+
+func (s *SnapSuite) TestSnapshotSaveEncryptEmptyPassphrase(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request %q", r.URL.Path)
+	})
+	s.password = ""
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "foo"})
+	c.Assert(err, ErrorMatches, "passphrase cannot be empty")
+}
+
 func (s *SnapSuite) TestSnapshotImportHappy(c *C) {
 	// mockSnapshotServer will return set-id 42 and three snaps for all
 	// import calls
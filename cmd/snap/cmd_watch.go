@@ -20,9 +20,13 @@
 package main
 
 import (
+	"context"
+
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/progress"
 )
 
 type cmdWatch struct{ changeIDMixin }
@@ -51,11 +55,61 @@ func (x *cmdWatch) Execute(args []string) error {
 		return err
 	}
 
+	_, err = x.watch(id)
+	return err
+}
+
+// watch waits for the change to be ready following the events streamed by
+// snapd, falling back to polling the change when they cannot be streamed
+// (e.g. with an older snapd) or when the stream ends (e.g. when snapd
+// restarts).
+func (x *cmdWatch) watch(id string) (*client.Change, error) {
 	// this is the only valid use of wait without a waitMixin (ie
 	// without --no-wait), so we fake it here.
 	wmx := &waitMixin{skipAbort: true}
 	wmx.client = x.client
-	_, err = wmx.wait(id)
 
-	return err
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := x.client.Events(ctx, &client.EventsOptions{
+		ChangeID: id,
+		Types:    []string{"change-status", "task-status", "task-progress"},
+	})
+	if err != nil {
+		return wmx.wait(id)
+	}
+	// the change might have progressed before the events were
+	// subscribed to
+	chg, err := x.client.Change(id)
+	if err != nil {
+		return wmx.wait(id)
+	}
+	if chg.Ready {
+		return changeResult(chg)
+	}
+
+	pb := progress.MakeProgressBar()
+	tp := newTaskProgress(pb)
+	for _, t := range chg.Tasks {
+		if t.Status == "Doing" {
+			tp.show(t)
+			break
+		}
+	}
+	for ev := range events {
+		switch {
+		case ev.Task != nil && ev.Task.Status == "Doing":
+			tp.show(ev.Task)
+		case ev.Type == "change-status" && ev.Ready:
+			pb.Finished()
+			chg, err := x.client.Change(id)
+			if err != nil {
+				return nil, err
+			}
+			return changeResult(chg)
+		}
+	}
+	pb.Finished()
+
+	return wmx.wait(id)
 }
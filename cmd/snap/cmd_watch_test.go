@@ -40,6 +40,8 @@ var fmtWatchChangeJSON = `{"type": "sync", "result": {
   "tasks": [{"id": "84", "kind": "bar", "summary": "some summary", "status": "Doing", "progress": {"label": "my-snap", "done": %d, "total": %d}, "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z"}]
 }}`
 
+const notFoundJSON = `{"type": "error", "status-code": 404, "result": {"message": "not found"}}`
+
 func (s *SnapSuite) TestCmdWatch(c *C) {
 	meter := &progresstest.Meter{}
 	defer progress.MockMeter(meter)()
@@ -48,6 +50,12 @@ func (s *SnapSuite) TestCmdWatch(c *C) {
 
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/events" {
+			// older snapd without events
+			w.WriteHeader(404)
+			fmt.Fprintln(w, notFoundJSON)
+			return
+		}
 		n++
 		switch n {
 		case 1:
@@ -84,6 +92,12 @@ func (s *SnapSuite) TestWatchLast(c *C) {
 
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/events" {
+			// older snapd without events
+			w.WriteHeader(404)
+			fmt.Fprintln(w, notFoundJSON)
+			return
+		}
 		n++
 		switch n {
 		case 1:
@@ -152,3 +166,69 @@ func (s *SnapSuite) TestWatchLastQuestionmark(c *C) {
 
 	c.Check(n, Equals, 4)
 }
+
+func (s *SnapSuite) TestCmdWatchEvents(c *C) {
+	meter := &progresstest.Meter{}
+	defer progress.MockMeter(meter)()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "GET")
+		switch n {
+		case 1:
+			c.Check(r.URL.Path, Equals, "/v2/events")
+			c.Check(r.URL.Query().Get("change-id"), Equals, "two")
+			fmt.Fprint(w, "\x1e"+`{"type": "task-progress", "change-id": "two", "task": {"id": "84", "summary": "some summary", "status": "Doing", "progress": {"label": "my-snap", "done": 51200, "total": 102400}}}`+"\n")
+			fmt.Fprint(w, "\x1e"+`{"type": "change-status", "change-id": "two", "status": "Done", "ready": true}`+"\n")
+		case 2:
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			fmt.Fprintf(w, fmtWatchChangeJSON, 0, 100*1024)
+		case 3:
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "two", "ready": true, "status": "Done"}}`)
+		default:
+			c.Errorf("expected 3 queries, currently on %d", n)
+		}
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"watch", "two"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Check(n, Equals, 3)
+	c.Check(meter.Values, DeepEquals, []float64{51200})
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestCmdWatchEventsStreamEnds(c *C) {
+	meter := &progresstest.Meter{}
+	defer progress.MockMeter(meter)()
+	defer snap.MockMaxGoneTime(time.Millisecond)()
+	defer snap.MockPollTime(time.Millisecond)()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "GET")
+		switch n {
+		case 1:
+			// the stream ends without the change being ready,
+			// e.g. as snapd restarts
+			c.Check(r.URL.Path, Equals, "/v2/events")
+		case 2:
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			fmt.Fprintf(w, fmtWatchChangeJSON, 0, 100*1024)
+		case 3:
+			// polling from now on
+			c.Check(r.URL.Path, Equals, "/v2/changes/two")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "two", "ready": true, "status": "Error", "err": "boom"}}`)
+		default:
+			c.Errorf("expected 3 queries, currently on %d", n)
+		}
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"watch", "two"})
+	c.Assert(err, ErrorMatches, "boom")
+	c.Check(n, Equals, 3)
+}
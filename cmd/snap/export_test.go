@@ -116,6 +116,10 @@ func SetVerbose(iw *infoWriter, verbose bool) {
 	iw.verbose = verbose
 }
 
+func SetHealthHistory(iw *infoWriter, hh *client.SnapHealthHistory) {
+	iw.healthHistory = hh
+}
+
 var (
 	ClientSnapFromPath          = clientSnapFromPath
 	SetupDiskSnap               = (*infoWriter).setupDiskSnap
@@ -108,6 +108,9 @@ var debugCommands []*cmdInfo
 // routineCommands holds information about all internal commands.
 var routineCommands []*cmdInfo
 
+// configCommands holds information about all "snap config" commands.
+var configCommands []*cmdInfo
+
 // addCommand replaces parser.addCommand() in a way that is compatible with
 // re-constructing a pristine parser.
 func addCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
@@ -155,6 +158,22 @@ func addRoutineCommand(name, shortHelp, longHelp string, builder func() flags.Co
 	return info
 }
 
+// addConfigCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding "snap config" commands.
+func addConfigCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+		optDescs:  optDescs,
+		argDescs:  argDescs,
+	}
+	configCommands = append(configCommands, info)
+	return info
+}
+
 type parserSetter interface {
 	setParser(*flags.Parser)
 }
@@ -337,7 +356,7 @@ func Parser(cli *client.Client) *flags.Parser {
 	// add --help like what go-flags would do for us, but hidden
 	addHelp(parser)
 
-	seen := make(map[string]bool, len(commands)+len(debugCommands)+len(routineCommands))
+	seen := make(map[string]bool, len(commands)+len(debugCommands)+len(routineCommands)+len(configCommands))
 	checkUnique := func(ci *cmdInfo, kind string) {
 		if seen[ci.shortHelp] && ci.shortHelp != "Internal" && ci.shortHelp != "Deprecated (hidden)" {
 			logger.Panicf(`%scommand %q has an already employed description != "Internal"|"Deprecated (hidden)": %s`, kind, ci.name, ci.shortHelp)
@@ -358,6 +377,15 @@ func Parser(cli *client.Client) *flags.Parser {
 	registerCommands(cli, parser, debugCommand, debugCommands, func(ci *cmdInfo) {
 		checkUnique(ci, "debug ")
 	})
+	// Add the config command
+	configCommand, err := parser.AddCommand("config", shortConfigHelp, longConfigHelp, &cmdConfig{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "config", err)
+	}
+	// Add all the sub-commands of the config command
+	registerCommands(cli, parser, configCommand, configCommands, func(ci *cmdInfo) {
+		checkUnique(ci, "config ")
+	})
 	// Add the internal command
 	routineCommand, err := parser.AddCommand("routine", shortRoutineHelp, longRoutineHelp, &cmdRoutine{})
 	routineCommand.Hidden = true
@@ -81,8 +81,7 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 
 	tMax := time.Time{}
 
-	var lastID string
-	lastLog := map[string]string{}
+	tp := newTaskProgress(pb)
 	for {
 		var rebootingErr error
 		chg, err := cli.Change(id)
@@ -116,35 +115,15 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 		}
 
 		for _, t := range chg.Tasks {
-			switch {
-			case t.Status != "Doing":
+			if t.Status != "Doing" {
 				continue
-			case t.Progress.Total == 1:
-				pb.Spin(t.Summary)
-				nowLog := lastLogStr(t.Log)
-				if lastLog[t.ID] != nowLog {
-					pb.Notify(nowLog)
-					lastLog[t.ID] = nowLog
-				}
-			case t.ID == lastID:
-				pb.Set(float64(t.Progress.Done))
-			default:
-				pb.Start(t.Summary, float64(t.Progress.Total))
-				lastID = t.ID
 			}
+			tp.show(t)
 			break
 		}
 
 		if chg.Ready {
-			if chg.Status == "Done" {
-				return chg, nil
-			}
-
-			if chg.Err != "" {
-				return chg, errors.New(chg.Err)
-			}
-
-			return nil, fmt.Errorf(i18n.G("change finished in status %q with no error message"), chg.Status)
+			return changeResult(chg)
 		}
 
 		if rebootingErr != nil {
@@ -158,6 +137,48 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 	}
 }
 
+// taskProgress shows the progress of the tasks of a change.
+type taskProgress struct {
+	pb      progress.Meter
+	lastID  string
+	lastLog map[string]string
+}
+
+func newTaskProgress(pb progress.Meter) *taskProgress {
+	return &taskProgress{pb: pb, lastLog: make(map[string]string)}
+}
+
+// show shows the progress of the given task in Doing status.
+func (tp *taskProgress) show(t *client.Task) {
+	switch {
+	case t.Progress.Total == 1:
+		tp.pb.Spin(t.Summary)
+		nowLog := lastLogStr(t.Log)
+		if tp.lastLog[t.ID] != nowLog {
+			tp.pb.Notify(nowLog)
+			tp.lastLog[t.ID] = nowLog
+		}
+	case t.ID == tp.lastID:
+		tp.pb.Set(float64(t.Progress.Done))
+	default:
+		tp.pb.Start(t.Summary, float64(t.Progress.Total))
+		tp.lastID = t.ID
+	}
+}
+
+// changeResult returns what waiting for the given ready change results in.
+func changeResult(chg *client.Change) (*client.Change, error) {
+	if chg.Status == "Done" {
+		return chg, nil
+	}
+
+	if chg.Err != "" {
+		return chg, errors.New(chg.Err)
+	}
+
+	return nil, fmt.Errorf(i18n.G("change finished in status %q with no error message"), chg.Status)
+}
+
 func lastLogStr(logs []string) string {
 	if len(logs) == 0 {
 		return ""
@@ -47,6 +47,8 @@ var api = []*Command{
 	snapFileCmd,
 	snapDownloadCmd,
 	snapConfCmd,
+	snapConfHistoryCmd,
+	configCmd,
 	interfacesCmd,
 	assertsCmd,
 	assertsFindManyCmd,
@@ -79,6 +81,10 @@ var api = []*Command{
 	systemRecoveryKeysCmd,
 	quotaGroupsCmd,
 	quotaGroupInfoCmd,
+	healthCmd,
+	metricsCmd,
+	eventsCmd,
+	applyCmd,
 }
 
 const (
@@ -137,6 +143,7 @@ var (
 	snapstateTryPath           = snapstate.TryPath
 	snapstateUpdate            = snapstate.Update
 	snapstateUpdateMany        = snapstate.UpdateMany
+	snapstateUpdatePathMany    = snapstate.UpdatePathMany
 	snapstateInstallMany       = snapstate.InstallMany
 	snapstateRemoveMany        = snapstate.RemoveMany
 	snapstateRevert            = snapstate.Revert
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/snapcore/snapd/overlord/applystate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var applyCmd = &Command{
+	Path:        "/v2/apply",
+	POST:        postApply,
+	WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
+}
+
+var (
+	applystatePlan  = applystate.Plan
+	applystateApply = applystate.Apply
+)
+
+// maxManifestSize is the maximum size of a manifest given to /v2/apply.
+const maxManifestSize = 1024 * 1024
+
+// manifestSnapNames returns the names of the snaps the manifest acts on.
+func manifestSnapNames(m *applystate.Manifest) []string {
+	var names []string
+	for _, sn := range m.Snaps {
+		if sn.Name != "system" {
+			names = append(names, sn.Name)
+		}
+	}
+	for _, grp := range m.QuotaGroups {
+		for _, name := range grp.Snaps {
+			if !strutil.ListContains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func postApply(c *Command, r *http.Request, user *auth.UserState) Response {
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxManifestSize+1))
+	if err != nil {
+		return BadRequest("cannot read manifest: %v", err)
+	}
+	if len(data) > maxManifestSize {
+		return BadRequest("cannot read manifest: too big")
+	}
+	m, err := applystate.ParseManifest(data)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	snapNames := manifestSnapNames(m)
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if r.URL.Query().Get("dry-run") == "true" {
+		actions, err := applystatePlan(st, m)
+		if err != nil {
+			return errToResponse(err, snapNames, BadRequest, "cannot apply manifest: %v")
+		}
+		return SyncResponse(map[string]interface{}{"plan": actions})
+	}
+
+	var userID int
+	if user != nil {
+		userID = user.ID
+	}
+	actions, tss, err := applystateApply(st, m, userID)
+	if err != nil {
+		return errToResponse(err, snapNames, BadRequest, "cannot apply manifest: %v")
+	}
+	if len(tss) == 0 {
+		// the system is already in the state of the manifest
+		return SyncResponse(map[string]interface{}{"plan": actions})
+	}
+
+	chg := newChange(st, "apply", "Apply manifest", tss, snapNames)
+	chg.Set("api-data", map[string]interface{}{"snap-names": snapNames})
+	ensureStateSoon(st)
+
+	return AsyncResponse(map[string]interface{}{"plan": actions}, chg.ID())
+}
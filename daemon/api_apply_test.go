@@ -0,0 +1,149 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/applystate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var _ = check.Suite(&applySuite{})
+
+type applySuite struct {
+	apiBaseSuite
+}
+
+func (s *applySuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
+}
+
+const applyManifest = `
+snaps:
+  - name: foo
+    channel: edge
+quota-groups:
+  - name: group
+    max-memory: 1MiB
+    snaps: [foo]
+`
+
+func (s *applySuite) TestApplyDryRun(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(daemon.MockApplystateApply(func(*state.State, *applystate.Manifest, int) ([]*applystate.Action, []*state.TaskSet, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil, nil
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/apply?dry-run=true", bytes.NewBufferString(applyManifest))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"plan": []*applystate.Action{
+			{Kind: "install", Target: "foo", Summary: `Install "foo" snap from channel "edge"`},
+			{Kind: "create-quota", Target: "group", Summary: `Create quota group "group"`},
+		},
+	})
+}
+
+func (s *applySuite) TestApplyDryRunError(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("POST", "/v2/apply?dry-run=true", bytes.NewBufferString(`connections: [{plug: "foo:home", slot: "core:home"}]`))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, `cannot apply manifest: cannot connect foo:home to core:home: snap "foo" is neither installed nor listed in the manifest`)
+}
+
+func (s *applySuite) TestApply(c *check.C) {
+	d := s.daemon(c)
+	soon := 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		soon++
+	})
+	defer restore()
+
+	actions := []*applystate.Action{{Kind: "install", Target: "foo", Summary: "..."}}
+	s.AddCleanup(daemon.MockApplystateApply(func(st *state.State, m *applystate.Manifest, userID int) ([]*applystate.Action, []*state.TaskSet, error) {
+		c.Check(m.Snaps, check.HasLen, 1)
+		return actions, []*state.TaskSet{state.NewTaskSet(st.NewTask("fake-install", "..."))}, nil
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/apply", bytes.NewBufferString(applyManifest))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"plan": actions})
+	c.Check(soon, check.Equals, 1)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "apply")
+	c.Check(chg.Tasks(), check.HasLen, 1)
+	var snapNames []string
+	c.Assert(chg.Get("snap-names", &snapNames), check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"foo"})
+}
+
+func (s *applySuite) TestApplyNothingToDo(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	snapstate.Set(st, "foo", &snapstate.SnapState{
+		Sequence:        []*snap.SideInfo{{RealName: "foo", Revision: snap.R(1)}},
+		Current:         snap.R(1),
+		Active:          true,
+		TrackingChannel: "latest/edge",
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("POST", "/v2/apply", bytes.NewBufferString(`snaps: [{name: foo, channel: edge}]`))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"plan": []*applystate.Action(nil)})
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (s *applySuite) TestApplyInvalidManifest(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("POST", "/v2/apply", bytes.NewBufferString(`snaps: [{name: Foo}]`))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `invalid manifest: invalid snap name: "Foo"`)
+}
@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var configCmd = &Command{
+	Path:        "/v2/config",
+	POST:        postConfig,
+	WriteAccess: authenticatedAccess{},
+}
+
+var (
+	configstateExportConfig = configstate.ExportConfig
+	configstateImportConfig = configstate.ImportConfig
+)
+
+type configAction struct {
+	Action string `json:"action"`
+	// for exporting
+	Snaps      []string `json:"snaps,omitempty"`
+	PrivateKey string   `json:"private-key,omitempty"`
+	Passphrase string   `json:"passphrase,omitempty"`
+	// for importing
+	Document  string `json:"document,omitempty"`
+	PublicKey string `json:"public-key,omitempty"`
+}
+
+func postConfig(c *Command, r *http.Request, user *auth.UserState) Response {
+	var action configAction
+	if err := jsonutil.DecodeWithNumber(r.Body, &action); err != nil {
+		return BadRequest("cannot decode request body: %v", err)
+	}
+	switch action.Action {
+	case "export":
+		return exportConfig(c, &action)
+	case "import":
+		return importConfig(c, r, user, &action)
+	default:
+		return BadRequest("unknown configuration action %q", action.Action)
+	}
+}
+
+func exportConfig(c *Command, action *configAction) Response {
+	if action.Document != "" || action.PublicKey != "" {
+		return BadRequest(`configuration "export" action cannot use a document or public key`)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	doc, err := configstateExportConfig(st, action.Snaps)
+	st.Unlock()
+	if err != nil {
+		return errToResponse(err, action.Snaps, InternalError, "cannot export configuration: %v")
+	}
+
+	data, err := doc.Encode(action.PrivateKey, []byte(action.Passphrase))
+	if err != nil {
+		return BadRequest("cannot export configuration: %v", err)
+	}
+	return SyncResponse(map[string]interface{}{"document": string(data)})
+}
+
+func importConfig(c *Command, r *http.Request, user *auth.UserState, action *configAction) Response {
+	if len(action.Snaps) > 0 || action.PrivateKey != "" || action.Passphrase != "" {
+		return BadRequest(`configuration "import" action cannot use snaps, a private key or passphrase`)
+	}
+	if action.Document == "" {
+		return BadRequest("cannot import configuration: no document given")
+	}
+	doc, err := configstate.DecodeConfigDocument([]byte(action.Document), action.PublicKey)
+	if err != nil {
+		return BadRequest("cannot import configuration: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	names, tss, err := configstateImportConfig(st, doc)
+	if err != nil {
+		return errToResponse(err, nil, BadRequest, "cannot import configuration: %v")
+	}
+
+	// the configure task of each snap, by the name it is given in the
+	// document, to report which snaps failed to be configured
+	configTasks := make(map[string]string, len(names))
+	docNames := make([]string, len(names))
+	var snapNames []string
+	for i, name := range names {
+		docNames[i] = configstate.RemapSnapToResponse(name)
+		configTasks[docNames[i]] = tss[i].Tasks()[0].ID()
+		if name != "core" {
+			snapNames = append(snapNames, name)
+		}
+	}
+	summary := fmt.Sprintf("Import configuration of %s", strutil.Quoted(docNames))
+	chg := newChange(st, "import-config", summary, tss, snapNames)
+	chg.Set("requested-by", requestedBy(r, user))
+	chg.Set("api-data", map[string]interface{}{
+		"snap-names":   snapNames,
+		"config-tasks": configTasks,
+	})
+
+	st.EnsureBefore(0)
+
+	return AsyncResponse(nil, chg.ID())
+}
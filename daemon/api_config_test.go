@@ -0,0 +1,207 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var _ = check.Suite(&configSuite{})
+
+type configSuite struct {
+	apiBaseSuite
+}
+
+func (s *configSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.expectWriteAccess(daemon.AuthenticatedAccess{})
+}
+
+func (s *configSuite) postConfig(c *check.C, action map[string]interface{}) *http.Request {
+	b, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/config", bytes.NewReader(b))
+	c.Assert(err, check.IsNil)
+	return req
+}
+
+func (s *configSuite) TestExportConfig(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(daemon.MockConfigstateExportConfig(func(st *state.State, snapNames []string) (*configstate.ConfigDocument, error) {
+		c.Check(snapNames, check.DeepEquals, []string{"system", "foo"})
+		return &configstate.ConfigDocument{
+			Type:     "config",
+			Version:  1,
+			Exported: time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC),
+			Config: map[string]map[string]interface{}{
+				"system": {"service": map[string]interface{}{"ssh": map[string]interface{}{"disable": true}}},
+				"foo":    {"port": json.Number("8080")},
+			},
+		}, nil
+	}))
+
+	req := s.postConfig(c, map[string]interface{}{"action": "export", "snaps": []string{"system", "foo"}})
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"document": `{
+  "type": "config",
+  "version": 1,
+  "exported": "2021-06-01T10:00:00Z",
+  "config": {
+    "foo": {
+      "port": 8080
+    },
+    "system": {
+      "service": {
+        "ssh": {
+          "disable": true
+        }
+      }
+    }
+  }
+}
+`})
+}
+
+func (s *configSuite) TestExportConfigNotInstalled(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(daemon.MockConfigstateExportConfig(func(st *state.State, snapNames []string) (*configstate.ConfigDocument, error) {
+		return nil, &snap.NotInstalledError{Snap: "foo"}
+	}))
+
+	req := s.postConfig(c, map[string]interface{}{"action": "export", "snaps": []string{"foo"}})
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapNotInstalled)
+	c.Check(rspe.Message, check.Equals, `snap "foo" is not installed`)
+}
+
+func (s *configSuite) TestExportConfigBadSigningKey(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(daemon.MockConfigstateExportConfig(func(st *state.State, snapNames []string) (*configstate.ConfigDocument, error) {
+		return &configstate.ConfigDocument{Type: "config", Version: 1}, nil
+	}))
+
+	req := s.postConfig(c, map[string]interface{}{"action": "export", "private-key": "garbage"})
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, `cannot export configuration: cannot read signing key: .*`)
+}
+
+func (s *configSuite) TestImportConfig(c *check.C) {
+	d := s.daemon(c)
+	s.AddCleanup(daemon.MockConfigstateImportConfig(func(st *state.State, doc *configstate.ConfigDocument) ([]string, []*state.TaskSet, error) {
+		c.Check(doc.Config, check.DeepEquals, map[string]map[string]interface{}{
+			"system": {"watchdog": map[string]interface{}{"runtime-timeout": "10s"}},
+			"foo":    {"port": json.Number("8080")},
+		})
+		return []string{"core", "foo"}, []*state.TaskSet{
+			state.NewTaskSet(st.NewTask("run-hook", "...")),
+			state.NewTaskSet(st.NewTask("run-hook", "...")),
+		}, nil
+	}))
+
+	req := s.postConfig(c, map[string]interface{}{
+		"action":   "import",
+		"document": `{"type":"config","version":1,"config":{"system":{"watchdog":{"runtime-timeout":"10s"}},"foo":{"port":8080}}}`,
+	})
+	s.asRootAuth(req)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "import-config")
+	c.Check(chg.Summary(), check.Equals, `Import configuration of "system", "foo"`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	var apiData map[string]interface{}
+	c.Assert(chg.Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]interface{}{
+		"snap-names": []interface{}{"foo"},
+		"config-tasks": map[string]interface{}{
+			"system": tasks[0].ID(),
+			"foo":    tasks[1].ID(),
+		},
+	})
+	var who string
+	c.Assert(chg.Get("requested-by", &who), check.IsNil)
+	c.Check(who, check.Equals, "root")
+}
+
+func (s *configSuite) TestImportConfigBadDocument(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(daemon.MockConfigstateImportConfig(func(st *state.State, doc *configstate.ConfigDocument) ([]string, []*state.TaskSet, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil, nil
+	}))
+
+	for _, t := range []struct {
+		action map[string]interface{}
+		err    string
+	}{
+		{map[string]interface{}{"action": "import"}, `cannot import configuration: no document given`},
+		{map[string]interface{}{"action": "import", "document": `{"type":"manifest","version":1}`}, `cannot import configuration: cannot decode configuration document: unexpected type "manifest"`},
+		{map[string]interface{}{"action": "import", "document": `{"type":"config","version":2}`}, `cannot import configuration: cannot decode configuration document: unsupported version 2`},
+		{map[string]interface{}{"action": "import", "document": `{"type":"config","version":1}`, "public-key": "key"}, `cannot import configuration: cannot verify configuration document: document is not signed`},
+		{map[string]interface{}{"action": "import", "document": `{}`, "snaps": []string{"foo"}}, `configuration "import" action cannot use snaps, a private key or passphrase`},
+	} {
+		req := s.postConfig(c, t.action)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Equals, t.err)
+	}
+}
+
+func (s *configSuite) TestImportConfigNothingToImport(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(daemon.MockConfigstateImportConfig(func(st *state.State, doc *configstate.ConfigDocument) ([]string, []*state.TaskSet, error) {
+		return nil, nil, errors.New("configuration is already as in the document")
+	}))
+
+	req := s.postConfig(c, map[string]interface{}{"action": "import", "document": `{"type":"config","version":1}`})
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot import configuration: configuration is already as in the document`)
+}
+
+func (s *configSuite) TestConfigBadAction(c *check.C) {
+	s.daemon(c)
+
+	req := s.postConfig(c, map[string]interface{}{"action": "frob"})
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `unknown configuration action "frob"`)
+}
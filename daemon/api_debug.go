@@ -50,6 +50,8 @@ type debugAction struct {
 		ChgID string `json:"chg-id"`
 
 		RecoverySystemLabel string `json:"recovery-system-label"`
+
+		Dir string `json:"dir"`
 	} `json:"params"`
 }
 
@@ -307,6 +309,8 @@ func getDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		return getChangeTimings(st, chgID, ensureTag, startupTag, all == "true")
 	case "seeding":
 		return getSeedingInfo(st)
+	case "download-cache":
+		return getDownloadCacheInfo(st)
 	default:
 		return BadRequest("unknown debug aspect %q", aspect)
 	}
@@ -346,6 +350,8 @@ func postDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		return getStacktraces()
 	case "create-recovery-system":
 		return createRecovery(st, a.Params.RecoverySystemLabel)
+	case "prefill-download-cache":
+		return prefillDownloadCache(st, a.Params.Dir)
 	default:
 		return BadRequest("unknown debug action: %v", a.Action)
 	}
@@ -0,0 +1,106 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"path/filepath"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+)
+
+type downloadCacheInfo struct {
+	Items int   `json:"items"`
+	Size  int64 `json:"size"`
+	// PinnedItems and PinnedSize are about the items that are also
+	// revisions of snaps on disk, which are never evicted.
+	PinnedItems int   `json:"pinned-items"`
+	PinnedSize  int64 `json:"pinned-size"`
+
+	MaxItems int    `json:"max-items"`
+	MaxSize  int64  `json:"max-size,omitempty"`
+	MaxAge   string `json:"max-age,omitempty"`
+}
+
+// downloadCacher is implemented by stores with a download cache.
+type downloadCacher interface {
+	DownloadCache() *store.CacheManager
+}
+
+func downloadCache(st *state.State) *store.CacheManager {
+	sto, ok := snapstate.Store(st, nil).(downloadCacher)
+	if !ok {
+		return nil
+	}
+	return sto.DownloadCache()
+}
+
+func getDownloadCacheInfo(st *state.State) Response {
+	cm := downloadCache(st)
+	if cm == nil {
+		return BadRequest("downloads are not cached")
+	}
+	// getting the policy needs the state
+	st.Unlock()
+	defer st.Lock()
+
+	stats, err := cm.Stats()
+	if err != nil {
+		return InternalError("cannot get download cache statistics: %v", err)
+	}
+	policy := cm.Policy()
+	info := downloadCacheInfo{
+		Items:       stats.Items,
+		Size:        stats.Size,
+		PinnedItems: stats.PinnedItems,
+		PinnedSize:  stats.PinnedSize,
+		MaxItems:    policy.MaxItems,
+		MaxSize:     policy.MaxSize,
+	}
+	if policy.MaxAge > 0 {
+		info.MaxAge = policy.MaxAge.String()
+	}
+	return SyncResponse(info)
+}
+
+func prefillDownloadCache(st *state.State, dir string) Response {
+	if dir == "" {
+		return BadRequest("cannot prefill the download cache without a directory")
+	}
+	if !filepath.IsAbs(dir) {
+		return BadRequest("cannot prefill the download cache from %q: not an absolute path", dir)
+	}
+	cm := downloadCache(st)
+	if cm == nil {
+		return BadRequest("downloads are not cached")
+	}
+	// this can take a while, and evicting needs the state
+	st.Unlock()
+	defer st.Lock()
+
+	added, err := cm.Prefill(dir)
+	if err != nil {
+		return InternalError("cannot prefill the download cache from %q: %v", dir, err)
+	}
+	return SyncResponse(map[string]interface{}{
+		"added": added,
+	})
+}
@@ -0,0 +1,135 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/store"
+)
+
+var _ = Suite(&downloadCacheDebugSuite{})
+
+type downloadCacheDebugSuite struct {
+	apiBaseSuite
+
+	cm *store.CacheManager
+}
+
+// downloadCacheStore is a store with a download cache
+type downloadCacheStore struct {
+	snapstate.StoreService
+	cm *store.CacheManager
+}
+
+func (sto downloadCacheStore) DownloadCache() *store.CacheManager {
+	return sto.cm
+}
+
+func (s *downloadCacheDebugSuite) SetUpTest(c *C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.expectWriteAccess(daemon.RootAccess{})
+
+	s.cm = store.NewCacheManager(c.MkDir(), 5)
+	s.cm.SetPolicy(func() store.CachePolicy {
+		return store.CachePolicy{MaxSize: 1000, MaxAge: 24 * time.Hour}
+	})
+
+	d := s.daemonWithOverlordMockAndStore(c)
+	st := d.Overlord().State()
+	st.Lock()
+	snapstate.ReplaceStore(st, downloadCacheStore{StoreService: s, cm: s.cm})
+	st.Unlock()
+}
+
+func (s *downloadCacheDebugSuite) TestGetDownloadCache(c *C) {
+	// one item is also installed
+	installed := filepath.Join(c.MkDir(), "installed")
+	c.Assert(ioutil.WriteFile(installed, []byte("1234"), 0644), IsNil)
+	c.Assert(s.cm.Put("installed", installed), IsNil)
+	other := filepath.Join(c.MkDir(), "other")
+	c.Assert(ioutil.WriteFile(other, []byte("12"), 0644), IsNil)
+	c.Assert(s.cm.Put("other", other), IsNil)
+	c.Assert(os.Remove(other), IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=download-cache", nil)
+	c.Assert(err, IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, DeepEquals, daemon.DownloadCacheInfo{
+		Items:       2,
+		Size:        6,
+		PinnedItems: 1,
+		PinnedSize:  4,
+		MaxItems:    5,
+		MaxSize:     1000,
+		MaxAge:      "24h0m0s",
+	})
+}
+
+func (s *downloadCacheDebugSuite) postDebug(c *C, action string, params map[string]interface{}) *http.Request {
+	buf, err := json.Marshal(map[string]interface{}{
+		"action": action,
+		"params": params,
+	})
+	c.Assert(err, IsNil)
+	req, err := http.NewRequest("POST", "/v2/debug", bytes.NewBuffer(buf))
+	c.Assert(err, IsNil)
+	return req
+}
+
+func (s *downloadCacheDebugSuite) TestPrefillDownloadCache(c *C) {
+	dir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "foo_1.snap"), []byte("foo"), 0644), IsNil)
+
+	req := s.postDebug(c, "prefill-download-cache", map[string]interface{}{"dir": dir})
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, DeepEquals, map[string]interface{}{"added": 1})
+
+	stats, err := s.cm.Stats()
+	c.Assert(err, IsNil)
+	c.Check(stats.Items, Equals, 1)
+}
+
+func (s *downloadCacheDebugSuite) TestPrefillDownloadCacheErrors(c *C) {
+	for _, t := range []struct {
+		dir    string
+		status int
+		err    string
+	}{
+		{"", 400, `cannot prefill the download cache without a directory`},
+		{"rel/dir", 400, `cannot prefill the download cache from "rel/dir": not an absolute path`},
+		{"/does/not/exist", 500, `cannot prefill the download cache from "/does/not/exist": .* no such file or directory`},
+	} {
+		req := s.postDebug(c, "prefill-download-cache", map[string]interface{}{"dir": t.dir})
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, Equals, t.status, Commentf("%s", t.dir))
+		c.Check(rspe.Message, Matches, t.err, Commentf("%s", t.dir))
+	}
+}
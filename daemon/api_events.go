@@ -0,0 +1,212 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var eventsCmd = &Command{
+	Path:       "/v2/events",
+	GET:        getEvents,
+	ReadAccess: openAccess{},
+}
+
+// eventTypes are the types of the events that can be streamed.
+var eventTypes = []string{
+	string(state.ChangeStatusEvent),
+	string(state.TaskStatusEvent),
+	string(state.TaskProgressEvent),
+	string(state.WarningEvent),
+}
+
+// eventsBufferSize is how many events can be queued for a client before
+// its stream is ended for not keeping up.
+var eventsBufferSize = 1000
+
+type eventFilter struct {
+	changeID   string
+	changeKind string
+	types      map[string]bool
+}
+
+func (f *eventFilter) match(ev *state.Event) bool {
+	if len(f.types) > 0 && !f.types[string(ev.Kind)] {
+		return false
+	}
+	if f.changeID == "" && f.changeKind == "" {
+		return true
+	}
+	if ev.Change == nil {
+		return false
+	}
+	if f.changeID != "" && ev.Change.ID() != f.changeID {
+		return false
+	}
+	if f.changeKind != "" && ev.Change.Kind() != f.changeKind {
+		return false
+	}
+	return true
+}
+
+func getEvents(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+	filter := &eventFilter{
+		changeID:   query.Get("change-id"),
+		changeKind: query.Get("change-kind"),
+	}
+	if types := strutil.CommaSeparatedList(query.Get("types")); len(types) > 0 {
+		filter.types = make(map[string]bool, len(types))
+		for _, typ := range types {
+			if !strutil.ListContains(eventTypes, typ) {
+				return BadRequest("invalid event type %q", typ)
+			}
+			filter.types[typ] = true
+		}
+	}
+
+	st := c.d.overlord.State()
+	if filter.changeID != "" {
+		st.Lock()
+		chg := st.Change(filter.changeID)
+		st.Unlock()
+		if chg == nil {
+			return NotFound("cannot find change with id %q", filter.changeID)
+		}
+	}
+
+	return &eventStreamResponse{
+		st:     st,
+		filter: filter,
+		dying:  c.d.tomb.Dying(),
+	}
+}
+
+// clientEvent returns the client representation of the event; it must be
+// called with the state locked.
+func clientEvent(ev *state.Event) *client.Event {
+	cev := &client.Event{
+		Type:    string(ev.Kind),
+		Time:    ev.Time,
+		Message: ev.Message,
+	}
+	if ev.Change != nil {
+		cev.ChangeID = ev.Change.ID()
+		cev.ChangeKind = ev.Change.Kind()
+	}
+	if ev.Kind == state.ChangeStatusEvent {
+		cev.Status = ev.Status.String()
+		cev.Ready = ev.Status.Ready()
+		if cev.Ready {
+			if err := ev.Change.Err(); err != nil {
+				cev.Err = err.Error()
+			}
+		}
+	}
+	if t := ev.Task; t != nil {
+		label, done, total := t.Progress()
+		cev.Task = &client.Task{
+			ID:       t.ID(),
+			Kind:     t.Kind(),
+			Summary:  t.Summary(),
+			Status:   t.Status().String(),
+			Progress: client.TaskProgress{Label: label, Done: done, Total: total},
+		}
+		// only the last log entry, as the others were already
+		// streamed with earlier events
+		if log := t.Log(); len(log) > 0 {
+			cev.Task.Log = log[len(log)-1:]
+		}
+	}
+	return cev
+}
+
+// An eventStreamResponse's ServeHTTP method streams the events happening
+// in the state that match its filter, as a json-seq, until the client goes
+// away or the daemon stops.
+type eventStreamResponse struct {
+	st     *state.State
+	filter *eventFilter
+	dying  <-chan struct{}
+}
+
+// ServeHTTP from the Response interface
+func (s *eventStreamResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	events := make(chan *client.Event, eventsBufferSize)
+	overflow := make(chan struct{})
+	overflowed := false
+
+	s.st.Lock()
+	id := s.st.AddEventHandler(func(ev *state.Event) {
+		if overflowed || !s.filter.match(ev) {
+			return
+		}
+		select {
+		case events <- clientEvent(ev):
+		default:
+			overflowed = true
+			close(overflow)
+		}
+	})
+	s.st.Unlock()
+	defer func() {
+		s.st.Lock()
+		s.st.RemoveEventHandler(id)
+		s.st.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json-seq")
+	w.WriteHeader(200)
+	flusher, hasFlusher := w.(http.Flusher)
+	if hasFlusher {
+		// let the client know it is subscribed
+		flusher.Flush()
+	}
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case ev := <-events:
+			if _, err := w.Write([]byte{0x1E}); err != nil { // RS -- see ascii(7), and RFC7464
+				return
+			}
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+			if hasFlusher {
+				flusher.Flush()
+			}
+		case <-overflow:
+			logger.Noticef("cannot stream events: client is not keeping up")
+			return
+		case <-r.Context().Done():
+			return
+		case <-s.dying:
+			return
+		}
+	}
+}
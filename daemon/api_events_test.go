@@ -0,0 +1,226 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var _ = check.Suite(&eventsSuite{})
+
+type eventsSuite struct {
+	apiBaseSuite
+}
+
+func (s *eventsSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.daemon(c)
+}
+
+// flushRecorder is a response recorder that signals every flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed chan struct{}
+}
+
+func (r *flushRecorder) Flush() {
+	r.ResponseRecorder.Flush()
+	r.flushed <- struct{}{}
+}
+
+// streamEvents starts serving the events request, calls generate once the
+// stream is set up, and returns the events streamed.
+func (s *eventsSuite) streamEvents(c *check.C, query string, nEvents int, generate func(st *state.State)) (*httptest.ResponseRecorder, []client.Event) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest("GET", "/v2/events"+query, nil)
+	c.Assert(err, check.IsNil)
+	req = req.WithContext(ctx)
+
+	rsp := s.req(c, req, nil)
+	rec := &flushRecorder{
+		ResponseRecorder: httptest.NewRecorder(),
+		flushed:          make(chan struct{}, 100),
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rsp.ServeHTTP(rec, req)
+	}()
+
+	// the stream is set up once the headers are flushed
+	<-rec.flushed
+	st := s.d.Overlord().State()
+	st.Lock()
+	generate(st)
+	st.Unlock()
+	for i := 0; i < nEvents; i++ {
+		<-rec.flushed
+	}
+	cancel()
+	<-done
+
+	var events []client.Event
+	for _, rec := range bytes.Split(rec.Body.Bytes(), []byte{0x1E}) {
+		if len(rec) == 0 {
+			continue
+		}
+		var ev client.Event
+		c.Assert(json.Unmarshal(rec, &ev), check.IsNil)
+		events = append(events, ev)
+	}
+	return rec.ResponseRecorder, events
+}
+
+func (s *eventsSuite) TestEvents(c *check.C) {
+	var chg *state.Change
+	var t *state.Task
+	rec, events := s.streamEvents(c, "", 6, func(st *state.State) {
+		chg = st.NewChange("install-snap", "...")
+		t = st.NewTask("download-snap", "Download")
+		chg.AddTask(t)
+		t.SetStatus(state.DoingStatus)
+		t.SetProgress("foo", 1, 2)
+		t.Errorf("boom")
+		t.SetStatus(state.ErrorStatus)
+		st.Warnf("hello")
+	})
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(rec.Header().Get("Content-Type"), check.Equals, "application/json-seq")
+	c.Assert(events, check.HasLen, 6)
+
+	for i := range events {
+		c.Check(events[i].Time.IsZero(), check.Equals, false)
+		events[i].Time = time.Time{}
+	}
+	logEntry := events[4].Task.Log
+	c.Assert(logEntry, check.HasLen, 1)
+	c.Check(logEntry[0], check.Matches, `.* ERROR boom`)
+
+	c.Check(events, check.DeepEquals, []client.Event{{
+		Type:       "task-status",
+		ChangeID:   chg.ID(),
+		ChangeKind: "install-snap",
+		Task: &client.Task{
+			ID:       t.ID(),
+			Kind:     "download-snap",
+			Summary:  "Download",
+			Status:   "Doing",
+			Progress: client.TaskProgress{Done: 1, Total: 1},
+		},
+	}, {
+		Type:       "change-status",
+		ChangeID:   chg.ID(),
+		ChangeKind: "install-snap",
+		Status:     "Doing",
+	}, {
+		Type:       "task-progress",
+		ChangeID:   chg.ID(),
+		ChangeKind: "install-snap",
+		Task: &client.Task{
+			ID:       t.ID(),
+			Kind:     "download-snap",
+			Summary:  "Download",
+			Status:   "Doing",
+			Progress: client.TaskProgress{Label: "foo", Done: 1, Total: 2},
+		},
+	}, {
+		Type:       "task-status",
+		ChangeID:   chg.ID(),
+		ChangeKind: "install-snap",
+		Task: &client.Task{
+			ID:       t.ID(),
+			Kind:     "download-snap",
+			Summary:  "Download",
+			Status:   "Error",
+			Progress: client.TaskProgress{Label: "foo", Done: 1, Total: 2},
+			Log:      logEntry,
+		},
+	}, {
+		Type:       "change-status",
+		ChangeID:   chg.ID(),
+		ChangeKind: "install-snap",
+		Status:     "Error",
+		Ready:      true,
+		Err:        "cannot perform the following tasks:\n- Download (boom)",
+	}, {
+		Type:    "warning",
+		Message: "hello",
+	}})
+}
+
+func (s *eventsSuite) TestEventsFiltered(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	chg1 := st.NewChange("install-snap", "...")
+	t1 := st.NewTask("download-snap", "...")
+	chg1.AddTask(t1)
+	chg2 := st.NewChange("remove-snap", "...")
+	t2 := st.NewTask("unlink-snap", "...")
+	chg2.AddTask(t2)
+	st.Unlock()
+
+	_, events := s.streamEvents(c, "?change-id="+chg2.ID()+"&types=task-status", 1, func(st *state.State) {
+		t1.SetStatus(state.DoingStatus)
+		st.Warnf("hello")
+		t2.SetStatus(state.DoingStatus)
+	})
+	c.Assert(events, check.HasLen, 1)
+	c.Check(events[0].Type, check.Equals, "task-status")
+	c.Check(events[0].Task.ID, check.Equals, t2.ID())
+
+	_, events = s.streamEvents(c, "?change-kind=install-snap", 2, func(st *state.State) {
+		t2.SetStatus(state.DoneStatus)
+		st.Warnf("hello")
+		t1.SetStatus(state.DoneStatus)
+	})
+	c.Assert(events, check.HasLen, 2)
+	c.Check(events[0].Type, check.Equals, "task-status")
+	c.Check(events[0].ChangeID, check.Equals, chg1.ID())
+	c.Check(events[1].Type, check.Equals, "change-status")
+	c.Check(events[1].ChangeID, check.Equals, chg1.ID())
+	c.Check(events[1].Ready, check.Equals, true)
+}
+
+func (s *eventsSuite) TestEventsUnknownChange(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/events?change-id=42", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, `cannot find change with id "42"`)
+}
+
+func (s *eventsSuite) TestEventsInvalidType(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/events?types=task-status,foo", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `invalid event type "foo"`)
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox"
@@ -112,6 +113,14 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err != nil {
 		return InternalError("cannot get refresh schedule: %s", err)
 	}
+	refreshWindows, err := snapstate.RefreshWindows(st)
+	if err != nil {
+		return InternalError("cannot get refresh windows: %s", err)
+	}
+	refreshCoordination, err := snapstate.LastRefreshCoordination(st)
+	if err != nil {
+		return InternalError("cannot get refresh coordinator status: %s", err)
+	}
 	users, err := auth.Users(st)
 	if err != nil && err != state.ErrNoState {
 		return InternalError("cannot get user auth data: %s", err)
@@ -127,6 +136,19 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	} else {
 		refreshInfo.Schedule = refreshScheduleStr
 	}
+	now := time.Now()
+	for _, w := range refreshWindows {
+		refreshInfo.Windows = append(refreshInfo.Windows, client.RefreshWindowInfo{
+			Name:  w.Name,
+			Timer: w.Timer,
+			Snaps: w.Snaps,
+			Open:  w.Open(now),
+			Next:  formatRefreshTime(w.NextOpen(now)),
+		})
+	}
+	if refreshCoordination != nil {
+		refreshInfo.Coordinator = refreshCoordinatorInfo(refreshCoordination)
+	}
 
 	m := map[string]interface{}{
 		"series":         release.Series,
@@ -167,6 +189,29 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(m)
 }
 
+func refreshCoordinatorInfo(rc *snapstate.RefreshCoordination) *client.RefreshCoordinatorInfo {
+	info := &client.RefreshCoordinatorInfo{
+		URL:   rc.URL,
+		Last:  formatRefreshTime(rc.Time),
+		Error: rc.Error,
+	}
+	if len(rc.Snaps) > 0 {
+		info.Snaps = make(map[string]client.RefreshCoordinatorDecision, len(rc.Snaps))
+	}
+	for name, d := range rc.Snaps {
+		decision := client.RefreshCoordinatorDecision{
+			Action: d.Action,
+			Until:  formatRefreshTime(d.Until),
+			Reason: d.Reason,
+		}
+		if !d.Revision.Unset() {
+			decision.Revision = d.Revision.String()
+		}
+		info.Snaps[name] = decision
+	}
+	return info
+}
+
 func formatRefreshTime(t time.Time) string {
 	if t.IsZero() {
 		return ""
@@ -33,14 +33,17 @@ import (
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox"
+	"github.com/snapcore/snapd/snap"
 )
 
 var _ = check.Suite(&generalSuite{})
@@ -328,6 +331,65 @@ func (s *generalSuite) TestSysInfoIsManaged(c *check.C) {
 	c.Check(rsp.Result.(map[string]interface{})["managed"], check.Equals, true)
 }
 
+func (s *generalSuite) TestSysInfoRefreshWindows(c *check.C) {
+	d := s.daemon(c)
+
+	st := d.Overlord().State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "refresh.windows.db.timer", "00:00-24:00")
+	tr.Set("core", "refresh.windows.db.snaps", "postgres,mysql")
+	tr.Commit()
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	refreshInfo := rsp.Result.(map[string]interface{})["refresh"].(client.RefreshInfo)
+	c.Assert(refreshInfo.Windows, check.HasLen, 1)
+	w := refreshInfo.Windows[0]
+	c.Check(w.Name, check.Equals, "db")
+	c.Check(w.Timer, check.Equals, "00:00-24:00")
+	c.Check(w.Snaps, check.DeepEquals, []string{"mysql", "postgres"})
+	c.Check(w.Open, check.Equals, true)
+	c.Check(w.Next, check.Not(check.Equals), "")
+}
+
+func (s *generalSuite) TestSysInfoRefreshCoordinator(c *check.C) {
+	d := s.daemon(c)
+
+	st := d.Overlord().State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "refresh.coordinator", "http://coordinator.example.com/refresh")
+	tr.Commit()
+	last := time.Date(2021, 8, 2, 9, 0, 0, 0, time.UTC)
+	st.Set("refresh-coordination", &snapstate.RefreshCoordination{
+		URL:  "http://coordinator.example.com/refresh",
+		Time: last,
+		Snaps: map[string]*snapstate.CoordinatorDecision{
+			"foo": {Action: "delay", Until: last.Add(time.Hour), Reason: "canary failing"},
+			"bar": {Action: "pin", Revision: snap.R(9)},
+		},
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	refreshInfo := rsp.Result.(map[string]interface{})["refresh"].(client.RefreshInfo)
+	c.Check(refreshInfo.Coordinator, check.DeepEquals, &client.RefreshCoordinatorInfo{
+		URL:  "http://coordinator.example.com/refresh",
+		Last: "2021-08-02T09:00:00Z",
+		Snaps: map[string]client.RefreshCoordinatorDecision{
+			"foo": {Action: "delay", Until: "2021-08-02T10:00:00Z", Reason: "canary failing"},
+			"bar": {Action: "pin", Revision: "9"},
+		},
+	})
+}
+
 func (s *generalSuite) TestSysInfoWorksDegraded(c *check.C) {
 	d := s.daemon(c)
 
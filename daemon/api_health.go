@@ -0,0 +1,91 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var healthCmd = &Command{
+	Path:       "/v2/health",
+	GET:        getHealth,
+	ReadAccess: openAccess{},
+}
+
+func getHealth(c *Command, r *http.Request, user *auth.UserState) Response {
+	wanted := strutil.CommaSeparatedList(r.URL.Query().Get("snaps"))
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapStates, err := snapstate.All(st)
+	if err != nil {
+		return InternalError("cannot list local snaps: %v", err)
+	}
+	healths, err := healthstate.All(st)
+	if err != nil {
+		return InternalError("cannot get snap health: %v", err)
+	}
+	history, err := healthstate.History(st)
+	if err != nil {
+		return InternalError("cannot get snap health history: %v", err)
+	}
+
+	names := wanted
+	if len(names) == 0 {
+		names = make([]string, 0, len(snapStates))
+		for name := range snapStates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	results := make([]*client.SnapHealthHistory, 0, len(names))
+	for _, name := range names {
+		snapst, ok := snapStates[name]
+		if !ok {
+			return SnapNotFound(name, fmt.Errorf("snap %q is not installed", name))
+		}
+		result := &client.SnapHealthHistory{
+			Snap:   name,
+			Health: clientHealthFromHealthstate(healths[name]),
+		}
+		if info, err := snapst.CurrentInfo(); err == nil {
+			if hook := info.Hooks["check-health"]; hook != nil && hook.Interval > 0 {
+				result.CheckInterval = hook.Interval.String()
+			}
+		}
+		for _, h := range history[name] {
+			result.History = append(result.History, clientHealthFromHealthstate(h))
+		}
+		results = append(results, result)
+	}
+
+	return SyncResponse(results)
+}
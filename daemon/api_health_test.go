@@ -0,0 +1,95 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+var _ = check.Suite(&healthSuite{})
+
+type healthSuite struct {
+	apiBaseSuite
+}
+
+func (s *healthSuite) TestGetHealth(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "hooks:\n  check-health:\n    interval: 10m\n")
+	s.mkInstalledInState(c, d, "baz", "bar", "v1", snap.R(5), true, "")
+
+	t0 := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("health", map[string]*healthstate.HealthState{
+		"foo": {Revision: snap.R(10), Timestamp: t0.Add(30 * time.Minute), Status: healthstate.ErrorStatus, Message: "broken"},
+	})
+	st.Set("health-history", map[string][]*healthstate.HealthState{
+		"foo": {
+			{Revision: snap.R(10), Timestamp: t0, Status: healthstate.OkayStatus},
+			{Revision: snap.R(10), Timestamp: t0.Add(20 * time.Minute), Status: healthstate.ErrorStatus, Message: "broken"},
+		},
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/health", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []*client.SnapHealthHistory{
+		{Snap: "baz"},
+		{
+			Snap:          "foo",
+			CheckInterval: "10m0s",
+			Health: &client.SnapHealth{
+				Revision:  snap.R(10),
+				Timestamp: t0.Add(30 * time.Minute),
+				Status:    "error",
+				Message:   "broken",
+			},
+			History: []*client.SnapHealth{
+				{Revision: snap.R(10), Timestamp: t0, Status: "okay"},
+				{Revision: snap.R(10), Timestamp: t0.Add(20 * time.Minute), Status: "error", Message: "broken"},
+			},
+		},
+	})
+
+	req, err = http.NewRequest("GET", "/v2/health?snaps=baz", nil)
+	c.Assert(err, check.IsNil)
+	rsp = s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*client.SnapHealthHistory{{Snap: "baz"}})
+}
+
+func (s *healthSuite) TestGetHealthNotInstalled(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/health?snaps=foo", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapNotFound)
+	c.Check(rspe.Message, check.Equals, `snap "foo" is not installed`)
+}
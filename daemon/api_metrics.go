@@ -0,0 +1,183 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var metricsCmd = &Command{
+	Path:       "/v2/metrics",
+	GET:        getMetrics,
+	ReadAccess: authenticatedAccess{},
+}
+
+// refreshChangeKinds are the kinds of changes whose outcomes are reported
+// as refresh outcomes.
+var refreshChangeKinds = map[string]bool{
+	"refresh-snap":  true,
+	"refresh-snaps": true,
+	"auto-refresh":  true,
+}
+
+func getMetrics(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	tr := config.NewTransaction(st)
+	enabled, err := features.Flag(tr, features.Metrics)
+	if err != nil && !config.IsNoOption(err) {
+		return InternalError("cannot check metrics feature flag: %v", err)
+	}
+	if !enabled {
+		return NotFound("metrics are disabled - enable them by setting 'experimental.metrics' to true")
+	}
+
+	var buf bytes.Buffer
+	w := metrics.NewWriter(&buf)
+	writeChangeMetrics(w, st)
+	writeTaskMetrics(w, st)
+	if err := writeQuotaMetrics(w, st); err != nil {
+		return InternalError("cannot get quota groups: %v", err)
+	}
+	w.Histogram("snapd_store_request_duration_seconds", "seconds",
+		"Duration of the requests to the store.", metrics.StoreRequestDuration.Snapshot())
+	w.Histogram("snapd_ensure_duration_seconds", "seconds",
+		"Duration of the runs of the ensure loop.", metrics.EnsureDuration.Snapshot())
+	if err := w.Close(); err != nil {
+		return InternalError("cannot write metrics: %v", err)
+	}
+
+	return metricsResponse(buf.Bytes())
+}
+
+type kindStatus struct {
+	kind   string
+	status string
+}
+
+func kindStatusSamples(counts map[kindStatus]int) []metrics.Sample {
+	keys := make([]kindStatus, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		return keys[i].status < keys[j].status
+	})
+	samples := make([]metrics.Sample, len(keys))
+	for i, k := range keys {
+		samples[i] = metrics.Sample{
+			Labels: []metrics.Label{{Name: "kind", Value: k.kind}, {Name: "status", Value: k.status}},
+			Value:  float64(counts[k]),
+		}
+	}
+	return samples
+}
+
+func writeChangeMetrics(w *metrics.Writer, st *state.State) {
+	changes := make(map[kindStatus]int)
+	refreshes := make(map[kindStatus]int)
+	for _, chg := range st.Changes() {
+		status := chg.Status()
+		k := kindStatus{kind: chg.Kind(), status: status.String()}
+		changes[k]++
+		if refreshChangeKinds[k.kind] && status.Ready() {
+			refreshes[k]++
+		}
+	}
+	// changes are pruned from the state after a while, so these are
+	// gauges rather than counters
+	w.Gauge("snapd_changes", "", "Number of changes in the state by kind and status.",
+		kindStatusSamples(changes))
+	w.Gauge("snapd_refresh_outcomes", "", "Number of finished refresh changes in the state by kind and status.",
+		kindStatusSamples(refreshes))
+}
+
+func writeTaskMetrics(w *metrics.Writer, st *state.State) {
+	durations := make(map[string]*metrics.SummarySample)
+	for _, t := range st.Tasks() {
+		if !t.Status().Ready() {
+			continue
+		}
+		s := durations[t.Kind()]
+		if s == nil {
+			s = &metrics.SummarySample{Labels: []metrics.Label{{Name: "kind", Value: t.Kind()}}}
+			durations[t.Kind()] = s
+		}
+		s.Count++
+		s.Sum += (t.DoingTime() + t.UndoingTime()).Seconds()
+	}
+	kinds := make([]string, 0, len(durations))
+	for kind := range durations {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	samples := make([]metrics.SummarySample, len(kinds))
+	for i, kind := range kinds {
+		samples[i] = *durations[kind]
+	}
+	w.Summary("snapd_task_duration_seconds", "seconds", "Time spent doing and undoing finished tasks in the state by kind.",
+		samples)
+}
+
+func writeQuotaMetrics(w *metrics.Writer, st *state.State) error {
+	quotas, err := servicestate.AllQuotas(st)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(quotas))
+	for name := range quotas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var usage, limits []metrics.Sample
+	for _, name := range names {
+		grp := quotas[name]
+		labels := []metrics.Label{{Name: "group", Value: name}}
+		if grp.MemoryLimit != 0 {
+			limits = append(limits, metrics.Sample{Labels: labels, Value: float64(grp.MemoryLimit)})
+		}
+		mem, err := getQuotaMemUsage(grp)
+		if err != nil {
+			// do not fail the whole scrape because of one group
+			logger.Noticef("cannot get memory usage of quota group %q: %v", name, err)
+			continue
+		}
+		usage = append(usage, metrics.Sample{Labels: labels, Value: float64(mem)})
+	}
+	w.Gauge("snapd_quota_memory_usage_bytes", "bytes", "Current memory usage of quota groups.", usage)
+	w.Gauge("snapd_quota_memory_limit_bytes", "bytes", "Memory limit of quota groups.", limits)
+	return nil
+}
@@ -0,0 +1,165 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/testutil"
+)
+
+var _ = check.Suite(&metricsSuite{})
+
+type metricsSuite struct {
+	apiBaseSuite
+}
+
+func (s *metricsSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.daemon(c)
+
+	s.expectReadAccess(daemon.AuthenticatedAccess{})
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "experimental.metrics", true)
+	tr.Set("core", "experimental.quota-groups", true)
+	tr.Commit()
+
+	s.AddCleanup(servicestate.MockSystemdVersion(248))
+}
+
+func (s *metricsSuite) getMetrics(c *check.C) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/v2/metrics", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.req(c, req, nil)
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *metricsSuite) TestMetrics(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	chg := st.NewChange("auto-refresh", "...")
+	t := st.NewTask("link-snap", "...")
+	t.SetStatus(state.DoneStatus)
+	chg.AddTask(t)
+	chg = st.NewChange("refresh-snap", "...")
+	t = st.NewTask("link-snap", "...")
+	t.SetStatus(state.ErrorStatus)
+	chg.AddTask(t)
+	chg = st.NewChange("install-snap", "...")
+	chg.AddTask(st.NewTask("download-snap", "..."))
+	err := servicestate.CreateQuota(st, "foo", "", nil, quota.Resources{Memory: 11000})
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+
+	r := daemon.MockGetQuotaMemUsage(func(grp *quota.Group) (quantity.Size, error) {
+		c.Check(grp.Name, check.Equals, "foo")
+		return quantity.Size(5000), nil
+	})
+	defer r()
+
+	rec := s.getMetrics(c)
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(rec.Header().Get("Content-Type"), check.Equals, "application/openmetrics-text; version=1.0.0; charset=utf-8")
+
+	body := rec.Body.String()
+	c.Check(strings.HasPrefix(body, `# TYPE snapd_changes gauge
+# HELP snapd_changes Number of changes in the state by kind and status.
+snapd_changes{kind="auto-refresh",status="Done"} 1.0
+snapd_changes{kind="install-snap",status="Do"} 1.0
+snapd_changes{kind="refresh-snap",status="Error"} 1.0
+# TYPE snapd_refresh_outcomes gauge
+# HELP snapd_refresh_outcomes Number of finished refresh changes in the state by kind and status.
+snapd_refresh_outcomes{kind="auto-refresh",status="Done"} 1.0
+snapd_refresh_outcomes{kind="refresh-snap",status="Error"} 1.0
+# TYPE snapd_task_duration_seconds summary
+# UNIT snapd_task_duration_seconds seconds
+# HELP snapd_task_duration_seconds Time spent doing and undoing finished tasks in the state by kind.
+snapd_task_duration_seconds_count{kind="link-snap"} 2
+snapd_task_duration_seconds_sum{kind="link-snap"} 0.0
+# TYPE snapd_quota_memory_usage_bytes gauge
+# UNIT snapd_quota_memory_usage_bytes bytes
+# HELP snapd_quota_memory_usage_bytes Current memory usage of quota groups.
+snapd_quota_memory_usage_bytes{group="foo"} 5000.0
+# TYPE snapd_quota_memory_limit_bytes gauge
+# UNIT snapd_quota_memory_limit_bytes bytes
+# HELP snapd_quota_memory_limit_bytes Memory limit of quota groups.
+snapd_quota_memory_limit_bytes{group="foo"} 11000.0
+`), check.Equals, true, check.Commentf("unexpected metrics:\n%s", body))
+	c.Check(body, check.Matches, `(?s).*
+# TYPE snapd_store_request_duration_seconds histogram
+.*snapd_store_request_duration_seconds_bucket\{le="\+Inf"\} [0-9]+
+.*
+# TYPE snapd_ensure_duration_seconds histogram
+.*snapd_ensure_duration_seconds_count [0-9]+
+.*
+# EOF
+`)
+}
+
+func (s *metricsSuite) TestMetricsQuotaUsageError(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestate.CreateQuota(st, "foo", "", nil, quota.Resources{Memory: 11000})
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+
+	r := daemon.MockGetQuotaMemUsage(func(grp *quota.Group) (quantity.Size, error) {
+		return 0, errors.New("boom")
+	})
+	defer r()
+
+	rec := s.getMetrics(c)
+	c.Check(rec.Code, check.Equals, 200)
+	body := rec.Body.String()
+	c.Check(body, check.Not(testutil.Contains), `snapd_quota_memory_usage_bytes{`)
+	c.Check(body, testutil.Contains, `snapd_quota_memory_limit_bytes{group="foo"} 11000.0`)
+}
+
+func (s *metricsSuite) TestMetricsDisabled(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "experimental.metrics", false)
+	tr.Commit()
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/metrics", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, "metrics are disabled - enable them by setting 'experimental.metrics' to true")
+}
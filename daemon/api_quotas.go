@@ -49,11 +49,78 @@ var (
 
 type postQuotaGroupData struct {
 	// Action can be "ensure" or "remove"
-	Action    string   `json:"action"`
-	GroupName string   `json:"group-name"`
-	MaxMemory uint64   `json:"max-memory,omitempty"`
-	Parent    string   `json:"parent,omitempty"`
-	Snaps     []string `json:"snaps,omitempty"`
+	Action     string                     `json:"action"`
+	GroupName  string                     `json:"group-name"`
+	MaxMemory  uint64                     `json:"max-memory,omitempty"`
+	CPU        *client.QuotaCPUValues     `json:"cpu,omitempty"`
+	MaxThreads int                        `json:"max-threads,omitempty"`
+	Journal    *client.QuotaJournalValues `json:"journal,omitempty"`
+	Parent     string                     `json:"parent,omitempty"`
+	Snaps      []string                   `json:"snaps,omitempty"`
+}
+
+// resourceLimits returns the quota resource limits set in the request.
+func (data *postQuotaGroupData) resourceLimits() quota.Resources {
+	limits := quota.Resources{
+		Memory:  quantity.Size(data.MaxMemory),
+		Threads: data.MaxThreads,
+	}
+	if data.CPU != nil {
+		limits.CPU = &quota.GroupQuotaCPU{
+			Count:       data.CPU.Count,
+			Percentage:  data.CPU.Percentage,
+			AllowedCPUs: data.CPU.CPUs,
+		}
+	}
+	if data.Journal != nil {
+		limits.Journal = &quota.GroupQuotaJournal{
+			Size:       quantity.Size(data.Journal.Size),
+			RateCount:  data.Journal.RateLimitCount,
+			RatePeriod: data.Journal.RateLimitPeriod,
+		}
+	}
+	return limits
+}
+
+// quotaGroupResult returns the result for the quota group with the current
+// resource usage of the group.
+func quotaGroupResult(grp *quota.Group) (*client.QuotaGroupResult, error) {
+	memoryUsage, err := getQuotaMemUsage(grp)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &client.QuotaGroupResult{
+		GroupName:     grp.Name,
+		Parent:        grp.ParentGroup,
+		Subgroups:     grp.SubGroups,
+		Snaps:         grp.Snaps,
+		MaxMemory:     uint64(grp.MemoryLimit),
+		MaxThreads:    grp.ThreadLimit,
+		CurrentMemory: uint64(memoryUsage),
+	}
+	if grp.CPULimit != nil {
+		res.CPU = &client.QuotaCPUValues{
+			Count:      grp.CPULimit.Count,
+			Percentage: grp.CPULimit.Percentage,
+			CPUs:       grp.CPULimit.AllowedCPUs,
+		}
+	}
+	if grp.JournalLimit != nil {
+		res.Journal = &client.QuotaJournalValues{
+			Size:            uint64(grp.JournalLimit.Size),
+			RateLimitCount:  grp.JournalLimit.RateCount,
+			RateLimitPeriod: grp.JournalLimit.RatePeriod,
+		}
+	}
+	// the number of threads is only interesting when they are limited
+	if grp.ThreadLimit != 0 {
+		res.CurrentThreads, err = getQuotaTaskUsage(grp)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
 }
 
 var (
@@ -66,6 +133,10 @@ var getQuotaMemUsage = func(grp *quota.Group) (quantity.Size, error) {
 	return grp.CurrentMemoryUsage()
 }
 
+var getQuotaTaskUsage = func(grp *quota.Group) (int, error) {
+	return grp.CurrentTaskUsage()
+}
+
 // getQuotaGroups returns all quota groups sorted by name.
 func getQuotaGroups(c *Command, r *http.Request, _ *auth.UserState) Response {
 	st := c.d.overlord.State()
@@ -87,21 +158,11 @@ func getQuotaGroups(c *Command, r *http.Request, _ *auth.UserState) Response {
 
 	results := make([]client.QuotaGroupResult, len(quotas))
 	for i, name := range names {
-		qt := quotas[name]
-
-		memoryUsage, err := getQuotaMemUsage(qt)
+		res, err := quotaGroupResult(quotas[name])
 		if err != nil {
 			return InternalError(err.Error())
 		}
-
-		results[i] = client.QuotaGroupResult{
-			GroupName:     qt.Name,
-			Parent:        qt.ParentGroup,
-			Subgroups:     qt.SubGroups,
-			Snaps:         qt.Snaps,
-			MaxMemory:     uint64(qt.MemoryLimit),
-			CurrentMemory: uint64(memoryUsage),
-		}
+		results[i] = *res
 	}
 	return SyncResponse(results)
 }
@@ -126,20 +187,11 @@ func getQuotaGroupInfo(c *Command, r *http.Request, _ *auth.UserState) Response
 		return InternalError(err.Error())
 	}
 
-	memoryUsage, err := getQuotaMemUsage(group)
+	res, err := quotaGroupResult(group)
 	if err != nil {
 		return InternalError(err.Error())
 	}
-
-	res := client.QuotaGroupResult{
-		GroupName:     group.Name,
-		Parent:        group.ParentGroup,
-		Snaps:         group.Snaps,
-		Subgroups:     group.SubGroups,
-		MaxMemory:     uint64(group.MemoryLimit),
-		CurrentMemory: uint64(memoryUsage),
-	}
-	return SyncResponse(res)
+	return SyncResponse(*res)
 }
 
 // postQuotaGroup creates quota resource group or updates an existing group.
@@ -169,15 +221,15 @@ func postQuotaGroup(c *Command, r *http.Request, _ *auth.UserState) Response {
 		}
 		if err == servicestate.ErrQuotaNotFound {
 			// then we need to create the quota
-			if err := servicestateCreateQuota(st, data.GroupName, data.Parent, data.Snaps, quantity.Size(data.MaxMemory)); err != nil {
+			if err := servicestateCreateQuota(st, data.GroupName, data.Parent, data.Snaps, data.resourceLimits()); err != nil {
 				// XXX: dedicated error type?
 				return BadRequest(err.Error())
 			}
 		} else if err == nil {
 			// the quota group already exists, update it
 			updateOpts := servicestate.QuotaGroupUpdate{
-				AddSnaps:       data.Snaps,
-				NewMemoryLimit: quantity.Size(data.MaxMemory),
+				AddSnaps:          data.Snaps,
+				NewResourceLimits: data.resourceLimits(),
 			}
 			if err := servicestateUpdateQuota(st, data.GroupName, updateOpts); err != nil {
 				return BadRequest(err.Error())
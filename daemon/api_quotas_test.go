@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -62,11 +63,11 @@ func (s *apiQuotaSuite) SetUpTest(c *check.C) {
 }
 
 func mockQuotas(st *state.State, c *check.C) {
-	err := servicestate.CreateQuota(st, "foo", "", nil, 11000)
+	err := servicestate.CreateQuota(st, "foo", "", nil, quota.Resources{Memory: 11000})
 	c.Assert(err, check.IsNil)
-	err = servicestate.CreateQuota(st, "bar", "foo", nil, 6000)
+	err = servicestate.CreateQuota(st, "bar", "foo", nil, quota.Resources{Memory: 6000})
 	c.Assert(err, check.IsNil)
-	err = servicestate.CreateQuota(st, "baz", "foo", nil, 5000)
+	err = servicestate.CreateQuota(st, "baz", "foo", nil, quota.Resources{Memory: 5000})
 	c.Assert(err, check.IsNil)
 }
 
@@ -93,11 +94,11 @@ func (s *apiQuotaSuite) TestPostQuotaInvalidGroupName(c *check.C) {
 }
 
 func (s *apiQuotaSuite) TestPostEnsureQuotaUnhappy(c *check.C) {
-	daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) error {
+	daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, resourceLimits quota.Resources) error {
 		c.Check(name, check.Equals, "booze")
 		c.Check(parentName, check.Equals, "foo")
 		c.Check(snaps, check.DeepEquals, []string{"bar"})
-		c.Check(memoryLimit, check.DeepEquals, quantity.Size(1000))
+		c.Check(resourceLimits, check.DeepEquals, quota.Resources{Memory: quantity.Size(1000)})
 		return fmt.Errorf("boom")
 	})
 
@@ -119,12 +120,12 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUnhappy(c *check.C) {
 
 func (s *apiQuotaSuite) TestPostEnsureQuotaCreateHappy(c *check.C) {
 	var called int
-	daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) error {
+	daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, resourceLimits quota.Resources) error {
 		called++
 		c.Check(name, check.Equals, "booze")
 		c.Check(parentName, check.Equals, "foo")
 		c.Check(snaps, check.DeepEquals, []string{"some-snap"})
-		c.Check(memoryLimit, check.DeepEquals, quantity.Size(1000))
+		c.Check(resourceLimits, check.DeepEquals, quota.Resources{Memory: quantity.Size(1000)})
 		return nil
 	})
 
@@ -144,14 +145,61 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaCreateHappy(c *check.C) {
 	c.Assert(called, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaCreateAllLimitsHappy(c *check.C) {
+	var called int
+	daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, resourceLimits quota.Resources) error {
+		called++
+		c.Check(name, check.Equals, "booze")
+		c.Check(resourceLimits, check.DeepEquals, quota.Resources{
+			Memory: quantity.Size(1000),
+			CPU: &quota.GroupQuotaCPU{
+				Count:       2,
+				Percentage:  50,
+				AllowedCPUs: []int{2, 3},
+			},
+			Threads: 128,
+			Journal: &quota.GroupQuotaJournal{
+				Size:       quantity.SizeMiB,
+				RateCount:  10,
+				RatePeriod: time.Minute,
+			},
+		})
+		return nil
+	})
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "booze",
+		MaxMemory: 1000,
+		CPU: &client.QuotaCPUValues{
+			Count:      2,
+			Percentage: 50,
+			CPUs:       []int{2, 3},
+		},
+		MaxThreads: 128,
+		Journal: &client.QuotaJournalValues{
+			Size:            uint64(quantity.SizeMiB),
+			RateLimitCount:  10,
+			RateLimitPeriod: time.Minute,
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(called, check.Equals, 1)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateHappy(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
-	err := servicestate.CreateQuota(st, "ginger-ale", "", nil, 5000)
+	err := servicestate.CreateQuota(st, "ginger-ale", "", nil, quota.Resources{Memory: 5000})
 	st.Unlock()
 	c.Assert(err, check.IsNil)
 
-	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) error {
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, parentName string, snaps []string, resourceLimits quota.Resources) error {
 		c.Errorf("should not have called create quota")
 		return fmt.Errorf("broken test")
 	})
@@ -162,8 +210,8 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateHappy(c *check.C) {
 		updateCalled++
 		c.Assert(name, check.Equals, "ginger-ale")
 		c.Assert(opts, check.DeepEquals, servicestate.QuotaGroupUpdate{
-			AddSnaps:       []string{"some-snap"},
-			NewMemoryLimit: 9000,
+			AddSnaps:          []string{"some-snap"},
+			NewResourceLimits: quota.Resources{Memory: 9000},
 		})
 		return nil
 	})
@@ -335,6 +383,55 @@ func (s *apiQuotaSuite) TestGetQuota(c *check.C) {
 	})
 }
 
+func (s *apiQuotaSuite) TestGetQuotaAllLimits(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestate.CreateQuota(st, "foo", "", nil, quota.Resources{
+		Memory: 11000,
+		CPU: &quota.GroupQuotaCPU{
+			Percentage:  50,
+			AllowedCPUs: []int{0},
+		},
+		Threads: 32,
+		Journal: &quota.GroupQuotaJournal{
+			Size: quantity.SizeMiB,
+		},
+	})
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	r := daemon.MockGetQuotaMemUsage(func(grp *quota.Group) (quantity.Size, error) {
+		return quantity.Size(500), nil
+	})
+	defer r()
+	r = daemon.MockGetQuotaTaskUsage(func(grp *quota.Group) (int, error) {
+		c.Assert(grp.Name, check.Equals, "foo")
+		return 16, nil
+	})
+	defer r()
+
+	req, err := http.NewRequest("GET", "/v2/quotas/foo", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.FitsTypeOf, client.QuotaGroupResult{})
+	res := rsp.Result.(client.QuotaGroupResult)
+	c.Check(res, check.DeepEquals, client.QuotaGroupResult{
+		GroupName: "foo",
+		MaxMemory: 11000,
+		CPU: &client.QuotaCPUValues{
+			Percentage: 50,
+			CPUs:       []int{0},
+		},
+		MaxThreads: 32,
+		Journal: &client.QuotaJournalValues{
+			Size: uint64(quantity.SizeMiB),
+		},
+		CurrentMemory:  500,
+		CurrentThreads: 16,
+	})
+}
+
 func (s *apiQuotaSuite) TestGetQuotaInvalidName(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
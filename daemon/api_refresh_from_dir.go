@@ -0,0 +1,208 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// localSnapDir holds the snaps and assertions read from a directory to
+// refresh from, such as one on removable media. The snaps are copied over
+// as the directory may go away before the refresh is done.
+type localSnapDir struct {
+	dir   string
+	batch *asserts.Batch
+	// origPaths are the paths in the directory of the copied snaps
+	origPaths []string
+	tempPaths []string
+	// handedOff are the copies that the refresh change is in charge of
+	handedOff map[string]bool
+}
+
+func readLocalSnapDir(dir string) (*localSnapDir, error) {
+	if !filepath.IsAbs(dir) {
+		return nil, fmt.Errorf("cannot refresh from %q: not an absolute path", dir)
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot refresh from %q: %v", dir, err)
+	}
+
+	local := &localSnapDir{
+		dir:       dir,
+		batch:     asserts.NewBatch(nil),
+		handedOff: make(map[string]bool),
+	}
+	// we are in charge of the copies life cycle until we hand them off
+	// to the change
+	success := false
+	defer func() {
+		if !success {
+			local.cleanup()
+		}
+	}()
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		switch filepath.Ext(fi.Name()) {
+		case ".assert":
+			if err := addAssertsFile(local.batch, path); err != nil {
+				return nil, fmt.Errorf("cannot read assertions from %q: %v", path, err)
+			}
+		case ".snap":
+			// if you change this prefix, look for it in the tests
+			// also see localInstallCleanup in snapstate/snapmgr.go
+			tempPath, err := copyToTempFile(path, dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix)
+			if err != nil {
+				return nil, fmt.Errorf("cannot copy %q: %v", path, err)
+			}
+			local.origPaths = append(local.origPaths, path)
+			local.tempPaths = append(local.tempPaths, tempPath)
+		}
+	}
+	if len(local.tempPaths) == 0 {
+		return nil, fmt.Errorf("cannot refresh from %q: no snaps found", dir)
+	}
+	success = true
+	return local, nil
+}
+
+func addAssertsFile(batch *asserts.Batch, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = batch.AddStream(f)
+	return err
+}
+
+func copyToTempFile(path, dir, prefix string) (tempPath string, err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	tmpf, err := ioutil.TempFile(dir, prefix)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if cerr := tmpf.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(tmpf.Name())
+		}
+	}()
+	if _, err := io.Copy(tmpf, src); err != nil {
+		return "", err
+	}
+	if err := tmpf.Sync(); err != nil {
+		return "", err
+	}
+	return tmpf.Name(), nil
+}
+
+// cleanup removes the copies of the snaps that were not handed off to a
+// change.
+func (local *localSnapDir) cleanup() {
+	for _, tempPath := range local.tempPaths {
+		if !local.handedOff[tempPath] {
+			os.Remove(tempPath)
+		}
+	}
+}
+
+// snapUpdateManyFromDir refreshes the snaps from the local snaps, after
+// importing the assertions found along them. Only snaps with assertions
+// are considered, and of those only the newest revision of each.
+func snapUpdateManyFromDir(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
+	local := inst.localSnaps
+	if err := assertstate.AddBatch(st, local.batch, &asserts.CommitOptions{
+		Precheck: true,
+	}); err != nil {
+		return nil, fmt.Errorf("cannot import assertions from %q: %v", local.dir, err)
+	}
+
+	db := assertstate.DB(st)
+	newest := make(map[string]int)
+	var sideInfos []*snap.SideInfo
+	var paths []string
+	for i, tempPath := range local.tempPaths {
+		si, err := snapasserts.DeriveSideInfo(tempPath, db)
+		if asserts.IsNotFound(err) {
+			logger.Noticef("Not refreshing from %q: cannot find signatures with metadata for snap.", local.origPaths[i])
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if j, ok := newest[si.SnapID]; ok {
+			if sideInfos[j].Revision.N >= si.Revision.N {
+				continue
+			}
+			sideInfos[j], paths[j] = si, tempPath
+			continue
+		}
+		newest[si.SnapID] = len(sideInfos)
+		sideInfos = append(sideInfos, si)
+		paths = append(paths, tempPath)
+	}
+
+	flags := &snapstate.Flags{RemoveSnapPath: true}
+	// TODO: use a per-request context
+	updated, tasksets, err := snapstateUpdatePathMany(context.TODO(), st, sideInfos, paths, inst.Snaps, inst.userID, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshed := make(map[string]bool, len(updated))
+	for _, name := range updated {
+		refreshed[snap.InstanceSnap(name)] = true
+	}
+	for i, si := range sideInfos {
+		if refreshed[si.RealName] {
+			local.handedOff[paths[i]] = true
+		}
+	}
+
+	return &snapInstructionResult{
+		Summary:  updateManySummary(inst.Snaps, updated),
+		Affected: updated,
+		Tasksets: tasksets,
+	}, nil
+}
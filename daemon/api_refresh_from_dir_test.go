@@ -0,0 +1,207 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+var _ = check.Suite(&refreshFromDirSuite{})
+
+type refreshFromDirSuite struct {
+	apiBaseSuite
+}
+
+func (s *refreshFromDirSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
+}
+
+func (s *refreshFromDirSuite) writeAsserts(c *check.C, path string) {
+	dev1Acct := assertstest.NewAccount(s.StoreSigning, "devel1", nil, "")
+	snapDecl, err := s.StoreSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "x-id",
+		"snap-name":    "x",
+		"publisher-id": dev1Acct.AccountID(),
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	snapRev, err := s.StoreSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-sha3-384": "YK0GWATaZf09g_fvspYPqm_qtaiqf-KjaNj5uMEQCjQpuXWPjqQbeBINL5H_A0Lo",
+		"snap-size":     "5",
+		"snap-id":       "x-id",
+		"snap-revision": "41",
+		"developer-id":  dev1Acct.AccountID(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	buf := new(bytes.Buffer)
+	enc := asserts.NewEncoder(buf)
+	for _, a := range []asserts.Assertion{s.StoreSigning.StoreAccountKey(""), dev1Acct, snapDecl, snapRev} {
+		c.Assert(enc.Encode(a), check.IsNil)
+	}
+	c.Assert(ioutil.WriteFile(path, buf.Bytes(), 0644), check.IsNil)
+}
+
+func (s *refreshFromDirSuite) fromDirReq(c *check.C, action, dir string) *http.Request {
+	buf, err := json.Marshal(map[string]interface{}{
+		"action":   action,
+		"from-dir": dir,
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBuffer(buf))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (s *refreshFromDirSuite) tempCopies(c *check.C) []string {
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapBlobDir, dirs.LocalInstallBlobTempPrefix+"*"))
+	c.Assert(err, check.IsNil)
+	return matches
+}
+
+func (s *refreshFromDirSuite) TestRefreshFromDir(c *check.C) {
+	d := s.daemonWithOverlordMockAndStore(c)
+
+	dir := c.MkDir()
+	s.writeAsserts(c, filepath.Join(dir, "x.assert"))
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "x.snap"), []byte("xyzzy"), 0644), check.IsNil)
+	// no assertions for this one
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "y.snap"), []byte("plugh"), 0644), check.IsNil)
+	// ignored
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "README"), []byte("hello"), 0644), check.IsNil)
+
+	var refreshedPath string
+	defer daemon.MockSnapstateUpdatePathMany(func(_ context.Context, _ *state.State, sideInfos []*snap.SideInfo, paths []string, names []string, userID int, flags *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		c.Check(flags, check.DeepEquals, &snapstate.Flags{RemoveSnapPath: true})
+		c.Check(names, check.HasLen, 0)
+		c.Check(sideInfos, check.DeepEquals, []*snap.SideInfo{{
+			RealName: "x",
+			SnapID:   "x-id",
+			Revision: snap.R(41),
+		}})
+		c.Assert(paths, check.HasLen, 1)
+		c.Check(strings.HasPrefix(filepath.Base(paths[0]), dirs.LocalInstallBlobTempPrefix), check.Equals, true)
+		content, err := ioutil.ReadFile(paths[0])
+		c.Assert(err, check.IsNil)
+		c.Check(string(content), check.Equals, "xyzzy")
+		refreshedPath = paths[0]
+
+		t := s.d.Overlord().State().NewTask("fake-refresh-snap", "Doing a fake refresh")
+		return []string{"x"}, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	})()
+
+	rsp := s.asyncReq(c, s.fromDirReq(c, "refresh", dir), nil)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, `Refresh snap "x"`)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"x"})
+
+	// only the copy handed off to the change is left around
+	c.Check(s.tempCopies(c), check.DeepEquals, []string{refreshedPath})
+	// the originals are untouched
+	c.Check(filepath.Join(dir, "x.snap"), testutil.FilePresent)
+	c.Check(filepath.Join(dir, "y.snap"), testutil.FilePresent)
+}
+
+func (s *refreshFromDirSuite) TestRefreshFromDirNothingRefreshed(c *check.C) {
+	d := s.daemonWithOverlordMockAndStore(c)
+
+	dir := c.MkDir()
+	s.writeAsserts(c, filepath.Join(dir, "x.assert"))
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "x.snap"), []byte("xyzzy"), 0644), check.IsNil)
+
+	defer daemon.MockSnapstateUpdatePathMany(func(_ context.Context, _ *state.State, sideInfos []*snap.SideInfo, paths []string, names []string, userID int, flags *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		c.Check(sideInfos, check.HasLen, 1)
+		return nil, nil, nil
+	})()
+
+	rsp := s.asyncReq(c, s.fromDirReq(c, "refresh", dir), nil)
+
+	st := d.Overlord().State()
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, "Refresh all snaps: no updates")
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+	st.Unlock()
+
+	// the copies were not handed off to any change
+
+	c.Check(s.tempCopies(c), check.HasLen, 0)
+}
+
+func (s *refreshFromDirSuite) TestRefreshFromDirErrors(c *check.C) {
+	s.daemonWithOverlordMockAndStore(c)
+
+	defer daemon.MockSnapstateUpdatePathMany(func(context.Context, *state.State, []*snap.SideInfo, []string, []string, int, *snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		c.Fatalf("unexpected call to UpdatePathMany")
+		return nil, nil, nil
+	})()
+
+	empty := c.MkDir()
+	badAsserts := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(badAsserts, "x.snap"), []byte("xyzzy"), 0644), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(badAsserts, "x.assert"), []byte("garbage"), 0644), check.IsNil)
+
+	for _, t := range []struct {
+		action, dir, err string
+	}{
+		{"install", empty, `from-dir can only be specified for refresh`},
+		{"refresh", "relative/dir", `cannot refresh from "relative/dir": not an absolute path`},
+		{"refresh", filepath.Join(empty, "missing"), `cannot refresh from ".*/missing": .* no such file or directory`},
+		{"refresh", empty, `cannot refresh from ".*": no snaps found`},
+		{"refresh", badAsserts, `cannot read assertions from ".*/x.assert": .*`},
+	} {
+		rspe := s.errorReq(c, s.fromDirReq(c, t.action, t.dir), nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%s", t.dir))
+		c.Check(rspe.Message, check.Matches, t.err, check.Commentf("%s", t.dir))
+	}
+
+	c.Check(s.tempCopies(c), check.HasLen, 0)
+}
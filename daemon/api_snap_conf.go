@@ -20,6 +20,8 @@
 package daemon
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -33,6 +35,8 @@ import (
 	"github.com/snapcore/snapd/strutil"
 )
 
+var configstateRevertConfig = configstate.RevertConfig
+
 var (
 	snapConfCmd = &Command{
 		Path:        "/v2/snaps/{name}/conf",
@@ -41,13 +45,25 @@ var (
 		ReadAccess:  authenticatedAccess{},
 		WriteAccess: authenticatedAccess{},
 	}
+
+	snapConfHistoryCmd = &Command{
+		Path:        "/v2/snaps/{name}/conf-history",
+		GET:         getSnapConfHistory,
+		POST:        postSnapConfHistory,
+		ReadAccess:  authenticatedAccess{},
+		WriteAccess: authenticatedAccess{},
+	}
 )
 
 func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	vars := muxVars(r)
 	snapName := configstate.RemapSnapFromRequest(vars["name"])
 
-	keys := strutil.CommaSeparatedList(r.URL.Query().Get("keys"))
+	query := r.URL.Query()
+	if query.Get("schema") == "true" {
+		return getSnapConfSchema(c, snapName)
+	}
+	keys := strutil.CommaSeparatedList(query.Get("keys"))
 
 	s := c.d.overlord.State()
 	s.Lock()
@@ -78,6 +94,8 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 				return InternalError("%v", err)
 			}
 		}
+		// secrets are only revealed to the snap itself
+		value = config.RedactSecrets(value)
 		if key == "" {
 			if len(keys) > 1 {
 				return BadRequest("keys contains zero-length string")
@@ -91,6 +109,29 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(currentConfValues)
 }
 
+// getSnapConfSchema returns the configuration schema of the snap.
+func getSnapConfSchema(c *Command, snapName string) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	// the "core" snap/pseudonym has no configuration schema
+	if snapName == "core" {
+		return SyncResponse(snap.ConfigSchema{})
+	}
+	schema, err := configstate.ConfigSchema(st, snapName)
+	if err != nil {
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			return SnapNotFound(snapName, err)
+		}
+		return InternalError("%v", err)
+	}
+	if schema == nil {
+		schema = snap.ConfigSchema{}
+	}
+	return SyncResponse(schema)
+}
+
 func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	vars := muxVars(r)
 	snapName := configstate.RemapSnapFromRequest(vars["name"])
@@ -104,17 +145,139 @@ func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	st.Lock()
 	defer st.Unlock()
 
+	if r.URL.Query().Get("secret") == "true" {
+		if err := configstate.EncryptSecrets(st, snapName, patchValues, true); err != nil {
+			return BadRequest("%v", err)
+		}
+	}
+
 	taskset, err := configstate.ConfigureInstalled(st, snapName, patchValues, 0)
 	if err != nil {
 		// TODO: just return snap-not-installed instead ?
 		if _, ok := err.(*snap.NotInstalledError); ok {
 			return SnapNotFound(snapName, err)
 		}
+		if _, ok := err.(*configstate.SchemaError); ok {
+			return BadRequest("%v", err)
+		}
 		return errToResponse(err, []string{snapName}, InternalError, "%v")
 	}
 
 	summary := fmt.Sprintf("Change configuration of %q snap", snapName)
 	change := newChange(st, "configure-snap", summary, []*state.TaskSet{taskset}, []string{snapName})
+	change.Set("requested-by", requestedBy(r, user))
+
+	st.EnsureBefore(0)
+
+	return AsyncResponse(nil, change.ID())
+}
+
+// requestedBy returns who made the request, as recorded in the
+// configuration history.
+func requestedBy(r *http.Request, user *auth.UserState) string {
+	if user != nil {
+		if user.Username != "" {
+			return user.Username
+		}
+		if user.Email != "" {
+			return user.Email
+		}
+	}
+	ucred, err := ucrednetGet(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	if ucred.Uid == 0 {
+		return "root"
+	}
+	return fmt.Sprintf("uid %d", ucred.Uid)
+}
+
+func getSnapConfHistory(c *Command, r *http.Request, user *auth.UserState) Response {
+	vars := muxVars(r)
+	snapName := configstate.RemapSnapFromRequest(vars["name"])
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	history, err := configstate.History(st, snapName)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+
+	entries := make([]client.ConfHistoryEntry, len(history))
+	for i, entry := range history {
+		changes := make([]client.ConfChange, len(entry.Changes))
+		for j, change := range entry.Changes {
+			old, err := redactedRaw(change.Old)
+			if err != nil {
+				return InternalError("%v", err)
+			}
+			new, err := redactedRaw(change.New)
+			if err != nil {
+				return InternalError("%v", err)
+			}
+			changes[j] = client.ConfChange{Key: change.Key, Old: old, New: new}
+		}
+		entries[i] = client.ConfHistoryEntry{
+			ID:       entry.ID,
+			Time:     entry.Time,
+			User:     entry.User,
+			ChangeID: entry.ChangeID,
+			Changes:  changes,
+		}
+	}
+	return SyncResponse(entries)
+}
+
+// redactedRaw decodes the raw value, with its secrets redacted.
+func redactedRaw(raw *json.RawMessage) (interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var value interface{}
+	if err := jsonutil.DecodeWithNumber(bytes.NewReader(*raw), &value); err != nil {
+		return nil, err
+	}
+	return config.RedactSecrets(value), nil
+}
+
+type confHistoryAction struct {
+	Action string `json:"action"`
+	ID     int    `json:"id"`
+}
+
+func postSnapConfHistory(c *Command, r *http.Request, user *auth.UserState) Response {
+	vars := muxVars(r)
+	snapName := configstate.RemapSnapFromRequest(vars["name"])
+
+	var action confHistoryAction
+	if err := jsonutil.DecodeWithNumber(r.Body, &action); err != nil {
+		return BadRequest("cannot decode request body: %v", err)
+	}
+	if action.Action != "revert" {
+		return BadRequest("unknown configuration history action %q", action.Action)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	ts, err := configstateRevertConfig(st, snapName, action.ID)
+	if err != nil {
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			return SnapNotFound(snapName, err)
+		}
+		if _, ok := err.(*configstate.SchemaError); ok {
+			return BadRequest("%v", err)
+		}
+		return errToResponse(err, []string{snapName}, BadRequest, "%v")
+	}
+
+	summary := fmt.Sprintf("Revert configuration of %q snap to change %d", snapName, action.ID)
+	change := newChange(st, "revert-config", summary, []*state.TaskSet{ts}, []string{snapName})
+	change.Set("requested-by", requestedBy(r, user))
 
 	st.EnsureBefore(0)
 
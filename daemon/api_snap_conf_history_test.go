@@ -0,0 +1,170 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var _ = check.Suite(&snapConfHistorySuite{})
+
+type snapConfHistorySuite struct {
+	apiBaseSuite
+}
+
+func (s *snapConfHistorySuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectAuthenticatedAccess()
+}
+
+func rawJSON(s string) *json.RawMessage {
+	raw := json.RawMessage(s)
+	return &raw
+}
+
+func (s *snapConfHistorySuite) TestGetConfHistory(c *check.C) {
+	d := s.daemon(c)
+
+	t0 := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("config-history", map[string][]*configstate.HistoryEntry{
+		"config-snap": {{
+			ID:       1,
+			Time:     t0,
+			User:     "root",
+			ChangeID: "42",
+			Changes: []*configstate.ConfigChange{
+				{Key: "foo", New: rawJSON(`"bar"`)},
+				{Key: "baz", Old: rawJSON(`1`), New: rawJSON(`2`)},
+			},
+			Config: map[string]*json.RawMessage{"foo": rawJSON(`"bar"`), "baz": rawJSON(`2`)},
+		}},
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/snaps/config-snap/conf-history", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []client.ConfHistoryEntry{{
+		ID:       1,
+		Time:     t0,
+		User:     "root",
+		ChangeID: "42",
+		Changes: []client.ConfChange{
+			{Key: "foo", New: "bar"},
+			{Key: "baz", Old: json.Number("1"), New: json.Number("2")},
+		},
+	}})
+}
+
+func (s *snapConfHistorySuite) TestGetConfHistoryNone(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/snaps/config-snap/conf-history", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []client.ConfHistoryEntry{})
+}
+
+func (s *snapConfHistorySuite) TestRevertConf(c *check.C) {
+	d := s.daemon(c)
+	s.AddCleanup(daemon.MockConfigstateRevertConfig(func(st *state.State, snapName string, id int) (*state.TaskSet, error) {
+		c.Check(snapName, check.Equals, "config-snap")
+		c.Check(id, check.Equals, 3)
+		return state.NewTaskSet(st.NewTask("run-hook", "...")), nil
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/snaps/config-snap/conf-history", bytes.NewBufferString(`{"action":"revert","id":3}`))
+	c.Assert(err, check.IsNil)
+	s.asRootAuth(req)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "revert-config")
+	c.Check(chg.Summary(), check.Equals, `Revert configuration of "config-snap" snap to change 3`)
+	var who string
+	c.Assert(chg.Get("requested-by", &who), check.IsNil)
+	c.Check(who, check.Equals, "root")
+}
+
+func (s *snapConfHistorySuite) TestRevertConfError(c *check.C) {
+	s.daemon(c)
+	s.AddCleanup(daemon.MockConfigstateRevertConfig(func(st *state.State, snapName string, id int) (*state.TaskSet, error) {
+		return nil, errors.New(`cannot find configuration change 3 of snap "config-snap"`)
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/snaps/config-snap/conf-history", bytes.NewBufferString(`{"action":"revert","id":3}`))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot find configuration change 3 of snap "config-snap"`)
+}
+
+func (s *snapConfHistorySuite) TestConfHistoryBadAction(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("POST", "/v2/snaps/config-snap/conf-history", bytes.NewBufferString(`{"action":"frob"}`))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `unknown configuration history action "frob"`)
+}
+
+func (s *snapConfHistorySuite) TestGetConfHistoryRedactsSecrets(c *check.C) {
+	d := s.daemon(c)
+
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("config-history", map[string][]*configstate.HistoryEntry{
+		"config-snap": {{
+			ID:      1,
+			Changes: []*configstate.ConfigChange{{Key: "token", New: rawJSON(`{"$secret":"c2VjcmV0"}`)}},
+		}},
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/snaps/config-snap/conf-history", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	history := rsp.Result.([]client.ConfHistoryEntry)
+	c.Assert(history, check.HasLen, 1)
+	c.Check(history[0].Changes, check.DeepEquals, []client.ConfChange{{Key: "token", New: "*****"}})
+}
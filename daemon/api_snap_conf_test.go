@@ -28,7 +28,9 @@ import (
 
 	"gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -149,6 +151,7 @@ func (s *snapConfSuite) TestSetConf(c *check.C) {
 	buffer := bytes.NewBuffer(text)
 	req, err := http.NewRequest("PUT", "/v2/snaps/config-snap/conf", buffer)
 	c.Assert(err, check.IsNil)
+	s.asRootAuth(req)
 
 	rec := httptest.NewRecorder()
 	s.req(c, req, nil).ServeHTTP(rec, req)
@@ -176,6 +179,16 @@ func (s *snapConfSuite) TestSetConf(c *check.C) {
 	c.Check(hookRunner.Calls(), check.DeepEquals, [][]string{{
 		"snap", "run", "--hook", "configure", "-r", "unset", "config-snap",
 	}})
+
+	// and that the change was recorded in the configuration history
+	st.Lock()
+	defer st.Unlock()
+	history, err := configstate.History(st, "config-snap")
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 1)
+	c.Check(history[0].User, check.Equals, "root")
+	c.Check(history[0].ChangeID, check.Equals, id)
+	c.Check(history[0].Keys(), check.DeepEquals, []string{"key"})
 }
 
 func (s *snapConfSuite) TestSetConfCoreSystemAlias(c *check.C) {
@@ -333,3 +346,104 @@ func (s *snapConfSuite) TestSetConfChangeConflict(c *check.C) {
 		},
 		"type": "error"})
 }
+
+const configSchemaYaml = `
+name: config-snap
+version: 1
+config-schema:
+    port:
+        type: int
+        max: 65535
+        default: 80
+    token:
+        type: string
+        secret: true
+`
+
+func (s *snapConfSuite) TestGetConfSchema(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, configSchemaYaml)
+
+	req, err := http.NewRequest("GET", "/v2/snaps/config-snap/conf?schema=true", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	max := 65535.0
+	c.Check(rsp.Result, check.DeepEquals, snap.ConfigSchema{
+		"port":  {Type: "int", Max: &max, Default: int64(80)},
+		"token": {Type: "string", Secret: true},
+	})
+
+	req, err = http.NewRequest("GET", "/v2/snaps/other-snap/conf?schema=true", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+}
+
+func (s *snapConfSuite) TestSetConfSchemaError(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, configSchemaYaml)
+
+	req, err := http.NewRequest("PUT", "/v2/snaps/config-snap/conf", strings.NewReader(`{"port": 70000}`))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot set configuration of snap "config-snap": invalid value for option "port": must be at most 65535`)
+}
+
+func (s *snapConfSuite) TestGetConfRedactsSecrets(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnap(c, configYaml)
+
+	st := d.Overlord().State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	c.Assert(tr.SetSecret("config-snap", "token", "hunter2"), check.IsNil)
+	c.Assert(tr.Set("config-snap", "name", "frank"), check.IsNil)
+	tr.Commit()
+	st.Unlock()
+
+	result := s.runGetConf(c, "config-snap", []string{"token", "name"}, 200)
+	c.Check(result, check.DeepEquals, map[string]interface{}{
+		"token": "*****",
+		"name":  "frank",
+	})
+}
+
+func (s *snapConfSuite) testSetConfSecret(c *check.C, url, body string) {
+	d := s.daemon(c)
+	s.mockSnap(c, configSchemaYaml)
+
+	req, err := http.NewRequest("PUT", url, strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 202)
+
+	// the secret does not end up in the state in plaintext
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(st.Change(rsp.Change), check.NotNil)
+	data, err := st.MarshalJSON()
+	c.Assert(err, check.IsNil)
+	c.Check(strings.Contains(string(data), "hunter2"), check.Equals, false)
+}
+
+func (s *snapConfSuite) TestSetConfSecretFromSchema(c *check.C) {
+	s.testSetConfSecret(c, "/v2/snaps/config-snap/conf", `{"token": "hunter2"}`)
+}
+
+func (s *snapConfSuite) TestSetConfSecretRequested(c *check.C) {
+	s.testSetConfSecret(c, "/v2/snaps/config-snap/conf?secret=true", `{"password": "hunter2"}`)
+}
+
+func (s *snapConfSuite) TestSetConfSecretNotString(c *check.C) {
+	s.daemon(c)
+	s.mockSnap(c, configSchemaYaml)
+
+	req, err := http.NewRequest("PUT", "/v2/snaps/config-snap/conf?secret=true", strings.NewReader(`{"password": 42}`))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot set secret option "password" of snap "config-snap": value is not a string`)
+}
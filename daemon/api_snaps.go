@@ -195,10 +195,16 @@ type snapInstruction struct {
 	Purge            bool     `json:"purge,omitempty"`
 	Snaps            []string `json:"snaps"`
 	Users            []string `json:"users"`
+	// Passphrase is used to encrypt the snapshots taken by "snapshot"
+	Passphrase string `json:"passphrase,omitempty"`
+	// FromDir is a directory of snaps and assertions to refresh from
+	// instead of the store
+	FromDir string `json:"from-dir,omitempty"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
-	userID int
-	ctx    context.Context
+	userID     int
+	ctx        context.Context
+	localSnaps *localSnapDir
 }
 
 func (inst *snapInstruction) revnoOpts() *snapstate.RevisionOptions {
@@ -240,6 +246,9 @@ func (inst *snapInstruction) validate() error {
 			return fmt.Errorf("leave-cohort can only be specified for refresh or switch")
 		}
 	}
+	if inst.FromDir != "" && inst.Action != "refresh" {
+		return fmt.Errorf("from-dir can only be specified for refresh")
+	}
 	if inst.Action == "install" {
 		for _, snapName := range inst.Snaps {
 			// FIXME: alternatively we could simply mutate *inst
@@ -502,6 +511,16 @@ func snapOpMany(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("%v", err)
 	}
 
+	if inst.FromDir != "" {
+		// the snaps are copied over before locking the state
+		local, err := readLocalSnapDir(inst.FromDir)
+		if err != nil {
+			return BadRequest("%v", err)
+		}
+		defer local.cleanup()
+		inst.localSnaps = local
+	}
+
 	st := c.d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
@@ -581,6 +600,11 @@ func snapInstallMany(inst *snapInstruction, st *state.State) (*snapInstructionRe
 }
 
 func snapUpdateMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
+	if inst.localSnaps != nil {
+		// see api_refresh_from_dir.go
+		return snapUpdateManyFromDir(inst, st)
+	}
+
 	// we need refreshed snap-declarations to enforce refresh-control as best as we can, this also ensures that snap-declarations and their prerequisite assertions are updated regularly
 	if err := assertstateRefreshSnapDeclarations(st, inst.userID); err != nil {
 		return nil, err
@@ -592,12 +616,20 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (*snapInstructionRes
 		return nil, err
 	}
 
+	return &snapInstructionResult{
+		Summary:  updateManySummary(inst.Snaps, updated),
+		Affected: updated,
+		Tasksets: tasksets,
+	}, nil
+}
+
+func updateManySummary(names, updated []string) string {
 	var msg string
 	switch len(updated) {
 	case 0:
-		if len(inst.Snaps) != 0 {
+		if len(names) != 0 {
 			// TRANSLATORS: the %s is a comma-separated list of quoted snap names
-			msg = fmt.Sprintf(i18n.G("Refresh snaps %s: no updates"), strutil.Quoted(inst.Snaps))
+			msg = fmt.Sprintf(i18n.G("Refresh snaps %s: no updates"), strutil.Quoted(names))
 		} else {
 			msg = i18n.G("Refresh all snaps: no updates")
 		}
@@ -608,12 +640,7 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (*snapInstructionRes
 		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
 		msg = fmt.Sprintf(i18n.G("Refresh snaps %s"), quoted)
 	}
-
-	return &snapInstructionResult{
-		Summary:  msg,
-		Affected: updated,
-		Tasksets: tasksets,
-	}, nil
+	return msg
 }
 
 func snapRemoveMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
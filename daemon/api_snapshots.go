@@ -32,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/strutil"
 )
@@ -59,6 +60,10 @@ var (
 	snapshotSave    = snapshotstate.Save
 	snapshotExport  = snapshotstate.Export
 	snapshotImport  = snapshotstate.Import
+	snapshotPush    = snapshotstate.Push
+	snapshotPull    = snapshotstate.Pull
+
+	snapshotSetSecrets = snapshotstate.SetSecrets
 )
 
 func listSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -89,9 +94,19 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+
+	Passphrase string `json:"passphrase,omitempty"`
+	PrivateKey string `json:"private-key,omitempty"`
+
+	// Name is what the snapshot set is stored as on the remote, when
+	// pushing or pulling it
+	Name string `json:"name,omitempty"`
 }
 
 func (action snapshotAction) String() string {
+	if action.Action == "pull" {
+		return fmt.Sprintf("Pull of snapshot %q", action.Name)
+	}
 	// verb of snapshot #N [for snaps %q] [for users %q]
 	var snaps string
 	var users string
@@ -119,6 +134,10 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		return BadRequest("extra content found after snapshot operation")
 	}
 
+	if action.Action == "pull" {
+		return doSnapshotPull(c, &action)
+	}
+
 	if action.SetID == 0 {
 		return BadRequest("snapshot operation requires snapshot set ID")
 	}
@@ -127,6 +146,20 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		return BadRequest("snapshot operation requires action")
 	}
 
+	if action.Name != "" && action.Action != "push" {
+		return BadRequest("snapshot %q operation cannot specify a name", action.Action)
+	}
+	if action.Name != "" {
+		if err := remote.ValidateName(action.Name); err != nil {
+			return BadRequest("%v", err)
+		}
+	}
+
+	hasSecrets := action.Passphrase != "" || action.PrivateKey != ""
+	if hasSecrets && action.Action != "restore" {
+		return BadRequest("snapshot %q operation cannot use a passphrase or private key", action.Action)
+	}
+
 	var affected []string
 	var ts *state.TaskSet
 	var err error
@@ -135,6 +168,14 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 	st.Lock()
 	defer st.Unlock()
 
+	if hasSecrets {
+		// these are only kept in memory while the restore is in progress
+		snapshotSetSecrets(st, action.SetID, &snapshotstate.Secrets{
+			Passphrase: action.Passphrase,
+			PrivateKey: action.PrivateKey,
+		})
+	}
+
 	switch action.Action {
 	case "check":
 		affected, ts, err = snapshotCheck(st, action.SetID, action.Snaps, action.Users)
@@ -145,6 +186,11 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 			return BadRequest(`snapshot "forget" operation cannot specify users`)
 		}
 		affected, ts, err = snapshotForget(st, action.SetID, action.Snaps)
+	case "push":
+		if len(action.Snaps) != 0 || len(action.Users) != 0 {
+			return BadRequest(`snapshot "push" operation cannot specify snaps or users`)
+		}
+		affected, ts, err = snapshotPush(st, action.SetID, action.Name)
 	default:
 		return BadRequest("unknown snapshot operation %q", action.Action)
 	}
@@ -154,6 +200,8 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		// woo
 	case client.ErrSnapshotSetNotFound, client.ErrSnapshotSnapsNotFound:
 		return NotFound("%v", err)
+	case client.ErrSnapshotEncrypted, snapshotstate.ErrNoRemote:
+		return BadRequest("%v", err)
 	default:
 		return InternalError("%v", err)
 	}
@@ -165,6 +213,40 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 	return AsyncResponse(nil, chg.ID())
 }
 
+// doSnapshotPull pulls a snapshot set from the remote, and imports it.
+func doSnapshotPull(c *Command, action *snapshotAction) Response {
+	if action.SetID != 0 || len(action.Snaps) != 0 || len(action.Users) != 0 {
+		return BadRequest(`snapshot "pull" operation cannot specify a set, snaps or users`)
+	}
+	if action.Passphrase != "" || action.PrivateKey != "" {
+		return BadRequest(`snapshot "pull" operation cannot use a passphrase or private key`)
+	}
+	if err := remote.ValidateName(action.Name); err != nil {
+		return BadRequest("%v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	ts, err := snapshotPull(st, action.Name)
+	switch err {
+	case nil:
+		// woo
+	case snapshotstate.ErrNoRemote:
+		return BadRequest("%v", err)
+	default:
+		return InternalError("%v", err)
+	}
+
+	// the set ID and snaps are known once imported, and are then set
+	// as the api-data of the change
+	chg := newChange(st, "pull-snapshot", action.String(), []*state.TaskSet{ts}, nil)
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, chg.ID())
+}
+
 // getSnapshotExport streams an archive containing an export of existing snapshots.
 //
 // The snapshots are re-packaged into a single uncompressed tar archive and
@@ -222,6 +304,10 @@ func snapshotMany(inst *snapInstruction, st *state.State) (*snapInstructionResul
 	if err != nil {
 		return nil, err
 	}
+	if inst.Passphrase != "" {
+		// this is only kept in memory while the snapshots are taken
+		snapshotSetSecrets(st, setID, &snapshotstate.Secrets{Passphrase: inst.Passphrase})
+	}
 
 	var msg string
 	if len(inst.Snaps) == 0 {
@@ -68,6 +68,26 @@ func (s *snapshotSuite) TestSnapshotMany(c *check.C) {
 	c.Check(res.Affected, check.DeepEquals, inst.Snaps)
 }
 
+func (s *snapshotSuite) TestSnapshotManyWithPassphrase(c *check.C) {
+	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string) (uint64, []string, *state.TaskSet, error) {
+		t := s.NewTask("fake-snapshot-2", "Snapshot two")
+		return 7, snaps, state.NewTaskSet(t), nil
+	})()
+	var secrets *snapshotstate.Secrets
+	defer daemon.MockSnapshotSetSecrets(func(_ *state.State, setID uint64, s *snapshotstate.Secrets) {
+		c.Check(setID, check.Equals, uint64(7))
+		secrets = s
+	})()
+
+	inst := daemon.MustUnmarshalSnapInstruction(c, `{"action": "snapshot", "snaps": ["foo"], "passphrase": "sekrit"}`)
+	st := s.d.Overlord().State()
+	st.Lock()
+	_, err := inst.DispatchForMany()(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(secrets, check.DeepEquals, &snapshotstate.Secrets{Passphrase: "sekrit"})
+}
+
 func (s *snapshotSuite) TestListSnapshots(c *check.C) {
 	s.expectOpenAccess()
 
@@ -158,6 +178,12 @@ func (s *snapshotSuite) TestFormatSnapshotAction(c *check.C) {
 		}, {
 			`{"set": 2, "action": "verb", "users": ["meep", "quux"], "snaps": ["foo", "bar"]}`,
 			`Verb of snapshot set #2 for snaps "foo", "bar" for users "meep", "quux"`,
+		}, {
+			`{"set": 2, "action": "push", "name": "backup"}`,
+			`Push of snapshot set #2`,
+		}, {
+			`{"action": "pull", "name": "backup"}`,
+			`Pull of snapshot "backup"`,
 		},
 	}
 
@@ -188,6 +214,27 @@ func (s *snapshotSuite) TestChangeSnapshots400(c *check.C) {
 		}, {
 			body:  `{"set": 42, "action": "forget", "users": ["foo"]}`,
 			error: `snapshot "forget" operation cannot specify users`,
+		}, {
+			body:  `{"set": 42, "action": "check", "passphrase": "sekrit"}`,
+			error: `snapshot "check" operation cannot use a passphrase or private key`,
+		}, {
+			body:  `{"set": 42, "action": "check", "name": "backup"}`,
+			error: `snapshot "check" operation cannot specify a name`,
+		}, {
+			body:  `{"set": 42, "action": "push", "name": "../backup"}`,
+			error: `invalid remote snapshot name "../backup"`,
+		}, {
+			body:  `{"set": 42, "action": "push", "snaps": ["foo"]}`,
+			error: `snapshot "push" operation cannot specify snaps or users`,
+		}, {
+			body:  `{"set": 42, "action": "pull", "name": "backup"}`,
+			error: `snapshot "pull" operation cannot specify a set, snaps or users`,
+		}, {
+			body:  `{"action": "pull", "name": "backup", "passphrase": "sekrit"}`,
+			error: `snapshot "pull" operation cannot use a passphrase or private key`,
+		}, {
+			body:  `{"action": "pull"}`,
+			error: `invalid remote snapshot name ""`,
 		},
 	}
 
@@ -418,3 +465,108 @@ func (s *snapshotSuite) TestImportSnapshotLimits(c *check.C) {
 	c.Check(rsp.Status, check.Equals, 200)
 	c.Check(dataRead, check.Equals, 10)
 }
+
+func (s *snapshotSuite) TestChangeSnapshotRestoreWithSecrets(c *check.C) {
+	var secrets *snapshotstate.Secrets
+	defer daemon.MockSnapshotSetSecrets(func(_ *state.State, setID uint64, s *snapshotstate.Secrets) {
+		c.Check(setID, check.Equals, uint64(42))
+		secrets = s
+	})()
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		// the secrets are set before the restore is set up
+		c.Check(secrets, check.NotNil)
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+
+	body := `{"set": 42, "action": "restore", "passphrase": "sekrit", "private-key": "a key"}`
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 202)
+	c.Check(secrets, check.DeepEquals, &snapshotstate.Secrets{Passphrase: "sekrit", PrivateKey: "a key"})
+}
+
+func (s *snapshotSuite) TestChangeSnapshotRestoreEncrypted(c *check.C) {
+	defer daemon.MockSnapshotRestore(func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		return nil, nil, client.ErrSnapshotEncrypted
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(`{"set": 42, "action": "restore"}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, client.ErrSnapshotEncrypted.Error())
+}
+
+func (s *snapshotSuite) TestChangeSnapshotPush(c *check.C) {
+	defer daemon.MockSnapshotPush(func(st *state.State, setID uint64, name string) ([]string, *state.TaskSet, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		c.Check(name, check.Equals, "backup")
+		return []string{"foo"}, state.NewTaskSet(st.NewTask("push-snapshot", "...")), nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(`{"set": 42, "action": "push", "name": "backup"}`))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 202)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "push-snapshot")
+	c.Check(chg.Summary(), check.Equals, "Push of snapshot set #42")
+	c.Check(chg.Tasks(), check.HasLen, 1)
+}
+
+func (s *snapshotSuite) TestChangeSnapshotPushNoRemote(c *check.C) {
+	defer daemon.MockSnapshotPush(func(*state.State, uint64, string) ([]string, *state.TaskSet, error) {
+		return nil, nil, snapshotstate.ErrNoRemote
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(`{"set": 42, "action": "push"}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, snapshotstate.ErrNoRemote.Error())
+}
+
+func (s *snapshotSuite) TestChangeSnapshotPull(c *check.C) {
+	defer daemon.MockSnapshotPull(func(st *state.State, name string) (*state.TaskSet, error) {
+		c.Check(name, check.Equals, "backup")
+		return state.NewTaskSet(st.NewTask("pull-snapshot", "...")), nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(`{"action": "pull", "name": "backup"}`))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 202)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "pull-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Pull of snapshot "backup"`)
+	c.Check(chg.Tasks(), check.HasLen, 1)
+}
+
+func (s *snapshotSuite) TestChangeSnapshotPullNoRemote(c *check.C) {
+	defer daemon.MockSnapshotPull(func(*state.State, string) (*state.TaskSet, error) {
+		return nil, snapshotstate.ErrNoRemote
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(`{"action": "pull", "name": "backup"}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, snapshotstate.ErrNoRemote.Error())
+}
@@ -30,7 +30,7 @@ type (
 	PostQuotaGroupData = postQuotaGroupData
 )
 
-func MockServicestateCreateQuota(f func(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) error) func() {
+func MockServicestateCreateQuota(f func(st *state.State, name string, parentName string, snaps []string, resourceLimits quota.Resources) error) func() {
 	old := servicestateCreateQuota
 	servicestateCreateQuota = f
 	return func() {
@@ -61,3 +61,11 @@ func MockGetQuotaMemUsage(f func(grp *quota.Group) (quantity.Size, error)) (rest
 		getQuotaMemUsage = old
 	}
 }
+
+func MockGetQuotaTaskUsage(f func(grp *quota.Group) (int, error)) (restore func()) {
+	old := getQuotaTaskUsage
+	getQuotaTaskUsage = f
+	return func() {
+		getQuotaTaskUsage = old
+	}
+}
@@ -39,6 +39,14 @@ func MockSnapshotSave(newSave func(*state.State, []string, []string) (uint64, []
 	}
 }
 
+func MockSnapshotSetSecrets(newSetSecrets func(*state.State, uint64, *snapshotstate.Secrets)) (restore func()) {
+	oldSetSecrets := snapshotSetSecrets
+	snapshotSetSecrets = newSetSecrets
+	return func() {
+		snapshotSetSecrets = oldSetSecrets
+	}
+}
+
 func MockSnapshotList(newList func(context.Context, *state.State, uint64, []string) ([]client.SnapshotSet, error)) (restore func()) {
 	oldList := snapshotList
 	snapshotList = newList
@@ -87,6 +95,22 @@ func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader)
 	}
 }
 
+func MockSnapshotPush(newPush func(*state.State, uint64, string) ([]string, *state.TaskSet, error)) (restore func()) {
+	oldPush := snapshotPush
+	snapshotPush = newPush
+	return func() {
+		snapshotPush = oldPush
+	}
+}
+
+func MockSnapshotPull(newPull func(*state.State, string) (*state.TaskSet, error)) (restore func()) {
+	oldPull := snapshotPull
+	snapshotPull = newPull
+	return func() {
+		snapshotPull = oldPull
+	}
+}
+
 func MustUnmarshalSnapInstruction(c *check.C, jinst string) *snapInstruction {
 	var inst snapInstruction
 	if err := json.Unmarshal([]byte(jinst), &inst); err != nil {
@@ -27,6 +27,8 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/applystate"
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -185,6 +187,14 @@ func MockSnapstateUpdateMany(mock func(context.Context, *state.State, []string,
 	}
 }
 
+func MockSnapstateUpdatePathMany(mock func(context.Context, *state.State, []*snap.SideInfo, []string, []string, int, *snapstate.Flags) ([]string, []*state.TaskSet, error)) (restore func()) {
+	oldSnapstateUpdatePathMany := snapstateUpdatePathMany
+	snapstateUpdatePathMany = mock
+	return func() {
+		snapstateUpdatePathMany = oldSnapstateUpdatePathMany
+	}
+}
+
 func MockSnapstateRemoveMany(mock func(*state.State, []string) ([]string, []*state.TaskSet, error)) (restore func()) {
 	oldSnapstateRemoveMany := snapstateRemoveMany
 	snapstateRemoveMany = mock
@@ -228,3 +238,35 @@ var (
 	MakeErrorResponder = makeErrorResponder
 	ErrToResponse      = errToResponse
 )
+
+func MockApplystateApply(mock func(*state.State, *applystate.Manifest, int) ([]*applystate.Action, []*state.TaskSet, error)) (restore func()) {
+	old := applystateApply
+	applystateApply = mock
+	return func() {
+		applystateApply = old
+	}
+}
+
+func MockConfigstateRevertConfig(mock func(*state.State, string, int) (*state.TaskSet, error)) (restore func()) {
+	old := configstateRevertConfig
+	configstateRevertConfig = mock
+	return func() {
+		configstateRevertConfig = old
+	}
+}
+
+func MockConfigstateExportConfig(mock func(*state.State, []string) (*configstate.ConfigDocument, error)) (restore func()) {
+	old := configstateExportConfig
+	configstateExportConfig = mock
+	return func() {
+		configstateExportConfig = old
+	}
+}
+
+func MockConfigstateImportConfig(mock func(*state.State, *configstate.ConfigDocument) ([]string, []*state.TaskSet, error)) (restore func()) {
+	old := configstateImportConfig
+	configstateImportConfig = mock
+	return func() {
+		configstateImportConfig = old
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -269,6 +270,17 @@ func (f fileResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, string(f))
 }
 
+// A metricsResponse's ServeHTTP method serves the metrics exposition in
+// the OpenMetrics text format
+type metricsResponse []byte
+
+// ServeHTTP from the Response interface
+func (m metricsResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(200)
+	w.Write(m)
+}
+
 // A journalLineReaderSeqResponse's ServeHTTP method reads lines (presumed to
 // be, each one on its own, a JSON dump of a systemd.Log, as output by
 // journalctl -o json) from an io.ReadCloser, loads that into a client.Log, and
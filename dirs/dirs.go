@@ -71,8 +71,9 @@ var (
 	SnapAssertsSpoolDir   string
 	SnapSeqDir            string
 
-	SnapStateFile     string
-	SnapSystemKeyFile string
+	SnapStateFile            string
+	SnapSystemKeyFile        string
+	SnapConfigSecretsKeyFile string
 
 	SnapRepairDir        string
 	SnapRepairStateFile  string
@@ -92,6 +93,7 @@ var (
 	SnapServicesDir     string
 	SnapUserServicesDir string
 	SnapSystemdConfDir  string
+	SnapSystemdDir      string
 	SnapDesktopFilesDir string
 	SnapDesktopIconsDir string
 
@@ -350,6 +352,7 @@ func SetRootDir(rootdir string) {
 
 	SnapStateFile = SnapStateFileUnder(rootdir)
 	SnapSystemKeyFile = filepath.Join(rootdir, snappyDir, "system-key")
+	SnapConfigSecretsKeyFile = filepath.Join(rootdir, snappyDir, "config-secrets.key")
 
 	SnapCacheDir = filepath.Join(rootdir, "/var/cache/snapd")
 	SnapNamesFile = filepath.Join(SnapCacheDir, "names")
@@ -378,6 +381,7 @@ func SetRootDir(rootdir string) {
 	SnapServicesDir = filepath.Join(rootdir, "/etc/systemd/system")
 	SnapUserServicesDir = filepath.Join(rootdir, "/etc/systemd/user")
 	SnapSystemdConfDir = SnapSystemdConfDirUnder(rootdir)
+	SnapSystemdDir = filepath.Join(rootdir, "/etc/systemd")
 
 	SnapDBusSystemPolicyDir = filepath.Join(rootdir, "/etc/dbus-1/system.d")
 	SnapDBusSessionPolicyDir = filepath.Join(rootdir, "/etc/dbus-1/session.d")
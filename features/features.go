@@ -65,6 +65,9 @@ const (
 	// QuotaGroups enable creating resource quota groups for snaps via the rest API and cli.
 	QuotaGroups
 
+	// Metrics enables the OpenMetrics endpoint of the rest API.
+	Metrics
+
 	// lastFeature is the final known feature, it is only used for testing.
 	lastFeature
 )
@@ -103,6 +106,8 @@ var featureNames = map[SnapdFeature]string{
 	GateAutoRefreshHook: "gate-auto-refresh-hook",
 
 	QuotaGroups: "quota-groups",
+
+	Metrics: "metrics",
 }
 
 // featuresEnabledWhenUnset contains a set of features that are enabled when not explicitly configured.
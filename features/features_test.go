@@ -55,6 +55,7 @@ func (*featureSuite) TestName(c *C) {
 	c.Check(features.CheckDiskSpaceRemove.String(), Equals, "check-disk-space-remove")
 	c.Check(features.GateAutoRefreshHook.String(), Equals, "gate-auto-refresh-hook")
 	c.Check(features.QuotaGroups.String(), Equals, "quota-groups")
+	c.Check(features.Metrics.String(), Equals, "metrics")
 	c.Check(func() { _ = features.SnapdFeature(1000).String() }, PanicMatches, "unknown feature flag code 1000")
 }
 
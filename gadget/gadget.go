@@ -99,6 +99,19 @@ type Info struct {
 	Defaults map[string]map[string]interface{} `yaml:"defaults,omitempty"`
 
 	Connections []Connection `yaml:"connections"`
+
+	// KernelCmdline holds the kernel command line arguments that can be
+	// appended through the system.kernel.cmdline-append option.
+	KernelCmdline KernelCmdline `yaml:"kernel-cmdline,omitempty"`
+}
+
+// KernelCmdline describes what the gadget allows to be done to the kernel
+// command line of the device.
+type KernelCmdline struct {
+	// Allow lists the arguments that can be appended to the kernel
+	// command line, either as name, name=value or name=* to allow any
+	// value.
+	Allow []string `yaml:"allow,omitempty"`
 }
 
 // Volume defines the structure and content for the image to be written into a
@@ -319,6 +332,12 @@ func InfoFromGadgetYaml(gadgetYaml []byte, model Model) (*Info, error) {
 		gi.Defaults[k] = dflt.(map[string]interface{})
 	}
 
+	for _, allowed := range gi.KernelCmdline.Allow {
+		if err := validateKernelCmdlineAllow(allowed); err != nil {
+			return nil, err
+		}
+	}
+
 	for i, gconn := range gi.Connections {
 		if gconn.Plug.Empty() {
 			return nil, errors.New("gadget connection plug cannot be empty")
@@ -1020,6 +1039,53 @@ func isKernelArgumentAllowed(arg string) bool {
 
 var ErrNoKernelCommandline = errors.New("no kernel command line in the gadget")
 
+func validateKernelCmdlineAllow(allowed string) error {
+	kargs, err := osutil.KernelCommandLineSplit(allowed)
+	if err != nil || len(kargs) != 1 || kargs[0] != allowed {
+		return fmt.Errorf("invalid allowed kernel command line argument %q", allowed)
+	}
+	split := strings.SplitN(allowed, "=", 2)
+	if !isKernelArgumentAllowed(split[0]) {
+		return fmt.Errorf("disallowed kernel argument %q in kernel-cmdline allow list", allowed)
+	}
+	return nil
+}
+
+// kernelCmdlineArgAllowed returns whether the kernel command line argument
+// is matched by an entry of the allow list.
+func kernelCmdlineArgAllowed(arg string, allow []string) bool {
+	name := strings.SplitN(arg, "=", 2)[0]
+	for _, allowed := range allow {
+		if allowed == arg || allowed == name+"=*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckKernelCommandLineAppend checks that all the arguments of the given
+// kernel command line are allowed to be appended by the gadget, and returns
+// them reassembled as a single string.
+func CheckKernelCommandLineAppend(cmdline string, info *Info) (string, error) {
+	kargs, err := osutil.KernelCommandLineSplit(cmdline)
+	if err != nil {
+		return "", err
+	}
+	for _, argValue := range kargs {
+		if strings.HasPrefix(argValue, "#") {
+			return "", fmt.Errorf("unexpected or invalid use of # in argument %q", argValue)
+		}
+		split := strings.SplitN(argValue, "=", 2)
+		if !isKernelArgumentAllowed(split[0]) {
+			return "", fmt.Errorf("disallowed kernel argument %q", argValue)
+		}
+		if !kernelCmdlineArgAllowed(argValue, info.KernelCmdline.Allow) {
+			return "", fmt.Errorf("kernel argument %q is not allowed by the gadget", argValue)
+		}
+	}
+	return strings.Join(kargs, " "), nil
+}
+
 // KernelCommandLineFromGadget returns the desired kernel command line provided by the
 // gadget. The full flag indicates whether the gadget provides a full command
 // line or just the extra parameters that will be appended to the static ones.
@@ -846,6 +846,41 @@ connections:
 	}
 }
 
+func (s *gadgetYamlTestSuite) TestReadGadgetYamlKernelCmdlineAllow(c *C) {
+	mockGadgetYaml := []byte(`
+kernel-cmdline:
+  allow:
+    - quiet
+    - console=ttyS0
+    - isolcpus=*
+`)
+	err := ioutil.WriteFile(s.gadgetYamlPath, mockGadgetYaml, 0644)
+	c.Assert(err, IsNil)
+
+	ginfo, err := gadget.ReadInfo(s.dir, &modelCharateristics{classic: true})
+	c.Assert(err, IsNil)
+	c.Check(ginfo.KernelCmdline.Allow, DeepEquals, []string{"quiet", "console=ttyS0", "isolcpus=*"})
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetYamlInvalidKernelCmdlineAllow(c *C) {
+	for _, t := range []struct {
+		allowed     string
+		expectedErr string
+	}{
+		{`"foo bar"`, `invalid allowed kernel command line argument "foo bar"`},
+		{`"foo=\"bar"`, `invalid allowed kernel command line argument "foo=\\"bar"`},
+		{`snapd_recovery_mode=*`, `disallowed kernel argument "snapd_recovery_mode=\*" in kernel-cmdline allow list`},
+		{`root=*`, `disallowed kernel argument "root=\*" in kernel-cmdline allow list`},
+	} {
+		mockGadgetYaml := fmt.Sprintf("kernel-cmdline:\n  allow:\n    - %s\n", t.allowed)
+		err := ioutil.WriteFile(s.gadgetYamlPath, []byte(mockGadgetYaml), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = gadget.ReadInfo(s.dir, nil)
+		c.Check(err, ErrorMatches, t.expectedErr, Commentf("%s", t.allowed))
+	}
+}
+
 func (s *gadgetYamlTestSuite) TestReadGadgetYamlVolumeUpdate(c *C) {
 	err := ioutil.WriteFile(s.gadgetYamlPath, mockVolumeUpdateGadgetYaml, 0644)
 	c.Assert(err, IsNil)
@@ -2547,3 +2582,34 @@ func (s *gadgetYamlTestSuite) TestKernelCommandLineArgsExtra(c *C) {
 func (s *gadgetYamlTestSuite) TestKernelCommandLineArgsFull(c *C) {
 	s.testKernelCommandLineArgs(c, "cmdline.full")
 }
+
+func (s *gadgetYamlTestSuite) TestCheckKernelCommandLineAppend(c *C) {
+	info := &gadget.Info{
+		KernelCmdline: gadget.KernelCmdline{
+			Allow: []string{"quiet", "console=ttyS0", "isolcpus=*"},
+		},
+	}
+
+	cmdline, err := gadget.CheckKernelCommandLineAppend("  quiet console=ttyS0 isolcpus=1,2 ", info)
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "quiet console=ttyS0 isolcpus=1,2")
+
+	cmdline, err = gadget.CheckKernelCommandLineAppend("", info)
+	c.Assert(err, IsNil)
+	c.Check(cmdline, Equals, "")
+
+	for _, t := range []struct {
+		cmdline     string
+		expectedErr string
+	}{
+		{"console=ttyS1", `kernel argument "console=ttyS1" is not allowed by the gadget`},
+		{"quiet=1", `kernel argument "quiet=1" is not allowed by the gadget`},
+		{"splash", `kernel argument "splash" is not allowed by the gadget`},
+		{"snapd_recovery_mode=install", `disallowed kernel argument "snapd_recovery_mode=install"`},
+		{"quiet #foo", `unexpected or invalid use of # in argument "#foo"`},
+		{`foo="bar`, `unbalanced quoting`},
+	} {
+		_, err := gadget.CheckKernelCommandLineAppend(t.cmdline, info)
+		c.Check(err, ErrorMatches, t.expectedErr, Commentf("%s", t.cmdline))
+	}
+}
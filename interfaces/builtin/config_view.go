@@ -0,0 +1,121 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/snap"
+)
+
+const configViewSummary = `allows reading configuration published by another snap`
+
+// connecting to a view published by a snap of another publisher needs to
+// be allowed by the snap declarations
+const configViewBaseDeclarationSlots = `
+  config-view:
+    allow-installation:
+      slot-snap-type:
+        - app
+    allow-connection:
+      plug-attributes:
+        view: $SLOT(view)
+    allow-auto-connection:
+      plug-publisher-id:
+        - $SLOT_PUBLISHER_ID
+      plug-attributes:
+        view: $SLOT(view)
+`
+
+// validConfigViewKey matches the configuration option names, as accepted by
+// snap set and snapctl set.
+var validConfigViewKey = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+
+// configViewInterface allows a snap to read the configuration options
+// another snap publishes as a named view with its slot.
+type configViewInterface struct{}
+
+func (iface *configViewInterface) Name() string {
+	return "config-view"
+}
+
+func (iface *configViewInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              configViewSummary,
+		BaseDeclarationSlots: configViewBaseDeclarationSlots,
+	}
+}
+
+func (iface *configViewInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	if slot.Attrs == nil {
+		slot.Attrs = make(map[string]interface{})
+	}
+	// view defaults to the slot name if unspecified
+	if view, ok := slot.Attrs["view"].(string); !ok || view == "" {
+		slot.Attrs["view"] = slot.Name
+	}
+
+	var keys []interface{}
+	if err := slot.Attr("keys", &keys); err != nil || len(keys) == 0 {
+		return fmt.Errorf("config-view slot must list the published configuration keys")
+	}
+	for _, k := range keys {
+		key, ok := k.(string)
+		if !ok {
+			return fmt.Errorf("config-view slot keys must be strings, not %v", k)
+		}
+		for _, subkey := range strings.Split(key, ".") {
+			if !validConfigViewKey.MatchString(subkey) {
+				return fmt.Errorf("invalid config-view key %q", key)
+			}
+		}
+	}
+	return nil
+}
+
+func (iface *configViewInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
+	// view defaults to the plug name if unspecified
+	if view, ok := plug.Attrs["view"].(string); !ok || view == "" {
+		if plug.Attrs == nil {
+			plug.Attrs = make(map[string]interface{})
+		}
+		plug.Attrs["view"] = plug.Name
+	}
+	return nil
+}
+
+func (iface *configViewInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	// views are read with snapctl, which the default policy allows, and
+	// snapd checks the connection itself
+	return nil
+}
+
+func (iface *configViewInterface) AutoConnect(plug *snap.PlugInfo, slot *snap.SlotInfo) bool {
+	// allow what declarations allowed
+	return true
+}
+
+func init() {
+	registerIface(&configViewInterface{})
+}
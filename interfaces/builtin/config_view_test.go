@@ -0,0 +1,126 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type ConfigViewSuite struct {
+	iface interfaces.Interface
+}
+
+var _ = Suite(&ConfigViewSuite{
+	iface: builtin.MustInterface("config-view"),
+})
+
+func (s *ConfigViewSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "config-view")
+}
+
+func (s *ConfigViewSuite) TestSanitizeSlot(c *C) {
+	slot := MockSlot(c, `name: publisher
+version: 0
+slots:
+  network-settings:
+    interface: config-view
+    view: network
+    keys: [proxy, dns.servers]
+`, nil, "network-settings")
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slot), IsNil)
+	c.Check(slot.Attrs["view"], Equals, "network")
+}
+
+func (s *ConfigViewSuite) TestSanitizeSlotViewDefault(c *C) {
+	slot := MockSlot(c, `name: publisher
+version: 0
+slots:
+  network-settings:
+    interface: config-view
+    keys: [proxy]
+`, nil, "network-settings")
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, slot), IsNil)
+	c.Check(slot.Attrs["view"], Equals, "network-settings")
+}
+
+func (s *ConfigViewSuite) TestSanitizeSlotInvalidKeys(c *C) {
+	for _, tc := range []struct {
+		keys string
+		err  string
+	}{
+		{"", `config-view slot must list the published configuration keys`},
+		{"keys: []", `config-view slot must list the published configuration keys`},
+		{"keys: proxy", `config-view slot must list the published configuration keys`},
+		{"keys: [1]", `config-view slot keys must be strings, not 1`},
+		{"keys: [Proxy]", `invalid config-view key "Proxy"`},
+		{"keys: [dns..servers]", `invalid config-view key "dns..servers"`},
+		{"keys: [proxy.]", `invalid config-view key "proxy."`},
+	} {
+		slot := MockSlot(c, `name: publisher
+version: 0
+slots:
+  network-settings:
+    interface: config-view
+    `+tc.keys+`
+`, nil, "network-settings")
+		c.Check(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches, tc.err, Commentf("%s", tc.keys))
+	}
+}
+
+func (s *ConfigViewSuite) TestSanitizePlugViewDefault(c *C) {
+	plug := MockPlug(c, `name: consumer
+version: 0
+plugs:
+  network-settings:
+    interface: config-view
+`, nil, "network-settings")
+	c.Assert(interfaces.BeforePreparePlug(s.iface, plug), IsNil)
+	c.Check(plug.Attrs["view"], Equals, "network-settings")
+
+	plug = MockPlug(c, `name: consumer
+version: 0
+plugs:
+  net:
+    interface: config-view
+    view: network
+`, nil, "net")
+	c.Assert(interfaces.BeforePreparePlug(s.iface, plug), IsNil)
+	c.Check(plug.Attrs["view"], Equals, "network")
+}
+
+func (s *ConfigViewSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, false)
+	c.Assert(si.Summary, Equals, "allows reading configuration published by another snap")
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "config-view")
+}
+
+func (s *ConfigViewSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(nil, nil), Equals, true)
+}
+
+func (s *ConfigViewSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
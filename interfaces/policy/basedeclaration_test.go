@@ -140,6 +140,7 @@ func (s *baseDeclSuite) TestAutoConnection(c *C) {
 	// these have more complex or in flux policies and have their
 	// own separate tests
 	snowflakes := map[string]bool{
+		"config-view":        true,
 		"content":            true,
 		"core-support":       true,
 		"home":               true,
@@ -593,6 +594,7 @@ var (
 		"bluez":                   {"app", "core"},
 		"bool-file":               {"core", "gadget"},
 		"browser-support":         {"core"},
+		"config-view":             {"app"},
 		"content":                 {"app", "gadget"},
 		"core-support":            {"core"},
 		"cups":                    {"app"},
@@ -760,6 +762,7 @@ func (s *baseDeclSuite) TestConnection(c *C) {
 	// connecting with these interfaces needs to be allowed on
 	// case-by-case basis
 	noconnect := map[string]bool{
+		"config-view":      true,
 		"content":          true,
 		"cups":             true,
 		"docker":           true,
@@ -1037,6 +1040,75 @@ plugs:
 	c.Check(err, NotNil)
 }
 
+func (s *baseDeclSuite) TestConnectionConfigView(c *C) {
+	// random snaps cannot connect with config-view
+	cand := s.connectCand(c, "config-view", "", "")
+	c.Check(cand.Check(), NotNil)
+	_, err := cand.CheckAutoConnect()
+	c.Check(err, NotNil)
+
+	slotDecl1 := s.mockSnapDecl(c, "slot-snap", "slotsnapidididididididididididid", "pub1", "")
+	plugDecl1 := s.mockSnapDecl(c, "plug-snap", "plugsnapidididididididididididid", "pub1", "")
+	plugDecl2 := s.mockSnapDecl(c, "plug-snap", "plugsnapidididididididididididid", "pub2", "")
+
+	const slotYaml = `name: slot-snap
+version: 0
+slots:
+  settings:
+    interface: config-view
+    view: network
+    keys: [proxy]
+`
+	// same publisher, same view
+	cand = s.connectCand(c, "settings", slotYaml, `
+name: plug-snap
+version: 0
+plugs:
+  settings:
+    interface: config-view
+    view: network
+`)
+	cand.SlotSnapDeclaration = slotDecl1
+	cand.PlugSnapDeclaration = plugDecl1
+	c.Check(cand.Check(), IsNil)
+	arity, err := cand.CheckAutoConnect()
+	c.Check(err, IsNil)
+	c.Check(arity.SlotsPerPlugAny(), Equals, false)
+
+	// different publisher, same view: can be connected manually only,
+	// unless the snap declarations allow otherwise
+	cand.PlugSnapDeclaration = plugDecl2
+	c.Check(cand.Check(), IsNil)
+	_, err = cand.CheckAutoConnect()
+	c.Check(err, NotNil)
+
+	plugDecl2 = s.mockSnapDecl(c, "plug-snap", "plugsnapidididididididididididid", "pub2", `
+plugs:
+  config-view:
+    allow-auto-connection:
+      slot-snap-id:
+        - slotsnapidididididididididididid
+`)
+	cand.PlugSnapDeclaration = plugDecl2
+	_, err = cand.CheckAutoConnect()
+	c.Check(err, IsNil)
+
+	// same publisher, different view
+	cand = s.connectCand(c, "settings", slotYaml, `
+name: plug-snap
+version: 0
+plugs:
+  settings:
+    interface: config-view
+    view: storage
+`)
+	cand.SlotSnapDeclaration = slotDecl1
+	cand.PlugSnapDeclaration = plugDecl1
+	c.Check(cand.Check(), NotNil)
+	_, err = cand.CheckAutoConnect()
+	c.Check(err, NotNil)
+}
+
 func (s *baseDeclSuite) TestComposeBaseDeclaration(c *C) {
 	decl, err := policy.ComposeBaseDeclaration(nil)
 	c.Assert(err, IsNil)
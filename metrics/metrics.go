@@ -0,0 +1,117 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package metrics implements the collection of snapd internal metrics and
+// their exposition in the OpenMetrics text format.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the buckets of the
+// duration histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+var (
+	// StoreRequestDuration holds the durations of the requests to the store.
+	StoreRequestDuration = NewHistogram(DefaultBuckets)
+	// EnsureDuration holds the durations of the runs of the ensure loop.
+	EnsureDuration = NewHistogram(DefaultBuckets)
+)
+
+// A Histogram counts observed durations into buckets. It is safe for
+// concurrent use.
+type Histogram struct {
+	mu sync.Mutex
+	// bounds are the upper bounds of the buckets, in seconds
+	bounds []float64
+	// counts are the number of observations falling in each bucket
+	// (not cumulative), the last one being for the +Inf bucket
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram returns a histogram with buckets of the given upper bounds,
+// in seconds, plus the implicit +Inf one.
+func NewHistogram(bounds []float64) *Histogram {
+	sorted := make([]float64, len(bounds))
+	copy(sorted, bounds)
+	sort.Float64s(sorted)
+	return &Histogram{
+		bounds: sorted,
+		counts: make([]uint64, len(sorted)+1),
+	}
+}
+
+// Observe records a duration.
+func (h *Histogram) Observe(d time.Duration) {
+	secs := d.Seconds()
+	i := sort.SearchFloat64s(h.bounds, secs)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.count++
+	h.sum += secs
+}
+
+// ObserveSince records the duration elapsed since the given time.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start))
+}
+
+// Bucket is a bucket of a histogram snapshot.
+type Bucket struct {
+	// UpperBound is the upper bound of the bucket, in seconds
+	UpperBound float64
+	// Count is the cumulative number of observations less than or
+	// equal to the upper bound
+	Count uint64
+}
+
+// HistogramSnapshot is the state of a histogram at some point in time.
+type HistogramSnapshot struct {
+	// Buckets are the buckets with a finite upper bound; the +Inf one
+	// is implied by Count
+	Buckets []Bucket
+	Count   uint64
+	Sum     float64
+}
+
+// Snapshot returns the current state of the histogram.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snap := HistogramSnapshot{
+		Buckets: make([]Bucket, len(h.bounds)),
+		Count:   h.count,
+		Sum:     h.sum,
+	}
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		snap.Buckets[i] = Bucket{UpperBound: bound, Count: cumulative}
+	}
+	return snap
+}
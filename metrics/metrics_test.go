@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/metrics"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type metricsSuite struct{}
+
+var _ = Suite(&metricsSuite{})
+
+func (s *metricsSuite) TestHistogram(c *C) {
+	h := metrics.NewHistogram([]float64{1, 0.1, 10})
+
+	h.Observe(50 * time.Millisecond)
+	h.Observe(100 * time.Millisecond)
+	h.Observe(2 * time.Second)
+	h.Observe(time.Minute)
+
+	c.Check(h.Snapshot(), DeepEquals, metrics.HistogramSnapshot{
+		Buckets: []metrics.Bucket{
+			{UpperBound: 0.1, Count: 2},
+			{UpperBound: 1, Count: 2},
+			{UpperBound: 10, Count: 3},
+		},
+		Count: 4,
+		Sum:   62.15,
+	})
+}
+
+func (s *metricsSuite) TestHistogramEmpty(c *C) {
+	h := metrics.NewHistogram(nil)
+	c.Check(h.Snapshot(), DeepEquals, metrics.HistogramSnapshot{
+		Buckets: []metrics.Bucket{},
+	})
+}
+
+func (s *metricsSuite) TestWriter(c *C) {
+	var buf bytes.Buffer
+	w := metrics.NewWriter(&buf)
+
+	w.Counter("snapd_foo", "", "Number of \"foo\" things.", []metrics.Sample{
+		{Labels: []metrics.Label{{"kind", "a"}, {"status", "Done"}}, Value: 3},
+		{Labels: []metrics.Label{{"kind", "b\"\\\n"}}, Value: 0.5},
+	})
+	w.Gauge("snapd_memory_bytes", "bytes", "Memory.", []metrics.Sample{
+		{Value: 1024},
+	})
+	w.Summary("snapd_task_duration_seconds", "seconds", "", []metrics.SummarySample{
+		{Labels: []metrics.Label{{"kind", "link-snap"}}, Count: 2, Sum: 1.5},
+	})
+	h := metrics.NewHistogram([]float64{0.5, 1})
+	h.Observe(time.Second)
+	w.Histogram("snapd_ensure_duration_seconds", "seconds", "Ensure.", h.Snapshot())
+	c.Assert(w.Close(), IsNil)
+
+	c.Check(buf.String(), Equals, `# TYPE snapd_foo counter
+# HELP snapd_foo Number of \"foo\" things.
+snapd_foo_total{kind="a",status="Done"} 3.0
+snapd_foo_total{kind="b\"\\\n"} 0.5
+# TYPE snapd_memory_bytes gauge
+# UNIT snapd_memory_bytes bytes
+# HELP snapd_memory_bytes Memory.
+snapd_memory_bytes 1024.0
+# TYPE snapd_task_duration_seconds summary
+# UNIT snapd_task_duration_seconds seconds
+snapd_task_duration_seconds_count{kind="link-snap"} 2
+snapd_task_duration_seconds_sum{kind="link-snap"} 1.5
+# TYPE snapd_ensure_duration_seconds histogram
+# UNIT snapd_ensure_duration_seconds seconds
+# HELP snapd_ensure_duration_seconds Ensure.
+snapd_ensure_duration_seconds_bucket{le="0.5"} 0
+snapd_ensure_duration_seconds_bucket{le="1.0"} 1
+snapd_ensure_duration_seconds_bucket{le="+Inf"} 1
+snapd_ensure_duration_seconds_count 1
+snapd_ensure_duration_seconds_sum 1.0
+# EOF
+`)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("boom")
+}
+
+func (s *metricsSuite) TestWriterError(c *C) {
+	w := metrics.NewWriter(failingWriter{})
+	w.Gauge("snapd_foo", "", "", []metrics.Sample{{Value: 1}})
+	c.Check(w.Close(), ErrorMatches, "boom")
+}
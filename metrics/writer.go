@@ -0,0 +1,174 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of the OpenMetrics text format.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Label is a label of a metric sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a sample of a counter or gauge metric.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// SummarySample is a sample of a summary metric, without quantiles.
+type SummarySample struct {
+	Labels []Label
+	Count  uint64
+	Sum    float64
+}
+
+// A Writer writes metric families in the OpenMetrics text format. Errors
+// writing to the underlying writer are returned by Close.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// metadata writes the descriptor lines of a metric family. If unit is not
+// empty the name must be suffixed with it, as the format requires.
+func (w *Writer) metadata(name, typ, unit, help string) {
+	w.printf("# TYPE %s %s\n", name, typ)
+	if unit != "" {
+		w.printf("# UNIT %s %s\n", name, unit)
+	}
+	if help != "" {
+		w.printf("# HELP %s %s\n", name, escape(help))
+	}
+}
+
+func (w *Writer) sample(name string, labels []Label, value string) {
+	w.printf("%s%s %s\n", name, formatLabels(labels), value)
+}
+
+// Counter writes a counter metric family.
+func (w *Writer) Counter(name, unit, help string, samples []Sample) {
+	w.metadata(name, "counter", unit, help)
+	for _, s := range samples {
+		w.sample(name+"_total", s.Labels, formatFloat(s.Value))
+	}
+}
+
+// Gauge writes a gauge metric family.
+func (w *Writer) Gauge(name, unit, help string, samples []Sample) {
+	w.metadata(name, "gauge", unit, help)
+	for _, s := range samples {
+		w.sample(name, s.Labels, formatFloat(s.Value))
+	}
+}
+
+// Summary writes a summary metric family.
+func (w *Writer) Summary(name, unit, help string, samples []SummarySample) {
+	w.metadata(name, "summary", unit, help)
+	for _, s := range samples {
+		w.sample(name+"_count", s.Labels, strconv.FormatUint(s.Count, 10))
+		w.sample(name+"_sum", s.Labels, formatFloat(s.Sum))
+	}
+}
+
+// Histogram writes a histogram metric family with a single histogram.
+func (w *Writer) Histogram(name, unit, help string, snap HistogramSnapshot) {
+	w.metadata(name, "histogram", unit, help)
+	for _, b := range snap.Buckets {
+		w.sample(name+"_bucket", []Label{{"le", formatFloat(b.UpperBound)}}, strconv.FormatUint(b.Count, 10))
+	}
+	w.sample(name+"_bucket", []Label{{"le", "+Inf"}}, strconv.FormatUint(snap.Count, 10))
+	w.sample(name+"_count", nil, strconv.FormatUint(snap.Count, 10))
+	w.sample(name+"_sum", nil, formatFloat(snap.Sum))
+}
+
+// Close terminates the exposition and returns the first error
+// encountered writing it, if any.
+func (w *Writer) Close() error {
+	w.printf("# EOF\n")
+	return w.err
+}
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = fmt.Sprintf("%s=\"%s\"", l.Name, escape(l.Value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '"':
+			sb.WriteString(`\"`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// formatFloat formats the number canonically, i.e. integral values
+// with a trailing ".0" as required for bucket bounds.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
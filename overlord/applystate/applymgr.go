@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package applystate
+
+import (
+	"fmt"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	ifacestateConnectFromChange = ifacestate.ConnectFromChange
+	servicestateCreateQuota     = servicestate.CreateQuota
+	servicestateUpdateQuota     = servicestate.UpdateQuota
+)
+
+// ApplyManager runs the tasks of a manifest that cannot be built
+// up-front, as what they do depends on the snaps being installed first.
+type ApplyManager struct{}
+
+// Manager returns a new ApplyManager, registering its task handlers with
+// the given runner.
+func Manager(st *state.State, runner *state.TaskRunner) *ApplyManager {
+	runner.AddHandler("apply-connect", doApplyConnect, nil)
+	runner.AddHandler("apply-quota", doApplyQuota, nil)
+	return &ApplyManager{}
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *ApplyManager) Ensure() error {
+	return nil
+}
+
+func doApplyConnect(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var plugRef interfaces.PlugRef
+	var slotRef interfaces.SlotRef
+	if err := t.Get("plug", &plugRef); err != nil {
+		return err
+	}
+	if err := t.Get("slot", &slotRef); err != nil {
+		return err
+	}
+
+	ts, err := ifacestateConnectFromChange(st, plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name, t.Change().ID())
+	if _, ok := err.(*ifacestate.ErrAlreadyConnected); ok {
+		// e.g. connected automatically on install
+		t.Logf("%s is already connected to %s", plugRef, slotRef)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	snapstate.InjectTasks(t, ts)
+	st.EnsureBefore(0)
+	return nil
+}
+
+func doApplyQuota(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var qa quotaAction
+	if err := t.Get("quota-action", &qa); err != nil {
+		return err
+	}
+
+	switch qa.Action {
+	case "create":
+		return servicestateCreateQuota(st, qa.Name, qa.Parent, qa.Snaps, qa.Resources)
+	case "update":
+		return servicestateUpdateQuota(st, qa.Name, servicestate.QuotaGroupUpdate{
+			AddSnaps:          qa.Snaps,
+			NewResourceLimits: qa.Resources,
+		})
+	default:
+		return fmt.Errorf("internal error: unknown quota action %q", qa.Action)
+	}
+}
+
+func applyConnectAffectedSnaps(t *state.Task) ([]string, error) {
+	var plugRef interfaces.PlugRef
+	var slotRef interfaces.SlotRef
+	if err := t.Get("plug", &plugRef); err != nil {
+		return nil, err
+	}
+	if err := t.Get("slot", &slotRef); err != nil {
+		return nil, err
+	}
+	return []string{plugRef.Snap, slotRef.Snap}, nil
+}
+
+func applyQuotaAffectedSnaps(t *state.Task) ([]string, error) {
+	var qa quotaAction
+	if err := t.Get("quota-action", &qa); err != nil {
+		return nil, err
+	}
+	return qa.Snaps, nil
+}
+
+func init() {
+	snapstate.AddAffectedSnapsByKind("apply-connect", applyConnectAffectedSnaps)
+	snapstate.AddAffectedSnapsByKind("apply-quota", applyQuotaAffectedSnaps)
+}
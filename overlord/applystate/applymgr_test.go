@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package applystate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/applystate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type applyMgrSuite struct {
+	testutil.BaseTest
+	o     *overlord.Overlord
+	state *state.State
+}
+
+var _ = Suite(&applyMgrSuite{})
+
+func (s *applyMgrSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.o = overlord.Mock()
+	s.state = s.o.State()
+	s.o.AddManager(applystate.Manager(s.state, s.o.TaskRunner()))
+	s.o.AddManager(s.o.TaskRunner())
+}
+
+func (s *applyMgrSuite) settle(c *C) {
+	s.state.Unlock()
+	defer s.state.Lock()
+	c.Assert(s.o.Settle(5*time.Second), IsNil)
+}
+
+func (s *applyMgrSuite) TestDoApplyConnect(c *C) {
+	var fromChange string
+	s.AddCleanup(applystate.MockIfacestateConnectFromChange(func(st *state.State, plugSnap, plugName, slotSnap, slotName, chgID string) (*state.TaskSet, error) {
+		c.Check(plugSnap, Equals, "foo")
+		c.Check(plugName, Equals, "home")
+		c.Check(slotSnap, Equals, "core")
+		c.Check(slotName, Equals, "home")
+		fromChange = chgID
+		return state.NewTaskSet(st.NewTask("fake-connect", "...")), nil
+	}))
+	s.o.TaskRunner().AddHandler("fake-connect", func(*state.Task, *tomb.Tomb) error { return nil }, nil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("apply", "...")
+	t := s.state.NewTask("apply-connect", "...")
+	t.Set("plug", interfaces.PlugRef{Snap: "foo", Name: "home"})
+	t.Set("slot", interfaces.SlotRef{Snap: "core", Name: "home"})
+	chg.AddTask(t)
+
+	s.settle(c)
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(fromChange, Equals, chg.ID())
+	c.Assert(chg.Tasks(), HasLen, 2)
+	c.Check(chg.Tasks()[1].Kind(), Equals, "fake-connect")
+	c.Check(chg.Tasks()[1].WaitTasks(), DeepEquals, []*state.Task{t})
+}
+
+func (s *applyMgrSuite) TestDoApplyConnectAlreadyConnected(c *C) {
+	s.AddCleanup(applystate.MockIfacestateConnectFromChange(func(st *state.State, plugSnap, plugName, slotSnap, slotName, chgID string) (*state.TaskSet, error) {
+		return nil, &ifacestate.ErrAlreadyConnected{}
+	}))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("apply", "...")
+	t := s.state.NewTask("apply-connect", "...")
+	t.Set("plug", interfaces.PlugRef{Snap: "foo", Name: "home"})
+	t.Set("slot", interfaces.SlotRef{Snap: "core", Name: "home"})
+	chg.AddTask(t)
+
+	s.settle(c)
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(chg.Tasks(), HasLen, 1)
+	c.Assert(t.Log(), HasLen, 1)
+	c.Check(t.Log()[0], Matches, `.* foo:home is already connected to core:home`)
+}
+
+func (s *applyMgrSuite) TestDoApplyQuota(c *C) {
+	var created, updated []string
+	s.AddCleanup(applystate.MockServicestateCreateQuota(func(st *state.State, name, parent string, snaps []string, resources quota.Resources) error {
+		c.Check(parent, Equals, "parent")
+		c.Check(snaps, DeepEquals, []string{"foo"})
+		c.Check(resources, DeepEquals, quota.Resources{Memory: quantity.SizeMiB})
+		created = append(created, name)
+		return nil
+	}))
+	s.AddCleanup(applystate.MockServicestateUpdateQuota(func(st *state.State, name string, update servicestate.QuotaGroupUpdate) error {
+		c.Check(update, DeepEquals, servicestate.QuotaGroupUpdate{
+			AddSnaps:          []string{"bar"},
+			NewResourceLimits: quota.Resources{Threads: 32},
+		})
+		updated = append(updated, name)
+		return nil
+	}))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("apply", "...")
+	t1 := s.state.NewTask("apply-quota", "...")
+	t1.Set("quota-action", &applystate.QuotaAction{
+		Action:    "create",
+		Name:      "new",
+		Parent:    "parent",
+		Snaps:     []string{"foo"},
+		Resources: quota.Resources{Memory: quantity.SizeMiB},
+	})
+	chg.AddTask(t1)
+	t2 := s.state.NewTask("apply-quota", "...")
+	t2.Set("quota-action", &applystate.QuotaAction{
+		Action:    "update",
+		Name:      "existing",
+		Snaps:     []string{"bar"},
+		Resources: quota.Resources{Threads: 32},
+	})
+	t2.WaitFor(t1)
+	chg.AddTask(t2)
+
+	s.settle(c)
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(created, DeepEquals, []string{"new"})
+	c.Check(updated, DeepEquals, []string{"existing"})
+}
@@ -0,0 +1,377 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package applystate implements bringing the system to the state described
+// by a manifest, with a single change.
+package applystate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// The kinds of the actions of a plan.
+const (
+	ActionInstall     = "install"
+	ActionRefresh     = "refresh"
+	ActionConfigure   = "configure"
+	ActionConnect     = "connect"
+	ActionCreateQuota = "create-quota"
+	ActionUpdateQuota = "update-quota"
+)
+
+// Action is a step in bringing the system to the state described by a
+// manifest.
+type Action struct {
+	Kind string `json:"kind"`
+	// Target is the snap, connection or quota group acted upon
+	Target  string `json:"target"`
+	Summary string `json:"summary"`
+}
+
+var (
+	snapstateInstall = snapstate.Install
+	snapstateUpdate  = snapstate.Update
+)
+
+// Plan returns the actions needed to bring the system to the state
+// described by the manifest, in the order they would be performed.
+func Plan(st *state.State, m *Manifest) ([]*Action, error) {
+	p := &planner{st: st}
+	if err := p.plan(m); err != nil {
+		return nil, err
+	}
+	return p.actions, nil
+}
+
+// Apply returns the actions needed to bring the system to the state
+// described by the manifest, and the task sets performing them. Snaps are
+// installed or refreshed first, then configured, connected, and finally
+// put in their quota groups.
+func Apply(st *state.State, m *Manifest, userID int) ([]*Action, []*state.TaskSet, error) {
+	p := &planner{st: st, userID: userID, build: true}
+	if err := p.plan(m); err != nil {
+		return nil, nil, err
+	}
+	return p.actions, append(p.snapTss, p.tss...), nil
+}
+
+type planner struct {
+	st     *state.State
+	userID int
+	// build is whether to build the task sets of the actions
+	build bool
+
+	actions []*Action
+	// snapTss are the task sets installing, refreshing and configuring
+	// snaps, which the other ones wait for
+	snapTss []*state.TaskSet
+	tss     []*state.TaskSet
+	// available holds the snaps that are or will be installed
+	available map[string]bool
+}
+
+func (p *planner) add(kind, target, summary string) {
+	p.actions = append(p.actions, &Action{Kind: kind, Target: target, Summary: summary})
+}
+
+func (p *planner) plan(m *Manifest) error {
+	p.available = make(map[string]bool)
+	for _, sn := range m.Snaps {
+		if err := p.planSnap(sn); err != nil {
+			return err
+		}
+	}
+	if err := p.planConnections(m.Connections); err != nil {
+		return err
+	}
+	return p.planQuotaGroups(m.QuotaGroups)
+}
+
+func revisionSummary(sn *SnapSpec) string {
+	switch {
+	case !sn.Revision.Unset():
+		return fmt.Sprintf(" at revision %s", sn.Revision)
+	case sn.Channel != "":
+		return fmt.Sprintf(" from channel %q", sn.Channel)
+	}
+	return ""
+}
+
+// needsRefresh returns whether the installed snap is not at the revision,
+// or tracking the channel, it should be.
+func needsRefresh(snapst *snapstate.SnapState, sn *SnapSpec) (bool, error) {
+	if !sn.Revision.Unset() {
+		return sn.Revision != snapst.Current, nil
+	}
+	if sn.Channel != "" {
+		resolved, err := channel.Resolve(snapst.TrackingChannel, sn.Channel)
+		if err != nil {
+			return false, err
+		}
+		return resolved != snapst.TrackingChannel, nil
+	}
+	return false, nil
+}
+
+func (p *planner) planSnap(sn *SnapSpec) error {
+	snapName := configstate.RemapSnapFromRequest(sn.Name)
+
+	var snapTs *state.TaskSet
+	if sn.Name != "system" {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(p.st, sn.Name, &snapst); err != nil && err != state.ErrNoState {
+			return err
+		}
+		opts := &snapstate.RevisionOptions{Channel: sn.Channel, Revision: sn.Revision}
+		flags := snapstate.Flags{Classic: sn.Classic}
+		if !snapst.IsInstalled() {
+			p.add(ActionInstall, sn.Name, fmt.Sprintf("Install %q snap%s", sn.Name, revisionSummary(sn)))
+			if p.build {
+				ts, err := snapstateInstall(context.TODO(), p.st, sn.Name, opts, p.userID, flags)
+				if err != nil {
+					return err
+				}
+				snapTs = ts
+			}
+		} else {
+			refresh, err := needsRefresh(&snapst, sn)
+			if err != nil {
+				return fmt.Errorf("cannot refresh snap %q: %v", sn.Name, err)
+			}
+			if refresh {
+				p.add(ActionRefresh, sn.Name, fmt.Sprintf("Refresh %q snap%s", sn.Name, revisionSummary(sn)))
+				if p.build {
+					ts, err := snapstateUpdate(p.st, sn.Name, opts, p.userID, flags)
+					if err != nil {
+						return err
+					}
+					snapTs = ts
+				}
+			}
+		}
+		p.available[sn.Name] = true
+		if snapTs != nil {
+			p.snapTss = append(p.snapTss, snapTs)
+		}
+	}
+
+	patch, err := p.configPatch(snapName, sn.Config)
+	if err != nil {
+		return err
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	p.add(ActionConfigure, sn.Name, fmt.Sprintf("Set %s of %q snap", strings.Join(keys, ", "), sn.Name))
+	if p.build {
+		ts := configstate.Configure(p.st, snapName, patch, 0)
+		if snapTs != nil {
+			ts.WaitAll(snapTs)
+		}
+		p.snapTss = append(p.snapTss, ts)
+	}
+	return nil
+}
+
+func sameValue(a, b interface{}) bool {
+	// values read from the configuration and from the manifest are
+	// not of the same types (e.g. json.Number vs int64), their JSON is
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ja, jb)
+}
+
+// configPatch returns the configuration values that differ from the
+// current ones.
+func (p *planner) configPatch(snapName string, conf map[string]interface{}) (map[string]interface{}, error) {
+	tr := config.NewTransaction(p.st)
+	patch := make(map[string]interface{})
+	for key, value := range conf {
+		var current interface{}
+		err := tr.Get(snapName, key, &current)
+		if err != nil && !config.IsNoOption(err) {
+			return nil, err
+		}
+		if err == nil && sameValue(current, value) {
+			continue
+		}
+		patch[key] = value
+	}
+	return patch, nil
+}
+
+// checkAvailable checks that the snap is or will be installed.
+func (p *planner) checkAvailable(snapName string) error {
+	if p.available[snapName] {
+		return nil
+	}
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(p.st, snapName, &snapst); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !snapst.IsInstalled() {
+		return fmt.Errorf("snap %q is neither installed nor listed in the manifest", snapName)
+	}
+	p.available[snapName] = true
+	return nil
+}
+
+// addTask adds a task waiting for the snap task sets, and for the
+// given previous task if any.
+func (p *planner) addTask(t, prev *state.Task) {
+	for _, ts := range p.snapTss {
+		t.WaitAll(ts)
+	}
+	if prev != nil {
+		t.WaitFor(prev)
+	}
+	p.tss = append(p.tss, state.NewTaskSet(t))
+}
+
+func (p *planner) planConnections(conns []*ConnectionSpec) error {
+	if len(conns) == 0 {
+		return nil
+	}
+	connStates, err := ifacestate.ConnectionStates(p.st)
+	if err != nil {
+		return err
+	}
+	// the connections are made one after the other, as making them
+	// conflicts with making other ones for the same snaps
+	var prev *state.Task
+	for _, conn := range conns {
+		ref := &interfaces.ConnRef{PlugRef: conn.plugRef, SlotRef: conn.slotRef}
+		if cs, ok := connStates[ref.ID()]; ok && !cs.Undesired && !cs.HotplugGone {
+			continue
+		}
+		for _, snapName := range []string{conn.plugRef.Snap, conn.slotRef.Snap} {
+			if err := p.checkAvailable(snapName); err != nil {
+				return fmt.Errorf("cannot connect %s to %s: %v", conn.plugRef, conn.slotRef, err)
+			}
+		}
+		summary := fmt.Sprintf("Connect %s to %s", conn.plugRef, conn.slotRef)
+		p.add(ActionConnect, ref.ID(), summary)
+		if p.build {
+			t := p.st.NewTask("apply-connect", summary)
+			t.Set("plug", conn.plugRef)
+			t.Set("slot", conn.slotRef)
+			p.addTask(t, prev)
+			prev = t
+		}
+	}
+	return nil
+}
+
+// quotaAction is what an apply-quota task does.
+type quotaAction struct {
+	Action    string          `json:"action"`
+	Name      string          `json:"name"`
+	Parent    string          `json:"parent,omitempty"`
+	Snaps     []string        `json:"snaps,omitempty"`
+	Resources quota.Resources `json:"resources"`
+}
+
+func (p *planner) planQuotaGroups(grps []*QuotaGroupSpec) error {
+	if len(grps) == 0 {
+		return nil
+	}
+	quotas, err := servicestate.AllQuotas(p.st)
+	if err != nil {
+		return err
+	}
+	// the groups are created or updated in order, for parents to
+	// exist before their sub-groups
+	var prev *state.Task
+	for _, spec := range grps {
+		for _, snapName := range spec.Snaps {
+			if err := p.checkAvailable(snapName); err != nil {
+				return fmt.Errorf("cannot put snap in quota group %q: %v", spec.Name, err)
+			}
+		}
+		var kind, summary string
+		var qa *quotaAction
+		if grp := quotas[spec.Name]; grp == nil {
+			resources := quota.Resources{Memory: spec.maxMemory, Threads: spec.MaxThreads}
+			if err := resources.Validate(); err != nil {
+				return fmt.Errorf("cannot create quota group %q: %v", spec.Name, err)
+			}
+			kind = ActionCreateQuota
+			summary = fmt.Sprintf("Create quota group %q", spec.Name)
+			qa = &quotaAction{Action: "create", Name: spec.Name, Parent: spec.Parent, Snaps: spec.Snaps, Resources: resources}
+		} else {
+			if spec.Parent != grp.ParentGroup {
+				return fmt.Errorf("cannot change the parent of quota group %q", spec.Name)
+			}
+			var resources quota.Resources
+			if spec.maxMemory != 0 && spec.maxMemory != grp.MemoryLimit {
+				resources.Memory = spec.maxMemory
+			}
+			if spec.MaxThreads != 0 && spec.MaxThreads != grp.ThreadLimit {
+				resources.Threads = spec.MaxThreads
+			}
+			var addSnaps []string
+			for _, snapName := range spec.Snaps {
+				if !strutil.ListContains(grp.Snaps, snapName) {
+					addSnaps = append(addSnaps, snapName)
+				}
+			}
+			if resources == (quota.Resources{}) && len(addSnaps) == 0 {
+				continue
+			}
+			kind = ActionUpdateQuota
+			summary = fmt.Sprintf("Update quota group %q", spec.Name)
+			qa = &quotaAction{Action: "update", Name: spec.Name, Snaps: addSnaps, Resources: resources}
+		}
+		p.add(kind, spec.Name, summary)
+		if p.build {
+			t := p.st.NewTask("apply-quota", summary)
+			t.Set("quota-action", qa)
+			p.addTask(t, prev)
+			prev = t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package applystate_test
+
+import (
+	"context"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/overlord/applystate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type applySuite struct {
+	testutil.BaseTest
+	state *state.State
+
+	installs []string
+	updates  []string
+}
+
+var _ = Suite(&applySuite{})
+
+func (s *applySuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.state = state.New(nil)
+	s.installs = nil
+	s.updates = nil
+
+	s.AddCleanup(applystate.MockSnapstateInstall(func(ctx context.Context, st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		s.installs = append(s.installs, name)
+		return state.NewTaskSet(st.NewTask("fake-install", name)), nil
+	}))
+	s.AddCleanup(applystate.MockSnapstateUpdate(func(st *state.State, name string, opts *snapstate.RevisionOptions, userID int, flags snapstate.Flags) (*state.TaskSet, error) {
+		s.updates = append(s.updates, name)
+		return state.NewTaskSet(st.NewTask("fake-refresh", name)), nil
+	}))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "installed", &snapstate.SnapState{
+		Sequence:        []*snap.SideInfo{{RealName: "installed", Revision: snap.R(1)}},
+		Current:         snap.R(1),
+		Active:          true,
+		TrackingChannel: "latest/stable",
+	})
+}
+
+func (s *applySuite) parse(c *C, manifest string) *applystate.Manifest {
+	m, err := applystate.ParseManifest([]byte(manifest))
+	c.Assert(err, IsNil)
+	return m
+}
+
+func (s *applySuite) TestPlanSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("installed", "same", "value"), IsNil)
+	c.Assert(tr.Set("installed", "other", "old"), IsNil)
+	tr.Commit()
+
+	m := s.parse(c, `
+snaps:
+  - name: new
+    channel: edge
+  - name: installed
+    channel: stable
+    config:
+      same: value
+      other: new
+  - name: system
+    config:
+      service.ssh.disable: true
+`)
+	actions, err := applystate.Plan(s.state, m)
+	c.Assert(err, IsNil)
+	c.Check(actions, DeepEquals, []*applystate.Action{
+		{Kind: applystate.ActionInstall, Target: "new", Summary: `Install "new" snap from channel "edge"`},
+		{Kind: applystate.ActionConfigure, Target: "installed", Summary: `Set other of "installed" snap`},
+		{Kind: applystate.ActionConfigure, Target: "system", Summary: `Set service.ssh.disable of "system" snap`},
+	})
+	// planning does not build anything
+	c.Check(s.installs, HasLen, 0)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *applySuite) TestPlanRefresh(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, t := range []struct {
+		manifest string
+		summary  string
+	}{
+		{`snaps: [{name: installed, channel: edge}]`, `Refresh "installed" snap from channel "edge"`},
+		{`snaps: [{name: installed, channel: "2.0"}]`, `Refresh "installed" snap from channel "2.0"`},
+		{`snaps: [{name: installed, revision: 2}]`, `Refresh "installed" snap at revision 2`},
+	} {
+		actions, err := applystate.Plan(s.state, s.parse(c, t.manifest))
+		c.Assert(err, IsNil)
+		c.Check(actions, DeepEquals, []*applystate.Action{
+			{Kind: applystate.ActionRefresh, Target: "installed", Summary: t.summary},
+		}, Commentf(t.manifest))
+	}
+
+	for _, manifest := range []string{
+		`snaps: [{name: installed}]`,
+		`snaps: [{name: installed, channel: stable}]`,
+		`snaps: [{name: installed, channel: latest/stable}]`,
+		`snaps: [{name: installed, revision: 1}]`,
+	} {
+		actions, err := applystate.Plan(s.state, s.parse(c, manifest))
+		c.Assert(err, IsNil)
+		c.Check(actions, HasLen, 0, Commentf(manifest))
+	}
+}
+
+func (s *applySuite) TestPlanConnections(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("conns", map[string]interface{}{
+		"installed:home core:home": map[string]interface{}{"interface": "home"},
+		"installed:x11 core:x11":   map[string]interface{}{"interface": "x11", "undesired": true},
+	})
+	snapstate.Set(s.state, "core", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{{RealName: "core", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "os",
+	})
+
+	m := s.parse(c, `
+snaps:
+  - name: new
+connections:
+  - plug: installed:home
+    slot: core:home
+  - plug: installed:x11
+    slot: core:x11
+  - plug: new:network
+    slot: core:network
+`)
+	actions, err := applystate.Plan(s.state, m)
+	c.Assert(err, IsNil)
+	c.Check(actions, DeepEquals, []*applystate.Action{
+		{Kind: applystate.ActionInstall, Target: "new", Summary: `Install "new" snap`},
+		{Kind: applystate.ActionConnect, Target: "installed:x11 core:x11", Summary: "Connect installed:x11 to core:x11"},
+		{Kind: applystate.ActionConnect, Target: "new:network core:network", Summary: "Connect new:network to core:network"},
+	})
+
+	_, err = applystate.Plan(s.state, s.parse(c, `connections: [{plug: "missing:home", slot: "core:home"}]`))
+	c.Check(err, ErrorMatches, `cannot connect missing:home to core:home: snap "missing" is neither installed nor listed in the manifest`)
+}
+
+func (s *applySuite) TestPlanQuotaGroups(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("quotas", map[string]*quota.Group{
+		"existing": {Name: "existing", MemoryLimit: quantity.Size(1000 * 1000), Snaps: []string{"installed"}},
+	})
+
+	m := s.parse(c, `
+quota-groups:
+  - name: existing
+    max-memory: 1MB
+    snaps: [installed]
+  - name: group
+    max-memory: 2MB
+    snaps: [installed]
+`)
+	actions, err := applystate.Plan(s.state, m)
+	c.Assert(err, IsNil)
+	c.Check(actions, DeepEquals, []*applystate.Action{
+		{Kind: applystate.ActionCreateQuota, Target: "group", Summary: `Create quota group "group"`},
+	})
+
+	m = s.parse(c, `quota-groups: [{name: existing, max-memory: 2MB}]`)
+	actions, err = applystate.Plan(s.state, m)
+	c.Assert(err, IsNil)
+	c.Check(actions, DeepEquals, []*applystate.Action{
+		{Kind: applystate.ActionUpdateQuota, Target: "existing", Summary: `Update quota group "existing"`},
+	})
+
+	_, err = applystate.Plan(s.state, s.parse(c, `quota-groups: [{name: existing, parent: other}]`))
+	c.Check(err, ErrorMatches, `cannot change the parent of quota group "existing"`)
+
+	_, err = applystate.Plan(s.state, s.parse(c, `quota-groups: [{name: group}]`))
+	c.Check(err, ErrorMatches, `cannot create quota group "group": .*`)
+}
+
+func (s *applySuite) TestApply(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	m := s.parse(c, `
+snaps:
+  - name: new
+    config:
+      key: value
+  - name: installed
+    channel: edge
+connections:
+  - plug: new:home
+    slot: installed:home
+  - plug: new:x11
+    slot: installed:x11
+quota-groups:
+  - name: group
+    max-memory: 2MB
+    snaps: [new, installed]
+`)
+	actions, tss, err := applystate.Apply(s.state, m, 0)
+	c.Assert(err, IsNil)
+	c.Check(actions, HasLen, 6)
+	c.Check(s.installs, DeepEquals, []string{"new"})
+	c.Check(s.updates, DeepEquals, []string{"installed"})
+	c.Assert(tss, HasLen, 6)
+
+	install := tss[0].Tasks()[0]
+	c.Check(install.Kind(), Equals, "fake-install")
+	configure := tss[1].Tasks()[0]
+	c.Check(configure.Kind(), Equals, "run-hook")
+	c.Check(configure.WaitTasks(), DeepEquals, []*state.Task{install})
+	refresh := tss[2].Tasks()[0]
+	c.Check(refresh.Kind(), Equals, "fake-refresh")
+
+	connect1 := tss[3].Tasks()[0]
+	c.Check(connect1.Kind(), Equals, "apply-connect")
+	c.Check(connect1.WaitTasks(), DeepEquals, []*state.Task{install, configure, refresh})
+	connect2 := tss[4].Tasks()[0]
+	c.Check(connect2.WaitTasks(), DeepEquals, []*state.Task{install, configure, refresh, connect1})
+
+	quotaTask := tss[5].Tasks()[0]
+	c.Check(quotaTask.Kind(), Equals, "apply-quota")
+	c.Check(quotaTask.WaitTasks(), DeepEquals, []*state.Task{install, configure, refresh})
+	var qa applystate.QuotaAction
+	c.Assert(quotaTask.Get("quota-action", &qa), IsNil)
+	c.Check(qa, DeepEquals, applystate.QuotaAction{
+		Action:    "create",
+		Name:      "group",
+		Snaps:     []string{"new", "installed"},
+		Resources: quota.Resources{Memory: quantity.Size(2 * 1000 * 1000)},
+	})
+}
+
+func (s *applySuite) TestApplyNothingToDo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	actions, tss, err := applystate.Apply(s.state, s.parse(c, `snaps: [{name: installed}]`), 0)
+	c.Assert(err, IsNil)
+	c.Check(actions, HasLen, 0)
+	c.Check(tss, HasLen, 0)
+}
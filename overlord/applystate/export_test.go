@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package applystate
+
+import (
+	"context"
+
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+type QuotaAction = quotaAction
+
+func MockSnapstateInstall(f func(context.Context, *state.State, string, *snapstate.RevisionOptions, int, snapstate.Flags) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateInstall
+	snapstateInstall = f
+	return func() {
+		snapstateInstall = old
+	}
+}
+
+func MockSnapstateUpdate(f func(*state.State, string, *snapstate.RevisionOptions, int, snapstate.Flags) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateUpdate
+	snapstateUpdate = f
+	return func() {
+		snapstateUpdate = old
+	}
+}
+
+func MockIfacestateConnectFromChange(f func(*state.State, string, string, string, string, string) (*state.TaskSet, error)) (restore func()) {
+	old := ifacestateConnectFromChange
+	ifacestateConnectFromChange = f
+	return func() {
+		ifacestateConnectFromChange = old
+	}
+}
+
+func MockServicestateCreateQuota(f func(*state.State, string, string, []string, quota.Resources) error) (restore func()) {
+	old := servicestateCreateQuota
+	servicestateCreateQuota = f
+	return func() {
+		servicestateCreateQuota = old
+	}
+}
+
+func MockServicestateUpdateQuota(f func(*state.State, string, servicestate.QuotaGroupUpdate) error) (restore func()) {
+	old := servicestateUpdateQuota
+	servicestateUpdateQuota = f
+	return func() {
+		servicestateUpdateQuota = old
+	}
+}
@@ -0,0 +1,210 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package applystate
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/metautil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// Manifest describes the desired state of the system: the snaps that
+// should be installed and their configuration, the connections that should
+// be made and the quota groups that should exist. Whatever the manifest
+// does not mention is left alone.
+type Manifest struct {
+	Snaps       []*SnapSpec       `yaml:"snaps,omitempty"`
+	Connections []*ConnectionSpec `yaml:"connections,omitempty"`
+	QuotaGroups []*QuotaGroupSpec `yaml:"quota-groups,omitempty"`
+}
+
+// SnapSpec describes a snap of a manifest. The "system" snap can only
+// be given configuration.
+type SnapSpec struct {
+	Name string `yaml:"name"`
+	// Channel is the channel the snap should track
+	Channel string `yaml:"channel,omitempty"`
+	// Revision is the revision the snap should be at, if it matters
+	Revision snap.Revision `yaml:"revision,omitempty"`
+	Classic  bool          `yaml:"classic,omitempty"`
+	// Config holds the values the given options should have
+	Config map[string]interface{} `yaml:"config,omitempty"`
+}
+
+// ConnectionSpec describes a connection of a manifest, with its plug and
+// slot given as <snap>:<name>.
+type ConnectionSpec struct {
+	Plug string `yaml:"plug"`
+	Slot string `yaml:"slot"`
+
+	plugRef interfaces.PlugRef
+	slotRef interfaces.SlotRef
+}
+
+// QuotaGroupSpec describes a quota group of a manifest. Groups with a
+// parent must come after it.
+type QuotaGroupSpec struct {
+	Name       string   `yaml:"name"`
+	Parent     string   `yaml:"parent,omitempty"`
+	MaxMemory  string   `yaml:"max-memory,omitempty"`
+	MaxThreads int      `yaml:"max-threads,omitempty"`
+	Snaps      []string `yaml:"snaps,omitempty"`
+
+	maxMemory quantity.Size
+}
+
+// ParseManifest parses and validates a manifest in YAML, or JSON.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse manifest: %v", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	return &m, nil
+}
+
+func (m *Manifest) validate() error {
+	seen := make(map[string]bool)
+	for _, sn := range m.Snaps {
+		if sn == nil {
+			return fmt.Errorf("empty snap entry")
+		}
+		if err := sn.validate(); err != nil {
+			return err
+		}
+		if seen[sn.Name] {
+			return fmt.Errorf("snap %q listed more than once", sn.Name)
+		}
+		seen[sn.Name] = true
+	}
+	for _, conn := range m.Connections {
+		if conn == nil {
+			return fmt.Errorf("empty connection entry")
+		}
+		if err := conn.validate(); err != nil {
+			return err
+		}
+	}
+	seen = make(map[string]bool)
+	for _, grp := range m.QuotaGroups {
+		if grp == nil {
+			return fmt.Errorf("empty quota group entry")
+		}
+		if err := grp.validate(); err != nil {
+			return err
+		}
+		if seen[grp.Name] {
+			return fmt.Errorf("quota group %q listed more than once", grp.Name)
+		}
+		seen[grp.Name] = true
+	}
+	return nil
+}
+
+func (sn *SnapSpec) validate() error {
+	if sn.Name == "system" {
+		if sn.Channel != "" || !sn.Revision.Unset() || sn.Classic {
+			return fmt.Errorf(`only configuration can be given for the "system" snap`)
+		}
+	} else if err := naming.ValidateInstance(sn.Name); err != nil {
+		return err
+	}
+	if sn.Channel != "" {
+		if _, err := channel.Parse(sn.Channel, ""); err != nil {
+			return fmt.Errorf("invalid channel for snap %q: %v", sn.Name, err)
+		}
+	}
+	for key, value := range sn.Config {
+		// yaml gives map[interface{}]interface{} for nested maps,
+		// which cannot be stored as configuration as is
+		normalized, err := metautil.NormalizeValue(value)
+		if err != nil {
+			return fmt.Errorf("invalid configuration value of %q for snap %q: %v", key, sn.Name, err)
+		}
+		sn.Config[key] = normalized
+	}
+	return nil
+}
+
+func splitRef(ref string) (snapName, name string, err error) {
+	parts := strings.Split(ref, ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected <snap>:<name>, got %q", ref)
+	}
+	if err := naming.ValidateInstance(parts[0]); err != nil {
+		return "", "", err
+	}
+	return parts[0], parts[1], nil
+}
+
+func (conn *ConnectionSpec) validate() error {
+	var err error
+	if conn.plugRef.Snap, conn.plugRef.Name, err = splitRef(conn.Plug); err != nil {
+		return fmt.Errorf("invalid connection plug: %v", err)
+	}
+	if err := naming.ValidatePlug(conn.plugRef.Name); err != nil {
+		return fmt.Errorf("invalid connection plug: %v", err)
+	}
+	if conn.slotRef.Snap, conn.slotRef.Name, err = splitRef(conn.Slot); err != nil {
+		return fmt.Errorf("invalid connection slot: %v", err)
+	}
+	if err := naming.ValidateSlot(conn.slotRef.Name); err != nil {
+		return fmt.Errorf("invalid connection slot: %v", err)
+	}
+	return nil
+}
+
+func (grp *QuotaGroupSpec) validate() error {
+	if err := naming.ValidateQuotaGroup(grp.Name); err != nil {
+		return err
+	}
+	if grp.Parent != "" {
+		if err := naming.ValidateQuotaGroup(grp.Parent); err != nil {
+			return fmt.Errorf("invalid parent of quota group %q: %v", grp.Name, err)
+		}
+	}
+	if grp.MaxMemory != "" {
+		size, err := strutil.ParseByteSize(grp.MaxMemory)
+		if err != nil {
+			return fmt.Errorf("invalid max-memory of quota group %q: %v", grp.Name, err)
+		}
+		grp.maxMemory = quantity.Size(size)
+	}
+	if grp.MaxThreads < 0 {
+		return fmt.Errorf("invalid max-threads of quota group %q: cannot be negative", grp.Name)
+	}
+	for _, name := range grp.Snaps {
+		if err := naming.ValidateInstance(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package applystate_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/applystate"
+	"github.com/snapcore/snapd/snap"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type manifestSuite struct{}
+
+var _ = Suite(&manifestSuite{})
+
+func (s *manifestSuite) TestParseManifest(c *C) {
+	m, err := applystate.ParseManifest([]byte(`
+snaps:
+  - name: foo
+    channel: latest/stable
+    config:
+      bar:
+        baz: 1
+  - name: bar
+    revision: 7
+    classic: true
+  - name: system
+    config:
+      service.ssh.disable: true
+connections:
+  - plug: foo:network
+    slot: system:network
+quota-groups:
+  - name: group
+    max-memory: 100MB
+    max-threads: 32
+    snaps: [foo]
+`))
+	c.Assert(err, IsNil)
+	c.Assert(m.Snaps, HasLen, 3)
+	c.Check(m.Snaps[0].Name, Equals, "foo")
+	c.Check(m.Snaps[0].Channel, Equals, "latest/stable")
+	c.Check(m.Snaps[0].Config, DeepEquals, map[string]interface{}{
+		"bar": map[string]interface{}{"baz": int64(1)},
+	})
+	c.Check(m.Snaps[1].Revision, Equals, snap.R(7))
+	c.Check(m.Snaps[1].Classic, Equals, true)
+	c.Check(m.Snaps[2].Config, DeepEquals, map[string]interface{}{"service.ssh.disable": true})
+	c.Assert(m.Connections, HasLen, 1)
+	c.Check(m.Connections[0].Plug, Equals, "foo:network")
+	c.Check(m.Connections[0].Slot, Equals, "system:network")
+	c.Assert(m.QuotaGroups, HasLen, 1)
+	c.Check(m.QuotaGroups[0].MaxMemory, Equals, "100MB")
+	c.Check(m.QuotaGroups[0].MaxThreads, Equals, 32)
+	c.Check(m.QuotaGroups[0].Snaps, DeepEquals, []string{"foo"})
+}
+
+func (s *manifestSuite) TestParseManifestJSON(c *C) {
+	m, err := applystate.ParseManifest([]byte(`{"snaps": [{"name": "foo", "channel": "edge"}]}`))
+	c.Assert(err, IsNil)
+	c.Assert(m.Snaps, HasLen, 1)
+	c.Check(m.Snaps[0].Channel, Equals, "edge")
+}
+
+func (s *manifestSuite) TestParseManifestErrors(c *C) {
+	for _, t := range []struct {
+		manifest string
+		err      string
+	}{
+		{`snaps: foo`, `(?s)cannot parse manifest: .*`},
+		{`unknown: 1`, `(?s)cannot parse manifest: .*field unknown not found.*`},
+		{`snaps: [{name: Foo}]`, `invalid manifest: invalid snap name: "Foo"`},
+		{`snaps: [{name: foo}, {name: foo}]`, `invalid manifest: snap "foo" listed more than once`},
+		{`snaps: [{name: foo, channel: "a/b/c/d"}]`, `invalid manifest: invalid channel for snap "foo": .*`},
+		{`snaps: [{name: system, channel: stable}]`, `invalid manifest: only configuration can be given for the "system" snap`},
+		{`connections: [{plug: foo, slot: "system:network"}]`, `invalid manifest: invalid connection plug: expected <snap>:<name>, got "foo"`},
+		{`connections: [{plug: "foo:network", slot: "system:"}]`, `invalid manifest: invalid connection slot: expected <snap>:<name>, got "system:"`},
+		{`quota-groups: [{name: grp, max-memory: lots}]`, `invalid manifest: invalid max-memory of quota group "grp": .*`},
+		{`quota-groups: [{name: grp, max-threads: -1}]`, `invalid manifest: invalid max-threads of quota group "grp": cannot be negative`},
+		{`quota-groups: [{name: grp}, {name: grp}]`, `invalid manifest: quota group "grp" listed more than once`},
+	} {
+		_, err := applystate.ParseManifest([]byte(t.manifest))
+		c.Check(err, ErrorMatches, t.err, Commentf(t.manifest))
+	}
+}
@@ -0,0 +1,217 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// RedactedValue is what secret values are shown as to anyone but the snap
+// owning them.
+const RedactedValue = "*****"
+
+// secretKey is the key of the JSON object a secret value is stored as, as
+// {"$secret": "<base64 encoded nonce and ciphertext>"}; it is not a valid
+// option name so it cannot clash with regular options.
+const secretKey = "$secret"
+
+const secretsKeySize = 32
+
+// secretsKey returns the key secret values are encrypted with, creating it
+// if needed. It is kept out of the state so that the state alone is not
+// enough to reveal the secrets.
+func secretsKey() ([]byte, error) {
+	key, err := ioutil.ReadFile(dirs.SnapConfigSecretsKeyFile)
+	if err == nil {
+		if len(key) != secretsKeySize {
+			return nil, fmt.Errorf("invalid configuration secrets key in %s", dirs.SnapConfigSecretsKeyFile)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key = make([]byte, secretsKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapConfigSecretsKeyFile), 0755); err != nil {
+		return nil, err
+	}
+	if err := osutil.AtomicWriteFile(dirs.SnapConfigSecretsKeyFile, key, 0600, 0); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func secretsAEAD() (cipher.AEAD, error) {
+	key, err := secretsKey()
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain configuration secrets key: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// NewSecret returns the value an option of the given snap is to be set to
+// for it to hold the given secret. The secret is encrypted, and can only be
+// revealed for the same snap.
+func NewSecret(instanceName, plaintext string) (map[string]interface{}, error) {
+	aead, err := secretsAEAD()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	// the snap name is authenticated along with the secret so that it
+	// cannot be copied over to another snap
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(instanceName))
+	return map[string]interface{}{
+		secretKey: base64.StdEncoding.EncodeToString(sealed),
+	}, nil
+}
+
+// SetSecret sets the option of the given snap to the given secret.
+func (t *Transaction) SetSecret(instanceName, key, plaintext string) error {
+	secret, err := NewSecret(instanceName, plaintext)
+	if err != nil {
+		return err
+	}
+	return t.Set(instanceName, key, secret)
+}
+
+// ChangedOption returns the option a path as returned by
+// Transaction.Changes is part of: setting a secret value shows up as a
+// change of the key inside the object the secret is stored as.
+func ChangedOption(path string) string {
+	return strings.TrimSuffix(path, "."+secretKey)
+}
+
+// sealedSecret returns the encoded secret the value holds, if it is a
+// secret value.
+func sealedSecret(value interface{}) (string, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	sealed, ok := m[secretKey].(string)
+	return sealed, ok
+}
+
+// IsSecret returns whether the value, as obtained from a transaction, is a
+// secret value.
+func IsSecret(value interface{}) bool {
+	_, ok := sealedSecret(value)
+	return ok
+}
+
+// mapSecrets returns the value with the secret values in it, at any depth,
+// replaced by what f returns for them. Secret values in maps are left out
+// if f returns nil.
+func mapSecrets(value interface{}, f func(sealed string) (interface{}, error)) (interface{}, error) {
+	if sealed, ok := sealedSecret(value); ok {
+		return f(sealed)
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			mapped, err := mapSecrets(elem, f)
+			if err != nil {
+				return nil, err
+			}
+			if mapped == nil && IsSecret(elem) {
+				continue
+			}
+			out[k] = mapped
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			mapped, err := mapSecrets(elem, f)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = mapped
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// RedactSecrets returns the value with the secret values in it replaced by
+// RedactedValue.
+func RedactSecrets(value interface{}) interface{} {
+	redacted, _ := mapSecrets(value, func(string) (interface{}, error) {
+		return RedactedValue, nil
+	})
+	return redacted
+}
+
+// WithoutSecrets returns the value without the secret values in it.
+func WithoutSecrets(value interface{}) interface{} {
+	stripped, _ := mapSecrets(value, func(string) (interface{}, error) {
+		return nil, nil
+	})
+	return stripped
+}
+
+// RevealSecrets returns the value, obtained from the configuration of the
+// given snap, with the secret values in it decrypted. It must only be used
+// to hand the configuration to the snap itself.
+func RevealSecrets(instanceName string, value interface{}) (interface{}, error) {
+	var aead cipher.AEAD
+	return mapSecrets(value, func(encoded string) (interface{}, error) {
+		if aead == nil {
+			var err error
+			if aead, err = secretsAEAD(); err != nil {
+				return nil, err
+			}
+		}
+		sealed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(sealed) < aead.NonceSize() {
+			return nil, fmt.Errorf("cannot decode secret value of snap %q", instanceName)
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(instanceName))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt secret value of snap %q", instanceName)
+		}
+		return string(plaintext), nil
+	})
+}
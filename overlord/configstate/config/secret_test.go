@@ -0,0 +1,143 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type secretSuite struct {
+	state *state.State
+}
+
+var _ = Suite(&secretSuite{})
+
+func (s *secretSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+}
+
+func (s *secretSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *secretSuite) TestSetSecret(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.SetSecret("test-snap", "token", "hunter2"), IsNil)
+	c.Assert(tr.Set("test-snap", "name", "frank"), IsNil)
+	tr.Commit()
+
+	// the secret is encrypted at rest
+	data, err := s.state.MarshalJSON()
+	c.Assert(err, IsNil)
+	c.Check(strings.Contains(string(data), "hunter2"), Equals, false)
+
+	// with a key kept out of the state
+	info, err := os.Stat(dirs.SnapConfigSecretsKeyFile)
+	c.Assert(err, IsNil)
+	c.Check(info.Mode().Perm(), Equals, os.FileMode(0600))
+
+	tr = config.NewTransaction(s.state)
+	var value interface{}
+	c.Assert(tr.Get("test-snap", "token", &value), IsNil)
+	c.Check(config.IsSecret(value), Equals, true)
+
+	var doc interface{}
+	c.Assert(tr.Get("test-snap", "", &doc), IsNil)
+	c.Check(config.RedactSecrets(doc), DeepEquals, map[string]interface{}{
+		"token": config.RedactedValue,
+		"name":  "frank",
+	})
+	c.Check(config.WithoutSecrets(doc), DeepEquals, map[string]interface{}{
+		"name": "frank",
+	})
+	revealed, err := config.RevealSecrets("test-snap", doc)
+	c.Assert(err, IsNil)
+	c.Check(revealed, DeepEquals, map[string]interface{}{
+		"token": "hunter2",
+		"name":  "frank",
+	})
+}
+
+func (s *secretSuite) TestNestedSecrets(c *C) {
+	secret, err := config.NewSecret("test-snap", "hunter2")
+	c.Assert(err, IsNil)
+	value := map[string]interface{}{
+		"auth": map[string]interface{}{"user": "frank", "password": secret},
+		"list": []interface{}{secret, 1},
+	}
+
+	c.Check(config.RedactSecrets(value), DeepEquals, map[string]interface{}{
+		"auth": map[string]interface{}{"user": "frank", "password": config.RedactedValue},
+		"list": []interface{}{config.RedactedValue, 1},
+	})
+	c.Check(config.WithoutSecrets(value), DeepEquals, map[string]interface{}{
+		"auth": map[string]interface{}{"user": "frank"},
+		"list": []interface{}{nil, 1},
+	})
+	revealed, err := config.RevealSecrets("test-snap", value)
+	c.Assert(err, IsNil)
+	c.Check(revealed, DeepEquals, map[string]interface{}{
+		"auth": map[string]interface{}{"user": "frank", "password": "hunter2"},
+		"list": []interface{}{"hunter2", 1},
+	})
+}
+
+func (s *secretSuite) TestRevealSecretOfOtherSnap(c *C) {
+	secret, err := config.NewSecret("test-snap", "hunter2")
+	c.Assert(err, IsNil)
+
+	_, err = config.RevealSecrets("other-snap", secret)
+	c.Check(err, ErrorMatches, `cannot decrypt secret value of snap "other-snap"`)
+}
+
+func (s *secretSuite) TestRevealSecretWithOtherKey(c *C) {
+	secret, err := config.NewSecret("test-snap", "hunter2")
+	c.Assert(err, IsNil)
+	c.Assert(os.Remove(dirs.SnapConfigSecretsKeyFile), IsNil)
+
+	_, err = config.RevealSecrets("test-snap", secret)
+	c.Check(err, ErrorMatches, `cannot decrypt secret value of snap "test-snap"`)
+}
+
+func (s *secretSuite) TestInvalidSecretsKey(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapdStateDir(dirs.GlobalRootDir), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapConfigSecretsKeyFile, []byte("short"), 0600), IsNil)
+
+	_, err := config.NewSecret("test-snap", "hunter2")
+	c.Check(err, ErrorMatches, `cannot obtain configuration secrets key: invalid configuration secrets key in .*`)
+}
+
+func (s *secretSuite) TestNotSecrets(c *C) {
+	c.Check(config.IsSecret("foo"), Equals, false)
+	c.Check(config.IsSecret(map[string]interface{}{"$secret": "x", "other": 1}), Equals, false)
+	c.Check(config.RedactSecrets("foo"), Equals, "foo")
+}
@@ -24,6 +24,7 @@ import (
 	"os"
 	"reflect"
 	"regexp"
+	"strings"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
 )
@@ -49,10 +50,37 @@ func coreCfg(tr config.ConfGetter, key string) (result string, err error) {
 	return fmt.Sprintf("%v", v), nil
 }
 
+// coreSecretCfg returns the configuration value for the core snap of an
+// option that holds a secret, decrypted.
+func coreSecretCfg(tr config.ConfGetter, key string) (result string, err error) {
+	var v interface{} = ""
+	if err := tr.Get("core", key, &v); err != nil && !config.IsNoOption(err) {
+		return "", err
+	}
+	v, err = config.RevealSecrets("core", v)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
 // supportedConfigurations contains a set of handled configuration keys.
 // The actual values are populated by `init()` functions in each module.
 var supportedConfigurations = make(map[string]bool, 32)
 
+// secretConfigurations contains the set of handled configuration keys that
+// hold secrets, which are stored encrypted and never shown by "snap get".
+var secretConfigurations = make(map[string]bool)
+
+// SecretOptions returns the options of the core snap that hold secrets.
+func SecretOptions() map[string]bool {
+	secrets := make(map[string]bool, len(secretConfigurations))
+	for key := range secretConfigurations {
+		secrets[strings.TrimPrefix(key, "core.")] = true
+	}
+	return secrets
+}
+
 func validateBoolFlag(tr config.ConfGetter, flag string) error {
 	value, err := coreCfg(tr, flag)
 	if err != nil {
@@ -79,7 +107,13 @@ func (cfg plainCoreConfig) Get(snapName, key string, result interface{}) error {
 
 	val, ok := cfg[key]
 	if !ok {
-		return &config.NoOptionError{SnapName: snapName, Key: key}
+		// the values are flattened, so options holding a map
+		// need to be put back together
+		subtree := cfg.subtree(key)
+		if subtree == nil {
+			return &config.NoOptionError{SnapName: snapName, Key: key}
+		}
+		val = subtree
 	}
 
 	rv := reflect.ValueOf(result)
@@ -87,6 +121,32 @@ func (cfg plainCoreConfig) Get(snapName, key string, result interface{}) error {
 	return nil
 }
 
+// subtree returns the options nested under the given key as a map, or nil
+// if there are none.
+func (cfg plainCoreConfig) subtree(key string) map[string]interface{} {
+	var tree map[string]interface{}
+	for k, v := range cfg {
+		if !strings.HasPrefix(k, key+".") {
+			continue
+		}
+		if tree == nil {
+			tree = make(map[string]interface{})
+		}
+		subkeys := strings.Split(strings.TrimPrefix(k, key+"."), ".")
+		node := tree
+		for _, subkey := range subkeys[:len(subkeys)-1] {
+			child, ok := node[subkey].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[subkey] = child
+			}
+			node = child
+		}
+		node[subkeys[len(subkeys)-1]] = v
+	}
+	return tree
+}
+
 // GetPristine implements config.ConfGetter interface
 // for plainCoreConfig, there are no "pristine" values, so just return nothing
 // this has the effect that every setting will be viewed as "dirty" and needing
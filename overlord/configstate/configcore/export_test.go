@@ -19,7 +19,12 @@
 
 package configcore
 
-import "github.com/snapcore/snapd/osutil/sys"
+import (
+	"time"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/osutil/sys"
+)
 
 var (
 	UpdatePiConfig       = updatePiConfig
@@ -48,3 +53,51 @@ func MockChownPath(f func(string, sys.UserID, sys.GroupID) error) func() {
 		sysChownPath = old
 	}
 }
+
+func MockNetplanCheckConnectivity(f func(addr string) bool) func() {
+	old := netplanCheckConnectivity
+	netplanCheckConnectivity = f
+	return func() {
+		netplanCheckConnectivity = old
+	}
+}
+
+func MockNetplanConnectivityTimeout(timeout, retry time.Duration) func() {
+	oldTimeout, oldRetry := netplanConnectivityTimeout, netplanConnectivityRetry
+	netplanConnectivityTimeout, netplanConnectivityRetry = timeout, retry
+	return func() {
+		netplanConnectivityTimeout, netplanConnectivityRetry = oldTimeout, oldRetry
+	}
+}
+
+func MockBootUpdateCommandLineAppend(f func(dev boot.Device, gadgetSnapOrDir, cmdlineAppend string) (bool, error)) func() {
+	old := bootUpdateCommandLineAppend
+	bootUpdateCommandLineAppend = f
+	return func() {
+		bootUpdateCommandLineAppend = old
+	}
+}
+
+func MockFirewallAfterFunc(f func(d time.Duration, fn func()) *time.Timer) func() {
+	old := firewallAfterFunc
+	firewallAfterFunc = f
+	firewallRollback = nil
+	return func() {
+		firewallAfterFunc = old
+		firewallRollback = nil
+	}
+}
+
+// ForgetFirewallRollback forgets the armed rollback timer, as happens when
+// snapd restarts.
+func ForgetFirewallRollback() {
+	firewallRollback = nil
+}
+
+func MockTimeNow(f func() time.Time) func() {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
@@ -0,0 +1,549 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+//go:build !nomanagers
+// +build !nomanagers
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/systemd"
+)
+
+const (
+	firewallOption               = "system.firewall"
+	firewallPolicyOption         = "system.firewall.policy"
+	firewallAllowOption          = "system.firewall.allow"
+	firewallConfirmTimeoutOption = "system.firewall.confirm-timeout"
+	firewallConfirmOption        = "system.firewall.confirm"
+
+	firewallUnit        = "snapd.firewall.service"
+	firewallRulesetFile = "snapd.nft"
+	firewallPendingKey  = "firewall-pending"
+
+	defaultFirewallConfirmTimeout = 2 * time.Minute
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core."+firewallOption] = true
+	supportedConfigurations["core."+firewallPolicyOption] = true
+	supportedConfigurations["core."+firewallAllowOption] = true
+	supportedConfigurations["core."+firewallConfirmTimeoutOption] = true
+	supportedConfigurations["core."+firewallConfirmOption] = true
+}
+
+var (
+	// rule names follow the same rules as the store-certs ones
+	validFirewallRuleName = regexp.MustCompile("^" + validCertRegexp + "$").MatchString
+
+	firewallAfterFunc = time.AfterFunc
+	timeNow           = time.Now
+
+	// firewallRollback is the timer restoring the previous rules if
+	// the pending ones are not confirmed, only accessed with the state
+	// locked
+	firewallRollback *time.Timer
+)
+
+func firewallDir() string {
+	return filepath.Join(dirs.SnapdStateDir(dirs.GlobalRootDir), "firewall")
+}
+
+type firewallRule struct {
+	name     string
+	port     string
+	protocol string
+	source   *net.IPNet
+}
+
+type firewallSettings struct {
+	policy         string
+	rules          []firewallRule
+	confirmTimeout time.Duration
+}
+
+// firewallString returns the string form of a value of the firewall
+// tree, numbers as set with snap set included.
+func firewallString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	}
+	return "", false
+}
+
+func validFirewallPort(port string) bool {
+	n, err := strconv.ParseUint(port, 10, 16)
+	return err == nil && n > 0
+}
+
+func getFirewallRule(name string, v interface{}) (*firewallRule, error) {
+	if !validFirewallRuleName(name) {
+		return nil, fmt.Errorf("cannot set firewall rule %q: name must only contain word characters or a dash", name)
+	}
+	values, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot set firewall rule %q: expected port, protocol and source", name)
+	}
+	rule := &firewallRule{name: name, protocol: "tcp"}
+	for key, value := range values {
+		s, ok := firewallString(value)
+		if !ok {
+			return nil, fmt.Errorf("cannot set firewall rule %q: invalid %s %v", name, key, value)
+		}
+		switch key {
+		case "port":
+			rule.port = s
+		case "protocol":
+			if s != "tcp" && s != "udp" {
+				return nil, fmt.Errorf("cannot set firewall rule %q: protocol must be tcp or udp, not %q", name, s)
+			}
+			rule.protocol = s
+		case "source":
+			if ip := net.ParseIP(s); ip != nil {
+				bits := 8 * net.IPv6len
+				if ip4 := ip.To4(); ip4 != nil {
+					ip, bits = ip4, 8*net.IPv4len
+				}
+				rule.source = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+				break
+			}
+			_, ipnet, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, fmt.Errorf("cannot set firewall rule %q: source must be an IP address or network, not %q", name, s)
+			}
+			rule.source = ipnet
+		default:
+			return nil, fmt.Errorf("cannot set firewall rule %q: unsupported option %q", name, key)
+		}
+	}
+	if rule.port == "" {
+		return nil, fmt.Errorf("cannot set firewall rule %q: port is required", name)
+	}
+	ports := strings.SplitN(rule.port, "-", 2)
+	valid := validFirewallPort(ports[0])
+	if valid && len(ports) == 2 {
+		first, _ := strconv.Atoi(ports[0])
+		last, _ := strconv.Atoi(ports[1])
+		valid = validFirewallPort(ports[1]) && first < last
+	}
+	if !valid {
+		return nil, fmt.Errorf("cannot set firewall rule %q: port must be a port number or a range like 8000-8100, not %q", name, rule.port)
+	}
+	return rule, nil
+}
+
+func getFirewallSettings(tr config.ConfGetter) (*firewallSettings, error) {
+	settings := &firewallSettings{
+		policy:         "accept",
+		confirmTimeout: defaultFirewallConfirmTimeout,
+	}
+
+	policy, err := coreCfg(tr, firewallPolicyOption)
+	if err != nil {
+		return nil, err
+	}
+	switch policy {
+	case "":
+		// default
+	case "accept", "drop":
+		settings.policy = policy
+	default:
+		return nil, fmt.Errorf("%s can only be set to 'accept' or 'drop'", firewallPolicyOption)
+	}
+
+	timeout, err := coreCfg(tr, firewallConfirmTimeoutOption)
+	if err != nil {
+		return nil, err
+	}
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("cannot parse %s: %q is not a valid duration", firewallConfirmTimeoutOption, timeout)
+		}
+		settings.confirmTimeout = d
+	}
+
+	var v interface{}
+	if err := tr.Get("core", firewallAllowOption, &v); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if v == nil {
+		return settings, nil
+	}
+	allow, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot set %s: expected rules by name", firewallAllowOption)
+	}
+	for _, name := range sortedMapKeys(allow) {
+		rule, err := getFirewallRule(name, allow[name])
+		if err != nil {
+			return nil, err
+		}
+		settings.rules = append(settings.rules, *rule)
+	}
+	return settings, nil
+}
+
+// ruleset returns the nftables table implementing the settings, or an
+// empty string when nothing is filtered.
+func (s *firewallSettings) ruleset() string {
+	if s.policy == "accept" && len(s.rules) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `table inet snapd {
+	chain input {
+		type filter hook input priority 0; policy %s;
+		ct state established,related accept
+		ct state invalid drop
+		iifname "lo" accept
+		meta l4proto { icmp, ipv6-icmp } accept
+`, s.policy)
+	for _, rule := range s.rules {
+		fmt.Fprintf(&buf, "\t\t# %s\n\t\t", rule.name)
+		if rule.source != nil {
+			family := "ip6"
+			if rule.source.IP.To4() != nil {
+				family = "ip"
+			}
+			fmt.Fprintf(&buf, "%s saddr %s ", family, rule.source)
+		}
+		fmt.Fprintf(&buf, "%s dport %s accept\n", rule.protocol, rule.port)
+	}
+	buf.WriteString("\t}\n}\n")
+	return buf.String()
+}
+
+// firewallRulesetContent returns the content of an nftables file
+// replacing the snapd table with the given ruleset in one transaction.
+func firewallRulesetContent(ruleset string) []byte {
+	// declaring the table first makes deleting it work even when it
+	// does not exist yet
+	return []byte("# generated by snapd, do not edit\ntable inet snapd\ndelete table inet snapd\n" + ruleset)
+}
+
+func runNft(args ...string) error {
+	output, err := exec.Command("nft", args...).CombinedOutput()
+	if err != nil {
+		return osutil.OutputErr(output, err)
+	}
+	return nil
+}
+
+// applyFirewallRuleset checks and then atomically replaces the rules of
+// the snapd table in the kernel.
+func applyFirewallRuleset(ruleset string) error {
+	dir := firewallDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := osutil.NewAtomicFile(filepath.Join(dir, firewallRulesetFile+".new"), 0600, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return err
+	}
+	defer f.Cancel()
+	if _, err := f.Write(firewallRulesetContent(ruleset)); err != nil {
+		return err
+	}
+	if err := f.Commit(); err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := runNft("-c", "-f", f.Name()); err != nil {
+		return fmt.Errorf("cannot validate firewall rules: %v", err)
+	}
+	if err := runNft("-f", f.Name()); err != nil {
+		return fmt.Errorf("cannot apply firewall rules: %v", err)
+	}
+	return nil
+}
+
+func firewallUnitContent(rulesetFile string) []byte {
+	return []byte(fmt.Sprintf(`[Unit]
+Description=Firewall rules of the system configuration (managed by snapd)
+DefaultDependencies=no
+After=local-fs.target
+Before=network-pre.target
+Wants=network-pre.target
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/usr/sbin/nft -f %s
+
+[Install]
+WantedBy=multi-user.target
+`, rulesetFile))
+}
+
+// persistFirewallRuleset makes the given ruleset the one loaded at boot.
+func persistFirewallRuleset(ruleset string) error {
+	dir := firewallDir()
+	rulesetPath := filepath.Join(dir, firewallRulesetFile)
+	unitDir := filepath.Join(dirs.GlobalRootDir, "/etc/systemd/system")
+	unitPath := filepath.Join(unitDir, firewallUnit)
+	sysd := systemd.New(systemd.SystemMode, &sysdLogger{})
+
+	if ruleset == "" {
+		if !osutil.FileExists(unitPath) {
+			return nil
+		}
+		if err := sysd.Disable(firewallUnit); err != nil {
+			return err
+		}
+		if err := os.Remove(unitPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(rulesetPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return sysd.DaemonReload()
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(rulesetPath, firewallRulesetContent(ruleset), 0600, 0); err != nil {
+		return err
+	}
+	if osutil.FileExists(unitPath) {
+		return nil
+	}
+	if err := os.MkdirAll(unitDir, 0755); err != nil {
+		return err
+	}
+	// the path the unit sees, without the root directory
+	unitRulesetPath := filepath.Join(dirs.SnapdStateDir("/"), "firewall", firewallRulesetFile)
+	if err := osutil.AtomicWriteFile(unitPath, firewallUnitContent(unitRulesetPath), 0644, 0); err != nil {
+		return err
+	}
+	if err := sysd.DaemonReload(); err != nil {
+		return err
+	}
+	// the rules are already in place, they only need loading at boot
+	return sysd.Enable(firewallUnit)
+}
+
+// firewallPending records rules applied to the kernel while they wait
+// for a confirmation.
+type firewallPending struct {
+	// PreviousConfig is the system.firewall configuration to restore
+	PreviousConfig *json.RawMessage `json:"previous-config,omitempty"`
+	// PreviousRuleset is the ruleset to restore
+	PreviousRuleset string `json:"previous-ruleset"`
+	// Ruleset is the ruleset waiting for a confirmation
+	Ruleset string `json:"ruleset"`
+	// Deadline is when the previous ruleset is restored if Ruleset is
+	// not confirmed
+	Deadline time.Time `json:"deadline"`
+}
+
+func persistedFirewallRuleset() (string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(firewallDir(), firewallRulesetFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(string(content), string(firewallRulesetContent(""))), nil
+}
+
+func validateFirewallSettings(tr config.Conf) error {
+	_, err := getFirewallSettings(tr)
+	return err
+}
+
+// handleFirewallConfiguration applies the firewall rules to the kernel.
+// Once the device is seeded, new rules that could cut off the device
+// from whoever changed them are only made persistent once confirmed
+// with system.firewall.confirm, and are reverted after the
+// system.firewall.confirm-timeout otherwise.
+func handleFirewallConfiguration(tr config.Conf, opts *fsOnlyContext) error {
+	var firewallChanged, confirm bool
+	for _, k := range tr.Changes() {
+		if k == "core."+firewallOption || strings.HasPrefix(k, "core."+firewallOption+".") {
+			firewallChanged = true
+		}
+		if k == "core."+firewallConfirmOption {
+			confirm = true
+		}
+	}
+	if !firewallChanged {
+		return nil
+	}
+
+	settings, err := getFirewallSettings(tr)
+	if err != nil {
+		return err
+	}
+	ruleset := settings.ruleset()
+
+	st := tr.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var seeded bool
+	if err := st.Get("seeded", &seeded); err != nil && err != state.ErrNoState {
+		return err
+	}
+	var pending firewallPending
+	hasPending := true
+	if err := st.Get(firewallPendingKey, &pending); err != nil {
+		if err != state.ErrNoState {
+			return err
+		}
+		hasPending = false
+	}
+
+	current := pending.Ruleset
+	if !hasPending {
+		current, err = persistedFirewallRuleset()
+		if err != nil {
+			return err
+		}
+	}
+	changed := ruleset != current
+	if changed {
+		if err := applyFirewallRuleset(ruleset); err != nil {
+			return err
+		}
+	}
+
+	// gadget defaults are applied as they are at first boot
+	needsConfirm := seeded && settings.confirmTimeout > 0 && !confirm
+	if changed && needsConfirm {
+		if !hasPending {
+			pending.PreviousRuleset = current
+			var previous interface{}
+			if err := tr.GetPristine("core", firewallOption, &previous); err != nil && !config.IsNoOption(err) {
+				return err
+			}
+			if previous != nil {
+				data, err := json.Marshal(previous)
+				if err != nil {
+					return err
+				}
+				raw := json.RawMessage(data)
+				pending.PreviousConfig = &raw
+			}
+		}
+		pending.Ruleset = ruleset
+		pending.Deadline = timeNow().Add(settings.confirmTimeout)
+		st.Set(firewallPendingKey, &pending)
+		armFirewallRollback(st, settings.confirmTimeout)
+		return nil
+	}
+
+	if changed || (hasPending && confirm) {
+		if err := persistFirewallRuleset(ruleset); err != nil {
+			return err
+		}
+		st.Set(firewallPendingKey, nil)
+		if firewallRollback != nil {
+			firewallRollback.Stop()
+			firewallRollback = nil
+		}
+	}
+	return nil
+}
+
+// armFirewallRollback (re)starts the timer reverting to the previous
+// rules. If snapd restarts meanwhile EnsureFirewallRollback arms it again.
+func armFirewallRollback(st *state.State, timeout time.Duration) {
+	if firewallRollback != nil {
+		firewallRollback.Stop()
+	}
+	firewallRollback = firewallAfterFunc(timeout, func() {
+		rollbackFirewall(st)
+	})
+}
+
+// EnsureFirewallRollback arms the timer reverting to the previous rules
+// while new ones wait for a confirmation, as the timer does not survive
+// snapd restarting. Rules past their deadline are reverted right away.
+// The state must be locked by the caller.
+func EnsureFirewallRollback(st *state.State) error {
+	if firewallRollback != nil {
+		return nil
+	}
+	var pending firewallPending
+	if err := st.Get(firewallPendingKey, &pending); err != nil {
+		if err == state.ErrNoState {
+			return nil
+		}
+		return err
+	}
+	timeout := pending.Deadline.Sub(timeNow())
+	if timeout < 0 {
+		timeout = 0
+	}
+	armFirewallRollback(st, timeout)
+	return nil
+}
+
+func rollbackFirewall(st *state.State) {
+	st.Lock()
+	defer st.Unlock()
+
+	var pending firewallPending
+	if err := st.Get(firewallPendingKey, &pending); err != nil {
+		if err != state.ErrNoState {
+			logger.Noticef("cannot read pending firewall rules: %v", err)
+		}
+		// confirmed meanwhile
+		return
+	}
+	st.Set(firewallPendingKey, nil)
+	firewallRollback = nil
+
+	if err := applyFirewallRuleset(pending.PreviousRuleset); err != nil {
+		logger.Noticef("cannot restore previous firewall rules: %v", err)
+	}
+	tr := config.NewTransaction(st)
+	if err := tr.Set("core", firewallOption, pending.PreviousConfig); err != nil {
+		logger.Noticef("cannot restore previous firewall configuration: %v", err)
+		return
+	}
+	tr.Commit()
+	logger.Noticef("firewall rules were not confirmed, restored the previous ones")
+}
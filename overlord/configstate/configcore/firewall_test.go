@@ -0,0 +1,405 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type firewallSuite struct {
+	configcoreSuite
+
+	mockNft    *testutil.MockCmd
+	nftLog     string
+	rulesetDir string
+	unitPath   string
+
+	timeouts []time.Duration
+	rollback func()
+}
+
+var _ = Suite(&firewallSuite{})
+
+func (s *firewallSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "etc"), 0755), IsNil)
+	s.AddCleanup(release.MockOnClassic(false))
+
+	// record the rulesets loaded into the kernel
+	s.nftLog = filepath.Join(c.MkDir(), "nft.log")
+	s.mockNft = testutil.MockCommand(c, "nft", fmt.Sprintf(`if [ "$1" = "-f" ]; then cat "$2" >> %s; fi`, s.nftLog))
+	s.AddCleanup(s.mockNft.Restore)
+
+	s.rulesetDir = filepath.Join(dirs.SnapdStateDir(dirs.GlobalRootDir), "firewall")
+	s.unitPath = filepath.Join(dirs.GlobalRootDir, "/etc/systemd/system/snapd.firewall.service")
+
+	s.timeouts = nil
+	s.rollback = nil
+	s.AddCleanup(configcore.MockFirewallAfterFunc(func(d time.Duration, fn func()) *time.Timer {
+		s.timeouts = append(s.timeouts, d)
+		s.rollback = fn
+		return time.AfterFunc(time.Hour, func() {})
+	}))
+
+	s.state.Lock()
+	s.state.Set("seeded", true)
+	s.state.Unlock()
+}
+
+const sshRuleset = `table inet snapd {
+	chain input {
+		type filter hook input priority 0; policy drop;
+		ct state established,related accept
+		ct state invalid drop
+		iifname "lo" accept
+		meta l4proto { icmp, ipv6-icmp } accept
+		# ssh
+		ip saddr 10.0.0.0/8 tcp dport 22 accept
+	}
+}
+`
+
+const firewallPreamble = "# generated by snapd, do not edit\ntable inet snapd\ndelete table inet snapd\n"
+
+func (s *firewallSuite) loadedRulesets(c *C) string {
+	content, err := ioutil.ReadFile(s.nftLog)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *firewallSuite) sshConf() *mockConf {
+	return &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.firewall.policy": "drop",
+			"system.firewall.allow": map[string]interface{}{
+				"ssh": map[string]interface{}{
+					"port":   json.Number("22"),
+					"source": "10.0.0.0/8",
+				},
+			},
+		},
+	}
+}
+
+func (s *firewallSuite) TestFirewallNotSeededPersistsDirectly(c *C) {
+	s.state.Lock()
+	s.state.Set("seeded", false)
+	s.state.Unlock()
+
+	err := configcore.Run(s.sshConf())
+	c.Assert(err, IsNil)
+
+	tmpRuleset := filepath.Join(s.rulesetDir, "snapd.nft.new")
+	c.Check(s.mockNft.Calls(), DeepEquals, [][]string{
+		{"nft", "-c", "-f", tmpRuleset},
+		{"nft", "-f", tmpRuleset},
+	})
+	c.Check(tmpRuleset, testutil.FileAbsent)
+	c.Check(s.loadedRulesets(c), Equals, firewallPreamble+sshRuleset)
+	c.Check(filepath.Join(s.rulesetDir, "snapd.nft"), testutil.FileEquals, firewallPreamble+sshRuleset)
+	c.Check(s.unitPath, testutil.FileContains, "ExecStart=/usr/sbin/nft -f /var/lib/snapd/firewall/snapd.nft\n")
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"enable", "snapd.firewall.service"},
+	})
+	// no confirmation needed
+	c.Check(s.timeouts, HasLen, 0)
+}
+
+func (s *firewallSuite) TestFirewallConfirm(c *C) {
+	err := configcore.Run(s.sshConf())
+	c.Assert(err, IsNil)
+
+	// applied but pending
+	c.Check(s.loadedRulesets(c), Equals, firewallPreamble+sshRuleset)
+	c.Check(s.timeouts, DeepEquals, []time.Duration{2 * time.Minute})
+	c.Check(filepath.Join(s.rulesetDir, "snapd.nft"), testutil.FileAbsent)
+	c.Check(s.unitPath, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, HasLen, 0)
+
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.firewall.policy": "drop",
+			"system.firewall.allow":  s.sshConf().changes["system.firewall.allow"],
+		},
+		changes: map[string]interface{}{
+			"system.firewall.confirm": true,
+		},
+	})
+	c.Assert(err, IsNil)
+
+	// the rules are not loaded again, but persisted
+	c.Check(s.mockNft.Calls(), HasLen, 2)
+	c.Check(filepath.Join(s.rulesetDir, "snapd.nft"), testutil.FileEquals, firewallPreamble+sshRuleset)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"enable", "snapd.firewall.service"},
+	})
+
+	s.state.Lock()
+	var pending interface{}
+	c.Check(s.state.Get("firewall-pending", &pending), Equals, state.ErrNoState)
+	s.state.Unlock()
+
+	// a late rollback is a no-op
+	s.rollback()
+	c.Check(s.mockNft.Calls(), HasLen, 2)
+}
+
+func (s *firewallSuite) commitConfig(c *C, values map[string]interface{}) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	for k, v := range values {
+		c.Assert(tr.Set("core", k, v), IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *firewallSuite) TestFirewallRollback(c *C) {
+	// rules dropping everything set at first boot
+	s.state.Lock()
+	s.state.Set("seeded", false)
+	s.state.Unlock()
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.firewall.policy": "drop",
+		},
+	})
+	c.Assert(err, IsNil)
+	s.commitConfig(c, map[string]interface{}{"system.firewall.policy": "drop"})
+	dropRuleset := s.loadedRulesets(c)
+	c.Check(dropRuleset, testutil.Contains, "policy drop;")
+
+	s.state.Lock()
+	s.state.Set("seeded", true)
+	s.state.Unlock()
+	allow := map[string]interface{}{
+		"http": map[string]interface{}{"port": "80"},
+	}
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.firewall":        map[string]interface{}{"policy": "drop"},
+			"system.firewall.policy": "drop",
+		},
+		changes: map[string]interface{}{
+			"system.firewall.confirm-timeout": "30s",
+			"system.firewall.allow":           allow,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.timeouts, DeepEquals, []time.Duration{30 * time.Second})
+	c.Check(s.loadedRulesets(c), testutil.Contains, "tcp dport 80 accept")
+	s.commitConfig(c, map[string]interface{}{
+		"system.firewall.confirm-timeout": "30s",
+		"system.firewall.allow":           allow,
+	})
+
+	c.Assert(s.rollback, NotNil)
+	s.rollback()
+
+	// the previous rules were loaded back
+	c.Check(s.mockNft.Calls(), HasLen, 6)
+	c.Check(s.loadedRulesets(c), testutil.Contains, "tcp dport 80 accept\n\t}\n}\n"+dropRuleset)
+	c.Check(filepath.Join(s.rulesetDir, "snapd.nft"), testutil.FileEquals, dropRuleset)
+
+	// and so was the configuration
+	s.state.Lock()
+	defer s.state.Unlock()
+	var previous map[string]interface{}
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Get("core", "system.firewall", &previous), IsNil)
+	c.Check(previous, DeepEquals, map[string]interface{}{"policy": "drop"})
+	var pending interface{}
+	c.Check(s.state.Get("firewall-pending", &pending), Equals, state.ErrNoState)
+}
+
+func (s *firewallSuite) TestFirewallRollbackAfterRestart(c *C) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(configcore.MockTimeNow(func() time.Time { return now }))
+
+	err := configcore.Run(s.sshConf())
+	c.Assert(err, IsNil)
+	c.Check(s.timeouts, DeepEquals, []time.Duration{2 * time.Minute})
+	s.commitConfig(c, s.sshConf().changes)
+
+	// nothing to do while the timer is armed
+	s.state.Lock()
+	c.Assert(configcore.EnsureFirewallRollback(s.state), IsNil)
+	s.state.Unlock()
+	c.Check(s.timeouts, HasLen, 1)
+
+	// snapd restarting loses the timer, which is armed again with the
+	// time left
+	configcore.ForgetFirewallRollback()
+	now = now.Add(30 * time.Second)
+	s.state.Lock()
+	c.Assert(configcore.EnsureFirewallRollback(s.state), IsNil)
+	s.state.Unlock()
+	c.Check(s.timeouts, DeepEquals, []time.Duration{2 * time.Minute, 90 * time.Second})
+
+	// past the deadline the rollback is immediate
+	configcore.ForgetFirewallRollback()
+	now = now.Add(time.Hour)
+	s.state.Lock()
+	c.Assert(configcore.EnsureFirewallRollback(s.state), IsNil)
+	s.state.Unlock()
+	c.Check(s.timeouts, DeepEquals, []time.Duration{2 * time.Minute, 90 * time.Second, 0})
+
+	s.rollback()
+	c.Check(s.mockNft.Calls(), HasLen, 4)
+	c.Check(s.loadedRulesets(c), testutil.Contains, "}\n"+firewallPreamble)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var pending interface{}
+	c.Check(s.state.Get("firewall-pending", &pending), Equals, state.ErrNoState)
+}
+
+func (s *firewallSuite) TestFirewallRollbackUnsetsConfiguration(c *C) {
+	err := configcore.Run(s.sshConf())
+	c.Assert(err, IsNil)
+
+	// a second change keeps what to go back to
+	conf := s.sshConf()
+	conf.changes["system.firewall.policy"] = "accept"
+	err = configcore.Run(conf)
+	c.Assert(err, IsNil)
+	c.Check(s.timeouts, HasLen, 2)
+	s.commitConfig(c, conf.changes)
+
+	s.rollback()
+
+	c.Check(s.mockNft.Calls(), HasLen, 6)
+	c.Check(s.loadedRulesets(c), testutil.Contains, "policy accept;")
+	c.Check(s.loadedRulesets(c), testutil.Contains, "}\n"+firewallPreamble)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var v interface{}
+	tr := config.NewTransaction(s.state)
+	c.Check(config.IsNoOption(tr.Get("core", "system.firewall", &v)), Equals, true)
+}
+
+func (s *firewallSuite) TestFirewallNoConfirmTimeout(c *C) {
+	conf := s.sshConf()
+	conf.changes["system.firewall.confirm-timeout"] = json.Number("0")
+	err := configcore.Run(conf)
+	c.Assert(err, IsNil)
+	c.Check(s.timeouts, HasLen, 0)
+	c.Check(filepath.Join(s.rulesetDir, "snapd.nft"), testutil.FileEquals, firewallPreamble+sshRuleset)
+}
+
+func (s *firewallSuite) TestFirewallUnsetRemovesRules(c *C) {
+	s.state.Lock()
+	s.state.Set("seeded", false)
+	s.state.Unlock()
+	err := configcore.Run(s.sshConf())
+	c.Assert(err, IsNil)
+	s.systemctlArgs = nil
+
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.firewall.policy": "",
+			"system.firewall.allow":  nil,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.loadedRulesets(c), Equals, firewallPreamble+sshRuleset+firewallPreamble)
+	c.Check(filepath.Join(s.rulesetDir, "snapd.nft"), testutil.FileAbsent)
+	c.Check(s.unitPath, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"disable", "snapd.firewall.service"},
+		{"daemon-reload"},
+	})
+}
+
+func (s *firewallSuite) TestFirewallInvalidRules(c *C) {
+	for _, tc := range []struct {
+		changes map[string]interface{}
+		err     string
+	}{
+		{map[string]interface{}{"system.firewall.policy": "reject"}, `system.firewall.policy can only be set to 'accept' or 'drop'`},
+		{map[string]interface{}{"system.firewall.confirm-timeout": "soon"}, `cannot parse system.firewall.confirm-timeout: "soon" is not a valid duration`},
+		{map[string]interface{}{"system.firewall.allow": "ssh"}, `cannot set system.firewall.allow: expected rules by name`},
+		{map[string]interface{}{"system.firewall.allow": map[string]interface{}{"ssh": "22"}}, `cannot set firewall rule "ssh": expected port, protocol and source`},
+		{map[string]interface{}{"system.firewall.allow": map[string]interface{}{"-ssh": map[string]interface{}{"port": "22"}}}, `cannot set firewall rule "-ssh": name must only contain word characters or a dash`},
+		{map[string]interface{}{"system.firewall.allow": map[string]interface{}{"ssh": map[string]interface{}{"protocol": "udp"}}}, `cannot set firewall rule "ssh": port is required`},
+		{map[string]interface{}{"system.firewall.allow": map[string]interface{}{"ssh": map[string]interface{}{"port": "0"}}}, `cannot set firewall rule "ssh": port must be a port number or a range like 8000-8100, not "0"`},
+		{map[string]interface{}{"system.firewall.allow": map[string]interface{}{"ssh": map[string]interface{}{"port": "90-80"}}}, `cannot set firewall rule "ssh": port must be a port number or a range like 8000-8100, not "90-80"`},
+		{map[string]interface{}{"system.firewall.allow": map[string]interface{}{"ssh": map[string]interface{}{"port": "65536"}}}, `cannot set firewall rule "ssh": port must be a port number or a range like 8000-8100, not "65536"`},
+		{map[string]interface{}{"system.firewall.allow": map[string]interface{}{"ssh": map[string]interface{}{"port": "22", "protocol": "sctp"}}}, `cannot set firewall rule "ssh": protocol must be tcp or udp, not "sctp"`},
+		{map[string]interface{}{"system.firewall.allow": map[string]interface{}{"ssh": map[string]interface{}{"port": "22", "source": "10.0.0.0/33"}}}, `cannot set firewall rule "ssh": source must be an IP address or network, not "10.0.0.0/33"`},
+		{map[string]interface{}{"system.firewall.allow": map[string]interface{}{"ssh": map[string]interface{}{"port": "22", "interface": "eth0"}}}, `cannot set firewall rule "ssh": unsupported option "interface"`},
+	} {
+		err := configcore.Run(&mockConf{state: s.state, changes: tc.changes})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.changes))
+	}
+	c.Check(s.mockNft.Calls(), HasLen, 0)
+}
+
+func (s *firewallSuite) TestFirewallRulesRejectedByNft(c *C) {
+	mockNft := testutil.MockCommand(c, "nft", "echo 'Error: syntax error'; exit 1")
+	defer mockNft.Restore()
+	err := configcore.Run(s.sshConf())
+	c.Assert(err, ErrorMatches, "cannot validate firewall rules: Error: syntax error")
+	c.Check(mockNft.Calls(), HasLen, 1)
+	c.Check(s.timeouts, HasLen, 0)
+}
+
+func (s *firewallSuite) TestFirewallIPv6SourceAndRange(c *C) {
+	s.state.Lock()
+	s.state.Set("seeded", false)
+	s.state.Unlock()
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.firewall.allow": map[string]interface{}{
+				"web": map[string]interface{}{"port": "8000-8100", "source": "fd00::1"},
+				"dns": map[string]interface{}{"port": "53", "protocol": "udp"},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.loadedRulesets(c), testutil.Contains, `policy accept;`)
+	c.Check(s.loadedRulesets(c), testutil.Contains, "\t\t# dns\n\t\tudp dport 53 accept\n\t\t# web\n\t\tip6 saddr fd00::1/128 tcp dport 8000-8100 accept\n")
+}
@@ -94,9 +94,24 @@ func init() {
 	// journal.persistent
 	addFSOnlyHandler(validateJournalSettings, handleJournalConfiguration, coreOnly)
 
+	// system.journal.{max-size,max-retention,rate-limit,forward.*}
+	addFSOnlyHandler(validateJournalOptions, handleJournalOptions, coreOnly)
+
 	// system.timezone
 	addFSOnlyHandler(validateTimezoneSettings, handleTimezoneConfiguration, coreOnly)
 
+	// system.hostname
+	addFSOnlyHandler(validateHostnameSettings, handleHostnameConfiguration, coreOnly)
+
+	// system.ntp.servers
+	addFSOnlyHandler(validateNTPSettings, handleNTPConfiguration, coreOnly)
+
+	// system.locale, system.keyboard.layout
+	addFSOnlyHandler(validateLocaleSettings, handleLocaleConfiguration, coreOnly)
+
+	// system.network.netplan
+	addFSOnlyHandler(validateNetplanSettings, handleNetplanConfiguration, coreOnly)
+
 	sysconfig.ApplyFilesystemOnlyDefaultsImpl = func(rootDir string, defaults map[string]interface{}, options *sysconfig.FilesystemOnlyApplyOptions) error {
 		return filesystemOnlyApply(rootDir, defaults, options)
 	}
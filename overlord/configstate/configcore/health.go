@@ -0,0 +1,63 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+// +build !nomanagers
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/strutil"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.health.remediation"] = true
+	supportedConfigurations["core.health.error-threshold"] = true
+}
+
+var healthRemediations = []string{"none", "warn", "restart", "revert"}
+
+func validateHealthRemediation(tr config.Conf) error {
+	remediation, err := coreCfg(tr, "health.remediation")
+	if err != nil {
+		return err
+	}
+	if remediation != "" && !strutil.ListContains(healthRemediations, remediation) {
+		return fmt.Errorf("health.remediation must be one of %s, not %q", strutil.Quoted(healthRemediations), remediation)
+	}
+
+	thresholdStr, err := coreCfg(tr, "health.error-threshold")
+	if err != nil {
+		return err
+	}
+	if thresholdStr != "" {
+		threshold, err := time.ParseDuration(thresholdStr)
+		if err != nil {
+			return fmt.Errorf("health.error-threshold cannot be parsed: %v", err)
+		}
+		if threshold < time.Minute {
+			return fmt.Errorf("health.error-threshold must be at least one minute")
+		}
+	}
+	return nil
+}
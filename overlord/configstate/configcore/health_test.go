@@ -0,0 +1,73 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type healthSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&healthSuite{})
+
+func (s *healthSuite) TestConfigureHealthRemediationHappy(c *C) {
+	for _, remediation := range []string{"", "none", "warn", "restart", "revert"} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"health.remediation":     remediation,
+				"health.error-threshold": "30m",
+			},
+		})
+		c.Check(err, IsNil, Commentf("%q", remediation))
+	}
+}
+
+func (s *healthSuite) TestConfigureHealthRemediationInvalid(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"health.remediation": "reboot",
+		},
+	})
+	c.Assert(err, ErrorMatches, `health.remediation must be one of "none", "warn", "restart", "revert", not "reboot"`)
+}
+
+func (s *healthSuite) TestConfigureHealthErrorThresholdInvalid(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"health.error-threshold": "soon",
+		},
+	})
+	c.Assert(err, ErrorMatches, `health.error-threshold cannot be parsed: .*`)
+
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"health.error-threshold": "10s",
+		},
+	})
+	c.Assert(err, ErrorMatches, `health.error-threshold must be at least one minute`)
+}
@@ -21,8 +21,13 @@ package configcore
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
@@ -36,6 +41,250 @@ var sysChownPath = sys.ChownPath
 
 func init() {
 	supportedConfigurations["core.journal.persistent"] = true
+	for _, opt := range journalOptions {
+		supportedConfigurations["core."+opt] = true
+	}
+}
+
+var journalOptions = []string{
+	"system.journal.max-size",
+	"system.journal.max-retention",
+	"system.journal.rate-limit",
+	"system.journal.forward.host",
+	"system.journal.forward.port",
+	"system.journal.forward.protocol",
+}
+
+const (
+	journaldConfFile   = "20-snapd.conf"
+	journalForwardUnit = "snapd.journal-forward.service"
+)
+
+func journaldConfDir(rootDir string) string {
+	return filepath.Join(rootDir, "/etc/systemd/journald.conf.d")
+}
+
+var (
+	// sizes as understood by journald, e.g. 500M or 2G
+	validJournalSize = regexp.MustCompile(`^[0-9]+[KMGT]?$`)
+	// time spans as understood by journald, e.g. 30s, 2weeks or 1month
+	validJournalTimeSpan = regexp.MustCompile(`^[0-9]+(us|ms|s|sec|m|min|h|d|day|days|w|week|weeks|month|months|y|year|years)?$`)
+)
+
+type journalForward struct {
+	host     string
+	port     string
+	protocol string
+}
+
+type journalSettings struct {
+	maxSize           string
+	maxRetention      string
+	rateLimitBurst    string
+	rateLimitInterval string
+	forward           *journalForward
+}
+
+func getJournalSettings(tr config.ConfGetter) (*journalSettings, error) {
+	values := make(map[string]string, len(journalOptions))
+	for _, opt := range journalOptions {
+		value, err := coreCfg(tr, opt)
+		if err != nil {
+			return nil, err
+		}
+		values[opt] = value
+	}
+
+	settings := &journalSettings{
+		maxSize:      values["system.journal.max-size"],
+		maxRetention: values["system.journal.max-retention"],
+	}
+	if settings.maxSize != "" && !validJournalSize.MatchString(settings.maxSize) {
+		return nil, fmt.Errorf("cannot set journal maximum size %q: expected a size like 500M or 2G", settings.maxSize)
+	}
+	if settings.maxRetention != "" && !validJournalTimeSpan.MatchString(settings.maxRetention) {
+		return nil, fmt.Errorf("cannot set journal maximum retention %q: expected a time span like 1d or 2weeks", settings.maxRetention)
+	}
+
+	// the rate limit is either 0 to disable it, or <burst>/<interval>,
+	// i.e. the number of messages per service allowed in the interval
+	if rateLimit := values["system.journal.rate-limit"]; rateLimit != "" {
+		if rateLimit == "0" {
+			settings.rateLimitInterval = "0"
+		} else {
+			split := strings.SplitN(rateLimit, "/", 2)
+			if len(split) != 2 || !validJournalTimeSpan.MatchString(split[1]) {
+				return nil, fmt.Errorf("cannot set journal rate limit %q: expected <messages>/<interval> like 1000/30s, or 0", rateLimit)
+			}
+			if _, err := strconv.ParseUint(split[0], 10, 32); err != nil {
+				return nil, fmt.Errorf("cannot set journal rate limit %q: expected <messages>/<interval> like 1000/30s, or 0", rateLimit)
+			}
+			settings.rateLimitBurst = split[0]
+			settings.rateLimitInterval = split[1]
+		}
+	}
+
+	host := values["system.journal.forward.host"]
+	port := values["system.journal.forward.port"]
+	protocol := values["system.journal.forward.protocol"]
+	if host == "" {
+		if port != "" || protocol != "" {
+			return nil, fmt.Errorf("cannot forward journal: system.journal.forward.host is not set")
+		}
+		return settings, nil
+	}
+	if net.ParseIP(host) == nil {
+		if err := validateHostname(host); err != nil {
+			return nil, fmt.Errorf("cannot forward journal to %q: name not valid", host)
+		}
+	}
+	if port == "" {
+		port = "514"
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return nil, fmt.Errorf("cannot forward journal to port %q: expected a number between 1 and 65535", port)
+	}
+	switch protocol {
+	case "":
+		protocol = "udp"
+	case "udp", "tcp":
+		// ok
+	default:
+		return nil, fmt.Errorf("cannot forward journal over %q: expected udp or tcp", protocol)
+	}
+	settings.forward = &journalForward{host: host, port: port, protocol: protocol}
+	return settings, nil
+}
+
+func (s *journalSettings) journaldConf() []byte {
+	var buf strings.Builder
+	if s.maxSize != "" {
+		fmt.Fprintf(&buf, "SystemMaxUse=%s\n", s.maxSize)
+	}
+	if s.maxRetention != "" {
+		fmt.Fprintf(&buf, "MaxRetentionSec=%s\n", s.maxRetention)
+	}
+	if s.rateLimitInterval != "" {
+		fmt.Fprintf(&buf, "RateLimitIntervalSec=%s\n", s.rateLimitInterval)
+	}
+	if s.rateLimitBurst != "" {
+		fmt.Fprintf(&buf, "RateLimitBurst=%s\n", s.rateLimitBurst)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return []byte("[Journal]\n" + buf.String())
+}
+
+// unitContent returns the unit of the service forwarding the
+// journal to a remote syslog server, with logger(1) from util-linux.
+func (f *journalForward) unitContent() []byte {
+	return []byte(fmt.Sprintf(`[Unit]
+Description=Forward the journal to %[1]s:%[2]s over %[3]s (managed by snapd)
+After=systemd-journald.service network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart=/bin/sh -c 'journalctl --follow --lines=0 --output=short-iso | logger --server %[1]s --port %[2]s --%[3]s --rfc5424 --tag journal'
+Restart=always
+RestartSec=10
+
+[Install]
+WantedBy=multi-user.target
+`, f.host, f.port, f.protocol))
+}
+
+func validateJournalOptions(tr config.ConfGetter) error {
+	_, err := getJournalSettings(tr)
+	return err
+}
+
+func handleJournalOptions(tr config.ConfGetter, opts *fsOnlyContext) error {
+	settings, err := getJournalSettings(tr)
+	if err != nil {
+		return err
+	}
+
+	rootDir := dirs.GlobalRootDir
+	if opts != nil {
+		rootDir = opts.RootDir
+	}
+
+	// journald drop-in with the size, retention and rate limit settings
+	dir := journaldConfDir(rootDir)
+	dirContent := make(map[string]osutil.FileState, 1)
+	if content := settings.journaldConf(); content != nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		dirContent[journaldConfFile] = &osutil.MemoryFileState{
+			Content: content,
+			Mode:    0644,
+		}
+	}
+	changed, removed, err := osutil.EnsureDirState(dir, journaldConfFile, dirContent)
+	if err != nil {
+		return err
+	}
+	journaldChanged := len(changed) > 0 || len(removed) > 0
+
+	// service forwarding the journal
+	unitDir := filepath.Join(rootDir, "/etc/systemd/system")
+	unitContent := make(map[string]osutil.FileState, 1)
+	if settings.forward != nil {
+		if err := os.MkdirAll(unitDir, 0755); err != nil {
+			return err
+		}
+		unitContent[journalForwardUnit] = &osutil.MemoryFileState{
+			Content: settings.forward.unitContent(),
+			Mode:    0644,
+		}
+	}
+	wasForwarding := osutil.FileExists(filepath.Join(unitDir, journalForwardUnit))
+	if opts == nil && wasForwarding && settings.forward == nil {
+		// stop forwarding before the unit goes away
+		sysd := systemd.New(systemd.SystemMode, &sysdLogger{})
+		if err := sysd.Disable(journalForwardUnit); err != nil {
+			return err
+		}
+		if err := sysd.Stop(journalForwardUnit, 60*time.Second); err != nil {
+			return err
+		}
+	}
+	changed, removed, err = osutil.EnsureDirState(unitDir, journalForwardUnit, unitContent)
+	if err != nil {
+		return err
+	}
+	forwardChanged := len(changed) > 0 || len(removed) > 0
+
+	if opts != nil {
+		if forwardChanged && settings.forward != nil {
+			return systemd.NewEmulationMode(opts.RootDir).Enable(journalForwardUnit)
+		}
+		return nil
+	}
+
+	sysd := systemd.New(systemd.SystemMode, &sysdLogger{})
+	if journaldChanged {
+		// journald only reads its configuration when starting
+		if err := sysd.Restart("systemd-journald.service", 60*time.Second); err != nil {
+			return err
+		}
+	}
+	if forwardChanged {
+		if err := sysd.DaemonReload(); err != nil {
+			return err
+		}
+		if settings.forward != nil {
+			if err := sysd.Enable(journalForwardUnit); err != nil {
+				return err
+			}
+			if err := sysd.Restart(journalForwardUnit, 60*time.Second); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func validateJournalSettings(tr config.ConfGetter) error {
@@ -32,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type journalSuite struct {
@@ -199,3 +200,150 @@ func (s *journalSuite) TestFilesystemOnlyApply(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(exists, Equals, true)
 }
+
+type journalOptionsSuite struct {
+	configcoreSuite
+
+	confFile string
+	unitFile string
+}
+
+var _ = Suite(&journalOptionsSuite{})
+
+func (s *journalOptionsSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	s.AddCleanup(release.MockOnClassic(false))
+
+	s.confFile = filepath.Join(dirs.GlobalRootDir, "/etc/systemd/journald.conf.d/20-snapd.conf")
+	s.unitFile = filepath.Join(dirs.GlobalRootDir, "/etc/systemd/system/snapd.journal-forward.service")
+}
+
+func (s *journalOptionsSuite) TestConfigureJournalOptionsInvalid(c *C) {
+	for _, t := range []struct {
+		conf map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"system.journal.max-size": "lots"}, `cannot set journal maximum size "lots": expected a size like 500M or 2G`},
+		{map[string]interface{}{"system.journal.max-retention": "2fortnights"}, `cannot set journal maximum retention "2fortnights": expected a time span like 1d or 2weeks`},
+		{map[string]interface{}{"system.journal.rate-limit": "1000"}, `cannot set journal rate limit "1000": expected <messages>/<interval> like 1000/30s, or 0`},
+		{map[string]interface{}{"system.journal.rate-limit": "many/30s"}, `cannot set journal rate limit "many/30s": expected <messages>/<interval> like 1000/30s, or 0`},
+		{map[string]interface{}{"system.journal.forward.port": "514"}, `cannot forward journal: system.journal.forward.host is not set`},
+		{map[string]interface{}{"system.journal.forward.host": "-bad"}, `cannot forward journal to "-bad": name not valid`},
+		{map[string]interface{}{"system.journal.forward.host": "log.example.com", "system.journal.forward.port": "65536"}, `cannot forward journal to port "65536": expected a number between 1 and 65535`},
+		{map[string]interface{}{"system.journal.forward.host": "log.example.com", "system.journal.forward.protocol": "http"}, `cannot forward journal over "http": expected udp or tcp`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf:  t.conf,
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(s.confFile, testutil.FileAbsent)
+	c.Check(s.unitFile, testutil.FileAbsent)
+}
+
+func (s *journalOptionsSuite) TestConfigureJournalLimits(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.journal.max-size":      "200M",
+			"system.journal.max-retention": "2weeks",
+			"system.journal.rate-limit":    "1000/30s",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confFile, testutil.FileEquals, `[Journal]
+SystemMaxUse=200M
+MaxRetentionSec=2weeks
+RateLimitIntervalSec=30s
+RateLimitBurst=1000
+`)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"stop", "systemd-journald.service"},
+		{"show", "--property=ActiveState", "systemd-journald.service"},
+		{"start", "systemd-journald.service"},
+	})
+
+	// disabling the rate limit
+	s.systemctlArgs = nil
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.journal.rate-limit": "0",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confFile, testutil.FileEquals, "[Journal]\nRateLimitIntervalSec=0\n")
+	c.Check(s.systemctlArgs, HasLen, 3)
+
+	// unsetting everything goes back to the defaults of journald
+	s.systemctlArgs = nil
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf:  map[string]interface{}{},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confFile, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, HasLen, 3)
+
+	// nothing changes, journald is left alone
+	s.systemctlArgs = nil
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf:  map[string]interface{}{},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *journalOptionsSuite) TestConfigureJournalForward(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.journal.forward.host":     "192.168.1.1",
+			"system.journal.forward.protocol": "tcp",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.unitFile, testutil.FileContains, "Description=Forward the journal to 192.168.1.1:514 over tcp (managed by snapd)\n")
+	c.Check(s.unitFile, testutil.FileContains, "ExecStart=/bin/sh -c 'journalctl --follow --lines=0 --output=short-iso | logger --server 192.168.1.1 --port 514 --tcp --rfc5424 --tag journal'\n")
+	c.Check(s.confFile, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"enable", "snapd.journal-forward.service"},
+		{"stop", "snapd.journal-forward.service"},
+		{"show", "--property=ActiveState", "snapd.journal-forward.service"},
+		{"start", "snapd.journal-forward.service"},
+	})
+
+	// stop forwarding
+	s.systemctlArgs = nil
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf:  map[string]interface{}{},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.unitFile, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"disable", "snapd.journal-forward.service"},
+		{"stop", "snapd.journal-forward.service"},
+		{"show", "--property=ActiveState", "snapd.journal-forward.service"},
+		{"daemon-reload"},
+	})
+}
+
+func (s *journalOptionsSuite) TestFilesystemOnlyApply(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.journal.max-size":     "50M",
+		"system.journal.forward.host": "log.example.com",
+		"system.journal.forward.port": "1514",
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(tmpDir, conf, nil), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/systemd/journald.conf.d/20-snapd.conf"), testutil.FileEquals, "[Journal]\nSystemMaxUse=50M\n")
+	c.Check(filepath.Join(tmpDir, "/etc/systemd/system/snapd.journal-forward.service"), testutil.FileContains, "logger --server log.example.com --port 1514 --udp")
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"--root", tmpDir, "enable", "snapd.journal-forward.service"},
+	})
+}
@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+// +build !nomanagers
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+const cmdlineAppendOption = "system.kernel.cmdline-append"
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core."+cmdlineAppendOption] = true
+}
+
+var bootUpdateCommandLineAppend = boot.UpdateCommandLineAppend
+
+func validateCmdlineAppend(tr config.Conf) error {
+	cmdline, err := coreCfg(tr, cmdlineAppendOption)
+	if err != nil {
+		return err
+	}
+	// whether the gadget allows the arguments is checked when applying
+	if _, err := osutil.KernelCommandLineSplit(cmdline); err != nil {
+		return fmt.Errorf("cannot parse %s: %v", cmdlineAppendOption, err)
+	}
+	return nil
+}
+
+// handleCmdlineAppend appends the arguments allowed by the gadget to the
+// kernel command line of the run system, resealing the encryption keys if
+// needed, and requests a reboot for them to take effect.
+func handleCmdlineAppend(tr config.Conf, opts *fsOnlyContext) error {
+	var pristineCmdline, newCmdline string
+	if err := tr.GetPristine("core", cmdlineAppendOption, &pristineCmdline); err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	if err := tr.Get("core", cmdlineAppendOption, &newCmdline); err != nil && !config.IsNoOption(err) {
+		return err
+	}
+	if pristineCmdline == newCmdline {
+		return nil
+	}
+
+	st := tr.State()
+	st.Lock()
+	defer st.Unlock()
+
+	deviceCtx, err := snapstate.DeviceCtx(st, nil, nil)
+	if err != nil {
+		return err
+	}
+	if !deviceCtx.HasModeenv() {
+		return fmt.Errorf("cannot set %s: only supported on Ubuntu Core 20 and later", cmdlineAppendOption)
+	}
+	gadgetInfo, err := snapstate.GadgetInfo(st, deviceCtx)
+	if err != nil {
+		return fmt.Errorf("cannot set %s: %v", cmdlineAppendOption, err)
+	}
+	gadgetDir := gadgetInfo.MountDir()
+	ginfo, err := gadget.ReadInfo(gadgetDir, deviceCtx.Model())
+	if err != nil {
+		return fmt.Errorf("cannot set %s: %v", cmdlineAppendOption, err)
+	}
+	cmdline, err := gadget.CheckKernelCommandLineAppend(newCmdline, ginfo)
+	if err != nil {
+		return fmt.Errorf("cannot set %s: %v", cmdlineAppendOption, err)
+	}
+
+	needsReboot, err := bootUpdateCommandLineAppend(deviceCtx, gadgetDir, cmdline)
+	if err != nil {
+		return fmt.Errorf("cannot update kernel command line: %v", err)
+	}
+	if needsReboot {
+		// the new command line takes effect on the next boot
+		st.RequestRestart(state.RestartSystem)
+	}
+	return nil
+}
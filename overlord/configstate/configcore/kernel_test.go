@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/boot/boottest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type kernelCmdlineSuite struct {
+	configcoreSuite
+
+	gadgetDir string
+	updates   []string
+	reboot    bool
+}
+
+var _ = Suite(&kernelCmdlineSuite{})
+
+const mockCmdlineGadgetYaml = `
+volumes:
+  pc:
+    bootloader: grub
+kernel-cmdline:
+  allow:
+    - quiet
+    - isolcpus=*
+`
+
+func (s *kernelCmdlineSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "etc"), 0755), IsNil)
+	s.AddCleanup(release.MockOnClassic(false))
+	s.AddCleanup(snapstatetest.MockDeviceModel(boottest.MakeMockUC20Model()))
+
+	si := &snap.SideInfo{RealName: "pc", Revision: snap.R(1)}
+	info := snaptest.MockSnapWithFiles(c, "name: pc\ntype: gadget\nversion: 1.0", si, [][]string{
+		{"meta/gadget.yaml", mockCmdlineGadgetYaml},
+	})
+	s.gadgetDir = info.MountDir()
+	s.state.Lock()
+	snapstate.Set(s.state, "pc", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "gadget",
+	})
+	s.state.Unlock()
+
+	s.updates = nil
+	s.reboot = true
+	s.AddCleanup(configcore.MockBootUpdateCommandLineAppend(func(dev boot.Device, gadgetSnapOrDir, cmdlineAppend string) (bool, error) {
+		c.Check(dev.HasModeenv(), Equals, true)
+		c.Check(gadgetSnapOrDir, Equals, s.gadgetDir)
+		s.updates = append(s.updates, cmdlineAppend)
+		return s.reboot, nil
+	}))
+}
+
+func (s *kernelCmdlineSuite) TestCmdlineAppend(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet  isolcpus=1,2",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.updates, DeepEquals, []string{"quiet isolcpus=1,2"})
+
+	// unsetting it drops the arguments
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet isolcpus=1,2",
+		},
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.updates, DeepEquals, []string{"quiet isolcpus=1,2", ""})
+}
+
+func (s *kernelCmdlineSuite) TestCmdlineAppendUnchanged(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.updates, HasLen, 0)
+}
+
+func (s *kernelCmdlineSuite) TestCmdlineAppendNotAllowed(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet splash",
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot set system.kernel.cmdline-append: kernel argument "splash" is not allowed by the gadget`)
+	c.Check(s.updates, HasLen, 0)
+}
+
+func (s *kernelCmdlineSuite) TestCmdlineAppendInvalid(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": `foo="bar`,
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot parse system.kernel.cmdline-append: unbalanced quoting`)
+	c.Check(s.updates, HasLen, 0)
+}
+
+func (s *kernelCmdlineSuite) TestCmdlineAppendNotUC20(c *C) {
+	defer snapstatetest.MockDeviceModel(boottest.MakeMockModel())()
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet",
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot set system.kernel.cmdline-append: only supported on Ubuntu Core 20 and later`)
+	c.Check(s.updates, HasLen, 0)
+}
@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.system.locale"] = true
+	supportedConfigurations["core.system.keyboard.layout"] = true
+}
+
+var (
+	// e.g. C, C.UTF-8, en_US.UTF-8, ca_ES.UTF-8@valencia
+	validLocale = regexp.MustCompile(`^[a-zA-Z]{1,3}(_[a-zA-Z]{2})?(\.[a-zA-Z0-9-]+)?(@[a-zA-Z0-9]+)?$`).MatchString
+	// XKB layout names, e.g. us, de, gb
+	validKeyboardLayout = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`).MatchString
+)
+
+func validateLocaleSettings(tr config.ConfGetter) error {
+	locale, err := coreCfg(tr, "system.locale")
+	if err != nil {
+		return err
+	}
+	if locale != "" && !validLocale(locale) {
+		return fmt.Errorf("cannot set locale %q: name not valid", locale)
+	}
+	layout, err := coreCfg(tr, "system.keyboard.layout")
+	if err != nil {
+		return err
+	}
+	if layout != "" && !validKeyboardLayout(layout) {
+		return fmt.Errorf("cannot set keyboard layout %q: name not valid", layout)
+	}
+	return nil
+}
+
+// writeDefaultsFile writes the given variable to a file in /etc/default,
+// where they are read from on Ubuntu.
+func writeDefaultsFile(rootDir, name, variable, value string) error {
+	path := filepath.Join(rootDir, "/etc/default", name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content := fmt.Sprintf("%s=%q\n", variable, value)
+	return osutil.AtomicWriteFile(path, []byte(content), 0644, 0)
+}
+
+func handleLocaleConfiguration(tr config.ConfGetter, opts *fsOnlyContext) error {
+	locale, err := coreCfg(tr, "system.locale")
+	if err != nil {
+		return err
+	}
+	layout, err := coreCfg(tr, "system.keyboard.layout")
+	if err != nil {
+		return err
+	}
+
+	// runtime system
+	if opts == nil {
+		if locale != "" {
+			output, err := exec.Command("localectl", "set-locale", "LANG="+locale).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("cannot set locale: %v", osutil.OutputErr(output, err))
+			}
+		}
+		if layout != "" {
+			output, err := exec.Command("localectl", "set-x11-keymap", layout).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("cannot set keyboard layout: %v", osutil.OutputErr(output, err))
+			}
+		}
+		return nil
+	}
+
+	if locale != "" {
+		if err := writeDefaultsFile(opts.RootDir, "locale", "LANG", locale); err != nil {
+			return fmt.Errorf("cannot write locale: %v", err)
+		}
+	}
+	if layout != "" {
+		if err := writeDefaultsFile(opts.RootDir, "keyboard", "XKBLAYOUT", layout); err != nil {
+			return fmt.Errorf("cannot write keyboard layout: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type localeSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&localeSuite{})
+
+func (s *localeSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "etc"), 0755), IsNil)
+}
+
+func (s *localeSuite) TestConfigureLocaleInvalid(c *C) {
+	for _, locale := range []string{"en_US UTF-8", "en_US;", "../../etc"} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.locale": locale,
+			},
+		})
+		c.Assert(err, ErrorMatches, `cannot set locale .*: name not valid`)
+	}
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.keyboard.layout": "us;de",
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot set keyboard layout "us;de": name not valid`)
+}
+
+func (s *localeSuite) TestConfigureLocaleIntegration(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	mockedLocalectl := testutil.MockCommand(c, "localectl", "")
+	defer mockedLocalectl.Restore()
+
+	for _, locale := range []string{"C", "C.UTF-8", "en_US.UTF-8", "ca_ES.UTF-8@valencia", "ast_ES.UTF-8"} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.locale":          locale,
+				"system.keyboard.layout": "de",
+			},
+		})
+		c.Assert(err, IsNil)
+		c.Check(mockedLocalectl.Calls(), DeepEquals, [][]string{
+			{"localectl", "set-locale", "LANG=" + locale},
+			{"localectl", "set-x11-keymap", "de"},
+		})
+		mockedLocalectl.ForgetCalls()
+	}
+}
+
+func (s *localeSuite) TestFilesystemOnlyApply(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.locale":          "de_DE.UTF-8",
+		"system.keyboard.layout": "de",
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(tmpDir, conf, nil), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/default/locale"), testutil.FileEquals, "LANG=\"de_DE.UTF-8\"\n")
+	c.Check(filepath.Join(tmpDir, "/etc/default/keyboard"), testutil.FileEquals, "XKBLAYOUT=\"de\"\n")
+}
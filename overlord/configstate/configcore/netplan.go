@@ -0,0 +1,480 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+)
+
+// The system.network.netplan option holds a netplan configuration, as in
+// its YAML files, e.g.:
+//
+//	snap set system system.network.netplan.network.ethernets.eth0.dhcp4=true
+//
+// It is written to its own netplan file, and applied, reverting to the
+// previous configuration if the device loses connectivity because of it.
+const netplanOption = "system.network.netplan"
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core."+netplanOption] = true
+}
+
+const netplanConfFile = "90-snapd-config.yaml"
+
+var (
+	netplanCheckConnectivity = checkConnectivity
+	// netplanStoreURL returns the URL of the store the device uses, it
+	// takes proxy.store into account when snapd runs with its managers
+	netplanStoreURL = func(tr config.ConfGetter) (*url.URL, error) {
+		return url.Parse("https://api.snapcraft.io/")
+	}
+	// netplanConnectivityTimeout is how long the network has to come
+	// back after applying a new configuration
+	netplanConnectivityTimeout = 30 * time.Second
+	netplanConnectivityRetry   = 2 * time.Second
+)
+
+// urlAddr returns the host and port the given URL points at.
+func urlAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// connectivityCheckAddr returns what is connected to in order to check
+// that the device can still be managed remotely: the proxy the device goes
+// through, if any, or the store it uses otherwise.
+func connectivityCheckAddr(tr config.ConfGetter) (string, error) {
+	for _, opt := range []string{"proxy.https", "proxy.http"} {
+		proxy, err := coreCfg(tr, opt)
+		if err != nil {
+			return "", err
+		}
+		if proxy == "" {
+			continue
+		}
+		proxyURL := proxy
+		if !strings.Contains(proxyURL, "://") {
+			proxyURL = "http://" + proxyURL
+		}
+		u, err := url.Parse(proxyURL)
+		if err != nil || u.Hostname() == "" {
+			return "", fmt.Errorf("cannot use %s %q to check connectivity", opt, proxy)
+		}
+		return urlAddr(u), nil
+	}
+
+	storeURL, err := netplanStoreURL(tr)
+	if err != nil {
+		return "", err
+	}
+	return urlAddr(storeURL), nil
+}
+
+func checkConnectivity(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// netplanDeviceTypes are the kinds of devices that can be configured.
+var netplanDeviceTypes = map[string]bool{
+	"ethernets": true,
+	"wifis":     true,
+	"bridges":   true,
+	"bonds":     true,
+	"vlans":     true,
+}
+
+// normalizeNetplanValue returns the value with the JSON numbers of the
+// configuration turned into numbers, and the maps decoded from YAML into
+// maps with string keys, so that it can be written as YAML.
+func normalizeNetplanValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			norm, err := normalizeNetplanValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = norm
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected key %v", k)
+			}
+			norm, err := normalizeNetplanValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = norm
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			norm, err := normalizeNetplanValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = norm
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// netplanConfig returns the netplan configuration set in the
+// system.network.netplan option, if any.
+func netplanConfig(tr config.ConfGetter) (map[string]interface{}, error) {
+	var value interface{}
+	if err := tr.Get("core", netplanOption, &value); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if value == nil {
+		return nil, nil
+	}
+	norm, err := normalizeNetplanValue(value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use network configuration: %v", err)
+	}
+	cfg, ok := norm.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot use network configuration: expected a map, got %T", value)
+	}
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+	return cfg, nil
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validateIPs(where string, value interface{}) error {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("%s must be a list", where)
+	}
+	for _, elem := range list {
+		s, _ := elem.(string)
+		if net.ParseIP(s) == nil {
+			return fmt.Errorf("%s: invalid IP address %v", where, elem)
+		}
+	}
+	return nil
+}
+
+func validateNetplanDevice(where string, device map[string]interface{}) error {
+	for _, key := range sortedMapKeys(device) {
+		value := device[key]
+		switch key {
+		case "dhcp4", "dhcp6":
+			if _, ok := value.(bool); !ok {
+				return fmt.Errorf("%s.%s must be true or false", where, key)
+			}
+		case "addresses":
+			list, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s.addresses must be a list", where)
+			}
+			for _, elem := range list {
+				s, _ := elem.(string)
+				if _, _, err := net.ParseCIDR(s); err != nil {
+					return fmt.Errorf("%s.addresses: invalid address %v (expected address/prefix)", where, elem)
+				}
+			}
+		case "gateway4", "gateway6":
+			s, _ := value.(string)
+			if net.ParseIP(s) == nil {
+				return fmt.Errorf("%s.%s: invalid IP address %v", where, key, value)
+			}
+		case "nameservers":
+			nameservers, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s.nameservers must be a map", where)
+			}
+			if addrs, ok := nameservers["addresses"]; ok {
+				if err := validateIPs(where+".nameservers.addresses", addrs); err != nil {
+					return err
+				}
+			}
+		case "routes":
+			routes, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s.routes must be a list", where)
+			}
+			for _, elem := range routes {
+				route, ok := elem.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("%s.routes must be a list of maps", where)
+				}
+				to, _ := route["to"].(string)
+				if _, _, err := net.ParseCIDR(to); err != nil && to != "default" {
+					return fmt.Errorf("%s.routes: invalid destination %v", where, route["to"])
+				}
+				via, _ := route["via"].(string)
+				if net.ParseIP(via) == nil {
+					return fmt.Errorf("%s.routes: invalid gateway %v", where, route["via"])
+				}
+			}
+		case "access-points":
+			if _, ok := value.(map[string]interface{}); !ok {
+				return fmt.Errorf("%s.access-points must be a map", where)
+			}
+		}
+		// anything else is left for netplan to check
+	}
+	return nil
+}
+
+func validateNetplanConfig(cfg map[string]interface{}) error {
+	for _, key := range sortedMapKeys(cfg) {
+		if key != "network" {
+			return fmt.Errorf("unsupported key %q (expected network)", key)
+		}
+	}
+	network, ok := cfg["network"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("network must be a map")
+	}
+	for _, key := range sortedMapKeys(network) {
+		value := network[key]
+		switch {
+		case key == "version":
+			if v, ok := value.(int64); !ok || v != 2 {
+				return fmt.Errorf("unsupported version %v (expected 2)", value)
+			}
+		case key == "renderer":
+			if value != "networkd" && value != "NetworkManager" {
+				return fmt.Errorf("unsupported renderer %v", value)
+			}
+		case netplanDeviceTypes[key]:
+			devices, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("network.%s must be a map", key)
+			}
+			for _, name := range sortedMapKeys(devices) {
+				device, ok := devices[name].(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("network.%s.%s must be a map", key, name)
+				}
+				if err := validateNetplanDevice(fmt.Sprintf("network.%s.%s", key, name), device); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unsupported key %q in network", key)
+		}
+	}
+	return nil
+}
+
+func validateNetplanSettings(tr config.ConfGetter) error {
+	cfg, err := netplanConfig(tr)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return nil
+	}
+	if err := validateNetplanConfig(cfg); err != nil {
+		return fmt.Errorf("cannot use network configuration: %v", err)
+	}
+	return nil
+}
+
+func netplanContent(cfg map[string]interface{}) ([]byte, error) {
+	network := cfg["network"].(map[string]interface{})
+	if _, ok := network["version"]; !ok {
+		network["version"] = 2
+	}
+	content, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("# generated by snapd from the system.network.netplan option, do not edit\n"), content...), nil
+}
+
+func runNetplan(args ...string) error {
+	output, err := exec.Command("netplan", args...).CombinedOutput()
+	if err != nil {
+		return osutil.OutputErr(output, err)
+	}
+	return nil
+}
+
+// applyNetplan applies the netplan configuration, after checking it.
+func applyNetplan() error {
+	if err := runNetplan("generate"); err != nil {
+		return fmt.Errorf("cannot generate network configuration: %v", err)
+	}
+	if err := runNetplan("apply"); err != nil {
+		return fmt.Errorf("cannot apply network configuration: %v", err)
+	}
+	return nil
+}
+
+func waitForConnectivity(addr string) bool {
+	deadline := time.Now().Add(netplanConnectivityTimeout)
+	for {
+		if netplanCheckConnectivity(addr) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(netplanConnectivityRetry)
+	}
+}
+
+func handleNetplanConfiguration(tr config.ConfGetter, opts *fsOnlyContext) error {
+	cfg, err := netplanConfig(tr)
+	if err != nil {
+		return err
+	}
+
+	rootDir := dirs.GlobalRootDir
+	if opts != nil {
+		rootDir = opts.RootDir
+	}
+	dir := filepath.Join(rootDir, "/etc/netplan")
+	path := filepath.Join(dir, netplanConfFile)
+
+	// keep the previous configuration around to go back to it
+	previous, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	dirContent := make(map[string]osutil.FileState, 1)
+	var content []byte
+	if cfg != nil {
+		content, err = netplanContent(cfg)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		// the configuration may hold Wi-Fi passwords
+		dirContent[netplanConfFile] = &osutil.MemoryFileState{
+			Content: content,
+			Mode:    0600,
+		}
+	}
+
+	var checkAddr string
+	hadConnectivity := false
+	if opts == nil && !bytes.Equal(content, previous) {
+		checkAddr, err = connectivityCheckAddr(tr)
+		if err != nil {
+			return err
+		}
+		hadConnectivity = netplanCheckConnectivity(checkAddr)
+		if !hadConnectivity {
+			logger.Noticef("cannot reach %s, the network configuration will not be rolled back on connectivity loss", checkAddr)
+		}
+	}
+
+	changed, removed, err := osutil.EnsureDirState(dir, netplanConfFile, dirContent)
+	if err != nil {
+		return err
+	}
+	if opts != nil || (len(changed) == 0 && len(removed) == 0) {
+		return nil
+	}
+
+	restore := func() error {
+		if previous == nil {
+			err := os.Remove(path)
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		return osutil.AtomicWriteFile(path, previous, 0600, 0)
+	}
+
+	if err := applyNetplan(); err != nil {
+		if rerr := restore(); rerr != nil {
+			logger.Noticef("cannot restore previous network configuration: %v", rerr)
+		}
+		return err
+	}
+	if !hadConnectivity || waitForConnectivity(checkAddr) {
+		return nil
+	}
+
+	// the new configuration cut the device off, go back to the
+	// previous one
+	if err := restore(); err != nil {
+		return fmt.Errorf("cannot restore previous network configuration after connectivity loss: %v", err)
+	}
+	if err := applyNetplan(); err != nil {
+		return fmt.Errorf("cannot restore previous network configuration after connectivity loss: %v", err)
+	}
+	return fmt.Errorf("cannot use network configuration: connectivity was lost, reverted to the previous configuration")
+}
@@ -0,0 +1,289 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type netplanSuite struct {
+	configcoreSuite
+
+	confFile          string
+	mockNetplan       *testutil.MockCmd
+	connectivity      []bool
+	connectivityAddrs []string
+}
+
+var _ = Suite(&netplanSuite{})
+
+func (s *netplanSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	s.AddCleanup(release.MockOnClassic(false))
+
+	s.confFile = filepath.Join(dirs.GlobalRootDir, "/etc/netplan/90-snapd-config.yaml")
+	s.mockNetplan = testutil.MockCommand(c, "netplan", "")
+	s.AddCleanup(s.mockNetplan.Restore)
+
+	s.connectivity = nil
+	s.connectivityAddrs = nil
+	s.AddCleanup(configcore.MockNetplanCheckConnectivity(func(addr string) bool {
+		s.connectivityAddrs = append(s.connectivityAddrs, addr)
+		if len(s.connectivity) == 0 {
+			return true
+		}
+		connected := s.connectivity[0]
+		// the last state stays
+		if len(s.connectivity) > 1 {
+			s.connectivity = s.connectivity[1:]
+		}
+		return connected
+	}))
+	s.AddCleanup(configcore.MockNetplanConnectivityTimeout(10*time.Millisecond, time.Millisecond))
+}
+
+var staticEth0 = map[string]interface{}{
+	"network": map[string]interface{}{
+		"ethernets": map[string]interface{}{
+			"eth0": map[string]interface{}{
+				"addresses": []interface{}{"192.168.1.10/24"},
+				"gateway4":  "192.168.1.1",
+				"nameservers": map[string]interface{}{
+					"addresses": []interface{}{"192.168.1.1"},
+				},
+			},
+		},
+	},
+}
+
+const staticEth0Yaml = `# generated by snapd from the system.network.netplan option, do not edit
+network:
+  ethernets:
+    eth0:
+      addresses:
+      - 192.168.1.10/24
+      gateway4: 192.168.1.1
+      nameservers:
+        addresses:
+        - 192.168.1.1
+  version: 2
+`
+
+func (s *netplanSuite) TestConfigureNetplanInvalid(c *C) {
+	for _, t := range []struct {
+		conf interface{}
+		err  string
+	}{
+		{map[string]interface{}{"foo": 1}, `unsupported key "foo" \(expected network\)`},
+		{map[string]interface{}{"network": "foo"}, `network must be a map`},
+		{map[string]interface{}{"network": map[string]interface{}{"version": 1}}, `unsupported version 1 \(expected 2\)`},
+		{map[string]interface{}{"network": map[string]interface{}{"renderer": "foo"}}, `unsupported renderer foo`},
+		{map[string]interface{}{"network": map[string]interface{}{"tunnels": map[string]interface{}{}}}, `unsupported key "tunnels" in network`},
+		{map[string]interface{}{"network": map[string]interface{}{"ethernets": map[string]interface{}{"eth0": "foo"}}}, `network.ethernets.eth0 must be a map`},
+		{map[string]interface{}{"network": map[string]interface{}{"ethernets": map[string]interface{}{
+			"eth0": map[string]interface{}{"dhcp4": "yes"}}}}, `network.ethernets.eth0.dhcp4 must be true or false`},
+		{map[string]interface{}{"network": map[string]interface{}{"ethernets": map[string]interface{}{
+			"eth0": map[string]interface{}{"addresses": []interface{}{"192.168.1.10"}}}}}, `network.ethernets.eth0.addresses: invalid address 192.168.1.10 \(expected address/prefix\)`},
+		{map[string]interface{}{"network": map[string]interface{}{"ethernets": map[string]interface{}{
+			"eth0": map[string]interface{}{"gateway4": "foo"}}}}, `network.ethernets.eth0.gateway4: invalid IP address foo`},
+		{map[string]interface{}{"network": map[string]interface{}{"ethernets": map[string]interface{}{
+			"eth0": map[string]interface{}{"nameservers": map[string]interface{}{"addresses": []interface{}{"1.2.3"}}}}}}, `network.ethernets.eth0.nameservers.addresses: invalid IP address 1.2.3`},
+		{map[string]interface{}{"network": map[string]interface{}{"ethernets": map[string]interface{}{
+			"eth0": map[string]interface{}{"routes": []interface{}{map[string]interface{}{"to": "10.0.0.0/8", "via": "foo"}}}}}}, `network.ethernets.eth0.routes: invalid gateway foo`},
+		{map[string]interface{}{"network": map[string]interface{}{"wifis": map[string]interface{}{
+			"wlan0": map[string]interface{}{"access-points": "foo"}}}}, `network.wifis.wlan0.access-points must be a map`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.network.netplan": t.conf,
+			},
+		})
+		c.Check(err, ErrorMatches, "cannot use network configuration: "+t.err, Commentf("%v", t.conf))
+	}
+	c.Check(s.confFile, testutil.FileAbsent)
+	c.Check(s.mockNetplan.Calls(), HasLen, 0)
+}
+
+func (s *netplanSuite) TestConfigureNetplan(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": staticEth0,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confFile, testutil.FileEquals, staticEth0Yaml)
+	st, err := os.Stat(s.confFile)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+	c.Check(s.mockNetplan.Calls(), DeepEquals, [][]string{
+		{"netplan", "generate"},
+		{"netplan", "apply"},
+	})
+
+	// nothing changes, the network is left alone
+	s.mockNetplan.ForgetCalls()
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": staticEth0,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.mockNetplan.Calls(), HasLen, 0)
+
+	// unsetting the option removes the configuration
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf:  map[string]interface{}{},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confFile, testutil.FileAbsent)
+	c.Check(s.mockNetplan.Calls(), DeepEquals, [][]string{
+		{"netplan", "generate"},
+		{"netplan", "apply"},
+	})
+}
+
+func (s *netplanSuite) TestConfigureNetplanGenerateFails(c *C) {
+	s.mockNetplan = testutil.MockCommand(c, "netplan", `
+if [ "$1" = generate ]; then
+    echo "eth0: unknown key 'foo'"
+    exit 1
+fi
+`)
+	defer s.mockNetplan.Restore()
+
+	c.Assert(os.MkdirAll(filepath.Dir(s.confFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.confFile, []byte("previous"), 0600), IsNil)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": staticEth0,
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot generate network configuration: eth0: unknown key 'foo'`)
+	c.Check(s.confFile, testutil.FileEquals, "previous")
+	c.Check(s.mockNetplan.Calls(), DeepEquals, [][]string{
+		{"netplan", "generate"},
+	})
+}
+
+func (s *netplanSuite) TestConfigureNetplanRollbackOnConnectivityLoss(c *C) {
+	// connected before, never after
+	s.connectivity = []bool{true, false}
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": staticEth0,
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot use network configuration: connectivity was lost, reverted to the previous configuration`)
+	c.Check(s.confFile, testutil.FileAbsent)
+	c.Check(s.mockNetplan.Calls(), DeepEquals, [][]string{
+		{"netplan", "generate"},
+		{"netplan", "apply"},
+		{"netplan", "generate"},
+		{"netplan", "apply"},
+	})
+}
+
+func (s *netplanSuite) TestConfigureNetplanNoRollbackWhenNotConnected(c *C) {
+	// there was no connectivity to lose to begin with
+	s.connectivity = []bool{false}
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": staticEth0,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confFile, testutil.FileEquals, staticEth0Yaml)
+	c.Check(s.mockNetplan.Calls(), HasLen, 2)
+	c.Check(logbuf.String(), testutil.Contains, "cannot reach api.snapcraft.io:443, the network configuration will not be rolled back on connectivity loss")
+}
+
+func (s *netplanSuite) TestConfigureNetplanConnectivityCheckAddr(c *C) {
+	// the proxy settings are written to /etc/environment
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "etc"), 0755), IsNil)
+
+	for _, tc := range []struct {
+		conf map[string]interface{}
+		addr string
+	}{
+		{map[string]interface{}{}, "api.snapcraft.io:443"},
+		{map[string]interface{}{"proxy.http": "http://proxy.example.com:3128"}, "proxy.example.com:3128"},
+		{map[string]interface{}{"proxy.http": "http://proxy.example.com"}, "proxy.example.com:80"},
+		{map[string]interface{}{"proxy.https": "https://secure.example.com", "proxy.http": "http://proxy.example.com"}, "secure.example.com:443"},
+		{map[string]interface{}{"proxy.https": "proxy.example.com:3128"}, "proxy.example.com:3128"},
+	} {
+		c.Assert(os.RemoveAll(s.confFile), IsNil)
+		s.connectivityAddrs = nil
+
+		tc.conf["system.network.netplan"] = staticEth0
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf:  tc.conf,
+		})
+		c.Assert(err, IsNil)
+		c.Check(s.connectivityAddrs, DeepEquals, []string{tc.addr}, Commentf("%v", tc.conf))
+	}
+}
+
+func (s *netplanSuite) TestFilesystemOnlyApply(c *C) {
+	// gadget defaults come flattened
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.network.netplan.network.ethernets.eth0.addresses":             []interface{}{"192.168.1.10/24"},
+		"system.network.netplan.network.ethernets.eth0.gateway4":              "192.168.1.1",
+		"system.network.netplan.network.ethernets.eth0.nameservers.addresses": []interface{}{"192.168.1.1"},
+		"system.network.netplan.network.version":                              2,
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(tmpDir, conf, nil), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/netplan/90-snapd-config.yaml"), testutil.FileEquals, staticEth0Yaml)
+	c.Check(s.mockNetplan.Calls(), HasLen, 0)
+}
+
+func (s *netplanSuite) TestFilesystemOnlyApplyInvalid(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.network.netplan.network.ethernets.eth0.dhcp4": "maybe",
+	})
+	tmpDir := c.MkDir()
+	err := configcore.FilesystemOnlyApply(tmpDir, conf, nil)
+	c.Assert(err, ErrorMatches, `cannot use network configuration: network.ethernets.eth0.dhcp4 must be true or false`)
+}
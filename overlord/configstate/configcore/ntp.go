@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/systemd"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.system.ntp.servers"] = true
+}
+
+const timesyncdConfFile = "20-snapd-ntp.conf"
+
+func timesyncdConfDir(rootDir string) string {
+	return filepath.Join(rootDir, "/etc/systemd/timesyncd.conf.d")
+}
+
+// ntpServers returns the NTP servers set in the comma separated
+// system.ntp.servers option.
+func ntpServers(tr config.ConfGetter) ([]string, error) {
+	output, err := coreCfg(tr, "system.ntp.servers")
+	if err != nil {
+		return nil, err
+	}
+	var servers []string
+	for _, server := range strings.Split(output, ",") {
+		server = strings.TrimSpace(server)
+		if server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+func validateNTPSettings(tr config.ConfGetter) error {
+	servers, err := ntpServers(tr)
+	if err != nil {
+		return err
+	}
+	for _, server := range servers {
+		if net.ParseIP(server) != nil {
+			continue
+		}
+		if err := validateHostname(server); err != nil {
+			return fmt.Errorf("cannot set NTP server %q: name not valid", server)
+		}
+	}
+	return nil
+}
+
+func handleNTPConfiguration(tr config.ConfGetter, opts *fsOnlyContext) error {
+	servers, err := ntpServers(tr)
+	if err != nil {
+		return err
+	}
+
+	rootDir := dirs.GlobalRootDir
+	if opts != nil {
+		rootDir = opts.RootDir
+	}
+	dir := timesyncdConfDir(rootDir)
+
+	// without servers set, those of the system are used
+	dirContent := make(map[string]osutil.FileState, 1)
+	if len(servers) > 0 {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		dirContent[timesyncdConfFile] = &osutil.MemoryFileState{
+			Content: []byte(fmt.Sprintf("[Time]\nNTP=%s\n", strings.Join(servers, " "))),
+			Mode:    0644,
+		}
+	}
+	changed, removed, err := osutil.EnsureDirState(dir, timesyncdConfFile, dirContent)
+	if err != nil {
+		return err
+	}
+	if opts != nil || (len(changed) == 0 && len(removed) == 0) {
+		return nil
+	}
+
+	// timesyncd only reads its configuration when starting; leave it
+	// alone if it is not in use though
+	sysd := systemd.New(systemd.SystemMode, &sysdLogger{})
+	active, err := sysd.IsActive("systemd-timesyncd.service")
+	if err != nil {
+		return err
+	}
+	if !active {
+		return nil
+	}
+	return sysd.Restart("systemd-timesyncd.service", 60*time.Second)
+}
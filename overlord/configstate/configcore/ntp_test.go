@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type ntpSuite struct {
+	configcoreSuite
+
+	confFile string
+}
+
+var _ = Suite(&ntpSuite{})
+
+func (s *ntpSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	s.confFile = filepath.Join(dirs.GlobalRootDir, "/etc/systemd/timesyncd.conf.d/20-snapd-ntp.conf")
+}
+
+func (s *ntpSuite) TestConfigureNTPServersInvalid(c *C) {
+	for _, servers := range []string{"not_valid", "pool.ntp.org,-bad"} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.ntp.servers": servers,
+			},
+		})
+		c.Assert(err, ErrorMatches, `cannot set NTP server ".*": name not valid`)
+	}
+}
+
+func (s *ntpSuite) TestConfigureNTPServers(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.ntp.servers": "ntp1.example.com, 192.168.1.1,fd00::1",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confFile, testutil.FileEquals, "[Time]\nNTP=ntp1.example.com 192.168.1.1 fd00::1\n")
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"is-active", "systemd-timesyncd.service"},
+		{"stop", "systemd-timesyncd.service"},
+		{"show", "--property=ActiveState", "systemd-timesyncd.service"},
+		{"start", "systemd-timesyncd.service"},
+	})
+
+	// nothing changes, timesyncd is left alone
+	s.systemctlArgs = nil
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.ntp.servers": "ntp1.example.com, 192.168.1.1,fd00::1",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+
+	// unsetting the servers goes back to those of the system
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf:  map[string]interface{}{},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confFile, testutil.FileAbsent)
+	c.Check(s.systemctlArgs, HasLen, 4)
+}
+
+func (s *ntpSuite) TestFilesystemOnlyApply(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.ntp.servers": "ntp.example.com",
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(tmpDir, conf, nil), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/systemd/timesyncd.conf.d/20-snapd-ntp.conf"), testutil.FileEquals, "[Time]\nNTP=ntp.example.com\n")
+}
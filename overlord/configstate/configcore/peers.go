@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.store.peers.enabled"] = true
+	supportedConfigurations["core.store.peers.advertise"] = true
+	supportedConfigurations["core.store.peers.port"] = true
+}
+
+func validateStorePeers(tr config.Conf) error {
+	for _, flag := range []string{"store.peers.enabled", "store.peers.advertise"} {
+		if err := validateBoolFlag(tr, flag); err != nil {
+			return err
+		}
+	}
+
+	portStr, err := coreCfg(tr, "store.peers.port")
+	if err != nil {
+		return err
+	}
+	if portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("store.peers.port must be a port number between 1 and 65535, not %q", portStr)
+		}
+	}
+	return nil
+}
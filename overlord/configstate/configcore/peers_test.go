@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type peersSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&peersSuite{})
+
+func (s *peersSuite) TestConfigureStorePeersHappy(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.peers.enabled":   "true",
+			"store.peers.advertise": "false",
+			"store.peers.port":      "8738",
+		},
+	})
+	c.Check(err, IsNil)
+}
+
+func (s *peersSuite) TestConfigureStorePeersInvalid(c *C) {
+	for _, t := range []struct {
+		key, value, err string
+	}{
+		{"store.peers.enabled", "yes", `store.peers.enabled can only be set to 'true' or 'false'`},
+		{"store.peers.advertise", "1", `store.peers.advertise can only be set to 'true' or 'false'`},
+		{"store.peers.port", "http", `store.peers.port must be a port number between 1 and 65535, not "http"`},
+		{"store.peers.port", "0", `store.peers.port must be a port number between 1 and 65535, not "0"`},
+		{"store.peers.port", "65536", `store.peers.port must be a port number between 1 and 65535, not "65536"`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				t.key: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%s", t.key, t.value))
+	}
+}
@@ -23,6 +23,7 @@ package configcore
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -31,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/store"
 )
 
 var proxyConfigKeys = map[string]bool{
@@ -47,6 +49,8 @@ func init() {
 	supportedConfigurations["core.proxy.ftp"] = true
 	supportedConfigurations["core.proxy.no-proxy"] = true
 	supportedConfigurations["core.proxy.store"] = true
+
+	netplanStoreURL = proxyStoreURL
 }
 
 func etcEnvironment() string {
@@ -120,3 +124,29 @@ func validateProxyStore(tr config.Conf) error {
 	}
 	return err
 }
+
+// proxyStoreURL returns the URL of the store the device uses, either the
+// one set with proxy.store or the default one, which can be overridden
+// with SNAPPY_FORCE_API_URL.
+func proxyStoreURL(tr config.ConfGetter) (*url.URL, error) {
+	proxyStore, err := coreCfg(tr, "proxy.store")
+	if err != nil {
+		return nil, err
+	}
+	conf, ok := tr.(config.Conf)
+	if !ok || proxyStore == "" {
+		return store.DefaultConfig().StoreBaseURL, nil
+	}
+
+	st := conf.State()
+	st.Lock()
+	defer st.Unlock()
+	storeAs, err := assertstate.Store(st, proxyStore)
+	if err != nil {
+		return nil, err
+	}
+	if storeAs.URL() == nil {
+		return store.DefaultConfig().StoreBaseURL, nil
+	}
+	return storeAs.URL(), nil
+}
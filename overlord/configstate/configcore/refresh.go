@@ -22,11 +22,13 @@ package configcore
 
 import (
 	"fmt"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timeutil"
 )
@@ -38,6 +40,9 @@ func init() {
 	supportedConfigurations["core.refresh.metered"] = true
 	supportedConfigurations["core.refresh.retain"] = true
 	supportedConfigurations["core.refresh.rate-limit"] = true
+	supportedConfigurations["core.refresh.health-grace-period"] = true
+	supportedConfigurations["core.refresh.windows"] = true
+	supportedConfigurations["core.refresh.coordinator"] = true
 }
 
 func reportOrIgnoreInvalidManageRefreshes(tr config.Conf, optName string) error {
@@ -146,3 +151,41 @@ func validateRefreshRateLimit(tr config.Conf) error {
 	}
 	return nil
 }
+
+func validateRefreshHealthGracePeriod(tr config.Conf) error {
+	graceStr, err := coreCfg(tr, "refresh.health-grace-period")
+	if err != nil {
+		return err
+	}
+	if graceStr == "" {
+		return nil
+	}
+	grace, err := time.ParseDuration(graceStr)
+	if err != nil {
+		return fmt.Errorf("refresh.health-grace-period cannot be parsed: %v", err)
+	}
+	if grace < 0 {
+		return fmt.Errorf("refresh.health-grace-period cannot be negative")
+	}
+	return nil
+}
+
+func validateRefreshWindows(tr config.Conf) error {
+	_, err := snapstate.ParseRefreshWindows(tr)
+	return err
+}
+
+func validateRefreshCoordinator(tr config.Conf) error {
+	coordURL, err := coreCfg(tr, "refresh.coordinator")
+	if err != nil {
+		return err
+	}
+	if coordURL == "" {
+		return nil
+	}
+	u, err := url.Parse(coordURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("refresh.coordinator must be a http or https URL, not %q", coordURL)
+	}
+	return nil
+}
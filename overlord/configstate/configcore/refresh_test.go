@@ -209,3 +209,89 @@ func (s *refreshSuite) TestConfigureRefreshRetainInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `retain must be a number between 2 and 20, not "invalid"`)
 }
+
+func (s *refreshSuite) TestConfigureRefreshHealthGracePeriodHappy(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"refresh.health-grace-period": "2m",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *refreshSuite) TestConfigureRefreshHealthGracePeriodInvalid(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"refresh.health-grace-period": "a while",
+		},
+	})
+	c.Assert(err, ErrorMatches, `refresh.health-grace-period cannot be parsed: .*`)
+
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"refresh.health-grace-period": "-1m",
+		},
+	})
+	c.Assert(err, ErrorMatches, `refresh.health-grace-period cannot be negative`)
+}
+
+func (s *refreshSuite) TestConfigureRefreshWindowsHappy(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"refresh.windows.db.timer": "sun,02:00-04:00",
+			"refresh.windows.db.snaps": "postgres",
+		},
+		conf: map[string]interface{}{
+			"refresh.windows": map[string]interface{}{
+				"db": map[string]interface{}{
+					"timer": "sun,02:00-04:00",
+					"snaps": "postgres",
+				},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *refreshSuite) TestConfigureRefreshWindowsInvalid(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"refresh.windows.db.timer": "sun,02:00-04:00",
+		},
+		conf: map[string]interface{}{
+			"refresh.windows": map[string]interface{}{
+				"db": map[string]interface{}{
+					"timer": "sun,02:00-04:00",
+				},
+			},
+		},
+	})
+	c.Assert(err, ErrorMatches, `refresh window "db" has no snaps`)
+}
+
+func (s *refreshSuite) TestConfigureRefreshCoordinatorHappy(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"refresh.coordinator": "https://coordinator.example.com/refresh",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *refreshSuite) TestConfigureRefreshCoordinatorInvalid(c *C) {
+	for _, coordURL := range []string{"coordinator.example.com", "ftp://coordinator.example.com", "http://"} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"refresh.coordinator": coordURL,
+			},
+		})
+		c.Check(err, ErrorMatches, `refresh.coordinator must be a http or https URL, not ".*"`, Commentf("%s", coordURL))
+	}
+}
@@ -49,13 +49,31 @@ func init() {
 	// store-certs.*
 	addWithStateHandler(validateCertSettings, handleCertConfiguration, nil)
 
+	// system.kernel.cmdline-append
+	addWithStateHandler(validateCmdlineAppend, handleCmdlineAppend, coreOnly)
+
+	// system.firewall.*
+	addWithStateHandler(validateFirewallSettings, handleFirewallConfiguration, coreOnly)
+
 	// users.create.automatic
 	addWithStateHandler(validateUsersSettings, handleUserSettings, &flags{earlyConfigFilter: earlyUsersSettingsFilter})
 
+	// snapshots.remote.*
+	addWithStateHandler(validateSnapshotsRemote, handleSnapshotsRemote, nil)
+
 	validateOnly := &flags{validatedOnlyStateConfig: true}
 	addWithStateHandler(validateRefreshSchedule, nil, validateOnly)
 	addWithStateHandler(validateRefreshRateLimit, nil, validateOnly)
+	addWithStateHandler(validateRefreshHealthGracePeriod, nil, validateOnly)
+	addWithStateHandler(validateRefreshWindows, nil, validateOnly)
+	addWithStateHandler(validateRefreshCoordinator, nil, validateOnly)
 	addWithStateHandler(validateAutomaticSnapshotsExpiration, nil, validateOnly)
+	addWithStateHandler(validateIncrementalSnapshots, nil, validateOnly)
+	addWithStateHandler(validateScheduledSnapshots, nil, validateOnly)
+	addWithStateHandler(validateSnapshotsEncryption, nil, validateOnly)
+	addWithStateHandler(validateHealthRemediation, nil, validateOnly)
+	addWithStateHandler(validateStorePeers, nil, validateOnly)
+	addWithStateHandler(validateStoreCache, nil, validateOnly)
 }
 
 type withStateHandler struct {
@@ -116,7 +134,18 @@ func applyHandlers(cfg config.Conf, handlers []configHandler) error {
 			if !validCertOption(k) {
 				return fmt.Errorf("cannot set store ssl certificate under name %q: name must only contain word characters or a dash", k)
 			}
-		case !supportedConfigurations[k]:
+		case strings.HasPrefix(k, "core."+netplanOption+"."):
+			// the netplan configuration is checked as a whole by
+			// its handler
+		case strings.HasPrefix(k, "core."+sysctlTreeOption+"."):
+			// kernel parameter names are checked by the sysctl
+			// handler
+		case strings.HasPrefix(k, "core."+firewallAllowOption+"."):
+			// firewall rules are checked by the firewall handler
+		case strings.HasPrefix(k, "core.refresh.windows."):
+			// refresh windows are checked as a whole by their
+			// handler
+		case !supportedConfigurations[config.ChangedOption(k)]:
 			return fmt.Errorf("cannot set %q: unsupported system option", k)
 		}
 	}
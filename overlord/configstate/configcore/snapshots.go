@@ -22,14 +22,39 @@ package configcore
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 func init() {
 	// add supported configuration of this module
 	supportedConfigurations["core.snapshots.automatic.retention"] = true
+	supportedConfigurations["core.snapshots.incremental"] = true
+	supportedConfigurations["core.snapshots.schedule"] = true
+	supportedConfigurations["core.snapshots.scheduled-snaps"] = true
+	supportedConfigurations["core.snapshots.keep-last"] = true
+	supportedConfigurations["core.snapshots.keep-daily"] = true
+	supportedConfigurations["core.snapshots.keep-weekly"] = true
+	supportedConfigurations["core.snapshots.encryption.public-key"] = true
+	supportedConfigurations["core.snapshots.remote.url"] = true
+	supportedConfigurations["core.snapshots.remote.endpoint"] = true
+	supportedConfigurations["core.snapshots.remote.region"] = true
+	supportedConfigurations["core.snapshots.remote.access-key-id"] = true
+	supportedConfigurations["core.snapshots.remote.secret-access-key"] = true
+	secretConfigurations["core.snapshots.remote.secret-access-key"] = true
+	supportedConfigurations["core.snapshots.remote.identity-file"] = true
+	supportedConfigurations["core.snapshots.remote.auto-push"] = true
+}
+
+func validateIncrementalSnapshots(tr config.Conf) error {
+	return validateBoolFlag(tr, "snapshots.incremental")
 }
 
 func validateAutomaticSnapshotsExpiration(tr config.Conf) error {
@@ -48,3 +73,106 @@ func validateAutomaticSnapshotsExpiration(tr config.Conf) error {
 	}
 	return nil
 }
+
+func validateScheduledSnapshots(tr config.Conf) error {
+	scheduleStr, err := coreCfg(tr, "snapshots.schedule")
+	if err != nil {
+		return err
+	}
+	if scheduleStr != "" {
+		if _, err := timeutil.ParseSchedule(scheduleStr); err != nil {
+			return fmt.Errorf("snapshots.schedule cannot be parsed: %v", err)
+		}
+	}
+
+	snapsStr, err := coreCfg(tr, "snapshots.scheduled-snaps")
+	if err != nil {
+		return err
+	}
+	for _, name := range strutil.CommaSeparatedList(snapsStr) {
+		if err := naming.ValidateInstance(name); err != nil {
+			return fmt.Errorf("snapshots.scheduled-snaps is invalid: %v", err)
+		}
+	}
+
+	for _, opt := range []string{"snapshots.keep-last", "snapshots.keep-daily", "snapshots.keep-weekly"} {
+		keepStr, err := coreCfg(tr, opt)
+		if err != nil {
+			return err
+		}
+		if keepStr == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(keepStr, 10, 16); err != nil {
+			return fmt.Errorf("%s must be a non-negative number, not %q", opt, keepStr)
+		}
+	}
+	return nil
+}
+
+func validateSnapshotsEncryption(tr config.Conf) error {
+	armored, err := coreCfg(tr, "snapshots.encryption.public-key")
+	if err != nil {
+		return err
+	}
+	if armored == "" {
+		return nil
+	}
+	if _, err := backend.ParsePublicKeys(armored); err != nil {
+		return fmt.Errorf("snapshots.encryption.public-key is invalid: %v", err)
+	}
+	return nil
+}
+
+func validateSnapshotsRemote(tr config.Conf) error {
+	if err := validateBoolFlag(tr, "snapshots.remote.auto-push"); err != nil {
+		return err
+	}
+
+	var cfg remote.Config
+	for opt, value := range map[string]*string{
+		"snapshots.remote.url":           &cfg.URL,
+		"snapshots.remote.endpoint":      &cfg.Endpoint,
+		"snapshots.remote.region":        &cfg.Region,
+		"snapshots.remote.access-key-id": &cfg.AccessKeyID,
+		"snapshots.remote.identity-file": &cfg.IdentityFile,
+	} {
+		v, err := coreCfg(tr, opt)
+		if err != nil {
+			return err
+		}
+		*value = v
+	}
+	secretAccessKey, err := coreSecretCfg(tr, "snapshots.remote.secret-access-key")
+	if err != nil {
+		return err
+	}
+	cfg.SecretAccessKey = secretAccessKey
+	if cfg.URL == "" {
+		return nil
+	}
+	if _, err := remote.New(&cfg); err != nil {
+		return fmt.Errorf("snapshots.remote.url is invalid: %v", err)
+	}
+	return nil
+}
+
+// handleSnapshotsRemote makes sure the secret access key of the remote is
+// stored encrypted, even when it was not set with "snap set", like when it
+// comes from the gadget defaults.
+func handleSnapshotsRemote(tr config.Conf, opts *fsOnlyContext) error {
+	const opt = "snapshots.remote.secret-access-key"
+	var value interface{}
+	if err := tr.GetMaybe("core", opt, &value); err != nil {
+		return err
+	}
+	plaintext, ok := value.(string)
+	if !ok || plaintext == "" {
+		return nil
+	}
+	secret, err := config.NewSecret("core", plaintext)
+	if err != nil {
+		return err
+	}
+	return tr.Set("core", opt, secret)
+}
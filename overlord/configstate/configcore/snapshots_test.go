@@ -22,6 +22,7 @@ package configcore_test
 import (
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
 )
 
@@ -70,3 +71,136 @@ func (s *refreshSuite) TestConfigureAutomaticSnapshotsExpirationInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `snapshots.automatic.retention cannot be parsed:.*`)
 }
+
+func (s *snapshotsSuite) TestConfigureIncrementalSnapshots(c *C) {
+	for _, v := range []string{"true", "false"} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"snapshots.incremental": v,
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureIncrementalSnapshotsInvalid(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.incremental": "sometimes",
+		},
+	})
+	c.Assert(err, ErrorMatches, `snapshots.incremental can only be set to 'true' or 'false'`)
+}
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsHappy(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.schedule":        "mon,02:00",
+			"snapshots.scheduled-snaps": "foo,bar_instance",
+			"snapshots.keep-last":       "3",
+			"snapshots.keep-daily":      7,
+			"snapshots.keep-weekly":     "0",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsInvalid(c *C) {
+	for _, tc := range []struct {
+		opt, val, err string
+	}{
+		{"snapshots.schedule", "invalid", `snapshots.schedule cannot be parsed: .*`},
+		{"snapshots.scheduled-snaps", "foo,Bar", `snapshots.scheduled-snaps is invalid: invalid snap name: "Bar"`},
+		{"snapshots.keep-last", "-1", `snapshots.keep-last must be a non-negative number, not "-1"`},
+		{"snapshots.keep-daily", "many", `snapshots.keep-daily must be a non-negative number, not "many"`},
+		{"snapshots.keep-weekly", "1.5", `snapshots.keep-weekly must be a non-negative number, not "1.5"`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				tc.opt: tc.val,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s=%s", tc.opt, tc.val))
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsEncryptionInvalid(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.encryption.public-key": "not a key",
+		},
+	})
+	c.Assert(err, ErrorMatches, `snapshots.encryption.public-key is invalid: cannot decode armored key: .*`)
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsRemoteHappy(c *C) {
+	for _, conf := range []map[string]interface{}{
+		{"snapshots.remote.url": "sftp://backup@host:2222/srv/snapshots"},
+		{
+			"snapshots.remote.url":               "s3://bucket/prefix",
+			"snapshots.remote.endpoint":          "http://localhost:9000",
+			"snapshots.remote.access-key-id":     "id",
+			"snapshots.remote.secret-access-key": "secret",
+			"snapshots.remote.auto-push":         "true",
+		},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf:  conf,
+		})
+		c.Check(err, IsNil, Commentf("%v", conf))
+	}
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsRemoteSecretAccessKey(c *C) {
+	c.Check(configcore.SecretOptions(), DeepEquals, map[string]bool{
+		"snapshots.remote.secret-access-key": true,
+	})
+
+	// the secret access key ends up encrypted, even when it was not set
+	// with "snap set"
+	conf := &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"snapshots.remote.url":               "s3://bucket/prefix",
+			"snapshots.remote.access-key-id":     "id",
+			"snapshots.remote.secret-access-key": "secret",
+		},
+	}
+	c.Assert(configcore.Run(conf), IsNil)
+	value := conf.conf["snapshots.remote.secret-access-key"]
+	c.Check(config.IsSecret(value), Equals, true)
+	revealed, err := config.RevealSecrets("core", value)
+	c.Assert(err, IsNil)
+	c.Check(revealed, Equals, "secret")
+
+	// and the encrypted key is still checked
+	conf.conf["snapshots.remote.secret-access-key"] = nil
+	err = configcore.Run(conf)
+	c.Check(err, ErrorMatches, `snapshots.remote.url is invalid: no s3 credentials given`)
+	conf.conf["snapshots.remote.secret-access-key"] = value
+	c.Check(configcore.Run(conf), IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureSnapshotsRemoteInvalid(c *C) {
+	for _, tc := range []struct {
+		conf map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"snapshots.remote.url": "ftp://host"}, `snapshots.remote.url is invalid: unsupported remote URL scheme "ftp" \(expected s3 or sftp\)`},
+		{map[string]interface{}{"snapshots.remote.url": "s3://bucket"}, `snapshots.remote.url is invalid: no s3 credentials given`},
+		{map[string]interface{}{"snapshots.remote.url": "sftp://host", "snapshots.remote.identity-file": "id_rsa"}, `snapshots.remote.url is invalid: sftp identity file "id_rsa" is not an absolute path`},
+		{map[string]interface{}{"snapshots.remote.auto-push": "always"}, `snapshots.remote.auto-push can only be set to 'true' or 'false'`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf:  tc.conf,
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.conf))
+	}
+}
@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/strutil"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.store.cache.max-items"] = true
+	supportedConfigurations["core.store.cache.max-size"] = true
+	supportedConfigurations["core.store.cache.max-age"] = true
+}
+
+func validateStoreCache(tr config.Conf) error {
+	maxItems, err := coreCfg(tr, "store.cache.max-items")
+	if err != nil {
+		return err
+	}
+	if maxItems != "" {
+		n, err := strconv.Atoi(maxItems)
+		if err != nil || n < 1 {
+			return fmt.Errorf("store.cache.max-items must be a positive number, not %q", maxItems)
+		}
+	}
+
+	maxSize, err := coreCfg(tr, "store.cache.max-size")
+	if err != nil {
+		return err
+	}
+	if maxSize != "" {
+		if _, err := strutil.ParseByteSize(maxSize); err != nil {
+			return fmt.Errorf("cannot parse store.cache.max-size: %v", err)
+		}
+	}
+
+	maxAge, err := coreCfg(tr, "store.cache.max-age")
+	if err != nil {
+		return err
+	}
+	if maxAge != "" {
+		d, err := time.ParseDuration(maxAge)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("store.cache.max-age must be a positive duration like 720h, not %q", maxAge)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type storeCacheSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&storeCacheSuite{})
+
+func (s *storeCacheSuite) TestConfigureStoreCacheHappy(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.cache.max-items": "20",
+			"store.cache.max-size":  "4GB",
+			"store.cache.max-age":   "720h",
+		},
+	})
+	c.Check(err, IsNil)
+}
+
+func (s *storeCacheSuite) TestConfigureStoreCacheInvalid(c *C) {
+	for _, t := range []struct {
+		key, value, err string
+	}{
+		{"store.cache.max-items", "many", `store.cache.max-items must be a positive number, not "many"`},
+		{"store.cache.max-items", "0", `store.cache.max-items must be a positive number, not "0"`},
+		{"store.cache.max-size", "lots", `cannot parse store.cache.max-size: .*`},
+		{"store.cache.max-age", "30d", `store.cache.max-age must be a positive duration like 720h, not "30d"`},
+		{"store.cache.max-age", "-1h", `store.cache.max-age must be a positive duration like 720h, not "-1h"`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				t.key: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%s", t.key, t.value))
+	}
+}
@@ -20,15 +20,21 @@
 package configcore
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/systemd"
 )
 
@@ -39,8 +45,15 @@ import (
 func init() {
 	// add supported configuration of this module
 	supportedConfigurations["core.system.kernel.printk.console-loglevel"] = true
+	supportedConfigurations["core."+sysctlTreeOption] = true
 }
 
+// Kernel parameters can also be set generally under system.kernel.sysctl,
+// e.g. system.kernel.sysctl.vm.swappiness. As option names cannot have
+// underscores, dashes stand for the underscores of parameter names, e.g.
+// system.kernel.sysctl.net.ipv4.ip-forward sets net.ipv4.ip_forward.
+const sysctlTreeOption = "system.kernel.sysctl"
+
 const (
 	sysctlConfsDir  = "/etc/sysctl.d"
 	snapdSysctlConf = "99-snapd.conf"
@@ -49,7 +62,93 @@ const (
 // these are the sysctl parameters prefixes we handle
 var sysctlPrefixes = []string{"kernel.printk"}
 
+var validSysctlName = regexp.MustCompile(`^[a-z0-9_-]+(\.[a-z0-9_-]+)*$`)
+
+func flattenSysctlTree(prefix string, value interface{}, out map[string]string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, elem := range v {
+			name := strings.Replace(k, "-", "_", -1)
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			if err := flattenSysctlTree(name, elem, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	case string:
+		if strings.ContainsAny(v, "\n\r") {
+			return fmt.Errorf("invalid value for kernel parameter %q: cannot contain newlines", prefix)
+		}
+		out[prefix] = v
+	case json.Number:
+		out[prefix] = v.String()
+	case int, int64, float64:
+		out[prefix] = fmt.Sprint(v)
+	default:
+		return fmt.Errorf("invalid value for kernel parameter %q: expected a string or number, got %T", prefix, value)
+	}
+	if !validSysctlName.MatchString(prefix) {
+		return fmt.Errorf("invalid kernel parameter name %q", prefix)
+	}
+	for _, handled := range sysctlPrefixes {
+		if prefix == handled || strings.HasPrefix(prefix, handled+".") {
+			return fmt.Errorf("cannot set kernel parameter %q through %s", prefix, sysctlTreeOption)
+		}
+	}
+	return nil
+}
+
+// sysctlSettings returns the kernel parameters set under the
+// system.kernel.sysctl option, by name.
+func sysctlSettings(tr config.ConfGetter) (map[string]string, error) {
+	var tree interface{}
+	if err := tr.Get("core", sysctlTreeOption, &tree); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	settings := make(map[string]string)
+	if tree == nil {
+		return settings, nil
+	}
+	if _, ok := tree.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("%s must be a map of kernel parameters", sysctlTreeOption)
+	}
+	if err := flattenSysctlTree("", tree, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// sysctlConfNames returns the names of the kernel parameters set in the
+// given sysctl.d file.
+func sysctlConfNames(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		names = append(names, strings.TrimSpace(strings.SplitN(line, "=", 2)[0]))
+	}
+	return names, s.Err()
+}
+
 func validateSysctlOptions(tr config.ConfGetter) error {
+	if _, err := sysctlSettings(tr); err != nil {
+		return err
+	}
+
 	consoleLoglevelStr, err := coreCfg(tr, "system.kernel.printk.console-loglevel")
 	if err != nil {
 		return err
@@ -84,6 +183,19 @@ func handleSysctlConfiguration(tr config.ConfGetter, opts *fsOnlyContext) error
 		// TODO: this logic will need more non-obvious work to support
 		// kernel parameters that don't have already on-disk defaults.
 	}
+
+	settings, err := sysctlSettings(tr)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(content, "%s = %s\n", name, settings[name])
+	}
 	dirContent := map[string]osutil.FileState{}
 	if content.Len() > 0 {
 		dirContent[snapdSysctlConf] = &osutil.MemoryFileState{
@@ -99,6 +211,12 @@ func handleSysctlConfiguration(tr config.ConfGetter, opts *fsOnlyContext) error
 		}
 	}
 
+	// parameters no longer set need to be applied again too
+	oldNames, err := sysctlConfNames(filepath.Join(dir, snapdSysctlConf))
+	if err != nil {
+		return err
+	}
+
 	// write the new config
 	glob := snapdSysctlConf
 	changed, removed, err := osutil.EnsureDirState(dir, glob, dirContent)
@@ -110,7 +228,13 @@ func handleSysctlConfiguration(tr config.ConfGetter, opts *fsOnlyContext) error
 		if len(changed) > 0 || len(removed) > 0 {
 			// apply our configuration or default configuration
 			// via systemd-sysctl for the relevant prefixes
-			return systemd.Sysctl(sysctlPrefixes)
+			prefixes := append([]string(nil), sysctlPrefixes...)
+			for _, name := range append(oldNames, names...) {
+				if !strutil.ListContains(prefixes, name) && !strings.HasPrefix(name, "kernel.printk") {
+					prefixes = append(prefixes, name)
+				}
+			}
+			return systemd.Sysctl(prefixes)
 		}
 	}
 
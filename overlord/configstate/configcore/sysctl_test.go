@@ -20,6 +20,7 @@
 package configcore_test
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 
@@ -138,3 +139,85 @@ func (s *sysctlSuite) TestFilesystemOnlyApply(c *C) {
 	// systemd-sysctl was not executed
 	c.Check(s.systemdSysctlArgs, HasLen, 0)
 }
+
+func (s *sysctlSuite) TestConfigureSysctlTree(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.printk.console-loglevel": "2",
+			"system.kernel.sysctl": map[string]interface{}{
+				"vm": map[string]interface{}{
+					"swappiness": json.Number("10"),
+				},
+				"net": map[string]interface{}{
+					"ipv4": map[string]interface{}{
+						"ip-forward":          "1",
+						"ip-local-port-range": "32768 60999",
+					},
+				},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.mockSysctlConfPath, testutil.FileEquals, `kernel.printk = 2 4 1 7
+net.ipv4.ip_forward = 1
+net.ipv4.ip_local_port_range = 32768 60999
+vm.swappiness = 10
+`)
+	c.Check(s.systemdSysctlArgs, DeepEquals, [][]string{
+		{"--prefix", "kernel.printk", "--prefix", "net.ipv4.ip_forward", "--prefix", "net.ipv4.ip_local_port_range", "--prefix", "vm.swappiness"},
+	})
+	s.systemdSysctlArgs = nil
+
+	// parameters no longer set are applied again as well
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.sysctl": map[string]interface{}{
+				"vm": map[string]interface{}{
+					"swappiness": json.Number("20"),
+				},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.mockSysctlConfPath, testutil.FileEquals, "vm.swappiness = 20\n")
+	c.Check(s.systemdSysctlArgs, DeepEquals, [][]string{
+		{"--prefix", "kernel.printk", "--prefix", "net.ipv4.ip_forward", "--prefix", "net.ipv4.ip_local_port_range", "--prefix", "vm.swappiness"},
+	})
+}
+
+func (s *sysctlSuite) TestConfigureSysctlTreeInvalid(c *C) {
+	for _, t := range []struct {
+		tree interface{}
+		err  string
+	}{
+		{"foo", `system.kernel.sysctl must be a map of kernel parameters`},
+		{map[string]interface{}{"vm": map[string]interface{}{"swappiness": true}}, `invalid value for kernel parameter "vm.swappiness": expected a string or number, got bool`},
+		{map[string]interface{}{"vm": map[string]interface{}{"swappiness": "1\nkernel.panic = 1"}}, `invalid value for kernel parameter "vm.swappiness": cannot contain newlines`},
+		{map[string]interface{}{"vm": map[string]interface{}{"swap piness": "1"}}, `invalid kernel parameter name "vm.swap piness"`},
+		{map[string]interface{}{"kernel": map[string]interface{}{"printk": "4 4 1 7"}}, `cannot set kernel parameter "kernel.printk" through system.kernel.sysctl`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.kernel.sysctl": t.tree,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(osutil.FileExists(s.mockSysctlConfPath), Equals, false)
+}
+
+func (s *sysctlSuite) TestFilesystemOnlyApplySysctlTree(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"system.kernel.sysctl.vm.swappiness":       10,
+		"system.kernel.sysctl.net.ipv4.ip_forward": "1",
+	})
+
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(tmpDir, conf, nil), IsNil)
+
+	c.Check(filepath.Join(tmpDir, "/etc/sysctl.d/99-snapd.conf"), testutil.FileEquals, "net.ipv4.ip_forward = 1\nvm.swappiness = 10\n")
+	c.Check(s.systemdSysctlArgs, HasLen, 0)
+}
@@ -28,7 +28,13 @@ import (
 	"github.com/snapcore/snapd/overlord/state"
 )
 
-var configcoreRun = configcore.Run
+var (
+	configcoreRun = configcore.Run
+
+	configcoreEnsureFirewallRollback = configcore.EnsureFirewallRollback
+
+	configcoreSecretOptions = configcore.SecretOptions
+)
 
 func MockConfigcoreRun(f func(config.Conf) error) (restore func()) {
 	origConfigcoreRun := configcoreRun
@@ -56,3 +62,23 @@ func Init(st *state.State, hookManager *hookstate.HookManager) error {
 
 	return nil
 }
+
+// ConfigManager takes care of the parts of the core configuration that
+// need attention beyond applying it, like reverting firewall rules that
+// were not confirmed in time.
+type ConfigManager struct {
+	state *state.State
+}
+
+// Manager returns a new ConfigManager.
+func Manager(st *state.State) *ConfigManager {
+	return &ConfigManager{state: st}
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *ConfigManager) Ensure() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	return configcoreEnsureFirewallRollback(m.state)
+}
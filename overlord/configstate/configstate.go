@@ -96,6 +96,16 @@ func ConfigureInstalled(st *state.State, snapName string, patch map[string]inter
 	if err := canConfigure(st, snapName); err != nil {
 		return nil, err
 	}
+	// the "core" snap/pseudonym has no configuration schema
+	if snapName != "core" {
+		if err := validatePatch(st, snapName, patch); err != nil {
+			return nil, err
+		}
+	}
+	// the patch is stored in the state, so secrets must be encrypted by now
+	if err := EncryptSecrets(st, snapName, patch, false); err != nil {
+		return nil, err
+	}
 
 	taskset := Configure(st, snapName, patch, flags)
 	return taskset, nil
@@ -326,6 +326,23 @@ func (s *miscSuite) TestRemappingFuncs(c *C) {
 	c.Assert(configstate.RemapSnapToResponse("core"), Equals, "system")
 }
 
+func (s *miscSuite) TestManagerEnsure(c *C) {
+	st := state.New(nil)
+	var ensured *state.State
+	restore := configstate.MockConfigcoreEnsureFirewallRollback(func(st *state.State) error {
+		// this panics if the state is not locked
+		var seeded bool
+		st.Get("seeded", &seeded)
+		ensured = st
+		return fmt.Errorf("boom")
+	})
+	defer restore()
+
+	err := configstate.Manager(st).Ensure()
+	c.Check(err, ErrorMatches, "boom")
+	c.Check(ensured, Equals, st)
+}
+
 type earlyConfigSuite struct {
 	testutil.BaseTest
 
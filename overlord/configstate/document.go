@@ -0,0 +1,511 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+const (
+	configDocumentType    = "config"
+	configDocumentVersion = 1
+)
+
+// ConfigDocument holds the configuration of the system and of snaps, as
+// exported from a device to be imported on the same or another one.
+type ConfigDocument struct {
+	Type     string    `json:"type"`
+	Version  int       `json:"version"`
+	Exported time.Time `json:"exported"`
+	// Config holds the configuration of each snap by name, with the
+	// system configuration under "system"
+	Config map[string]map[string]interface{} `json:"config"`
+}
+
+// ExportConfig returns the configuration of the given snaps, or of the
+// system and all installed snaps if none are given, as a document.
+// Secret values are left out of it. The state must be locked.
+func ExportConfig(st *state.State, snapNames []string) (*ConfigDocument, error) {
+	if len(snapNames) == 0 {
+		var allConfig map[string]*json.RawMessage
+		if err := st.Get("config", &allConfig); err != nil && err != state.ErrNoState {
+			return nil, err
+		}
+		for snapName := range allConfig {
+			if snapName != "core" {
+				var snapst snapstate.SnapState
+				if err := snapstate.Get(st, snapName, &snapst); err != nil && err != state.ErrNoState {
+					return nil, err
+				}
+				if !snapst.IsInstalled() {
+					continue
+				}
+			}
+			snapNames = append(snapNames, snapName)
+		}
+	}
+
+	doc := &ConfigDocument{
+		Type:     configDocumentType,
+		Version:  configDocumentVersion,
+		Exported: timeNow(),
+		Config:   make(map[string]map[string]interface{}),
+	}
+	tr := config.NewTransaction(st)
+	for _, name := range snapNames {
+		snapName := RemapSnapFromRequest(name)
+		if snapName != "core" {
+			var snapst snapstate.SnapState
+			if err := snapstate.Get(st, snapName, &snapst); err != nil && err != state.ErrNoState {
+				return nil, err
+			}
+			if !snapst.IsInstalled() {
+				return nil, &snap.NotInstalledError{Snap: snapName}
+			}
+		}
+		var value map[string]interface{}
+		if err := tr.Get(snapName, "", &value); err != nil {
+			if config.IsNoOption(err) {
+				continue
+			}
+			return nil, err
+		}
+		stripped, _ := config.WithoutSecrets(value).(map[string]interface{})
+		if len(stripped) == 0 {
+			continue
+		}
+		doc.Config[RemapSnapToResponse(snapName)] = stripped
+	}
+	return doc, nil
+}
+
+// signatureHeader starts the ASCII-armored OpenPGP signature that follows
+// the JSON of a signed document.
+const signatureHeader = "-----BEGIN PGP SIGNATURE-----"
+
+// Encode returns the document as JSON, followed by its detached
+// ASCII-armored OpenPGP signature if a private key is given, unlocking
+// the key with the given passphrase if needed.
+func (doc *ConfigDocument) Encode(armoredKey string, passphrase []byte) ([]byte, error) {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+	if armoredKey == "" {
+		return data, nil
+	}
+
+	priv, err := signingKey(armoredKey, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	sig := &packet.Signature{
+		SigType:      packet.SigTypeBinary,
+		PubKeyAlgo:   priv.PubKeyAlgo,
+		Hash:         crypto.SHA256,
+		CreationTime: timeNow(),
+		IssuerKeyId:  &priv.KeyId,
+	}
+	h := sig.Hash.New()
+	h.Write(data)
+	if err := sig.Sign(h, priv, nil); err != nil {
+		return nil, fmt.Errorf("cannot sign configuration document: %v", err)
+	}
+
+	buf := bytes.NewBuffer(data)
+	w, err := armor.Encode(buf, "PGP SIGNATURE", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := sig.Serialize(w); err != nil {
+		return nil, fmt.Errorf("cannot sign configuration document: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// armoredPackets returns a reader of the packets of the given
+// ASCII-armored OpenPGP block.
+func armoredPackets(armored, blockType string) (*packet.Reader, error) {
+	block, err := armor.Decode(strings.NewReader(armored))
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != blockType {
+		return nil, fmt.Errorf("expected %q block, got %q", blockType, block.Type)
+	}
+	return packet.NewReader(block.Body), nil
+}
+
+// signingKey returns the first private key usable for signing found in the
+// given ASCII-armored OpenPGP private key block, unlocked with the given
+// passphrase if needed.
+func signingKey(armored string, passphrase []byte) (*packet.PrivateKey, error) {
+	pr, err := armoredPackets(armored, "PGP PRIVATE KEY BLOCK")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read signing key: %v", err)
+	}
+	for {
+		p, err := pr.Next()
+		if err == io.EOF {
+			return nil, errors.New("no private key usable for signing found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read signing key: %v", err)
+		}
+		priv, ok := p.(*packet.PrivateKey)
+		if !ok || !priv.PubKeyAlgo.CanSign() {
+			continue
+		}
+		if priv.Encrypted {
+			if len(passphrase) == 0 {
+				return nil, errors.New("cannot unlock signing key: no passphrase given")
+			}
+			if err := priv.Decrypt(passphrase); err != nil {
+				return nil, fmt.Errorf("cannot unlock signing key: %v", err)
+			}
+		}
+		return priv, nil
+	}
+}
+
+// verifySignature checks that the armored signature of the data was made
+// by one of the keys of the given ASCII-armored OpenPGP public key block.
+func verifySignature(data []byte, armoredSig, armoredPublicKey string) error {
+	pr, err := armoredPackets(armoredSig, "PGP SIGNATURE")
+	if err != nil {
+		return err
+	}
+	p, err := pr.Next()
+	if err != nil {
+		return err
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok || sig.IssuerKeyId == nil {
+		return errors.New("invalid signature")
+	}
+
+	pr, err = armoredPackets(armoredPublicKey, "PGP PUBLIC KEY BLOCK")
+	if err != nil {
+		return fmt.Errorf("cannot read public key: %v", err)
+	}
+	for {
+		p, err := pr.Next()
+		if err == io.EOF {
+			return errors.New("not signed by the given public key")
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read public key: %v", err)
+		}
+		pub, ok := p.(*packet.PublicKey)
+		if !ok || pub.KeyId != *sig.IssuerKeyId {
+			continue
+		}
+		h := sig.Hash.New()
+		h.Write(data)
+		return pub.VerifySignature(h, sig)
+	}
+}
+
+// DecodeConfigDocument returns the configuration document encoded in the
+// given data. If an ASCII-armored OpenPGP public key block is given, the
+// document must be signed by one of its keys; a signed document can only
+// be decoded with the public key to verify it.
+func DecodeConfigDocument(data []byte, armoredPublicKey string) (*ConfigDocument, error) {
+	i := bytes.Index(data, []byte(signatureHeader))
+	switch {
+	case i < 0 && armoredPublicKey != "":
+		return nil, errors.New("cannot verify configuration document: document is not signed")
+	case i >= 0 && armoredPublicKey == "":
+		return nil, errors.New("cannot verify configuration document: no public key given")
+	case i >= 0:
+		if err := verifySignature(data[:i], string(data[i:]), armoredPublicKey); err != nil {
+			return nil, fmt.Errorf("cannot verify configuration document: %v", err)
+		}
+		data = data[:i]
+	}
+
+	var doc ConfigDocument
+	if err := jsonutil.DecodeWithNumber(bytes.NewReader(data), &doc); err != nil {
+		return nil, fmt.Errorf("cannot decode configuration document: %v", err)
+	}
+	if doc.Type != configDocumentType {
+		return nil, fmt.Errorf("cannot decode configuration document: unexpected type %q", doc.Type)
+	}
+	if doc.Version != configDocumentVersion {
+		return nil, fmt.Errorf("cannot decode configuration document: unsupported version %d", doc.Version)
+	}
+	return &doc, nil
+}
+
+func sameValue(a, b interface{}) bool {
+	// values read from the configuration and from the document are not
+	// necessarily of the same types, their JSON is
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ja, jb)
+}
+
+// importPatch returns the options of the given configuration that differ
+// from the current configuration of the snap. The patch sets the leaf
+// options, so that the other sub-options of the snap, like its secrets,
+// are kept.
+func importPatch(tr *config.Transaction, snapName string, conf map[string]interface{}) (map[string]interface{}, error) {
+	patch := make(map[string]interface{})
+	var add func(prefix string, conf map[string]interface{}) error
+	add = func(prefix string, conf map[string]interface{}) error {
+		for key, value := range conf {
+			key = prefix + key
+			if m, ok := value.(map[string]interface{}); ok {
+				if err := add(key+".", m); err != nil {
+					return err
+				}
+				continue
+			}
+			var current interface{}
+			err := tr.Get(snapName, key, &current)
+			if err != nil && !config.IsNoOption(err) {
+				return err
+			}
+			if err == nil && sameValue(current, value) {
+				continue
+			}
+			patch[key] = value
+		}
+		return nil
+	}
+	if err := add("", conf); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// importRank returns where a snap of the given type goes when importing:
+// gadget and kernel snaps are configured before applications.
+func importRank(typ snap.Type) int {
+	switch typ {
+	case snap.TypeGadget:
+		return 0
+	case snap.TypeKernel:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// importGroups returns the given snaps grouped by connection, each group
+// in the order the configuration of its snaps is imported in: the snaps
+// with slots before the snaps with plugs connected to them, and otherwise
+// by type and name.
+func importGroups(st *state.State, snapNames []string) ([][]string, error) {
+	rank := make(map[string]int, len(snapNames))
+	for _, snapName := range snapNames {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, snapName, &snapst); err != nil {
+			return nil, err
+		}
+		typ, err := snapst.Type()
+		if err != nil {
+			return nil, err
+		}
+		rank[snapName] = importRank(typ)
+	}
+	less := func(a, b string) bool {
+		if rank[a] != rank[b] {
+			return rank[a] < rank[b]
+		}
+		return a < b
+	}
+
+	conns, err := ifacestate.ConnectionStates(st)
+	if err != nil {
+		return nil, err
+	}
+	// deps maps each snap to the snaps its plugs are connected to
+	deps := make(map[string]map[string]bool)
+	parent := make(map[string]string, len(snapNames))
+	var root func(string) string
+	root = func(snapName string) string {
+		if p, ok := parent[snapName]; ok && p != snapName {
+			return root(p)
+		}
+		return snapName
+	}
+	for id, cstate := range conns {
+		if cstate.Undesired || cstate.HotplugGone {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, err
+		}
+		plugSnap, slotSnap := connRef.PlugRef.Snap, connRef.SlotRef.Snap
+		_, plugOk := rank[plugSnap]
+		_, slotOk := rank[slotSnap]
+		if plugSnap == slotSnap || !plugOk || !slotOk {
+			continue
+		}
+		if deps[plugSnap] == nil {
+			deps[plugSnap] = make(map[string]bool)
+		}
+		deps[plugSnap][slotSnap] = true
+		if a, b := root(plugSnap), root(slotSnap); a != b {
+			parent[a] = b
+		}
+	}
+
+	members := make(map[string][]string)
+	for _, snapName := range snapNames {
+		r := root(snapName)
+		members[r] = append(members[r], snapName)
+	}
+	groups := make([][]string, 0, len(members))
+	for _, group := range members {
+		sort.Slice(group, func(i, j int) bool { return less(group[i], group[j]) })
+		ordered := make([]string, 0, len(group))
+		done := make(map[string]bool, len(group))
+		for len(group) > 0 {
+			// the first snap whose slot providers are all
+			// configured, or the first one if they depend on
+			// each other
+			next := 0
+			for i, snapName := range group {
+				ready := true
+				for dep := range deps[snapName] {
+					if !done[dep] {
+						ready = false
+						break
+					}
+				}
+				if ready {
+					next = i
+					break
+				}
+			}
+			done[group[next]] = true
+			ordered = append(ordered, group[next])
+			group = append(group[:next], group[next+1:]...)
+		}
+		groups = append(groups, ordered)
+	}
+	sort.Slice(groups, func(i, j int) bool { return less(groups[i][0], groups[j][0]) })
+	return groups, nil
+}
+
+// ImportConfig returns the task sets that apply the configuration of the
+// document, running the configure hooks of the snaps whose configuration
+// changes, along with the names of these snaps in the same order.
+//
+// The system is configured first, and then the snaps of each group of
+// connected snaps one after the other, slot providers first. The groups
+// are in lanes of their own, so that a failure only holds off the snaps
+// connected to the failed one.
+func ImportConfig(st *state.State, doc *ConfigDocument) ([]string, []*state.TaskSet, error) {
+	tr := config.NewTransaction(st)
+	patches := make(map[string]map[string]interface{}, len(doc.Config))
+	var snapNames []string
+	for name, conf := range doc.Config {
+		snapName := RemapSnapFromRequest(name)
+		patch, err := importPatch(tr, snapName, conf)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(patch) == 0 {
+			continue
+		}
+		patches[snapName] = patch
+		if snapName != "core" {
+			snapNames = append(snapNames, snapName)
+		}
+	}
+	if len(patches) == 0 {
+		return nil, nil, errors.New("configuration is already as in the document")
+	}
+
+	// check that all snaps can be configured before ordering them
+	tss := make(map[string]*state.TaskSet, len(patches))
+	for snapName, patch := range patches {
+		ts, err := ConfigureInstalled(st, snapName, patch, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		tss[snapName] = ts
+	}
+	groups, err := importGroups(st, snapNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names []string
+	var ordered []*state.TaskSet
+	systemTs := tss["core"]
+	if systemTs != nil {
+		names = append(names, "core")
+		ordered = append(ordered, systemTs)
+	}
+	for _, group := range groups {
+		lane := st.NewLane()
+		prev := systemTs
+		if prev != nil {
+			prev.JoinLane(lane)
+		}
+		for _, snapName := range group {
+			ts := tss[snapName]
+			ts.JoinLane(lane)
+			if prev != nil {
+				ts.WaitAll(prev)
+			}
+			prev = ts
+			names = append(names, snapName)
+			ordered = append(ordered, ts)
+		}
+	}
+	return names, ordered, nil
+}
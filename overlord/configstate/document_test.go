@@ -0,0 +1,341 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type documentSuite struct {
+	testutil.BaseTest
+	state *state.State
+	now   time.Time
+}
+
+var _ = Suite(&documentSuite{})
+
+func (s *documentSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.state = state.New(nil)
+
+	s.now = time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	s.AddCleanup(configstate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	for name, typ := range map[string]string{
+		"pc":       "gadget",
+		"provider": "app",
+		"consumer": "app",
+		"lonely":   "app",
+	} {
+		si := &snap.SideInfo{RealName: name, Revision: snap.R(1)}
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Sequence: []*snap.SideInfo{si},
+			Current:  snap.R(1),
+			Active:   true,
+			SnapType: typ,
+		})
+		snaptest.MockSnapCurrent(c, "name: "+name+"\nversion: 1\ntype: "+typ+"\n", si)
+	}
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:db provider:db": map[string]interface{}{
+			"interface": "content",
+		},
+		"lonely:old provider:old": map[string]interface{}{
+			"interface": "content",
+			"undesired": true,
+		},
+		"consumer:home core:home": map[string]interface{}{
+			"interface": "home",
+		},
+	})
+}
+
+func (s *documentSuite) TestExportConfig(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "service.ssh.disable", true), IsNil)
+	c.Assert(tr.Set("consumer", "port", 8080), IsNil)
+	c.Assert(tr.SetSecret("consumer", "db.password", "hunter2"), IsNil)
+	c.Assert(tr.SetSecret("provider", "token", "abc"), IsNil)
+	c.Assert(tr.Set("removed", "foo", "bar"), IsNil)
+	tr.Commit()
+
+	doc, err := configstate.ExportConfig(s.state, nil)
+	c.Assert(err, IsNil)
+	c.Check(doc.Type, Equals, "config")
+	c.Check(doc.Version, Equals, 1)
+	c.Check(doc.Exported.Equal(s.now), Equals, true)
+	c.Check(doc.Config, DeepEquals, map[string]map[string]interface{}{
+		"system": {
+			"service": map[string]interface{}{
+				"ssh": map[string]interface{}{"disable": true},
+			},
+		},
+		"consumer": {
+			"port": json.Number("8080"),
+			"db":   map[string]interface{}{},
+		},
+	})
+
+	doc, err = configstate.ExportConfig(s.state, []string{"system"})
+	c.Assert(err, IsNil)
+	c.Check(doc.Config, HasLen, 1)
+	c.Check(doc.Config["system"], NotNil)
+
+	// snaps without configuration are left out
+	doc, err = configstate.ExportConfig(s.state, []string{"lonely"})
+	c.Assert(err, IsNil)
+	c.Check(doc.Config, HasLen, 0)
+
+	_, err = configstate.ExportConfig(s.state, []string{"removed"})
+	c.Check(err, FitsTypeOf, &snap.NotInstalledError{})
+	c.Check(err, ErrorMatches, `snap "removed" is not installed`)
+}
+
+// testSigningKeyPair returns a new ASCII-armored OpenPGP key pair.
+func testSigningKeyPair(c *C) (pub, priv string) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	key := packet.NewRSAPrivateKey(time.Now(), rsaKey)
+
+	var pubBuf bytes.Buffer
+	w, err := armor.Encode(&pubBuf, "PGP PUBLIC KEY BLOCK", nil)
+	c.Assert(err, IsNil)
+	c.Assert(key.PublicKey.Serialize(w), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	var privBuf bytes.Buffer
+	w, err = armor.Encode(&privBuf, "PGP PRIVATE KEY BLOCK", nil)
+	c.Assert(err, IsNil)
+	c.Assert(key.Serialize(w), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	return pubBuf.String(), privBuf.String()
+}
+
+func (s *documentSuite) testDocument() *configstate.ConfigDocument {
+	return &configstate.ConfigDocument{
+		Type:     "config",
+		Version:  1,
+		Exported: s.now,
+		Config: map[string]map[string]interface{}{
+			"system":   {"watchdog": map[string]interface{}{"runtime-timeout": "10s"}},
+			"consumer": {"port": json.Number("8080")},
+		},
+	}
+}
+
+func (s *documentSuite) TestEncodeDecodePlain(c *C) {
+	data, err := s.testDocument().Encode("", nil)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{
+  "type": "config",
+  "version": 1,
+  "exported": "2021-06-01T10:00:00Z",
+  "config": {
+    "consumer": {
+      "port": 8080
+    },
+    "system": {
+      "watchdog": {
+        "runtime-timeout": "10s"
+      }
+    }
+  }
+}
+`)
+
+	doc, err := configstate.DecodeConfigDocument(data, "")
+	c.Assert(err, IsNil)
+	c.Check(doc.Config, DeepEquals, s.testDocument().Config)
+
+	pub, _ := testSigningKeyPair(c)
+	_, err = configstate.DecodeConfigDocument(data, pub)
+	c.Check(err, ErrorMatches, `cannot verify configuration document: document is not signed`)
+}
+
+func (s *documentSuite) TestEncodeDecodeSigned(c *C) {
+	pub, priv := testSigningKeyPair(c)
+	otherPub, _ := testSigningKeyPair(c)
+
+	data, err := s.testDocument().Encode(priv, nil)
+	c.Assert(err, IsNil)
+	plain, err := s.testDocument().Encode("", nil)
+	c.Assert(err, IsNil)
+	c.Check(strings.HasPrefix(string(data), string(plain)+"-----BEGIN PGP SIGNATURE-----\n"), Equals, true)
+
+	doc, err := configstate.DecodeConfigDocument(data, pub)
+	c.Assert(err, IsNil)
+	c.Check(doc.Config, DeepEquals, s.testDocument().Config)
+
+	_, err = configstate.DecodeConfigDocument(data, "")
+	c.Check(err, ErrorMatches, `cannot verify configuration document: no public key given`)
+
+	_, err = configstate.DecodeConfigDocument(data, otherPub)
+	c.Check(err, ErrorMatches, `cannot verify configuration document: not signed by the given public key`)
+
+	tampered := bytes.Replace(data, []byte("8080"), []byte("8081"), 1)
+	_, err = configstate.DecodeConfigDocument(tampered, pub)
+	c.Check(err, ErrorMatches, `cannot verify configuration document: .*`)
+}
+
+func (s *documentSuite) TestEncodeSignedErrors(c *C) {
+	pub, _ := testSigningKeyPair(c)
+
+	_, err := s.testDocument().Encode("garbage", nil)
+	c.Check(err, ErrorMatches, `cannot read signing key: .*`)
+
+	_, err = s.testDocument().Encode(pub, nil)
+	c.Check(err, ErrorMatches, `cannot read signing key: expected "PGP PRIVATE KEY BLOCK" block, got "PGP PUBLIC KEY BLOCK"`)
+}
+
+func (s *documentSuite) TestDecodeErrors(c *C) {
+	for _, t := range []struct {
+		data, err string
+	}{
+		{`{`, `cannot decode configuration document: .*`},
+		{`{"type":"manifest","version":1}`, `cannot decode configuration document: unexpected type "manifest"`},
+		{`{"type":"config","version":2}`, `cannot decode configuration document: unsupported version 2`},
+	} {
+		_, err := configstate.DecodeConfigDocument([]byte(t.data), "")
+		c.Check(err, ErrorMatches, t.err, Commentf("%s", t.data))
+	}
+}
+
+func (s *documentSuite) TestImportConfigOrder(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("lonely", "unchanged", "value"), IsNil)
+	tr.Commit()
+
+	doc := &configstate.ConfigDocument{
+		Type:    "config",
+		Version: 1,
+		Config: map[string]map[string]interface{}{
+			"system":   {"watchdog": map[string]interface{}{"runtime-timeout": "10s"}},
+			"consumer": {"port": json.Number("8080")},
+			"provider": {"db": map[string]interface{}{"size": "1G"}},
+			"pc":       {"display": "on"},
+			"lonely":   {"unchanged": "value", "new": true},
+		},
+	}
+	names, tss, err := configstate.ImportConfig(s.state, doc)
+	c.Assert(err, IsNil)
+	// gadget before applications, slot providers before consumers
+	c.Check(names, DeepEquals, []string{"core", "pc", "lonely", "provider", "consumer"})
+	c.Assert(tss, HasLen, 5)
+
+	tasks := make(map[string]*state.Task, len(names))
+	for i, name := range names {
+		c.Assert(tss[i].Tasks(), HasLen, 1)
+		tasks[name] = tss[i].Tasks()[0]
+	}
+
+	// only the changed options are applied, by leaf
+	patchOf := func(t *state.Task) map[string]interface{} {
+		var hookContext map[string]interface{}
+		c.Assert(t.Get("hook-context", &hookContext), IsNil)
+		patch, _ := hookContext["patch"].(map[string]interface{})
+		return patch
+	}
+	c.Check(patchOf(tasks["lonely"]), DeepEquals, map[string]interface{}{"new": true})
+	c.Check(patchOf(tasks["provider"]), DeepEquals, map[string]interface{}{"db.size": "1G"})
+
+	// the system is configured first, in the lanes of all groups
+	c.Check(tasks["core"].WaitTasks(), HasLen, 0)
+	c.Check(tasks["core"].Lanes(), HasLen, 3)
+	for _, name := range []string{"pc", "lonely", "provider"} {
+		c.Check(tasks[name].WaitTasks(), DeepEquals, []*state.Task{tasks["core"]}, Commentf("%s", name))
+		c.Check(tasks[name].Lanes(), HasLen, 1, Commentf("%s", name))
+	}
+	c.Check(tasks["consumer"].WaitTasks(), DeepEquals, []*state.Task{tasks["provider"]})
+	c.Check(tasks["consumer"].Lanes(), DeepEquals, tasks["provider"].Lanes())
+	c.Check(tasks["lonely"].Lanes(), Not(DeepEquals), tasks["provider"].Lanes())
+	c.Check(tasks["pc"].Lanes(), Not(DeepEquals), tasks["provider"].Lanes())
+}
+
+func (s *documentSuite) TestImportConfigWithoutSystem(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	doc := &configstate.ConfigDocument{
+		Type:    "config",
+		Version: 1,
+		Config: map[string]map[string]interface{}{
+			"consumer": {"port": json.Number("8080")},
+			"provider": {"db": map[string]interface{}{"size": "1G"}},
+		},
+	}
+	names, tss, err := configstate.ImportConfig(s.state, doc)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"provider", "consumer"})
+	c.Assert(tss, HasLen, 2)
+	c.Check(tss[0].Tasks()[0].WaitTasks(), HasLen, 0)
+	c.Check(tss[1].Tasks()[0].WaitTasks(), DeepEquals, tss[0].Tasks())
+}
+
+func (s *documentSuite) TestImportConfigErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("consumer", "port", 8080), IsNil)
+	tr.Commit()
+
+	doc := &configstate.ConfigDocument{
+		Type:    "config",
+		Version: 1,
+		Config: map[string]map[string]interface{}{
+			"consumer": {"port": json.Number("8080")},
+		},
+	}
+	_, _, err := configstate.ImportConfig(s.state, doc)
+	c.Check(err, ErrorMatches, `configuration is already as in the document`)
+
+	doc.Config["removed"] = map[string]interface{}{"foo": "bar"}
+	_, _, err = configstate.ImportConfig(s.state, doc)
+	c.Check(err, ErrorMatches, `snap "removed" is not installed`)
+}
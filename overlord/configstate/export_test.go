@@ -20,7 +20,10 @@
 package configstate
 
 import (
+	"time"
+
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
 )
 
 var NewConfigureHandler = newConfigureHandler
@@ -40,3 +43,27 @@ func MockConfigcoreEarly(mock func(cfg config.Conf, values map[string]interface{
 		configcoreEarly = old
 	}
 }
+
+func MockMaxHistory(n int) (restore func()) {
+	old := maxHistory
+	maxHistory = n
+	return func() {
+		maxHistory = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+func MockConfigcoreEnsureFirewallRollback(f func(st *state.State) error) (restore func()) {
+	old := configcoreEnsureFirewallRollback
+	configcoreEnsureFirewallRollback = f
+	return func() {
+		configcoreEnsureFirewallRollback = old
+	}
+}
@@ -0,0 +1,240 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/jsonutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	timeNow = time.Now
+
+	// maxHistory is how many configuration changes are kept per snap
+	maxHistory = 20
+)
+
+// ConfigChange is the change of the value of a configuration option. A
+// nil value means the option was unset.
+type ConfigChange struct {
+	Key string           `json:"key"`
+	Old *json.RawMessage `json:"old,omitempty"`
+	New *json.RawMessage `json:"new,omitempty"`
+}
+
+// HistoryEntry records a change of the configuration of a snap.
+type HistoryEntry struct {
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+	// User is who requested the change, if known
+	User string `json:"user,omitempty"`
+	// ChangeID is the change the configuration was changed by, if any
+	ChangeID string          `json:"change-id,omitempty"`
+	Changes  []*ConfigChange `json:"changes"`
+	// Config is the configuration of the snap after the change
+	Config map[string]*json.RawMessage `json:"config"`
+}
+
+// Keys returns the options changed by the entry.
+func (e *HistoryEntry) Keys() []string {
+	keys := make([]string, len(e.Changes))
+	for i, change := range e.Changes {
+		keys[i] = change.Key
+	}
+	return keys
+}
+
+// History returns the recorded configuration changes of the snap, oldest
+// first.
+func History(st *state.State, snapName string) ([]*HistoryEntry, error) {
+	var history map[string][]*HistoryEntry
+	err := st.Get("config-history", &history)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	return history[snapName], nil
+}
+
+func rawOption(tr *config.Transaction, pristine bool, snapName, key string) (*json.RawMessage, error) {
+	var raw *json.RawMessage
+	var err error
+	if pristine {
+		err = tr.GetPristine(snapName, key, &raw)
+	} else {
+		err = tr.Get(snapName, key, &raw)
+	}
+	if config.IsNoOption(err) {
+		return nil, nil
+	}
+	return raw, err
+}
+
+func sameRaw(a, b *json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return bytes.Equal(*a, *b)
+}
+
+// transactionChanges returns the changes of the options of each snap made
+// in the transaction, before it is committed.
+func transactionChanges(tr *config.Transaction) (map[string][]*ConfigChange, error) {
+	changes := make(map[string][]*ConfigChange)
+	seen := make(map[string]bool)
+	for _, path := range tr.Changes() {
+		path = config.ChangedOption(path)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		parts := strings.SplitN(path, ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		snapName, key := parts[0], parts[1]
+		old, err := rawOption(tr, true, snapName, key)
+		if err != nil {
+			return nil, err
+		}
+		new, err := rawOption(tr, false, snapName, key)
+		if err != nil {
+			return nil, err
+		}
+		if sameRaw(old, new) {
+			continue
+		}
+		changes[snapName] = append(changes[snapName], &ConfigChange{Key: key, Old: old, New: new})
+	}
+	return changes, nil
+}
+
+// commitWithHistory commits the transaction, recording the configuration
+// changes it makes in the history of the snaps and notifying the consumers
+// of the configuration views they affect. The state must be locked.
+func commitWithHistory(tr *config.Transaction, chg *state.Change) error {
+	changes, err := transactionChanges(tr)
+	tr.Commit()
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	st := tr.State()
+	var history map[string][]*HistoryEntry
+	if err := st.Get("config-history", &history); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if history == nil {
+		history = make(map[string][]*HistoryEntry)
+	}
+	var allConfig map[string]map[string]*json.RawMessage
+	if err := st.Get("config", &allConfig); err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	var user, changeID string
+	if chg != nil {
+		changeID = chg.ID()
+		if err := chg.Get("requested-by", &user); err != nil && err != state.ErrNoState {
+			return err
+		}
+	}
+	now := timeNow()
+	for snapName, snapChanges := range changes {
+		entries := history[snapName]
+		id := 1
+		if len(entries) > 0 {
+			id = entries[len(entries)-1].ID + 1
+		}
+		entries = append(entries, &HistoryEntry{
+			ID:       id,
+			Time:     now,
+			User:     user,
+			ChangeID: changeID,
+			Changes:  snapChanges,
+			Config:   allConfig[snapName],
+		})
+		if len(entries) > maxHistory {
+			entries = entries[len(entries)-maxHistory:]
+		}
+		history[snapName] = entries
+	}
+	st.Set("config-history", history)
+	return notifyConfigViewConsumers(st, changes)
+}
+
+// RevertConfig returns a task set reverting the configuration of the snap
+// to what it was after the given history entry, and running its configure
+// hook.
+func RevertConfig(st *state.State, snapName string, id int) (*state.TaskSet, error) {
+	history, err := History(st, snapName)
+	if err != nil {
+		return nil, err
+	}
+	var target *HistoryEntry
+	for _, entry := range history {
+		if entry.ID == id {
+			target = entry
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("cannot find configuration change %d of snap %q", id, snapName)
+	}
+
+	var allConfig map[string]map[string]*json.RawMessage
+	if err := st.Get("config", &allConfig); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	current := allConfig[snapName]
+
+	// the patch replaces the top-level options, so that the keys of the
+	// patch never overlap
+	patch := make(map[string]interface{})
+	for key := range current {
+		if _, ok := target.Config[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for key, raw := range target.Config {
+		if sameRaw(current[key], raw) {
+			continue
+		}
+		var value interface{}
+		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*raw), &value); err != nil {
+			return nil, err
+		}
+		patch[key] = value
+	}
+	if len(patch) == 0 {
+		return nil, fmt.Errorf("configuration of snap %q is already as after change %d", snapName, id)
+	}
+
+	return ConfigureInstalled(st, snapName, patch, 0)
+}
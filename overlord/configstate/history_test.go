@@ -0,0 +1,255 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type historySuite struct {
+	testutil.BaseTest
+	state *state.State
+	now   time.Time
+}
+
+var _ = Suite(&historySuite{})
+
+func (s *historySuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.state = state.New(nil)
+
+	s.now = time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.AddCleanup(configstate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "app",
+	})
+	snaptest.MockSnapCurrent(c, "name: test-snap\nversion: 1\n", si)
+}
+
+// setInHook sets the given options of test-snap as a hook of a change
+// would, and returns the change. The state must not be locked.
+func (s *historySuite) setInHook(c *C, values map[string]interface{}) *state.Change {
+	return s.configureInHook(c, func(tr *config.Transaction) {
+		for key, value := range values {
+			c.Assert(tr.Set("test-snap", key, value), IsNil)
+		}
+	})
+}
+
+// configureInHook runs f on the configuration transaction of a hook of a
+// change, and returns the change. The state must not be locked.
+func (s *historySuite) configureInHook(c *C, f func(tr *config.Transaction)) *state.Change {
+	s.state.Lock()
+	chg := s.state.NewChange("configure", "...")
+	chg.Set("requested-by", "alice")
+	task := s.state.NewTask("run-hook", "...")
+	chg.AddTask(task)
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	context, err := hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+
+	context.Lock()
+	defer context.Unlock()
+	f(configstate.ContextTransaction(context))
+	c.Assert(context.Done(), IsNil)
+	task.SetStatus(state.DoneStatus)
+	return chg
+}
+
+func (s *historySuite) TestRecordsChanges(c *C) {
+	chg1 := s.setInHook(c, map[string]interface{}{"foo": "bar", "baz": 1})
+	s.now = s.now.Add(time.Hour)
+	chg2 := s.setInHook(c, map[string]interface{}{"foo": "qux", "baz": 1})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	history, err := configstate.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+
+	c.Check(history[0].ID, Equals, 1)
+	c.Check(history[0].Time.Equal(time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)), Equals, true)
+	c.Check(history[0].User, Equals, "alice")
+	c.Check(history[0].ChangeID, Equals, chg1.ID())
+	c.Check(history[0].Keys(), DeepEquals, []string{"baz", "foo"})
+	c.Check(history[0].Changes[1].Old, IsNil)
+	c.Check(string(*history[0].Changes[1].New), Equals, `"bar"`)
+
+	// unchanged values are not recorded
+	c.Check(history[1].ID, Equals, 2)
+	c.Check(history[1].ChangeID, Equals, chg2.ID())
+	c.Assert(history[1].Changes, HasLen, 1)
+	c.Check(history[1].Changes[0].Key, Equals, "foo")
+	c.Check(string(*history[1].Changes[0].Old), Equals, `"bar"`)
+	c.Check(string(*history[1].Changes[0].New), Equals, `"qux"`)
+	c.Check(history[1].Config, HasLen, 2)
+	c.Check(string(*history[1].Config["foo"]), Equals, `"qux"`)
+}
+
+func (s *historySuite) TestRecordsUnset(c *C) {
+	s.setInHook(c, map[string]interface{}{"foo": "bar"})
+	s.setInHook(c, map[string]interface{}{"foo": nil})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	history, err := configstate.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+	c.Check(string(*history[1].Changes[0].Old), Equals, `"bar"`)
+	c.Check(history[1].Changes[0].New, IsNil)
+	c.Check(history[1].Config, HasLen, 0)
+}
+
+func (s *historySuite) TestRecordsSecrets(c *C) {
+	s.configureInHook(c, func(tr *config.Transaction) {
+		c.Assert(tr.SetSecret("test-snap", "token", "hunter2"), IsNil)
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	history, err := configstate.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 1)
+	// the secret is recorded as a change of its option
+	c.Check(history[0].Keys(), DeepEquals, []string{"token"})
+	c.Check(history[0].Changes[0].Old, IsNil)
+	var value interface{}
+	c.Assert(json.Unmarshal(*history[0].Changes[0].New, &value), IsNil)
+	c.Check(config.IsSecret(value), Equals, true)
+}
+
+func (s *historySuite) TestHistoryIsBounded(c *C) {
+	s.AddCleanup(configstate.MockMaxHistory(2))
+
+	for i := 0; i < 4; i++ {
+		s.setInHook(c, map[string]interface{}{"foo": i})
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	history, err := configstate.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+	c.Check(history[0].ID, Equals, 3)
+	c.Check(history[1].ID, Equals, 4)
+}
+
+func (s *historySuite) TestHistoryNone(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	history, err := configstate.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 0)
+}
+
+func (s *historySuite) TestRevertConfig(c *C) {
+	s.setInHook(c, map[string]interface{}{"foo": "bar", "port": 80})
+	s.setInHook(c, map[string]interface{}{"foo": "baz", "extra": true})
+
+	s.state.Lock()
+	ts, err := configstate.RevertConfig(s.state, "test-snap", 1)
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 1)
+
+	var hooksup hookstate.HookSetup
+	c.Assert(ts.Tasks()[0].Get("hook-setup", &hooksup), IsNil)
+	c.Check(hooksup.Hook, Equals, "configure")
+
+	context, err := hookstate.NewContext(ts.Tasks()[0], s.state, &hooksup, nil, "")
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+
+	context.Lock()
+	defer context.Unlock()
+	var patch map[string]interface{}
+	c.Assert(context.Get("patch", &patch), IsNil)
+	c.Check(patch, DeepEquals, map[string]interface{}{
+		"foo":   "bar",
+		"extra": nil,
+	})
+}
+
+func (s *historySuite) TestRevertConfigErrors(c *C) {
+	s.setInHook(c, map[string]interface{}{"foo": "bar"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := configstate.RevertConfig(s.state, "test-snap", 7)
+	c.Check(err, ErrorMatches, `cannot find configuration change 7 of snap "test-snap"`)
+
+	_, err = configstate.RevertConfig(s.state, "test-snap", 1)
+	c.Check(err, ErrorMatches, `configuration of snap "test-snap" is already as after change 1`)
+}
+
+func (s *historySuite) TestCommitWithoutChangeRecordsNoUser(c *C) {
+	// ephemeral contexts, like snapctl run outside of hooks, have no
+	// change
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1)}
+	context, err := hookstate.NewContext(nil, s.state, setup, nil, "")
+	c.Assert(err, IsNil)
+	context.Lock()
+	tr := configstate.ContextTransaction(context)
+	c.Assert(tr.Set("test-snap", "foo", "bar"), IsNil)
+	c.Assert(context.Done(), IsNil)
+	context.Unlock()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	history, err := configstate.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 1)
+	c.Check(history[0].User, Equals, "")
+	c.Check(history[0].ChangeID, Equals, "")
+
+	var value string
+	c.Assert(config.NewTransaction(s.state).Get("test-snap", "foo", &value), IsNil)
+	c.Check(value, Equals, "bar")
+}
@@ -50,7 +50,13 @@ func ContextTransaction(context *hookstate.Context) *config.Transaction {
 	tr = config.NewTransaction(context.State())
 
 	context.OnDone(func() error {
-		tr.Commit()
+		var chg *state.Change
+		if task, ok := context.Task(); ok {
+			chg = task.Change()
+		}
+		if err := commitWithHistory(tr, chg); err != nil {
+			return err
+		}
 		if context.InstanceName() == "core" {
 			// make sure the Ensure logic can process
 			// system configuration changes as soon as possible
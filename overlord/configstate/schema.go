@@ -0,0 +1,150 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ConfigSchema returns the configuration schema declared by the current
+// revision of the given snap, if any.
+func ConfigSchema(st *state.State, snapName string) (snap.ConfigSchema, error) {
+	var snapst snapstate.SnapState
+	err := snapstate.Get(st, snapName, &snapst)
+	if err == state.ErrNoState {
+		return nil, &snap.NotInstalledError{Snap: snapName}
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	return info.ConfigSchema, nil
+}
+
+// SchemaError is returned when the configuration of a snap does not match
+// its configuration schema.
+type SchemaError struct {
+	Snap string
+	Err  error
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("cannot set configuration of snap %q: %v", e.Snap, e.Err)
+}
+
+// relatedKeys returns whether one of the options is, or is nested in,
+// the other.
+func relatedKeys(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// validateConfig checks that the options of the schema related to the
+// given keys, or all of them if no keys are given, have valid values in
+// the transaction.
+func validateConfig(tr *config.Transaction, snapName string, schema snap.ConfigSchema, keys []string) error {
+	for _, schemaKey := range schema.Keys() {
+		if len(keys) > 0 {
+			related := false
+			for _, key := range keys {
+				if relatedKeys(schemaKey, key) {
+					related = true
+					break
+				}
+			}
+			if !related {
+				continue
+			}
+		}
+
+		opt := schema[schemaKey]
+		var value interface{}
+		err := tr.Get(snapName, schemaKey, &value)
+		if config.IsNoOption(err) {
+			if opt.Required && opt.Default == nil {
+				return fmt.Errorf("option %q is required", schemaKey)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if config.IsSecret(value) {
+			// secrets are encrypted so only their type can be checked
+			if opt.Type != snap.ConfigTypeString {
+				return fmt.Errorf("invalid value for option %q: expected %s, got secret", schemaKey, opt.Type)
+			}
+			continue
+		}
+		if err := schema.ValidateValue(schemaKey, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateConfig checks that the values of the given options of the snap
+// in the transaction match the configuration schema of the snap.
+func ValidateConfig(st *state.State, tr *config.Transaction, snapName string, keys []string) error {
+	schema, err := ConfigSchema(st, snapName)
+	if _, ok := err.(*snap.NotInstalledError); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(schema) == 0 {
+		return nil
+	}
+	if err := validateConfig(tr, snapName, schema, keys); err != nil {
+		return &SchemaError{Snap: snapName, Err: err}
+	}
+	return nil
+}
+
+// validatePatch checks that applying the patch to the configuration of the
+// snap leaves it matching the configuration schema of the snap.
+func validatePatch(st *state.State, snapName string, patch map[string]interface{}) error {
+	schema, err := ConfigSchema(st, snapName)
+	if err != nil {
+		return err
+	}
+	if len(schema) == 0 {
+		return nil
+	}
+	tr := config.NewTransaction(st)
+	for key, value := range patch {
+		if err := tr.Set(snapName, key, value); err != nil {
+			return err
+		}
+	}
+	if err := validateConfig(tr, snapName, schema, nil); err != nil {
+		return &SchemaError{Snap: snapName, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type schemaSuite struct {
+	state *state.State
+}
+
+var _ = Suite(&schemaSuite{})
+
+const schemaSnapYaml = `name: test-snap
+version: 1
+config-schema:
+  port:
+    type: int
+    min: 1
+    default: 80
+  mode:
+    type: string
+    enum: [fast, slow]
+    required: true
+  log.level:
+    type: string
+`
+
+func (s *schemaSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "app",
+	})
+	snaptest.MockSnapCurrent(c, schemaSnapYaml, si)
+}
+
+func (s *schemaSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *schemaSuite) TestConfigSchema(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	schema, err := configstate.ConfigSchema(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(schema.Keys(), DeepEquals, []string{"log.level", "mode", "port"})
+
+	_, err = configstate.ConfigSchema(s.state, "other-snap")
+	c.Check(err, ErrorMatches, `snap "other-snap" is not installed`)
+}
+
+func (s *schemaSuite) TestConfigureInstalledValidates(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, t := range []struct {
+		patch map[string]interface{}
+		err   string
+	}{
+		{map[string]interface{}{"mode": "fast"}, ""},
+		{map[string]interface{}{"mode": "slow", "port": 8080, "other": true}, ""},
+		{map[string]interface{}{"port": 8080}, `cannot set configuration of snap "test-snap": option "mode" is required`},
+		{map[string]interface{}{"mode": "medium"}, `cannot set configuration of snap "test-snap": invalid value for option "mode": must be one of "fast", "slow"`},
+		{map[string]interface{}{"mode": "fast", "port": 0}, `cannot set configuration of snap "test-snap": invalid value for option "port": must be at least 1`},
+		{map[string]interface{}{"mode": "fast", "log": map[string]interface{}{"level": 1}}, `cannot set configuration of snap "test-snap": invalid value for option "log.level": expected string, got int`},
+	} {
+		_, err := configstate.ConfigureInstalled(s.state, "test-snap", t.patch, 0)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("%v", t.patch))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("%v", t.patch))
+			c.Check(err, FitsTypeOf, &configstate.SchemaError{})
+		}
+	}
+}
+
+func (s *schemaSuite) TestConfigureInstalledValidatesAgainstCurrent(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("test-snap", "mode", "fast"), IsNil)
+	tr.Commit()
+
+	_, err := configstate.ConfigureInstalled(s.state, "test-snap", map[string]interface{}{"port": 8080}, 0)
+	c.Check(err, IsNil)
+
+	// a required option cannot be unset
+	_, err = configstate.ConfigureInstalled(s.state, "test-snap", map[string]interface{}{"mode": nil}, 0)
+	c.Check(err, ErrorMatches, `cannot set configuration of snap "test-snap": option "mode" is required`)
+}
+
+func (s *schemaSuite) TestValidateConfigKeys(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("test-snap", "port", 8080), IsNil)
+
+	// only the given options are checked, so that they can be set one at
+	// a time
+	c.Check(configstate.ValidateConfig(s.state, tr, "test-snap", []string{"port"}), IsNil)
+	c.Check(configstate.ValidateConfig(s.state, tr, "test-snap", nil), ErrorMatches, `cannot set configuration of snap "test-snap": option "mode" is required`)
+
+	c.Assert(tr.Set("test-snap", "log", map[string]interface{}{"level": true}), IsNil)
+	c.Check(configstate.ValidateConfig(s.state, tr, "test-snap", []string{"port"}), IsNil)
+	c.Check(configstate.ValidateConfig(s.state, tr, "test-snap", []string{"log"}), ErrorMatches, `.*invalid value for option "log.level": expected string, got bool`)
+
+	// snaps that are not installed have no schema
+	c.Check(configstate.ValidateConfig(s.state, tr, "other-snap", nil), IsNil)
+}
@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// secretOptions returns the options declared secret in the configuration
+// schema of the snap.
+func secretOptions(st *state.State, snapName string) (map[string]bool, error) {
+	// the options of the "core" snap/pseudonym are handled internally
+	if snapName == "core" {
+		return configcoreSecretOptions(), nil
+	}
+	schema, err := ConfigSchema(st, snapName)
+	if _, ok := err.(*snap.NotInstalledError); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var secrets map[string]bool
+	for key, opt := range schema {
+		if opt.Secret {
+			if secrets == nil {
+				secrets = make(map[string]bool)
+			}
+			secrets[key] = true
+		}
+	}
+	return secrets, nil
+}
+
+// hasSecretsUnder returns whether any of the secret options is nested
+// under the option with the given dotted path.
+func hasSecretsUnder(secrets map[string]bool, path string) bool {
+	for key := range secrets {
+		if strings.HasPrefix(key, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// EncryptSecrets encrypts the values of the patch for the given snap that
+// are for options declared secret in its configuration schema, or all of
+// them if all is set. Secret options nested in object values of the patch
+// are encrypted too. Secret values must be strings, or nil to unset the
+// option.
+func EncryptSecrets(st *state.State, snapName string, patch map[string]interface{}, all bool) error {
+	secrets, err := secretOptions(st, snapName)
+	if err != nil {
+		return err
+	}
+	return encryptSecrets(snapName, "", patch, secrets, all)
+}
+
+func encryptSecrets(snapName, prefix string, values map[string]interface{}, secrets map[string]bool, all bool) error {
+	for key, value := range values {
+		path := prefix + key
+		if !all && !secrets[path] {
+			if nested, ok := value.(map[string]interface{}); ok && hasSecretsUnder(secrets, path) {
+				if err := encryptSecrets(snapName, path+".", nested, secrets, false); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if value == nil || config.IsSecret(value) {
+			continue
+		}
+		plaintext, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("cannot set secret option %q of snap %q: value is not a string", path, snapName)
+		}
+		secret, err := config.NewSecret(snapName, plaintext)
+		if err != nil {
+			return err
+		}
+		values[key] = secret
+	}
+	return nil
+}
+
+// EncryptSecretOptions encrypts the values of the given options of the snap
+// in the transaction that are declared secret in its configuration schema.
+func EncryptSecretOptions(st *state.State, tr *config.Transaction, snapName string, keys []string) error {
+	secrets, err := secretOptions(st, snapName)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if secrets[key] {
+			if err := encryptSecretOption(tr, snapName, key); err != nil {
+				return err
+			}
+			continue
+		}
+		if !hasSecretsUnder(secrets, key) {
+			continue
+		}
+		// the option was set to an object, which can have secret
+		// options nested in it
+		var value interface{}
+		if err := tr.Get(snapName, key, &value); err != nil {
+			if config.IsNoOption(err) {
+				continue
+			}
+			return err
+		}
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := encryptSecrets(snapName, key+".", nested, secrets, false); err != nil {
+			return err
+		}
+		if err := tr.Set(snapName, key, nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encryptSecretOption(tr *config.Transaction, snapName, key string) error {
+	var value interface{}
+	if err := tr.Get(snapName, key, &value); err != nil {
+		if config.IsNoOption(err) {
+			return nil
+		}
+		return err
+	}
+	if plaintext, ok := value.(string); ok {
+		return tr.SetSecret(snapName, key, plaintext)
+	}
+	return nil
+}
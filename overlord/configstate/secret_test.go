@@ -0,0 +1,193 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type secretSuite struct {
+	state *state.State
+}
+
+var _ = Suite(&secretSuite{})
+
+func (s *secretSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "app",
+	})
+	snaptest.MockSnapCurrent(c, `name: test-snap
+version: 1
+config-schema:
+  token:
+    type: string
+    secret: true
+  port:
+    type: int
+  db.password:
+    type: string
+    secret: true
+`, si)
+}
+
+func (s *secretSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *secretSuite) TestEncryptSecretsFromSchema(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	patch := map[string]interface{}{"token": "hunter2", "port": 80, "other": "value"}
+	c.Assert(configstate.EncryptSecrets(s.state, "test-snap", patch, false), IsNil)
+	c.Check(config.IsSecret(patch["token"]), Equals, true)
+	c.Check(patch["port"], Equals, 80)
+	c.Check(patch["other"], Equals, "value")
+
+	revealed, err := config.RevealSecrets("test-snap", patch["token"])
+	c.Assert(err, IsNil)
+	c.Check(revealed, Equals, "hunter2")
+}
+
+func (s *secretSuite) TestEncryptSecretsNested(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the secret option can be set by its dotted path
+	patch := map[string]interface{}{"db.password": "hunter2", "db.user": "admin"}
+	c.Assert(configstate.EncryptSecrets(s.state, "test-snap", patch, false), IsNil)
+	c.Check(config.IsSecret(patch["db.password"]), Equals, true)
+	c.Check(patch["db.user"], Equals, "admin")
+
+	revealed, err := config.RevealSecrets("test-snap", patch["db.password"])
+	c.Assert(err, IsNil)
+	c.Check(revealed, Equals, "hunter2")
+
+	// or as part of an object
+	db := map[string]interface{}{"password": "hunter2", "user": "admin"}
+	patch = map[string]interface{}{"db": db}
+	c.Assert(configstate.EncryptSecrets(s.state, "test-snap", patch, false), IsNil)
+	c.Check(config.IsSecret(db["password"]), Equals, true)
+	c.Check(db["user"], Equals, "admin")
+
+	revealed, err = config.RevealSecrets("test-snap", db["password"])
+	c.Assert(err, IsNil)
+	c.Check(revealed, Equals, "hunter2")
+
+	patch = map[string]interface{}{"db": map[string]interface{}{"password": 1234}}
+	err = configstate.EncryptSecrets(s.state, "test-snap", patch, false)
+	c.Check(err, ErrorMatches, `cannot set secret option "db.password" of snap "test-snap": value is not a string`)
+}
+
+func (s *secretSuite) TestEncryptSecretOptionsNested(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("test-snap", "token", "hunter2"), IsNil)
+	c.Assert(tr.Set("test-snap", "db", map[string]interface{}{"password": "hunter3", "user": "admin"}), IsNil)
+	c.Assert(configstate.EncryptSecretOptions(s.state, tr, "test-snap", []string{"token", "db"}), IsNil)
+
+	var value interface{}
+	c.Assert(tr.Get("test-snap", "token", &value), IsNil)
+	c.Check(config.IsSecret(value), Equals, true)
+	c.Assert(tr.Get("test-snap", "db.password", &value), IsNil)
+	c.Check(config.IsSecret(value), Equals, true)
+	c.Assert(tr.Get("test-snap", "db.user", &value), IsNil)
+	c.Check(value, Equals, "admin")
+
+	c.Assert(tr.Get("test-snap", "db", &value), IsNil)
+	revealed, err := config.RevealSecrets("test-snap", value)
+	c.Assert(err, IsNil)
+	c.Check(revealed, DeepEquals, map[string]interface{}{"password": "hunter3", "user": "admin"})
+}
+
+func (s *secretSuite) TestEncryptSecretsAll(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	patch := map[string]interface{}{"other": "value", "gone": nil}
+	c.Assert(configstate.EncryptSecrets(s.state, "test-snap", patch, true), IsNil)
+	c.Check(config.IsSecret(patch["other"]), Equals, true)
+	c.Check(patch["gone"], IsNil)
+
+	err := configstate.EncryptSecrets(s.state, "test-snap", map[string]interface{}{"port": 80}, true)
+	c.Check(err, ErrorMatches, `cannot set secret option "port" of snap "test-snap": value is not a string`)
+}
+
+func (s *secretSuite) TestConfigureInstalledEncryptsSecrets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	patch := map[string]interface{}{"token": "hunter2"}
+	_, err := configstate.ConfigureInstalled(s.state, "test-snap", patch, 0)
+	c.Assert(err, IsNil)
+	c.Check(config.IsSecret(patch["token"]), Equals, true)
+}
+
+func (s *secretSuite) TestConfigureInstalledEncryptsCoreSecrets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	remote := map[string]interface{}{"secret-access-key": "secret", "url": "s3://bucket"}
+	patch := map[string]interface{}{
+		"snapshots.remote.access-key-id": "id",
+		"snapshots":                      map[string]interface{}{"remote": remote},
+	}
+	_, err := configstate.ConfigureInstalled(s.state, "core", patch, 0)
+	c.Assert(err, IsNil)
+	c.Check(patch["snapshots.remote.access-key-id"], Equals, "id")
+	c.Check(remote["url"], Equals, "s3://bucket")
+	c.Check(config.IsSecret(remote["secret-access-key"]), Equals, true)
+
+	revealed, err := config.RevealSecrets("core", remote["secret-access-key"])
+	c.Assert(err, IsNil)
+	c.Check(revealed, Equals, "secret")
+}
+
+func (s *secretSuite) TestSecretOfOtherType(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.SetSecret("test-snap", "port", "80"), IsNil)
+	err := configstate.ValidateConfig(s.state, tr, "test-snap", []string{"port"})
+	c.Check(err, ErrorMatches, `cannot set configuration of snap "test-snap": invalid value for option "port": expected int, got secret`)
+
+	c.Assert(tr.SetSecret("test-snap", "token", "hunter2"), IsNil)
+	c.Check(configstate.ValidateConfig(s.state, tr, "test-snap", []string{"token"}), IsNil)
+}
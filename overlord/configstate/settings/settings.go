@@ -20,9 +20,15 @@
 package settings
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
 )
 
 // ProblemReportsDisabled returns true if the problem reports are disabled
@@ -39,3 +45,31 @@ func ProblemReportsDisabled(st *state.State) bool {
 
 	return disableProblemReports
 }
+
+// DownloadCachePolicy returns the download cache policy set via the
+// "core.store.cache.{max-items,max-size,max-age}" settings. Unset or
+// invalid settings are left as zero.
+//
+// The state must be locked when this is called.
+func DownloadCachePolicy(st *state.State) store.CachePolicy {
+	tr := config.NewTransaction(st)
+	get := func(key string) string {
+		var v interface{} = ""
+		if err := tr.GetMaybe("core", key, &v); err != nil {
+			logger.Noticef("cannot get download cache setting %s: %v", key, err)
+		}
+		return fmt.Sprintf("%v", v)
+	}
+
+	var policy store.CachePolicy
+	if n, err := strconv.Atoi(get("store.cache.max-items")); err == nil && n > 0 {
+		policy.MaxItems = n
+	}
+	if size, err := strutil.ParseByteSize(get("store.cache.max-size")); err == nil {
+		policy.MaxSize = size
+	}
+	if d, err := time.ParseDuration(get("store.cache.max-age")); err == nil && d > 0 {
+		policy.MaxAge = d
+	}
+	return policy
+}
@@ -21,12 +21,14 @@ package settings_test
 
 import (
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/configstate/settings"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
 )
 
 func TestT(t *testing.T) { TestingT(t) }
@@ -58,3 +60,27 @@ func (s *settingsSuite) TestSettingProblemReportsDisable(c *C) {
 
 	c.Check(settings.ProblemReportsDisabled(s.state), Equals, true)
 }
+
+func (s *settingsSuite) TestDownloadCachePolicyDefault(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(settings.DownloadCachePolicy(s.state), Equals, store.CachePolicy{})
+}
+
+func (s *settingsSuite) TestDownloadCachePolicy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "store.cache.max-items", "20")
+	tr.Set("core", "store.cache.max-size", "2kB")
+	tr.Set("core", "store.cache.max-age", "2h")
+	tr.Commit()
+
+	c.Check(settings.DownloadCachePolicy(s.state), Equals, store.CachePolicy{
+		MaxItems: 20,
+		MaxSize:  2000,
+		MaxAge:   2 * time.Hour,
+	})
+}
@@ -0,0 +1,180 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// ConfigView is a set of configuration options a snap publishes to other
+// snaps with a slot of the config-view interface.
+type ConfigView struct {
+	// Snap is the snap publishing the view
+	Snap string
+	// Keys are the published options, including their sub-options
+	Keys []string
+}
+
+func configViewOf(snapName string, cstate *ifacestate.ConnectionState) *ConfigView {
+	view := &ConfigView{Snap: snapName}
+	keys, _ := cstate.StaticSlotAttrs["keys"].([]interface{})
+	for _, k := range keys {
+		if key, ok := k.(string); ok {
+			view.Keys = append(view.Keys, key)
+		}
+	}
+	return view
+}
+
+// Includes returns whether the option is published by the view.
+func (v *ConfigView) Includes(key string) bool {
+	for _, k := range v.Keys {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Get reads the value of the published option into result. Secret values
+// are redacted, they are only revealed to the snap owning them.
+func (v *ConfigView) Get(st *state.State, key string, result *interface{}) error {
+	if !v.Includes(key) {
+		return fmt.Errorf("cannot get %q: not published by the configuration view of snap %q", key, v.Snap)
+	}
+	var value interface{}
+	if err := config.NewTransaction(st).Get(v.Snap, key, &value); err != nil {
+		return err
+	}
+	*result = config.RedactSecrets(value)
+	return nil
+}
+
+// affectedKeys returns the published options touched by the changes, in
+// order.
+func (v *ConfigView) affectedKeys(changes []*ConfigChange) []string {
+	affected := make(map[string]bool)
+	for _, change := range changes {
+		for _, k := range v.Keys {
+			switch {
+			case change.Key == k || strings.HasPrefix(change.Key, k+"."):
+				affected[change.Key] = true
+			case strings.HasPrefix(k, change.Key+"."):
+				// only part of the changed option is published
+				affected[k] = true
+			}
+		}
+	}
+	keys := make([]string, 0, len(affected))
+	for k := range affected {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// activeConfigViewConns returns the config-view connections by
+// reference, in order.
+func activeConfigViewConns(st *state.State) ([]*interfaces.ConnRef, map[string]ifacestate.ConnectionState, error) {
+	conns, err := ifacestate.ConnectionStates(st)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([]string, 0, len(conns))
+	for id, cstate := range conns {
+		if cstate.Interface != "config-view" || cstate.Undesired || cstate.HotplugGone {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	refs := make([]*interfaces.ConnRef, 0, len(ids))
+	for _, id := range ids {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		refs = append(refs, connRef)
+	}
+	return refs, conns, nil
+}
+
+// PlugConfigView returns the configuration view the given config-view
+// plug of the snap is connected to. The state must be locked.
+func PlugConfigView(st *state.State, snapName, plugName string) (*ConfigView, error) {
+	refs, conns, err := activeConfigViewConns(st)
+	if err != nil {
+		return nil, err
+	}
+	var view *ConfigView
+	for _, connRef := range refs {
+		if connRef.PlugRef.Snap != snapName || connRef.PlugRef.Name != plugName {
+			continue
+		}
+		if view != nil {
+			return nil, fmt.Errorf("cannot use configuration view of plug %q: connected to more than one view", plugName)
+		}
+		cstate := conns[connRef.ID()]
+		view = configViewOf(connRef.SlotRef.Snap, &cstate)
+	}
+	if view == nil {
+		return nil, fmt.Errorf("cannot use configuration view of plug %q: no connected config-view plug with that name", plugName)
+	}
+	return view, nil
+}
+
+// notifyConfigViewConsumers runs the view-changed hooks of the snaps
+// connected to views affected by the configuration changes, in a change of
+// their own.
+func notifyConfigViewConsumers(st *state.State, changes map[string][]*ConfigChange) error {
+	refs, conns, err := activeConfigViewConns(st)
+	if err != nil {
+		return err
+	}
+	var tasks []*state.Task
+	for _, connRef := range refs {
+		snapChanges := changes[connRef.SlotRef.Snap]
+		if len(snapChanges) == 0 {
+			continue
+		}
+		cstate := conns[connRef.ID()]
+		keys := configViewOf(connRef.SlotRef.Snap, &cstate).affectedKeys(snapChanges)
+		if len(keys) == 0 {
+			continue
+		}
+		tasks = append(tasks, hookstate.SetupViewChangedHook(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, keys))
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+	chg := st.NewChange("notify-config-views", i18n.G("Notify snaps of changed configuration views"))
+	chg.AddAll(state.NewTaskSet(tasks...))
+	st.EnsureBefore(0)
+	return nil
+}
@@ -0,0 +1,205 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type viewsSuite struct {
+	testutil.BaseTest
+	state *state.State
+}
+
+var _ = Suite(&viewsSuite{})
+
+func (s *viewsSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.state = state.New(nil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:net publisher:network": map[string]interface{}{
+			"interface":   "config-view",
+			"plug-static": map[string]interface{}{"view": "network"},
+			"slot-static": map[string]interface{}{"view": "network", "keys": []interface{}{"proxy", "dns.servers"}},
+		},
+		"other-consumer:dns publisher:dns": map[string]interface{}{
+			"interface":   "config-view",
+			"plug-static": map[string]interface{}{"view": "dns"},
+			"slot-static": map[string]interface{}{"view": "dns", "keys": []interface{}{"dns"}},
+		},
+		"other-consumer:old publisher:network": map[string]interface{}{
+			"interface": "config-view",
+			"undesired": true,
+		},
+		"consumer:home core:home": map[string]interface{}{
+			"interface": "home",
+		},
+	})
+}
+
+// setFromSnap sets the given options of the publisher snap as snapctl set
+// run from one of its apps would. The state must not be locked.
+func (s *viewsSuite) setFromSnap(c *C, values map[string]interface{}) {
+	setup := &hookstate.HookSetup{Snap: "publisher", Revision: snap.R(1)}
+	context, err := hookstate.NewContext(nil, s.state, setup, nil, "")
+	c.Assert(err, IsNil)
+
+	context.Lock()
+	defer context.Unlock()
+
+	tr := configstate.ContextTransaction(context)
+	for key, value := range values {
+		c.Assert(tr.Set("publisher", key, value), IsNil)
+	}
+	c.Assert(context.Done(), IsNil)
+}
+
+func (s *viewsSuite) TestPlugConfigView(c *C) {
+	s.setFromSnap(c, map[string]interface{}{
+		"proxy": map[string]interface{}{"http": "http://proxy:3128"},
+		"token": "abc",
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.SetSecret("publisher", "proxy.password", "hunter2"), IsNil)
+	tr.Commit()
+
+	view, err := configstate.PlugConfigView(s.state, "consumer", "net")
+	c.Assert(err, IsNil)
+	c.Check(view.Snap, Equals, "publisher")
+	c.Check(view.Keys, DeepEquals, []string{"proxy", "dns.servers"})
+
+	c.Check(view.Includes("proxy"), Equals, true)
+	c.Check(view.Includes("proxy.http"), Equals, true)
+	c.Check(view.Includes("dns.servers"), Equals, true)
+	c.Check(view.Includes("dns"), Equals, false)
+	c.Check(view.Includes("proxyfoo"), Equals, false)
+
+	var value interface{}
+	c.Assert(view.Get(s.state, "proxy.http", &value), IsNil)
+	c.Check(value, Equals, "http://proxy:3128")
+
+	// secrets stay with the publisher
+	c.Assert(view.Get(s.state, "proxy", &value), IsNil)
+	c.Check(value, DeepEquals, map[string]interface{}{
+		"http":     "http://proxy:3128",
+		"password": config.RedactedValue,
+	})
+
+	err = view.Get(s.state, "token", &value)
+	c.Check(err, ErrorMatches, `cannot get "token": not published by the configuration view of snap "publisher"`)
+	err = view.Get(s.state, "dns.servers", &value)
+	c.Check(config.IsNoOption(err), Equals, true)
+}
+
+func (s *viewsSuite) TestPlugConfigViewNotConnected(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, plug := range []string{"old", "home", "missing"} {
+		_, err := configstate.PlugConfigView(s.state, "other-consumer", plug)
+		c.Check(err, ErrorMatches, `cannot use configuration view of plug "`+plug+`": no connected config-view plug with that name`)
+	}
+}
+
+// viewChangedHooks returns the changed keys passed to the view-changed
+// hooks created since the last call, by snap and hook.
+func (s *viewsSuite) viewChangedHooks(c *C) map[string][]string {
+	hooks := make(map[string][]string)
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() != "notify-config-views" || chg.IsReady() {
+			continue
+		}
+		for _, t := range chg.Tasks() {
+			var setup hookstate.HookSetup
+			c.Assert(t.Get("hook-setup", &setup), IsNil)
+			c.Check(setup.Optional, Equals, true)
+			c.Check(setup.IgnoreError, Equals, true)
+			var hookCtx map[string][]string
+			c.Assert(t.Get("hook-context", &hookCtx), IsNil)
+			hooks[setup.Snap+":"+setup.Hook] = hookCtx["changed-keys"]
+			t.SetStatus(state.DoneStatus)
+		}
+	}
+	return hooks
+}
+
+func (s *viewsSuite) TestNotifiesConsumers(c *C) {
+	s.setFromSnap(c, map[string]interface{}{"proxy.http": "http://proxy:3128"})
+	s.state.Lock()
+	c.Check(s.viewChangedHooks(c), DeepEquals, map[string][]string{
+		"consumer:view-changed-net": {"proxy.http"},
+	})
+	s.state.Unlock()
+
+	s.setFromSnap(c, map[string]interface{}{
+		"dns": map[string]interface{}{"servers": "1.1.1.1", "search": "lan"},
+	})
+	s.state.Lock()
+	c.Check(s.viewChangedHooks(c), DeepEquals, map[string][]string{
+		"consumer:view-changed-net":       {"dns.servers"},
+		"other-consumer:view-changed-dns": {"dns.search", "dns.servers"},
+	})
+	s.state.Unlock()
+
+	// only the published part of dns is seen as changed by consumer
+	s.setFromSnap(c, map[string]interface{}{"dns": nil})
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.viewChangedHooks(c), DeepEquals, map[string][]string{
+		"consumer:view-changed-net":       {"dns.servers"},
+		"other-consumer:view-changed-dns": {"dns"},
+	})
+	c.Check(s.state.Changes(), HasLen, 3)
+}
+
+// changesCount returns the number of changes in the state.
+func (s *viewsSuite) changesCount() int {
+	s.state.Lock()
+	defer s.state.Unlock()
+	return len(s.state.Changes())
+}
+
+func (s *viewsSuite) TestNoNotificationForUnpublishedOptions(c *C) {
+	s.setFromSnap(c, map[string]interface{}{"token": "abc"})
+	c.Check(s.changesCount(), Equals, 0)
+
+	// nor for setting the same value again
+	s.setFromSnap(c, map[string]interface{}{"proxy.http": "http://proxy:3128"})
+	c.Check(s.changesCount(), Equals, 1)
+	s.setFromSnap(c, map[string]interface{}{"proxy.http": "http://proxy:3128"})
+	c.Check(s.changesCount(), Equals, 1)
+}
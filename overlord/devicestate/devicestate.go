@@ -235,6 +235,7 @@ func delayedCrossMgrInit() {
 	snapstate.IsOnMeteredConnection = netutil.IsOnMeteredConnection
 	snapstate.DeviceCtx = DeviceCtx
 	snapstate.Remodeling = Remodeling
+	snapstate.DeviceIdentity = internal.Device
 }
 
 // proxyStore returns the store assertion for the proxy store if one is set.
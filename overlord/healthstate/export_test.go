@@ -21,6 +21,12 @@ package healthstate
 
 import (
 	"time"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 func MockCheckTimeout(t time.Duration) (restore func()) {
@@ -32,3 +38,35 @@ func MockCheckTimeout(t time.Duration) (restore func()) {
 }
 
 var KnownStatuses = knownStatuses
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+func MockMaxHistory(n int) (restore func()) {
+	old := maxHistory
+	maxHistory = n
+	return func() {
+		maxHistory = old
+	}
+}
+
+func MockServicestateControl(f func(*state.State, []*snap.AppInfo, *servicestate.Instruction, *servicestate.Flags, *hookstate.Context) ([]*state.TaskSet, error)) (restore func()) {
+	old := servicestateControl
+	servicestateControl = f
+	return func() {
+		servicestateControl = old
+	}
+}
+
+func MockSnapstateRevert(f func(*state.State, string, snapstate.Flags) (*state.TaskSet, error)) (restore func()) {
+	old := snapstateRevert
+	snapstateRevert = f
+	return func() {
+		snapstateRevert = old
+	}
+}
@@ -0,0 +1,315 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	timeNow = time.Now
+
+	servicestateControl = servicestate.Control
+	snapstateRevert     = snapstate.Revert
+)
+
+// defaultErrorThreshold is how long a snap needs to be in error before it
+// is remediated, unless health.error-threshold says otherwise.
+const defaultErrorThreshold = time.Hour
+
+// The ways a snap that stays in error can be remediated, as set in the
+// health.remediation option.
+const (
+	RemediationNone    = "none"
+	RemediationWarn    = "warn"
+	RemediationRestart = "restart"
+	RemediationRevert  = "revert"
+)
+
+// HealthManager runs the check-health hook of the snaps that declare an
+// interval for it, and remediates the snaps that stay in error.
+type HealthManager struct {
+	state *state.State
+	// lastCheck is when the health check of a snap was last scheduled
+	lastCheck map[string]time.Time
+}
+
+// Manager returns a new HealthManager, registering the check-health hook
+// handler with the given hook manager.
+func Manager(st *state.State, hookManager *hookstate.HookManager) *HealthManager {
+	Init(hookManager)
+	return &HealthManager{
+		state:     st,
+		lastCheck: make(map[string]time.Time),
+	}
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *HealthManager) Ensure() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	// remediate first, so that the checks scheduled below do not hold
+	// back the remediation of the snaps they are about
+	if err := m.remediate(); err != nil {
+		return err
+	}
+	return m.scheduleChecks()
+}
+
+// scheduleChecks starts a change running the check-health hook of each
+// active snap whose check interval has elapsed since its health was last
+// set or checked.
+func (m *HealthManager) scheduleChecks() error {
+	snapStates, err := snapstate.All(m.state)
+	if err != nil {
+		return err
+	}
+	healths, err := All(m.state)
+	if err != nil {
+		return err
+	}
+
+	now := timeNow()
+	var next time.Time
+	for name, snapst := range snapStates {
+		if !snapst.Active {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			logger.Noticef("cannot get info of snap %q to schedule its health check: %v", name, err)
+			continue
+		}
+		hook := info.Hooks["check-health"]
+		if hook == nil || hook.Interval <= 0 {
+			continue
+		}
+		interval := time.Duration(hook.Interval)
+
+		last := m.lastCheck[name]
+		if health := healths[name]; health != nil && health.Timestamp.After(last) {
+			last = health.Timestamp
+		}
+		if due := last.Add(interval); due.After(now) {
+			if next.IsZero() || due.Before(next) {
+				next = due
+			}
+			continue
+		}
+		if err := snapstate.CheckChangeConflict(m.state, name, nil); err != nil {
+			// something else is going on with the snap, try again
+			// next time around
+			continue
+		}
+
+		chg := m.state.NewChange("check-health", fmt.Sprintf("Run periodic health check of %q snap", name))
+		chg.AddTask(Hook(m.state, name, snapst.Current))
+		chg.Set("snap-names", []string{name})
+		m.lastCheck[name] = now
+		if due := now.Add(interval); next.IsZero() || due.Before(next) {
+			next = due
+		}
+	}
+
+	if !next.IsZero() {
+		m.state.EnsureBefore(next.Sub(now))
+	}
+	return nil
+}
+
+// remediationConfig returns the remediation to apply to snaps that stay in
+// error, and for how long they need to be in error for it to be applied.
+func remediationConfig(st *state.State) (remediation string, threshold time.Duration, err error) {
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "health.remediation", &remediation); err != nil && !config.IsNoOption(err) {
+		return "", 0, err
+	}
+	var thresholdStr string
+	if err := tr.Get("core", "health.error-threshold", &thresholdStr); err != nil && !config.IsNoOption(err) {
+		return "", 0, err
+	}
+	threshold = defaultErrorThreshold
+	if thresholdStr != "" {
+		threshold, err = time.ParseDuration(thresholdStr)
+		if err != nil {
+			return "", 0, fmt.Errorf("health.error-threshold cannot be parsed: %v", err)
+		}
+	}
+	return remediation, threshold, nil
+}
+
+// errorSince returns since when the snap has been in error according to
+// its health history, or the zero time if it is not in error.
+func errorSince(history []*HealthState) time.Time {
+	if len(history) == 0 {
+		return time.Time{}
+	}
+	last := history[len(history)-1]
+	if last.Status != ErrorStatus {
+		return time.Time{}
+	}
+	return last.Timestamp
+}
+
+// remediate applies the configured remediation to each snap that has been
+// in error for longer than the threshold, once per error.
+func (m *HealthManager) remediate() error {
+	remediation, threshold, err := remediationConfig(m.state)
+	if err != nil {
+		return err
+	}
+	if remediation == "" || remediation == RemediationNone {
+		return nil
+	}
+
+	history, err := History(m.state)
+	if err != nil {
+		return err
+	}
+	var remediated map[string]time.Time
+	if err := m.state.Get("health-remediated", &remediated); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if remediated == nil {
+		remediated = make(map[string]time.Time)
+	}
+
+	now := timeNow()
+	var next time.Time
+	changed := false
+	for name, snapHistory := range history {
+		since := errorSince(snapHistory)
+		if since.IsZero() || remediated[name].Equal(since) {
+			continue
+		}
+		if due := since.Add(threshold); due.After(now) {
+			if next.IsZero() || due.Before(next) {
+				next = due
+			}
+			continue
+		}
+		done, err := m.remediateSnap(name, remediation, now.Sub(since))
+		if err != nil {
+			logger.Noticef("cannot remediate snap %q: %v", name, err)
+			m.state.Warnf("cannot remediate snap %q that has been in error for %s: %v", name, now.Sub(since).Round(time.Second), err)
+			done = true
+		}
+		if done {
+			remediated[name] = since
+			changed = true
+		}
+	}
+	if changed {
+		m.state.Set("health-remediated", remediated)
+	}
+
+	if !next.IsZero() {
+		m.state.EnsureBefore(next.Sub(now))
+	}
+	return nil
+}
+
+// pendingCheck returns the ID of the change running the health check of the
+// given snap, if any.
+func (m *HealthManager) pendingCheck(name string) string {
+	for _, chg := range m.state.Changes() {
+		if chg.Kind() != "check-health" || chg.Status().Ready() {
+			continue
+		}
+		var snapNames []string
+		if err := chg.Get("snap-names", &snapNames); err != nil {
+			continue
+		}
+		if strutil.ListContains(snapNames, name) {
+			return chg.ID()
+		}
+	}
+	return ""
+}
+
+// remediateSnap applies the remediation to the given snap, returning false
+// if it needs to be tried again later. An error means the remediation
+// cannot be applied at all.
+func (m *HealthManager) remediateSnap(name, remediation string, inError time.Duration) (bool, error) {
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(m.state, name, &snapst); err != nil {
+		if err == state.ErrNoState {
+			// the snap is gone, nothing to remediate
+			return true, nil
+		}
+		return true, err
+	}
+	if !snapst.Active {
+		return true, nil
+	}
+	// the health checks of the snap are not in the way of remediating it
+	if err := snapstate.CheckChangeConflictMany(m.state, []string{name}, m.pendingCheck(name)); err != nil {
+		// try again once whatever is going on with the snap is done
+		return false, nil
+	}
+
+	inError = inError.Round(time.Second)
+	switch remediation {
+	case RemediationWarn:
+		m.state.Warnf("snap %q has been in error for %s", name, inError)
+	case RemediationRestart:
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return true, err
+		}
+		if len(info.Services()) == 0 {
+			return true, errors.New("snap has no services to restart")
+		}
+		inst := &servicestate.Instruction{Action: "restart", Names: []string{name}}
+		tss, err := servicestateControl(m.state, info.Services(), inst, nil, nil)
+		if err != nil {
+			return true, err
+		}
+		chg := m.state.NewChange("service-control", fmt.Sprintf("Restart services of %q snap in error for %s", name, inError))
+		for _, ts := range tss {
+			chg.AddAll(ts)
+		}
+		chg.Set("snap-names", []string{name})
+	case RemediationRevert:
+		ts, err := snapstateRevert(m.state, name, snapstate.Flags{})
+		if err != nil {
+			return true, err
+		}
+		chg := m.state.NewChange("revert-snap", fmt.Sprintf("Revert %q snap in error for %s", name, inError))
+		chg.AddAll(ts)
+		chg.Set("snap-names", []string{name})
+	default:
+		return true, fmt.Errorf("unknown remediation %q", remediation)
+	}
+	logger.Noticef("snap %q in error for %s, applied %q remediation", name, inError, remediation)
+	m.state.EnsureBefore(0)
+	return true, nil
+}
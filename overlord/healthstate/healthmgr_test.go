@@ -0,0 +1,278 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate_test
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type healthMgrSuite struct {
+	testutil.BaseTest
+	state *state.State
+	mgr   *healthstate.HealthManager
+	now   time.Time
+}
+
+var _ = check.Suite(&healthMgrSuite{})
+
+const healthSnapYaml = `name: test-snap
+version: v1
+apps:
+  svc:
+    command: bin/svc
+    daemon: simple
+hooks:
+  check-health:
+    interval: 10m
+`
+
+func (s *healthMgrSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.now = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return s.now }))
+
+	o := overlord.Mock()
+	s.state = o.State()
+	hookMgr, err := hookstate.Manager(s.state, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	s.mgr = healthstate.Manager(s.state, hookMgr)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	sideInfo := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(42)}
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{sideInfo},
+		Current:  snap.R(42),
+		Active:   true,
+		SnapType: "app",
+	})
+	snaptest.MockSnapCurrent(c, healthSnapYaml, sideInfo)
+}
+
+// setHealth records the health of test-snap as its check-health hook would.
+// The state must not be locked.
+func (s *healthMgrSuite) setHealth(c *check.C, status healthstate.HealthStatus, timestamp time.Time) {
+	ctx, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "test-snap"}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	ctx.Lock()
+	defer ctx.Unlock()
+	ctx.Set("health", &healthstate.HealthState{
+		Revision:  snap.R(42),
+		Timestamp: timestamp,
+		Status:    status,
+		Message:   "something is wrong",
+	})
+	c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+}
+
+func (s *healthMgrSuite) setRemediation(c *check.C, remediation, threshold string) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "health.remediation", remediation), check.IsNil)
+	if threshold != "" {
+		c.Assert(tr.Set("core", "health.error-threshold", threshold), check.IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *healthMgrSuite) changes(kind string) []*state.Change {
+	var chgs []*state.Change
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == kind {
+			chgs = append(chgs, chg)
+		}
+	}
+	return chgs
+}
+
+func (s *healthMgrSuite) TestEnsureSchedulesChecks(c *check.C) {
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	s.state.Lock()
+	chgs := s.changes("check-health")
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Summary(), check.Equals, `Run periodic health check of "test-snap" snap`)
+	tasks := chgs[0].Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	var hooksup hookstate.HookSetup
+	c.Assert(tasks[0].Get("hook-setup", &hooksup), check.IsNil)
+	c.Check(hooksup.Hook, check.Equals, "check-health")
+	c.Check(hooksup.Revision, check.Equals, snap.R(42))
+	// pretend the check ran
+	chgs[0].SetStatus(state.DoneStatus)
+	s.state.Unlock()
+	s.setHealth(c, healthstate.OkayStatus, s.now)
+
+	// not due yet
+	s.now = s.now.Add(5 * time.Minute)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.changes("check-health"), check.HasLen, 1)
+	s.state.Unlock()
+
+	// due
+	s.now = s.now.Add(5 * time.Minute)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.changes("check-health"), check.HasLen, 2)
+	s.state.Unlock()
+}
+
+func (s *healthMgrSuite) TestEnsureNoInterval(c *check.C) {
+	// replace the snap.yaml of the revision mocked in SetUpTest, whose
+	// current symlink already exists
+	sideInfo := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(42)}
+	snaptest.MockSnap(c, "{name: test-snap, version: v1}", sideInfo)
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.changes("check-health"), check.HasLen, 0)
+}
+
+func (s *healthMgrSuite) TestRemediateWarn(c *check.C) {
+	s.state.Lock()
+	s.setRemediation(c, "warn", "30m")
+	s.state.Unlock()
+	s.setHealth(c, healthstate.ErrorStatus, s.now.Add(-20*time.Minute))
+
+	// not in error for long enough
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	c.Check(s.state.AllWarnings(), check.HasLen, 0)
+	// the pending health check does not hold back the remediation below
+	c.Check(s.changes("check-health"), check.HasLen, 1)
+	s.state.Unlock()
+
+	s.now = s.now.Add(15 * time.Minute)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	warns := s.state.AllWarnings()
+	c.Assert(warns, check.HasLen, 1)
+	c.Check(warns[0].String(), check.Equals, `snap "test-snap" has been in error for 35m0s`)
+	s.state.Unlock()
+
+	// only once per error
+	s.now = s.now.Add(time.Hour)
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.AllWarnings(), check.HasLen, 1)
+}
+
+func (s *healthMgrSuite) TestRemediateNotConfigured(c *check.C) {
+	s.setHealth(c, healthstate.ErrorStatus, s.now.Add(-48*time.Hour))
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.AllWarnings(), check.HasLen, 0)
+	c.Check(s.changes("service-control"), check.HasLen, 0)
+	c.Check(s.changes("revert-snap"), check.HasLen, 0)
+}
+
+func (s *healthMgrSuite) TestRemediateRestart(c *check.C) {
+	var restarted []string
+	s.AddCleanup(healthstate.MockServicestateControl(func(st *state.State, appInfos []*snap.AppInfo, inst *servicestate.Instruction, flags *servicestate.Flags, context *hookstate.Context) ([]*state.TaskSet, error) {
+		c.Check(inst.Action, check.Equals, "restart")
+		for _, app := range appInfos {
+			restarted = append(restarted, app.Name)
+		}
+		return []*state.TaskSet{state.NewTaskSet(st.NewTask("service-control", "restart"))}, nil
+	}))
+
+	s.state.Lock()
+	s.setRemediation(c, "restart", "")
+	s.state.Unlock()
+	s.setHealth(c, healthstate.ErrorStatus, s.now.Add(-2*time.Hour))
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(restarted, check.DeepEquals, []string{"svc"})
+	chgs := s.changes("service-control")
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Summary(), check.Equals, `Restart services of "test-snap" snap in error for 2h0m0s`)
+	c.Check(chgs[0].Tasks(), check.HasLen, 1)
+}
+
+func (s *healthMgrSuite) TestRemediateRevert(c *check.C) {
+	var reverted []string
+	s.AddCleanup(healthstate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags) (*state.TaskSet, error) {
+		reverted = append(reverted, name)
+		return state.NewTaskSet(st.NewTask("revert", "revert")), nil
+	}))
+
+	s.state.Lock()
+	s.setRemediation(c, "revert", "1h")
+	s.state.Unlock()
+	s.setHealth(c, healthstate.ErrorStatus, s.now.Add(-2*time.Hour))
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(reverted, check.DeepEquals, []string{"test-snap"})
+	chgs := s.changes("revert-snap")
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Summary(), check.Equals, `Revert "test-snap" snap in error for 2h0m0s`)
+}
+
+func (s *healthMgrSuite) TestRemediateRevertFails(c *check.C) {
+	s.AddCleanup(healthstate.MockSnapstateRevert(func(st *state.State, name string, flags snapstate.Flags) (*state.TaskSet, error) {
+		return nil, errors.New("no revision to revert to")
+	}))
+
+	s.state.Lock()
+	s.setRemediation(c, "revert", "1h")
+	s.state.Unlock()
+	s.setHealth(c, healthstate.ErrorStatus, s.now.Add(-2*time.Hour))
+
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.changes("revert-snap"), check.HasLen, 0)
+	warns := s.state.AllWarnings()
+	c.Assert(warns, check.HasLen, 1)
+	c.Check(warns[0].String(), check.Equals, `cannot remediate snap "test-snap" that has been in error for 2h0m0s: no revision to revert to`)
+}
@@ -36,6 +36,9 @@ import (
 
 var checkTimeout = 30 * time.Second
 
+// maxHistory is how many health transitions are kept per snap.
+var maxHistory = 20
+
 func init() {
 	if s, ok := os.LookupEnv("SNAPD_CHECK_HEALTH_HOOK_TIMEOUT"); ok {
 		if to, err := time.ParseDuration(s); err == nil {
@@ -46,6 +49,7 @@ func init() {
 	}
 
 	snapstate.CheckHealthHook = Hook
+	snapstate.HealthError = healthError
 }
 
 func Hook(st *state.State, snapName string, snapRev snap.Revision) *state.Task {
@@ -176,6 +180,33 @@ func appendHealth(ctx *hookstate.Context, health *HealthState) error {
 	hs[ctx.InstanceName()] = health
 	st.Set("health", hs)
 
+	return appendHistory(st, ctx.InstanceName(), health)
+}
+
+// appendHistory records the health of the snap in its history if it is a
+// transition, i.e. if its status or revision changed.
+func appendHistory(st *state.State, snapName string, health *HealthState) error {
+	var history map[string][]*HealthState
+	if err := st.Get("health-history", &history); err != nil {
+		if err != state.ErrNoState {
+			return err
+		}
+		history = map[string][]*HealthState{}
+	}
+	snapHistory := history[snapName]
+	if n := len(snapHistory); n > 0 {
+		last := snapHistory[n-1]
+		if last.Status == health.Status && last.Revision == health.Revision {
+			return nil
+		}
+	}
+	snapHistory = append(snapHistory, health)
+	if len(snapHistory) > maxHistory {
+		snapHistory = snapHistory[len(snapHistory)-maxHistory:]
+	}
+	history[snapName] = snapHistory
+	st.Set("health-history", history)
+
 	return nil
 }
 
@@ -203,6 +234,31 @@ func All(st *state.State) (map[string]*HealthState, error) {
 	return hs, nil
 }
 
+// History returns the health transitions of all snaps, oldest first.
+func History(st *state.State) (map[string][]*HealthState, error) {
+	var history map[string][]*HealthState
+	if err := st.Get("health-history", &history); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	return history, nil
+}
+
+// healthError returns the message of the error the given revision of the
+// snap set as its health since the given time, if any.
+func healthError(st *state.State, snapName string, rev snap.Revision, since time.Time) (string, error) {
+	health, err := Get(st, snapName)
+	if err != nil {
+		return "", err
+	}
+	if health == nil || health.Status != ErrorStatus || health.Revision != rev || health.Timestamp.Before(since) {
+		return "", nil
+	}
+	if health.Message == "" {
+		return "no message given", nil
+	}
+	return health.Message, nil
+}
+
 func Get(st *state.State, snap string) (*HealthState, error) {
 	var hs map[string]json.RawMessage
 	if err := st.Get("health", &hs); err != nil {
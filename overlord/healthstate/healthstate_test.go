@@ -252,3 +252,88 @@ func (s *healthSuite) TestSetFromHookContextEmpty(c *check.C) {
 	// no health in the context -> no health in state
 	c.Check(s.state.Get("health", &hs), check.Equals, state.ErrNoState)
 }
+
+func (s *healthSuite) TestHistory(c *check.C) {
+	s.AddCleanup(healthstate.MockMaxHistory(3))
+
+	ctx, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "foo"}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, status := range []healthstate.HealthStatus{
+		healthstate.OkayStatus,
+		healthstate.OkayStatus,
+		healthstate.WaitingStatus,
+		healthstate.ErrorStatus,
+		healthstate.ErrorStatus,
+		healthstate.OkayStatus,
+	} {
+		ctx.Set("health", &healthstate.HealthState{
+			Revision:  snap.R(1),
+			Timestamp: t0.Add(time.Duration(i) * time.Minute),
+			Status:    status,
+		})
+		c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+	}
+
+	history, err := healthstate.History(s.state)
+	c.Assert(err, check.IsNil)
+	// only transitions are kept, and only the latest of them
+	c.Check(history, check.DeepEquals, map[string][]*healthstate.HealthState{
+		"foo": {
+			{Revision: snap.R(1), Timestamp: t0.Add(2 * time.Minute), Status: healthstate.WaitingStatus},
+			{Revision: snap.R(1), Timestamp: t0.Add(3 * time.Minute), Status: healthstate.ErrorStatus},
+			{Revision: snap.R(1), Timestamp: t0.Add(5 * time.Minute), Status: healthstate.OkayStatus},
+		},
+	})
+
+	// a new revision is a transition too
+	ctx.Set("health", &healthstate.HealthState{
+		Revision:  snap.R(2),
+		Timestamp: t0.Add(6 * time.Minute),
+		Status:    healthstate.OkayStatus,
+	})
+	c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+
+	history, err = healthstate.History(s.state)
+	c.Assert(err, check.IsNil)
+	c.Assert(history["foo"], check.HasLen, 3)
+	c.Check(history["foo"][2].Revision, check.Equals, snap.R(2))
+}
+
+func (s *healthSuite) TestHealthError(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"foo": {Revision: snap.R(2), Timestamp: t0, Status: healthstate.ErrorStatus, Message: "broken"},
+		"bar": {Revision: snap.R(2), Timestamp: t0, Status: healthstate.ErrorStatus},
+		"baz": {Revision: snap.R(2), Timestamp: t0, Status: healthstate.WaitingStatus},
+	})
+
+	for _, t := range []struct {
+		snap     string
+		rev      snap.Revision
+		since    time.Time
+		expected string
+	}{
+		{"foo", snap.R(2), t0.Add(-time.Minute), "broken"},
+		{"bar", snap.R(2), t0.Add(-time.Minute), "no message given"},
+		// health set before
+		{"foo", snap.R(2), t0.Add(time.Minute), ""},
+		// by another revision
+		{"foo", snap.R(1), t0.Add(-time.Minute), ""},
+		// not an error
+		{"baz", snap.R(2), t0.Add(-time.Minute), ""},
+		// no health at all
+		{"quux", snap.R(2), t0.Add(-time.Minute), ""},
+	} {
+		msg, err := snapstate.HealthError(s.state, t.snap, t.rev, t.since)
+		c.Check(err, check.IsNil)
+		c.Check(msg, check.Equals, t.expected, check.Commentf("%s %s", t.snap, t.rev))
+	}
+}
@@ -44,6 +44,8 @@ type getCommand struct {
 		Keys           []string `positional-arg-name:"<keys>" description:"option keys"`
 	} `positional-args:"yes"`
 
+	View string `long:"view" value-name:"<plug>" description:"return configuration published by the snap connected to the given config-view plug"`
+
 	Document bool `short:"d" description:"always return document, even with single key"`
 	Typed    bool `short:"t" description:"strict typing with nulls and quoted strings"`
 }
@@ -80,6 +82,13 @@ by explicitly requesting that via the --plug and --slot command line options:
     $ snapctl get :myplug --slot usb-vendor
 
 This requests the "usb-vendor" setting from the slot that is connected to "myplug".
+
+Configuration published by another snap through a configuration view may be
+printed by naming the config-view plug connected to it:
+
+    $ snapctl get --view network-settings proxy.http
+
+Without option names, all the published configuration is returned.
 `)
 
 func init() {
@@ -131,7 +140,7 @@ func (c *getCommand) printValues(getByKey func(string) (interface{}, bool, error
 }
 
 func (c *getCommand) Execute(args []string) error {
-	if len(c.Positional.Keys) == 0 && c.Positional.PlugOrSlotSpec == "" {
+	if len(c.Positional.Keys) == 0 && c.Positional.PlugOrSlotSpec == "" && c.View == "" {
 		return fmt.Errorf(i18n.G("get which option?"))
 	}
 
@@ -144,6 +153,17 @@ func (c *getCommand) Execute(args []string) error {
 		return fmt.Errorf("cannot use -d and -t together")
 	}
 
+	if c.View != "" {
+		if strings.Contains(c.Positional.PlugOrSlotSpec, ":") {
+			return fmt.Errorf("cannot use --view with <snap>:<plug|slot> argument")
+		}
+		if c.Positional.PlugOrSlotSpec != "" {
+			c.Positional.Keys = append([]string{c.Positional.PlugOrSlotSpec}, c.Positional.Keys...)
+			c.Positional.PlugOrSlotSpec = ""
+		}
+		return c.getViewSetting(context)
+	}
+
 	if strings.Contains(c.Positional.PlugOrSlotSpec, ":") {
 		parts := strings.SplitN(c.Positional.PlugOrSlotSpec, ":", 2)
 		snap, name := parts[0], parts[1]
@@ -176,9 +196,50 @@ func (c *getCommand) getConfigSetting(context *hookstate.Context) error {
 	transaction := configstate.ContextTransaction(context)
 	context.Unlock()
 
+	instanceName := c.context().InstanceName()
+	return c.printValues(func(key string) (interface{}, bool, error) {
+		var value interface{}
+		err := transaction.Get(instanceName, key, &value)
+		if err == nil {
+			// the snap is the only one its secrets are revealed to
+			value, err = config.RevealSecrets(instanceName, value)
+			if err != nil {
+				return nil, false, err
+			}
+			return value, true, nil
+		}
+		if config.IsNoOption(err) {
+			if !c.Typed {
+				value = ""
+			}
+			return value, false, nil
+		}
+		return value, false, err
+	})
+}
+
+func (c *getCommand) getViewSetting(context *hookstate.Context) error {
+	if c.ForcePlugSide || c.ForceSlotSide {
+		return fmt.Errorf("cannot use --plug or --slot with --view")
+	}
+
+	st := context.State()
+	st.Lock()
+	defer st.Unlock()
+
+	view, err := configstate.PlugConfigView(st, context.InstanceName(), c.View)
+	if err != nil {
+		return err
+	}
+	if len(c.Positional.Keys) == 0 {
+		// the whole view
+		c.Positional.Keys = view.Keys
+		c.Document = true
+	}
+
 	return c.printValues(func(key string) (interface{}, bool, error) {
 		var value interface{}
-		err := transaction.Get(c.context().InstanceName(), key, &value)
+		err := view.Get(st, key, &value)
 		if err == nil {
 			return value, true, nil
 		}
@@ -250,6 +250,65 @@ func (s *getSuite) TestCommandWithoutContext(c *C) {
 	c.Check(err, ErrorMatches, ".*cannot get without a context.*")
 }
 
+var getViewTests = []struct {
+	args, stdout, error string
+}{{
+	args:   "get --view net proxy.http",
+	stdout: "http://proxy:3128\n",
+}, {
+	args:   "get --view net -t dns.servers",
+	stdout: "null\n",
+}, {
+	args:   "get --view net",
+	stdout: "{\n\t\"proxy\": {\n\t\t\"http\": \"http://proxy:3128\"\n\t}\n}\n",
+}, {
+	args:  "get --view net token",
+	error: `cannot get "token": not published by the configuration view of snap "publisher"`,
+}, {
+	args:  "get --view other proxy",
+	error: `cannot use configuration view of plug "other": no connected config-view plug with that name`,
+}, {
+	args:  "get --view net :net proxy",
+	error: `cannot use --view with <snap>:<plug\|slot> argument`,
+}, {
+	args:  "get --view net --slot proxy",
+	error: `cannot use --plug or --slot with --view`,
+}}
+
+func (s *getSuite) TestGetView(c *C) {
+	for _, test := range getViewTests {
+		c.Logf("Test: %s", test.args)
+
+		st := state.New(nil)
+		st.Lock()
+		task := st.NewTask("test-task", "my test task")
+		setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "test-hook"}
+		mockContext, err := hookstate.NewContext(task, st, setup, hooktest.NewMockHandler(), "")
+		c.Assert(err, IsNil)
+
+		st.Set("conns", map[string]interface{}{
+			"test-snap:net publisher:network": map[string]interface{}{
+				"interface":   "config-view",
+				"slot-static": map[string]interface{}{"view": "network", "keys": []interface{}{"proxy", "dns.servers"}},
+			},
+		})
+		tr := config.NewTransaction(st)
+		tr.Set("publisher", "proxy.http", "http://proxy:3128")
+		tr.Set("publisher", "token", "abc")
+		tr.Commit()
+		st.Unlock()
+
+		stdout, stderr, err := ctlcmd.Run(mockContext, strings.Fields(test.args), 0)
+		if test.error != "" {
+			c.Check(err, ErrorMatches, test.error)
+		} else {
+			c.Check(err, IsNil)
+			c.Check(string(stderr), Equals, "")
+			c.Check(string(stdout), Equals, test.stdout)
+		}
+	}
+}
+
 func (s *setSuite) TestNull(c *C) {
 	_, _, err := ctlcmd.Run(s.mockContext, []string{"set", "foo=null"}, 0)
 	c.Check(err, IsNil)
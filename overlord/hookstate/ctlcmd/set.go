@@ -100,11 +100,26 @@ func (s *setCommand) setConfigSetting(context *hookstate.Context) error {
 	tr := configstate.ContextTransaction(context)
 	context.Unlock()
 
+	instanceName := s.context().InstanceName()
+	keys := make([]string, 0, len(s.Positional.ConfValues))
+	// previous holds the values the options had before, to restore them
+	// if the new ones do not match the configuration schema
+	previous := make([]interface{}, 0, len(s.Positional.ConfValues))
+	setOption := func(key string, value interface{}) {
+		var old interface{}
+		if err := tr.Get(instanceName, key, &old); err != nil {
+			old = nil
+		}
+		tr.Set(instanceName, key, value)
+		keys = append(keys, key)
+		previous = append(previous, old)
+	}
+
 	for _, patchValue := range s.Positional.ConfValues {
 		parts := strings.SplitN(patchValue, "=", 2)
 		if len(parts) == 1 && strings.HasSuffix(patchValue, "!") {
 			key := strings.TrimSuffix(patchValue, "!")
-			tr.Set(s.context().InstanceName(), key, nil)
+			setOption(key, nil)
 			continue
 		}
 		if len(parts) != 2 {
@@ -117,10 +132,18 @@ func (s *setCommand) setConfigSetting(context *hookstate.Context) error {
 			value = parts[1]
 		}
 
-		tr.Set(s.context().InstanceName(), key, value)
+		setOption(key, value)
 	}
 
-	return nil
+	context.Lock()
+	defer context.Unlock()
+	if err := configstate.ValidateConfig(context.State(), tr, instanceName, keys); err != nil {
+		for i := len(keys) - 1; i >= 0; i-- {
+			tr.Set(instanceName, keys[i], previous[i])
+		}
+		return err
+	}
+	return configstate.EncryptSecretOptions(context.State(), tr, instanceName, keys)
 }
 
 func setInterfaceAttribute(context *hookstate.Context, staticAttrs map[string]interface{}, dynamicAttrs map[string]interface{}, key string, value interface{}) error {
@@ -25,13 +25,16 @@ import (
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
 )
 
 type setSuite struct {
@@ -99,6 +102,86 @@ func (s *setSuite) TestCommand(c *C) {
 	c.Check(value, Equals, "qux")
 }
 
+func (s *setSuite) TestCommandValidatesSchema(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	st := s.mockContext.State()
+	st.Lock()
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snapstate.Set(st, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+	})
+	snaptest.MockSnapCurrent(c, `name: test-snap
+version: 1
+config-schema:
+  port:
+    type: int
+    max: 65535
+  mode:
+    type: string
+    required: true
+`, si)
+	st.Unlock()
+
+	// options are checked as they are set, required ones included only
+	// when they are set
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set", "port=8080"}, 0)
+	c.Check(err, IsNil)
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"set", "port=80000"}, 0)
+	c.Check(err, ErrorMatches, `cannot set configuration of snap "test-snap": invalid value for option "port": must be at most 65535`)
+	// the invalid value is not kept
+	stdout, _, err := ctlcmd.Run(s.mockContext, []string{"get", "port"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "8080\n")
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"set", "mode!"}, 0)
+	c.Check(err, ErrorMatches, `cannot set configuration of snap "test-snap": option "mode" is required`)
+}
+
+func (s *setSuite) TestCommandEncryptsSecrets(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	st := s.mockContext.State()
+	st.Lock()
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snapstate.Set(st, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+	})
+	snaptest.MockSnapCurrent(c, `name: test-snap
+version: 1
+config-schema:
+  token:
+    type: string
+    secret: true
+`, si)
+	st.Unlock()
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set", "token=hunter2"}, 0)
+	c.Assert(err, IsNil)
+
+	s.mockContext.Lock()
+	c.Check(s.mockContext.Done(), IsNil)
+	s.mockContext.Unlock()
+
+	// the secret is stored encrypted
+	st.Lock()
+	tr := config.NewTransaction(st)
+	var value interface{}
+	c.Check(tr.Get("test-snap", "token", &value), IsNil)
+	st.Unlock()
+	c.Check(config.IsSecret(value), Equals, true)
+
+	// but the snap gets it in plaintext
+	stdout, _, err := ctlcmd.Run(s.mockContext, []string{"get", "token"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "hunter2\n")
+}
+
 func (s *setSuite) TestSetRegularUserForbidden(c *C) {
 	_, _, err := ctlcmd.Run(s.mockContext, []string{"set", "test-key1"}, 1000)
 	c.Assert(err, ErrorMatches, `cannot use "set" with uid 1000, try with sudo`)
@@ -93,6 +93,24 @@ func SetupGateAutoRefreshHook(st *state.State, snapName string, base, restart bo
 	return task
 }
 
+// SetupViewChangedHook returns a task running the view-changed hook of
+// the plug of a snap consuming a configuration view, if present, after
+// the given published keys changed.
+func SetupViewChangedHook(st *state.State, snapName, plugName string, changedKeys []string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:        snapName,
+		Hook:        "view-changed-" + plugName,
+		Optional:    true,
+		IgnoreError: true,
+	}
+	summary := fmt.Sprintf(i18n.G("Run hook %s of snap %q"), hooksup.Hook, hooksup.Snap)
+	hookCtx := map[string]interface{}{
+		"changed-keys": changedKeys,
+	}
+	task := HookTask(st, summary, hooksup, hookCtx)
+	return task
+}
+
 type snapHookHandler struct {
 }
 
@@ -132,4 +150,5 @@ func setupHooks(hookMgr *HookManager) {
 	hookMgr.Register(regexp.MustCompile("^pre-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^remove$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^gate-auto-refresh$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^view-changed-[-a-z0-9]+$"), handlerGenerator)
 }
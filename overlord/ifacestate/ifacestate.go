@@ -89,7 +89,14 @@ type connectOpts struct {
 // Connect returns a set of tasks for connecting an interface.
 //
 func Connect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*state.TaskSet, error) {
-	if err := snapstate.CheckChangeConflictMany(st, []string{plugSnap, slotSnap}, ""); err != nil {
+	return ConnectFromChange(st, plugSnap, plugName, slotSnap, slotName, "")
+}
+
+// ConnectFromChange is like Connect but ignores the given change when
+// checking for conflicts, for tasks of that change to connect the snaps it
+// operates on.
+func ConnectFromChange(st *state.State, plugSnap, plugName, slotSnap, slotName string, fromChange string) (*state.TaskSet, error) {
+	if err := snapstate.CheckChangeConflictMany(st, []string{plugSnap, slotSnap}, fromChange); err != nil {
 		return nil, err
 	}
 
@@ -34,16 +34,20 @@ import (
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/applystate"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/proxyconf"
+	"github.com/snapcore/snapd/overlord/configstate/settings"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/healthstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/peerstate"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -100,6 +104,9 @@ type Overlord struct {
 	deviceMgr  *devicestate.DeviceManager
 	cmdMgr     *cmdstate.CommandManager
 	shotMgr    *snapshotstate.SnapshotManager
+	healthMgr  *healthstate.HealthManager
+	applyMgr   *applystate.ApplyManager
+	peerMgr    *peerstate.PeerManager
 	// proxyConf mediates the http proxy config
 	proxyConf func(req *http.Request) (*url.URL, error)
 }
@@ -185,7 +192,10 @@ func New(restartBehavior RestartBehavior) (*Overlord, error) {
 	if err := configstateInit(s, hookMgr); err != nil {
 		return nil, err
 	}
-	healthstate.Init(hookMgr)
+	o.addManager(configstate.Manager(s))
+	o.addManager(healthstate.Manager(s, hookMgr))
+	o.addManager(applystate.Manager(s, o.runner))
+	o.addManager(peerstate.Manager(s))
 
 	// the shared task runner should be added last!
 	o.stateEng.AddManager(o.runner)
@@ -220,6 +230,12 @@ func (o *Overlord) addManager(mgr StateManager) {
 		o.cmdMgr = x
 	case *snapshotstate.SnapshotManager:
 		o.shotMgr = x
+	case *healthstate.HealthManager:
+		o.healthMgr = x
+	case *applystate.ApplyManager:
+		o.applyMgr = x
+	case *peerstate.PeerManager:
+		o.peerMgr = x
 	}
 	o.stateEng.AddManager(mgr)
 }
@@ -300,11 +316,21 @@ func verifyReboot(s *state.State, curBootID string, restartBehavior RestartBehav
 func (o *Overlord) newStoreWithContext(storeCtx store.DeviceAndAuthContext) snapstate.StoreService {
 	cfg := store.DefaultConfig()
 	cfg.Proxy = o.proxyConf
+	cfg.CachePolicy = o.downloadCachePolicy
 	sto := storeNew(cfg, storeCtx)
 	sto.SetCacheDownloads(defaultCachedDownloads)
 	return sto
 }
 
+// downloadCachePolicy returns the download cache policy from the
+// configuration.
+func (o *Overlord) downloadCachePolicy() store.CachePolicy {
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	return settings.DownloadCachePolicy(st)
+}
+
 // newStore can make new stores for use during remodeling.
 // The device backend will tie them to the remodeling device state.
 func (o *Overlord) newStore(devBE storecontext.DeviceBackend) snapstate.StoreService {
@@ -430,7 +456,9 @@ func (o *Overlord) Loop() {
 			o.ensureTimerReset()
 			// in case of errors engine logs them,
 			// continue to the next Ensure() try for now
+			start := time.Now()
 			err := o.stateEng.Ensure()
+			metrics.EnsureDuration.ObserveSince(start)
 			if err != nil && preseed {
 				st := o.State()
 				// acquire state lock to ensure nothing attempts to write state
@@ -631,6 +659,22 @@ func (o *Overlord) SnapshotManager() *snapshotstate.SnapshotManager {
 	return o.shotMgr
 }
 
+// HealthManager returns the manager responsible for snap health.
+func (o *Overlord) HealthManager() *healthstate.HealthManager {
+	return o.healthMgr
+}
+
+// ApplyManager returns the manager responsible for applying manifests.
+func (o *Overlord) ApplyManager() *applystate.ApplyManager {
+	return o.applyMgr
+}
+
+// PeerManager returns the manager responsible for sharing downloaded snaps
+// with peers.
+func (o *Overlord) PeerManager() *peerstate.PeerManager {
+	return o.peerMgr
+}
+
 // Mock creates an Overlord without any managers and with a backend
 // not using disk. Managers can be added with AddManager. For testing.
 func Mock() *Overlord {
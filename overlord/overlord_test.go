@@ -37,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
@@ -114,6 +115,9 @@ func (ovs *overlordSuite) TestNew(c *C) {
 	c.Check(o.DeviceManager(), NotNil)
 	c.Check(o.CommandManager(), NotNil)
 	c.Check(o.SnapshotManager(), NotNil)
+	c.Check(o.HealthManager(), NotNil)
+	c.Check(o.ApplyManager(), NotNil)
+	c.Check(o.PeerManager(), NotNil)
 	c.Check(configstateInitCalled, Equals, true)
 
 	o.InterfaceManager().DisableUDevMonitor()
@@ -152,6 +156,28 @@ func (ovs *overlordSuite) TestNewStore(c *C) {
 	c.Check(sto.(*store.Store).CacheDownloads(), Equals, 5)
 }
 
+func (ovs *overlordSuite) TestStoreDownloadCachePolicy(c *C) {
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "store.cache.max-size", "1GB")
+	tr.Set("core", "store.cache.max-age", "48h")
+	tr.Commit()
+	sto := snapstate.Store(st, nil)
+	st.Unlock()
+
+	cm := sto.(*store.Store).DownloadCache()
+	c.Assert(cm, NotNil)
+	c.Check(cm.Policy(), Equals, store.CachePolicy{
+		MaxItems: 5,
+		MaxSize:  1000 * 1000 * 1000,
+		MaxAge:   48 * time.Hour,
+	})
+}
+
 func (ovs *overlordSuite) TestNewWithGoodState(c *C) {
 	// ensure we don't write state load timing in the state on really
 	// slow architectures (e.g. risc-v)
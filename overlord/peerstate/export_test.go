@@ -0,0 +1,100 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package peerstate
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+type (
+	DNSMessage   = dnsMessage
+	DNSQuestion  = dnsQuestion
+	DNSRecord    = dnsRecord
+	PeerInstance = peerInstance
+)
+
+const (
+	PeerService = peerService
+	DNSTypePTR  = dnsTypePTR
+	DNSTypeSRV  = dnsTypeSRV
+	DefaultPort = defaultPort
+)
+
+var (
+	EncodeQuery    = encodeQuery
+	EncodeResponse = encodeResponse
+	DecodeMessage  = decodeMessage
+	Browse         = browse
+)
+
+func NewResponder(peerName string, port int) (io.Closer, error) {
+	return newResponder(peerName, port)
+}
+
+// MockMulticast makes mDNS use the given unicast address instead of the
+// multicast group, for the tests not to depend on multicast routing.
+func MockMulticast(addr *net.UDPAddr) (restore func()) {
+	oldGroup := mdnsGroup
+	oldListen := listenMulticast
+	mdnsGroup = addr
+	listenMulticast = func() (*net.UDPConn, error) {
+		return net.ListenUDP("udp4", addr)
+	}
+	return func() {
+		mdnsGroup = oldGroup
+		listenMulticast = oldListen
+	}
+}
+
+func MockBrowseTimeout(d time.Duration) (restore func()) {
+	old := browseTimeout
+	browseTimeout = d
+	return func() {
+		browseTimeout = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+func MockOsHostname(f func() (string, error)) (restore func()) {
+	old := osHostname
+	osHostname = f
+	return func() {
+		osHostname = old
+	}
+}
+
+func (m *PeerManager) Name() string {
+	return m.name
+}
+
+func (m *PeerManager) AdvertisedPort() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.port
+}
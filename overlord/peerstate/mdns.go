@@ -0,0 +1,379 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package peerstate
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// A minimal mDNS/DNS-SD implementation (RFC 6762, RFC 6763), just enough
+// for snapd instances to find each other on the local network: browsing
+// sends a PTR query for the peer service from an ephemeral port, and
+// responders answer it with the PTR and SRV records of their instance,
+// unicast to the querier. The address of a peer is the source address of
+// its answer.
+
+const (
+	// peerService is the DNS-SD service snapd peers advertise
+	peerService = "_snapd-peers._tcp.local."
+
+	dnsTypePTR = 12
+	dnsTypeSRV = 33
+	dnsClassIN = 1
+
+	// dnsRecordTTL is the TTL of the records in answers, as
+	// recommended for records with host names in them
+	dnsRecordTTL = 120
+)
+
+var (
+	mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+	listenMulticast = func() (*net.UDPConn, error) {
+		return net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	}
+)
+
+var errDNSShort = errors.New("dns message too short")
+
+type dnsQuestion struct {
+	Name string
+	Type uint16
+}
+
+// dnsRecord is a PTR or SRV resource record.
+type dnsRecord struct {
+	Name string
+	Type uint16
+	// Target is the name a PTR record points to, or the host of a SRV
+	// record
+	Target string
+	// Port is the port of a SRV record
+	Port uint16
+}
+
+type dnsMessage struct {
+	Response  bool
+	Questions []dnsQuestion
+	// Records holds the PTR and SRV records of all sections
+	Records []dnsRecord
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendName(b []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+func encodeQuery(name string) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint16(b[4:], 1)
+	b = appendName(b, name)
+	b = appendUint16(b, dnsTypePTR)
+	return appendUint16(b, dnsClassIN)
+}
+
+func encodeResponse(records []dnsRecord) []byte {
+	b := make([]byte, 12)
+	// authoritative answer
+	binary.BigEndian.PutUint16(b[2:], 0x8400)
+	binary.BigEndian.PutUint16(b[6:], uint16(len(records)))
+	for _, r := range records {
+		var rdata []byte
+		switch r.Type {
+		case dnsTypePTR:
+			rdata = appendName(nil, r.Target)
+		case dnsTypeSRV:
+			// priority and weight are unused
+			rdata = appendUint16(make([]byte, 4), r.Port)
+			rdata = appendName(rdata, r.Target)
+		}
+		b = appendName(b, r.Name)
+		b = appendUint16(b, r.Type)
+		b = appendUint16(b, dnsClassIN)
+		b = append(b, 0, 0)
+		b = appendUint16(b, dnsRecordTTL)
+		b = appendUint16(b, uint16(len(rdata)))
+		b = append(b, rdata...)
+	}
+	return b
+}
+
+// readName reads the possibly compressed name at off of the message,
+// returning it and the offset following it.
+func readName(b []byte, off int) (name string, next int, err error) {
+	var labels []string
+	next = -1
+	for jumps := 0; ; {
+		if off >= len(b) {
+			return "", 0, errDNSShort
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(b) {
+				return "", 0, errDNSShort
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			jumps++
+			if jumps > 16 {
+				return "", 0, errors.New("too many dns name compression pointers")
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+		case l&0xc0 != 0:
+			return "", 0, errors.New("invalid dns label")
+		default:
+			if off+1+l > len(b) {
+				return "", 0, errDNSShort
+			}
+			labels = append(labels, string(b[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
+
+func decodeMessage(b []byte) (*dnsMessage, error) {
+	if len(b) < 12 {
+		return nil, errDNSShort
+	}
+	msg := &dnsMessage{Response: b[2]&0x80 != 0}
+	qdCount := int(binary.BigEndian.Uint16(b[4:]))
+	rrCount := 0
+	for _, off := range []int{6, 8, 10} {
+		rrCount += int(binary.BigEndian.Uint16(b[off:]))
+	}
+
+	off := 12
+	for i := 0; i < qdCount; i++ {
+		name, next, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+4 > len(b) {
+			return nil, errDNSShort
+		}
+		msg.Questions = append(msg.Questions, dnsQuestion{
+			Name: name,
+			Type: binary.BigEndian.Uint16(b[next:]),
+		})
+		off = next + 4
+	}
+	for i := 0; i < rrCount; i++ {
+		name, next, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(b) {
+			return nil, errDNSShort
+		}
+		typ := binary.BigEndian.Uint16(b[next:])
+		rdLen := int(binary.BigEndian.Uint16(b[next+8:]))
+		off = next + 10
+		if off+rdLen > len(b) {
+			return nil, errDNSShort
+		}
+		switch typ {
+		case dnsTypePTR:
+			target, _, err := readName(b, off)
+			if err != nil {
+				return nil, err
+			}
+			msg.Records = append(msg.Records, dnsRecord{Name: name, Type: typ, Target: target})
+		case dnsTypeSRV:
+			if rdLen < 7 {
+				return nil, errDNSShort
+			}
+			target, _, err := readName(b, off+6)
+			if err != nil {
+				return nil, err
+			}
+			msg.Records = append(msg.Records, dnsRecord{
+				Name:   name,
+				Type:   typ,
+				Target: target,
+				Port:   binary.BigEndian.Uint16(b[off+4:]),
+			})
+		}
+		off += rdLen
+	}
+	return msg, nil
+}
+
+// instanceName returns the service instance name of the given peer name.
+func instanceName(peerName string) string {
+	return peerName + "." + peerService
+}
+
+// responder answers the mDNS queries for the peer service with the
+// records of one instance of it.
+type responder struct {
+	conn    *net.UDPConn
+	records []dnsRecord
+	done    chan struct{}
+}
+
+func newResponder(peerName string, port int) (*responder, error) {
+	conn, err := listenMulticast()
+	if err != nil {
+		return nil, err
+	}
+	instance := instanceName(peerName)
+	r := &responder{
+		conn: conn,
+		records: []dnsRecord{
+			{Name: peerService, Type: dnsTypePTR, Target: instance},
+			{Name: instance, Type: dnsTypeSRV, Target: peerName + ".local.", Port: uint16(port)},
+		},
+		done: make(chan struct{}),
+	}
+	go r.run()
+	return r, nil
+}
+
+func (r *responder) run() {
+	defer close(r.done)
+	response := encodeResponse(r.records)
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			// closed
+			return
+		}
+		msg, err := decodeMessage(buf[:n])
+		if err != nil || msg.Response {
+			continue
+		}
+		for _, q := range msg.Questions {
+			if q.Type == dnsTypePTR && strings.EqualFold(q.Name, peerService) {
+				r.conn.WriteToUDP(response, from)
+				break
+			}
+		}
+	}
+}
+
+func (r *responder) Close() error {
+	err := r.conn.Close()
+	<-r.done
+	return err
+}
+
+// peerInstance is a peer found on the network.
+type peerInstance struct {
+	Name string
+	IP   net.IP
+	Port int
+}
+
+// browse queries the network for the peer service, collecting the answers
+// until the timeout expires or the context is done.
+func browse(ctx context.Context, timeout time.Duration) ([]peerInstance, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteToUDP(encodeQuery(peerService), mdnsGroup); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetReadDeadline(deadline)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	var found []peerInstance
+	seen := make(map[string]bool)
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return found, nil
+			}
+			return nil, err
+		}
+		msg, err := decodeMessage(buf[:n])
+		if err != nil || !msg.Response {
+			continue
+		}
+		instances := make(map[string]bool)
+		for _, r := range msg.Records {
+			if r.Type == dnsTypePTR && strings.EqualFold(r.Name, peerService) {
+				instances[strings.ToLower(r.Target)] = true
+			}
+		}
+		for _, r := range msg.Records {
+			if r.Type != dnsTypeSRV || !instances[strings.ToLower(r.Name)] {
+				continue
+			}
+			suffix := len(r.Name) - len(peerService) - 1
+			if suffix <= 0 || !strings.EqualFold(r.Name[suffix:], "."+peerService) {
+				continue
+			}
+			peer := peerInstance{
+				Name: r.Name[:suffix],
+				IP:   from.IP,
+				Port: int(r.Port),
+			}
+			key := (&net.TCPAddr{IP: peer.IP, Port: peer.Port}).String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			found = append(found, peer)
+		}
+	}
+}
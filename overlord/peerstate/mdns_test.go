@@ -0,0 +1,137 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package peerstate_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/peerstate"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type mdnsSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&mdnsSuite{})
+
+// mockMulticast makes mDNS use a free port of the loopback interface.
+func mockMulticast(c *C, s *testutil.BaseTest) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	c.Assert(err, IsNil)
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	conn.Close()
+	s.AddCleanup(peerstate.MockMulticast(addr))
+}
+
+func (s *mdnsSuite) TestQueryRoundtrip(c *C) {
+	msg, err := peerstate.DecodeMessage(peerstate.EncodeQuery(peerstate.PeerService))
+	c.Assert(err, IsNil)
+	c.Check(msg, DeepEquals, &peerstate.DNSMessage{
+		Questions: []peerstate.DNSQuestion{
+			{Name: "_snapd-peers._tcp.local.", Type: peerstate.DNSTypePTR},
+		},
+	})
+}
+
+func (s *mdnsSuite) TestResponseRoundtrip(c *C) {
+	records := []peerstate.DNSRecord{
+		{Name: peerstate.PeerService, Type: peerstate.DNSTypePTR, Target: "foo-x1." + peerstate.PeerService},
+		{Name: "foo-x1." + peerstate.PeerService, Type: peerstate.DNSTypeSRV, Target: "foo-x1.local.", Port: 8738},
+	}
+	msg, err := peerstate.DecodeMessage(peerstate.EncodeResponse(records))
+	c.Assert(err, IsNil)
+	c.Check(msg, DeepEquals, &peerstate.DNSMessage{
+		Response: true,
+		Records:  records,
+	})
+}
+
+func (s *mdnsSuite) TestDecodeCompressedNames(c *C) {
+	msg := []byte{
+		// header: response, one answer
+		0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0,
+		// _snapd-peers._tcp.local.
+		12, '_', 's', 'n', 'a', 'p', 'd', '-', 'p', 'e', 'e', 'r', 's',
+		4, '_', 't', 'c', 'p',
+		5, 'l', 'o', 'c', 'a', 'l', 0,
+		// PTR IN, TTL 120, 6 bytes of data
+		0, 12, 0, 1, 0, 0, 0, 120, 0, 6,
+		// bar. followed by a pointer to the service name
+		3, 'b', 'a', 'r', 0xc0, 12,
+	}
+	m, err := peerstate.DecodeMessage(msg)
+	c.Assert(err, IsNil)
+	c.Check(m.Records, DeepEquals, []peerstate.DNSRecord{
+		{Name: peerstate.PeerService, Type: peerstate.DNSTypePTR, Target: "bar." + peerstate.PeerService},
+	})
+}
+
+func (s *mdnsSuite) TestDecodeInvalid(c *C) {
+	for _, t := range []struct {
+		msg []byte
+		err string
+	}{
+		{[]byte{0, 0, 0}, "dns message too short"},
+		{[]byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 3, 'f', 'o'}, "dns message too short"},
+		{[]byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0x80}, "invalid dns label"},
+		// a pointer to itself
+		{[]byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xc0, 12, 0, 12, 0, 1}, "too many dns name compression pointers"},
+		{[]byte{0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 12, 0, 1, 0, 0, 0, 120, 0, 6, 0}, "dns message too short"},
+	} {
+		_, err := peerstate.DecodeMessage(t.msg)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.msg))
+	}
+}
+
+func (s *mdnsSuite) TestBrowse(c *C) {
+	mockMulticast(c, &s.BaseTest)
+
+	r, err := peerstate.NewResponder("foo-x1", 1234)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	found, err := peerstate.Browse(context.Background(), 200*time.Millisecond)
+	c.Assert(err, IsNil)
+	c.Check(found, DeepEquals, []peerstate.PeerInstance{
+		{Name: "foo-x1", IP: net.IPv4(127, 0, 0, 1).To4(), Port: 1234},
+	})
+}
+
+func (s *mdnsSuite) TestBrowseNobody(c *C) {
+	mockMulticast(c, &s.BaseTest)
+
+	found, err := peerstate.Browse(context.Background(), 50*time.Millisecond)
+	c.Assert(err, IsNil)
+	c.Check(found, HasLen, 0)
+}
+
+func (s *mdnsSuite) TestBrowseCancelled(c *C) {
+	mockMulticast(c, &s.BaseTest)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := peerstate.Browse(ctx, time.Minute)
+	c.Check(err, Equals, context.Canceled)
+}
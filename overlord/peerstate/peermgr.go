@@ -0,0 +1,253 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package peerstate implements the manager sharing downloaded snaps with
+// the other snapd instances of the local network.
+package peerstate
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/randutil"
+	"github.com/snapcore/snapd/store"
+)
+
+// defaultPort is the port the download cache is served on, unless
+// store.peers.port says otherwise.
+const defaultPort = 8738
+
+var (
+	// browseTimeout is how long answers to a peer query are waited for
+	browseTimeout = time.Second
+	// peersTTL is for how long the found peers are used before the
+	// network is queried again
+	peersTTL = time.Minute
+
+	timeNow    = time.Now
+	osHostname = os.Hostname
+)
+
+// PeerManager advertises the download cache of snapd to the other snapd
+// instances of the local network over mDNS and serves it to them, if
+// store.peers.advertise is set, and finds the peers to download snaps
+// from before the store, if store.peers.enabled is set.
+type PeerManager struct {
+	state *state.State
+	// name is the name of this instance on the network
+	name string
+
+	mu sync.Mutex
+	// port is the port the download cache is advertised on, 0 if not
+	// advertised
+	port      int
+	server    *http.Server
+	responder *responder
+
+	peersMu    sync.Mutex
+	peers      []store.Peer
+	peersFound time.Time
+}
+
+// Manager returns a new PeerManager, setting the snapstate hook through
+// which snap downloads find their peers.
+func Manager(st *state.State) *PeerManager {
+	m := &PeerManager{
+		state: st,
+		name:  peerName(),
+	}
+	snapstate.PeerSource = m.peerSource
+	return m
+}
+
+// configFlag returns whether the given boolean core option is set.
+func configFlag(tr *config.Transaction, key string) (bool, error) {
+	var flag interface{}
+	if err := tr.GetMaybe("core", key, &flag); err != nil {
+		return false, err
+	}
+	switch flag {
+	case true, "true":
+		return true, nil
+	case false, "false", nil, "":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s can only be set to 'true' or 'false', got %q", key, flag)
+}
+
+// advertisedPort returns the port to advertise the download cache on, or
+// 0 if it is not to be advertised.
+func advertisedPort(st *state.State) (int, error) {
+	tr := config.NewTransaction(st)
+	advertise, err := configFlag(tr, "store.peers.advertise")
+	if err != nil || !advertise {
+		return 0, err
+	}
+	var portValue interface{}
+	if err := tr.GetMaybe("core", "store.peers.port", &portValue); err != nil {
+		return 0, err
+	}
+	if portValue == nil || portValue == "" {
+		return defaultPort, nil
+	}
+	port, err := strconv.Atoi(fmt.Sprint(portValue))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("store.peers.port must be a port number between 1 and 65535, not %q", portValue)
+	}
+	return port, nil
+}
+
+// peerName returns a name for this snapd instance on the network. As
+// devices of a site often share their hostname, it is made unique with a
+// random suffix.
+func peerName() string {
+	hostname, err := osHostname()
+	if err != nil || hostname == "" {
+		hostname = "snapd"
+	}
+	name := strings.SplitN(hostname, ".", 2)[0]
+	if len(name) > 56 {
+		name = name[:56]
+	}
+	return name + "-" + randutil.RandomString(6)
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *PeerManager) Ensure() error {
+	m.state.Lock()
+	port, err := advertisedPort(m.state)
+	m.state.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if port == m.port {
+		return nil
+	}
+	m.stopAdvertising()
+	if port == 0 {
+		return nil
+	}
+	// not retried on failure until the configuration changes
+	m.port = port
+	if err := m.startAdvertising(port); err != nil {
+		logger.Noticef("Cannot advertise the download cache to peers: %v", err)
+	}
+	return nil
+}
+
+// Stop implements StateStopper. It stops advertising the download cache.
+func (m *PeerManager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopAdvertising()
+}
+
+func (m *PeerManager) startAdvertising(port int) error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	resp, err := newResponder(m.name, port)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	// the cache size only matters when adding to it
+	cacher := store.NewCacheManager(dirs.SnapDownloadCacheDir, 0)
+	m.server = &http.Server{
+		Handler:           store.NewPeerHandler(cacher),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	m.responder = resp
+	go m.server.Serve(l)
+	logger.Noticef("Advertising the download cache to peers on port %d.", port)
+	return nil
+}
+
+func (m *PeerManager) stopAdvertising() {
+	if m.responder != nil {
+		m.responder.Close()
+		m.responder = nil
+	}
+	if m.server != nil {
+		m.server.Close()
+		m.server = nil
+	}
+	m.port = 0
+}
+
+// peerSource implements snapstate.PeerSource. It must be called with the
+// state locked.
+func (m *PeerManager) peerSource(st *state.State) store.PeerSource {
+	enabled, err := configFlag(config.NewTransaction(st), "store.peers.enabled")
+	if err != nil {
+		logger.Noticef("Cannot download from peers: %v", err)
+		return nil
+	}
+	if !enabled {
+		return nil
+	}
+	return m
+}
+
+// Peers implements store.PeerSource. The peers found on the network are
+// reused for a while, and this instance is never one of them.
+func (m *PeerManager) Peers(ctx context.Context) ([]store.Peer, error) {
+	m.peersMu.Lock()
+	defer m.peersMu.Unlock()
+
+	now := timeNow()
+	if !m.peersFound.IsZero() && now.Sub(m.peersFound) < peersTTL {
+		return m.peers, nil
+	}
+
+	found, err := browse(ctx, browseTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var peers []store.Peer
+	for _, p := range found {
+		if strings.EqualFold(p.Name, m.name) {
+			continue
+		}
+		peers = append(peers, store.Peer{
+			Name: p.Name,
+			URL:  "http://" + (&net.TCPAddr{IP: p.IP, Port: p.Port}).String(),
+		})
+	}
+	m.peers = peers
+	m.peersFound = now
+	return peers, nil
+}
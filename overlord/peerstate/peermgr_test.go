@@ -0,0 +1,223 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package peerstate_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/peerstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type peerMgrSuite struct {
+	testutil.BaseTest
+	state *state.State
+	mgr   *peerstate.PeerManager
+	now   time.Time
+}
+
+var _ = Suite(&peerMgrSuite{})
+
+func (s *peerMgrSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	mockMulticast(c, &s.BaseTest)
+	s.AddCleanup(peerstate.MockBrowseTimeout(200 * time.Millisecond))
+	s.now = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(peerstate.MockTimeNow(func() time.Time { return s.now }))
+	s.AddCleanup(peerstate.MockOsHostname(func() (string, error) { return "ubuntu.example.com", nil }))
+
+	oldPeerSource := snapstate.PeerSource
+	s.AddCleanup(func() { snapstate.PeerSource = oldPeerSource })
+
+	s.state = overlord.Mock().State()
+	s.mgr = peerstate.Manager(s.state)
+	s.AddCleanup(s.mgr.Stop)
+}
+
+func (s *peerMgrSuite) setConfig(c *C, key string, value interface{}) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", key, value), IsNil)
+	tr.Commit()
+}
+
+func freeTCPPort(c *C) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func (s *peerMgrSuite) TestName(c *C) {
+	c.Check(s.mgr.Name(), Matches, `ubuntu-[a-zA-Z0-9]{6}`)
+	c.Check(peerstate.Manager(s.state).Name(), Not(Equals), s.mgr.Name())
+}
+
+func (s *peerMgrSuite) TestPeerSource(c *C) {
+	s.state.Lock()
+	c.Check(snapstate.PeerSource(s.state), IsNil)
+	s.state.Unlock()
+
+	s.setConfig(c, "store.peers.enabled", true)
+	s.state.Lock()
+	c.Check(snapstate.PeerSource(s.state), Equals, s.mgr)
+	s.state.Unlock()
+
+	s.setConfig(c, "store.peers.enabled", "false")
+	s.state.Lock()
+	c.Check(snapstate.PeerSource(s.state), IsNil)
+	s.state.Unlock()
+}
+
+func (s *peerMgrSuite) TestPeers(c *C) {
+	port := freeTCPPort(c)
+	r, err := peerstate.NewResponder("other-x1", port)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	peers, err := s.mgr.Peers(context.Background())
+	c.Assert(err, IsNil)
+	c.Check(peers, DeepEquals, []store.Peer{
+		{Name: "other-x1", URL: fmt.Sprintf("http://127.0.0.1:%d", port)},
+	})
+
+	// the peers found are used for a while
+	r.Close()
+	s.now = s.now.Add(30 * time.Second)
+	peers, err = s.mgr.Peers(context.Background())
+	c.Assert(err, IsNil)
+	c.Check(peers, HasLen, 1)
+
+	s.now = s.now.Add(time.Minute)
+	peers, err = s.mgr.Peers(context.Background())
+	c.Assert(err, IsNil)
+	c.Check(peers, HasLen, 0)
+}
+
+func (s *peerMgrSuite) TestPeersNotSelf(c *C) {
+	r, err := peerstate.NewResponder(s.mgr.Name(), peerstate.DefaultPort)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	peers, err := s.mgr.Peers(context.Background())
+	c.Assert(err, IsNil)
+	c.Check(peers, HasLen, 0)
+}
+
+func (s *peerMgrSuite) TestEnsureAdvertises(c *C) {
+	content := []byte("cached snap")
+	digest := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0700), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapDownloadCacheDir, digest), content, 0600), IsNil)
+
+	// nothing is advertised by default
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.mgr.AdvertisedPort(), Equals, 0)
+
+	port := freeTCPPort(c)
+	s.setConfig(c, "store.peers.advertise", true)
+	s.setConfig(c, "store.peers.port", port)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.mgr.AdvertisedPort(), Equals, port)
+
+	// the cache is served
+	url := fmt.Sprintf("http://127.0.0.1:%d%s%s", port, store.PeerSnapsPath, digest)
+	resp, err := http.Get(url)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Check(resp.StatusCode, Equals, 200)
+	c.Check(data, DeepEquals, content)
+
+	// and advertised
+	found, err := peerstate.Browse(context.Background(), 200*time.Millisecond)
+	c.Assert(err, IsNil)
+	c.Assert(found, HasLen, 1)
+	c.Check(found[0].Name, Equals, s.mgr.Name())
+	c.Check(found[0].Port, Equals, port)
+
+	// until the option is unset
+	s.setConfig(c, "store.peers.advertise", false)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.mgr.AdvertisedPort(), Equals, 0)
+	_, err = http.Get(url)
+	c.Check(err, NotNil)
+}
+
+func (s *peerMgrSuite) TestEnsureAdvertiseFailure(c *C) {
+	l, err := net.Listen("tcp", ":0")
+	c.Assert(err, IsNil)
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	s.setConfig(c, "store.peers.advertise", true)
+	s.setConfig(c, "store.peers.port", port)
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	// the failure is logged, and not retried until the option changes
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(logbuf.String(), Matches, `(?s).*Cannot advertise the download cache to peers: .*`)
+	logbuf.Reset()
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(logbuf.String(), Equals, "")
+}
+
+func (s *peerMgrSuite) TestEnsureInvalidConfig(c *C) {
+	s.setConfig(c, "store.peers.advertise", "yes")
+	c.Check(s.mgr.Ensure(), ErrorMatches, `store.peers.advertise can only be set to 'true' or 'false', got "yes"`)
+}
+
+func (s *peerMgrSuite) TestStop(c *C) {
+	port := freeTCPPort(c)
+	s.setConfig(c, "store.peers.advertise", true)
+	s.setConfig(c, "store.peers.port", port)
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Check(s.mgr.AdvertisedPort(), Equals, port)
+
+	s.mgr.Stop()
+	c.Check(s.mgr.AdvertisedPort(), Equals, 0)
+	_, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", port))
+	c.Check(err, NotNil)
+}
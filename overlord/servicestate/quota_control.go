@@ -23,11 +23,11 @@ import (
 	"fmt"
 
 	"github.com/snapcore/snapd/features"
-	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/systemd"
 )
@@ -84,7 +84,7 @@ func quotaGroupsAvailable(st *state.State) error {
 // CreateQuota attempts to create the specified quota group with the specified
 // snaps in it.
 // TODO: should this use something like QuotaGroupUpdate with fewer fields?
-func CreateQuota(st *state.State, name string, parentName string, snaps []string, memoryLimit quantity.Size) error {
+func CreateQuota(st *state.State, name string, parentName string, snaps []string, resourceLimits quota.Resources) error {
 	if err := quotaGroupsAvailable(st); err != nil {
 		return err
 	}
@@ -97,11 +97,11 @@ func CreateQuota(st *state.State, name string, parentName string, snaps []string
 	// TODO: switch to returning a taskset with the right handler instead of
 	// executing this directly
 	qc := QuotaControlAction{
-		Action:      "create",
-		QuotaName:   name,
-		MemoryLimit: memoryLimit,
-		AddSnaps:    snaps,
-		ParentName:  parentName,
+		Action:         "create",
+		QuotaName:      name,
+		ResourceLimits: resourceLimits,
+		AddSnaps:       snaps,
+		ParentName:     parentName,
 	}
 
 	return quotaCreate(st, nil, qc, allGrps, nil, nil)
@@ -140,9 +140,9 @@ type QuotaGroupUpdate struct {
 	// the quota group
 	AddSnaps []string
 
-	// NewMemoryLimit is the new memory limit to be used for the quota group. If
-	// zero, then the quota group's memory limit is not changed.
-	NewMemoryLimit quantity.Size
+	// NewResourceLimits are the new resource limits to be used for the quota
+	// group. Limits which are unset (zero) are not changed.
+	NewResourceLimits quota.Resources
 }
 
 // UpdateQuota updates the quota as per the options.
@@ -162,10 +162,10 @@ func UpdateQuota(st *state.State, name string, updateOpts QuotaGroupUpdate) erro
 	// TODO: switch to returning a taskset with the right handler instead of
 	// executing this directly
 	qc := QuotaControlAction{
-		Action:      "update",
-		QuotaName:   name,
-		MemoryLimit: updateOpts.NewMemoryLimit,
-		AddSnaps:    updateOpts.AddSnaps,
+		Action:         "update",
+		QuotaName:      name,
+		ResourceLimits: updateOpts.NewResourceLimits,
+		AddSnaps:       updateOpts.AddSnaps,
 	}
 
 	return quotaUpdate(st, nil, qc, allGrps, nil, nil)
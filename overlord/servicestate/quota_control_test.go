@@ -32,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/systemd"
@@ -200,7 +201,7 @@ func (s *quotaControlSuite) TestCreateQuotaNotEnabled(c *C) {
 	tr.Commit()
 
 	// try to create an empty quota group
-	err := servicestate.CreateQuota(s.state, "foo", "", nil, quantity.SizeGiB)
+	err := servicestate.CreateQuota(s.state, "foo", "", nil, quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, ErrorMatches, `experimental feature disabled - test it by setting 'experimental.quota-groups' to true`)
 }
 
@@ -214,7 +215,7 @@ func (s *quotaControlSuite) TestCreateQuotaSystemdTooOld(c *C) {
 	err := servicestate.CheckSystemdVersion()
 	c.Assert(err, IsNil)
 
-	err = servicestate.CreateQuota(s.state, "foo", "", nil, quantity.SizeGiB)
+	err = servicestate.CreateQuota(s.state, "foo", "", nil, quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, ErrorMatches, `systemd version too old: snap quotas requires systemd 205 and newer \(currently have 204\)`)
 }
 
@@ -230,7 +231,7 @@ func (s *quotaControlSuite) TestRemoveQuotaPreseeding(c *C) {
 	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
 
 	// create a quota group
-	err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, quantity.SizeGiB)
+	err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	// check that the quota groups were created in the state
@@ -272,7 +273,7 @@ func (s *quotaControlSuite) TestCreateUpdateRemoveQuotaHappy(c *C) {
 	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
 
 	// create the quota group
-	err := servicestate.CreateQuota(st, "foo", "", []string{"test-snap"}, quantity.SizeGiB)
+	err := servicestate.CreateQuota(st, "foo", "", []string{"test-snap"}, quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	// check that the quota groups were created in the state
@@ -284,7 +285,7 @@ func (s *quotaControlSuite) TestCreateUpdateRemoveQuotaHappy(c *C) {
 	})
 
 	// increase the memory limit
-	err = servicestate.UpdateQuota(st, "foo", servicestate.QuotaGroupUpdate{NewMemoryLimit: 2 * quantity.SizeGiB})
+	err = servicestate.UpdateQuota(st, "foo", servicestate.QuotaGroupUpdate{NewResourceLimits: quota.Resources{Memory: 2 * quantity.SizeGiB}})
 	c.Assert(err, IsNil)
 
 	checkQuotaState(c, st, map[string]quotaGroupState{
@@ -338,7 +339,7 @@ func (s *quotaControlSuite) TestEnsureSnapAbsentFromQuotaGroup(c *C) {
 	snaptest.MockSnapCurrent(c, testYaml2, si2)
 
 	// create a quota group
-	err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap", "test-snap2"}, quantity.SizeGiB)
+	err := servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap", "test-snap2"}, quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	checkQuotaState(c, st, map[string]quotaGroupState{
@@ -54,11 +54,11 @@ type QuotaControlAction struct {
 	// the "update" or the "create" actions.
 	AddSnaps []string `json:"snaps"`
 
-	// MemoryLimit is the memory limit for the quota group being controlled,
-	// either the initial limit the group is created with for the "create"
-	// action, or if non-zero for the "update" the memory limit, then the new
-	// value to be set.
-	MemoryLimit quantity.Size
+	// ResourceLimits are the resource limits for the quota group being
+	// controlled, either the initial limits the group is created with for the
+	// "create" action, or for the "update" action the new values of the limits
+	// which are set (non-zero).
+	ResourceLimits quota.Resources `json:"resource-limits,omitempty"`
 
 	// ParentName is the name of the parent for the quota group if it is being
 	// created. Eventually this could be used with the "update" action to
@@ -118,20 +118,13 @@ func quotaCreate(st *state.State, t *state.Task, action QuotaControlAction, allG
 		return fmt.Errorf("group %q already exists", action.QuotaName)
 	}
 
-	// make sure the memory limit is not zero
-	// TODO: this needs to be updated to 4K when PR snapcore/snapd#10346 lands
-	// and an equivalent check needs to be put back into CreateQuota() before
-	// the tasks are created
-	if action.MemoryLimit == 0 {
-		return fmt.Errorf("internal error, MemoryLimit option is mandatory for create action")
+	// make sure at least one resource limit is set
+	if err := action.ResourceLimits.Validate(); err != nil {
+		return fmt.Errorf("cannot create group %q: %v", action.QuotaName, err)
 	}
 
-	// make sure the memory limit is at least 4K, that is the minimum size
-	// to allow nesting, otherwise groups with less than 4K will trigger the
-	// oom killer to be invoked when a new group is added as a sub-group to the
-	// larger group.
-	if action.MemoryLimit <= 4*quantity.SizeKiB {
-		return fmt.Errorf("memory limit for group %q is too small: size must be larger than 4KB", action.QuotaName)
+	if err := validateResourceLimitsForSystem(action.QuotaName, action.ResourceLimits); err != nil {
+		return err
 	}
 
 	// make sure the specified snaps exist and aren't currently in another group
@@ -162,7 +155,7 @@ func quotaCreateImpl(st *state.State, action QuotaControlAction, allGrps map[str
 			return nil, nil, fmt.Errorf("cannot create group under non-existent parent group %q", action.ParentName)
 		}
 
-		grp, err = parentGrp.NewSubGroup(action.QuotaName, action.MemoryLimit)
+		grp, err = parentGrp.NewSubGroup(action.QuotaName, action.ResourceLimits)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -170,7 +163,7 @@ func quotaCreateImpl(st *state.State, action QuotaControlAction, allGrps map[str
 		updatedGrps = append(updatedGrps, parentGrp)
 	} else {
 		// make a new group
-		grp, err = quota.NewGroup(action.QuotaName, action.MemoryLimit)
+		grp, err = quota.NewGroup(action.QuotaName, action.ResourceLimits)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -205,8 +198,8 @@ func quotaRemove(st *state.State, t *state.Task, action QuotaControlAction, allG
 		return fmt.Errorf("internal error, AddSnaps option cannot be used with remove action")
 	}
 
-	if action.MemoryLimit != 0 {
-		return fmt.Errorf("internal error, MemoryLimit option cannot be used with remove action")
+	if action.ResourceLimits != (quota.Resources{}) {
+		return fmt.Errorf("internal error, ResourceLimits option cannot be used with remove action")
 	}
 
 	// XXX: remove this limitation eventually
@@ -289,15 +282,23 @@ func quotaUpdate(st *state.State, t *state.Task, action QuotaControlAction, allG
 	grp.Snaps = append(grp.Snaps, action.AddSnaps...)
 
 	// if the memory limit is not zero then change it too
-	if action.MemoryLimit != 0 {
+	if action.ResourceLimits.Memory != 0 {
 		// we disallow decreasing the memory limit because it is difficult to do
 		// so correctly with the current state of our code in
 		// EnsureSnapServices, see comment in ensureSnapServicesForGroup for
 		// full details
-		if action.MemoryLimit < grp.MemoryLimit {
+		if action.ResourceLimits.Memory < grp.MemoryLimit {
 			return fmt.Errorf("cannot decrease memory limit of existing quota-group, remove and re-create it to decrease the limit")
 		}
-		grp.MemoryLimit = action.MemoryLimit
+	}
+
+	if err := validateResourceLimitsForSystem(action.QuotaName, action.ResourceLimits); err != nil {
+		return err
+	}
+
+	// update the limits which are set, the other ones are left as they are
+	if err := grp.UpdateQuotaLimits(action.ResourceLimits); err != nil {
+		return fmt.Errorf("cannot update quota %q: group %q is invalid: %v", action.QuotaName, action.QuotaName, err)
 	}
 
 	// update the quota group state
@@ -379,6 +380,7 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 	}
 
 	grpsToStart := []*quota.Group{}
+	journalNamespacesToRestart := []string{}
 	appsToRestartBySnap := map[*snap.Info][]*snap.AppInfo{}
 
 	collectModifiedUnits := func(app *snap.AppInfo, grp *quota.Group, unitType string, name, old, new string) {
@@ -418,6 +420,15 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 				grpsToStart = append(grpsToStart, grp)
 			}
 
+		case "journald":
+			// a new journal namespace is started on demand by systemd when
+			// the first service logging to it starts, but a modified
+			// configuration is only picked up after restarting the journald
+			// instance of the namespace
+			if old != "" {
+				journalNamespacesToRestart = append(journalNamespacesToRestart, grp.JournalNamespaceName())
+			}
+
 		case "service":
 			// in this case, the only way that a service could have been changed
 			// was if it was moved into or out of a slice, in both cases we need
@@ -449,6 +460,13 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 		}
 	}
 
+	// restart the journald instances of the modified journal namespaces
+	for _, ns := range journalNamespacesToRestart {
+		if err := systemSysd.Restart(fmt.Sprintf("systemd-journald@%s.service", ns), 5*time.Second); err != nil {
+			return err
+		}
+	}
+
 	// after starting all the grps that we modified from EnsureSnapServices,
 	// we need to handle the case where a quota was removed, this will only
 	// happen one at a time and can be identified by the grp provided to us
@@ -512,6 +530,37 @@ func ensureSnapServicesForGroup(st *state.State, t *state.Task, grp *quota.Group
 	return nil
 }
 
+// validateResourceLimitsForSystem checks that the set resource limits can be
+// enforced with the systemd version of the system.
+func validateResourceLimitsForSystem(name string, resourceLimits quota.Resources) error {
+	// make sure the memory limit is at least 4K, that is the minimum size
+	// to allow nesting, otherwise groups with less than 4K will trigger the
+	// oom killer to be invoked when a new group is added as a sub-group to the
+	// larger group.
+	if resourceLimits.Memory != 0 && resourceLimits.Memory <= 4*quantity.SizeKiB {
+		return fmt.Errorf("memory limit for group %q is too small: size must be larger than 4KB", name)
+	}
+
+	if resourceLimits.CPU != nil {
+		if resourceLimits.CPU.Percentage != 0 && systemdVersion < 213 {
+			return fmt.Errorf("cannot use CPU quota with group %q: systemd version too old: CPU quotas require systemd 213 and newer (currently have %d)", name, systemdVersion)
+		}
+		if len(resourceLimits.CPU.AllowedCPUs) != 0 && systemdVersion < 244 {
+			return fmt.Errorf("cannot use allowed CPUs with group %q: systemd version too old: allowed CPUs require systemd 244 and newer (currently have %d)", name, systemdVersion)
+		}
+	}
+
+	if resourceLimits.Threads != 0 && systemdVersion < 228 {
+		return fmt.Errorf("cannot use thread limit with group %q: systemd version too old: thread limits require systemd 228 and newer (currently have %d)", name, systemdVersion)
+	}
+
+	if resourceLimits.Journal != nil && systemdVersion < 245 {
+		return fmt.Errorf("cannot use journal quota with group %q: systemd version too old: journal quotas require systemd 245 and newer (currently have %d)", name, systemdVersion)
+	}
+
+	return nil
+}
+
 func validateSnapForAddingToGroup(st *state.State, snaps []string, group string, allGrps map[string]*quota.Group) error {
 	for _, name := range snaps {
 		// validate that the snap exists
@@ -85,10 +85,10 @@ func (s *quotaHandlersSuite) TestDoQuotaControlCreate(c *C) {
 
 	qcs := []servicestate.QuotaControlAction{
 		{
-			Action:      "create",
-			QuotaName:   "foo-group",
-			MemoryLimit: quantity.SizeGiB,
-			AddSnaps:    []string{"test-snap"},
+			Action:         "create",
+			QuotaName:      "foo-group",
+			ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+			AddSnaps:       []string{"test-snap"},
 		},
 	}
 
@@ -128,7 +128,7 @@ func (s *quotaHandlersSuite) TestDoQuotaControlUpdate(c *C) {
 	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
 
 	// create a quota group
-	err := servicestate.CreateQuota(st, "foo-group", "", []string{"test-snap"}, quantity.SizeGiB)
+	err := servicestate.CreateQuota(st, "foo-group", "", []string{"test-snap"}, quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	// create a task for updating the quota group
@@ -137,9 +137,9 @@ func (s *quotaHandlersSuite) TestDoQuotaControlUpdate(c *C) {
 	// update the memory limit to be double
 	qcs := []servicestate.QuotaControlAction{
 		{
-			Action:      "update",
-			QuotaName:   "foo-group",
-			MemoryLimit: 2 * quantity.SizeGiB,
+			Action:         "update",
+			QuotaName:      "foo-group",
+			ResourceLimits: quota.Resources{Memory: 2 * quantity.SizeGiB},
 		},
 	}
 
@@ -182,7 +182,7 @@ func (s *quotaHandlersSuite) TestDoQuotaControlRemove(c *C) {
 	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
 
 	// create a quota group
-	err := servicestate.CreateQuota(st, "foo-group", "", []string{"test-snap"}, quantity.SizeGiB)
+	err := servicestate.CreateQuota(st, "foo-group", "", []string{"test-snap"}, quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	// create a task for removing the quota group
@@ -223,10 +223,10 @@ func (s *quotaHandlersSuite) TestQuotaCreatePreseeding(c *C) {
 
 	// now we can create the quota group
 	qc := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo",
-		MemoryLimit: quantity.SizeGiB,
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err := servicestate.QuotaCreate(st, nil, qc, allGrps(c, st), nil, nil)
@@ -260,10 +260,10 @@ func (s *quotaHandlersSuite) TestQuotaCreate(c *C) {
 
 	// trying to create a quota with a snap that doesn't exist fails
 	qc := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo",
-		MemoryLimit: quantity.SizeGiB,
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err := servicestate.QuotaCreate(st, nil, qc, allGrps(c, st), nil, nil)
@@ -274,10 +274,10 @@ func (s *quotaHandlersSuite) TestQuotaCreate(c *C) {
 	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
 
 	qc2 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo",
-		MemoryLimit: 4 * quantity.SizeKiB,
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: 4 * quantity.SizeKiB},
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	// trying to create a quota with too low of a memory limit fails
@@ -287,16 +287,16 @@ func (s *quotaHandlersSuite) TestQuotaCreate(c *C) {
 	// but with an adequately sized memory limit, and a snap that exists, we can
 	// create it
 	qc3 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo",
-		MemoryLimit: 4*quantity.SizeKiB + 1,
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: 4*quantity.SizeKiB + 1},
+		AddSnaps:       []string{"test-snap"},
 	}
 	err = servicestate.QuotaCreate(st, nil, qc3, allGrps(c, st), nil, nil)
 	c.Assert(err, IsNil)
 
 	// creating the same group again will fail
-	err = servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, 4*quantity.SizeKiB+1)
+	err = servicestate.CreateQuota(s.state, "foo", "", []string{"test-snap"}, quota.Resources{Memory: 4*quantity.SizeKiB + 1})
 	c.Assert(err, ErrorMatches, `group "foo" already exists`)
 
 	// check that the quota groups were created in the state
@@ -308,6 +308,60 @@ func (s *quotaHandlersSuite) TestQuotaCreate(c *C) {
 	})
 }
 
+func (s *quotaHandlersSuite) TestQuotaCreateUnsupportedSystemdVersion(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+
+	tt := []struct {
+		systemdVersion int
+		limits         quota.Resources
+		err            string
+	}{
+		{
+			systemdVersion: 212,
+			limits:         quota.Resources{CPU: &quota.GroupQuotaCPU{Percentage: 50}},
+			err:            `cannot use CPU quota with group "foo": systemd version too old: CPU quotas require systemd 213 and newer \(currently have 212\)`,
+		},
+		{
+			systemdVersion: 243,
+			limits:         quota.Resources{CPU: &quota.GroupQuotaCPU{AllowedCPUs: []int{0}}},
+			err:            `cannot use allowed CPUs with group "foo": systemd version too old: allowed CPUs require systemd 244 and newer \(currently have 243\)`,
+		},
+		{
+			systemdVersion: 227,
+			limits:         quota.Resources{Threads: 32},
+			err:            `cannot use thread limit with group "foo": systemd version too old: thread limits require systemd 228 and newer \(currently have 227\)`,
+		},
+		{
+			systemdVersion: 244,
+			limits:         quota.Resources{Journal: &quota.GroupQuotaJournal{}},
+			err:            `cannot use journal quota with group "foo": systemd version too old: journal quotas require systemd 245 and newer \(currently have 244\)`,
+		},
+	}
+
+	for _, t := range tt {
+		restore := servicestate.MockSystemdVersion(t.systemdVersion)
+		qc := servicestate.QuotaControlAction{
+			Action:         "create",
+			QuotaName:      "foo",
+			ResourceLimits: t.limits,
+		}
+		err := servicestate.QuotaCreate(st, nil, qc, allGrps(c, st), nil, nil)
+		c.Check(err, ErrorMatches, t.err)
+		restore()
+	}
+
+	// invalid limits are refused before checking the system
+	qc := servicestate.QuotaControlAction{
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{CPU: &quota.GroupQuotaCPU{Percentage: 200}},
+	}
+	err := servicestate.QuotaCreate(st, nil, qc, allGrps(c, st), nil, nil)
+	c.Check(err, ErrorMatches, `cannot create group "foo": invalid CPU percentage 200: must be between 1 and 100`)
+}
+
 func (s *quotaHandlersSuite) TestDoCreateSubGroupQuota(c *C) {
 	r := s.mockSystemctlCalls(c, join(
 		// CreateQuota for foo - no systemctl calls since no snaps in it
@@ -333,9 +387,9 @@ func (s *quotaHandlersSuite) TestDoCreateSubGroupQuota(c *C) {
 
 	// create a quota group with no snaps to be the parent
 	qc := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo-group",
-		MemoryLimit: quantity.SizeGiB,
+		Action:         "create",
+		QuotaName:      "foo-group",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
 	}
 
 	err := servicestate.QuotaCreate(st, nil, qc, allGrps(c, st), nil, nil)
@@ -343,11 +397,11 @@ func (s *quotaHandlersSuite) TestDoCreateSubGroupQuota(c *C) {
 
 	// trying to create a quota group with a non-existent parent group fails
 	qc2 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo2",
-		MemoryLimit: quantity.SizeGiB,
-		ParentName:  "foo-non-real",
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo2",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		ParentName:     "foo-non-real",
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err = servicestate.QuotaCreate(st, nil, qc2, allGrps(c, st), nil, nil)
@@ -356,11 +410,11 @@ func (s *quotaHandlersSuite) TestDoCreateSubGroupQuota(c *C) {
 	// trying to create a quota group with too big of a limit to fit inside the
 	// parent fails
 	qc3 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo2",
-		MemoryLimit: 2 * quantity.SizeGiB,
-		ParentName:  "foo-group",
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo2",
+		ResourceLimits: quota.Resources{Memory: 2 * quantity.SizeGiB},
+		ParentName:     "foo-group",
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err = servicestate.QuotaCreate(st, nil, qc3, allGrps(c, st), nil, nil)
@@ -368,11 +422,11 @@ func (s *quotaHandlersSuite) TestDoCreateSubGroupQuota(c *C) {
 
 	// now we can create a sub-quota
 	qc4 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo2",
-		MemoryLimit: quantity.SizeGiB,
-		ParentName:  "foo-group",
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo2",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		ParentName:     "foo-group",
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err = servicestate.QuotaCreate(st, nil, qc4, allGrps(c, st), nil, nil)
@@ -439,10 +493,10 @@ func (s *quotaHandlersSuite) TestQuotaRemove(c *C) {
 	c.Assert(err, ErrorMatches, `cannot remove non-existent quota group "not-exists"`)
 
 	qc2 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo",
-		MemoryLimit: quantity.SizeGiB,
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err = servicestate.QuotaCreate(st, nil, qc2, allGrps(c, st), nil, nil)
@@ -450,20 +504,20 @@ func (s *quotaHandlersSuite) TestQuotaRemove(c *C) {
 
 	// create 2 quota sub-groups too
 	qc3 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo2",
-		MemoryLimit: quantity.SizeGiB / 2,
-		ParentName:  "foo",
+		Action:         "create",
+		QuotaName:      "foo2",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB / 2},
+		ParentName:     "foo",
 	}
 
 	err = servicestate.QuotaCreate(st, nil, qc3, allGrps(c, st), nil, nil)
 	c.Assert(err, IsNil)
 
 	qc4 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo3",
-		MemoryLimit: quantity.SizeGiB / 2,
-		ParentName:  "foo",
+		Action:         "create",
+		QuotaName:      "foo3",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB / 2},
+		ParentName:     "foo",
 	}
 
 	err = servicestate.QuotaCreate(st, nil, qc4, allGrps(c, st), nil, nil)
@@ -598,10 +652,10 @@ func (s *quotaHandlersSuite) TestQuotaUpdateSubGroupTooBig(c *C) {
 
 	// create a quota group
 	qc := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo",
-		MemoryLimit: quantity.SizeGiB,
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err := servicestate.QuotaCreate(st, nil, qc, allGrps(c, st), nil, nil)
@@ -618,11 +672,11 @@ func (s *quotaHandlersSuite) TestQuotaUpdateSubGroupTooBig(c *C) {
 
 	// create a sub-group with 0.5 GiB
 	qc2 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo2",
-		MemoryLimit: quantity.SizeGiB / 2,
-		AddSnaps:    []string{"test-snap2"},
-		ParentName:  "foo",
+		Action:         "create",
+		QuotaName:      "foo2",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB / 2},
+		AddSnaps:       []string{"test-snap2"},
+		ParentName:     "foo",
 	}
 
 	err = servicestate.QuotaCreate(st, nil, qc2, allGrps(c, st), nil, nil)
@@ -644,9 +698,9 @@ func (s *quotaHandlersSuite) TestQuotaUpdateSubGroupTooBig(c *C) {
 
 	// now try to increase it to the max size
 	qc3 := servicestate.QuotaControlAction{
-		Action:      "update",
-		QuotaName:   "foo2",
-		MemoryLimit: quantity.SizeGiB,
+		Action:         "update",
+		QuotaName:      "foo2",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
 	}
 
 	err = servicestate.QuotaUpdate(st, nil, qc3, allGrps(c, st), nil, nil)
@@ -661,9 +715,9 @@ func (s *quotaHandlersSuite) TestQuotaUpdateSubGroupTooBig(c *C) {
 
 	// now try to increase it above the parent limit
 	qc4 := servicestate.QuotaControlAction{
-		Action:      "update",
-		QuotaName:   "foo2",
-		MemoryLimit: 2 * quantity.SizeGiB,
+		Action:         "update",
+		QuotaName:      "foo2",
+		ResourceLimits: quota.Resources{Memory: 2 * quantity.SizeGiB},
 	}
 
 	err = servicestate.QuotaUpdate(st, nil, qc4, allGrps(c, st), nil, nil)
@@ -709,10 +763,10 @@ func (s *quotaHandlersSuite) TestQuotaUpdateChangeMemLimit(c *C) {
 
 	// create a quota group
 	qc := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo",
-		MemoryLimit: quantity.SizeGiB,
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err := servicestate.QuotaCreate(st, nil, qc, allGrps(c, st), nil, nil)
@@ -728,9 +782,9 @@ func (s *quotaHandlersSuite) TestQuotaUpdateChangeMemLimit(c *C) {
 
 	// modify to 2 GB
 	qc2 := servicestate.QuotaControlAction{
-		Action:      "update",
-		QuotaName:   "foo",
-		MemoryLimit: 2 * quantity.SizeGiB,
+		Action:         "update",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: 2 * quantity.SizeGiB},
 	}
 	err = servicestate.QuotaUpdate(st, nil, qc2, allGrps(c, st), nil, nil)
 	c.Assert(err, IsNil)
@@ -745,9 +799,9 @@ func (s *quotaHandlersSuite) TestQuotaUpdateChangeMemLimit(c *C) {
 
 	// trying to decrease the memory limit is not yet supported
 	qc3 := servicestate.QuotaControlAction{
-		Action:      "update",
-		QuotaName:   "foo",
-		MemoryLimit: quantity.SizeGiB,
+		Action:         "update",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
 	}
 	err = servicestate.QuotaUpdate(st, nil, qc3, allGrps(c, st), nil, nil)
 	c.Assert(err, ErrorMatches, "cannot decrease memory limit of existing quota-group, remove and re-create it to decrease the limit")
@@ -785,10 +839,10 @@ func (s *quotaHandlersSuite) TestQuotaUpdateAddSnap(c *C) {
 
 	// create a quota group
 	qc := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo",
-		MemoryLimit: quantity.SizeGiB,
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err := servicestate.QuotaCreate(st, nil, qc, allGrps(c, st), nil, nil)
@@ -851,10 +905,10 @@ func (s *quotaHandlersSuite) TestQuotaUpdateAddSnapAlreadyInOtherGroup(c *C) {
 
 	// create a quota group
 	qc := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo",
-		MemoryLimit: quantity.SizeGiB,
-		AddSnaps:    []string{"test-snap"},
+		Action:         "create",
+		QuotaName:      "foo",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		AddSnaps:       []string{"test-snap"},
 	}
 
 	err := servicestate.QuotaCreate(st, nil, qc, allGrps(c, st), nil, nil)
@@ -869,10 +923,10 @@ func (s *quotaHandlersSuite) TestQuotaUpdateAddSnapAlreadyInOtherGroup(c *C) {
 
 	// create another quota group with the second snap
 	qc2 := servicestate.QuotaControlAction{
-		Action:      "create",
-		QuotaName:   "foo2",
-		MemoryLimit: quantity.SizeGiB,
-		AddSnaps:    []string{"test-snap2"},
+		Action:         "create",
+		QuotaName:      "foo2",
+		ResourceLimits: quota.Resources{Memory: quantity.SizeGiB},
+		AddSnaps:       []string{"test-snap2"},
 	}
 
 	err = servicestate.QuotaCreate(st, nil, qc2, allGrps(c, st), nil, nil)
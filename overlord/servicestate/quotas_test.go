@@ -120,15 +120,15 @@ func (s *servicestateQuotasSuite) TestQuotas(c *C) {
 	// adding multiple quotas that are invalid produces a nice error message
 	otherGrp := &quota.Group{
 		Name: "other-group",
-		// invalid memory limit
+		// no resource limits
 	}
 
 	otherGrp2 := &quota.Group{
 		Name: "other-group2",
-		// invalid memory limit
+		// no resource limits
 	}
 
 	_, err = servicestate.PatchQuotas(st, otherGrp2, otherGrp)
 	// either group can get checked first
-	c.Assert(err, ErrorMatches, `cannot update quotas "other-group", "other-group2": group "other-group2?" is invalid: group memory limit must be non-zero`)
+	c.Assert(err, ErrorMatches, `cannot update quotas "other-group", "other-group2": group "other-group2?" is invalid: quota group must have at least one resource limit set`)
 }
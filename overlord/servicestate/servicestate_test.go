@@ -306,7 +306,7 @@ func (s *snapServiceOptionsSuite) TestSnapServiceOptionsQuotaGroups(c *C) {
 	defer st.Unlock()
 
 	// make a quota group
-	grp, err := quota.NewGroup("foogroup", quantity.SizeGiB)
+	grp, err := quota.NewGroup("foogroup", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	grp.Snaps = []string{"foosnap"}
@@ -54,6 +54,8 @@ const (
 	archiveName  = "archive.tgz"
 	metadataName = "meta.json"
 	metaHashName = "meta.sha3_384"
+	// the snap's configuration, when the snapshot is encrypted
+	encryptedConfName = "conf.json"
 
 	userArchivePrefix = "user/"
 	userArchiveSuffix = ".tgz"
@@ -201,7 +203,18 @@ func Iter(ctx context.Context, f func(*Reader) error) error {
 // List valid snapshots sets.
 func List(ctx context.Context, setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
 	setshots := map[uint64][]*client.Snapshot{}
+	// the disk usage of incremental snapshots depends on all the other
+	// incremental snapshots, not just the listed ones
+	var diskUsages []*snapshotDiskUsage
 	err := Iter(ctx, func(reader *Reader) error {
+		if reader.Incremental && reader.Broken == "" {
+			usage, err := newSnapshotDiskUsage(reader)
+			if err != nil {
+				logger.Noticef("Cannot determine disk usage of snapshot %q: %v.", reader.Name(), err)
+			} else {
+				diskUsages = append(diskUsages, usage)
+			}
+		}
 		if setID == 0 || reader.SetID == setID {
 			if len(snapNames) == 0 || strutil.ListContains(snapNames, reader.Snap) {
 				setshots[reader.SetID] = append(setshots[reader.SetID], &reader.Snapshot)
@@ -209,6 +222,7 @@ func List(ctx context.Context, setID uint64, snapNames []string) ([]client.Snaps
 		}
 		return nil
 	})
+	setDiskSizes(diskUsages)
 
 	sets := make([]client.SnapshotSet, 0, len(setshots))
 	for id, shots := range setshots {
@@ -301,12 +315,35 @@ func EstimateSnapshotSize(si *snap.Info, usernames []string) (uint64, error) {
 	return total, nil
 }
 
+// SaveFlags carries extra flags to drive save behavior.
+type SaveFlags struct {
+	// Incremental tells save to store the data of the snapshot in the
+	// chunk store shared by all incremental snapshots, so that data
+	// unchanged since an earlier snapshot is not stored again.
+	Incremental bool
+	// Encryption, if set, tells save to encrypt the archives and the
+	// configuration of the snap; it cannot be combined with Incremental.
+	Encryption *Encryption
+}
+
 // Save a snapshot
-func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string) (*client.Snapshot, error) {
+func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *SaveFlags) (*client.Snapshot, error) {
 	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
 		return nil, err
 	}
 
+	if flags == nil {
+		flags = &SaveFlags{}
+	}
+	if flags.Encryption != nil {
+		if flags.Incremental {
+			return nil, errors.New("cannot save an encrypted snapshot incrementally")
+		}
+		if err := flags.Encryption.validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	snapshot := &client.Snapshot{
 		SetID:    id,
 		Snap:     si.InstanceName(),
@@ -319,6 +356,27 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 		Size:     0,
 		Conf:     cfg,
 		// Note: Auto is no longer set in the Snapshot.
+		Incremental: flags.Incremental,
+	}
+	if flags.Encryption != nil {
+		snapshot.Encryption = flags.Encryption.kind()
+		// the configuration goes in an encrypted entry instead
+		snapshot.Conf = nil
+	}
+
+	sysArchiveName := archiveName
+	userArchiveNameFor := userArchiveName
+	if flags.Incremental {
+		// keep unused chunks from being pruned until the snapshot is
+		// complete
+		unlock, err := lockChunkStore(false)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+
+		sysArchiveName = incrementalArchiveName
+		userArchiveNameFor = incrementalUserArchiveName
 	}
 
 	aw, err := osutil.NewAtomicFile(Filename(snapshot), 0600, 0, osutil.NoChown, osutil.NoChown)
@@ -330,7 +388,7 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 
 	w := zip.NewWriter(aw)
 	defer w.Close() // note this does not close the file descriptor (that's done by hand on the atomic writer, above)
-	if err := addDirToZip(ctx, snapshot, w, "root", archiveName, si.DataDir()); err != nil {
+	if err := addDirToZip(ctx, snapshot, w, "root", sysArchiveName, si.DataDir(), flags); err != nil {
 		return nil, err
 	}
 
@@ -340,7 +398,13 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 	}
 
 	for _, usr := range users {
-		if err := addDirToZip(ctx, snapshot, w, usr.Username, userArchiveName(usr), si.UserDataDir(usr.HomeDir)); err != nil {
+		if err := addDirToZip(ctx, snapshot, w, usr.Username, userArchiveNameFor(usr), si.UserDataDir(usr.HomeDir), flags); err != nil {
+			return nil, err
+		}
+	}
+
+	if flags.Encryption != nil && cfg != nil {
+		if err := addConfToZip(snapshot, w, cfg, flags.Encryption); err != nil {
 			return nil, err
 		}
 	}
@@ -378,7 +442,36 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]interfac
 
 var isTesting = snapdenv.Testing()
 
-func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username string, entry, dir string) error {
+// addConfToZip adds the encrypted configuration of the snap to the snapshot.
+func addConfToZip(snapshot *client.Snapshot, w *zip.Writer, cfg map[string]interface{}, encryption *Encryption) error {
+	confWriter, err := w.Create(encryptedConfName)
+	if err != nil {
+		return err
+	}
+
+	var sz osutil.Sizer
+	hasher := crypto.SHA3_384.New()
+	encrypter, err := encryption.newWriter(io.MultiWriter(confWriter, hasher, &sz))
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(encrypter).Encode(cfg); err != nil {
+		return err
+	}
+	if err := encrypter.Close(); err != nil {
+		return err
+	}
+
+	snapshot.SHA3_384[encryptedConfName] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size += sz.Size()
+
+	return nil
+}
+
+func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username string, entry, dir string, flags *SaveFlags) error {
+	if flags == nil {
+		flags = &SaveFlags{}
+	}
 	parent, revdir := filepath.Split(dir)
 	exists, isDir, err := osutil.DirExists(parent)
 	if err != nil {
@@ -394,10 +487,16 @@ func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer,
 	}
 	tarArgs := []string{
 		"--create",
-		"--sparse", "--gzip",
+		"--sparse",
 		"--format", "gnu",
 		"--directory", parent,
 	}
+	if !flags.Incremental {
+		// the tar stream of incremental snapshots is compressed chunk by
+		// chunk instead, as compressing it as a whole would make chunks
+		// of unchanged data differ between snapshots
+		tarArgs = append(tarArgs, "--gzip")
+	}
 
 	noRev, noCommon := true, true
 
@@ -442,8 +541,26 @@ func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer,
 	var sz osutil.Sizer
 	hasher := crypto.SHA3_384.New()
 
+	var chunker *chunkWriter
+	dataWriter := archiveWriter
+	if flags.Incremental {
+		// the archive entry holds the index of the chunks instead
+		chunker = newChunkWriter()
+		dataWriter = chunker
+	}
+
 	cmd := tarAsUser(username, tarArgs...)
-	cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	cmd.Stdout = io.MultiWriter(dataWriter, hasher, &sz)
+	var encrypter io.WriteCloser
+	if flags.Encryption != nil {
+		// the hash and size are those of the encrypted data, so the
+		// snapshot can be checked without decrypting it
+		encrypter, err = flags.Encryption.newWriter(cmd.Stdout)
+		if err != nil {
+			return err
+		}
+		cmd.Stdout = encrypter
+	}
 	matchCounter := &strutil.MatchCounter{
 		// keep at most 5 matches
 		N: 5,
@@ -470,6 +587,21 @@ func addDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer,
 		return fmt.Errorf("tar failed: %v", err)
 	}
 
+	if encrypter != nil {
+		if err := encrypter.Close(); err != nil {
+			return err
+		}
+	}
+
+	if chunker != nil {
+		if err := chunker.Close(); err != nil {
+			return err
+		}
+		if err := json.NewEncoder(archiveWriter).Encode(&chunker.index); err != nil {
+			return err
+		}
+	}
+
 	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
 	snapshot.Size += sz.Size()
 
@@ -766,6 +898,14 @@ func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint
 		flags = &ImportFlags{}
 	}
 
+	// the chunk store is only locked once an incremental snapshot is found
+	var unlockChunkStore func()
+	defer func() {
+		if unlockChunkStore != nil {
+			unlockChunkStore()
+		}
+	}()
+
 	for tarErr == nil {
 		header, tarErr = tr.Next()
 		if tarErr == io.EOF {
@@ -805,6 +945,21 @@ func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint
 			continue
 		}
 
+		// the chunks of incremental snapshots come before the
+		// snapshot files using them
+		if strings.HasPrefix(header.Name, chunksDirName+"/") {
+			if unlockChunkStore == nil {
+				unlockChunkStore, err = lockChunkStore(false)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if err := importChunk(path.Base(header.Name), tr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		// Format of the snapshot import is:
 		//     $setID_.....
 		// But because the setID is local this will not be correct
@@ -851,6 +1006,11 @@ type SnapshotExport struct {
 	// open snapshot files
 	snapshotFiles []*os.File
 
+	// the chunks used by incremental snapshots, and the function to
+	// release the lock that keeps them from being pruned
+	chunks           []string
+	unlockChunkStore func()
+
 	// contentHash of the full snapshot
 	contentHash []byte
 
@@ -866,6 +1026,8 @@ type SnapshotExport struct {
 func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, err error) {
 	var snapshotFiles []*os.File
 	var snapshotSet client.SnapshotSet
+	var unlockChunkStore func()
+	chunks := make(map[string]bool)
 
 	defer func() {
 		// cleanup any open FDs if anything goes wrong
@@ -873,6 +1035,9 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 			for _, f := range snapshotFiles {
 				f.Close()
 			}
+			if unlockChunkStore != nil {
+				unlockChunkStore()
+			}
 		}
 	}()
 
@@ -884,6 +1049,25 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 		if reader.SetID == setID {
 			snapshotSet.Snapshots = append(snapshotSet.Snapshots, &reader.Snapshot)
 
+			if reader.Incremental {
+				// the chunks are read when streaming, keep them
+				// from being pruned until the export is closed
+				if unlockChunkStore == nil {
+					var err error
+					unlockChunkStore, err = lockChunkStore(false)
+					if err != nil {
+						return err
+					}
+				}
+				refs, err := reader.chunkRefs()
+				if err != nil {
+					return err
+				}
+				for _, ref := range refs {
+					chunks[ref.Hash] = true
+				}
+			}
+
 			// Duplicate the file descriptor of the reader
 			// we were handed as Iter() closes those as
 			// soon as this unnamed returns. We re-package
@@ -912,7 +1096,17 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 	if err != nil {
 		return nil, fmt.Errorf("cannot calculate content hash for snapshot export %v: %v", setID, err)
 	}
-	se = &SnapshotExport{snapshotFiles: snapshotFiles, setID: setID, contentHash: h}
+	se = &SnapshotExport{
+		snapshotFiles:    snapshotFiles,
+		setID:            setID,
+		contentHash:      h,
+		chunks:           make([]string, 0, len(chunks)),
+		unlockChunkStore: unlockChunkStore,
+	}
+	for hash := range chunks {
+		se.chunks = append(se.chunks, hash)
+	}
+	sort.Strings(se.chunks)
 
 	// ensure we never leak FDs even if the user does not call close
 	runtime.SetFinalizer(se, (*SnapshotExport).Close)
@@ -949,6 +1143,10 @@ func (se *SnapshotExport) Close() {
 		f.Close()
 	}
 	se.snapshotFiles = nil
+	if se.unlockChunkStore != nil {
+		se.unlockChunkStore()
+		se.unlockChunkStore = nil
+	}
 }
 
 type contentJSON struct {
@@ -980,6 +1178,15 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 		return err
 	}
 
+	// write out the chunks of incremental snapshots, before the
+	// snapshots themselves so these can be checked on import
+	for _, hash := range se.chunks {
+		if err := se.streamChunkTo(tw, hash); err != nil {
+			return err
+		}
+		files = append(files, path.Join(chunksDirName, hash))
+	}
+
 	// write out the individual snapshots
 	for _, snapshotFile := range se.snapshotFiles {
 		stat, err := snapshotFile.Stat()
@@ -1034,3 +1241,30 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 
 	return nil
 }
+
+func (se *SnapshotExport) streamChunkTo(tw *tar.Writer, hash string) error {
+	f, err := os.Open(chunkPath(hash))
+	if err != nil {
+		return fmt.Errorf("cannot open snapshot chunk %.7s…: %v", hash, err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path.Join(chunksDirName, hash),
+		Size:     stat.Size(),
+		Mode:     0600,
+		ModTime:  stat.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("cannot write header for snapshot chunk %.7s…: %v", hash, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("cannot write data for snapshot chunk %.7s…: %v", hash, err)
+	}
+	return nil
+}
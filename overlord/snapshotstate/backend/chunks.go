@@ -0,0 +1,492 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// Incremental snapshots do not store a compressed archive of the data in the
+// snapshot file. Instead the (uncompressed) tar stream is split into content
+// defined chunks which are stored, compressed, in a chunk store shared by all
+// snapshots and keyed by the hash of their content. The snapshot file then
+// only contains the list of chunks needed to reassemble the tar stream. As
+// chunk boundaries only depend on the content around them, unchanged data in
+// successive snapshots results in the same chunks, which are stored once.
+
+const (
+	chunksDirName  = "chunks"
+	chunksLockName = ".lock"
+
+	incrementalArchiveName       = "archive.tar"
+	incrementalUserArchiveSuffix = ".tar"
+)
+
+var (
+	// the bounds for the size of the chunks; chunkAvgSize must be a power of
+	// two. Note that changing these means that data stored by existing
+	// snapshots will not be deduplicated with that of new ones.
+	chunkMinSize = 256 * 1024
+	chunkAvgSize = 1024 * 1024
+	chunkMaxSize = 8 * 1024 * 1024
+
+	// maxImportChunkSize is the maximum size of a chunk accepted on import,
+	// it is larger than chunkMaxSize to cope with exports from systems
+	// using different chunking parameters.
+	maxImportChunkSize = int64(64 * 1024 * 1024)
+)
+
+// gearTable is the table of random values used by the rolling hash that
+// determines the chunk boundaries. It is generated with splitmix64 from a
+// fixed seed and must never change, see above.
+var gearTable = func() (table [256]uint64) {
+	x := uint64(0x736e617073686f74)
+	for i := range table {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+func chunksDir() string {
+	return filepath.Join(dirs.SnapshotsDir, chunksDirName)
+}
+
+func chunkPath(hash string) string {
+	return filepath.Join(chunksDir(), hash[:2], hash)
+}
+
+// isValidChunkHash checks that the given string looks like the hex encoded
+// SHA3-384 of a chunk, as it's used to build paths.
+func isValidChunkHash(hash string) bool {
+	if len(hash) != 2*crypto.SHA3_384.Size() {
+		return false
+	}
+	for _, c := range hash {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// chunkRef references a chunk in the chunk store.
+type chunkRef struct {
+	Hash string `json:"sha3-384"`
+	Size int64  `json:"size"`
+}
+
+// chunkIndex is the content of the snapshot file entry of an incremental
+// archive; it lists the chunks the archive is made of, in order.
+type chunkIndex struct {
+	Size   int64      `json:"size"`
+	Chunks []chunkRef `json:"chunks"`
+}
+
+func isIncrementalEntry(entry string) bool {
+	return filepath.Ext(entry) == incrementalUserArchiveSuffix
+}
+
+// lockChunkStore takes the lock of the chunk store, either shared, which is
+// used while chunks are added or read, or exclusive, which is used while
+// unused chunks are removed. The returned function releases the lock.
+func lockChunkStore(exclusive bool) (unlock func(), err error) {
+	if err := os.MkdirAll(chunksDir(), 0700); err != nil {
+		return nil, err
+	}
+	lock, err := osutil.NewFileLockWithMode(filepath.Join(chunksDir(), chunksLockName), 0600)
+	if err != nil {
+		return nil, err
+	}
+	if exclusive {
+		err = lock.Lock()
+	} else {
+		err = lock.ReadLock()
+	}
+	if err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("cannot lock snapshot chunk store: %v", err)
+	}
+	return func() { lock.Close() }, nil
+}
+
+func chunkHash(data []byte) string {
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(data)
+	return fmt.Sprintf("%x", hasher.Sum(nil))
+}
+
+// storeChunk adds the given data to the chunk store, unless it is already
+// there, and returns the reference to it.
+func storeChunk(data []byte) (chunkRef, error) {
+	ref := chunkRef{
+		Hash: chunkHash(data),
+		Size: int64(len(data)),
+	}
+
+	p := chunkPath(ref.Hash)
+	if osutil.FileExists(p) {
+		return ref, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return ref, err
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return ref, err
+	}
+	if err := gz.Close(); err != nil {
+		return ref, err
+	}
+	if err := osutil.AtomicWriteFile(p, buf.Bytes(), 0600, 0); err != nil {
+		return ref, fmt.Errorf("cannot store snapshot chunk: %v", err)
+	}
+	return ref, nil
+}
+
+// loadChunk reads the given chunk from the chunk store and verifies it.
+func loadChunk(ref chunkRef) ([]byte, error) {
+	if !isValidChunkHash(ref.Hash) {
+		return nil, fmt.Errorf("invalid snapshot chunk hash %q", ref.Hash)
+	}
+	f, err := os.Open(chunkPath(ref.Hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot chunk %.7s… is missing", ref.Hash)
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot chunk %.7s…: %v", ref.Hash, err)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(gz, ref.Size+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot chunk %.7s…: %v", ref.Hash, err)
+	}
+	if int64(len(data)) != ref.Size {
+		return nil, fmt.Errorf("snapshot chunk %.7s… expected size (%d) does not match actual (%d)", ref.Hash, ref.Size, len(data))
+	}
+	if actualHash := chunkHash(data); actualHash != ref.Hash {
+		return nil, fmt.Errorf("snapshot chunk %.7s… does not match its hash (%.7s…)", ref.Hash, actualHash)
+	}
+	return data, nil
+}
+
+// importChunk adds a chunk from an exported snapshot to the chunk store,
+// checking that its content matches the given hash.
+func importChunk(hash string, r io.Reader) error {
+	if !isValidChunkHash(hash) {
+		return fmt.Errorf("invalid snapshot chunk name %q", hash)
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("cannot read snapshot chunk %.7s…: %v", hash, err)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(gz, maxImportChunkSize+1))
+	if err != nil {
+		return fmt.Errorf("cannot read snapshot chunk %.7s…: %v", hash, err)
+	}
+	if int64(len(data)) > maxImportChunkSize {
+		return fmt.Errorf("snapshot chunk %.7s… is too large", hash)
+	}
+	if actualHash := chunkHash(data); actualHash != hash {
+		return fmt.Errorf("snapshot chunk %.7s… does not match its hash (%.7s…)", hash, actualHash)
+	}
+	_, err = storeChunk(data)
+	return err
+}
+
+// chunkWriter splits the data written to it into content defined chunks and
+// adds them to the chunk store. Close must be called once all data has been
+// written.
+type chunkWriter struct {
+	buf   []byte
+	hash  uint64
+	mask  uint64
+	index chunkIndex
+}
+
+func newChunkWriter() *chunkWriter {
+	// the hash is shifted left for every byte, so the top bits depend on
+	// the most bytes
+	bits := uint(0)
+	for avg := chunkAvgSize; avg > 1; avg >>= 1 {
+		bits++
+	}
+	return &chunkWriter{
+		buf:  make([]byte, 0, chunkMinSize),
+		mask: ((1 << bits) - 1) << (64 - bits),
+	}
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	start := 0
+	for i, b := range p {
+		cw.hash = (cw.hash << 1) + gearTable[b]
+		size := len(cw.buf) + i - start + 1
+		if size < chunkMinSize {
+			continue
+		}
+		if cw.hash&cw.mask == 0 || size >= chunkMaxSize {
+			cw.buf = append(cw.buf, p[start:i+1]...)
+			start = i + 1
+			if err := cw.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	cw.buf = append(cw.buf, p[start:]...)
+	return len(p), nil
+}
+
+func (cw *chunkWriter) flush() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	ref, err := storeChunk(cw.buf)
+	if err != nil {
+		return err
+	}
+	cw.index.Chunks = append(cw.index.Chunks, ref)
+	cw.index.Size += ref.Size
+	cw.buf = cw.buf[:0]
+	cw.hash = 0
+	return nil
+}
+
+// Close stores the last chunk.
+func (cw *chunkWriter) Close() error {
+	return cw.flush()
+}
+
+// chunkReader reassembles the data of an incremental archive from the chunk
+// store, verifying every chunk on the way.
+type chunkReader struct {
+	chunks []chunkRef
+	cur    *bytes.Reader
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for cr.cur == nil || cr.cur.Len() == 0 {
+		if len(cr.chunks) == 0 {
+			return 0, io.EOF
+		}
+		data, err := loadChunk(cr.chunks[0])
+		if err != nil {
+			return 0, err
+		}
+		cr.chunks = cr.chunks[1:]
+		cr.cur = bytes.NewReader(data)
+	}
+	return cr.cur.Read(p)
+}
+
+func (cr *chunkReader) Close() error {
+	return nil
+}
+
+func readChunkIndex(f *os.File, entry string) (*chunkIndex, error) {
+	body, _, err := zipMember(f, entry)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var index chunkIndex
+	if err := json.NewDecoder(body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("cannot read chunk index of snapshot entry %q: %v", entry, err)
+	}
+	return &index, nil
+}
+
+// entryReader returns a reader for the archive of the given entry and the
+// archive size. The archives of incremental snapshots are reassembled from
+// the chunk store.
+func (r *Reader) entryReader(entry string) (io.ReadCloser, int64, error) {
+	if !isIncrementalEntry(entry) {
+		return zipMember(r.File, entry)
+	}
+	index, err := readChunkIndex(r.File, entry)
+	if err != nil {
+		return nil, -1, err
+	}
+	return &chunkReader{chunks: index.Chunks}, index.Size, nil
+}
+
+// chunkRefs returns the chunks used by the incremental archives of the
+// snapshot.
+func (r *Reader) chunkRefs() ([]chunkRef, error) {
+	var refs []chunkRef
+	for entry := range r.SHA3_384 {
+		if !isIncrementalEntry(entry) {
+			continue
+		}
+		index, err := readChunkIndex(r.File, entry)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, index.Chunks...)
+	}
+	return refs, nil
+}
+
+// PruneChunks removes the chunks that are no longer used by any snapshot from
+// the chunk store, and returns the amount of disk space that was freed. If any
+// snapshot cannot be read, nothing is removed.
+func PruneChunks(ctx context.Context) (freed int64, err error) {
+	if !osutil.IsDirectory(chunksDir()) {
+		// no incremental snapshots were ever taken
+		return 0, nil
+	}
+
+	unlock, err := lockChunkStore(true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	snapshotFiles, err := filepathGlob(filepath.Join(dirs.SnapshotsDir, "*.zip"))
+	if err != nil {
+		return 0, err
+	}
+	used := make(map[string]bool)
+	for _, fn := range snapshotFiles {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if ok, _ := isSnapshotFilename(fn); !ok {
+			continue
+		}
+		reader, err := backendOpen(fn, ExtractFnameSetID)
+		if reader == nil {
+			return 0, fmt.Errorf("cannot prune snapshot chunks: cannot open snapshot %q: %v", fn, err)
+		}
+		if err != nil {
+			// the file of a broken snapshot is closed, but the chunks
+			// it uses must be kept all the same
+			if reader.File, err = os.Open(fn); err != nil {
+				return 0, fmt.Errorf("cannot prune snapshot chunks: %v", err)
+			}
+		}
+		refs, err := reader.chunkRefs()
+		reader.Close()
+		if err != nil {
+			return 0, fmt.Errorf("cannot prune snapshot chunks: %v", err)
+		}
+		for _, ref := range refs {
+			used[ref.Hash] = true
+		}
+	}
+
+	chunkFiles, err := filepathGlob(filepath.Join(chunksDir(), "*", "*"))
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range chunkFiles {
+		if used[filepath.Base(p)] {
+			continue
+		}
+		// this also removes leftovers of interrupted writes
+		fi, err := os.Stat(p)
+		if err != nil {
+			return freed, err
+		}
+		if err := os.Remove(p); err != nil {
+			return freed, err
+		}
+		freed += fi.Size()
+	}
+	if freed > 0 {
+		logger.Debugf("Removed %d bytes of unused snapshot chunks.", freed)
+	}
+	return freed, nil
+}
+
+type snapshotDiskUsage struct {
+	snapshot *client.Snapshot
+	fileSize int64
+	chunks   []chunkRef
+}
+
+func newSnapshotDiskUsage(reader *Reader) (*snapshotDiskUsage, error) {
+	fi, err := reader.Stat()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := reader.chunkRefs()
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotDiskUsage{
+		snapshot: &reader.Snapshot,
+		fileSize: fi.Size(),
+		chunks:   refs,
+	}, nil
+}
+
+// setDiskSizes sets the disk size of the given incremental snapshots, that is
+// the size of the snapshot file and of the chunks that were not already used
+// by an older snapshot. This way the disk sizes of all snapshots add up to
+// the disk space used by all of them.
+func setDiskSizes(usages []*snapshotDiskUsage) {
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].snapshot.SetID != usages[j].snapshot.SetID {
+			return usages[i].snapshot.SetID < usages[j].snapshot.SetID
+		}
+		return usages[i].snapshot.Snap < usages[j].snapshot.Snap
+	})
+
+	seen := make(map[string]bool)
+	for _, usage := range usages {
+		size := usage.fileSize
+		for _, ref := range usage.chunks {
+			if seen[ref.Hash] {
+				continue
+			}
+			seen[ref.Hash] = true
+			if fi, err := os.Stat(chunkPath(ref.Hash)); err == nil {
+				size += fi.Size()
+			}
+		}
+		usage.snapshot.DiskSize = size
+	}
+}
@@ -0,0 +1,287 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+)
+
+func randomData(n int, seed int64) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func sum(sizes []int64) (total int64) {
+	for _, sz := range sizes {
+		total += sz
+	}
+	return total
+}
+
+func chunkFiles(c *check.C) []string {
+	matches, err := filepath.Glob(filepath.Join(backend.ChunksDir(), "*", "*"))
+	c.Assert(err, check.IsNil)
+	return matches
+}
+
+func (s *snapshotSuite) TestSplitIntoChunks(c *check.C) {
+	defer backend.MockChunkSizes(1024, 4096, 16384)()
+
+	data := randomData(1024*1024, 42)
+	sizes, err := backend.SplitIntoChunks(data)
+	c.Assert(err, check.IsNil)
+	c.Check(sum(sizes), check.Equals, int64(len(data)))
+	// roughly the average size
+	c.Check(len(sizes) > 1024*1024/16384, check.Equals, true)
+	c.Check(len(sizes) < 1024*1024/1024, check.Equals, true)
+	for i, sz := range sizes[:len(sizes)-1] {
+		comm := check.Commentf("chunk %d", i)
+		c.Check(sz >= 1024, check.Equals, true, comm)
+		c.Check(sz <= 16384, check.Equals, true, comm)
+	}
+
+	// inserting data only changes the chunks around it
+	shifted := append([]byte("some inserted data"), data...)
+	shiftedSizes, err := backend.SplitIntoChunks(shifted)
+	c.Assert(err, check.IsNil)
+	c.Assert(len(shiftedSizes) > 4, check.Equals, true)
+	c.Check(shiftedSizes[4:], check.DeepEquals, sizes[len(sizes)-len(shiftedSizes)+4:])
+}
+
+func (s *snapshotSuite) TestSplitIntoChunksMaxSize(c *check.C) {
+	defer backend.MockChunkSizes(1024, 4096, 8192)()
+
+	// a run of zeros never hits a boundary
+	sizes, err := backend.SplitIntoChunks(make([]byte, 20000))
+	c.Assert(err, check.IsNil)
+	c.Check(sizes, check.DeepEquals, []int64{8192, 8192, 3616})
+}
+
+func (s *snapshotSuite) TestIncrementalHappyRoundtrip(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	logger.SimpleSetup()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	cfg := map[string]interface{}{"some-setting": false}
+
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, &backend.SaveFlags{Incremental: true})
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Incremental, check.Equals, true)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tar", "user/snapuser.tar"})
+	c.Check(chunkFiles(c), check.Not(check.HasLen), 0)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	c.Check(shr.Incremental, check.Equals, true)
+	c.Check(shr.SHA3_384, check.DeepEquals, shw.SHA3_384)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	newroot := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(newroot, "home/snapuser"), 0755), check.IsNil)
+	// the chunk store needs to be carried over to the new root
+	c.Assert(os.MkdirAll(filepath.Join(newroot, "var/lib/snapd/snapshots"), 0755), check.IsNil)
+	c.Assert(os.Rename(backend.ChunksDir(), filepath.Join(newroot, "var/lib/snapd/snapshots/chunks")), check.IsNil)
+	dirs.SetRootDir(newroot)
+
+	diff := exec.Command("diff", "-urN", "-x*.zip", "-xchunks", s.root, newroot)
+	c.Check(diff.Run(), check.NotNil)
+
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+	diff = exec.Command("diff", "-urN", "-x*.zip", "-xchunks", s.root, newroot)
+	c.Check(diff.Run(), check.IsNil)
+}
+
+func (s *snapshotSuite) TestIncrementalDeduplicates(c *check.C) {
+	logger.SimpleSetup()
+	defer backend.MockChunkSizes(1024, 4096, 16384)()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	flags := &backend.SaveFlags{Incremental: true}
+
+	bigFile := filepath.Join(info.DataDir(), "big")
+	c.Assert(ioutil.WriteFile(bigFile, randomData(2*1024*1024, 1), 0644), check.IsNil)
+
+	sh1, err := backend.Save(context.TODO(), 1, info, nil, []string{"snapuser"}, flags)
+	c.Assert(err, check.IsNil)
+	chunks := chunkFiles(c)
+
+	// nothing changed, no new chunks
+	sh2, err := backend.Save(context.TODO(), 2, info, nil, []string{"snapuser"}, flags)
+	c.Assert(err, check.IsNil)
+	c.Check(chunkFiles(c), check.DeepEquals, chunks)
+	c.Check(sh2.SHA3_384, check.DeepEquals, sh1.SHA3_384)
+
+	sets, err := backend.List(context.TODO(), 0, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sets, check.HasLen, 2)
+	sh1 = sets[0].Snapshots[0]
+	sh2 = sets[1].Snapshots[0]
+	c.Check(sh1.Size > 2*1024*1024, check.Equals, true)
+	c.Check(sh1.DiskSize > 2*1024*1024, check.Equals, true)
+	c.Check(sh2.Size, check.Equals, sh1.Size)
+	// the second snapshot only takes up the space of its own file
+	fi, err := os.Stat(backend.Filename(sh2))
+	c.Assert(err, check.IsNil)
+	c.Check(sh2.DiskSize, check.Equals, fi.Size())
+
+	// a small change only adds a few chunks, for the changed tar header
+	// and the changed data
+	f, err := os.OpenFile(bigFile, os.O_WRONLY|os.O_APPEND, 0644)
+	c.Assert(err, check.IsNil)
+	_, err = f.Write([]byte("more data"))
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
+
+	_, err = backend.Save(context.TODO(), 3, info, nil, []string{"snapuser"}, flags)
+	c.Assert(err, check.IsNil)
+	newChunks := chunkFiles(c)
+	c.Check(len(newChunks) > len(chunks), check.Equals, true)
+	c.Check(len(newChunks) <= len(chunks)+4, check.Equals, true, check.Commentf("%d new chunks", len(newChunks)-len(chunks)))
+}
+
+func (s *snapshotSuite) TestIncrementalCheckMissingChunk(c *check.C) {
+	logger.SimpleSetup()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+
+	shw, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, &backend.SaveFlags{Incremental: true})
+	c.Assert(err, check.IsNil)
+
+	chunks := chunkFiles(c)
+	c.Assert(chunks, check.Not(check.HasLen), 0)
+	for _, p := range chunks {
+		c.Assert(os.Remove(p), check.IsNil)
+	}
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, `.*snapshot chunk [0-9a-f]{7}… is missing`)
+}
+
+func (s *snapshotSuite) TestPruneChunks(c *check.C) {
+	logger.SimpleSetup()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	flags := &backend.SaveFlags{Incremental: true}
+
+	// no chunk store yet
+	freed, err := backend.PruneChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(freed, check.Equals, int64(0))
+
+	sh1, err := backend.Save(context.TODO(), 1, info, nil, []string{"snapuser"}, flags)
+	c.Assert(err, check.IsNil)
+	sh2, err := backend.Save(context.TODO(), 2, info, nil, []string{"snapuser"}, flags)
+	c.Assert(err, check.IsNil)
+	// and a non-incremental one that must not get in the way
+	_, err = backend.Save(context.TODO(), 3, info, nil, []string{"snapuser"}, nil)
+	c.Assert(err, check.IsNil)
+	chunks := chunkFiles(c)
+	c.Assert(chunks, check.Not(check.HasLen), 0)
+
+	// chunks still in use by the second snapshot are kept
+	c.Assert(os.Remove(backend.Filename(sh1)), check.IsNil)
+	freed, err = backend.PruneChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(freed, check.Equals, int64(0))
+	c.Check(chunkFiles(c), check.DeepEquals, chunks)
+
+	c.Assert(os.Remove(backend.Filename(sh2)), check.IsNil)
+	freed, err = backend.PruneChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(freed > 0, check.Equals, true)
+	c.Check(chunkFiles(c), check.HasLen, 0)
+}
+
+func (s *snapshotSuite) TestPruneChunksContextDone(c *check.C) {
+	logger.SimpleSetup()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.Save(context.TODO(), 1, info, nil, []string{"snapuser"}, &backend.SaveFlags{Incremental: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(os.Remove(backend.Filename(shw)), check.IsNil)
+	chunks := chunkFiles(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// a dummy snapshot file so the context is checked
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapshotsDir, "2_foo_1.0_1.zip"), nil, 0644), check.IsNil)
+	_, err = backend.PruneChunks(ctx)
+	c.Check(err, check.Equals, context.Canceled)
+	c.Check(chunkFiles(c), check.DeepEquals, chunks)
+}
+
+func (s *snapshotSuite) TestIncrementalImportExportRoundtrip(c *check.C) {
+	logger.SimpleSetup()
+
+	ctx := context.TODO()
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+
+	shw, err := backend.Save(ctx, 12, info, nil, []string{"snapuser"}, &backend.SaveFlags{Incremental: true})
+	c.Assert(err, check.IsNil)
+	chunks := chunkFiles(c)
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	c.Assert(err, check.IsNil)
+	c.Assert(export.Init(), check.IsNil)
+	buf := bytes.NewBuffer(nil)
+	c.Assert(export.StreamTo(buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+	export.Close()
+
+	// the export is self-contained
+	c.Assert(os.Remove(backend.Filename(shw)), check.IsNil)
+	_, err = backend.PruneChunks(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(chunkFiles(c), check.HasLen, 0)
+
+	names, err := backend.Import(ctx, 123, buf, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"hello-snap"})
+	c.Check(chunkFiles(c), check.DeepEquals, chunks)
+
+	rdr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer rdr.Close()
+	c.Check(rdr.Incremental, check.Equals, true)
+	c.Check(rdr.Check(ctx, nil), check.IsNil)
+}
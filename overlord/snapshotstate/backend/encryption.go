@@ -0,0 +1,297 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/openpgp/armor"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// The ways the archives of a snapshot can be encrypted, as recorded in
+// its Encryption field.
+const (
+	EncryptionPassphrase = "passphrase"
+	EncryptionPublicKey  = "public-key"
+)
+
+// ErrNoDecryptionKey is returned when restoring an encrypted snapshot
+// without a passphrase or private key that can decrypt it.
+var ErrNoDecryptionKey = errors.New("cannot decrypt snapshot: no matching passphrase or private key given")
+
+// archives are encrypted as OpenPGP messages so that they can also be
+// decrypted with standard tools (e.g. gpg) if need be; they are not
+// compressed again as the archives already are.
+var encryptionConfig = &packet.Config{DefaultCipher: packet.CipherAES256}
+
+// Encryption holds what is needed to encrypt the archives of a new
+// snapshot: either a passphrase, or the public keys of the recipients
+// that will be able to decrypt it.
+type Encryption struct {
+	Passphrase []byte
+	Recipients []*packet.PublicKey
+}
+
+func (e *Encryption) kind() string {
+	if len(e.Passphrase) > 0 {
+		return EncryptionPassphrase
+	}
+	return EncryptionPublicKey
+}
+
+func (e *Encryption) validate() error {
+	if len(e.Passphrase) > 0 && len(e.Recipients) > 0 {
+		return errors.New("cannot encrypt snapshot with both a passphrase and public keys")
+	}
+	if len(e.Passphrase) == 0 && len(e.Recipients) == 0 {
+		return errors.New("cannot encrypt snapshot without a passphrase or public key")
+	}
+	return nil
+}
+
+// newWriter returns a writer that encrypts what is written to it into
+// w. The returned writer must be closed for the message to be complete.
+func (e *Encryption) newWriter(w io.Writer) (io.WriteCloser, error) {
+	cipherFunc := encryptionConfig.Cipher()
+	var key []byte
+	if len(e.Passphrase) > 0 {
+		var err error
+		key, err = packet.SerializeSymmetricKeyEncrypted(w, e.Passphrase, encryptionConfig)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		key = make([]byte, cipherFunc.KeySize())
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, err
+		}
+		for _, pub := range e.Recipients {
+			if err := packet.SerializeEncryptedKey(w, pub, cipherFunc, key, encryptionConfig); err != nil {
+				return nil, err
+			}
+		}
+	}
+	contents, err := packet.SerializeSymmetricallyEncrypted(w, cipherFunc, key, encryptionConfig)
+	if err != nil {
+		return nil, err
+	}
+	// closing the literal data writer also closes contents, which
+	// writes the modification detection code
+	return packet.SerializeLiteral(contents, true, "", 0)
+}
+
+// Decryption holds the passphrase and the private keys that can be used
+// to decrypt encrypted snapshots.
+type Decryption struct {
+	Passphrase  []byte
+	PrivateKeys []*packet.PrivateKey
+}
+
+// sessionKey returns the key of the encrypted data, if any of the given
+// encrypted session keys can be decrypted.
+func (d *Decryption) sessionKey(symKeys []*packet.SymmetricKeyEncrypted, pubKeys []*packet.EncryptedKey) (packet.CipherFunction, []byte) {
+	for _, ek := range pubKeys {
+		for _, priv := range d.PrivateKeys {
+			if priv.KeyId != ek.KeyId {
+				continue
+			}
+			if err := ek.Decrypt(priv, encryptionConfig); err == nil {
+				return ek.CipherFunc, ek.Key
+			}
+		}
+	}
+	if len(d.Passphrase) > 0 {
+		for _, ske := range symKeys {
+			if key, cipherFunc, err := ske.Decrypt(d.Passphrase); err == nil {
+				return cipherFunc, key
+			}
+		}
+	}
+	return 0, nil
+}
+
+// newReader returns a reader of the decrypted contents of the message
+// read from r. The integrity of the data is verified once the reader
+// reaches the end of the message, at which point the error, if any, is
+// returned instead of io.EOF.
+func (d *Decryption) newReader(r io.Reader) (io.Reader, error) {
+	var symKeys []*packet.SymmetricKeyEncrypted
+	var pubKeys []*packet.EncryptedKey
+
+	pr := packet.NewReader(r)
+	for {
+		p, err := pr.Next()
+		if err == io.EOF {
+			return nil, errors.New("cannot decrypt snapshot: no encrypted data found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt snapshot: %v", err)
+		}
+		switch p := p.(type) {
+		case *packet.SymmetricKeyEncrypted:
+			symKeys = append(symKeys, p)
+		case *packet.EncryptedKey:
+			pubKeys = append(pubKeys, p)
+		case *packet.SymmetricallyEncrypted:
+			if !p.MDC {
+				return nil, errors.New("cannot decrypt snapshot: encrypted data is not integrity protected")
+			}
+			cipherFunc, key := d.sessionKey(symKeys, pubKeys)
+			if key == nil {
+				return nil, ErrNoDecryptionKey
+			}
+			contents, err := p.Decrypt(cipherFunc, key)
+			if err == pgperrors.ErrKeyIncorrect {
+				return nil, ErrNoDecryptionKey
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cannot decrypt snapshot: %v", err)
+			}
+			if err := pr.Push(contents); err != nil {
+				return nil, fmt.Errorf("cannot decrypt snapshot: %v", err)
+			}
+			inner, err := pr.Next()
+			if err != nil {
+				return nil, fmt.Errorf("cannot decrypt snapshot: %v", err)
+			}
+			literal, ok := inner.(*packet.LiteralData)
+			if !ok {
+				return nil, fmt.Errorf("cannot decrypt snapshot: unexpected %T in encrypted data", inner)
+			}
+			return &decryptingReader{body: literal.Body, contents: contents}, nil
+		default:
+			return nil, fmt.Errorf("cannot decrypt snapshot: unexpected %T before encrypted data", p)
+		}
+	}
+}
+
+type decryptingReader struct {
+	body     io.Reader
+	contents io.ReadCloser
+	done     bool
+	err      error
+}
+
+func (r *decryptingReader) Read(buf []byte) (int, error) {
+	if r.done {
+		return 0, r.err
+	}
+	n, err := r.body.Read(buf)
+	if err == io.EOF {
+		r.done = true
+		r.err = io.EOF
+		// closing checks the modification detection code
+		if cerr := r.contents.Close(); cerr != nil {
+			r.err = fmt.Errorf("cannot verify decrypted snapshot data: %v", cerr)
+		}
+		err = r.err
+	}
+	return n, err
+}
+
+func decodeArmoredKeyBlock(armored, blockType string) (*packet.Reader, error) {
+	block, err := armor.Decode(strings.NewReader(armored))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode armored key: %v", err)
+	}
+	if block.Type != blockType {
+		return nil, fmt.Errorf("expected %q block, got %q", blockType, block.Type)
+	}
+	return packet.NewReader(block.Body), nil
+}
+
+// ParsePublicKeys returns the public keys usable for encryption found in
+// the given ASCII-armored OpenPGP public key block. Encryption subkeys are
+// preferred over primary keys, as OpenPGP implementations do.
+func ParsePublicKeys(armored string) ([]*packet.PublicKey, error) {
+	pr, err := decodeArmoredKeyBlock(armored, "PGP PUBLIC KEY BLOCK")
+	if err != nil {
+		return nil, err
+	}
+	var primaries, subkeys []*packet.PublicKey
+	for {
+		p, err := pr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse public key: %v", err)
+		}
+		pub, ok := p.(*packet.PublicKey)
+		if !ok || !pub.PubKeyAlgo.CanEncrypt() {
+			continue
+		}
+		if pub.IsSubkey {
+			subkeys = append(subkeys, pub)
+		} else {
+			primaries = append(primaries, pub)
+		}
+	}
+	if len(subkeys) > 0 {
+		return subkeys, nil
+	}
+	if len(primaries) > 0 {
+		return primaries, nil
+	}
+	return nil, errors.New("no public key usable for encryption found")
+}
+
+// ParsePrivateKeys returns the private keys usable for decryption found in
+// the given ASCII-armored OpenPGP private key block, unlocking them with the
+// given passphrase if needed.
+func ParsePrivateKeys(armored string, passphrase []byte) ([]*packet.PrivateKey, error) {
+	pr, err := decodeArmoredKeyBlock(armored, "PGP PRIVATE KEY BLOCK")
+	if err != nil {
+		return nil, err
+	}
+	var privs []*packet.PrivateKey
+	for {
+		p, err := pr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse private key: %v", err)
+		}
+		priv, ok := p.(*packet.PrivateKey)
+		if !ok || !priv.PubKeyAlgo.CanEncrypt() {
+			continue
+		}
+		if priv.Encrypted {
+			if len(passphrase) == 0 {
+				return nil, errors.New("cannot unlock private key: no passphrase given")
+			}
+			if err := priv.Decrypt(passphrase); err != nil {
+				return nil, fmt.Errorf("cannot unlock private key: %v", err)
+			}
+		}
+		privs = append(privs, priv)
+	}
+	if len(privs) == 0 {
+		return nil, errors.New("no private key usable for decryption found")
+	}
+	return privs, nil
+}
@@ -0,0 +1,204 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+)
+
+// testKeyPair returns a new ASCII-armored OpenPGP key pair.
+func testKeyPair(c *check.C) (pub, priv string) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, check.IsNil)
+	key := packet.NewRSAPrivateKey(time.Now(), rsaKey)
+
+	var pubBuf bytes.Buffer
+	w, err := armor.Encode(&pubBuf, "PGP PUBLIC KEY BLOCK", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(key.PublicKey.Serialize(w), check.IsNil)
+	c.Assert(w.Close(), check.IsNil)
+
+	var privBuf bytes.Buffer
+	w, err = armor.Encode(&privBuf, "PGP PRIVATE KEY BLOCK", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(key.Serialize(w), check.IsNil)
+	c.Assert(w.Close(), check.IsNil)
+
+	return pubBuf.String(), privBuf.String()
+}
+
+func encrypt(c *check.C, encryption *backend.Encryption, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := encryption.NewWriter(&buf)
+	c.Assert(err, check.IsNil)
+	_, err = w.Write(data)
+	c.Assert(err, check.IsNil)
+	c.Assert(w.Close(), check.IsNil)
+	return buf.Bytes()
+}
+
+func decrypt(decryption *backend.Decryption, data []byte) ([]byte, error) {
+	r, err := decryption.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func (s *snapshotSuite) TestEncryptPassphrase(c *check.C) {
+	data := randomData(100000, 42)
+	encrypted := encrypt(c, &backend.Encryption{Passphrase: []byte("sekrit")}, data)
+	c.Check(bytes.Contains(encrypted, data[:1000]), check.Equals, false)
+
+	decrypted, err := decrypt(&backend.Decryption{Passphrase: []byte("sekrit")}, encrypted)
+	c.Assert(err, check.IsNil)
+	c.Check(decrypted, check.DeepEquals, data)
+
+	_, err = decrypt(&backend.Decryption{Passphrase: []byte("not it")}, encrypted)
+	c.Check(err, check.Equals, backend.ErrNoDecryptionKey)
+	_, err = decrypt(&backend.Decryption{}, encrypted)
+	c.Check(err, check.Equals, backend.ErrNoDecryptionKey)
+
+	// tampering with the data is detected
+	encrypted[len(encrypted)/2] ^= 0xff
+	_, err = decrypt(&backend.Decryption{Passphrase: []byte("sekrit")}, encrypted)
+	c.Check(err, check.ErrorMatches, "cannot verify decrypted snapshot data: .*")
+}
+
+func (s *snapshotSuite) TestEncryptPublicKey(c *check.C) {
+	pub, priv := testKeyPair(c)
+	otherPub, otherPriv := testKeyPair(c)
+
+	recipients, err := backend.ParsePublicKeys(pub)
+	c.Assert(err, check.IsNil)
+	c.Assert(recipients, check.HasLen, 1)
+	privKeys, err := backend.ParsePrivateKeys(priv, nil)
+	c.Assert(err, check.IsNil)
+	otherRecipients, err := backend.ParsePublicKeys(otherPub)
+	c.Assert(err, check.IsNil)
+	otherPrivKeys, err := backend.ParsePrivateKeys(otherPriv, nil)
+	c.Assert(err, check.IsNil)
+
+	data := randomData(10000, 42)
+	encrypted := encrypt(c, &backend.Encryption{Recipients: recipients}, data)
+
+	decrypted, err := decrypt(&backend.Decryption{PrivateKeys: privKeys}, encrypted)
+	c.Assert(err, check.IsNil)
+	c.Check(decrypted, check.DeepEquals, data)
+
+	_, err = decrypt(&backend.Decryption{PrivateKeys: otherPrivKeys}, encrypted)
+	c.Check(err, check.Equals, backend.ErrNoDecryptionKey)
+
+	// any of the recipients can decrypt
+	encrypted = encrypt(c, &backend.Encryption{Recipients: append(recipients, otherRecipients...)}, data)
+	decrypted, err = decrypt(&backend.Decryption{PrivateKeys: otherPrivKeys}, encrypted)
+	c.Assert(err, check.IsNil)
+	c.Check(decrypted, check.DeepEquals, data)
+}
+
+func (s *snapshotSuite) TestParseKeysErrors(c *check.C) {
+	pub, priv := testKeyPair(c)
+
+	_, err := backend.ParsePublicKeys("not a key")
+	c.Check(err, check.ErrorMatches, "cannot decode armored key: .*")
+	_, err = backend.ParsePublicKeys(priv)
+	c.Check(err, check.ErrorMatches, `expected "PGP PUBLIC KEY BLOCK" block, got "PGP PRIVATE KEY BLOCK"`)
+	_, err = backend.ParsePrivateKeys(pub, nil)
+	c.Check(err, check.ErrorMatches, `expected "PGP PRIVATE KEY BLOCK" block, got "PGP PUBLIC KEY BLOCK"`)
+	_, err = backend.ParsePrivateKeys("not a key", nil)
+	c.Check(err, check.ErrorMatches, "cannot decode armored key: .*")
+}
+
+func (s *snapshotSuite) TestSaveEncryptedIncremental(c *check.C) {
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	flags := &backend.SaveFlags{
+		Incremental: true,
+		Encryption:  &backend.Encryption{Passphrase: []byte("sekrit")},
+	}
+	_, err := backend.Save(context.TODO(), 12, info, nil, nil, flags)
+	c.Check(err, check.ErrorMatches, "cannot save an encrypted snapshot incrementally")
+}
+
+func (s *snapshotSuite) TestEncryptedHappyRoundtrip(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	logger.SimpleSetup()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	cfg := map[string]interface{}{"some-setting": "sekrit value"}
+	flags := &backend.SaveFlags{Encryption: &backend.Encryption{Passphrase: []byte("sekrit")}}
+
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, flags)
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Encryption, check.Equals, "passphrase")
+	c.Check(shw.Conf, check.IsNil)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "conf.json", "user/snapuser.tgz"})
+
+	zipData, err := ioutil.ReadFile(backend.Filename(shw))
+	c.Assert(err, check.IsNil)
+	c.Check(bytes.Contains(zipData, []byte("sekrit value")), check.Equals, false)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+
+	c.Check(shr.Encryption, check.Equals, "passphrase")
+	c.Check(shr.SHA3_384, check.DeepEquals, shw.SHA3_384)
+	// checking does not need decrypting
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	newroot := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(newroot, "home/snapuser"), 0755), check.IsNil)
+	dirs.SetRootDir(newroot)
+
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf)
+	c.Assert(err, check.Equals, backend.ErrNoDecryptionKey)
+
+	shr.SetDecryption(&backend.Decryption{Passphrase: []byte("not it")})
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf)
+	c.Assert(err, check.Equals, backend.ErrNoDecryptionKey)
+
+	shr.SetDecryption(&backend.Decryption{Passphrase: []byte("sekrit")})
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+	c.Check(shr.Conf, check.DeepEquals, cfg)
+
+	diff := exec.Command("diff", "-urN", "-x*.zip", s.root, newroot)
+	c.Check(diff.Run(), check.IsNil)
+}
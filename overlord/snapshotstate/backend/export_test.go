@@ -20,6 +20,7 @@
 package backend
 
 import (
+	"io"
 	"os"
 	"os/user"
 	"time"
@@ -36,6 +37,8 @@ var (
 
 	IsSnapshotFilename = isSnapshotFilename
 
+	ChunksDir = chunksDir
+
 	NewMultiError = newMultiError
 )
 
@@ -141,6 +144,53 @@ func MockFilepathGlob(new func(pattern string) (matches []string, err error)) (r
 	}
 }
 
+func MockChunkSizes(min, avg, max int) (restore func()) {
+	oldMin, oldAvg, oldMax := chunkMinSize, chunkAvgSize, chunkMaxSize
+	chunkMinSize, chunkAvgSize, chunkMaxSize = min, avg, max
+	return func() {
+		chunkMinSize, chunkAvgSize, chunkMaxSize = oldMin, oldAvg, oldMax
+	}
+}
+
+// ChunkIndexSizes returns the sizes of the chunks listed in the given
+// incremental entry of the snapshot.
+func (r *Reader) ChunkIndexSizes(entry string) ([]int64, error) {
+	index, err := readChunkIndex(r.File, entry)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make([]int64, 0, len(index.Chunks))
+	for _, ref := range index.Chunks {
+		sizes = append(sizes, ref.Size)
+	}
+	return sizes, nil
+}
+
+// SplitIntoChunks runs the given data through the chunker and returns the
+// sizes of the resulting chunks.
+func SplitIntoChunks(data []byte) ([]int64, error) {
+	cw := newChunkWriter()
+	if _, err := cw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	sizes := make([]int64, 0, len(cw.index.Chunks))
+	for _, ref := range cw.index.Chunks {
+		sizes = append(sizes, ref.Size)
+	}
+	return sizes, nil
+}
+
 func (se *SnapshotExport) ContentHash() []byte {
 	return se.contentHash
 }
+
+func (e *Encryption) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return e.newWriter(w)
+}
+
+func (d *Decryption) NewReader(r io.Reader) (io.Reader, error) {
+	return d.newReader(r)
+}
@@ -68,13 +68,20 @@ func userArchiveName(usr *user.User) string {
 	return filepath.Join(userArchivePrefix, usr.Username+userArchiveSuffix)
 }
 
+func incrementalUserArchiveName(usr *user.User) string {
+	return filepath.Join(userArchivePrefix, usr.Username+incrementalUserArchiveSuffix)
+}
+
 func isUserArchive(entry string) bool {
-	return strings.HasPrefix(entry, userArchivePrefix) && strings.HasSuffix(entry, userArchiveSuffix)
+	if !strings.HasPrefix(entry, userArchivePrefix) {
+		return false
+	}
+	return strings.HasSuffix(entry, userArchiveSuffix) || strings.HasSuffix(entry, incrementalUserArchiveSuffix)
 }
 
 func entryUsername(entry string) string {
 	// this _will_ panic if !isUserArchive(entry)
-	return entry[len(userArchivePrefix) : len(entry)-len(userArchiveSuffix)]
+	return entry[len(userArchivePrefix) : len(entry)-len(filepath.Ext(entry))]
 }
 
 type bySnap []*client.Snapshot
@@ -50,6 +50,14 @@ const ExtractFnameSetID = 0
 type Reader struct {
 	*os.File
 	client.Snapshot
+
+	decryption *Decryption
+}
+
+// SetDecryption sets the passphrase or private keys to use for restoring
+// the data of an encrypted snapshot.
+func (r *Reader) SetDecryption(decryption *Decryption) {
+	r.decryption = decryption
 }
 
 // Open a Snapshot given its full filename.
@@ -143,7 +151,7 @@ func Open(fn string, setID uint64) (reader *Reader, e error) {
 }
 
 func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) error {
-	body, reportedSize, err := zipMember(r.File, entry)
+	body, reportedSize, err := r.entryReader(entry)
 	if err != nil {
 		return err
 	}
@@ -166,6 +174,9 @@ func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) e
 }
 
 // Check that the data contained in the snapshot matches its hashsums.
+//
+// As the hashsums of encrypted snapshots are those of the encrypted
+// data, these are checked without being decrypted.
 func (r *Reader) Check(ctx context.Context, usernames []string) error {
 	sort.Strings(usernames)
 
@@ -188,6 +199,42 @@ func (r *Reader) Check(ctx context.Context, usernames []string) error {
 	return nil
 }
 
+// decryptedConf returns the configuration of the snap stored in an
+// encrypted snapshot, after checking it matches its hashsum.
+func (r *Reader) decryptedConf() (map[string]interface{}, error) {
+	body, expectedSize, err := r.entryReader(encryptedConfName)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var sz osutil.Sizer
+	hasher := crypto.SHA3_384.New()
+	plain, err := r.decryption.newReader(io.TeeReader(body, io.MultiWriter(hasher, &sz)))
+	if err != nil {
+		return nil, err
+	}
+	var conf map[string]interface{}
+	if err := jsonutil.DecodeWithNumber(plain, &conf); err != nil {
+		return nil, fmt.Errorf("cannot decode snapshot configuration: %v", err)
+	}
+	// read up to the end so the decrypted data is verified
+	if _, err := io.Copy(ioutil.Discard, plain); err != nil {
+		return nil, err
+	}
+
+	if sz.Size() != expectedSize {
+		return nil, fmt.Errorf("snapshot %q entry %q expected size (%d) does not match actual (%d)",
+			r.Name(), encryptedConfName, expectedSize, sz.Size())
+	}
+	expectedHash := r.SHA3_384[encryptedConfName]
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != expectedHash {
+		return nil, fmt.Errorf("snapshot %q entry %q expected hash (%.7s…) does not match actual (%.7s…)",
+			r.Name(), encryptedConfName, expectedHash, actualHash)
+	}
+	return conf, nil
+}
+
 // Logf is the type implemented by logging functions.
 type Logf func(format string, args ...interface{})
 
@@ -196,8 +243,23 @@ type Logf func(format string, args ...interface{})
 // If successful this will replace the existing data (for the given revision,
 // or the one in the snapshot) with that contained in the snapshot. It keeps
 // track of the old data in the task so it can be undone (or cleaned up).
+//
+// Restoring an encrypted snapshot needs SetDecryption to be called first;
+// on success the configuration of the snap is then available in Conf.
 func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames []string, logf Logf) (rs *RestoreState, e error) {
 	rs = &RestoreState{}
+	if r.Encryption != "" {
+		if r.decryption == nil {
+			return nil, ErrNoDecryptionKey
+		}
+		if _, ok := r.SHA3_384[encryptedConfName]; ok {
+			conf, err := r.decryptedConf()
+			if err != nil {
+				return nil, err
+			}
+			r.Conf = conf
+		}
+	}
 	defer func() {
 		if e != nil {
 			logger.Noticef("Restore of snapshot %q failed (%v); undoing.", r.Name(), e)
@@ -228,8 +290,13 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		uid := sys.UserID(osutil.NoChown)
 		gid := sys.GroupID(osutil.NoChown)
 
+		if entry == encryptedConfName {
+			// already restored above
+			continue
+		}
+
 		if !isUser {
-			if entry != archiveName {
+			if entry != archiveName && entry != incrementalArchiveName {
 				// hmmm
 				logf("Skipping restore of unknown entry %q.", entry)
 				continue
@@ -310,22 +377,34 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 
 		logger.Debugf("Restoring %q from %q into %q.", entry, r.Name(), tempdir)
 
-		body, expectedSize, err := zipMember(r.File, entry)
+		body, expectedSize, err := r.entryReader(entry)
 		if err != nil {
 			return rs, err
 		}
+		defer body.Close()
 
 		expectedHash := r.SHA3_384[entry]
 
-		tr := io.TeeReader(body, io.MultiWriter(hasher, &sz))
+		var tr io.Reader = io.TeeReader(body, io.MultiWriter(hasher, &sz))
+		if r.Encryption != "" {
+			tr, err = r.decryption.newReader(tr)
+			if err != nil {
+				return rs, err
+			}
+		}
 
 		// resist the temptation of using archive/tar unless it's proven
 		// that calling out to tar has issues -- there are a lot of
 		// special cases we'd need to consider otherwise
-		cmd := tarAsUser(username,
+		tarArgs := []string{
 			"--extract",
-			"--preserve-permissions", "--preserve-order", "--gunzip",
-			"--directory", tempdir)
+			"--preserve-permissions", "--preserve-order",
+			"--directory", tempdir,
+		}
+		if !isIncrementalEntry(entry) {
+			tarArgs = append(tarArgs, "--gunzip")
+		}
+		cmd := tarAsUser(username, tarArgs...)
 		cmd.Env = []string{}
 		cmd.Stdin = tr
 		matchCounter := &strutil.MatchCounter{N: 1}
@@ -344,6 +423,14 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 			return rs, fmt.Errorf("tar failed: %v", err)
 		}
 
+		if r.Encryption != "" {
+			// tar stops reading at the end of the archive; read what is
+			// left so the decrypted data gets verified
+			if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+				return rs, err
+			}
+		}
+
 		if sz.Size() != expectedSize {
 			return rs, fmt.Errorf("snapshot %q entry %q expected size (%d) does not match actual (%d)",
 				r.Name(), entry, expectedSize, sz.Size())
@@ -25,8 +25,11 @@ import (
 	"io"
 	"time"
 
+	"golang.org/x/crypto/openpgp/packet"
+
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -47,6 +50,14 @@ var (
 	SaveExpiration             = saveExpiration
 	ExpiredSnapshotSets        = expiredSnapshotSets
 	RemoveSnapshotState        = removeSnapshotState
+	SaveScheduledTime          = saveScheduledTime
+	ScheduledSnapshotSets      = scheduledSnapshotSets
+	ScheduledSnapshotSnaps     = scheduledSnapshotSnaps
+	SnapshotSecrets            = snapshotSecrets
+	DropUnusedSecrets          = dropUnusedSecrets
+	SnapshotRemote             = snapshotRemote
+	DoPush                     = doPush
+	DoPull                     = doPull
 
 	SetSnapshotOpInProgress = setSnapshotOpInProgress
 
@@ -170,6 +181,30 @@ func MockBackenCleanupAbandondedImports(f func() (int, error)) (restore func())
 	}
 }
 
+func MockBackendPruneChunks(f func(context.Context) (int64, error)) (restore func()) {
+	old := backendPruneChunks
+	backendPruneChunks = f
+	return func() {
+		backendPruneChunks = old
+	}
+}
+
+func MockBackendParsePublicKeys(f func(string) ([]*packet.PublicKey, error)) (restore func()) {
+	old := backendParsePublicKeys
+	backendParsePublicKeys = f
+	return func() {
+		backendParsePublicKeys = old
+	}
+}
+
+func MockBackendParsePrivateKeys(f func(string, []byte) ([]*packet.PrivateKey, error)) (restore func()) {
+	old := backendParsePrivateKeys
+	backendParsePrivateKeys = f
+	return func() {
+		backendParsePrivateKeys = old
+	}
+}
+
 func MockBackendEstimateSnapshotSize(f func(*snap.Info, []string) (uint64, error)) (restore func()) {
 	old := backendEstimateSnapshotSize
 	backendEstimateSnapshotSize = f
@@ -186,6 +221,22 @@ func MockBackendNewSnapshotExport(f func(ctx context.Context, setID uint64) (se
 	}
 }
 
+func MockRemoteNew(f func(*remote.Config) (remote.Remote, error)) (restore func()) {
+	old := remoteNew
+	remoteNew = f
+	return func() {
+		remoteNew = old
+	}
+}
+
+func MockRemoteTransferRetry(attempts int, delay time.Duration) (restore func()) {
+	oldAttempts, oldDelay := maxRemoteTransferAttempts, remoteTransferRetryDelay
+	maxRemoteTransferAttempts, remoteTransferRetryDelay = attempts, delay
+	return func() {
+		maxRemoteTransferAttempts, remoteTransferRetryDelay = oldAttempts, oldDelay
+	}
+}
+
 func MockConfigGetSnapConfig(f func(*state.State, string) (*json.RawMessage, error)) (restore func()) {
 	old := configGetSnapConfig
 	configGetSnapConfig = f
@@ -206,3 +257,20 @@ func MockConfigSetSnapConfig(f func(*state.State, string, *json.RawMessage) erro
 func SetLastForgetExpiredSnapshotTime(mgr *SnapshotManager, t time.Time) {
 	mgr.lastForgetExpiredSnapshotTime = t
 }
+
+func NextScheduledSnapshot(mgr *SnapshotManager) time.Time {
+	return mgr.nextScheduledSnapshot
+}
+
+func ScheduledSnapshotsRetention(st *state.State) (last, daily, weekly int, err error) {
+	r, err := scheduledSnapshotsRetention(st)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return r.last, r.daily, r.weekly, nil
+}
+
+func ExpiredByRetention(last, daily, weekly int, sets map[uint64]time.Time) map[uint64]bool {
+	r := &snapshotRetention{last: last, daily: daily, weekly: weekly}
+	return r.expired(sets)
+}
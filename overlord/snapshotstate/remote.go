@@ -0,0 +1,283 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package snapshotstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	remoteNew = remote.New
+
+	// how many times a transfer failing with transient errors is
+	// attempted before giving up, and the delay before retrying it,
+	// which grows with each attempt
+	maxRemoteTransferAttempts = 5
+	remoteTransferRetryDelay  = 30 * time.Second
+)
+
+// ErrNoRemote is returned when pushing or pulling snapshots without a
+// remote configured.
+var ErrNoRemote = errors.New("no remote configured for snapshots (see snapshots.remote.url)")
+
+type remoteSetup struct {
+	Name     string `json:"name"`
+	Auto     bool   `json:"auto,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+}
+
+// RemoteName returns the name the snapshot set with the given ID is pushed
+// as, if no other is given.
+func RemoteName(setID uint64) string {
+	return fmt.Sprintf("%d.snapshot", setID)
+}
+
+// snapshotRemote returns the remote configured with the snapshots.remote.*
+// options.
+func snapshotRemote(st *state.State) (remote.Remote, error) {
+	var cfg remote.Config
+	tr := config.NewTransaction(st)
+	for opt, value := range map[string]*string{
+		"snapshots.remote.url":           &cfg.URL,
+		"snapshots.remote.endpoint":      &cfg.Endpoint,
+		"snapshots.remote.region":        &cfg.Region,
+		"snapshots.remote.access-key-id": &cfg.AccessKeyID,
+		"snapshots.remote.identity-file": &cfg.IdentityFile,
+	} {
+		if err := tr.Get("core", opt, value); err != nil && !config.IsNoOption(err) {
+			return nil, err
+		}
+	}
+	// the secret access key is stored encrypted
+	var secretAccessKey interface{}
+	if err := tr.Get("core", "snapshots.remote.secret-access-key", &secretAccessKey); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	revealed, err := config.RevealSecrets("core", secretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	cfg.SecretAccessKey, _ = revealed.(string)
+	if cfg.URL == "" {
+		return nil, ErrNoRemote
+	}
+	rem, err := remoteNew(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use snapshots.remote.url: %v", err)
+	}
+	return rem, nil
+}
+
+// autoPushSnapshots returns whether new snapshot sets should be pushed to
+// the remote once saved.
+func autoPushSnapshots(st *state.State) (bool, error) {
+	var autoPush interface{}
+	tr := config.NewTransaction(st)
+	if err := tr.GetMaybe("core", "snapshots.remote.auto-push", &autoPush); err != nil {
+		return false, err
+	}
+	switch autoPush {
+	case true, "true":
+		return true, nil
+	case false, "false", nil, "":
+		return false, nil
+	}
+	return false, fmt.Errorf("snapshots.remote.auto-push can only be set to 'true' or 'false', got %q", autoPush)
+}
+
+func newPushTask(st *state.State, setID uint64, setup *remoteSetup) *state.Task {
+	desc := fmt.Sprintf("Push snapshot set #%d to remote as %q", setID, setup.Name)
+	task := st.NewTask("push-snapshot", desc)
+	task.Set("snapshot-setup", &snapshotSetup{SetID: setID})
+	task.Set("remote-setup", setup)
+	return task
+}
+
+// Push creates a taskset for pushing an export of a snapshot set to the
+// remote, under the given name or, if empty, the one given by RemoteName.
+// Note that the state must be locked by the caller.
+func Push(st *state.State, setID uint64, name string) (snapsFound []string, ts *state.TaskSet, err error) {
+	if name == "" {
+		name = RemoteName(setID)
+	}
+	if err := remote.ValidateName(name); err != nil {
+		return nil, nil, err
+	}
+	// push needs to conflict with forget, and with the set still
+	// being saved
+	if err := checkSnapshotConflict(st, setID, "forget-snapshot", "save-snapshot"); err != nil {
+		return nil, nil, err
+	}
+	if _, err := snapshotRemote(st); err != nil {
+		return nil, nil, err
+	}
+
+	summaries, err := snapSummariesInSnapshotSet(setID, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	task := newPushTask(st, setID, &remoteSetup{Name: name})
+	return summaries.snapNames(), state.NewTaskSet(task), nil
+}
+
+// Pull creates a taskset for pulling the snapshot set exported under the
+// given name from the remote, and importing it.
+// Note that the state must be locked by the caller.
+func Pull(st *state.State, name string) (*state.TaskSet, error) {
+	if err := remote.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if _, err := snapshotRemote(st); err != nil {
+		return nil, err
+	}
+
+	desc := fmt.Sprintf("Pull snapshot %q from remote and import it", name)
+	task := st.NewTask("pull-snapshot", desc)
+	task.Set("remote-setup", &remoteSetup{Name: name})
+	return state.NewTaskSet(task), nil
+}
+
+// retryTransfer returns the error a task that failed to transfer a snapshot
+// with the given error should fail with: a Retry if the error is transient
+// and the transfer was not attempted too many times already.
+// The state must be locked by the caller.
+func retryTransfer(task *state.Task, setup *remoteSetup, err error) error {
+	if !remote.IsTransient(err) {
+		return err
+	}
+	setup.Attempts++
+	if setup.Attempts >= maxRemoteTransferAttempts {
+		return fmt.Errorf("%v (giving up after %d attempts)", err, setup.Attempts)
+	}
+	task.Set("remote-setup", setup)
+	delay := time.Duration(setup.Attempts) * remoteTransferRetryDelay
+	task.Logf("Transfer failed, retrying in %s: %v", delay, err)
+	return &state.Retry{After: delay, Reason: err.Error()}
+}
+
+// pushExport streams an export of the snapshot set to the remote.
+func pushExport(ctx context.Context, rem remote.Remote, setID uint64, name string) error {
+	export, err := backendNewSnapshotExport(ctx, setID)
+	if err != nil {
+		return fmt.Errorf("cannot export snapshot set #%d: %v", setID, err)
+	}
+	defer export.Close()
+	if err := export.Init(); err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	streamed := make(chan struct{})
+	go func() {
+		pw.CloseWithError(export.StreamTo(pw))
+		close(streamed)
+	}()
+	err = rem.Push(ctx, name, pr, export.Size())
+	// stop the export if the remote stopped reading it
+	pr.Close()
+	<-streamed
+	return err
+}
+
+func doPush(task *state.Task, tomb *tomb.Tomb) error {
+	var snapshot snapshotSetup
+	var setup remoteSetup
+
+	st := task.State()
+	st.Lock()
+	if err := task.Get("snapshot-setup", &snapshot); err != nil {
+		st.Unlock()
+		return taskGetErrMsg(task, err, "snapshot")
+	}
+	if err := task.Get("remote-setup", &setup); err != nil {
+		st.Unlock()
+		return taskGetErrMsg(task, err, "remote")
+	}
+	rem, err := snapshotRemote(st)
+	st.Unlock()
+
+	if err == nil {
+		err = pushExport(tomb.Context(nil), rem, snapshot.SetID, setup.Name)
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	if err != nil {
+		err = retryTransfer(task, &setup, err)
+		if _, ok := err.(*state.Retry); !ok && setup.Auto {
+			// the snapshots themselves were saved fine, and can
+			// still be pushed by hand
+			logger.Noticef("Cannot push snapshot set #%d to remote: %v", snapshot.SetID, err)
+			task.Errorf("Cannot push snapshot set #%d to remote: %v", snapshot.SetID, err)
+			return nil
+		}
+		return err
+	}
+	task.Logf("Pushed snapshot set #%d to %s as %q", snapshot.SetID, rem, setup.Name)
+	return nil
+}
+
+func doPull(task *state.Task, tomb *tomb.Tomb) error {
+	var setup remoteSetup
+
+	st := task.State()
+	st.Lock()
+	if err := task.Get("remote-setup", &setup); err != nil {
+		st.Unlock()
+		return taskGetErrMsg(task, err, "remote")
+	}
+	rem, err := snapshotRemote(st)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	ctx := tomb.Context(nil)
+	body, err := rem.Pull(ctx, setup.Name)
+	if err != nil {
+		st.Lock()
+		defer st.Unlock()
+		return retryTransfer(task, &setup, err)
+	}
+	defer body.Close()
+
+	// Import takes the state lock as needed
+	setID, snapNames, err := Import(ctx, st, body)
+	if err != nil {
+		return fmt.Errorf("cannot import snapshot %q pulled from %s: %v", setup.Name, rem, err)
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	task.Logf("Imported snapshot %q pulled from %s as snapshot set #%d", setup.Name, rem, setID)
+	task.Change().Set("api-data", map[string]interface{}{"set-id": setID, "snap-names": snapNames})
+	return nil
+}
@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package remote implements the transfer of exported snapshot sets to and
+// from remote storage: S3-compatible object stores, and SFTP servers.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Config describes a remote.
+type Config struct {
+	// URL is the location the snapshots are stored at, either
+	// s3://<bucket>[/<prefix>] or sftp://[<user>@]<host>[:<port>][/<path>]
+	URL string
+
+	// Endpoint is the base URL of the S3 API, for S3-compatible stores
+	// other than AWS (e.g. http://localhost:9000 for MinIO)
+	Endpoint string
+	// Region is the region the S3 bucket is in
+	Region string
+	// AccessKeyID and SecretAccessKey are the S3 credentials
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// IdentityFile is the SSH private key to use for SFTP
+	IdentityFile string
+}
+
+// A Remote is somewhere exported snapshot sets can be pushed to, and
+// pulled back from.
+type Remote interface {
+	// Push stores size bytes read from r under the given name.
+	Push(ctx context.Context, name string, r io.Reader, size int64) error
+	// Pull returns a reader of what is stored under the given name.
+	Pull(ctx context.Context, name string) (io.ReadCloser, error)
+	// String returns the URL of the remote.
+	String() string
+}
+
+// New returns the Remote described by the configuration.
+func New(cfg *Config) (Remote, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("no remote URL given")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse remote URL: %v", err)
+	}
+	switch u.Scheme {
+	case "s3":
+		return newS3Remote(u, cfg)
+	case "sftp":
+		return newSFTPRemote(u, cfg)
+	default:
+		return nil, fmt.Errorf("unsupported remote URL scheme %q (expected s3 or sftp)", u.Scheme)
+	}
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// ValidateName checks that the name is valid for storing an exported
+// snapshot set on a remote.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid remote snapshot name %q", name)
+	}
+	return nil
+}
+
+// cleanPrefix returns the given path without its leading and trailing
+// slashes.
+func cleanPrefix(p string) string {
+	return strings.Trim(p, "/")
+}
+
+// TransientError is an error that retrying the transfer might fix, like a
+// network or server error.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+// withPrefix returns the error prefixed with the given message, keeping
+// it transient if it was.
+func withPrefix(err error, format string, args ...interface{}) error {
+	prefixed := fmt.Errorf(format+": %v", append(args, err)...)
+	if IsTransient(err) {
+		return &TransientError{prefixed}
+	}
+	return prefixed
+}
+
+// IsTransient returns whether retrying the transfer that failed with the
+// given error might succeed.
+func IsTransient(err error) bool {
+	_, ok := err.(*TransientError)
+	return ok
+}
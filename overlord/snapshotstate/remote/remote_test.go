@@ -0,0 +1,86 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package remote_test
+
+import (
+	"errors"
+	"testing"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type remoteSuite struct{}
+
+var _ = check.Suite(&remoteSuite{})
+
+func (remoteSuite) TestNew(c *check.C) {
+	for _, t := range []struct {
+		cfg remote.Config
+		str string
+	}{
+		{remote.Config{URL: "s3://bucket", AccessKeyID: "id", SecretAccessKey: "secret"}, "s3://bucket"},
+		{remote.Config{URL: "s3://bucket/some/prefix/", Endpoint: "http://localhost:9000", AccessKeyID: "id", SecretAccessKey: "secret"}, "s3://bucket/some/prefix"},
+		{remote.Config{URL: "sftp://host"}, "sftp://host"},
+		{remote.Config{URL: "sftp://user@host:2222/srv/snapshots/"}, "sftp://user@host:2222/srv/snapshots"},
+		{remote.Config{URL: "sftp://user@host", IdentityFile: "/root/.ssh/id_ed25519"}, "sftp://user@host"},
+	} {
+		r, err := remote.New(&t.cfg)
+		c.Assert(err, check.IsNil, check.Commentf("%q", t.cfg.URL))
+		c.Check(r.String(), check.Equals, t.str)
+	}
+}
+
+func (remoteSuite) TestNewErrors(c *check.C) {
+	for _, t := range []struct {
+		cfg remote.Config
+		err string
+	}{
+		{remote.Config{}, "no remote URL given"},
+		{remote.Config{URL: ":"}, "cannot parse remote URL: .*"},
+		{remote.Config{URL: "ftp://host/path"}, `unsupported remote URL scheme "ftp" \(expected s3 or sftp\)`},
+		{remote.Config{URL: "s3:///prefix", AccessKeyID: "id", SecretAccessKey: "secret"}, "no bucket given in s3 remote URL"},
+		{remote.Config{URL: "s3://bucket"}, "no s3 credentials given"},
+		{remote.Config{URL: "s3://bucket", Endpoint: "localhost:9000", AccessKeyID: "id", SecretAccessKey: "secret"}, `invalid s3 endpoint "localhost:9000"`},
+		{remote.Config{URL: "sftp:///path"}, "no host given in sftp remote URL"},
+		{remote.Config{URL: "sftp://host/pa%22th"}, `invalid sftp remote path "/pa\\"th"`},
+		{remote.Config{URL: "sftp://host", IdentityFile: "id_rsa"}, `sftp identity file "id_rsa" is not an absolute path`},
+	} {
+		_, err := remote.New(&t.cfg)
+		c.Check(err, check.ErrorMatches, t.err, check.Commentf("%q", t.cfg.URL))
+	}
+}
+
+func (remoteSuite) TestValidateName(c *check.C) {
+	for _, name := range []string{"42.snapshot", "my-backup_1.zip", "A"} {
+		c.Check(remote.ValidateName(name), check.IsNil, check.Commentf(name))
+	}
+	for _, name := range []string{"", ".hidden", "-opt", "a/b", "../x", "a b", "a\"b"} {
+		c.Check(remote.ValidateName(name), check.ErrorMatches, "invalid remote snapshot name .*", check.Commentf(name))
+	}
+}
+
+func (remoteSuite) TestIsTransient(c *check.C) {
+	c.Check(remote.IsTransient(&remote.TransientError{Err: errors.New("boom")}), check.Equals, true)
+	c.Check(remote.IsTransient(errors.New("boom")), check.Equals, false)
+	c.Check(remote.IsTransient(nil), check.Equals, false)
+}
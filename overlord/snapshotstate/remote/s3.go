@@ -0,0 +1,248 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package remote
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	// the largest object a single PUT can upload
+	maxS3PutSize = 5 * 1024 * 1024 * 1024
+
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+)
+
+var (
+	timeNow = time.Now
+
+	s3Client = &http.Client{}
+)
+
+// s3Remote stores snapshots as objects in a bucket of an S3-compatible
+// store, addressed path-style (<endpoint>/<bucket>/<key>) so that it
+// works with stand-ins like MinIO.
+type s3Remote struct {
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	region   string
+
+	accessKeyID     string
+	secretAccessKey string
+}
+
+func newS3Remote(u *url.URL, cfg *Config) (*s3Remote, error) {
+	if u.Host == "" {
+		return nil, errors.New("no bucket given in s3 remote URL")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	ep, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse s3 endpoint: %v", err)
+	}
+	if ep.Scheme != "http" && ep.Scheme != "https" || ep.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", endpoint)
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("no s3 credentials given")
+	}
+	return &s3Remote{
+		endpoint:        ep,
+		bucket:          u.Host,
+		prefix:          cleanPrefix(u.Path),
+		region:          region,
+		accessKeyID:     cfg.AccessKeyID,
+		secretAccessKey: cfg.SecretAccessKey,
+	}, nil
+}
+
+func (s *s3Remote) String() string {
+	if s.prefix == "" {
+		return "s3://" + s.bucket
+	}
+	return "s3://" + s.bucket + "/" + s.prefix
+}
+
+func (s *s3Remote) objectURL(name string) *url.URL {
+	u := *s.endpoint
+	key := name
+	if s.prefix != "" {
+		key = s.prefix + "/" + name
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	return &u
+}
+
+func (s *s3Remote) Push(ctx context.Context, name string, r io.Reader, size int64) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if size > maxS3PutSize {
+		return fmt.Errorf("cannot push %d bytes to s3 remote: more than the maximum of %d", size, int64(maxS3PutSize))
+	}
+	req, err := http.NewRequest("PUT", s.objectURL(name).String(), ioutil.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := s.do(ctx, req)
+	if err != nil {
+		return withPrefix(err, "cannot push %q to s3 remote", name)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Remote) Pull(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", s.objectURL(name).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, req)
+	if err != nil {
+		return nil, withPrefix(err, "cannot pull %q from s3 remote", name)
+	}
+	return resp.Body, nil
+}
+
+// do signs and sends the request, returning the response if it was
+// successful.
+func (s *s3Remote) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	s.sign(req, timeNow().UTC())
+	resp, err := s3Client.Do(req.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &TransientError{err}
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	err = s3ResponseError(resp)
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &TransientError{err}
+	}
+	return nil, err
+}
+
+// s3ResponseError returns an error built from the code and message of the
+// S3 error document in the response, if any.
+func s3ResponseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	code := xmlElement(string(body), "Code")
+	msg := xmlElement(string(body), "Message")
+	switch {
+	case code != "" && msg != "":
+		return fmt.Errorf("%s (%s: %s)", resp.Status, code, msg)
+	case code != "":
+		return fmt.Errorf("%s (%s)", resp.Status, code)
+	default:
+		return errors.New(resp.Status)
+	}
+}
+
+// xmlElement returns the text of the first element with the given name in
+// the document; S3 error documents are simple enough not to need an XML
+// parser.
+func xmlElement(doc, name string) string {
+	open, close := "<"+name+">", "</"+name+">"
+	i := strings.Index(doc, open)
+	if i < 0 {
+		return ""
+	}
+	doc = doc[i+len(open):]
+	j := strings.Index(doc, close)
+	if j < 0 {
+		return ""
+	}
+	return strings.TrimSpace(doc[:j])
+}
+
+// sign adds an AWS Signature Version 4 authorization header to the
+// request. The payload is not signed, so that it can be streamed.
+func (s *s3Remote) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format(s3TimeFormat)
+	scope := strings.Join([]string{now.Format(s3DateFormat), s.region, "s3", "aws4_request"}, "/")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + s3UnsignedPayload,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+	h := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(h[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + s.secretAccessKey)
+	for _, part := range []string{now.Format(s3DateFormat), s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,190 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package remote_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+)
+
+type s3Suite struct {
+	server *httptest.Server
+	remote remote.Remote
+
+	handler func(w http.ResponseWriter, r *http.Request)
+}
+
+var _ = check.Suite(&s3Suite{})
+
+func (s *s3Suite) SetUpTest(c *check.C) {
+	s.handler = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handler(w, r)
+	}))
+	r, err := remote.New(&remote.Config{
+		URL:             "s3://snaps/backups",
+		Endpoint:        s.server.URL,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "SECRET",
+	})
+	c.Assert(err, check.IsNil)
+	s.remote = r
+}
+
+func (s *s3Suite) TearDownTest(c *check.C) {
+	s.server.Close()
+}
+
+func (s *s3Suite) TestPush(c *check.C) {
+	var body []byte
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "PUT")
+		c.Check(r.URL.Path, check.Equals, "/snaps/backups/42.snapshot")
+		c.Check(r.ContentLength, check.Equals, int64(5))
+		c.Check(r.Header.Get("X-Amz-Content-Sha256"), check.Equals, "UNSIGNED-PAYLOAD")
+		c.Check(r.Header.Get("Authorization"), check.Matches, "AWS4-HMAC-SHA256 Credential=AKID/[0-9]{8}/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}")
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		c.Check(err, check.IsNil)
+	}
+
+	err := s.remote.Push(context.Background(), "42.snapshot", strings.NewReader("hello"), 5)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, "hello")
+}
+
+func (s *s3Suite) TestSign(c *check.C) {
+	r, err := remote.New(&remote.Config{
+		URL:             "s3://snaps/backups",
+		Endpoint:        "http://127.0.0.1:9000",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "SECRET",
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("PUT", "http://127.0.0.1:9000/snaps/backups/42.snapshot", nil)
+	c.Assert(err, check.IsNil)
+
+	remote.S3Sign(r, req, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	c.Check(req.Header.Get("X-Amz-Date"), check.Equals, "20210304T050607Z")
+	c.Check(req.Header.Get("X-Amz-Content-Sha256"), check.Equals, "UNSIGNED-PAYLOAD")
+	c.Check(req.Header.Get("Authorization"), check.Equals, "AWS4-HMAC-SHA256 Credential=AKID/20210304/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=25c4ce0ee52914d862d47dfb3b5462c4cc7b8b1e6a2dcdc765987a328e0ade70")
+}
+
+func (s *s3Suite) TestPushUsesCurrentTime(c *check.C) {
+	restore := remote.MockTimeNow(func() time.Time {
+		return time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	})
+	defer restore()
+
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("X-Amz-Date"), check.Equals, "20210304T040607Z")
+		ioutil.ReadAll(r.Body)
+	}
+	err := s.remote.Push(context.Background(), "42.snapshot", strings.NewReader("hello"), 5)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *s3Suite) TestPushTooBig(c *check.C) {
+	err := s.remote.Push(context.Background(), "42.snapshot", nil, 6*1024*1024*1024)
+	c.Check(err, check.ErrorMatches, "cannot push 6442450944 bytes to s3 remote: more than the maximum of 5368709120")
+}
+
+func (s *s3Suite) TestPushInvalidName(c *check.C) {
+	err := s.remote.Push(context.Background(), "../42.snapshot", nil, 0)
+	c.Check(err, check.ErrorMatches, `invalid remote snapshot name "../42.snapshot"`)
+}
+
+func (s *s3Suite) TestPushErrors(c *check.C) {
+	for _, t := range []struct {
+		status    int
+		body      string
+		err       string
+		transient bool
+	}{
+		{403, "<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>", `cannot push "42.snapshot" to s3 remote: 403 Forbidden \(AccessDenied: Access Denied\)`, false},
+		{404, "<Error><Code>NoSuchBucket</Code></Error>", `cannot push "42.snapshot" to s3 remote: 404 Not Found \(NoSuchBucket\)`, false},
+		{500, "", `cannot push "42.snapshot" to s3 remote: 500 Internal Server Error`, true},
+		{503, "<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>", `cannot push "42.snapshot" to s3 remote: 503 Service Unavailable \(SlowDown: Please reduce your request rate.\)`, true},
+		{429, "", `cannot push "42.snapshot" to s3 remote: 429 Too Many Requests`, true},
+	} {
+		s.handler = func(w http.ResponseWriter, r *http.Request) {
+			ioutil.ReadAll(r.Body)
+			w.WriteHeader(t.status)
+			w.Write([]byte(t.body))
+		}
+		err := s.remote.Push(context.Background(), "42.snapshot", strings.NewReader("hello"), 5)
+		c.Check(err, check.ErrorMatches, t.err)
+		c.Check(remote.IsTransient(err), check.Equals, t.transient, check.Commentf("%d", t.status))
+	}
+}
+
+func (s *s3Suite) TestPushConnectionError(c *check.C) {
+	s.server.Close()
+	err := s.remote.Push(context.Background(), "42.snapshot", strings.NewReader("hello"), 5)
+	c.Check(err, check.ErrorMatches, `cannot push "42.snapshot" to s3 remote: .*`)
+	c.Check(remote.IsTransient(err), check.Equals, true)
+}
+
+func (s *s3Suite) TestPushCancelled(c *check.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Error("unexpected request")
+	}
+	err := s.remote.Push(ctx, "42.snapshot", strings.NewReader("hello"), 5)
+	c.Check(err, check.ErrorMatches, `cannot push "42.snapshot" to s3 remote: context canceled`)
+	c.Check(remote.IsTransient(err), check.Equals, false)
+}
+
+func (s *s3Suite) TestPull(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/snaps/backups/42.snapshot")
+		c.Check(r.Header.Get("Authorization"), check.Matches, "AWS4-HMAC-SHA256 Credential=AKID/.*")
+		w.Write([]byte("hello"))
+	}
+
+	rc, err := s.remote.Pull(context.Background(), "42.snapshot")
+	c.Assert(err, check.IsNil)
+	defer rc.Close()
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(rc)
+	c.Assert(err, check.IsNil)
+	c.Check(buf.String(), check.Equals, "hello")
+}
+
+func (s *s3Suite) TestPullNotFound(c *check.C) {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		w.Write([]byte("<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>"))
+	}
+
+	_, err := s.remote.Pull(context.Background(), "42.snapshot")
+	c.Check(err, check.ErrorMatches, `cannot pull "42.snapshot" from s3 remote: 404 Not Found \(NoSuchKey: The specified key does not exist.\)`)
+	c.Check(remote.IsTransient(err), check.Equals, false)
+}
@@ -0,0 +1,206 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// sftp exits with this status when the connection to the server could
+// not be established or was lost
+const sftpConnectionFailedStatus = 255
+
+// sftpRemote stores snapshots as files in a directory of an SFTP server,
+// using the system's sftp client in batch mode so that the usual ssh
+// configuration (known hosts, etc) applies.
+type sftpRemote struct {
+	user         string
+	host         string
+	port         string
+	dir          string
+	identityFile string
+}
+
+func newSFTPRemote(u *url.URL, cfg *Config) (*sftpRemote, error) {
+	if u.Hostname() == "" {
+		return nil, errors.New("no host given in sftp remote URL")
+	}
+	dir := strings.TrimSuffix(u.Path, "/")
+	// paths are double-quoted in the sftp batch commands
+	if strings.ContainsAny(dir, "\"\\\n") {
+		return nil, fmt.Errorf("invalid sftp remote path %q", u.Path)
+	}
+	if cfg.IdentityFile != "" && !filepath.IsAbs(cfg.IdentityFile) {
+		return nil, fmt.Errorf("sftp identity file %q is not an absolute path", cfg.IdentityFile)
+	}
+	return &sftpRemote{
+		user:         u.User.Username(),
+		host:         u.Hostname(),
+		port:         u.Port(),
+		dir:          dir,
+		identityFile: cfg.IdentityFile,
+	}, nil
+}
+
+func (s *sftpRemote) String() string {
+	u := url.URL{Scheme: "sftp", Host: s.host, Path: s.dir}
+	if s.port != "" {
+		u.Host += ":" + s.port
+	}
+	if s.user != "" {
+		u.User = url.User(s.user)
+	}
+	return u.String()
+}
+
+func (s *sftpRemote) path(name string) string {
+	if s.dir == "" {
+		// relative to the login directory
+		return name
+	}
+	return s.dir + "/" + name
+}
+
+// run runs the given sftp batch commands against the server.
+func (s *sftpRemote) run(ctx context.Context, batch string) error {
+	args := []string{"-b", "-", "-o", "BatchMode=yes"}
+	if s.port != "" {
+		args = append(args, "-P", s.port)
+	}
+	if s.identityFile != "" {
+		args = append(args, "-i", s.identityFile)
+	}
+	dest := s.host
+	if s.user != "" {
+		dest = s.user + "@" + s.host
+	}
+	// keep a host starting with a dash from being taken as an option
+	args = append(args, "--", dest)
+
+	cmd := exec.Command("sftp", args...)
+	var output bytes.Buffer
+	cmd.Stdin = strings.NewReader(batch)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := osutil.RunWithContext(ctx, cmd); err != nil {
+		transient := false
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.ExitStatus() == sftpConnectionFailedStatus {
+				transient = true
+			}
+		}
+		err = osutil.OutputErr(output.Bytes(), err)
+		if transient {
+			return &TransientError{err}
+		}
+		return err
+	}
+	return nil
+}
+
+// tempFile returns a new temporary file to stage transfers in, next to
+// the snapshots.
+func tempFile() (*os.File, error) {
+	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
+		return nil, err
+	}
+	return ioutil.TempFile(dirs.SnapshotsDir, ".remote-transfer-")
+}
+
+func (s *sftpRemote) Push(ctx context.Context, name string, r io.Reader, size int64) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	f, err := tempFile()
+	if err != nil {
+		return fmt.Errorf("cannot stage snapshot for sftp remote: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	n, err := io.Copy(f, r)
+	if err != nil {
+		return fmt.Errorf("cannot stage snapshot for sftp remote: %v", err)
+	}
+	if n != size {
+		return fmt.Errorf("cannot stage snapshot for sftp remote: expected %d bytes, got %d", size, n)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot stage snapshot for sftp remote: %v", err)
+	}
+
+	// upload under a temporary name first so that a partial upload is
+	// never mistaken for a complete one
+	target := s.path(name)
+	partial := target + ".part"
+	batch := fmt.Sprintf("put \"%s\" \"%s\"\n-rm \"%s\"\nrename \"%s\" \"%s\"\n", f.Name(), partial, target, partial, target)
+	if err := s.run(ctx, batch); err != nil {
+		return withPrefix(err, "cannot push %q to sftp remote", name)
+	}
+	return nil
+}
+
+func (s *sftpRemote) Pull(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	f, err := tempFile()
+	if err != nil {
+		return nil, fmt.Errorf("cannot stage snapshot from sftp remote: %v", err)
+	}
+	staged := f.Name()
+	f.Close()
+
+	batch := fmt.Sprintf("get \"%s\" \"%s\"\n", s.path(name), staged)
+	if err := s.run(ctx, batch); err != nil {
+		os.Remove(staged)
+		return nil, withPrefix(err, "cannot pull %q from sftp remote", name)
+	}
+	f, err = os.Open(staged)
+	if err != nil {
+		os.Remove(staged)
+		return nil, fmt.Errorf("cannot stage snapshot from sftp remote: %v", err)
+	}
+	return &removingFile{f}, nil
+}
+
+// removingFile is a file that is removed once closed.
+type removingFile struct {
+	*os.File
+}
+
+func (f *removingFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
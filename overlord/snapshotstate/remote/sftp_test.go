@@ -0,0 +1,199 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package remote_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type sftpSuite struct {
+	batchLog string
+}
+
+var _ = check.Suite(&sftpSuite{})
+
+func (s *sftpSuite) SetUpTest(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	s.batchLog = filepath.Join(c.MkDir(), "batch")
+}
+
+func (s *sftpSuite) TearDownTest(c *check.C) {
+	dirs.SetRootDir("")
+}
+
+// mockSFTP mocks sftp so that it logs its batch commands, and copies the
+// staged file of a put for the test to inspect, or stages the given
+// content on a get.
+func (s *sftpSuite) mockSFTP(c *check.C, content string, exitStatus int) *testutil.MockCmd {
+	return testutil.MockCommand(c, "sftp", fmt.Sprintf(`
+cat > %[1]q
+put=$(sed -n 's/^put "\([^"]*\)" .*/\1/p' %[1]q)
+if [ -n "$put" ]; then
+    cp "$put" %[1]q.put
+fi
+get=$(sed -n 's/^get "[^"]*" "\([^"]*\)"$/\1/p' %[1]q)
+if [ -n "$get" ]; then
+    printf '%%s' %[2]q > "$get"
+fi
+if [ %[3]d -ne 0 ]; then
+    echo "sftp failed" >&2
+fi
+exit %[3]d
+`, s.batchLog, content, exitStatus))
+}
+
+func (s *sftpSuite) stagedFiles(c *check.C) []string {
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, ".remote-transfer-*"))
+	c.Assert(err, check.IsNil)
+	return matches
+}
+
+func (s *sftpSuite) TestPush(c *check.C) {
+	cmd := s.mockSFTP(c, "", 0)
+	defer cmd.Restore()
+
+	r, err := remote.New(&remote.Config{URL: "sftp://backup@example.com:2222/srv/snaps", IdentityFile: "/root/.ssh/id_backup"})
+	c.Assert(err, check.IsNil)
+
+	err = r.Push(context.Background(), "42.snapshot", strings.NewReader("hello"), 5)
+	c.Assert(err, check.IsNil)
+
+	c.Check(cmd.Calls(), check.DeepEquals, [][]string{
+		{"sftp", "-b", "-", "-o", "BatchMode=yes", "-P", "2222", "-i", "/root/.ssh/id_backup", "--", "backup@example.com"},
+	})
+	batch, err := ioutil.ReadFile(s.batchLog)
+	c.Assert(err, check.IsNil)
+	c.Check(string(batch), check.Matches, `put ".*/var/lib/snapd/snapshots/.remote-transfer-[0-9]+" "/srv/snaps/42.snapshot.part"
+-rm "/srv/snaps/42.snapshot"
+rename "/srv/snaps/42.snapshot.part" "/srv/snaps/42.snapshot"
+`)
+	c.Check(s.batchLog+".put", testutil.FileEquals, "hello")
+	c.Check(s.stagedFiles(c), check.HasLen, 0)
+}
+
+func (s *sftpSuite) TestPushNoPath(c *check.C) {
+	cmd := s.mockSFTP(c, "", 0)
+	defer cmd.Restore()
+
+	r, err := remote.New(&remote.Config{URL: "sftp://example.com"})
+	c.Assert(err, check.IsNil)
+
+	err = r.Push(context.Background(), "42.snapshot", strings.NewReader("hello"), 5)
+	c.Assert(err, check.IsNil)
+
+	c.Check(cmd.Calls(), check.DeepEquals, [][]string{
+		{"sftp", "-b", "-", "-o", "BatchMode=yes", "--", "example.com"},
+	})
+	batch, err := ioutil.ReadFile(s.batchLog)
+	c.Assert(err, check.IsNil)
+	c.Check(string(batch), check.Matches, `(?s)put ".*" "42.snapshot.part"
+-rm "42.snapshot"
+rename "42.snapshot.part" "42.snapshot"
+`)
+}
+
+func (s *sftpSuite) TestPushShortRead(c *check.C) {
+	cmd := s.mockSFTP(c, "", 0)
+	defer cmd.Restore()
+
+	r, err := remote.New(&remote.Config{URL: "sftp://example.com"})
+	c.Assert(err, check.IsNil)
+
+	err = r.Push(context.Background(), "42.snapshot", strings.NewReader("hello"), 10)
+	c.Check(err, check.ErrorMatches, "cannot stage snapshot for sftp remote: expected 10 bytes, got 5")
+	c.Check(cmd.Calls(), check.HasLen, 0)
+	c.Check(s.stagedFiles(c), check.HasLen, 0)
+}
+
+func (s *sftpSuite) TestPushErrors(c *check.C) {
+	for _, t := range []struct {
+		exitStatus int
+		transient  bool
+	}{
+		{1, false},
+		{255, true},
+	} {
+		cmd := s.mockSFTP(c, "", t.exitStatus)
+
+		r, err := remote.New(&remote.Config{URL: "sftp://example.com/srv"})
+		c.Assert(err, check.IsNil)
+
+		err = r.Push(context.Background(), "42.snapshot", strings.NewReader("hello"), 5)
+		c.Check(err, check.ErrorMatches, `cannot push "42.snapshot" to sftp remote: sftp failed`)
+		c.Check(remote.IsTransient(err), check.Equals, t.transient, check.Commentf("%d", t.exitStatus))
+		c.Check(s.stagedFiles(c), check.HasLen, 0)
+		cmd.Restore()
+	}
+}
+
+func (s *sftpSuite) TestPull(c *check.C) {
+	cmd := s.mockSFTP(c, "hello", 0)
+	defer cmd.Restore()
+
+	r, err := remote.New(&remote.Config{URL: "sftp://backup@example.com/srv/snaps"})
+	c.Assert(err, check.IsNil)
+
+	rc, err := r.Pull(context.Background(), "42.snapshot")
+	c.Assert(err, check.IsNil)
+	data, err := ioutil.ReadAll(rc)
+	c.Assert(err, check.IsNil)
+	c.Check(string(data), check.Equals, "hello")
+	c.Check(s.stagedFiles(c), check.HasLen, 1)
+	c.Assert(rc.Close(), check.IsNil)
+	c.Check(s.stagedFiles(c), check.HasLen, 0)
+
+	c.Check(cmd.Calls(), check.DeepEquals, [][]string{
+		{"sftp", "-b", "-", "-o", "BatchMode=yes", "--", "backup@example.com"},
+	})
+	batch, err := ioutil.ReadFile(s.batchLog)
+	c.Assert(err, check.IsNil)
+	c.Check(string(batch), check.Matches, `get "/srv/snaps/42.snapshot" ".*/var/lib/snapd/snapshots/.remote-transfer-[0-9]+"
+`)
+}
+
+func (s *sftpSuite) TestPullError(c *check.C) {
+	cmd := s.mockSFTP(c, "", 1)
+	defer cmd.Restore()
+
+	r, err := remote.New(&remote.Config{URL: "sftp://example.com/srv/snaps"})
+	c.Assert(err, check.IsNil)
+
+	_, err = r.Pull(context.Background(), "42.snapshot")
+	c.Check(err, check.ErrorMatches, `cannot pull "42.snapshot" from sftp remote: sftp failed`)
+	c.Check(remote.IsTransient(err), check.Equals, false)
+	c.Check(s.stagedFiles(c), check.HasLen, 0)
+}
+
+func (s *sftpSuite) TestPullInvalidName(c *check.C) {
+	r, err := remote.New(&remote.Config{URL: "sftp://example.com/srv/snaps"})
+	c.Assert(err, check.IsNil)
+
+	_, err = r.Pull(context.Background(), "a b")
+	c.Check(err, check.ErrorMatches, `invalid remote snapshot name "a b"`)
+}
@@ -0,0 +1,491 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package snapshotstate_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/remote"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type fakeRemote struct {
+	pushed map[string][]byte
+	errs   []error
+}
+
+func (r *fakeRemote) nextErr() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+	err := r.errs[0]
+	r.errs = r.errs[1:]
+	return err
+}
+
+func (r *fakeRemote) Push(ctx context.Context, name string, rd io.Reader, size int64) error {
+	if err := r.nextErr(); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return errors.New("size mismatch")
+	}
+	r.pushed[name] = data
+	return nil
+}
+
+func (r *fakeRemote) Pull(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := r.nextErr(); err != nil {
+		return nil, err
+	}
+	data, ok := r.pushed[name]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (r *fakeRemote) String() string {
+	return "fake://remote"
+}
+
+type remoteSuite struct {
+	remote  *fakeRemote
+	configs []*remote.Config
+	restore func()
+}
+
+var _ = check.Suite(&remoteSuite{})
+
+func (s *remoteSuite) SetUpTest(c *check.C) {
+	snapshotSuite{}.SetUpTest(c)
+	s.remote = &fakeRemote{pushed: make(map[string][]byte)}
+	s.configs = nil
+	restoreNew := snapshotstate.MockRemoteNew(func(cfg *remote.Config) (remote.Remote, error) {
+		s.configs = append(s.configs, cfg)
+		return s.remote, nil
+	})
+	restoreRetry := snapshotstate.MockRemoteTransferRetry(3, time.Minute)
+	s.restore = func() {
+		restoreNew()
+		restoreRetry()
+	}
+}
+
+func (s *remoteSuite) TearDownTest(c *check.C) {
+	s.restore()
+	snapshotSuite{}.TearDownTest(c)
+}
+
+func setRemoteConfig(c *check.C, st *state.State, opts map[string]interface{}) {
+	tr := config.NewTransaction(st)
+	for opt, value := range opts {
+		c.Assert(tr.Set("core", opt, value), check.IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *remoteSuite) TestSnapshotRemote(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := snapshotstate.SnapshotRemote(st)
+	c.Check(err, check.Equals, snapshotstate.ErrNoRemote)
+	c.Check(s.configs, check.HasLen, 0)
+
+	setRemoteConfig(c, st, map[string]interface{}{
+		"snapshots.remote.url":               "s3://bucket/prefix",
+		"snapshots.remote.endpoint":          "http://localhost:9000",
+		"snapshots.remote.region":            "eu-west-1",
+		"snapshots.remote.access-key-id":     "id",
+		"snapshots.remote.secret-access-key": "secret",
+		"snapshots.remote.identity-file":     "/root/.ssh/id_backup",
+	})
+	rem, err := snapshotstate.SnapshotRemote(st)
+	c.Assert(err, check.IsNil)
+	c.Check(rem, check.Equals, s.remote)
+	c.Check(s.configs, check.DeepEquals, []*remote.Config{{
+		URL:             "s3://bucket/prefix",
+		Endpoint:        "http://localhost:9000",
+		Region:          "eu-west-1",
+		AccessKeyID:     "id",
+		SecretAccessKey: "secret",
+		IdentityFile:    "/root/.ssh/id_backup",
+	}})
+
+	restore := snapshotstate.MockRemoteNew(func(cfg *remote.Config) (remote.Remote, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+	_, err = snapshotstate.SnapshotRemote(st)
+	c.Check(err, check.ErrorMatches, "cannot use snapshots.remote.url: boom")
+}
+
+func (s *remoteSuite) TestSnapshotRemoteSecretAccessKey(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	secret, err := config.NewSecret("core", "secret")
+	c.Assert(err, check.IsNil)
+	setRemoteConfig(c, st, map[string]interface{}{
+		"snapshots.remote.url":               "s3://bucket/prefix",
+		"snapshots.remote.access-key-id":     "id",
+		"snapshots.remote.secret-access-key": secret,
+	})
+	_, err = snapshotstate.SnapshotRemote(st)
+	c.Assert(err, check.IsNil)
+	c.Check(s.configs, check.DeepEquals, []*remote.Config{{
+		URL:             "s3://bucket/prefix",
+		AccessKeyID:     "id",
+		SecretAccessKey: "secret",
+	}})
+}
+
+func (s *remoteSuite) TestPush(c *check.C) {
+	defer mockDummySnapshot(c)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+
+	found, ts, err := snapshotstate.Push(st, 1, "")
+	c.Assert(err, check.IsNil)
+	c.Check(found, check.DeepEquals, []string{"a-snap"})
+	tasks := ts.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "push-snapshot")
+	c.Check(tasks[0].Summary(), check.Equals, `Push snapshot set #1 to remote as "1.snapshot"`)
+	var setup map[string]interface{}
+	c.Assert(tasks[0].Get("remote-setup", &setup), check.IsNil)
+	c.Check(setup, check.DeepEquals, map[string]interface{}{"name": "1.snapshot"})
+
+	_, ts, err = snapshotstate.Push(st, 1, "my-backup")
+	c.Assert(err, check.IsNil)
+	c.Check(ts.Tasks()[0].Summary(), check.Equals, `Push snapshot set #1 to remote as "my-backup"`)
+}
+
+func (s *remoteSuite) TestPushErrors(c *check.C) {
+	defer mockDummySnapshot(c)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, err := snapshotstate.Push(st, 1, "")
+	c.Check(err, check.Equals, snapshotstate.ErrNoRemote)
+
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+
+	_, _, err = snapshotstate.Push(st, 1, "../foo")
+	c.Check(err, check.ErrorMatches, `invalid remote snapshot name "../foo"`)
+
+	_, _, err = snapshotstate.Push(st, 2, "")
+	c.Check(err, check.Equals, client.ErrSnapshotSetNotFound)
+
+	chg := st.NewChange("forget-snapshot", "...")
+	task := st.NewTask("forget-snapshot", "...")
+	task.Set("snapshot-setup", map[string]int{"set-id": 1})
+	chg.AddTask(task)
+
+	_, _, err = snapshotstate.Push(st, 1, "")
+	c.Check(err, check.ErrorMatches, `cannot operate on snapshot set #1 while change "1" is in progress`)
+}
+
+func (s *remoteSuite) TestForgetChecksPushConflicts(c *check.C) {
+	defer mockDummySnapshot(c)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+
+	_, ts, err := snapshotstate.Push(st, 1, "")
+	c.Assert(err, check.IsNil)
+	chg := st.NewChange("push-snapshot", "...")
+	chg.AddAll(ts)
+
+	_, _, err = snapshotstate.Forget(st, 1, nil)
+	c.Check(err, check.ErrorMatches, `cannot operate on snapshot set #1 while change "1" is in progress`)
+}
+
+func (s *remoteSuite) TestPull(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := snapshotstate.Pull(st, "1.snapshot")
+	c.Check(err, check.Equals, snapshotstate.ErrNoRemote)
+
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+
+	_, err = snapshotstate.Pull(st, "a/b")
+	c.Check(err, check.ErrorMatches, `invalid remote snapshot name "a/b"`)
+
+	ts, err := snapshotstate.Pull(st, "1.snapshot")
+	c.Assert(err, check.IsNil)
+	tasks := ts.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "pull-snapshot")
+	c.Check(tasks[0].Summary(), check.Equals, `Pull snapshot "1.snapshot" from remote and import it`)
+	var setup map[string]interface{}
+	c.Assert(tasks[0].Get("remote-setup", &setup), check.IsNil)
+	c.Check(setup, check.DeepEquals, map[string]interface{}{"name": "1.snapshot"})
+}
+
+func (s *remoteSuite) TestSaveAutoPush(c *check.C) {
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+			"b-snap": {Active: true},
+		}, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.auto-push": true})
+
+	setID, saved, ts, err := snapshotstate.Save(st, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(saved, check.DeepEquals, []string{"a-snap", "b-snap"})
+	tasks := ts.Tasks()
+	c.Assert(tasks, check.HasLen, 3)
+	push := tasks[2]
+	c.Check(push.Kind(), check.Equals, "push-snapshot")
+	c.Check(push.WaitTasks(), check.DeepEquals, tasks[:2])
+	var setup map[string]interface{}
+	c.Assert(push.Get("remote-setup", &setup), check.IsNil)
+	c.Check(setup, check.DeepEquals, map[string]interface{}{"name": snapshotstate.RemoteName(setID), "auto": true})
+
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.auto-push": "maybe"})
+	_, _, _, err = snapshotstate.Save(st, nil, nil)
+	c.Check(err, check.ErrorMatches, `snapshots.remote.auto-push can only be set to 'true' or 'false', got "maybe"`)
+}
+
+// saveSnapshot saves a snapshot of the system data of a snap in the given
+// set.
+func saveSnapshot(c *check.C, setID uint64, name string) {
+	sideInfo := &snap.SideInfo{RealName: name, Revision: snap.R(1)}
+	snapInfo := snaptest.MockSnap(c, "{name: "+name+", version: v1}", sideInfo)
+	c.Assert(os.MkdirAll(snapInfo.DataDir(), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(snapInfo.DataDir(), "canary"), []byte("hello\n"), 0644), check.IsNil)
+	_, err := backend.Save(context.TODO(), setID, snapInfo, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+}
+
+func newTransferTask(st *state.State, kind string, setup map[string]interface{}) *state.Task {
+	chg := st.NewChange(kind, "...")
+	task := st.NewTask(kind, "...")
+	if kind == "push-snapshot" {
+		task.Set("snapshot-setup", map[string]interface{}{"set-id": 42})
+	}
+	task.Set("remote-setup", setup)
+	chg.AddTask(task)
+	return task
+}
+
+func (s *remoteSuite) TestDoPush(c *check.C) {
+	saveSnapshot(c, 42, "a-snap")
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+	task := newTransferTask(st, "push-snapshot", map[string]interface{}{"name": "42.snapshot"})
+
+	st.Unlock()
+	err := snapshotstate.DoPush(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+	c.Check(strings.Join(task.Log(), "\n"), check.Matches, `.* Pushed snapshot set #42 to fake://remote as "42.snapshot"`)
+
+	// what was pushed is an export of the set
+	data := s.remote.pushed["42.snapshot"]
+	c.Assert(data, check.NotNil)
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, check.IsNil)
+		names = append(names, hdr.Name)
+	}
+	c.Check(names, check.DeepEquals, []string{"content.json", "42_a-snap_v1_1.zip", "export.json"})
+}
+
+func (s *remoteSuite) TestDoPushRetries(c *check.C) {
+	saveSnapshot(c, 42, "a-snap")
+	transient := &remote.TransientError{Err: errors.New("connection reset")}
+	s.remote.errs = []error{transient, transient, transient}
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+	task := newTransferTask(st, "push-snapshot", map[string]interface{}{"name": "42.snapshot"})
+
+	for attempt := 1; attempt < 3; attempt++ {
+		st.Unlock()
+		err := snapshotstate.DoPush(task, &tomb.Tomb{})
+		st.Lock()
+		c.Assert(err, check.FitsTypeOf, &state.Retry{})
+		c.Check(err.(*state.Retry).After, check.Equals, time.Duration(attempt)*time.Minute)
+		var setup map[string]interface{}
+		c.Assert(task.Get("remote-setup", &setup), check.IsNil)
+		c.Check(setup["attempts"], check.Equals, float64(attempt))
+	}
+
+	st.Unlock()
+	err := snapshotstate.DoPush(task, &tomb.Tomb{})
+	st.Lock()
+	c.Check(err, check.ErrorMatches, `connection reset \(giving up after 3 attempts\)`)
+	c.Check(s.remote.pushed, check.HasLen, 0)
+}
+
+func (s *remoteSuite) TestDoPushFails(c *check.C) {
+	saveSnapshot(c, 42, "a-snap")
+	s.remote.errs = []error{errors.New("permission denied")}
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+	task := newTransferTask(st, "push-snapshot", map[string]interface{}{"name": "42.snapshot"})
+
+	st.Unlock()
+	err := snapshotstate.DoPush(task, &tomb.Tomb{})
+	st.Lock()
+	c.Check(err, check.ErrorMatches, "permission denied")
+}
+
+func (s *remoteSuite) TestDoPushAutoFails(c *check.C) {
+	saveSnapshot(c, 42, "a-snap")
+	s.remote.errs = []error{errors.New("permission denied")}
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+	task := newTransferTask(st, "push-snapshot", map[string]interface{}{"name": "42.snapshot", "auto": true})
+
+	// the snapshots are kept, and can be pushed by hand later
+	st.Unlock()
+	err := snapshotstate.DoPush(task, &tomb.Tomb{})
+	st.Lock()
+	c.Check(err, check.IsNil)
+	c.Check(strings.Join(task.Log(), "\n"), check.Matches, `.* ERROR Cannot push snapshot set #42 to remote: permission denied`)
+}
+
+func (s *remoteSuite) TestDoPushNoRemote(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	task := newTransferTask(st, "push-snapshot", map[string]interface{}{"name": "42.snapshot"})
+
+	st.Unlock()
+	err := snapshotstate.DoPush(task, &tomb.Tomb{})
+	st.Lock()
+	c.Check(err, check.Equals, snapshotstate.ErrNoRemote)
+}
+
+func (s *remoteSuite) TestDoPull(c *check.C) {
+	s.remote.pushed["42.snapshot"] = []byte("exported data")
+	transient := &remote.TransientError{Err: errors.New("connection reset")}
+	s.remote.errs = []error{transient}
+
+	var imported []byte
+	defer snapshotstate.MockBackendImport(func(ctx context.Context, setID uint64, r io.Reader, flags *backend.ImportFlags) ([]string, error) {
+		c.Check(setID, check.Equals, uint64(1))
+		var err error
+		imported, err = ioutil.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		return []string{"a-snap", "b-snap"}, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+	task := newTransferTask(st, "pull-snapshot", map[string]interface{}{"name": "42.snapshot"})
+
+	st.Unlock()
+	err := snapshotstate.DoPull(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.FitsTypeOf, &state.Retry{})
+
+	st.Unlock()
+	err = snapshotstate.DoPull(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+	c.Check(string(imported), check.Equals, "exported data")
+	c.Check(strings.Join(task.Log(), "\n"), check.Matches, `(?s).* Imported snapshot "42.snapshot" pulled from fake://remote as snapshot set #1`)
+
+	var apiData map[string]interface{}
+	c.Assert(task.Change().Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]interface{}{
+		"set-id":     1.,
+		"snap-names": []interface{}{"a-snap", "b-snap"},
+	})
+}
+
+func (s *remoteSuite) TestDoPullImportFails(c *check.C) {
+	s.remote.pushed["42.snapshot"] = []byte("exported data")
+	defer snapshotstate.MockBackendImport(func(ctx context.Context, setID uint64, r io.Reader, flags *backend.ImportFlags) ([]string, error) {
+		return nil, errors.New("not a snapshot export")
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	setRemoteConfig(c, st, map[string]interface{}{"snapshots.remote.url": "sftp://host"})
+	task := newTransferTask(st, "pull-snapshot", map[string]interface{}{"name": "42.snapshot"})
+
+	st.Unlock()
+	err := snapshotstate.DoPull(task, &tomb.Tomb{})
+	st.Lock()
+	c.Check(err, check.ErrorMatches, `cannot import snapshot "42.snapshot" pulled from fake://remote: not a snapshot export`)
+}
@@ -29,12 +29,15 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 var (
@@ -51,8 +54,11 @@ var (
 	backendCleanup       = (*backend.RestoreState).Cleanup
 
 	backendCleanupAbandondedImports = backend.CleanupAbandondedImports
+	backendPruneChunks              = backend.PruneChunks
 
 	autoExpirationInterval = time.Hour * 24 // interval between forgetExpiredSnapshots runs as part of Ensure()
+
+	maxScheduledSnapshotDelay = time.Hour * 24 * 60 // maximum time between scheduled snapshots, whatever the schedule
 )
 
 // SnapshotManager takes snapshots of active snaps
@@ -60,6 +66,9 @@ type SnapshotManager struct {
 	state *state.State
 
 	lastForgetExpiredSnapshotTime time.Time
+
+	nextScheduledSnapshot time.Time
+	lastSnapshotSchedule  string
 }
 
 // Manager returns a new SnapshotManager
@@ -71,6 +80,8 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 	runner.AddHandler("check-snapshot", doCheck, nil)
 	runner.AddHandler("restore-snapshot", doRestore, undoRestore)
 	runner.AddCleanup("restore-snapshot", cleanupRestore)
+	runner.AddHandler("push-snapshot", doPush, nil)
+	runner.AddHandler("pull-snapshot", doPull, nil)
 
 	manager := &SnapshotManager{
 		state: st,
@@ -82,12 +93,21 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 
 // Ensure is part of the overlord.StateManager interface.
 func (mgr *SnapshotManager) Ensure() error {
+	var err error
 	// process expired snapshots once a day.
 	if time.Now().After(mgr.lastForgetExpiredSnapshotTime.Add(autoExpirationInterval)) {
-		return mgr.forgetExpiredSnapshots()
+		err = mgr.forgetExpiredSnapshots()
 	}
 
-	return nil
+	if schedErr := mgr.ensureScheduledSnapshots(); err == nil {
+		err = schedErr
+	}
+
+	mgr.state.Lock()
+	dropUnusedSecrets(mgr.state)
+	mgr.state.Unlock()
+
+	return err
 }
 
 func (mgr *SnapshotManager) StartUp() error {
@@ -110,10 +130,32 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 		return nil
 	}
 
+	if _, err := forgetSnapshotSets(mgr.state, sets); err != nil {
+		return fmt.Errorf("cannot process expired snapshots: %v", err)
+	}
+
+	// only reset time if there are no sets left because of conflicts
+	if len(sets) == 0 {
+		mgr.lastForgetExpiredSnapshotTime = time.Now()
+	}
+
+	return nil
+}
+
+// forgetSnapshotSets removes the given snapshot sets from the state and from
+// disk. The sets that were removed are deleted from the given map, the sets
+// that are in use by another operation are left there to be retried later.
+// The sets that were not found on disk are returned.
+// The state needs to be locked by the caller.
+func forgetSnapshotSets(st *state.State, sets map[uint64]bool) (missing []uint64, err error) {
+	found := make(map[uint64]bool, len(sets))
 	err = backendIter(context.TODO(), func(r *backend.Reader) error {
-		// forget needs to conflict with check and restore
-		if err := checkSnapshotConflict(mgr.state, r.SetID, "export-snapshot",
-			"check-snapshot", "restore-snapshot"); err != nil {
+		if sets[r.SetID] {
+			found[r.SetID] = true
+		}
+		// forget needs to conflict with check, restore and push
+		if err := checkSnapshotConflict(st, r.SetID, "export-snapshot",
+			"check-snapshot", "restore-snapshot", "push-snapshot"); err != nil {
 			// there is a conflict, do nothing and we will retry this set on next Ensure().
 			return nil
 		}
@@ -123,7 +165,7 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 			// to automatically remove this snapshot again and will leave it on the disk (so the user can still try to remove it manually);
 			// this is better than the other way around where a failing osRemove would be retried forever because snapshot would never
 			// leave the state.
-			if err := removeSnapshotState(mgr.state, r.SetID); err != nil {
+			if err := removeSnapshotState(st, r.SetID); err != nil {
 				return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", r.SetID, err)
 			}
 			if err := osRemove(r.Name()); err != nil {
@@ -134,21 +176,150 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 	})
 
 	if err != nil {
-		return fmt.Errorf("cannot process expired snapshots: %v", err)
+		return nil, err
 	}
 
-	// only reset time if there are no sets left because of conflicts
-	if len(sets) == 0 {
-		mgr.lastForgetExpiredSnapshotTime = time.Now()
+	// the data of incremental snapshots may still be used by others
+	pruneChunks(st)
+
+	for setID := range sets {
+		if !found[setID] {
+			missing = append(missing, setID)
+		}
+	}
+	return missing, nil
+}
+
+// ensureScheduledSnapshots forgets the scheduled snapshots that are no
+// longer kept by the retention rules, and takes a new scheduled snapshot if
+// it's time to do so according to snapshots.schedule.
+func (mgr *SnapshotManager) ensureScheduledSnapshots() error {
+	st := mgr.state
+	st.Lock()
+	defer st.Unlock()
+
+	// ensure nothing is in flight already
+	if scheduledSnapshotInFlight(st) {
+		return nil
+	}
+
+	// retention rules also apply if snapshots are no longer scheduled
+	if err := forgetUnretainedSnapshots(st); err != nil {
+		return fmt.Errorf("cannot process scheduled snapshots: %v", err)
+	}
+
+	schedule, scheduleStr, err := scheduledSnapshotSchedule(st)
+	if err != nil {
+		return err
+	}
+	if len(schedule) == 0 {
+		mgr.nextScheduledSnapshot = time.Time{}
+		return nil
+	}
+	// we already have a snapshot time, check if we got a new config
+	if !mgr.nextScheduledSnapshot.IsZero() && mgr.lastSnapshotSchedule != scheduleStr {
+		logger.Debugf("Snapshot schedule changed.")
+		mgr.nextScheduledSnapshot = time.Time{}
+	}
+	mgr.lastSnapshotSchedule = scheduleStr
+
+	now := time.Now()
+	// compute next snapshot time (if needed)
+	if mgr.nextScheduledSnapshot.IsZero() {
+		var last time.Time
+		if err := st.Get("last-scheduled-snapshot", &last); err != nil && err != state.ErrNoState {
+			return err
+		}
+		if last.IsZero() {
+			// snapshots were just scheduled, go by the schedule
+			// from now on
+			last = now
+			st.Set("last-scheduled-snapshot", last)
+		}
+		mgr.nextScheduledSnapshot = now.Add(timeutil.Next(schedule, last, maxScheduledSnapshotDelay))
+		logger.Debugf("Next scheduled snapshot at %s.", mgr.nextScheduledSnapshot.Format(time.RFC3339))
+	}
+	if mgr.nextScheduledSnapshot.After(now) {
+		return nil
+	}
+
+	snapNames, err := scheduledSnapshotSnaps(st)
+	if err != nil {
+		return err
 	}
+	if len(snapNames) > 0 {
+		setID, snapsSaved, ts, err := Save(st, snapNames, nil)
+		if err != nil {
+			if _, ok := err.(*snapstate.ChangeConflictError); ok {
+				// try again on next Ensure()
+				logger.Noticef("Cannot take scheduled snapshot, will retry: %v", err)
+				return nil
+			}
+			return err
+		}
+		if err := saveScheduledTime(st, setID, now); err != nil {
+			return err
+		}
+
+		msg := fmt.Sprintf(i18n.G("Take scheduled snapshot of snaps %s"), strutil.Quoted(snapsSaved))
+		chg := st.NewChange(scheduledSnapshotChangeKind, msg)
+		chg.AddAll(ts)
+		chg.Set("snap-names", snapsSaved)
+		chg.Set("api-data", map[string]interface{}{"set-id": setID})
+		st.EnsureBefore(0)
+	}
+
+	st.Set("last-scheduled-snapshot", now)
+	mgr.nextScheduledSnapshot = time.Time{}
 
 	return nil
 }
 
+// forgetUnretainedSnapshots forgets the scheduled snapshot sets that are not
+// kept by the retention rules.
+// The state needs to be locked by the caller.
+func forgetUnretainedSnapshots(st *state.State) error {
+	sets, err := scheduledSnapshotSets(st)
+	if err != nil {
+		return err
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+	retention, err := scheduledSnapshotsRetention(st)
+	if err != nil {
+		return err
+	}
+	expired := retention.expired(sets)
+	if len(expired) == 0 {
+		return nil
+	}
+
+	missing, err := forgetSnapshotSets(st, expired)
+	if err != nil {
+		return err
+	}
+	// the sets are gone already, do not try to forget them again
+	return removeSnapshotState(st, missing...)
+}
+
+// pruneChunks removes the data of incremental snapshots that is no longer
+// used by any snapshot. It must be called with the state locked, but
+// releases the lock while reading the snapshots.
+func pruneChunks(st *state.State) {
+	st.Unlock()
+	defer st.Lock()
+
+	if _, err := backendPruneChunks(context.TODO()); err != nil {
+		logger.Noticef("Cannot remove unused data of incremental snapshots: %v", err)
+	}
+}
+
 func (SnapshotManager) affectedSnaps(t *state.Task) ([]string, error) {
-	if k := t.Kind(); k == "check-snapshot" || k == "forget-snapshot" {
-		// check and forget don't affect snaps
-		// (this could also be written k != save && k != restore, but it's safer this way around)
+	switch t.Kind() {
+	case "check-snapshot", "forget-snapshot", "push-snapshot", "pull-snapshot":
+		// check, forget, push and pull don't affect snaps
+		// (this could also be written kind != save && kind != restore, but it's safer this way around)
 		return nil, nil
 	}
 	var snapshot snapshotSetup
@@ -166,6 +337,9 @@ type snapshotSetup struct {
 	Filename string        `json:"filename,omitempty"`
 	Current  snap.Revision `json:"current"`
 	Auto     bool          `json:"auto,omitempty"`
+	// Passphrase is set when a passphrase was given to encrypt the
+	// snapshot; the passphrase itself is never stored
+	Passphrase bool `json:"passphrase,omitempty"`
 }
 
 func filename(setID uint64, si *snap.Info) string {
@@ -180,17 +354,17 @@ func filename(setID uint64, si *snap.Info) string {
 
 // prepareSave does all the steps of doSave that require the state lock;
 // it has no real significance beyond making the lock handling simpler
-func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg map[string]interface{}, err error) {
+func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg map[string]interface{}, flags *backend.SaveFlags, err error) {
 	st := task.State()
 	st.Lock()
 	defer st.Unlock()
 
 	if err := task.Get("snapshot-setup", &snapshot); err != nil {
-		return nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
+		return nil, nil, nil, nil, taskGetErrMsg(task, err, "snapshot")
 	}
 	cur, err = snapstateCurrentInfo(st, snapshot.Snap)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 	// updating snapshot-setup with the filename, for use in undo
 	snapshot.Filename = filename(snapshot.SetID, cur)
@@ -198,29 +372,49 @@ func prepareSave(task *state.Task) (snapshot *snapshotSetup, cur *snap.Info, cfg
 
 	cfg, err = unmarshalSnapConfig(st, snapshot.Snap)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+	// secret values are encrypted with a key specific to this system,
+	// and are not to be found outside of it
+	if stripped, ok := config.WithoutSecrets(cfg).(map[string]interface{}); ok {
+		cfg = stripped
 	}
 
+	incremental, err := incrementalSnapshots(st)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	encryption, err := snapshotEncryption(st, snapshot)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if encryption != nil && incremental {
+		// deduplicating encrypted data would leak what is stored
+		logger.Noticef("Not saving snapshot #%d of %q incrementally as it is encrypted.", snapshot.SetID, snapshot.Snap)
+		incremental = false
+	}
+	flags = &backend.SaveFlags{Incremental: incremental, Encryption: encryption}
+
 	// this should be done last because of it modifies the state and the caller needs to undo this if other operation fails.
 	if snapshot.Auto {
 		expiration, err := AutomaticSnapshotExpiration(st)
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 		if err := saveExpiration(st, snapshot.SetID, time.Now().Add(expiration)); err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
 		}
 	}
 
-	return snapshot, cur, cfg, nil
+	return snapshot, cur, cfg, flags, nil
 }
 
 func doSave(task *state.Task, tomb *tomb.Tomb) error {
-	snapshot, cur, cfg, err := prepareSave(task)
+	snapshot, cur, cfg, flags, err := prepareSave(task)
 	if err != nil {
 		return err
 	}
-	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users)
+	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, flags)
 	if err != nil {
 		st := task.State()
 		st.Lock()
@@ -252,6 +446,15 @@ func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[strin
 	}
 	// note given the Open succeeded, caller needs to close it when done
 
+	if reader.Encryption != "" {
+		decryption, err := snapshotDecryption(st, snapshot.SetID)
+		if err != nil {
+			reader.Close()
+			return nil, nil, nil, fmt.Errorf("cannot decrypt snapshot: %v", err)
+		}
+		reader.SetDecryption(decryption)
+	}
+
 	return snapshot, oldCfg, reader, nil
 }
 
@@ -422,7 +625,14 @@ func doForget(task *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", snapshot.SetID, err)
 	}
 
-	return osRemove(snapshot.Filename)
+	if err := osRemove(snapshot.Filename); err != nil {
+		return err
+	}
+
+	// the data of incremental snapshots may still be used by others
+	pruneChunks(st)
+
+	return nil
 }
 
 func delayedCrossMgrInit() {
@@ -432,7 +642,7 @@ func delayedCrossMgrInit() {
 	snapstate.EstimateSnapshotSize = EstimateSnapshotSize
 }
 
-func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]interface{}, []string) (*client.Snapshot, error)) (restore func()) {
+func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]interface{}, []string, *backend.SaveFlags) (*client.Snapshot, error)) (restore func()) {
 	old := backendSave
 	backendSave = f
 	return func() {
@@ -28,6 +28,7 @@ import (
 	"sort"
 	"time"
 
+	"golang.org/x/crypto/openpgp/packet"
 	"gopkg.in/check.v1"
 	"gopkg.in/tomb.v2"
 
@@ -35,8 +36,10 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
@@ -54,6 +57,8 @@ func (snapshotSuite) TestManager(c *check.C) {
 	c.Check(kinds, check.DeepEquals, []string{
 		"check-snapshot",
 		"forget-snapshot",
+		"pull-snapshot",
+		"push-snapshot",
 		"restore-snapshot",
 		"save-snapshot",
 	})
@@ -87,6 +92,12 @@ func (snapshotSuite) TestEnsureForgetsSnapshots(c *check.C) {
 	})
 	defer restoreOsRemove()
 
+	pruned := 0
+	defer snapshotstate.MockBackendPruneChunks(func(context.Context) (int64, error) {
+		pruned++
+		return 0, nil
+	})()
+
 	restore := mockDummySnapshot(c)
 	defer restore()
 
@@ -113,6 +124,7 @@ func (snapshotSuite) TestEnsureForgetsSnapshots(c *check.C) {
 	c.Check(expirations, check.DeepEquals, map[uint64]interface{}{
 		2: map[string]interface{}{"expiry-time": "2037-02-12T12:50:00Z"}})
 	c.Check(removedSnapshot, check.Matches, ".*/foo.zip")
+	c.Check(pruned, check.Equals, 1)
 }
 
 func (snapshotSuite) TestEnsureForgetsSnapshotsRunsRegularly(c *check.C) {
@@ -248,6 +260,161 @@ func (s *snapshotSuite) TestEnsureForgetSnapshotsConflictWithExportSnapshot(c *c
 	s.testEnsureForgetSnapshotsConflict(c, "export-snapshot")
 }
 
+func (snapshotSuite) TestEnsureScheduledSnapshotsNoSchedule(c *check.C) {
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	var last time.Time
+	c.Check(st.Get("last-scheduled-snapshot", &last), check.Equals, state.ErrNoState)
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(snapshotstate.NextScheduledSnapshot(mgr).IsZero(), check.Equals, true)
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshotsFirstRun(c *check.C) {
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.schedule", "00:00-24:00")
+	tr.Commit()
+	st.Unlock()
+
+	before := time.Now()
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	// the schedule starts counting from now
+	var last time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.Before(before), check.Equals, false)
+	c.Check(snapshotstate.NextScheduledSnapshot(mgr).After(last), check.Equals, true)
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshots(c *check.C) {
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+			"b-snap": {Active: true},
+		}, nil
+	})()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.schedule", "00:00-24:00")
+	tr.Set("core", "snapshots.scheduled-snaps", "b-snap")
+	tr.Commit()
+	lastTime := time.Now().Add(-48 * time.Hour)
+	st.Set("last-scheduled-snapshot", lastTime)
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	st.Lock()
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "scheduled-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Take scheduled snapshot of snaps "b-snap"`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "save-snapshot")
+	c.Check(tasks[0].Summary(), check.Equals, `Save data of snap "b-snap" in snapshot set #1`)
+
+	sets, err := snapshotstate.ScheduledSnapshotSets(st)
+	c.Assert(err, check.IsNil)
+	c.Check(sets, check.HasLen, 1)
+	c.Check(sets[1].IsZero(), check.Equals, false)
+
+	var last time.Time
+	c.Assert(st.Get("last-scheduled-snapshot", &last), check.IsNil)
+	c.Check(last.After(lastTime), check.Equals, true)
+	st.Unlock()
+
+	// nothing happens while the snapshot is in flight
+	st.Lock()
+	st.Set("last-scheduled-snapshot", lastTime)
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 1)
+	st.Unlock()
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshotsRetention(c *check.C) {
+	dir := c.MkDir()
+	var files []*os.File
+	for _, name := range []string{"2_foo.zip", "3_foo.zip"} {
+		f, err := os.Create(filepath.Join(dir, name))
+		c.Assert(err, check.IsNil)
+		files = append(files, f)
+		defer f.Close()
+	}
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		// set #1 is gone already
+		for i, file := range files {
+			if err := f(&backend.Reader{
+				Snapshot: client.Snapshot{SetID: uint64(i + 2), Snap: "foo"},
+				File:     file,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})()
+	var removed []string
+	defer snapshotstate.MockOsRemove(func(fileName string) error {
+		removed = append(removed, filepath.Base(fileName))
+		return nil
+	})()
+	pruned := 0
+	defer snapshotstate.MockBackendPruneChunks(func(context.Context) (int64, error) {
+		pruned++
+		return 0, nil
+	})()
+
+	st := state.New(nil)
+	runner := state.NewTaskRunner(st)
+	mgr := snapshotstate.Manager(st, runner)
+
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.keep-last", 1)
+	tr.Commit()
+	now := time.Now()
+	for setID := uint64(1); setID <= 3; setID++ {
+		t := now.Add(time.Duration(setID-4) * 24 * time.Hour)
+		c.Assert(snapshotstate.SaveScheduledTime(st, setID, t), check.IsNil)
+	}
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(removed, check.DeepEquals, []string{"2_foo.zip"})
+	c.Check(pruned, check.Equals, 1)
+	sets, err := snapshotstate.ScheduledSnapshotSets(st)
+	c.Assert(err, check.IsNil)
+	c.Check(sets, check.HasLen, 1)
+	_, ok := sets[3]
+	c.Check(ok, check.Equals, true)
+	// no snapshots are scheduled
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
 func (snapshotSuite) TestFilename(c *check.C) {
 	si := &snap.Info{
 		SideInfo: snap.SideInfo{
@@ -278,7 +445,7 @@ func (snapshotSuite) TestDoSave(c *check.C) {
 		buf := json.RawMessage(`{"hello": "there"}`)
 		return &buf, nil
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
 		c.Check(id, check.Equals, uint64(42))
 		c.Check(si, check.DeepEquals, &snapInfo)
 		c.Check(cfg, check.DeepEquals, map[string]interface{}{"hello": "there"})
@@ -299,12 +466,152 @@ func (snapshotSuite) TestDoSave(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (snapshotSuite) TestDoSaveLeavesOutSecrets(c *check.C) {
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snap.Info{SideInfo: snap.SideInfo{RealName: "a-snap", Revision: snap.R(-1)}}, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
+		buf := json.RawMessage(`{"hello": "there", "token": {"$secret": "c2VjcmV0"}, "auth": {"user": "frank", "password": {"$secret": "c2VjcmV0"}}}`)
+		return &buf, nil
+	})()
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
+		c.Check(cfg, check.DeepEquals, map[string]interface{}{
+			"hello": "there",
+			"auth":  map[string]interface{}{"user": "frank"},
+		})
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id": 42,
+		"snap":   "a-snap",
+	})
+	st.Unlock()
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+}
+
+func (snapshotSuite) TestDoSaveIncremental(c *check.C) {
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snap.Info{SideInfo: snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)}, Version: "1.33"}, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
+		return nil, nil
+	})()
+	var saveFlags []*backend.SaveFlags
+	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]interface{}, _ []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
+		saveFlags = append(saveFlags, flags)
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id": 42,
+		"snap":   "a-snap",
+	})
+	st.Unlock()
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.incremental", true)
+	tr.Commit()
+	st.Unlock()
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+
+	c.Check(saveFlags, check.DeepEquals, []*backend.SaveFlags{
+		{Incremental: false},
+		{Incremental: true},
+	})
+}
+
+func (snapshotSuite) TestDoSaveEncrypted(c *check.C) {
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snap.Info{SideInfo: snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)}, Version: "1.33"}, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
+		return nil, nil
+	})()
+	var saveFlags []*backend.SaveFlags
+	defer snapshotstate.MockBackendSave(func(_ context.Context, _ uint64, _ *snap.Info, _ map[string]interface{}, _ []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
+		saveFlags = append(saveFlags, flags)
+		return nil, nil
+	})()
+	keys := []*packet.PublicKey{{KeyId: 1}}
+	defer snapshotstate.MockBackendParsePublicKeys(func(armored string) ([]*packet.PublicKey, error) {
+		c.Check(armored, check.Equals, "an armored key")
+		return keys, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id": 42,
+		"snap":   "a-snap",
+	})
+	// encryption wins over incremental snapshots
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.incremental", true)
+	tr.Set("core", "snapshots.encryption.public-key", "an armored key")
+	tr.Commit()
+	st.Unlock()
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+
+	// a passphrase given for the set wins over the public key
+	st.Lock()
+	snapshotstate.SetSecrets(st, 42, &snapshotstate.Secrets{Passphrase: "sekrit"})
+	st.Unlock()
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+
+	c.Check(saveFlags, check.DeepEquals, []*backend.SaveFlags{
+		{Encryption: &backend.Encryption{Recipients: keys}},
+		{Encryption: &backend.Encryption{Passphrase: []byte("sekrit")}},
+	})
+}
+
+func (snapshotSuite) TestDoSaveFailsWithLostPassphrase(c *check.C) {
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snap.Info{SideInfo: snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)}, Version: "1.33"}, nil
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
+		return nil, nil
+	})()
+	defer snapshotstate.MockBackendSave(func(context.Context, uint64, *snap.Info, map[string]interface{}, []string, *backend.SaveFlags) (*client.Snapshot, error) {
+		c.Fatal("snapshot should not be saved")
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"set-id": 42,
+		"snap":   "a-snap",
+	})
+	snapshotstate.SetSecrets(st, 42, &snapshotstate.Secrets{Passphrase: "sekrit"})
+	var snapshot map[string]interface{}
+	c.Assert(task.Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot["passphrase"], check.Equals, true)
+	// as happens when snapd restarts
+	st.Cache("snapshot-secrets", nil)
+	st.Unlock()
+
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, `cannot encrypt snapshot #42 of "a-snap": passphrase is no longer available`)
+}
+
 func (snapshotSuite) TestDoSaveFailsWithNoSnap(c *check.C) {
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
 		return nil, errors.New("bzzt")
 	})()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -331,7 +638,7 @@ func (snapshotSuite) TestDoSaveFailsWithNoSnapshot(c *check.C) {
 	}
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -355,7 +662,7 @@ func (snapshotSuite) TestDoSaveFailsBackendError(c *check.C) {
 	}
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, errors.New("bzzt")
 	})()
 
@@ -384,7 +691,7 @@ func (snapshotSuite) TestDoSaveFailsConfigError(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
 		return nil, errors.New("bzzt")
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -415,7 +722,7 @@ func (snapshotSuite) TestDoSaveFailsBadConfig(c *check.C) {
 		buf := json.RawMessage(`"hello-there"`)
 		return &buf, nil
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -448,7 +755,7 @@ func (snapshotSuite) TestDoSaveFailureRemovesStateEntry(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(_ *state.State, snapname string) (*json.RawMessage, error) {
 		return nil, nil
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]interface{}, usernames []string, flags *backend.SaveFlags) (*client.Snapshot, error) {
 		var expirations map[uint64]interface{}
 		st.Lock()
 		defer st.Unlock()
@@ -532,6 +839,10 @@ func (rs *readerSuite) SetUpTest(c *check.C) {
 		snapshotstate.MockBackendCleanup(func(*backend.RestoreState) {
 			rs.calls = append(rs.calls, "cleanup")
 		}),
+		snapshotstate.MockBackendPruneChunks(func(context.Context) (int64, error) {
+			rs.calls = append(rs.calls, "prune chunks")
+			return 0, nil
+		}),
 	}
 }
 
@@ -541,6 +852,37 @@ func (rs *readerSuite) TearDownTest(c *check.C) {
 	}
 }
 
+func (rs *readerSuite) TestDoRestoreEncrypted(c *check.C) {
+	defer snapshotstate.MockBackendOpen(func(string, uint64) (*backend.Reader, error) {
+		rs.calls = append(rs.calls, "open")
+		return &backend.Reader{
+			Snapshot: client.Snapshot{Encryption: "public-key"},
+		}, nil
+	})()
+	parseErr := errors.New("bzzt")
+	defer snapshotstate.MockBackendParsePrivateKeys(func(armored string, passphrase []byte) ([]*packet.PrivateKey, error) {
+		rs.calls = append(rs.calls, "parse private key")
+		c.Check(armored, check.Equals, "a private key")
+		c.Check(passphrase, check.DeepEquals, []byte("sekrit"))
+		return nil, parseErr
+	})()
+
+	st := rs.task.State()
+	st.Lock()
+	snapshotstate.SetSecrets(st, 0, &snapshotstate.Secrets{Passphrase: "sekrit", PrivateKey: "a private key"})
+	st.Unlock()
+
+	err := snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, "cannot decrypt snapshot: bzzt")
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open", "parse private key"})
+
+	rs.calls = nil
+	parseErr = nil
+	err = snapshotstate.DoRestore(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"get config", "open", "parse private key", "restore", "set config"})
+}
+
 func (rs *readerSuite) TestDoRestore(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(_ *state.State, snapname string) (*json.RawMessage, error) {
 		rs.calls = append(rs.calls, "get config")
@@ -788,7 +1130,17 @@ func (rs *readerSuite) TestDoRemove(c *check.C) {
 	})()
 	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
 	c.Assert(err, check.IsNil)
-	c.Check(rs.calls, check.DeepEquals, []string{"remove"})
+	c.Check(rs.calls, check.DeepEquals, []string{"remove", "prune chunks"})
+}
+
+func (rs *readerSuite) TestDoRemovePruneChunksErrorIsIgnored(c *check.C) {
+	defer snapshotstate.MockBackendPruneChunks(func(context.Context) (int64, error) {
+		rs.calls = append(rs.calls, "prune chunks")
+		return 0, errors.New("bzzt")
+	})()
+	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
+	c.Assert(err, check.IsNil)
+	c.Check(rs.calls, check.DeepEquals, []string{"remove", "prune chunks"})
 }
 
 func (rs *readerSuite) TestDoForgetRemovesAutomaticSnapshotExpiry(c *check.C) {
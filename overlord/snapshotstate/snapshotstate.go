@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/snapcore/snapd/client"
@@ -36,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 var (
@@ -45,11 +47,21 @@ var (
 	backendEstimateSnapshotSize      = backend.EstimateSnapshotSize
 	backendList                      = backend.List
 	backendNewSnapshotExport         = backend.NewSnapshotExport
+	backendParsePublicKeys           = backend.ParsePublicKeys
+	backendParsePrivateKeys          = backend.ParsePrivateKeys
 
 	// Default expiration time for automatic snapshots, if not set by the user
 	defaultAutomaticSnapshotExpiration = time.Hour * 24 * 31
+
+	// Default number of scheduled snapshots to keep, if no retention rule
+	// is set by the user
+	defaultScheduledSnapshotsKeepLast = 7
 )
 
+// scheduledSnapshotChangeKind is the kind of the changes taking scheduled
+// snapshots.
+const scheduledSnapshotChangeKind = "scheduled-snapshot"
+
 type snapshotState struct {
 	ExpiryTime time.Time `json:"expiry-time"`
 }
@@ -144,6 +156,242 @@ func AutomaticSnapshotExpiration(st *state.State) (time.Duration, error) {
 	return defaultAutomaticSnapshotExpiration, nil
 }
 
+// incrementalSnapshots returns whether new snapshots should be incremental,
+// i.e. store only the data that was not already stored by an older snapshot.
+func incrementalSnapshots(st *state.State) (bool, error) {
+	var incremental interface{}
+	tr := config.NewTransaction(st)
+	if err := tr.GetMaybe("core", "snapshots.incremental", &incremental); err != nil {
+		return false, err
+	}
+	switch incremental {
+	case true, "true":
+		return true, nil
+	case false, "false", nil, "":
+		return false, nil
+	}
+	return false, fmt.Errorf("snapshots.incremental can only be set to 'true' or 'false', got %q", incremental)
+}
+
+// snapshotEncryption returns how the given snapshot is to be encrypted: with
+// the passphrase given for its set, if any, or else with the public keys set
+// in the snapshots.encryption.public-key option, if any. It returns nil if
+// the snapshot is not to be encrypted.
+func snapshotEncryption(st *state.State, snapshot *snapshotSetup) (*backend.Encryption, error) {
+	if secrets := snapshotSecrets(st, snapshot.SetID); secrets != nil && secrets.Passphrase != "" {
+		return &backend.Encryption{Passphrase: []byte(secrets.Passphrase)}, nil
+	}
+	if snapshot.Passphrase {
+		// the passphrase is only kept in memory, and was lost (e.g.
+		// snapd restarted); do not save the snapshot unencrypted
+		return nil, fmt.Errorf("cannot encrypt snapshot #%d of %q: passphrase is no longer available", snapshot.SetID, snapshot.Snap)
+	}
+
+	var armored string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "snapshots.encryption.public-key", &armored); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if armored == "" {
+		return nil, nil
+	}
+	keys, err := backendParsePublicKeys(armored)
+	if err != nil {
+		return nil, fmt.Errorf("cannot use snapshots.encryption.public-key: %v", err)
+	}
+	return &backend.Encryption{Recipients: keys}, nil
+}
+
+// snapshotDecryption returns what can decrypt the snapshots of the given
+// set, from the secrets given for it. It returns nil if there are none.
+func snapshotDecryption(st *state.State, setID uint64) (*backend.Decryption, error) {
+	secrets := snapshotSecrets(st, setID)
+	if secrets == nil {
+		return nil, nil
+	}
+	decryption := &backend.Decryption{Passphrase: []byte(secrets.Passphrase)}
+	if secrets.PrivateKey != "" {
+		keys, err := backendParsePrivateKeys(secrets.PrivateKey, decryption.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		decryption.PrivateKeys = keys
+	}
+	return decryption, nil
+}
+
+// scheduledSnapshotSchedule returns the parsed snapshots.schedule option,
+// together with its string form. An empty schedule means no snapshots are
+// scheduled.
+func scheduledSnapshotSchedule(st *state.State) (schedule []*timeutil.Schedule, scheduleStr string, err error) {
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "snapshots.schedule", &scheduleStr); err != nil && !config.IsNoOption(err) {
+		return nil, "", err
+	}
+	if scheduleStr == "" {
+		return nil, "", nil
+	}
+	schedule, err = timeutil.ParseSchedule(scheduleStr)
+	if err != nil {
+		return nil, "", fmt.Errorf("snapshots.schedule cannot be parsed: %v", err)
+	}
+	return schedule, scheduleStr, nil
+}
+
+// scheduledSnapshotSnaps returns the snaps to include in scheduled snapshots,
+// i.e. the active snaps listed in snapshots.scheduled-snaps, or all active
+// snaps if the option is not set.
+func scheduledSnapshotSnaps(st *state.State) ([]string, error) {
+	var snapsStr string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "snapshots.scheduled-snaps", &snapsStr); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	active, err := allActiveSnapNames(st)
+	if err != nil {
+		return nil, err
+	}
+	if snapsStr == "" {
+		return active, nil
+	}
+
+	var names []string
+	for _, name := range strutil.CommaSeparatedList(snapsStr) {
+		if !strutil.SortedListContains(active, name) {
+			logger.Noticef("Not including snap %q in scheduled snapshot: snap is not installed or not active.", name)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// snapshotRetention holds the rules deciding which scheduled snapshot sets
+// are kept. A set is kept if any of the rules keeps it.
+type snapshotRetention struct {
+	// keep the given number of most recent sets
+	last int
+	// keep the most recent set of each of the given number of most recent
+	// days with sets
+	daily int
+	// keep the most recent set of each of the given number of most recent
+	// weeks with sets
+	weekly int
+}
+
+// scheduledSnapshotsRetention returns the retention rules for scheduled
+// snapshots from the snapshots.keep-{last,daily,weekly} options.
+func scheduledSnapshotsRetention(st *state.State) (*snapshotRetention, error) {
+	var retention snapshotRetention
+	tr := config.NewTransaction(st)
+	for opt, keep := range map[string]*int{
+		"snapshots.keep-last":   &retention.last,
+		"snapshots.keep-daily":  &retention.daily,
+		"snapshots.keep-weekly": &retention.weekly,
+	} {
+		// the value can be a number or a string, depending on how it
+		// was set
+		var v interface{}
+		if err := tr.GetMaybe("core", opt, &v); err != nil {
+			return nil, err
+		}
+		if v == nil || v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(fmt.Sprintf("%v", v))
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a non-negative number, not %v", opt, v)
+		}
+		*keep = n
+	}
+	if retention.last <= 0 && retention.daily <= 0 && retention.weekly <= 0 {
+		retention.last = defaultScheduledSnapshotsKeepLast
+	}
+	return &retention, nil
+}
+
+// expired returns the sets that none of the retention rules keeps, out of
+// the given scheduled snapshot sets and the times they were taken.
+func (r *snapshotRetention) expired(sets map[uint64]time.Time) map[uint64]bool {
+	setIDs := make([]uint64, 0, len(sets))
+	for setID := range sets {
+		setIDs = append(setIDs, setID)
+	}
+	// most recent first
+	sort.Slice(setIDs, func(i, j int) bool {
+		ti, tj := sets[setIDs[i]], sets[setIDs[j]]
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return setIDs[i] > setIDs[j]
+	})
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	expired := make(map[uint64]bool)
+	for i, setID := range setIDs {
+		t := sets[setID].Local()
+		keep := i < r.last
+
+		day := t.Format("2006-01-02")
+		if !days[day] && len(days) < r.daily {
+			days[day] = true
+			keep = true
+		}
+
+		year, week := t.ISOWeek()
+		weekStr := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[weekStr] && len(weeks) < r.weekly {
+			weeks[weekStr] = true
+			keep = true
+		}
+
+		if !keep {
+			expired[setID] = true
+		}
+	}
+	return expired
+}
+
+// saveScheduledTime records in the state that the given snapshot set was
+// taken as scheduled at the given time, which makes it subject to the
+// retention rules of scheduled snapshots.
+// The state needs to be locked by the caller.
+func saveScheduledTime(st *state.State, setID uint64, t time.Time) error {
+	var sets map[uint64]time.Time
+	if err := st.Get("scheduled-snapshots", &sets); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if sets == nil {
+		sets = make(map[uint64]time.Time)
+	}
+	sets[setID] = t
+	st.Set("scheduled-snapshots", sets)
+	return nil
+}
+
+// scheduledSnapshotSets returns the scheduled snapshot sets from the state,
+// and the times they were taken.
+// The state needs to be locked by the caller.
+func scheduledSnapshotSets(st *state.State) (map[uint64]time.Time, error) {
+	var sets map[uint64]time.Time
+	if err := st.Get("scheduled-snapshots", &sets); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// scheduledSnapshotInFlight returns whether a scheduled snapshot is being
+// taken.
+func scheduledSnapshotInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == scheduledSnapshotChangeKind && !chg.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
 // saveExpiration saves expiration date of the given snapshot set, in the state.
 // The state needs to be locked by the caller.
 func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
@@ -169,20 +417,22 @@ func saveExpiration(st *state.State, setID uint64, expiryTime time.Time) error {
 
 // removeSnapshotState removes given set IDs from the state.
 func removeSnapshotState(st *state.State, setIDs ...uint64) error {
-	var snapshots map[uint64]*json.RawMessage
-	err := st.Get("snapshots", &snapshots)
-	if err != nil {
-		if err == state.ErrNoState {
-			return nil
+	for _, key := range []string{"snapshots", "scheduled-snapshots"} {
+		var snapshots map[uint64]*json.RawMessage
+		err := st.Get(key, &snapshots)
+		if err != nil {
+			if err == state.ErrNoState {
+				continue
+			}
+			return err
 		}
-		return err
-	}
 
-	for _, setID := range setIDs {
-		delete(snapshots, setID)
-	}
+		for _, setID := range setIDs {
+			delete(snapshots, setID)
+		}
 
-	st.Set("snapshots", snapshots)
+		st.Set(key, snapshots)
+	}
 	return nil
 }
 
@@ -221,10 +471,11 @@ func (summaries snapshotSnapSummaries) snapNames() []string {
 }
 
 type snapshotSnapSummary struct {
-	snap     string
-	snapID   string
-	filename string
-	epoch    snap.Epoch
+	snap      string
+	snapID    string
+	filename  string
+	epoch     snap.Epoch
+	encrypted bool
 }
 
 // snapSummariesInSnapshotSet goes looking for the requested snaps in the
@@ -237,10 +488,11 @@ func snapSummariesInSnapshotSet(setID uint64, requested []string) (summaries sna
 			found = true
 			if len(requested) == 0 || strutil.SortedListContains(requested, r.Snap) {
 				summaries = append(summaries, &snapshotSnapSummary{
-					filename: r.Name(),
-					snap:     r.Snap,
-					snapID:   r.SnapID,
-					epoch:    r.Epoch,
+					filename:  r.Name(),
+					snap:      r.Snap,
+					snapID:    r.SnapID,
+					epoch:     r.Epoch,
+					encrypted: r.Encryption != "",
 				})
 			}
 		}
@@ -313,6 +565,11 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 		return nil, err
 	}
 
+	scheduled, err := scheduledSnapshotSets(st)
+	if err != nil {
+		return nil, err
+	}
+
 	// decorate all snapshots with "auto" flag if we have expiry time set for them.
 	for _, sset := range sets {
 		// at the moment we only keep records with expiry time so checking non-zero
@@ -323,6 +580,11 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 				snapshot.Auto = true
 			}
 		}
+		if _, ok := scheduled[sset.ID]; ok {
+			for _, snapshot := range sset.Snapshots {
+				snapshot.Scheduled = true
+			}
+		}
 	}
 
 	return sets, nil
@@ -360,7 +622,9 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 			// the existing one and reset its expiry time.
 			// XXX: at the moment expiry-time is the only attribute so we can
 			// just remove the record. If we ever add more attributes this needs
-			// to reset expiry-time only.
+			// to reset expiry-time only. Note this also takes a scheduled
+			// set out of the retention rules, which is what we want as the
+			// user explicitly asked for this data.
 			if err := removeSnapshotState(st, dupErr.SetID); err != nil {
 				return 0, nil, err
 			}
@@ -416,6 +680,17 @@ func Save(st *state.State, instanceNames []string, users []string) (setID uint64
 		ts.AddTask(task)
 	}
 
+	autoPush, err := autoPushSnapshots(st)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if autoPush && len(instanceNames) > 0 {
+		// push the set once it's complete
+		push := newPushTask(st, setID, &remoteSetup{Name: RemoteName(setID), Auto: true})
+		push.WaitAll(ts)
+		ts.AddTask(push)
+	}
+
 	return setID, instanceNames, ts, nil
 }
 
@@ -469,6 +744,14 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 		return nil, nil, err
 	}
 
+	if snapshotSecrets(st, setID) == nil {
+		for _, summary := range summaries {
+			if summary.encrypted {
+				return nil, nil, client.ErrSnapshotEncrypted
+			}
+		}
+	}
+
 	ts = state.NewTaskSet()
 
 	for _, summary := range summaries {
@@ -541,9 +824,9 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 // Forget creates a taskset for deletinig a snapshot.
 // Note that the state must be locked by the caller.
 func Forget(st *state.State, setID uint64, snapNames []string) (snapsFound []string, ts *state.TaskSet, err error) {
-	// forget needs to conflict with check, restore, import and export.
+	// forget needs to conflict with check, restore, import, export and push.
 	if err := checkSnapshotConflict(st, setID, "export-snapshot",
-		"check-snapshot", "restore-snapshot"); err != nil {
+		"check-snapshot", "restore-snapshot", "push-snapshot"); err != nil {
 		return nil, nil, err
 	}
 
@@ -597,6 +880,90 @@ func UnsetSnapshotOpInProgress(st *state.State, setID uint64) string {
 	return op
 }
 
+// Secrets holds the passphrase or private key to use for saving or
+// restoring encrypted snapshots.
+type Secrets struct {
+	// Passphrase encrypts the snapshots being saved, or decrypts the
+	// ones being restored, or unlocks PrivateKey
+	Passphrase string
+	// PrivateKey is an ASCII-armored OpenPGP private key to decrypt the
+	// snapshots being restored
+	PrivateKey string
+}
+
+// SetSecrets sets the secrets to use for saving or restoring the snapshot
+// set with the given ID. Secrets are only ever kept in memory, never in the
+// state, and are dropped once the set is no longer being saved or restored.
+// Pending saves of the set remember that a passphrase was given, so that
+// they fail instead of saving unencrypted snapshots if it is lost.
+// The state must be locked by the caller.
+func SetSecrets(st *state.State, setID uint64, secrets *Secrets) {
+	if secrets.Passphrase != "" {
+		for _, task := range st.Tasks() {
+			if task.Kind() != "save-snapshot" || task.Status().Ready() {
+				continue
+			}
+			var snapshot snapshotSetup
+			if err := task.Get("snapshot-setup", &snapshot); err != nil || snapshot.SetID != setID {
+				continue
+			}
+			snapshot.Passphrase = true
+			task.Set("snapshot-setup", &snapshot)
+		}
+	}
+
+	var snapshotSecrets map[uint64]*Secrets
+	if val := st.Cached("snapshot-secrets"); val != nil {
+		snapshotSecrets, _ = val.(map[uint64]*Secrets)
+	} else {
+		snapshotSecrets = make(map[uint64]*Secrets)
+	}
+	snapshotSecrets[setID] = secrets
+	st.Cache("snapshot-secrets", snapshotSecrets)
+}
+
+func snapshotSecrets(st *state.State, setID uint64) *Secrets {
+	if val := st.Cached("snapshot-secrets"); val != nil {
+		snapshotSecrets, _ := val.(map[uint64]*Secrets)
+		return snapshotSecrets[setID]
+	}
+	return nil
+}
+
+// dropUnusedSecrets forgets the secrets of the snapshot sets that are no
+// longer being saved or restored.
+func dropUnusedSecrets(st *state.State) {
+	val := st.Cached("snapshot-secrets")
+	if val == nil {
+		return
+	}
+	snapshotSecrets, _ := val.(map[uint64]*Secrets)
+
+	inUse := make(map[uint64]bool, len(snapshotSecrets))
+	for _, task := range st.Tasks() {
+		if task.Kind() != "save-snapshot" && task.Kind() != "restore-snapshot" {
+			continue
+		}
+		if chg := task.Change(); chg != nil && chg.Status().Ready() {
+			continue
+		}
+		var snapshot snapshotSetup
+		if err := task.Get("snapshot-setup", &snapshot); err != nil {
+			continue
+		}
+		inUse[snapshot.SetID] = true
+	}
+
+	for setID := range snapshotSecrets {
+		if !inUse[setID] {
+			delete(snapshotSecrets, setID)
+		}
+	}
+	if len(snapshotSecrets) == 0 {
+		st.Cache("snapshot-secrets", nil)
+	}
+}
+
 // Export exports a given snapshot ID
 // Note that the state must be locked by the caller.
 func Export(ctx context.Context, st *state.State, setID uint64) (se *backend.SnapshotExport, err error) {
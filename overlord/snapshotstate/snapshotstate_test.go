@@ -1139,6 +1139,67 @@ func (snapshotSuite) TestRestore(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestRestoreEncryptedNeedsSecrets(c *check.C) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+	fakeIter := func(_ context.Context, f func(*backend.Reader) error) error {
+		c.Assert(f(&backend.Reader{
+			Snapshot: client.Snapshot{SetID: 42, Snap: "a-snap", Encryption: "passphrase"},
+			File:     shotfile,
+		}), check.IsNil)
+
+		return nil
+	}
+	defer snapshotstate.MockBackendIter(fakeIter)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, err = snapshotstate.Restore(st, 42, nil, nil)
+	c.Assert(err, check.Equals, client.ErrSnapshotEncrypted)
+
+	snapshotstate.SetSecrets(st, 42, &snapshotstate.Secrets{Passphrase: "sekrit"})
+	found, _, err := snapshotstate.Restore(st, 42, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(found, check.DeepEquals, []string{"a-snap"})
+}
+
+func (snapshotSuite) TestDropUnusedSecrets(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	for _, setID := range []uint64{1, 2, 3} {
+		snapshotstate.SetSecrets(st, setID, &snapshotstate.Secrets{Passphrase: "sekrit"})
+	}
+
+	chg := st.NewChange("restore-snapshot", "...")
+	restoreTask := st.NewTask("restore-snapshot", "...")
+	restoreTask.Set("snapshot-setup", map[string]interface{}{"set-id": 1})
+	chg.AddTask(restoreTask)
+	// secrets are not kept for checks
+	checkTask := st.NewTask("check-snapshot", "...")
+	checkTask.Set("snapshot-setup", map[string]interface{}{"set-id": 2})
+	chg.AddTask(checkTask)
+	doneChg := st.NewChange("save-snapshot", "...")
+	saveTask := st.NewTask("save-snapshot", "...")
+	saveTask.Set("snapshot-setup", map[string]interface{}{"set-id": 3})
+	saveTask.SetStatus(state.DoneStatus)
+	doneChg.AddTask(saveTask)
+
+	snapshotstate.DropUnusedSecrets(st)
+	c.Check(snapshotstate.SnapshotSecrets(st, 1), check.DeepEquals, &snapshotstate.Secrets{Passphrase: "sekrit"})
+	c.Check(snapshotstate.SnapshotSecrets(st, 2), check.IsNil)
+	c.Check(snapshotstate.SnapshotSecrets(st, 3), check.IsNil)
+
+	restoreTask.SetStatus(state.DoneStatus)
+	checkTask.SetStatus(state.DoneStatus)
+	snapshotstate.DropUnusedSecrets(st)
+	c.Check(snapshotstate.SnapshotSecrets(st, 1), check.IsNil)
+}
+
 func (snapshotSuite) TestRestoreIntegration(c *check.C) {
 	if os.Geteuid() == 0 {
 		c.Skip("this test cannot run as root (runuser will fail)")
@@ -1188,7 +1249,7 @@ func (snapshotSuite) TestRestoreIntegration(c *check.C) {
 			c.Assert(os.MkdirAll(filepath.Join(home, "snap", name, "common", "common-"+name), 0755), check.IsNil)
 		}
 
-		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user", "b-user"})
+		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user", "b-user"}, nil)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -1265,7 +1326,7 @@ func (snapshotSuite) TestRestoreIntegrationFails(c *check.C) {
 		c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", name, fmt.Sprint(i+1), "canary-"+name), 0755), check.IsNil)
 		c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", name, "common", "common-"+name), 0755), check.IsNil)
 
-		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user"})
+		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user"}, nil)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -1609,6 +1670,136 @@ func (snapshotSuite) TestExpiredSnapshotSets(c *check.C) {
 	c.Check(expired, check.DeepEquals, map[uint64]bool{13: true})
 }
 
+func (snapshotSuite) TestScheduledSnapshotSets(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	sets, err := snapshotstate.ScheduledSnapshotSets(st)
+	c.Assert(err, check.IsNil)
+	c.Check(sets, check.HasLen, 0)
+
+	t1 := time.Date(2021, 3, 1, 2, 0, 0, 0, time.UTC)
+	t2 := time.Date(2021, 3, 2, 2, 0, 0, 0, time.UTC)
+	c.Assert(snapshotstate.SaveScheduledTime(st, 12, t1), check.IsNil)
+	c.Assert(snapshotstate.SaveScheduledTime(st, 13, t2), check.IsNil)
+	c.Assert(snapshotstate.SaveExpiration(st, 14, t2), check.IsNil)
+
+	sets, err = snapshotstate.ScheduledSnapshotSets(st)
+	c.Assert(err, check.IsNil)
+	c.Check(sets, check.HasLen, 2)
+	c.Check(sets[12].Equal(t1), check.Equals, true)
+	c.Check(sets[13].Equal(t2), check.Equals, true)
+
+	// scheduled sets are not expired sets
+	expired, err := snapshotstate.ExpiredSnapshotSets(st, t2.Add(time.Hour))
+	c.Assert(err, check.IsNil)
+	c.Check(expired, check.DeepEquals, map[uint64]bool{14: true})
+
+	c.Assert(snapshotstate.RemoveSnapshotState(st, 12, 14), check.IsNil)
+	sets, err = snapshotstate.ScheduledSnapshotSets(st)
+	c.Assert(err, check.IsNil)
+	c.Check(sets, check.HasLen, 1)
+	c.Check(sets[13].Equal(t2), check.Equals, true)
+	var snapshots map[uint64]interface{}
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots, check.HasLen, 0)
+}
+
+func (snapshotSuite) TestScheduledSnapshotsRetention(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	// keep the last 7 by default
+	last, daily, weekly, err := snapshotstate.ScheduledSnapshotsRetention(st)
+	c.Assert(err, check.IsNil)
+	c.Check([]int{last, daily, weekly}, check.DeepEquals, []int{7, 0, 0})
+
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.keep-daily", "5")
+	tr.Set("core", "snapshots.keep-weekly", 3)
+	tr.Commit()
+
+	last, daily, weekly, err = snapshotstate.ScheduledSnapshotsRetention(st)
+	c.Assert(err, check.IsNil)
+	c.Check([]int{last, daily, weekly}, check.DeepEquals, []int{0, 5, 3})
+
+	tr = config.NewTransaction(st)
+	tr.Set("core", "snapshots.keep-last", "many")
+	tr.Commit()
+
+	_, _, _, err = snapshotstate.ScheduledSnapshotsRetention(st)
+	c.Assert(err, check.ErrorMatches, `snapshots.keep-last must be a non-negative number, not many`)
+}
+
+func (snapshotSuite) TestExpiredByRetention(c *check.C) {
+	day := func(d, h int) time.Time {
+		return time.Date(2021, 3, d, h, 0, 0, 0, time.Local)
+	}
+	// 2021-03-01 is a monday
+	sets := map[uint64]time.Time{
+		1: day(1, 2),
+		2: day(1, 14),
+		3: day(2, 2),
+		4: day(3, 2),
+		5: day(8, 2),
+		6: day(9, 2),
+		7: day(15, 2),
+		8: day(15, 14),
+	}
+
+	for _, tc := range []struct {
+		last, daily, weekly int
+		kept                []uint64
+	}{
+		{last: 3, kept: []uint64{6, 7, 8}},
+		{last: 10, kept: []uint64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{daily: 3, kept: []uint64{5, 6, 8}},
+		{weekly: 2, kept: []uint64{6, 8}},
+		{weekly: 5, kept: []uint64{4, 6, 8}},
+		{last: 1, daily: 2, weekly: 3, kept: []uint64{4, 6, 8}},
+		{last: 2, daily: 1, weekly: 1, kept: []uint64{7, 8}},
+	} {
+		comm := check.Commentf("%+v", tc)
+		expected := make(map[uint64]bool)
+		for setID := range sets {
+			expected[setID] = true
+		}
+		for _, setID := range tc.kept {
+			delete(expected, setID)
+		}
+		c.Check(snapshotstate.ExpiredByRetention(tc.last, tc.daily, tc.weekly, sets), check.DeepEquals, expected, comm)
+	}
+}
+
+func (snapshotSuite) TestScheduledSnapshotSnaps(c *check.C) {
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+			"b-snap": {},
+			"c-snap": {Active: true},
+		}, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	// all active snaps by default
+	names, err := snapshotstate.ScheduledSnapshotSnaps(st)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"a-snap", "c-snap"})
+
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.scheduled-snaps", "c-snap,b-snap,d-snap")
+	tr.Commit()
+
+	names, err = snapshotstate.ScheduledSnapshotSnaps(st)
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"c-snap"})
+}
+
 func (snapshotSuite) TestAutomaticSnapshotDisabled(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
@@ -1759,6 +1950,31 @@ func (snapshotSuite) TestListSetsAutoFlag(c *check.C) {
 	}
 }
 
+func (snapshotSuite) TestListSetsScheduledFlag(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	c.Assert(snapshotstate.SaveScheduledTime(st, 2, time.Now()), check.IsNil)
+
+	restore := snapshotstate.MockBackendList(func(ctx context.Context, setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+		return []client.SnapshotSet{
+			{ID: 1, Snapshots: []*client.Snapshot{{Snap: "foo", SetID: 1}}},
+			{ID: 2, Snapshots: []*client.Snapshot{{Snap: "foo", SetID: 2}, {Snap: "bar", SetID: 2}}},
+		}, nil
+	})
+	defer restore()
+
+	sets, err := snapshotstate.List(context.TODO(), st, 0, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(sets, check.HasLen, 2)
+	c.Check(sets[0].Snapshots[0].Scheduled, check.Equals, false)
+	for _, snapshot := range sets[1].Snapshots {
+		c.Check(snapshot.Scheduled, check.Equals, true)
+		c.Check(snapshot.Auto, check.Equals, false)
+	}
+}
+
 func (snapshotSuite) TestImportSnapshotHappy(c *check.C) {
 	st := state.New(nil)
 
@@ -289,6 +289,16 @@ func (m *autoRefresh) Ensure() error {
 		m.nextRefresh = time.Time{}
 		return nil
 	}
+	// also attempt refreshes when refresh windows open, so that the snaps
+	// restricted to them get refreshed
+	windows, err := RefreshWindows(m.state)
+	if err != nil {
+		return err
+	}
+	for _, w := range windows {
+		refreshSchedule = append(refreshSchedule, w.schedule...)
+		refreshScheduleStr += fmt.Sprintf(";%s=%s", w.Name, w.Timer)
+	}
 	// we already have a refresh time, check if we got a new config
 	if !m.nextRefresh.IsZero() {
 		if m.lastRefreshSchedule != refreshScheduleStr {
@@ -312,6 +322,12 @@ func (m *autoRefresh) Ensure() error {
 			delta := timeutil.Next(refreshSchedule, lastRefresh, maxPostponement)
 			now = time.Now()
 			m.nextRefresh = now.Add(delta)
+			// ask the refresh coordinator again when it said to
+			if rc := refreshCoordinationSince(m.state, m.lastRefreshAttempt); rc != nil {
+				if retry := rc.retryTime(); retry.After(now) && retry.Before(m.nextRefresh) {
+					m.nextRefresh = retry
+				}
+			}
 		} else {
 			// make sure either seed-time or last-refresh
 			// are set for hold code below
@@ -534,7 +550,12 @@ func (m *autoRefresh) launchAutoRefresh(refreshSchedule []*timeutil.Schedule) er
 		chg.AddAll(ts)
 	}
 	chg.Set("snap-names", updated)
-	chg.Set("api-data", map[string]interface{}{"snap-names": updated})
+	apiData := map[string]interface{}{"snap-names": updated}
+	if rc := refreshCoordinationSince(m.state, m.lastRefreshAttempt); rc != nil {
+		chg.Set("refresh-coordination", rc)
+		apiData["refresh-coordination"] = rc.Snaps
+	}
+	chg.Set("api-data", apiData)
 	state.TagTimingsWithChange(perfTimings, chg)
 
 	return nil
@@ -976,3 +976,65 @@ func (s *autoRefreshTestSuite) TestInhibitRefreshRefreshesWhenOverdue(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(notificationCount, Equals, 1)
 }
+
+func (s *autoRefreshTestSuite) TestRefreshWindowsScheduleRefreshes(c *C) {
+	now := time.Now()
+	clockAt := func(t time.Time) string {
+		return fmt.Sprintf("%02d:%02d", t.Hour(), t.Minute())
+	}
+
+	s.state.Lock()
+	s.state.Set("last-refresh", now)
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.timer", clockAt(now.Add(3*time.Hour)))
+	tr.Set("core", "refresh.windows.db.timer", clockAt(now.Add(time.Hour)))
+	tr.Set("core", "refresh.windows.db.snaps", "some-snap")
+	tr.Commit()
+	s.state.Unlock()
+
+	// the next refresh happens when the refresh window opens
+	af := snapstate.NewAutoRefresh(s.state)
+	err := af.Ensure()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, HasLen, 0)
+	c.Check(af.NextRefresh().Before(now.Add(61*time.Minute)), Equals, true)
+	c.Check(af.NextRefresh().After(now.Add(58*time.Minute)), Equals, true)
+
+	// dropping the window resets the next refresh to the refresh.timer one
+	s.state.Lock()
+	tr = config.NewTransaction(s.state)
+	tr.Set("core", "refresh.windows.db", nil)
+	tr.Commit()
+	s.state.Unlock()
+
+	err = af.Ensure()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, HasLen, 0)
+	c.Check(af.NextRefresh().After(now.Add(178*time.Minute)), Equals, true)
+}
+
+func (s *autoRefreshTestSuite) TestRefreshCoordinatorRetryTime(c *C) {
+	now := time.Now()
+
+	s.state.Lock()
+	s.state.Set("last-refresh", now)
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.timer", "sun5,23:00")
+	tr.Set("core", "refresh.coordinator", "http://coordinator.example.com")
+	tr.Commit()
+	s.state.Set("refresh-coordination", &snapstate.RefreshCoordination{
+		URL:  "http://coordinator.example.com",
+		Time: now,
+		Snaps: map[string]*snapstate.CoordinatorDecision{
+			"some-snap": {Action: "delay", Until: now.Add(10 * time.Minute)},
+		},
+	})
+	s.state.Unlock()
+
+	// the coordinator is asked again when it said to
+	af := snapstate.NewAutoRefresh(s.state)
+	err := af.Ensure()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, HasLen, 0)
+	c.Check(af.NextRefresh().Equal(now.Add(10*time.Minute)), Equals, true)
+}
@@ -312,6 +312,12 @@ func (b Backend) QueryDisabledServices(info *snap.Info, pb progress.Meter) ([]st
 	return wrappers.QueryDisabledServices(info, pb)
 }
 
+// FailedServices returns the enabled services of a snap that should be
+// running but are not, e.g. because they are crash-looping.
+func (b Backend) FailedServices(info *snap.Info, meter progress.Meter) ([]string, error) {
+	return wrappers.FailedServices(info, meter)
+}
+
 func removeCurrentSymlinks(info snap.PlaceInfo) error {
 	var err1, err2 error
 
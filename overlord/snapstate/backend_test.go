@@ -680,6 +680,7 @@ type fakeSnappyBackend struct {
 	emptyContainer          snap.Container
 
 	servicesCurrentlyDisabled []string
+	servicesFailed            []string
 
 	lockDir string
 
@@ -999,6 +1000,15 @@ func (f *fakeSnappyBackend) QueryDisabledServices(info *snap.Info, meter progres
 	return l, nil
 }
 
+func (f *fakeSnappyBackend) FailedServices(info *snap.Info, meter progress.Meter) ([]string, error) {
+	f.appendOp(&fakeOp{
+		op:   "failed-services",
+		name: info.InstanceName(),
+	})
+
+	return f.servicesFailed, f.maybeErrForLastOp()
+}
+
 func (f *fakeSnappyBackend) UndoSetupSnap(s snap.PlaceInfo, typ snap.Type, installRecord *backend.InstallRecord, dev boot.Device, p progress.Meter) error {
 	p.Notify("setup-snap")
 	f.appendOp(&fakeOp{
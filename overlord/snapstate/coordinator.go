@@ -0,0 +1,324 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/proxyconf"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snapdenv"
+)
+
+// A refresh coordinator lets a fleet of devices roll out new revisions in
+// stages. When the refresh.coordinator system option is set to a http(s)
+// URL, every auto-refresh POSTs its candidates to it before refreshing
+// anything:
+//
+//   {
+//     "device": {"brand": "...", "model": "...", "serial": "..."},
+//     "snaps": [
+//       {"name": "foo", "snap-id": "...", "channel": "latest/stable",
+//        "revision": "5", "candidate-revision": "7"}
+//     ]
+//   }
+//
+// The coordinator answers with 200 and a decision per snap:
+//
+//   {
+//     "snaps": {
+//       "foo": {"action": "delay", "until": "2021-08-02T10:00:00Z", "reason": "..."},
+//       "bar": {"action": "pin", "revision": "6", "reason": "..."}
+//     }
+//   }
+//
+// "approve" refreshes the snap to the candidate revision, "delay" leaves
+// it alone for this auto-refresh (with an optional time at which to ask
+// again) and "pin" refreshes it to exactly the given revision, or leaves
+// it alone if that is its current one. Snaps missing from the answer are
+// approved. If the coordinator cannot be reached or answers with an
+// error nothing is auto-refreshed.
+
+// Hook setup by devicestate to identify the device to the refresh
+// coordinator.
+var DeviceIdentity func(st *state.State) (*auth.DeviceState, error)
+
+// Decisions the refresh coordinator can take about a snap.
+const (
+	CoordinatorApprove = "approve"
+	CoordinatorDelay   = "delay"
+	CoordinatorPin     = "pin"
+)
+
+var coordinatorTimeout = 30 * time.Second
+
+// CoordinatorDecision is what the refresh coordinator decided about the
+// auto-refresh of a snap.
+type CoordinatorDecision struct {
+	Action   string        `json:"action"`
+	Revision snap.Revision `json:"revision,omitempty"`
+	Until    time.Time     `json:"until,omitempty"`
+	Reason   string        `json:"reason,omitempty"`
+}
+
+func (d *CoordinatorDecision) validate() error {
+	switch d.Action {
+	case CoordinatorApprove, CoordinatorDelay:
+		return nil
+	case CoordinatorPin:
+		if d.Revision.Unset() {
+			return fmt.Errorf("pin without revision")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q", d.Action)
+	}
+}
+
+// RefreshCoordination records the outcome of the last consultation of
+// the refresh coordinator.
+type RefreshCoordination struct {
+	URL   string                          `json:"url"`
+	Time  time.Time                       `json:"time,omitempty"`
+	Error string                          `json:"error,omitempty"`
+	Snaps map[string]*CoordinatorDecision `json:"snaps,omitempty"`
+}
+
+// retryTime returns the earliest time the coordinator asked to be
+// consulted again about delayed snaps, if any.
+func (rc *RefreshCoordination) retryTime() time.Time {
+	var retry time.Time
+	for _, d := range rc.Snaps {
+		if d.Action != CoordinatorDelay || d.Until.IsZero() {
+			continue
+		}
+		if retry.IsZero() || d.Until.Before(retry) {
+			retry = d.Until
+		}
+	}
+	return retry
+}
+
+type coordinatorDevice struct {
+	Brand  string `json:"brand,omitempty"`
+	Model  string `json:"model,omitempty"`
+	Serial string `json:"serial,omitempty"`
+}
+
+type coordinatorSnap struct {
+	Name              string        `json:"name"`
+	SnapID            string        `json:"snap-id"`
+	Channel           string        `json:"channel,omitempty"`
+	Revision          snap.Revision `json:"revision"`
+	CandidateRevision snap.Revision `json:"candidate-revision"`
+}
+
+type coordinatorRequest struct {
+	Device coordinatorDevice `json:"device"`
+	Snaps  []coordinatorSnap `json:"snaps"`
+}
+
+type coordinatorResponse struct {
+	Snaps map[string]*CoordinatorDecision `json:"snaps"`
+}
+
+func refreshCoordinatorURL(st *state.State) (string, error) {
+	var coordURL string
+	tr := config.NewTransaction(st)
+	if err := tr.GetMaybe("core", "refresh.coordinator", &coordURL); err != nil {
+		return "", err
+	}
+	return coordURL, nil
+}
+
+// LastRefreshCoordination returns the outcome of the last consultation
+// of the configured refresh coordinator, or nil if none is configured.
+func LastRefreshCoordination(st *state.State) (*RefreshCoordination, error) {
+	coordURL, err := refreshCoordinatorURL(st)
+	if err != nil || coordURL == "" {
+		return nil, err
+	}
+	var rc RefreshCoordination
+	if err := st.Get("refresh-coordination", &rc); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if rc.URL != coordURL {
+		// not consulted since it was configured
+		return &RefreshCoordination{URL: coordURL}, nil
+	}
+	return &rc, nil
+}
+
+func askRefreshCoordinator(ctx context.Context, client *http.Client, coordURL string, coordReq *coordinatorRequest) (map[string]*CoordinatorDecision, error) {
+	body, err := json.Marshal(coordReq)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", coordURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", snapdenv.UserAgent())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var coordResp coordinatorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&coordResp); err != nil {
+		return nil, fmt.Errorf("cannot decode response: %v", err)
+	}
+	for name, d := range coordResp.Snaps {
+		if d == nil {
+			return nil, fmt.Errorf("invalid decision for %q: missing", name)
+		}
+		if err := d.validate(); err != nil {
+			return nil, fmt.Errorf("invalid decision for %q: %v", name, err)
+		}
+	}
+	return coordResp.Snaps, nil
+}
+
+// coordinateRefreshes consults the refresh coordinator, if one is
+// configured, about the given auto-refresh candidates and returns the
+// ones to go ahead with, with pinned revisions swapped in. The outcome is
+// recorded in the state.
+// The state must be locked by the caller, it is unlocked while talking to
+// the coordinator and the store.
+func coordinateRefreshes(ctx context.Context, st *state.State, updates []*snap.Info, stateByInstanceName map[string]*SnapState) ([]*snap.Info, error) {
+	coordURL, err := refreshCoordinatorURL(st)
+	if err != nil {
+		return nil, err
+	}
+	if coordURL == "" {
+		st.Set("refresh-coordination", nil)
+		return updates, nil
+	}
+	if len(updates) == 0 {
+		return updates, nil
+	}
+
+	var coordReq coordinatorRequest
+	if DeviceIdentity != nil {
+		device, err := DeviceIdentity(st)
+		if err != nil {
+			return nil, err
+		}
+		coordReq.Device = coordinatorDevice{
+			Brand:  device.Brand,
+			Model:  device.Model,
+			Serial: device.Serial,
+		}
+	}
+	for _, up := range updates {
+		snapst := stateByInstanceName[up.InstanceName()]
+		coordReq.Snaps = append(coordReq.Snaps, coordinatorSnap{
+			Name:              up.InstanceName(),
+			SnapID:            up.SnapID,
+			Channel:           snapst.TrackingChannel,
+			Revision:          snapst.Current,
+			CandidateRevision: up.Revision,
+		})
+	}
+
+	client := httputil.NewHTTPClient(&httputil.ClientOptions{
+		Timeout:            coordinatorTimeout,
+		Proxy:              proxyconf.New(st).Conf,
+		ProxyConnectHeader: http.Header{"User-Agent": []string{snapdenv.UserAgent()}},
+	})
+	st.Unlock()
+	decisions, err := askRefreshCoordinator(ctx, client, coordURL, &coordReq)
+	st.Lock()
+
+	rc := &RefreshCoordination{
+		URL:  coordURL,
+		Time: timeNow(),
+	}
+	if err != nil {
+		rc.Error = err.Error()
+		st.Set("refresh-coordination", rc)
+		return nil, fmt.Errorf("cannot consult refresh coordinator: %v", err)
+	}
+
+	rc.Snaps = make(map[string]*CoordinatorDecision, len(updates))
+	coordinated := make([]*snap.Info, 0, len(updates))
+	for _, up := range updates {
+		name := up.InstanceName()
+		d := decisions[name]
+		if d == nil {
+			d = &CoordinatorDecision{Action: CoordinatorApprove}
+		}
+		rc.Snaps[name] = d
+
+		switch d.Action {
+		case CoordinatorApprove:
+			coordinated = append(coordinated, up)
+		case CoordinatorDelay:
+			logger.Noticef("auto-refresh: refresh coordinator delays refresh of %q: %s", name, d.Reason)
+		case CoordinatorPin:
+			snapst := stateByInstanceName[name]
+			switch d.Revision {
+			case up.Revision:
+				coordinated = append(coordinated, up)
+			case snapst.Current:
+				logger.Noticef("auto-refresh: refresh coordinator pins %q to its current revision %s: %s", name, d.Revision, d.Reason)
+			default:
+				pinned, err := updateToRevisionInfo(st, snapst, d.Revision, snapst.UserID, nil)
+				if err != nil {
+					logger.Noticef("auto-refresh: cannot refresh %q to revision %s pinned by refresh coordinator: %v", name, d.Revision, err)
+					continue
+				}
+				coordinated = append(coordinated, pinned)
+			}
+		}
+	}
+	st.Set("refresh-coordination", rc)
+	return coordinated, nil
+}
+
+// refreshCoordinationSince returns the outcome of the refresh
+// coordinator consultation made at or after the given time, if any.
+func refreshCoordinationSince(st *state.State, t time.Time) *RefreshCoordination {
+	var rc RefreshCoordination
+	if err := st.Get("refresh-coordination", &rc); err != nil {
+		return nil
+	}
+	if rc.Time.Before(t) {
+		return nil
+	}
+	return &rc
+}
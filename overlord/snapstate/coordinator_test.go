@@ -0,0 +1,191 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) mockRefreshCoordinator(c *C, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	s.AddCleanup(server.Close)
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.coordinator", server.URL+"/refresh"), IsNil)
+	tr.Commit()
+}
+
+func (s *snapmgrTestSuite) setCoordinatedSnaps() {
+	for _, name := range []string{"some-snap", "some-other-snap", "services-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, SnapID: name + "-id", Revision: snap.R(1)},
+			},
+			Current:  snap.R(1),
+			SnapType: "app",
+		})
+	}
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshRefreshCoordinator(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setCoordinatedSnaps()
+	snapstate.DeviceIdentity = func(*state.State) (*auth.DeviceState, error) {
+		return &auth.DeviceState{Brand: "my-brand", Model: "my-model", Serial: "serial-1"}, nil
+	}
+	defer func() { snapstate.DeviceIdentity = nil }()
+	now := time.Now()
+	defer snapstate.MockTimeNow(func() time.Time { return now })()
+
+	until := time.Date(2021, 8, 2, 10, 0, 0, 0, time.UTC)
+	var coordReq map[string]interface{}
+	s.mockRefreshCoordinator(c, func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/refresh")
+		c.Check(r.Header.Get("Content-Type"), Equals, "application/json")
+		c.Assert(json.NewDecoder(r.Body).Decode(&coordReq), IsNil)
+		fmt.Fprintf(w, `{"snaps": {
+"some-snap": {"action": "delay", "until": %q, "reason": "canary failing"},
+"some-other-snap": {"action": "pin", "revision": "9", "reason": "stage 2"}
+}}`, until.Format(time.RFC3339))
+	})
+
+	updates, tts, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	sort.Strings(updates)
+	c.Check(updates, DeepEquals, []string{"services-snap", "some-other-snap"})
+
+	// the coordinator was told about all candidates
+	c.Check(coordReq["device"], DeepEquals, map[string]interface{}{
+		"brand":  "my-brand",
+		"model":  "my-model",
+		"serial": "serial-1",
+	})
+	c.Check(coordReq["snaps"], HasLen, 3)
+	for _, cand := range coordReq["snaps"].([]interface{}) {
+		cand := cand.(map[string]interface{})
+		c.Check(cand["revision"], Equals, "1")
+		c.Check(cand["candidate-revision"], Equals, "11")
+	}
+
+	// the pinned revision is refreshed to
+	var revisions []snap.Revision
+	for _, ts := range tts {
+		snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+		if err != nil {
+			continue
+		}
+		if snapsup.InstanceName() == "some-other-snap" {
+			revisions = append(revisions, snapsup.Revision())
+		}
+	}
+	c.Check(revisions, DeepEquals, []snap.Revision{snap.R(9)})
+
+	// and the decisions are recorded
+	rc, err := snapstate.LastRefreshCoordination(s.state)
+	c.Assert(err, IsNil)
+	c.Check(rc.Error, Equals, "")
+	c.Check(rc.Time.Equal(now), Equals, true)
+	c.Check(rc.Snaps, DeepEquals, map[string]*snapstate.CoordinatorDecision{
+		"some-snap":       {Action: "delay", Until: until, Reason: "canary failing"},
+		"some-other-snap": {Action: "pin", Revision: snap.R(9), Reason: "stage 2"},
+		"services-snap":   {Action: "approve"},
+	})
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshRefreshCoordinatorPinCurrent(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setCoordinatedSnaps()
+	s.mockRefreshCoordinator(c, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"snaps": {"some-snap": {"action": "pin", "revision": 1}}}`)
+	})
+
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	sort.Strings(updates)
+	c.Check(updates, DeepEquals, []string{"services-snap", "some-other-snap"})
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshRefreshCoordinatorErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setCoordinatedSnaps()
+	for _, t := range []struct {
+		status int
+		body   string
+		err    string
+	}{
+		{500, `{}`, `unexpected status 500`},
+		{200, `{"snaps": `, `cannot decode response: .*`},
+		{200, `{"snaps": {"some-snap": {"action": "skip"}}}`, `invalid decision for "some-snap": unknown action "skip"`},
+		{200, `{"snaps": {"some-snap": {"action": "pin"}}}`, `invalid decision for "some-snap": pin without revision`},
+	} {
+		s.mockRefreshCoordinator(c, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(t.status)
+			fmt.Fprintln(w, t.body)
+		})
+
+		// nothing is refreshed if the coordinator cannot be consulted
+		_, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+		c.Check(err, ErrorMatches, "cannot consult refresh coordinator: "+t.err, Commentf("%s", t.body))
+
+		rc, err := snapstate.LastRefreshCoordination(s.state)
+		c.Assert(err, IsNil)
+		c.Check(rc.Error, Matches, t.err)
+	}
+}
+
+func (s *snapmgrTestSuite) TestRefreshCoordinatorOnlyForAutoRefresh(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setCoordinatedSnaps()
+	s.mockRefreshCoordinator(c, func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request to the refresh coordinator")
+	})
+
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 3)
+
+	rc, err := snapstate.LastRefreshCoordination(s.state)
+	c.Assert(err, IsNil)
+	c.Check(rc.Time.IsZero(), Equals, true)
+}
@@ -563,9 +563,14 @@ func downloadSnapParams(st *state.State, t *state.Task) (*SnapSetup, StoreServic
 	return snapsup, sto, user, nil
 }
 
+// PeerSource is a hook set up by peerstate returning the LAN peers to
+// try downloading snaps from before the store, or nil.
+var PeerSource func(st *state.State) store.PeerSource
+
 func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 	st := t.State()
 	var rate int64
+	var peers store.PeerSource
 
 	st.Lock()
 	perfTimings := state.TimingsForTask(t)
@@ -574,6 +579,9 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 		// NOTE rate is never negative
 		rate = autoRefreshRateLimited(st)
 	}
+	if PeerSource != nil {
+		peers = PeerSource(st)
+	}
 	st.Unlock()
 	if err != nil {
 		return err
@@ -585,6 +593,7 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 	dlOpts := &store.DownloadOptions{
 		IsAutoRefresh: snapsup.IsAutoRefresh,
 		RateLimit:     rate,
+		Peers:         peers,
 	}
 	if snapsup.DownloadInfo == nil {
 		var storeInfo store.SnapActionResult
@@ -1929,6 +1938,73 @@ func (m *SnapManager) undoStartSnapServices(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+// doCheckRefreshHealth waits for the grace period after the services of the
+// refreshed snap were started, and then checks that the snap did not report
+// an error as its health and that its services are running. An unhealthy
+// snap makes the task fail, undoing the refresh.
+func (m *SnapManager) doCheckRefreshHealth(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	var grace time.Duration
+	if err := t.Get("grace-period", &grace); err != nil && err != state.ErrNoState {
+		return err
+	}
+	var started time.Time
+	err = t.Get("started", &started)
+	if err == state.ErrNoState {
+		started = timeNow()
+		t.Set("started", started)
+		t.Logf("Waiting %s before checking the health of snap %q", grace, snapsup.InstanceName())
+	} else if err != nil {
+		return err
+	}
+	if left := started.Add(grace).Sub(timeNow()); left > 0 {
+		return &state.Retry{After: left, Reason: "waiting for the refreshed snap to settle"}
+	}
+
+	// health reported by the new revision at any point of the refresh
+	// counts, e.g. from its post-refresh hook
+	since := started
+	if chg := t.Change(); chg != nil {
+		since = chg.SpawnTime()
+	}
+	var problems []string
+	healthErr, err := HealthError(st, snapsup.InstanceName(), snapsup.Revision(), since)
+	if err != nil {
+		return err
+	}
+	if healthErr != "" {
+		problems = append(problems, fmt.Sprintf("health is error: %s", healthErr))
+	}
+
+	currentInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+	st.Unlock()
+	failed, err := m.backend.FailedServices(currentInfo, progress.Null)
+	st.Lock()
+	if err != nil {
+		return fmt.Errorf("cannot check services of snap %q: %v", snapsup.InstanceName(), err)
+	}
+	for _, svc := range failed {
+		problems = append(problems, fmt.Sprintf("service %q is not running", svc))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("snap %q is unhealthy after refresh to revision %s, reverting: %s", snapsup.InstanceName(), snapsup.Revision(), strings.Join(problems, "; "))
+	}
+	t.Logf("Snap %q is healthy", snapsup.InstanceName())
+	return nil
+}
+
 func (m *SnapManager) stopSnapServices(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
@@ -20,6 +20,7 @@
 package snapstate_test
 
 import (
+	"context"
 	"path/filepath"
 
 	. "gopkg.in/check.v1"
@@ -295,3 +296,48 @@ func (s *downloadSnapSuite) TestDoDownloadRateLimitedIntegration(c *C) {
 	})
 
 }
+
+type fakePeerSource struct{}
+
+func (fakePeerSource) Peers(ctx context.Context) ([]store.Peer, error) {
+	return nil, nil
+}
+
+func (s *downloadSnapSuite) TestDoDownloadWithPeers(c *C) {
+	src := fakePeerSource{}
+	oldPeerSource := snapstate.PeerSource
+	snapstate.PeerSource = func(st *state.State) store.PeerSource {
+		return src
+	}
+	defer func() { snapstate.PeerSource = oldPeerSource }()
+
+	s.state.Lock()
+	si := &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   "foo-id",
+		Revision: snap.R(11),
+	}
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	s.state.NewChange("dummy", "...").AddTask(t)
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	// the peers are handed to the store download
+	c.Assert(s.fakeStore.downloads, DeepEquals, []fakeDownload{
+		{
+			name:   "foo",
+			target: filepath.Join(dirs.SnapBlobDir, "foo_11.snap"),
+			opts: &store.DownloadOptions{
+				Peers: src,
+			},
+		},
+	})
+}
@@ -0,0 +1,130 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type refreshHealthSuite struct {
+	baseHandlerSuite
+
+	healthErr string
+}
+
+var _ = Suite(&refreshHealthSuite{})
+
+func (s *refreshHealthSuite) SetUpTest(c *C) {
+	s.setup(c, nil)
+	s.AddCleanup(snapstatetest.MockDeviceModel(DefaultModel()))
+
+	s.healthErr = ""
+	old := snapstate.HealthError
+	snapstate.HealthError = func(st *state.State, snapName string, rev snap.Revision, since time.Time) (string, error) {
+		c.Check(snapName, Equals, "foo")
+		c.Check(rev, Equals, snap.R(33))
+		return s.healthErr, nil
+	}
+	s.AddCleanup(func() { snapstate.HealthError = old })
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(11)},
+			{RealName: "foo", Revision: snap.R(33)},
+		},
+		Current:  snap.R(33),
+		Active:   true,
+		SnapType: "app",
+	})
+}
+
+func (s *refreshHealthSuite) runCheck(c *C, grace time.Duration, started time.Time) *state.Task {
+	s.state.Lock()
+	t := s.state.NewTask("check-refresh-health", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(33),
+		},
+	})
+	t.Set("grace-period", grace)
+	if !started.IsZero() {
+		t.Set("started", started)
+	}
+	s.state.NewChange("dummy", "...").AddTask(t)
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	return t
+}
+
+func (s *refreshHealthSuite) TestHealthy(c *C) {
+	t := s.runCheck(c, 0, time.Time{})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{{op: "failed-services", name: "foo"}})
+	c.Assert(t.Log(), HasLen, 2)
+	c.Check(t.Log()[1], Matches, `.* Snap "foo" is healthy`)
+}
+
+func (s *refreshHealthSuite) TestHealthError(c *C) {
+	s.healthErr = "cannot reach the server"
+	t := s.runCheck(c, 0, time.Time{})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Change().Err(), ErrorMatches, `(?s).*snap "foo" is unhealthy after refresh to revision 33, reverting: health is error: cannot reach the server.*`)
+}
+
+func (s *refreshHealthSuite) TestFailedServices(c *C) {
+	s.fakeBackend.servicesFailed = []string{"svc1", "svc2"}
+	t := s.runCheck(c, 0, time.Time{})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Change().Err(), ErrorMatches, `(?s).*snap "foo" is unhealthy after refresh to revision 33, reverting: service "svc1" is not running; service "svc2" is not running.*`)
+}
+
+func (s *refreshHealthSuite) TestWaitsForGracePeriod(c *C) {
+	now := time.Now()
+	s.AddCleanup(snapstate.MockTimeNow(func() time.Time { return now }))
+
+	t := s.runCheck(c, time.Hour, now.Add(-10*time.Minute))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoingStatus)
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+}
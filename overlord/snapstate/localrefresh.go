@@ -0,0 +1,338 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// localSnapInfo is a refresh read from a local snap file instead of the
+// store, or a prerequisite of one that is to be installed along.
+type localSnapInfo struct {
+	*snap.Info
+	path string
+	// prereq are the default providers of the snap that are installed
+	// or among the local snaps, the others cannot be installed
+	// without the store
+	prereq     []string
+	removePath bool
+}
+
+func (ls localSnapInfo) DownloadSize() int64 {
+	// nothing to download
+	return 0
+}
+
+// SnapBase returns the base snap of the snap.
+func (ls localSnapInfo) SnapBase() string {
+	return ls.Base
+}
+
+func (ls localSnapInfo) Prereq(st *state.State) []string {
+	return ls.prereq
+}
+
+func (ls localSnapInfo) SnapSetupForUpdate(st *state.State, params updateParamsFunc, userID int, globalFlags *Flags) (*SnapSetup, *SnapState, error) {
+	update := ls.Info
+
+	revnoOpts, flags, snapst := params(update)
+	flags.IsAutoRefresh = globalFlags.IsAutoRefresh
+	flags.RemoveSnapPath = ls.removePath
+
+	flags, err := earlyChecks(st, snapst, update, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapUserID, err := userIDForSnap(st, snapst, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapsup := SnapSetup{
+		Base:        update.Base,
+		Prereq:      ls.prereq,
+		Channel:     revnoOpts.Channel,
+		CohortKey:   revnoOpts.CohortKey,
+		UserID:      snapUserID,
+		Flags:       flags.ForSnapSetup(),
+		SnapPath:    ls.path,
+		SideInfo:    &update.SideInfo,
+		Type:        update.Type(),
+		PlugsOnly:   len(update.Slots) == 0,
+		InstanceKey: update.InstanceKey,
+	}
+	return &snapsup, snapst, nil
+}
+
+// soundness check
+var _ readyUpdateInfo = localSnapInfo{}
+
+// requiredBase returns the base that needs to be installed for the snap,
+// as the prerequisites task sees it, or "" if none.
+func requiredBase(info *snap.Info) string {
+	switch info.Type() {
+	case snap.TypeOS, snap.TypeBase, snap.TypeKernel, snap.TypeGadget, snap.TypeSnapd:
+		return ""
+	}
+	switch info.Base {
+	case "":
+		return defaultCoreSnapName
+	case "none":
+		return ""
+	}
+	return info.Base
+}
+
+// UpdatePathMany refreshes, without the store, the snaps from the given
+// list of names, or all snaps if the list is empty, for which a newer
+// revision is among the given local snap files. The side infos of the
+// files must be derived from their assertions. The refresh set is computed
+// as UpdateMany does with the store: inactive, devmode (unless named) and
+// blocked snaps are skipped, and validation applies. Bases and default
+// providers of the refreshed snaps that are not installed are installed
+// along if they are among the local snaps; a refresh needing a base that
+// is not is not possible.
+// Note that the state must be locked by the caller.
+func UpdatePathMany(ctx context.Context, st *state.State, sideInfos []*snap.SideInfo, paths []string, names []string, userID int, flags *Flags) ([]string, []*state.TaskSet, error) {
+	if len(sideInfos) != len(paths) {
+		return nil, nil, fmt.Errorf("internal error: number of paths and side infos must match: %d != %d", len(paths), len(sideInfos))
+	}
+	if flags == nil {
+		flags = &Flags{}
+	}
+	// re-refreshes need the store
+	globalFlags := *flags
+	globalFlags.NoReRefresh = true
+	globalFlags.RemoveSnapPath = false
+
+	deviceCtx, err := DevicePastSeeding(st, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the newest local revision of each snap, by snap id and by name
+	localByID := make(map[string]localSnapInfo, len(paths))
+	localByName := make(map[string]localSnapInfo, len(paths))
+	for i, si := range sideInfos {
+		path := paths[i]
+		if si.SnapID == "" || si.Revision.Unset() {
+			return nil, nil, fmt.Errorf("internal error: snap id and revision must be set to refresh from %q", path)
+		}
+		info, container, err := backend.OpenSnapFile(path, si)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := validateContainer(container, info, logger.Noticef); err != nil {
+			return nil, nil, err
+		}
+		if info.SnapName() != si.RealName {
+			return nil, nil, fmt.Errorf("cannot refresh from %q, the name does not match the metadata %q", path, info.SnapName())
+		}
+		if prev, ok := localByID[si.SnapID]; ok && prev.Revision.N >= info.Revision.N {
+			continue
+		}
+		ls := localSnapInfo{Info: info, path: path}
+		localByID[si.SnapID] = ls
+		localByName[info.SnapName()] = ls
+	}
+
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range names {
+		if _, ok := snapStates[name]; !ok {
+			return nil, nil, snap.NotInstalledError{Snap: name}
+		}
+	}
+	sort.Strings(names)
+	refreshAll := len(names) == 0
+
+	stateByInstanceName := make(map[string]*SnapState)
+	ignoreValidation := make(map[string]bool)
+	var updates []*snap.Info
+	collectCurrentSnaps(snapStates, func(installed *store.CurrentSnap, snapst *SnapState) {
+		if !snapst.Active {
+			return
+		}
+		if refreshAll && snapst.DevMode {
+			return
+		}
+		if !refreshAll && !strutil.SortedListContains(names, installed.InstanceName) {
+			return
+		}
+		ls, ok := localByID[installed.SnapID]
+		if !ok || ls.Revision.N <= installed.Revision.N {
+			return
+		}
+		if refreshAll {
+			for _, rev := range snapst.Block() {
+				if rev == ls.Revision {
+					return
+				}
+			}
+		}
+		update := *ls.Info
+		_, update.InstanceKey = snap.SplitInstanceName(installed.InstanceName)
+		stateByInstanceName[installed.InstanceName] = snapst
+		if snapst.IgnoreValidation {
+			ignoreValidation[installed.InstanceName] = true
+		}
+		updates = append(updates, &update)
+	})
+	// collectCurrentSnaps goes through a map
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].InstanceName() < updates[j].InstanceName()
+	})
+
+	if ValidateRefreshes != nil && len(updates) != 0 {
+		updates, err = ValidateRefreshes(st, updates, ignoreValidation, userID, deviceCtx)
+		if err != nil {
+			// not doing "refresh all" report the error
+			if !refreshAll {
+				return nil, nil, err
+			}
+			// doing "refresh all", log the problems
+			logger.Noticef("cannot refresh some snaps: %v", err)
+		}
+	}
+
+	var toUpdate []localSnapInfo
+	installing := make(map[string]bool)
+
+	// available returns whether the snap of the given name is
+	// installed or is to be, adding it to the snaps to install if it is
+	// among the local snaps
+	var available func(name string) (bool, error)
+	// prereqs returns the default providers of the snap that are
+	// available, or an error if its base is not
+	prereqs := func(info *snap.Info) ([]string, error) {
+		if base := requiredBase(info); base != "" {
+			ok, err := available(base)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("base %q is not installed nor among the local snaps", base)
+			}
+		}
+		var prereq []string
+		for _, provider := range defaultContentPlugProviders(st, info) {
+			ok, err := available(provider)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				logger.Noticef("Default provider %q of snap %q is not installed nor among the local snaps, not installing it.", provider, info.InstanceName())
+				continue
+			}
+			prereq = append(prereq, provider)
+		}
+		return prereq, nil
+	}
+	available = func(name string) (bool, error) {
+		if installing[name] {
+			return true, nil
+		}
+		ok, err := isInstalled(st, name)
+		if err != nil || ok {
+			return ok, err
+		}
+		if name == "core16" {
+			// core provides everything needed for core16
+			if ok, err := isInstalled(st, defaultCoreSnapName); err != nil || ok {
+				return ok, err
+			}
+		}
+		ls, ok := localByName[name]
+		if !ok {
+			return false, nil
+		}
+		installing[name] = true
+		prereq, err := prereqs(ls.Info)
+		if err != nil {
+			delete(installing, name)
+			logger.Noticef("Cannot install snap %q from local files: %v", name, err)
+			return false, nil
+		}
+		ls.prereq = prereq
+		toUpdate = append(toUpdate, ls)
+		return true, nil
+	}
+
+	for _, update := range updates {
+		prereq, err := prereqs(update)
+		if err != nil {
+			if refreshAll {
+				logger.Noticef("cannot refresh snap %q from local files: %v", update.InstanceName(), err)
+				continue
+			}
+			return nil, nil, fmt.Errorf("cannot refresh snap %q from local files: %v", update.InstanceName(), err)
+		}
+		toUpdate = append(toUpdate, localSnapInfo{
+			Info:   update,
+			path:   localByID[update.SnapID].path,
+			prereq: prereq,
+		})
+	}
+
+	// a file used by several instances is left to the cleanup of
+	// local installs
+	uses := make(map[string]int, len(toUpdate))
+	for _, ls := range toUpdate {
+		uses[ls.path]++
+	}
+	minimal := make([]minimalInstallInfo, len(toUpdate))
+	for i, ls := range toUpdate {
+		ls.removePath = flags.RemoveSnapPath && uses[ls.path] == 1
+		minimal[i] = ls
+	}
+
+	params := func(update *snap.Info) (*RevisionOptions, Flags, *SnapState) {
+		snapst := stateByInstanceName[update.InstanceName()]
+		if snapst == nil {
+			// a prerequisite that is installed along
+			return &RevisionOptions{}, Flags{}, &SnapState{}
+		}
+		// setting options to what's in state as multi-refresh doesn't let you change these
+		opts := &RevisionOptions{
+			Channel:   snapst.TrackingChannel,
+			CohortKey: snapst.CohortKey,
+		}
+		return opts, snapst.Flags, snapst
+	}
+
+	updated, tasksets, err := doUpdate(ctx, st, names, minimal, params, userID, &globalFlags, deviceCtx, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	tasksets = finalizeUpdate(st, tasksets, len(minimal) > 0, updated, userID, &globalFlags)
+	return updated, tasksets, nil
+}
@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"sort"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *snapmgrTestSuite) setInstalled(instanceName, snapID string, rev int, typ string) {
+	snapName, instanceKey := snap.SplitInstanceName(instanceName)
+	snapstate.Set(s.state, instanceName, &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: snapName, SnapID: snapID, Revision: snap.R(rev)},
+		},
+		Current:         snap.R(rev),
+		SnapType:        typ,
+		TrackingChannel: "latest/stable",
+		InstanceKey:     instanceKey,
+	})
+}
+
+func snapSetupOf(c *C, ts *state.TaskSet) *snapstate.SnapSetup {
+	snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	return snapsup
+}
+
+func (s *snapmgrTestSuite) TestUpdatePathMany(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setInstalled("some-snap", "some-snap-id", 1, "app")
+	s.setInstalled("some-other-snap", "some-other-snap-id", 5, "app")
+
+	sideInfos := []*snap.SideInfo{
+		{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+		{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(3)},
+		// older than installed
+		{RealName: "some-other-snap", SnapID: "some-other-snap-id", Revision: snap.R(4)},
+		// not installed
+		{RealName: "unrelated-snap", SnapID: "unrelated-snap-id", Revision: snap.R(1)},
+	}
+	paths := []string{
+		makeTestSnap(c, "name: some-snap\nversion: 2"),
+		makeTestSnap(c, "name: some-snap\nversion: 3"),
+		makeTestSnap(c, "name: some-other-snap\nversion: 4"),
+		makeTestSnap(c, "name: unrelated-snap\nversion: 1"),
+	}
+
+	updated, tss, err := snapstate.UpdatePathMany(context.Background(), s.state, sideInfos, paths, nil, 0, &snapstate.Flags{RemoveSnapPath: true})
+	c.Assert(err, IsNil)
+	c.Check(updated, DeepEquals, []string{"some-snap"})
+	// no re-refresh without the store
+	c.Assert(tss, HasLen, 1)
+
+	ts := tss[0]
+	// nothing to download
+	c.Check(taskKinds(ts.Tasks())[:3], DeepEquals, []string{"prerequisites", "prepare-snap", "mount-snap"})
+	snapsup := snapSetupOf(c, ts)
+	c.Check(snapsup.SnapPath, Equals, paths[1])
+	c.Check(snapsup.Revision(), Equals, snap.R(3))
+	c.Check(snapsup.SideInfo.SnapID, Equals, "some-snap-id")
+	c.Check(snapsup.Channel, Equals, "latest/stable")
+	c.Check(snapsup.DownloadInfo, IsNil)
+	c.Check(snapsup.Flags.RemoveSnapPath, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestUpdatePathManyNamed(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setInstalled("some-snap", "some-snap-id", 1, "app")
+	s.setInstalled("some-other-snap", "some-other-snap-id", 1, "app")
+
+	sideInfos := []*snap.SideInfo{
+		{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+		{RealName: "some-other-snap", SnapID: "some-other-snap-id", Revision: snap.R(2)},
+	}
+	paths := []string{
+		makeTestSnap(c, "name: some-snap\nversion: 2"),
+		makeTestSnap(c, "name: some-other-snap\nversion: 2"),
+	}
+
+	updated, tss, err := snapstate.UpdatePathMany(context.Background(), s.state, sideInfos, paths, []string{"some-other-snap"}, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updated, DeepEquals, []string{"some-other-snap"})
+	c.Assert(tss, HasLen, 1)
+	c.Check(snapSetupOf(c, tss[0]).Flags.RemoveSnapPath, Equals, false)
+
+	_, _, err = snapstate.UpdatePathMany(context.Background(), s.state, sideInfos, paths, []string{"not-installed"}, 0, nil)
+	c.Check(err, ErrorMatches, `snap "not-installed" is not installed`)
+}
+
+func (s *snapmgrTestSuite) TestUpdatePathManyInstallsMissingBase(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setInstalled("some-snap", "some-snap-id", 1, "app")
+
+	sideInfos := []*snap.SideInfo{
+		{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+		{RealName: "some-base", SnapID: "some-base-id", Revision: snap.R(7)},
+	}
+	paths := []string{
+		makeTestSnap(c, "name: some-snap\nversion: 2\nbase: some-base"),
+		makeTestSnap(c, "name: some-base\nversion: 1\ntype: base"),
+	}
+
+	updated, tss, err := snapstate.UpdatePathMany(context.Background(), s.state, sideInfos, paths, nil, 0, nil)
+	c.Assert(err, IsNil)
+	sort.Strings(updated)
+	c.Check(updated, DeepEquals, []string{"some-base", "some-snap"})
+	c.Assert(tss, HasLen, 2)
+
+	// the base comes first, and the snap waits for it
+	baseTs, snapTs := tss[0], tss[1]
+	c.Check(snapSetupOf(c, baseTs).InstanceName(), Equals, "some-base")
+	c.Check(snapSetupOf(c, baseTs).SnapPath, Equals, paths[1])
+	c.Check(snapSetupOf(c, snapTs).InstanceName(), Equals, "some-snap")
+	lastBaseTask := baseTs.Tasks()[len(baseTs.Tasks())-1]
+	c.Check(snapTs.Tasks()[0].WaitTasks(), testutil.Contains, lastBaseTask)
+}
+
+func (s *snapmgrTestSuite) TestUpdatePathManyMissingBase(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setInstalled("some-snap", "some-snap-id", 1, "app")
+
+	sideInfos := []*snap.SideInfo{
+		{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+	}
+	paths := []string{
+		makeTestSnap(c, "name: some-snap\nversion: 2\nbase: some-base"),
+	}
+
+	// refreshing all skips the snap
+	updated, tss, err := snapstate.UpdatePathMany(context.Background(), s.state, sideInfos, paths, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updated, HasLen, 0)
+	c.Check(tss, HasLen, 0)
+
+	// naming it is an error
+	_, _, err = snapstate.UpdatePathMany(context.Background(), s.state, sideInfos, paths, []string{"some-snap"}, 0, nil)
+	c.Check(err, ErrorMatches, `cannot refresh snap "some-snap" from local files: base "some-base" is not installed nor among the local snaps`)
+}
+
+func (s *snapmgrTestSuite) TestUpdatePathManyInstancesShareFile(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "experimental.parallel-instances", true)
+	tr.Commit()
+
+	s.setInstalled("some-snap", "some-snap-id", 1, "app")
+	s.setInstalled("some-snap_instance", "some-snap-id", 1, "app")
+
+	sideInfos := []*snap.SideInfo{
+		{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+	}
+	paths := []string{
+		makeTestSnap(c, "name: some-snap\nversion: 2"),
+	}
+
+	updated, tss, err := snapstate.UpdatePathMany(context.Background(), s.state, sideInfos, paths, nil, 0, &snapstate.Flags{RemoveSnapPath: true})
+	c.Assert(err, IsNil)
+	sort.Strings(updated)
+	c.Check(updated, DeepEquals, []string{"some-snap", "some-snap_instance"})
+	c.Assert(tss, HasLen, 2)
+	for _, ts := range tss {
+		snapsup := snapSetupOf(c, ts)
+		c.Check(snapsup.SnapPath, Equals, paths[0])
+		// the file cannot be removed after the first refresh
+		c.Check(snapsup.Flags.RemoveSnapPath, Equals, false)
+	}
+}
+
+func (s *snapmgrTestSuite) TestUpdatePathManyMismatch(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	sideInfos := []*snap.SideInfo{
+		{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+	}
+	paths := []string{
+		makeTestSnap(c, "name: some-other-snap\nversion: 2"),
+	}
+	_, _, err := snapstate.UpdatePathMany(context.Background(), s.state, sideInfos, paths, nil, 0, nil)
+	c.Check(err, ErrorMatches, `cannot refresh from ".*", the name does not match the metadata "some-other-snap"`)
+
+	_, _, err = snapstate.UpdatePathMany(context.Background(), s.state, []*snap.SideInfo{{RealName: "some-snap"}}, paths, nil, 0, nil)
+	c.Check(err, ErrorMatches, `internal error: snap id and revision must be set to refresh from ".*"`)
+}
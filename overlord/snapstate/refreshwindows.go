@@ -0,0 +1,174 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+// RefreshWindow restricts the auto-refreshes of a group of snaps to the
+// times covered by its timer. Refresh windows are configured with
+// refresh.windows.<name>.timer and refresh.windows.<name>.snaps; snaps
+// not listed in any window are auto-refreshed according to refresh.timer
+// alone.
+type RefreshWindow struct {
+	Name  string
+	Timer string
+	Snaps []string
+
+	schedule []*timeutil.Schedule
+}
+
+// Open returns whether the window is open at the given time.
+func (w *RefreshWindow) Open(t time.Time) bool {
+	return timeutil.Includes(w.schedule, t)
+}
+
+// NextOpen returns the earliest time at or after t, which is expected to
+// be the current time, at which the window is open.
+func (w *RefreshWindow) NextOpen(t time.Time) time.Time {
+	if w.Open(t) {
+		return t
+	}
+	var next time.Time
+	for _, sched := range w.schedule {
+		window := sched.Next(t)
+		if next.IsZero() || window.Start.Before(next) {
+			next = window.Start
+		}
+	}
+	return next
+}
+
+func refreshWindowSnaps(name string, v interface{}) ([]string, error) {
+	var snaps []string
+	switch v := v.(type) {
+	case nil:
+	case string:
+		snaps = strutil.CommaSeparatedList(v)
+	case []interface{}:
+		for _, s := range v {
+			str, ok := s.(string)
+			if !ok {
+				return nil, fmt.Errorf("refresh window %q has invalid snap name %v", name, s)
+			}
+			snaps = append(snaps, strings.TrimSpace(str))
+		}
+	default:
+		return nil, fmt.Errorf("refresh window %q snaps must be a list of snap names", name)
+	}
+	for _, snapName := range snaps {
+		if err := snap.ValidateInstanceName(snapName); err != nil {
+			return nil, fmt.Errorf("refresh window %q has invalid snap name: %v", name, err)
+		}
+	}
+	return snaps, nil
+}
+
+// ParseRefreshWindows parses and validates the refresh.windows system
+// configuration as seen through tr. The windows are returned sorted by
+// name.
+func ParseRefreshWindows(tr config.ConfGetter) ([]*RefreshWindow, error) {
+	var v interface{}
+	if err := tr.Get("core", "refresh.windows", &v); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	conf, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("refresh.windows must be a set of named refresh windows")
+	}
+
+	names := make([]string, 0, len(conf))
+	for name := range conf {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	windows := make([]*RefreshWindow, 0, len(conf))
+	windowForSnap := make(map[string]string)
+	for _, name := range names {
+		wconf, ok := conf[name].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("refresh window %q must have a timer and snaps", name)
+		}
+		for opt := range wconf {
+			if opt != "timer" && opt != "snaps" {
+				return nil, fmt.Errorf("refresh window %q has unsupported option %q", name, opt)
+			}
+		}
+		timer, ok := wconf["timer"].(string)
+		if !ok || timer == "" {
+			return nil, fmt.Errorf("refresh window %q has no timer", name)
+		}
+		schedule, err := timeutil.ParseSchedule(timer)
+		if err != nil {
+			return nil, fmt.Errorf("refresh window %q has invalid timer: %v", name, err)
+		}
+		snaps, err := refreshWindowSnaps(name, wconf["snaps"])
+		if err != nil {
+			return nil, err
+		}
+		if len(snaps) == 0 {
+			return nil, fmt.Errorf("refresh window %q has no snaps", name)
+		}
+		for _, snapName := range snaps {
+			if other, ok := windowForSnap[snapName]; ok && other != name {
+				return nil, fmt.Errorf("snap %q cannot be in both refresh windows %q and %q", snapName, other, name)
+			}
+			windowForSnap[snapName] = name
+		}
+		sort.Strings(snaps)
+		windows = append(windows, &RefreshWindow{
+			Name:     name,
+			Timer:    timer,
+			Snaps:    snaps,
+			schedule: schedule,
+		})
+	}
+	return windows, nil
+}
+
+// RefreshWindows returns the configured refresh windows sorted by name.
+func RefreshWindows(st *state.State) ([]*RefreshWindow, error) {
+	return ParseRefreshWindows(config.NewTransaction(st))
+}
+
+// refreshWindowFor returns the refresh window the given snap belongs to,
+// if any.
+func refreshWindowFor(windows []*RefreshWindow, snapName string) *RefreshWindow {
+	for _, w := range windows {
+		if strutil.SortedListContains(w.Snaps, snapName) {
+			return w
+		}
+	}
+	return nil
+}
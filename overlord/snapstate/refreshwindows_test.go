@@ -0,0 +1,194 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type refreshWindowsSuite struct {
+	state *state.State
+}
+
+var _ = Suite(&refreshWindowsSuite{})
+
+func (s *refreshWindowsSuite) SetUpTest(c *C) {
+	s.state = state.New(nil)
+}
+
+func (s *refreshWindowsSuite) setWindows(c *C, windows interface{}) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.windows", windows), IsNil)
+	tr.Commit()
+}
+
+func (s *refreshWindowsSuite) TestRefreshWindowsUnset(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	windows, err := snapstate.RefreshWindows(s.state)
+	c.Assert(err, IsNil)
+	c.Check(windows, HasLen, 0)
+}
+
+func (s *refreshWindowsSuite) TestRefreshWindows(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setWindows(c, map[string]interface{}{
+		"db": map[string]interface{}{
+			"timer": "sun,02:00-04:00",
+			"snaps": "postgres, mysql",
+		},
+		"apps": map[string]interface{}{
+			"timer": "23:00-01:00",
+			"snaps": []interface{}{"foo_instance"},
+		},
+	})
+
+	windows, err := snapstate.RefreshWindows(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(windows, HasLen, 2)
+	c.Check(windows[0].Name, Equals, "apps")
+	c.Check(windows[0].Timer, Equals, "23:00-01:00")
+	c.Check(windows[0].Snaps, DeepEquals, []string{"foo_instance"})
+	c.Check(windows[1].Name, Equals, "db")
+	c.Check(windows[1].Timer, Equals, "sun,02:00-04:00")
+	c.Check(windows[1].Snaps, DeepEquals, []string{"mysql", "postgres"})
+}
+
+func (s *refreshWindowsSuite) TestRefreshWindowsErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, t := range []struct {
+		windows interface{}
+		err     string
+	}{
+		{"foo", `refresh.windows must be a set of named refresh windows`},
+		{map[string]interface{}{"db": "foo"}, `refresh window "db" must have a timer and snaps`},
+		{map[string]interface{}{"db": map[string]interface{}{"snaps": "foo"}}, `refresh window "db" has no timer`},
+		{map[string]interface{}{"db": map[string]interface{}{"timer": "bogus", "snaps": "foo"}}, `refresh window "db" has invalid timer: .*`},
+		{map[string]interface{}{"db": map[string]interface{}{"timer": "02:00"}}, `refresh window "db" has no snaps`},
+		{map[string]interface{}{"db": map[string]interface{}{"timer": "02:00", "snaps": "Foo"}}, `refresh window "db" has invalid snap name: .*`},
+		{map[string]interface{}{"db": map[string]interface{}{"timer": "02:00", "snaps": 1}}, `refresh window "db" snaps must be a list of snap names`},
+		{map[string]interface{}{"db": map[string]interface{}{"timer": "02:00", "snaps": "foo", "when": "x"}}, `refresh window "db" has unsupported option "when"`},
+		{map[string]interface{}{
+			"db":    map[string]interface{}{"timer": "02:00", "snaps": "foo,bar"},
+			"other": map[string]interface{}{"timer": "03:00", "snaps": "foo"},
+		}, `snap "foo" cannot be in both refresh windows "db" and "other"`},
+	} {
+		s.setWindows(c, t.windows)
+		_, err := snapstate.RefreshWindows(s.state)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.windows))
+	}
+}
+
+func (s *refreshWindowsSuite) TestRefreshWindowOpenAndNextOpen(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setWindows(c, map[string]interface{}{
+		"db": map[string]interface{}{
+			"timer": "sun,02:00-04:00",
+			"snaps": "postgres",
+		},
+	})
+	windows, err := snapstate.RefreshWindows(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(windows, HasLen, 1)
+	w := windows[0]
+
+	// 2021-08-01 is a Sunday
+	sunday := time.Date(2021, 8, 1, 3, 0, 0, 0, time.Local)
+	c.Check(w.Open(sunday), Equals, true)
+	c.Check(w.NextOpen(sunday).Equal(sunday), Equals, true)
+
+	saturday := sunday.Add(-24 * time.Hour)
+	c.Check(w.Open(saturday), Equals, false)
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshHonorsRefreshWindows(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.windows.db.timer", "sun,02:00-04:00"), IsNil)
+	c.Assert(tr.Set("core", "refresh.windows.db.snaps", "some-snap"), IsNil)
+	tr.Commit()
+
+	// 2021-08-01 is a Sunday
+	sunday := time.Date(2021, 8, 1, 3, 0, 0, 0, time.Local)
+
+	// outside of the window the snap is not auto-refreshed
+	restore := snapstate.MockTimeNow(func() time.Time { return sunday.Add(-24 * time.Hour) })
+	defer restore()
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 0)
+
+	// but a manual refresh is not restricted
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshInsideRefreshWindow(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.windows.db.timer", "sun,02:00-04:00"), IsNil)
+	c.Assert(tr.Set("core", "refresh.windows.db.snaps", "some-snap"), IsNil)
+	tr.Commit()
+
+	sunday := time.Date(2021, 8, 1, 3, 0, 0, 0, time.Local)
+	restore := snapstate.MockTimeNow(func() time.Time { return sunday })
+	defer restore()
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, 0, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+}
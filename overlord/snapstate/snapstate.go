@@ -409,6 +409,21 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	addTask(startSnapServices)
 	prev = startSnapServices
 
+	// check the health of the refreshed snap once it has had time to
+	// settle, undoing the refresh if it is unhealthy
+	if snapst.IsInstalled() && !snapsup.Flags.Revert && snapsup.Type == snap.TypeApp {
+		grace, err := refreshHealthGracePeriod(st)
+		if err != nil {
+			return nil, err
+		}
+		if grace > 0 {
+			checkHealth := st.NewTask("check-refresh-health", fmt.Sprintf(i18n.G("Check health of snap %q%s after refresh"), snapsup.InstanceName(), revisionStr))
+			checkHealth.Set("grace-period", grace)
+			addTask(checkHealth)
+			prev = checkHealth
+		}
+	}
+
 	// Do not do that if we are reverting to a local revision
 	if snapst.IsInstalled() && !snapsup.Flags.Revert {
 		var retain int
@@ -552,6 +567,30 @@ var CheckHealthHook = func(st *state.State, snapName string, rev snap.Revision)
 	panic("internal error: snapstate.CheckHealthHook is unset")
 }
 
+// HealthError returns a description of the error the given revision of the
+// snap reported as its health since the given time, or "" if it did not.
+var HealthError = func(st *state.State, snapName string, rev snap.Revision, since time.Time) (string, error) {
+	panic("internal error: snapstate.HealthError is unset")
+}
+
+// refreshHealthGracePeriod returns how long to wait after the services of a
+// refreshed snap are started before checking its health, as set by the
+// refresh.health-grace-period option; zero means not to check.
+func refreshHealthGracePeriod(st *state.State) (time.Duration, error) {
+	var graceStr string
+	if err := config.NewTransaction(st).Get("core", "refresh.health-grace-period", &graceStr); err != nil && !config.IsNoOption(err) {
+		return 0, err
+	}
+	if graceStr == "" {
+		return 0, nil
+	}
+	grace, err := time.ParseDuration(graceStr)
+	if err != nil {
+		return 0, fmt.Errorf("refresh.health-grace-period cannot be parsed: %v", err)
+	}
+	return grace, nil
+}
+
 var SetupGateAutoRefreshHook = func(st *state.State, snapName string, base, restart bool, affectingSnaps map[string]bool) *state.Task {
 	panic("internal error: snapstate.SetupAutoRefreshGatingHook is unset")
 }
@@ -704,6 +704,50 @@ func (s *snapmgrTestSuite) TestUpdateTasks(c *C) {
 	c.Check(snapsup.Channel, Equals, "some-channel")
 }
 
+func (s *snapmgrTestSuite) TestUpdateTasksWithHealthGracePeriod(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.health-grace-period", "2m")
+	tr.Commit()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:          true,
+		TrackingChannel: "latest/edge",
+		Sequence:        []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:         snap.R(7),
+		SnapType:        "app",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	var startServices, checkHealth *state.Task
+	for _, t := range ts.Tasks() {
+		switch t.Kind() {
+		case "start-snap-services":
+			startServices = t
+		case "check-refresh-health":
+			checkHealth = t
+		}
+	}
+	c.Assert(startServices, NotNil)
+	c.Assert(checkHealth, NotNil)
+	c.Check(checkHealth.WaitTasks(), DeepEquals, []*state.Task{startServices})
+	var grace time.Duration
+	c.Assert(checkHealth.Get("grace-period", &grace), IsNil)
+	c.Check(grace, Equals, 2*time.Minute)
+	// the old revision is only cleaned up once the snap is healthy, and
+	// like the rest of the refresh the check is waited on by the
+	// configure and check-health hooks
+	c.Check(taskKinds(checkHealth.HaltTasks()), DeepEquals, []string{
+		"cleanup",
+		"run-hook[configure]",
+		"run-hook[check-health]",
+	})
+}
+
 func (s *snapmgrTestSuite) TestUpdateAmendRunThrough(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
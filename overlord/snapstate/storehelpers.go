@@ -466,6 +466,18 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, use
 	ignoreValidationByInstanceName := make(map[string]bool)
 	nCands := 0
 
+	autoRefresh := len(names) == 0 && opts.IsAutoRefresh
+
+	// auto-refreshes leave out snaps outside of their refresh window
+	var windows []*RefreshWindow
+	if autoRefresh {
+		windows, err = RefreshWindows(st)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	now := timeNow()
+
 	addCand := func(installed *store.CurrentSnap, snapst *SnapState) {
 		// FIXME: snaps that are not active are skipped for now
 		//        until we know what we want to do
@@ -478,6 +490,11 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, use
 			return
 		}
 
+		if w := refreshWindowFor(windows, installed.InstanceName); w != nil && !w.Open(now) {
+			logger.Debugf("auto-refresh: skipping %q outside of refresh window %q", installed.InstanceName, w.Name)
+			return
+		}
+
 		if len(names) > 0 && !strutil.SortedListContains(names, installed.InstanceName) {
 			return
 		}
@@ -556,6 +573,13 @@ func refreshCandidates(ctx context.Context, st *state.State, names []string, use
 		}
 	}
 
+	if autoRefresh {
+		updates, err = coordinateRefreshes(ctx, st, updates, stateByInstanceName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	return updates, stateByInstanceName, ignoreValidationByInstanceName, nil
 }
 
@@ -301,10 +301,20 @@ func (c *Change) Status() Status {
 // SetStatus sets the change status, overriding the default behavior (see Status method).
 func (c *Change) SetStatus(s Status) {
 	c.state.writing()
+	notify := c.state.hasEventHandlers()
+	var old Status
+	if notify {
+		old = c.Status()
+	}
 	c.status = s
 	if s.Ready() {
 		c.markReady()
 	}
+	if notify {
+		if new := c.Status(); new != old {
+			c.state.notify(&Event{Kind: ChangeStatusEvent, Change: c, Status: new})
+		}
+	}
 }
 
 func (c *Change) markReady() {
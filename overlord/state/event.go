@@ -0,0 +1,92 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"time"
+)
+
+// EventKind is the kind of an Event.
+type EventKind string
+
+const (
+	// ChangeStatusEvent is emitted when the status of a change changes.
+	ChangeStatusEvent EventKind = "change-status"
+	// TaskStatusEvent is emitted when the status of a task changes.
+	TaskStatusEvent EventKind = "task-status"
+	// TaskProgressEvent is emitted when the progress of a task is set.
+	TaskProgressEvent EventKind = "task-progress"
+	// WarningEvent is emitted when a warning is recorded.
+	WarningEvent EventKind = "warning"
+)
+
+// Event describes something that happened in the state, as passed to the
+// handlers registered with AddEventHandler.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// Change is the change of change events, or of the task of task
+	// events if the task is in one.
+	Change *Change
+	// Task is the task of task events.
+	Task *Task
+	// Status is the new status, for status events.
+	Status Status
+	// Message is the message of warning events.
+	Message string
+}
+
+// EventHandlerId identifies an event handler registered with
+// AddEventHandler.
+type EventHandlerId int
+
+// AddEventHandler registers the given function to be called for every
+// event happening in the state from now on. The function is called with the
+// state locked, and so must not block nor try to lock it; it must not modify
+// the state either.
+func (s *State) AddEventHandler(f func(ev *Event)) EventHandlerId {
+	s.reading()
+	if s.eventHandlers == nil {
+		s.eventHandlers = make(map[EventHandlerId]func(ev *Event))
+	}
+	s.lastEventHandlerId++
+	id := s.lastEventHandlerId
+	s.eventHandlers[id] = f
+	return id
+}
+
+// RemoveEventHandler unregisters the event handler with the given id.
+func (s *State) RemoveEventHandler(id EventHandlerId) {
+	s.reading()
+	delete(s.eventHandlers, id)
+}
+
+// hasEventHandlers returns whether there is anyone to notify of events,
+// for callers to skip the work of preparing them otherwise.
+func (s *State) hasEventHandlers() bool {
+	return len(s.eventHandlers) > 0
+}
+
+func (s *State) notify(ev *Event) {
+	ev.Time = timeNow()
+	for _, f := range s.eventHandlers {
+		f(ev)
+	}
+}
@@ -0,0 +1,153 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type eventSuite struct{}
+
+var _ = Suite(&eventSuite{})
+
+type recordedEvent struct {
+	kind    state.EventKind
+	change  string
+	task    string
+	status  state.Status
+	message string
+}
+
+func recordEvents(st *state.State) (*[]recordedEvent, state.EventHandlerId) {
+	var events []recordedEvent
+	id := st.AddEventHandler(func(ev *state.Event) {
+		rec := recordedEvent{kind: ev.Kind, status: ev.Status, message: ev.Message}
+		if ev.Change != nil {
+			rec.change = ev.Change.ID()
+		}
+		if ev.Task != nil {
+			rec.task = ev.Task.ID()
+		}
+		if ev.Time.IsZero() {
+			panic("event without time")
+		}
+		events = append(events, rec)
+	})
+	return &events, id
+}
+
+func (es *eventSuite) TestTaskAndChangeStatusEvents(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "...")
+	t2 := st.NewTask("link", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+
+	events, _ := recordEvents(st)
+
+	t1.SetStatus(state.DoingStatus)
+	t1.SetStatus(state.DoneStatus)
+	t2.SetStatus(state.DoneStatus)
+	// no change, no event
+	t2.SetStatus(state.DoneStatus)
+
+	c.Check(*events, DeepEquals, []recordedEvent{
+		{kind: state.TaskStatusEvent, change: chg.ID(), task: t1.ID(), status: state.DoingStatus},
+		{kind: state.ChangeStatusEvent, change: chg.ID(), status: state.DoingStatus},
+		{kind: state.TaskStatusEvent, change: chg.ID(), task: t1.ID(), status: state.DoneStatus},
+		{kind: state.ChangeStatusEvent, change: chg.ID(), status: state.DoStatus},
+		{kind: state.TaskStatusEvent, change: chg.ID(), task: t2.ID(), status: state.DoneStatus},
+		{kind: state.ChangeStatusEvent, change: chg.ID(), status: state.DoneStatus},
+	})
+}
+
+func (es *eventSuite) TestChangeSetStatusEvents(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	chg.AddTask(st.NewTask("download", "..."))
+
+	events, _ := recordEvents(st)
+
+	chg.SetStatus(state.ErrorStatus)
+	chg.SetStatus(state.ErrorStatus)
+
+	c.Check(*events, DeepEquals, []recordedEvent{
+		{kind: state.ChangeStatusEvent, change: chg.ID(), status: state.ErrorStatus},
+	})
+}
+
+func (es *eventSuite) TestTaskProgressEvents(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t := st.NewTask("download", "...")
+
+	events, _ := recordEvents(st)
+
+	t.SetProgress("foo", 1, 2)
+
+	c.Check(*events, DeepEquals, []recordedEvent{
+		{kind: state.TaskProgressEvent, task: t.ID()},
+	})
+}
+
+func (es *eventSuite) TestWarningEvents(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	events, _ := recordEvents(st)
+
+	st.Warnf("hello %s", "world")
+
+	c.Check(*events, DeepEquals, []recordedEvent{
+		{kind: state.WarningEvent, message: "hello world"},
+	})
+}
+
+func (es *eventSuite) TestRemoveEventHandler(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	events1, id1 := recordEvents(st)
+	events2, _ := recordEvents(st)
+	st.RemoveEventHandler(id1)
+
+	st.Warnf("hello")
+
+	c.Check(*events1, HasLen, 0)
+	c.Check(*events2, HasLen, 1)
+}
+
+func (es *eventSuite) TestAddEventHandlerWithoutLock(c *C) {
+	st := state.New(nil)
+	c.Check(func() { st.AddEventHandler(func(*state.Event) {}) }, PanicMatches, "internal error: accessing state without lock")
+}
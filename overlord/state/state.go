@@ -119,6 +119,9 @@ type State struct {
 
 	cache map[interface{}]interface{}
 
+	eventHandlers      map[EventHandlerId]func(ev *Event)
+	lastEventHandlerId EventHandlerId
+
 	restarting RestartType
 	restartLck sync.Mutex
 	bootID     string
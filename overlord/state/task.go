@@ -200,14 +200,30 @@ func (t *Task) Status() Status {
 func (t *Task) SetStatus(new Status) {
 	t.state.writing()
 	old := t.status
+	chg := t.Change()
+	notify := t.state.hasEventHandlers()
+	var oldChgStatus Status
+	if notify && chg != nil {
+		oldChgStatus = chg.Status()
+	}
 	t.status = new
 	if !old.Ready() && new.Ready() {
 		t.readyTime = timeNow()
 	}
-	chg := t.Change()
 	if chg != nil {
 		chg.taskStatusChanged(t, old, new)
 	}
+	if !notify {
+		return
+	}
+	if new != old {
+		t.state.notify(&Event{Kind: TaskStatusEvent, Change: chg, Task: t, Status: new})
+	}
+	if chg != nil {
+		if newChgStatus := chg.Status(); newChgStatus != oldChgStatus {
+			t.state.notify(&Event{Kind: ChangeStatusEvent, Change: chg, Status: newChgStatus})
+		}
+	}
 }
 
 // IsClean returns whether the task has been cleaned. See SetClean.
@@ -270,6 +286,9 @@ func (t *Task) SetProgress(label string, done, total int) {
 	} else {
 		t.progress = &progress{Label: label, Done: done, Total: total}
 	}
+	if t.state.hasEventHandlers() {
+		t.state.notify(&Event{Kind: TaskProgressEvent, Change: t.Change(), Task: t})
+	}
 }
 
 // SpawnTime returns the time when the change was created.
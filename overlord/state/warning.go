@@ -204,6 +204,9 @@ func (s *State) addWarning(w Warning, t time.Time) {
 		s.warnings[w.message] = &w
 	}
 	s.warnings[w.message].lastAdded = t
+	if s.hasEventHandlers() {
+		s.notify(&Event{Kind: WarningEvent, Message: w.message})
+	}
 }
 
 type byLastAdded []*Warning
@@ -0,0 +1,264 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/metautil"
+)
+
+// The types of configuration options that can be declared in the
+// config-schema section of snap.yaml.
+const (
+	ConfigTypeString = "string"
+	ConfigTypeInt    = "int"
+	ConfigTypeNumber = "number"
+	ConfigTypeBool   = "bool"
+	ConfigTypeArray  = "array"
+	ConfigTypeObject = "object"
+)
+
+// ConfigOption describes a configuration option of a snap.
+type ConfigOption struct {
+	Type        string        `yaml:"type" json:"type"`
+	Description string        `yaml:"description,omitempty" json:"description,omitempty"`
+	Enum        []interface{} `yaml:"enum,omitempty" json:"enum,omitempty"`
+	// Min and Max bound the values of int and number options
+	Min     *float64    `yaml:"min,omitempty" json:"min,omitempty"`
+	Max     *float64    `yaml:"max,omitempty" json:"max,omitempty"`
+	Default interface{} `yaml:"default,omitempty" json:"default,omitempty"`
+	// Required options must have a value, unless they have a default
+	Required bool `yaml:"required,omitempty" json:"required,omitempty"`
+	// Secret options hold values like passwords or tokens
+	Secret bool `yaml:"secret,omitempty" json:"secret,omitempty"`
+}
+
+// ConfigSchema describes the configuration options of a snap, by their
+// dotted path (e.g. "server.port"). Options that are not described are not
+// constrained.
+type ConfigSchema map[string]*ConfigOption
+
+// Keys returns the options of the schema, sorted.
+func (s ConfigSchema) Keys() []string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Defaults returns the default values of the options of the schema that
+// have one.
+func (s ConfigSchema) Defaults() map[string]interface{} {
+	defaults := make(map[string]interface{})
+	for key, opt := range s {
+		if opt.Default != nil {
+			defaults[key] = opt.Default
+		}
+	}
+	return defaults
+}
+
+// ValidateValue checks that the value of the given option, as read from
+// the configuration, matches the schema.
+func (s ConfigSchema) ValidateValue(key string, value interface{}) error {
+	opt := s[key]
+	if opt == nil {
+		return nil
+	}
+	if err := opt.validateValue(value); err != nil {
+		return fmt.Errorf("invalid value for option %q: %v", key, err)
+	}
+	return nil
+}
+
+// configNumber returns the given value as a number, and whether it is an
+// integer.
+func configNumber(value interface{}) (f float64, isInt bool, ok bool) {
+	switch v := value.(type) {
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			f, err := v.Float64()
+			return f, true, err == nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, false, false
+		}
+		return f, f == math.Trunc(f), true
+	case int:
+		return float64(v), true, true
+	case int64:
+		return float64(v), true, true
+	case float64:
+		return v, v == math.Trunc(v), true
+	}
+	return 0, false, false
+}
+
+func configTypeOf(value interface{}) string {
+	if _, isInt, ok := configNumber(value); ok {
+		if isInt {
+			return ConfigTypeInt
+		}
+		return ConfigTypeNumber
+	}
+	switch value.(type) {
+	case string:
+		return ConfigTypeString
+	case bool:
+		return ConfigTypeBool
+	case []interface{}:
+		return ConfigTypeArray
+	case map[string]interface{}:
+		return ConfigTypeObject
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func sameConfigValue(a, b interface{}) bool {
+	if fa, _, ok := configNumber(a); ok {
+		fb, _, ok := configNumber(b)
+		return ok && fa == fb
+	}
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(ja) == string(jb)
+}
+
+func (opt *ConfigOption) validateValue(value interface{}) error {
+	typ := configTypeOf(value)
+	// integers are numbers too
+	if typ != opt.Type && !(typ == ConfigTypeInt && opt.Type == ConfigTypeNumber) {
+		return fmt.Errorf("expected %s, got %s", opt.Type, typ)
+	}
+	if f, _, ok := configNumber(value); ok {
+		if opt.Min != nil && f < *opt.Min {
+			return fmt.Errorf("must be at least %v", *opt.Min)
+		}
+		if opt.Max != nil && f > *opt.Max {
+			return fmt.Errorf("must be at most %v", *opt.Max)
+		}
+	}
+	if len(opt.Enum) > 0 {
+		for _, allowed := range opt.Enum {
+			if sameConfigValue(value, allowed) {
+				return nil
+			}
+		}
+		allowed := make([]string, len(opt.Enum))
+		for i, v := range opt.Enum {
+			data, _ := json.Marshal(v)
+			allowed[i] = string(data)
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// normalize turns the values of the option decoded from YAML into the
+// types the configuration uses.
+func (opt *ConfigOption) normalize() error {
+	if opt.Default != nil {
+		v, err := metautil.NormalizeValue(opt.Default)
+		if err != nil {
+			return fmt.Errorf("invalid default: %v", err)
+		}
+		opt.Default = v
+	}
+	for i, allowed := range opt.Enum {
+		v, err := metautil.NormalizeValue(allowed)
+		if err != nil {
+			return fmt.Errorf("invalid enum value: %v", err)
+		}
+		opt.Enum[i] = v
+	}
+	return nil
+}
+
+// validConfigSubkey matches the parts of the option names accepted by
+// configstate.
+var validConfigSubkey = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+
+func validateConfigOption(key string, opt *ConfigOption) error {
+	for _, subkey := range strings.Split(key, ".") {
+		if !validConfigSubkey.MatchString(subkey) {
+			return fmt.Errorf("invalid option name: %q", key)
+		}
+	}
+	if opt == nil {
+		return fmt.Errorf("option %q has no definition", key)
+	}
+	switch opt.Type {
+	case ConfigTypeString, ConfigTypeInt, ConfigTypeNumber, ConfigTypeBool, ConfigTypeArray, ConfigTypeObject:
+	case "":
+		return fmt.Errorf("option %q has no type", key)
+	default:
+		return fmt.Errorf("option %q has unknown type %q", key, opt.Type)
+	}
+	if opt.Min != nil || opt.Max != nil {
+		if opt.Type != ConfigTypeInt && opt.Type != ConfigTypeNumber {
+			return fmt.Errorf("option %q of type %s cannot have a minimum or maximum", key, opt.Type)
+		}
+		if opt.Min != nil && opt.Max != nil && *opt.Min > *opt.Max {
+			return fmt.Errorf("option %q has a minimum greater than its maximum", key)
+		}
+	}
+	if opt.Secret && opt.Type != ConfigTypeString {
+		return fmt.Errorf("option %q of type %s cannot be secret", key, opt.Type)
+	}
+	for _, allowed := range opt.Enum {
+		check := *opt
+		check.Enum = nil
+		if err := check.validateValue(allowed); err != nil {
+			return fmt.Errorf("invalid enum value of option %q: %v", key, err)
+		}
+	}
+	if opt.Default != nil {
+		if err := opt.validateValue(opt.Default); err != nil {
+			return fmt.Errorf("invalid default of option %q: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// ValidateConfigSchema checks that the configuration schema of the snap is
+// consistent.
+func ValidateConfigSchema(info *Info) error {
+	for _, key := range info.ConfigSchema.Keys() {
+		if err := validateConfigOption(key, info.ConfigSchema[key]); err != nil {
+			return fmt.Errorf("invalid config-schema: %v", err)
+		}
+	}
+	return nil
+}
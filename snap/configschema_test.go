@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type configSchemaSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&configSchemaSuite{})
+
+func (s *configSchemaSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.BaseTest.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+}
+
+func (s *configSchemaSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+const configSchemaYaml = `name: foo
+version: 1
+config-schema:
+  server.port:
+    type: int
+    min: 1
+    max: 65535
+    default: 8080
+  mode:
+    type: string
+    enum: [fast, slow]
+    required: true
+  ratio:
+    type: number
+    max: 1
+  token:
+    type: string
+    secret: true
+  opts:
+    type: object
+    default:
+      a: 1
+`
+
+func (s *configSchemaSuite) TestParse(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(configSchemaYaml))
+	c.Assert(err, IsNil)
+	c.Assert(snap.Validate(info), IsNil)
+
+	schema := info.ConfigSchema
+	c.Check(schema.Keys(), DeepEquals, []string{"mode", "opts", "ratio", "server.port", "token"})
+	c.Check(schema["server.port"].Type, Equals, "int")
+	c.Check(*schema["server.port"].Min, Equals, 1.0)
+	c.Check(*schema["server.port"].Max, Equals, 65535.0)
+	c.Check(schema["mode"].Enum, DeepEquals, []interface{}{"fast", "slow"})
+	c.Check(schema["mode"].Required, Equals, true)
+	c.Check(schema["token"].Secret, Equals, true)
+	c.Check(schema.Defaults(), DeepEquals, map[string]interface{}{
+		"server.port": int64(8080),
+		"opts":        map[string]interface{}{"a": int64(1)},
+	})
+}
+
+func (s *configSchemaSuite) TestValidateValue(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(configSchemaYaml))
+	c.Assert(err, IsNil)
+	schema := info.ConfigSchema
+
+	for _, t := range []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"server.port", json.Number("80"), ""},
+		{"server.port", int64(65535), ""},
+		{"server.port", json.Number("0"), `invalid value for option "server.port": must be at least 1`},
+		{"server.port", json.Number("70000"), `invalid value for option "server.port": must be at most 65535`},
+		{"server.port", json.Number("1.5"), `invalid value for option "server.port": expected int, got number`},
+		{"server.port", "80", `invalid value for option "server.port": expected int, got string`},
+		{"mode", "fast", ""},
+		{"mode", "medium", `invalid value for option "mode": must be one of "fast", "slow"`},
+		{"ratio", json.Number("0.5"), ""},
+		{"ratio", json.Number("1"), ""},
+		{"ratio", json.Number("1.5"), `invalid value for option "ratio": must be at most 1`},
+		{"opts", map[string]interface{}{}, ""},
+		{"opts", []interface{}{}, `invalid value for option "opts": expected object, got array`},
+		{"unknown", true, ""},
+	} {
+		err := schema.ValidateValue(t.key, t.value)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("%s=%v", t.key, t.value))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("%s=%v", t.key, t.value))
+		}
+	}
+}
+
+func (s *configSchemaSuite) TestValidateSchemaErrors(c *C) {
+	for _, t := range []struct {
+		schema string
+		err    string
+	}{
+		{"Foo:\n    type: int", `invalid config-schema: invalid option name: "Foo"`},
+		{"foo:\n    default: 1", `invalid config-schema: option "foo" has no type`},
+		{"foo:\n    type: float", `invalid config-schema: option "foo" has unknown type "float"`},
+		{"foo:\n    type: string\n    min: 1", `invalid config-schema: option "foo" of type string cannot have a minimum or maximum`},
+		{"foo:\n    type: int\n    min: 2\n    max: 1", `invalid config-schema: option "foo" has a minimum greater than its maximum`},
+		{"foo:\n    type: int\n    secret: true", `invalid config-schema: option "foo" of type int cannot be secret`},
+		{"foo:\n    type: int\n    enum: [1, a]", `invalid config-schema: invalid enum value of option "foo": expected int, got string`},
+		{"foo:\n    type: int\n    max: 10\n    default: 11", `invalid config-schema: invalid default of option "foo": must be at most 10`},
+	} {
+		info, err := snap.InfoFromSnapYaml([]byte("name: foo\nversion: 1\nconfig-schema:\n  " + t.schema + "\n"))
+		c.Assert(err, IsNil, Commentf(t.schema))
+		c.Check(snap.Validate(info), ErrorMatches, t.err, Commentf(t.schema))
+	}
+}
@@ -38,6 +38,7 @@ var supportedHooks = []*HookType{
 	NewHookType(regexp.MustCompile("^check-health$")),
 	NewHookType(regexp.MustCompile("^fde-setup$")),
 	NewHookType(regexp.MustCompile("^gate-auto-refresh$")),
+	NewHookType(regexp.MustCompile("^view-changed-[-a-z0-9]+$")),
 }
 
 // HookType represents a pattern of supported hook names.
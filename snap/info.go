@@ -311,6 +311,9 @@ type Info struct {
 	// List of system users (usernames) this snap may use. The group of the same
 	// name must also exist.
 	SystemUsernames map[string]*SystemUsernameInfo
+
+	// ConfigSchema describes the configuration options of the snap.
+	ConfigSchema ConfigSchema
 }
 
 // StoreAccount holds information about a store account, for example of snap
@@ -926,6 +929,10 @@ type HookInfo struct {
 	Environment  strutil.OrderedMap
 	CommandChain []string
 
+	// Interval is how often the hook is run periodically by snapd, only
+	// supported by check-health
+	Interval timeout.Timeout
+
 	Explicit bool
 }
 
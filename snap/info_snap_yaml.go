@@ -53,6 +53,7 @@ type snapYaml struct {
 	Hooks           map[string]hookYaml    `yaml:"hooks,omitempty"`
 	Layout          map[string]layoutYaml  `yaml:"layout,omitempty"`
 	SystemUsernames map[string]interface{} `yaml:"system-usernames,omitempty"`
+	ConfigSchema    ConfigSchema           `yaml:"config-schema,omitempty"`
 
 	// TypoLayouts is used to detect the use of the incorrect plural form of "layout"
 	TypoLayouts typoDetector `yaml:"layouts,omitempty"`
@@ -112,6 +113,7 @@ type hookYaml struct {
 	SlotNames    []string           `yaml:"slots,omitempty"`
 	Environment  strutil.OrderedMap `yaml:"environment,omitempty"`
 	CommandChain []string           `yaml:"command-chain,omitempty"`
+	Interval     timeout.Timeout    `yaml:"interval,omitempty"`
 }
 
 type layoutYaml struct {
@@ -232,6 +234,11 @@ func infoFromSnapYaml(yamlData []byte, strk *scopedTracker) (*Info, error) {
 		return nil, err
 	}
 
+	// Collect the configuration schema
+	if err := setConfigSchemaFromSnapYaml(y, snap); err != nil {
+		return nil, err
+	}
+
 	// FIXME: validation of the fields
 	return snap, nil
 }
@@ -474,6 +481,7 @@ func setHooksFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) {
 			Name:         hookName,
 			Environment:  yHook.Environment,
 			CommandChain: yHook.CommandChain,
+			Interval:     yHook.Interval,
 			Explicit:     true,
 		}
 		if len(y.Plugs) > 0 || len(yHook.PlugNames) > 0 {
@@ -550,6 +558,22 @@ func setSystemUsernamesFromSnapYaml(y snapYaml, snap *Info) error {
 	return nil
 }
 
+func setConfigSchemaFromSnapYaml(y snapYaml, snap *Info) error {
+	if len(y.ConfigSchema) == 0 {
+		return nil
+	}
+	for key, opt := range y.ConfigSchema {
+		if opt == nil {
+			continue
+		}
+		if err := opt.normalize(); err != nil {
+			return fmt.Errorf("invalid config-schema option %q: %v", key, err)
+		}
+	}
+	snap.ConfigSchema = y.ConfigSchema
+	return nil
+}
+
 func bindUnscopedPlugs(snap *Info, strk *scopedTracker) {
 	for plugName, plug := range snap.Plugs {
 		if strk.plug(plug) {
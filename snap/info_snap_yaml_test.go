@@ -833,6 +833,19 @@ hooks:
 	})
 }
 
+func (s *YamlSuite) TestUnmarshalHookWithInterval(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+hooks:
+    check-health:
+        interval: 15m
+`))
+	c.Assert(err, IsNil)
+	c.Assert(info.Hooks, HasLen, 1)
+	c.Check(info.Hooks["check-health"].Interval, Equals, timeout.Timeout(15*time.Minute))
+}
+
 func (s *YamlSuite) TestUnmarshalCorruptedSlotWithNonStringInterfaceName(c *C) {
 	// NOTE: yaml content cannot use tabs, indent the section with spaces.
 	_, err := snap.InfoFromSnapYaml([]byte(`
@@ -25,6 +25,7 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"time"
 
 	// TODO: move this to snap/quantity? or similar
 	"github.com/snapcore/snapd/gadget/quantity"
@@ -34,8 +35,9 @@ import (
 )
 
 // Group is a quota group of snaps, services or sub-groups that are all subject
-// to specific resource quotas. The only quota resource types currently
-// supported is memory, but this can be expanded in the future.
+// to specific resource quotas. The quota resource types currently supported
+// are memory, CPU (including the set of allowed CPUs), threads and journal
+// namespaces.
 type Group struct {
 	// Name is the name of the quota group. This name is used the
 	// name of the systemd slice underlying the quota group.
@@ -59,6 +61,19 @@ type Group struct {
 	// ExhaustionBehavior. MemoryLimit is expressed in bytes.
 	MemoryLimit quantity.Size `json:"memory-limit,omitempty"`
 
+	// CPULimit is the quota for CPU time and CPU affinity of the processes in
+	// the group. If nil, there is no CPU quota for the group.
+	CPULimit *GroupQuotaCPU `json:"cpu-limit,omitempty"`
+
+	// ThreadLimit is the maximum number of threads (tasks) that the processes
+	// in the group can have in total. If zero, there is no thread limit.
+	ThreadLimit int `json:"thread-limit,omitempty"`
+
+	// JournalLimit is the quota for the journal namespace of the group. If
+	// set, the services in the group log to their own journal namespace
+	// instead of the system journal. If nil, there is no journal quota.
+	JournalLimit *GroupQuotaJournal `json:"journal-limit,omitempty"`
+
 	// ParentGroup is the the parent group that this group is a child of. If it
 	// is empty, then this is a "root" quota group.
 	ParentGroup string `json:"parent-group,omitempty"`
@@ -72,12 +87,121 @@ type Group struct {
 	Snaps []string `json:"snaps,omitempty"`
 }
 
-// NewGroup creates a new top quota group with the given name and memory limit.
-func NewGroup(name string, memLimit quantity.Size) (*Group, error) {
+// GroupQuotaCPU contains the CPU quota settings of a quota group.
+type GroupQuotaCPU struct {
+	// Count is the number of CPUs the percentage applies to. If zero, the
+	// percentage applies to a single CPU.
+	Count int `json:"count,omitempty"`
+
+	// Percentage is the percentage of CPU time of a single CPU that the group
+	// is allowed to use, per CPU in Count. If zero, CPU time is not limited.
+	Percentage int `json:"percentage,omitempty"`
+
+	// AllowedCPUs is the set of CPUs the processes in the group are allowed
+	// to run on. If empty, the processes may run on any CPU.
+	AllowedCPUs []int `json:"allowed-cpus,omitempty"`
+}
+
+// TotalPercentage returns the total CPU time allowed for the group expressed
+// as a percentage of a single CPU, i.e. 2 CPUs at 50% is 100%.
+func (cpu *GroupQuotaCPU) TotalPercentage() int {
+	count := cpu.Count
+	if count == 0 {
+		count = 1
+	}
+	return count * cpu.Percentage
+}
+
+// GroupQuotaJournal contains the journal namespace quota settings of a quota
+// group.
+type GroupQuotaJournal struct {
+	// Size is the maximum disk space the journal namespace can use. If zero,
+	// the journald defaults apply.
+	Size quantity.Size `json:"size,omitempty"`
+
+	// RateCount is the number of messages that can be logged in RatePeriod
+	// before messages are dropped. If zero, the journald defaults apply.
+	RateCount int `json:"rate-count,omitempty"`
+
+	// RatePeriod is the period of time over which RateCount is applied.
+	RatePeriod time.Duration `json:"rate-period,omitempty"`
+}
+
+// Resources are the resource limits of a quota group. When used to update an
+// existing group, unset (zero) limits are left unchanged.
+type Resources struct {
+	Memory  quantity.Size      `json:"memory,omitempty"`
+	CPU     *GroupQuotaCPU     `json:"cpu,omitempty"`
+	Threads int                `json:"threads,omitempty"`
+	Journal *GroupQuotaJournal `json:"journal,omitempty"`
+}
+
+// maxJournalSize is the maximum size of a journal namespace, this is the same
+// maximum as journald itself enforces for SystemMaxUse.
+var maxJournalSize = 4 * quantity.SizeGiB
+
+// Validate checks that the resource limits are consistent. At least one limit
+// must be set.
+func (r Resources) Validate() error {
+	if r.Memory == 0 && r.CPU == nil && r.Threads == 0 && r.Journal == nil {
+		return fmt.Errorf("quota group must have at least one resource limit set")
+	}
+
+	if r.CPU != nil {
+		if r.CPU.Count < 0 {
+			return fmt.Errorf("invalid CPU count %d: must not be negative", r.CPU.Count)
+		}
+		switch {
+		case r.CPU.Percentage == 0:
+			// no percentage means no limit on CPU time, which is only
+			// meaningful when the group is restricted to a set of CPUs
+			if r.CPU.Count != 0 {
+				return fmt.Errorf("cannot use a CPU count without a CPU percentage")
+			}
+			if len(r.CPU.AllowedCPUs) == 0 {
+				return fmt.Errorf("CPU quota must have either a percentage or a set of allowed CPUs")
+			}
+		case r.CPU.Percentage < 1 || r.CPU.Percentage > 100:
+			return fmt.Errorf("invalid CPU percentage %d: must be between 1 and 100", r.CPU.Percentage)
+		}
+		seen := make(map[int]bool, len(r.CPU.AllowedCPUs))
+		for _, cpu := range r.CPU.AllowedCPUs {
+			if cpu < 0 {
+				return fmt.Errorf("invalid CPU number %d: must not be negative", cpu)
+			}
+			if seen[cpu] {
+				return fmt.Errorf("CPU number %d is listed more than once", cpu)
+			}
+			seen[cpu] = true
+		}
+	}
+
+	if r.Threads < 0 {
+		return fmt.Errorf("invalid thread limit %d: must not be negative", r.Threads)
+	}
+
+	if r.Journal != nil {
+		if r.Journal.Size > maxJournalSize {
+			return fmt.Errorf("journal size %s is too large: size must be at most %s", r.Journal.Size.IECString(), maxJournalSize.IECString())
+		}
+		if r.Journal.RateCount < 0 || r.Journal.RatePeriod < 0 {
+			return fmt.Errorf("journal rate limit must not be negative")
+		}
+		if (r.Journal.RateCount == 0) != (r.Journal.RatePeriod == 0) {
+			return fmt.Errorf("journal rate limit must have both a count and a period")
+		}
+	}
+
+	return nil
+}
+
+// NewGroup creates a new top quota group with the given name and resource
+// limits.
+func NewGroup(name string, resourceLimits Resources) (*Group, error) {
 	grp := &Group{
-		Name:        name,
-		MemoryLimit: memLimit,
+		Name: name,
 	}
+	grp.setQuotaResources(resourceLimits)
 
 	if err := grp.validate(); err != nil {
 		return nil, err
@@ -86,6 +210,94 @@ func NewGroup(name string, memLimit quantity.Size) (*Group, error) {
 	return grp, nil
 }
 
+// GetQuotaResources returns the resource limits of the group.
+func (grp *Group) GetQuotaResources() Resources {
+	return Resources{
+		Memory:  grp.MemoryLimit,
+		CPU:     grp.CPULimit,
+		Threads: grp.ThreadLimit,
+		Journal: grp.JournalLimit,
+	}
+}
+
+func (grp *Group) setQuotaResources(resourceLimits Resources) {
+	grp.MemoryLimit = resourceLimits.Memory
+	grp.CPULimit = resourceLimits.CPU
+	grp.ThreadLimit = resourceLimits.Threads
+	grp.JournalLimit = resourceLimits.Journal
+}
+
+// mergeCPULimits returns a copy of the current CPU limits with the set
+// (non-zero) limits of update applied on top. The count and percentage always
+// go together.
+func mergeCPULimits(current, update *GroupQuotaCPU) *GroupQuotaCPU {
+	var merged GroupQuotaCPU
+	if current != nil {
+		merged = *current
+	}
+	if update.Percentage != 0 {
+		merged.Count = update.Count
+		merged.Percentage = update.Percentage
+	}
+	if len(update.AllowedCPUs) != 0 {
+		merged.AllowedCPUs = update.AllowedCPUs
+	}
+	return &merged
+}
+
+// mergeJournalLimits returns a copy of the current journal limits with the set
+// (non-zero) limits of update applied on top. The rate count and period always
+// go together.
+func mergeJournalLimits(current, update *GroupQuotaJournal) *GroupQuotaJournal {
+	var merged GroupQuotaJournal
+	if current != nil {
+		merged = *current
+	}
+	if update.Size != 0 {
+		merged.Size = update.Size
+	}
+	if update.RateCount != 0 || update.RatePeriod != 0 {
+		merged.RateCount = update.RateCount
+		merged.RatePeriod = update.RatePeriod
+	}
+	return &merged
+}
+
+// UpdateQuotaLimits updates the resource limits of the group with the set
+// (non-zero) limits in resourceLimits. The group is validated with the new
+// limits, if the validation fails the group is left unchanged.
+func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
+	current := grp.GetQuotaResources()
+	if resourceLimits.Memory != 0 {
+		current.Memory = resourceLimits.Memory
+	}
+	if resourceLimits.CPU != nil {
+		current.CPU = mergeCPULimits(current.CPU, resourceLimits.CPU)
+	}
+	if resourceLimits.Threads != 0 {
+		current.Threads = resourceLimits.Threads
+	}
+	if resourceLimits.Journal != nil {
+		current.Journal = mergeJournalLimits(current.Journal, resourceLimits.Journal)
+	}
+
+	old := grp.GetQuotaResources()
+	grp.setQuotaResources(current)
+	if err := grp.validate(); err != nil {
+		grp.setQuotaResources(old)
+		return err
+	}
+
+	// the existing sub-groups must still fit into the new limits
+	for _, sub := range grp.subGroups {
+		if err := sub.validateFitsInParent(); err != nil {
+			grp.setQuotaResources(old)
+			return err
+		}
+	}
+	return nil
+}
+
 // special value that systemd sometimes returns in buggy situations
 const sixteenExb = quantity.Size(1<<64 - 1)
 
@@ -136,6 +348,27 @@ func (grp *Group) CurrentMemoryUsage() (quantity.Size, error) {
 	return mem, nil
 }
 
+// CurrentTaskUsage returns the current number of tasks (threads) in the quota
+// group. For quota groups which do not yet have a backing systemd slice on the
+// system, the number of tasks is reported as 0.
+func (grp *Group) CurrentTaskUsage() (int, error) {
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+
+	isActive, err := sysd.IsActive(grp.SliceFileName())
+	if err != nil {
+		return 0, err
+	}
+	if !isActive {
+		return 0, nil
+	}
+
+	count, err := sysd.CurrentTasksCount(grp.SliceFileName())
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
 // SliceFileName returns the name of the slice file that should be used for this
 // quota group. This name will include all of the group's parents in the name.
 // For example, a group named "bar" that is a child of the "foo" group will have
@@ -177,13 +410,19 @@ func (grp *Group) validate() error {
 		return fmt.Errorf("group name %q reserved", grp.Name)
 	}
 
-	if grp.MemoryLimit == 0 {
-		return fmt.Errorf("group memory limit must be non-zero")
+	if err := grp.GetQuotaResources().Validate(); err != nil {
+		return err
 	}
 
 	// TODO: probably there is a minimum amount of bytes here that is
 	// technically usable/enforcable, should we check that too?
 
+	// journal namespaces cannot be nested, the services of sub-groups log to
+	// the journal namespace of their parent instead
+	if grp.ParentGroup != "" && grp.JournalLimit != nil {
+		return fmt.Errorf("journal quota is not supported for sub-groups")
+	}
+
 	if grp.ParentGroup != "" && grp.Name == grp.ParentGroup {
 		return fmt.Errorf("group has circular parent reference to itself")
 	}
@@ -200,34 +439,109 @@ func (grp *Group) validate() error {
 	// to accommodate this new group (we assume that other existing sub-groups
 	// in the parent group have already been validated)
 	if grp.parentGroup != nil {
-		alreadyUsed := quantity.Size(0)
-		for _, child := range grp.parentGroup.subGroups {
-			if child.Name == grp.Name {
-				continue
-			}
-			alreadyUsed += child.MemoryLimit
+		if err := grp.validateFitsInParent(); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
+
+// validateFitsInParent checks that the resource limits of the group fit into
+// the remaining quota of its parent group, i.e. what the parent group's limits
+// leave after accounting for the other sub-groups of the parent. A parent
+// without a limit for a given resource does not constrain its sub-groups for
+// that resource.
+func (grp *Group) validateFitsInParent() error {
+	parent := grp.parentGroup
+
+	var usedMemory quantity.Size
+	var usedCPU, usedThreads int
+	for _, child := range parent.subGroups {
+		if child.Name == grp.Name {
+			continue
+		}
+		usedMemory += child.MemoryLimit
+		if child.CPULimit != nil {
+			usedCPU += child.CPULimit.TotalPercentage()
+		}
+		usedThreads += child.ThreadLimit
+	}
+
+	if parent.MemoryLimit != 0 && grp.MemoryLimit != 0 {
 		// careful arithmetic here in case we somehow overflow the max size of
 		// quantity.Size
-		if grp.parentGroup.MemoryLimit-alreadyUsed < grp.MemoryLimit {
-			remaining := grp.parentGroup.MemoryLimit - alreadyUsed
-			return fmt.Errorf("sub-group memory limit of %s is too large to fit inside remaining quota space %s for parent group %s", grp.MemoryLimit.IECString(), remaining.IECString(), grp.parentGroup.Name)
+		if parent.MemoryLimit-usedMemory < grp.MemoryLimit {
+			remaining := parent.MemoryLimit - usedMemory
+			return fmt.Errorf("sub-group memory limit of %s is too large to fit inside remaining quota space %s for parent group %s", grp.MemoryLimit.IECString(), remaining.IECString(), parent.Name)
+		}
+	}
+
+	if parent.CPULimit != nil && grp.CPULimit != nil {
+		if parent.CPULimit.Percentage != 0 && grp.CPULimit.Percentage != 0 {
+			remaining := parent.CPULimit.TotalPercentage() - usedCPU
+			if remaining < grp.CPULimit.TotalPercentage() {
+				return fmt.Errorf("sub-group CPU limit of %d%% is too large to fit inside remaining quota space %d%% for parent group %s", grp.CPULimit.TotalPercentage(), remaining, parent.Name)
+			}
+		}
+		if len(parent.CPULimit.AllowedCPUs) != 0 {
+			for _, cpu := range grp.CPULimit.AllowedCPUs {
+				if !intListContains(parent.CPULimit.AllowedCPUs, cpu) {
+					return fmt.Errorf("sub-group allowed CPU %d is not in the allowed CPUs of parent group %s", cpu, parent.Name)
+				}
+			}
+		}
+	}
+
+	if parent.ThreadLimit != 0 && grp.ThreadLimit != 0 {
+		remaining := parent.ThreadLimit - usedThreads
+		if remaining < grp.ThreadLimit {
+			return fmt.Errorf("sub-group thread limit of %d is too large to fit inside remaining quota space %d for parent group %s", grp.ThreadLimit, remaining, parent.Name)
 		}
 	}
 
 	return nil
 }
 
+func intListContains(list []int, v int) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// JournalNamespaceName returns the name of the journal namespace the services
+// of the group log to, or an empty string if the services of the group log to
+// the system journal. Sub-groups use the journal namespace of their closest
+// ancestor with a journal quota.
+func (grp *Group) JournalNamespaceName() string {
+	for g := grp; g != nil; g = g.parentGroup {
+		if g.JournalLimit != nil {
+			return fmt.Sprintf("snap-%s", g.Name)
+		}
+	}
+	return ""
+}
+
+// JournalConfFileName returns the name of the journald configuration file for
+// the journal namespace of the group. It is only meaningful for groups with a
+// journal quota.
+func (grp *Group) JournalConfFileName() string {
+	return fmt.Sprintf("journald@snap-%s.conf", grp.Name)
+}
+
 // NewSubGroup creates a new sub group under the current group.
-func (grp *Group) NewSubGroup(name string, memLimit quantity.Size) (*Group, error) {
+func (grp *Group) NewSubGroup(name string, resourceLimits Resources) (*Group, error) {
 	// TODO: implement a maximum sub-group depth
 
 	subGrp := &Group{
 		Name:        name,
-		MemoryLimit: memLimit,
 		ParentGroup: grp.Name,
 		parentGroup: grp,
 	}
+	subGrp.setQuotaResources(resourceLimits)
 
 	// check early that the sub group name is not the same as that of the
 	// parent, this is fine in systemd world, but in snapd we want unique quota
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"math"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -60,7 +61,7 @@ func (ts *quotaTestSuite) TestNewGroup(c *C) {
 		{
 			name:    "zero",
 			limit:   0,
-			err:     `group memory limit must be non-zero`,
+			err:     `quota group must have at least one resource limit set`,
 			comment: "group with zero memory limit",
 		},
 		{
@@ -132,7 +133,7 @@ func (ts *quotaTestSuite) TestNewGroup(c *C) {
 
 	for _, t := range tt {
 		comment := Commentf(t.comment)
-		grp, err := quota.NewGroup(t.name, t.limit)
+		grp, err := quota.NewGroup(t.name, quota.Resources{Memory: t.limit})
 		if t.err != "" {
 			c.Assert(err, ErrorMatches, t.err, comment)
 			continue
@@ -216,7 +217,7 @@ func (ts *quotaTestSuite) TestSimpleSubGroupVerification(c *C) {
 			rootlimit: quantity.SizeMiB,
 			subname:   "zero",
 			sublimit:  0,
-			err:       `group memory limit must be non-zero`,
+			err:       `quota group must have at least one resource limit set`,
 			comment:   "sub group with zero memory limit",
 		},
 	}
@@ -228,11 +229,11 @@ func (ts *quotaTestSuite) TestSimpleSubGroupVerification(c *C) {
 		if rootname == "" {
 			rootname = "myroot"
 		}
-		rootGrp, err := quota.NewGroup(rootname, t.rootlimit)
+		rootGrp, err := quota.NewGroup(rootname, quota.Resources{Memory: t.rootlimit})
 		c.Assert(err, IsNil, comment)
 
 		// make a sub-group under the root group
-		subGrp, err := rootGrp.NewSubGroup(t.subname, t.sublimit)
+		subGrp, err := rootGrp.NewSubGroup(t.subname, quota.Resources{Memory: t.sublimit})
 		if t.err != "" {
 			c.Assert(err, ErrorMatches, t.err, comment)
 			continue
@@ -248,30 +249,30 @@ func (ts *quotaTestSuite) TestSimpleSubGroupVerification(c *C) {
 }
 
 func (ts *quotaTestSuite) TestComplexSubGroups(c *C) {
-	rootGrp, err := quota.NewGroup("myroot", quantity.SizeMiB)
+	rootGrp, err := quota.NewGroup("myroot", quota.Resources{Memory: quantity.SizeMiB})
 	c.Assert(err, IsNil)
 
 	// try adding 2 sub-groups with total quota split exactly equally
-	sub1, err := rootGrp.NewSubGroup("sub1", quantity.SizeMiB/2)
+	sub1, err := rootGrp.NewSubGroup("sub1", quota.Resources{Memory: quantity.SizeMiB / 2})
 	c.Assert(err, IsNil)
 	c.Assert(sub1.SliceFileName(), Equals, "snap.myroot-sub1.slice")
 
-	sub2, err := rootGrp.NewSubGroup("sub2", quantity.SizeMiB/2)
+	sub2, err := rootGrp.NewSubGroup("sub2", quota.Resources{Memory: quantity.SizeMiB / 2})
 	c.Assert(err, IsNil)
 	c.Assert(sub2.SliceFileName(), Equals, "snap.myroot-sub2.slice")
 
 	// adding another sub-group to this group fails
-	_, err = rootGrp.NewSubGroup("sub3", 1)
+	_, err = rootGrp.NewSubGroup("sub3", quota.Resources{Memory: 1})
 	c.Assert(err, ErrorMatches, "sub-group memory limit of 1 B is too large to fit inside remaining quota space 0 B for parent group myroot")
 
 	// we can however add a sub-group to one of the sub-groups with the exact
 	// size of the parent sub-group
-	subsub1, err := sub1.NewSubGroup("subsub1", quantity.SizeMiB/2)
+	subsub1, err := sub1.NewSubGroup("subsub1", quota.Resources{Memory: quantity.SizeMiB / 2})
 	c.Assert(err, IsNil)
 	c.Assert(subsub1.SliceFileName(), Equals, "snap.myroot-sub1-subsub1.slice")
 
 	// and we can even add a smaller sub-sub-sub-group to the sub-group
-	subsubsub1, err := subsub1.NewSubGroup("subsubsub1", quantity.SizeMiB/4)
+	subsubsub1, err := subsub1.NewSubGroup("subsubsub1", quota.Resources{Memory: quantity.SizeMiB / 4})
 	c.Assert(err, IsNil)
 	c.Assert(subsubsub1.SliceFileName(), Equals, "snap.myroot-sub1-subsub1-subsubsub1.slice")
 }
@@ -320,7 +321,7 @@ func (ts *quotaTestSuite) TestResolveCrossReferences(c *C) {
 					MemoryLimit: 0,
 				},
 			},
-			err:     `group "foogroup" is invalid: group memory limit must be non-zero`,
+			err:     `group "foogroup" is invalid: quota group must have at least one resource limit set`,
 			comment: "invalid group",
 		},
 		{
@@ -490,10 +491,10 @@ func (ts *quotaTestSuite) TestResolveCrossReferences(c *C) {
 }
 
 func (ts *quotaTestSuite) TestAddAllNecessaryGroupsAvoidsInfiniteRecursion(c *C) {
-	grp, err := quota.NewGroup("infinite-group", quantity.SizeGiB)
+	grp, err := quota.NewGroup("infinite-group", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
-	grp2, err := grp.NewSubGroup("infinite-group2", quantity.SizeGiB)
+	grp2, err := grp.NewSubGroup("infinite-group2", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	// create a cycle artificially to the same group
@@ -513,7 +514,7 @@ func (ts *quotaTestSuite) TestAddAllNecessaryGroupsAvoidsInfiniteRecursion(c *C)
 	// make a real sub-group and try one more level of indirection going back
 	// to the parent
 	grp2.SetInternalSubGroups(nil)
-	grp3, err := grp2.NewSubGroup("infinite-group3", quantity.SizeGiB)
+	grp3, err := grp2.NewSubGroup("infinite-group3", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 	grp3.SetInternalSubGroups([]*quota.Group{grp})
 
@@ -527,7 +528,7 @@ func (ts *quotaTestSuite) TestAddAllNecessaryGroups(c *C) {
 	// it should initially be empty
 	c.Assert(qs.AllQuotaGroups(), HasLen, 0)
 
-	grp1, err := quota.NewGroup("myroot", quantity.SizeGiB)
+	grp1, err := quota.NewGroup("myroot", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	// add the group and make sure it is in the set
@@ -543,7 +544,7 @@ func (ts *quotaTestSuite) TestAddAllNecessaryGroups(c *C) {
 	c.Assert(qs.AllQuotaGroups(), DeepEquals, []*quota.Group{grp1})
 
 	// add a new group and make sure it is in the set now
-	grp2, err := quota.NewGroup("myroot2", quantity.SizeGiB)
+	grp2, err := quota.NewGroup("myroot2", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 	err = qs.AddAllNecessaryGroups(grp2)
 	c.Assert(err, IsNil)
@@ -554,7 +555,7 @@ func (ts *quotaTestSuite) TestAddAllNecessaryGroups(c *C) {
 
 	// make a sub-group and add the root group - it will automatically add
 	// the sub-group without us needing to explicitly add the sub-group
-	subgrp1, err := grp1.NewSubGroup("mysub1", quantity.SizeGiB)
+	subgrp1, err := grp1.NewSubGroup("mysub1", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 	// add grp2 as well
 	err = qs.AddAllNecessaryGroups(grp2)
@@ -571,13 +572,13 @@ func (ts *quotaTestSuite) TestAddAllNecessaryGroups(c *C) {
 
 	// create a new set of group and sub-groups to add the deepest child group
 	// and add that, and notice that the root groups are also added
-	grp3, err := quota.NewGroup("myroot3", quantity.SizeGiB)
+	grp3, err := quota.NewGroup("myroot3", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
-	subgrp3, err := grp3.NewSubGroup("mysub3", quantity.SizeGiB)
+	subgrp3, err := grp3.NewSubGroup("mysub3", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
-	subsubgrp3, err := subgrp3.NewSubGroup("mysubsub3", quantity.SizeGiB)
+	subsubgrp3, err := subgrp3.NewSubGroup("mysubsub3", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	err = qs.AddAllNecessaryGroups(subsubgrp3)
@@ -587,13 +588,13 @@ func (ts *quotaTestSuite) TestAddAllNecessaryGroups(c *C) {
 	// finally create a tree with multiple branches and ensure that adding just
 	// a single deepest child will add all the other deepest children from other
 	// branches
-	grp4, err := quota.NewGroup("myroot4", quantity.SizeGiB)
+	grp4, err := quota.NewGroup("myroot4", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
-	subgrp4, err := grp4.NewSubGroup("mysub4", quantity.SizeGiB/2)
+	subgrp4, err := grp4.NewSubGroup("mysub4", quota.Resources{Memory: quantity.SizeGiB / 2})
 	c.Assert(err, IsNil)
 
-	subgrp5, err := grp4.NewSubGroup("mysub5", quantity.SizeGiB/2)
+	subgrp5, err := grp4.NewSubGroup("mysub5", quota.Resources{Memory: quantity.SizeGiB / 2})
 	c.Assert(err, IsNil)
 
 	// adding just subgrp5 to a quota set will automatically add the other sub
@@ -605,13 +606,13 @@ func (ts *quotaTestSuite) TestAddAllNecessaryGroups(c *C) {
 }
 
 func (ts *quotaTestSuite) TestResolveCrossReferencesLimitCheckSkipsSelf(c *C) {
-	grp1, err := quota.NewGroup("myroot", quantity.SizeGiB)
+	grp1, err := quota.NewGroup("myroot", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
-	subgrp1, err := grp1.NewSubGroup("mysub1", quantity.SizeGiB)
+	subgrp1, err := grp1.NewSubGroup("mysub1", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
-	subgrp2, err := subgrp1.NewSubGroup("mysub2", quantity.SizeGiB)
+	subgrp2, err := subgrp1.NewSubGroup("mysub2", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	all := map[string]*quota.Group{
@@ -624,13 +625,13 @@ func (ts *quotaTestSuite) TestResolveCrossReferencesLimitCheckSkipsSelf(c *C) {
 }
 
 func (ts *quotaTestSuite) TestResolveCrossReferencesCircular(c *C) {
-	grp1, err := quota.NewGroup("myroot", quantity.SizeGiB)
+	grp1, err := quota.NewGroup("myroot", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
-	subgrp1, err := grp1.NewSubGroup("mysub1", quantity.SizeGiB)
+	subgrp1, err := grp1.NewSubGroup("mysub1", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
-	subgrp2, err := subgrp1.NewSubGroup("mysub2", quantity.SizeGiB)
+	subgrp2, err := subgrp1.NewSubGroup("mysub2", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	all := map[string]*quota.Group{
@@ -729,7 +730,7 @@ func (ts *quotaTestSuite) TestCurrentMemoryUsage(c *C) {
 	})
 	defer r()
 
-	grp1, err := quota.NewGroup("group", quantity.SizeGiB)
+	grp1, err := quota.NewGroup("group", quota.Resources{Memory: quantity.SizeGiB})
 	c.Assert(err, IsNil)
 
 	// group initially is inactive, so it has no current memory usage
@@ -760,3 +761,272 @@ func (ts *quotaTestSuite) TestCurrentMemoryUsage(c *C) {
 	const sixteenExb = quantity.Size(1<<64 - 1)
 	c.Assert(currentMem, Equals, sixteenExb)
 }
+
+func (ts *quotaTestSuite) TestCurrentTaskUsage(c *C) {
+	systemctlCalls := 0
+	r := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		systemctlCalls++
+		switch systemctlCalls {
+
+		// inactive case, number of tasks is 0
+		case 1:
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("inactive"), systemctlInactiveServiceError{}
+
+		// active case, the number of tasks is reported
+		case 2:
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("active"), nil
+		case 3:
+			c.Assert(args, DeepEquals, []string{"show", "--property", "TasksCurrent", "snap.group.slice"})
+			return []byte("TasksCurrent=7"), nil
+		default:
+			c.Errorf("too many systemctl calls (%d) (current call is %+v)", systemctlCalls, args)
+			return []byte("broken test"), fmt.Errorf("broken test")
+		}
+	})
+	defer r()
+
+	grp1, err := quota.NewGroup("group", quota.Resources{Threads: 32})
+	c.Assert(err, IsNil)
+
+	currentTasks, err := grp1.CurrentTaskUsage()
+	c.Assert(err, IsNil)
+	c.Assert(currentTasks, Equals, 0)
+
+	currentTasks, err = grp1.CurrentTaskUsage()
+	c.Assert(err, IsNil)
+	c.Assert(currentTasks, Equals, 7)
+}
+
+func (ts *quotaTestSuite) TestResourcesValidate(c *C) {
+	tt := []struct {
+		limits  quota.Resources
+		err     string
+		comment string
+	}{
+		{
+			limits:  quota.Resources{},
+			err:     `quota group must have at least one resource limit set`,
+			comment: "no limits",
+		},
+		{
+			limits:  quota.Resources{Memory: quantity.SizeMiB},
+			comment: "memory only",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{Percentage: 50}},
+			comment: "cpu percentage only",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{Count: 2, Percentage: 50, AllowedCPUs: []int{0, 1}}},
+			comment: "full cpu quota",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{AllowedCPUs: []int{1}}},
+			comment: "allowed cpus only",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{}},
+			err:     `CPU quota must have either a percentage or a set of allowed CPUs`,
+			comment: "empty cpu quota",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{Percentage: 101}},
+			err:     `invalid CPU percentage 101: must be between 1 and 100`,
+			comment: "cpu percentage too large",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{Percentage: -1, AllowedCPUs: []int{0}}},
+			err:     `invalid CPU percentage -1: must be between 1 and 100`,
+			comment: "negative cpu percentage",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{Count: -1, Percentage: 50}},
+			err:     `invalid CPU count -1: must not be negative`,
+			comment: "negative cpu count",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{Count: 2}},
+			err:     `cannot use a CPU count without a CPU percentage`,
+			comment: "cpu count without percentage",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{AllowedCPUs: []int{-1}}},
+			err:     `invalid CPU number -1: must not be negative`,
+			comment: "negative allowed cpu",
+		},
+		{
+			limits:  quota.Resources{CPU: &quota.GroupQuotaCPU{AllowedCPUs: []int{1, 1}}},
+			err:     `CPU number 1 is listed more than once`,
+			comment: "duplicated allowed cpu",
+		},
+		{
+			limits:  quota.Resources{Threads: 32},
+			comment: "threads only",
+		},
+		{
+			limits:  quota.Resources{Threads: -1},
+			err:     `invalid thread limit -1: must not be negative`,
+			comment: "negative threads",
+		},
+		{
+			limits:  quota.Resources{Journal: &quota.GroupQuotaJournal{}},
+			comment: "journal namespace with the default settings",
+		},
+		{
+			limits:  quota.Resources{Journal: &quota.GroupQuotaJournal{Size: 64 * quantity.SizeMiB, RateCount: 100, RatePeriod: time.Second}},
+			comment: "full journal quota",
+		},
+		{
+			limits:  quota.Resources{Journal: &quota.GroupQuotaJournal{Size: 5 * quantity.SizeGiB}},
+			err:     `journal size 5 GiB is too large: size must be at most 4 GiB`,
+			comment: "journal size too large",
+		},
+		{
+			limits:  quota.Resources{Journal: &quota.GroupQuotaJournal{RateCount: 100}},
+			err:     `journal rate limit must have both a count and a period`,
+			comment: "journal rate count without period",
+		},
+		{
+			limits:  quota.Resources{Journal: &quota.GroupQuotaJournal{RateCount: -1, RatePeriod: time.Second}},
+			err:     `journal rate limit must not be negative`,
+			comment: "negative journal rate count",
+		},
+	}
+
+	for _, t := range tt {
+		comment := Commentf(t.comment)
+		err := t.limits.Validate()
+		if t.err != "" {
+			c.Assert(err, ErrorMatches, t.err, comment)
+		} else {
+			c.Assert(err, IsNil, comment)
+		}
+	}
+}
+
+func (ts *quotaTestSuite) TestSubGroupResourcesFitInParent(c *C) {
+	grp, err := quota.NewGroup("myroot", quota.Resources{
+		CPU:     &quota.GroupQuotaCPU{Count: 2, Percentage: 50, AllowedCPUs: []int{0, 1}},
+		Threads: 100,
+	})
+	c.Assert(err, IsNil)
+
+	// the parent has 100% of a single CPU in total
+	_, err = grp.NewSubGroup("sub1", quota.Resources{CPU: &quota.GroupQuotaCPU{Percentage: 60}})
+	c.Assert(err, IsNil)
+
+	_, err = grp.NewSubGroup("sub2", quota.Resources{CPU: &quota.GroupQuotaCPU{Percentage: 50}})
+	c.Assert(err, ErrorMatches, `sub-group CPU limit of 50% is too large to fit inside remaining quota space 40% for parent group myroot`)
+
+	_, err = grp.NewSubGroup("sub2", quota.Resources{CPU: &quota.GroupQuotaCPU{AllowedCPUs: []int{1, 2}}})
+	c.Assert(err, ErrorMatches, `sub-group allowed CPU 2 is not in the allowed CPUs of parent group myroot`)
+
+	_, err = grp.NewSubGroup("sub2", quota.Resources{Threads: 101})
+	c.Assert(err, ErrorMatches, `sub-group thread limit of 101 is too large to fit inside remaining quota space 100 for parent group myroot`)
+
+	sub2, err := grp.NewSubGroup("sub2", quota.Resources{Threads: 60})
+	c.Assert(err, IsNil)
+
+	_, err = grp.NewSubGroup("sub3", quota.Resources{Threads: 50})
+	c.Assert(err, ErrorMatches, `sub-group thread limit of 50 is too large to fit inside remaining quota space 40 for parent group myroot`)
+
+	// the parent has no memory limit, so it does not constrain the memory
+	// limit of its sub-groups
+	_, err = grp.NewSubGroup("sub3", quota.Resources{Memory: quantity.SizeGiB})
+	c.Assert(err, IsNil)
+
+	// sub-groups cannot have their own journal namespace
+	_, err = grp.NewSubGroup("sub4", quota.Resources{Journal: &quota.GroupQuotaJournal{}})
+	c.Assert(err, ErrorMatches, `journal quota is not supported for sub-groups`)
+
+	// updating a sub-group is also subject to the parent limits
+	err = sub2.UpdateQuotaLimits(quota.Resources{Threads: 200})
+	c.Assert(err, ErrorMatches, `sub-group thread limit of 200 is too large to fit inside remaining quota space 100 for parent group myroot`)
+	c.Assert(sub2.ThreadLimit, Equals, 60)
+}
+
+func (ts *quotaTestSuite) TestUpdateQuotaLimits(c *C) {
+	grp, err := quota.NewGroup("myroot", quota.Resources{Memory: quantity.SizeGiB})
+	c.Assert(err, IsNil)
+
+	sub, err := grp.NewSubGroup("mysub", quota.Resources{Memory: quantity.SizeGiB, Threads: 10})
+	c.Assert(err, IsNil)
+
+	// adding new limits keeps the existing ones
+	err = grp.UpdateQuotaLimits(quota.Resources{
+		CPU:     &quota.GroupQuotaCPU{Percentage: 50},
+		Threads: 20,
+		Journal: &quota.GroupQuotaJournal{Size: quantity.SizeMiB},
+	})
+	c.Assert(err, IsNil)
+	c.Check(grp.GetQuotaResources(), DeepEquals, quota.Resources{
+		Memory:  quantity.SizeGiB,
+		CPU:     &quota.GroupQuotaCPU{Percentage: 50},
+		Threads: 20,
+		Journal: &quota.GroupQuotaJournal{Size: quantity.SizeMiB},
+	})
+
+	// the sub-group would not fit anymore, so the update is refused and the
+	// group is left unchanged
+	err = grp.UpdateQuotaLimits(quota.Resources{Threads: 5})
+	c.Assert(err, ErrorMatches, `sub-group thread limit of 10 is too large to fit inside remaining quota space 5 for parent group myroot`)
+	c.Check(grp.ThreadLimit, Equals, 20)
+	c.Check(sub.ThreadLimit, Equals, 10)
+
+	// invalid limits are refused too
+	err = grp.UpdateQuotaLimits(quota.Resources{CPU: &quota.GroupQuotaCPU{Percentage: 200}})
+	c.Assert(err, ErrorMatches, `invalid CPU percentage 200: must be between 1 and 100`)
+	c.Check(grp.CPULimit, DeepEquals, &quota.GroupQuotaCPU{Percentage: 50})
+}
+
+func (ts *quotaTestSuite) TestUpdateQuotaLimitsMergesSubLimits(c *C) {
+	grp, err := quota.NewGroup("myroot", quota.Resources{
+		CPU:     &quota.GroupQuotaCPU{Count: 2, Percentage: 50},
+		Journal: &quota.GroupQuotaJournal{Size: quantity.SizeMiB},
+	})
+	c.Assert(err, IsNil)
+
+	// setting the allowed CPUs keeps the CPU time limit
+	err = grp.UpdateQuotaLimits(quota.Resources{CPU: &quota.GroupQuotaCPU{AllowedCPUs: []int{0, 1}}})
+	c.Assert(err, IsNil)
+	c.Check(grp.CPULimit, DeepEquals, &quota.GroupQuotaCPU{Count: 2, Percentage: 50, AllowedCPUs: []int{0, 1}})
+
+	// and changing the CPU time limit keeps the allowed CPUs
+	err = grp.UpdateQuotaLimits(quota.Resources{CPU: &quota.GroupQuotaCPU{Percentage: 75}})
+	c.Assert(err, IsNil)
+	c.Check(grp.CPULimit, DeepEquals, &quota.GroupQuotaCPU{Percentage: 75, AllowedCPUs: []int{0, 1}})
+
+	// setting the journal rate limit keeps the journal size
+	err = grp.UpdateQuotaLimits(quota.Resources{Journal: &quota.GroupQuotaJournal{RateCount: 10, RatePeriod: time.Second}})
+	c.Assert(err, IsNil)
+	c.Check(grp.JournalLimit, DeepEquals, &quota.GroupQuotaJournal{Size: quantity.SizeMiB, RateCount: 10, RatePeriod: time.Second})
+
+	// and changing the size keeps the rate limit
+	err = grp.UpdateQuotaLimits(quota.Resources{Journal: &quota.GroupQuotaJournal{Size: 2 * quantity.SizeMiB}})
+	c.Assert(err, IsNil)
+	c.Check(grp.JournalLimit, DeepEquals, &quota.GroupQuotaJournal{Size: 2 * quantity.SizeMiB, RateCount: 10, RatePeriod: time.Second})
+
+	// a failed update leaves the existing limits untouched
+	err = grp.UpdateQuotaLimits(quota.Resources{CPU: &quota.GroupQuotaCPU{AllowedCPUs: []int{-1}}})
+	c.Assert(err, ErrorMatches, `invalid CPU number -1: must not be negative`)
+	c.Check(grp.CPULimit, DeepEquals, &quota.GroupQuotaCPU{Percentage: 75, AllowedCPUs: []int{0, 1}})
+}
+
+func (ts *quotaTestSuite) TestJournalNamespaceName(c *C) {
+	grp, err := quota.NewGroup("myroot", quota.Resources{Journal: &quota.GroupQuotaJournal{}})
+	c.Assert(err, IsNil)
+	c.Check(grp.JournalNamespaceName(), Equals, "snap-myroot")
+	c.Check(grp.JournalConfFileName(), Equals, "journald@snap-myroot.conf")
+
+	// sub-groups log to the namespace of their parent
+	sub, err := grp.NewSubGroup("mysub", quota.Resources{Memory: quantity.SizeGiB})
+	c.Assert(err, IsNil)
+	c.Check(sub.JournalNamespaceName(), Equals, "snap-myroot")
+
+	// groups without a journal quota log to the system journal
+	grp2, err := quota.NewGroup("other", quota.Resources{Memory: quantity.SizeGiB})
+	c.Assert(err, IsNil)
+	c.Check(grp2.JournalNamespaceName(), Equals, "")
+}
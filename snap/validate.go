@@ -28,6 +28,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/snapcore/snapd/osutil"
@@ -160,6 +161,9 @@ func ValidateLicense(license string) error {
 	return nil
 }
 
+// minHookInterval is the shortest interval a hook can be run periodically at.
+const minHookInterval = time.Minute
+
 // ValidateHook validates the content of the given HookInfo
 func ValidateHook(hook *HookInfo) error {
 	if err := naming.ValidateHook(hook.Name); err != nil {
@@ -173,6 +177,15 @@ func ValidateHook(hook *HookInfo) error {
 		}
 	}
 
+	if hook.Interval != 0 {
+		if hook.Name != "check-health" {
+			return errors.New("interval is only applicable to the check-health hook")
+		}
+		if time.Duration(hook.Interval) < minHookInterval {
+			return fmt.Errorf("hook interval cannot be less than %s", minHookInterval)
+		}
+	}
+
 	return nil
 }
 
@@ -383,6 +396,10 @@ func Validate(info *Info) error {
 		return err
 	}
 
+	if err := ValidateConfigSchema(info); err != nil {
+		return err
+	}
+
 	return ValidateLayoutAll(info)
 }
 
@@ -24,11 +24,13 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	. "gopkg.in/check.v1"
 
 	. "github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/timeout"
 )
 
 type ValidateSuite struct {
@@ -170,6 +172,17 @@ func (s *ValidateSuite) TestValidateHook(c *C) {
 	}
 }
 
+func (s *ValidateSuite) TestValidateHookInterval(c *C) {
+	hook := &HookInfo{Name: "check-health", Interval: timeout.Timeout(time.Hour)}
+	c.Check(ValidateHook(hook), IsNil)
+
+	hook = &HookInfo{Name: "check-health", Interval: timeout.Timeout(30 * time.Second)}
+	c.Check(ValidateHook(hook), ErrorMatches, `hook interval cannot be less than 1m0s`)
+
+	hook = &HookInfo{Name: "configure", Interval: timeout.Timeout(time.Hour)}
+	c.Check(ValidateHook(hook), ErrorMatches, `interval is only applicable to the check-health hook`)
+}
+
 // ValidateApp
 
 func (s *ValidateSuite) TestValidateAppSockets(c *C) {
@@ -20,11 +20,14 @@
 package store
 
 import (
+	"crypto"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"sync"
 	"syscall"
 	"time"
 
@@ -63,10 +66,43 @@ func (s changesByMtime) Len() int           { return len(s) }
 func (s changesByMtime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 func (s changesByMtime) Less(i, j int) bool { return s[i].ModTime().Before(s[j].ModTime()) }
 
+// validCacheKey matches the hex encoded sha3-384 digests used as cache keys
+var validCacheKey = regexp.MustCompile(`^[0-9a-f]{96}$`)
+
+// CachePolicy controls the eviction of items from the download cache.
+// Items that are also referenced elsewhere in the filesystem, such as the
+// revisions of snaps installed on disk, are pinned: they are never evicted
+// and do not count towards the limits.
+type CachePolicy struct {
+	// MaxItems is the maximum number of items to keep
+	MaxItems int
+	// MaxSize is the maximum size in bytes of the items to keep, or
+	// unlimited if zero
+	MaxSize int64
+	// MaxAge is how long to keep items after they were last used, or
+	// unlimited if zero
+	MaxAge time.Duration
+}
+
+// CacheStats describes the content of the download cache.
+type CacheStats struct {
+	Items int
+	Size  int64
+	// PinnedItems and PinnedSize account for the items that are pinned,
+	// included in Items and Size
+	PinnedItems int
+	PinnedSize  int64
+}
+
 // cacheManager implements a downloadCache via content based hard linking
 type CacheManager struct {
 	cacheDir string
 	maxItems int
+
+	mu sync.Mutex
+	// policy, if set, is consulted on every cleanup, overriding maxItems
+	// when it sets MaxItems
+	policy func() CachePolicy
 }
 
 // NewCacheManager returns a new CacheManager with the given cacheDir
@@ -78,8 +114,9 @@ type CacheManager struct {
 //    return success
 // 3. If not found, download the snap
 // 4. On success, hardlink into $cacheDir/<digest>
-// 5. If cache dir has more than maxItems entries, remove oldest mtimes
-//    until it has maxItems
+// 5. If cache dir has more than maxItems entries, or more than allowed
+//    by the policy set with SetPolicy, remove oldest mtimes until it is
+//    within the limits
 //
 // The caching part is done here, the downloading happens in the store.go
 // code.
@@ -90,6 +127,33 @@ func NewCacheManager(cacheDir string, maxItems int) *CacheManager {
 	}
 }
 
+// SetPolicy sets the function returning the policy to use when evicting
+// items from the cache, on top of the maximum amount of items given to
+// NewCacheManager.
+func (cm *CacheManager) SetPolicy(policy func() CachePolicy) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.policy = policy
+}
+
+// Policy returns the policy currently in effect.
+func (cm *CacheManager) Policy() CachePolicy {
+	cm.mu.Lock()
+	policyFn := cm.policy
+	cm.mu.Unlock()
+
+	policy := CachePolicy{MaxItems: cm.maxItems}
+	if policyFn != nil {
+		p := policyFn()
+		if p.MaxItems > 0 {
+			policy.MaxItems = p.MaxItems
+		}
+		policy.MaxSize = p.MaxSize
+		policy.MaxAge = p.MaxAge
+	}
+	return policy
+}
+
 // GetPath returns the full path of the given content in the cache
 // or empty string
 func (cm *CacheManager) GetPath(cacheKey string) string {
@@ -153,37 +217,22 @@ func (cm *CacheManager) path(cacheKey string) string {
 	return filepath.Join(cm.cacheDir, cacheKey)
 }
 
-// cleanup ensures that only maxItems are stored in the cache
+// cleanup evicts items from the cache, oldest used first, until the
+// cache is within the limits of the policy
 func (cm *CacheManager) cleanup() error {
+	policy := cm.Policy()
+
 	fil, err := ioutil.ReadDir(cm.cacheDir)
 	if err != nil {
 		return err
 	}
-	if len(fil) <= cm.maxItems {
-		return nil
-	}
-
-	numOwned := 0
-	for _, fi := range fil {
-		n, err := hardLinkCount(fi)
-		if err != nil {
-			logger.Noticef("cannot inspect cache: %s", err)
-		}
-		// Only count the file if it is not referenced elsewhere in the filesystem
-		if n <= 1 {
-			numOwned++
-		}
-	}
-
-	if numOwned <= cm.maxItems {
+	if len(fil) <= policy.MaxItems && policy.MaxSize <= 0 && policy.MaxAge <= 0 {
 		return nil
 	}
 
-	var lastErr error
-	sort.Sort(changesByMtime(fil))
-	deleted := 0
+	var owned []os.FileInfo
+	var ownedSize int64
 	for _, fi := range fil {
-		path := cm.path(fi.Name())
 		n, err := hardLinkCount(fi)
 		if err != nil {
 			logger.Noticef("cannot inspect cache: %s", err)
@@ -194,21 +243,100 @@ func (cm *CacheManager) cleanup() error {
 		if n > 1 {
 			continue
 		}
-		if err := osRemove(path); err != nil {
+		owned = append(owned, fi)
+		ownedSize += fi.Size()
+	}
+
+	var lastErr error
+	sort.Sort(changesByMtime(owned))
+	numOwned := len(owned)
+	now := time.Now()
+	for _, fi := range owned {
+		expired := policy.MaxAge > 0 && now.Sub(fi.ModTime()) > policy.MaxAge
+		tooBig := policy.MaxSize > 0 && ownedSize > policy.MaxSize
+		if !expired && !tooBig && numOwned <= policy.MaxItems {
+			// the remaining items are newer
+			break
+		}
+		if err := osRemove(cm.path(fi.Name())); err != nil {
 			if !os.IsNotExist(err) {
 				logger.Noticef("cannot cleanup cache: %s", err)
 				lastErr = err
 			}
 			continue
 		}
-		deleted++
-		if numOwned-deleted <= cm.maxItems {
-			break
-		}
+		numOwned--
+		ownedSize -= fi.Size()
 	}
 	return lastErr
 }
 
+// Stats returns statistics about the content of the cache.
+func (cm *CacheManager) Stats() (*CacheStats, error) {
+	fil, err := ioutil.ReadDir(cm.cacheDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	stats := &CacheStats{}
+	for _, fi := range fil {
+		stats.Items++
+		stats.Size += fi.Size()
+		if n, err := hardLinkCount(fi); err == nil && n > 1 {
+			stats.PinnedItems++
+			stats.PinnedSize += fi.Size()
+		}
+	}
+	return stats, nil
+}
+
+// Prefill adds to the cache the snaps found in the given directory, as
+// either .snap files like in a seed, or files named after their digest
+// like in the download cache of another device. Items are copied, not hard
+// linked, so that they are owned by the cache and can be evicted like any
+// other. It returns the number of items added.
+func (cm *CacheManager) Prefill(dir string) (added int, err error) {
+	fil, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(cm.cacheDir, 0700); err != nil {
+		return 0, err
+	}
+
+	for _, fi := range fil {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		if filepath.Ext(fi.Name()) != ".snap" && !validCacheKey.MatchString(fi.Name()) {
+			continue
+		}
+		sourcePath := filepath.Join(dir, fi.Name())
+		// the content is what matters, whatever the file is named
+		digest, _, err := osutil.FileDigest(sourcePath, crypto.SHA3_384)
+		if err != nil {
+			return added, err
+		}
+		cachePath := cm.path(fmt.Sprintf("%x", digest))
+		if osutil.FileExists(cachePath) {
+			continue
+		}
+		// copy under another name first so that partial copies are
+		// never used
+		partialPath := cachePath + ".partial"
+		if err := osutil.CopyFile(sourcePath, partialPath, osutil.CopyFlagSync); err != nil {
+			os.Remove(partialPath)
+			return added, fmt.Errorf("cannot add %q to the cache: %v", sourcePath, err)
+		}
+		if err := os.Rename(partialPath, cachePath); err != nil {
+			os.Remove(partialPath)
+			return added, fmt.Errorf("cannot add %q to the cache: %v", sourcePath, err)
+		}
+		added++
+	}
+
+	return added, cm.cleanup()
+}
+
 // hardLinkCount returns the number of hardlinks for the given path
 func hardLinkCount(fi os.FileInfo) (uint64, error) {
 	if stat, ok := fi.Sys().(*syscall.Stat_t); ok && stat != nil {
@@ -29,6 +29,7 @@ import (
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/testutil"
@@ -215,3 +216,163 @@ func (s *cacheSuite) TestHardLinkCount(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(n, Equals, uint64(10))
 }
+
+func (s *cacheSuite) putOwned(c *C, cacheKey, content string, mtime time.Time) {
+	p := s.makeTestFile(c, cacheKey, content)
+	c.Assert(s.cm.Put(cacheKey, p), IsNil)
+	// the item is now only in the cache
+	c.Assert(os.Remove(p), IsNil)
+	c.Assert(os.Chtimes(filepath.Join(s.cm.CacheDir(), cacheKey), mtime, mtime), IsNil)
+}
+
+func (s *cacheSuite) cached(cacheKey string) bool {
+	return osutil.FileExists(filepath.Join(s.cm.CacheDir(), cacheKey))
+}
+
+func (s *cacheSuite) TestPolicy(c *C) {
+	c.Check(s.cm.Policy(), Equals, store.CachePolicy{MaxItems: s.maxItems})
+
+	policy := store.CachePolicy{MaxSize: 1024}
+	s.cm.SetPolicy(func() store.CachePolicy { return policy })
+	c.Check(s.cm.Policy(), Equals, store.CachePolicy{MaxItems: s.maxItems, MaxSize: 1024})
+
+	policy = store.CachePolicy{MaxItems: 2, MaxAge: time.Hour}
+	c.Check(s.cm.Policy(), Equals, store.CachePolicy{MaxItems: 2, MaxAge: time.Hour})
+}
+
+func (s *cacheSuite) TestCleanupMaxSize(c *C) {
+	s.cm.SetPolicy(func() store.CachePolicy {
+		return store.CachePolicy{MaxSize: 10}
+	})
+
+	now := time.Now()
+	s.putOwned(c, "oldest", "12345", now.Add(-3*time.Hour))
+	s.putOwned(c, "older", "12345", now.Add(-2*time.Hour))
+	s.putOwned(c, "newer", "12345", now.Add(-time.Hour))
+	c.Assert(s.cm.Cleanup(), IsNil)
+
+	// the oldest used item goes to fit in 10 bytes
+	c.Check(s.cached("oldest"), Equals, false)
+	c.Check(s.cached("older"), Equals, true)
+	c.Check(s.cached("newer"), Equals, true)
+}
+
+func (s *cacheSuite) TestCleanupMaxAge(c *C) {
+	s.cm.SetPolicy(func() store.CachePolicy {
+		return store.CachePolicy{MaxAge: 24 * time.Hour}
+	})
+
+	now := time.Now()
+	s.putOwned(c, "stale", "1", now.Add(-48*time.Hour))
+	s.putOwned(c, "fresh", "2", now.Add(-time.Hour))
+	c.Assert(s.cm.Cleanup(), IsNil)
+
+	c.Check(s.cached("stale"), Equals, false)
+	c.Check(s.cached("fresh"), Equals, true)
+}
+
+func (s *cacheSuite) TestCleanupKeepsPinned(c *C) {
+	s.cm.SetPolicy(func() store.CachePolicy {
+		return store.CachePolicy{MaxSize: 1, MaxAge: time.Hour}
+	})
+
+	// an installed snap is a hard link to its cached copy
+	installed := s.makeTestFile(c, "installed", "big installed snap")
+	c.Assert(s.cm.Put("installed", installed), IsNil)
+	old := time.Now().Add(-48 * time.Hour)
+	c.Assert(os.Chtimes(installed, old, old), IsNil)
+	s.putOwned(c, "owned", "big owned snap", time.Now())
+	c.Assert(s.cm.Cleanup(), IsNil)
+
+	c.Check(s.cached("installed"), Equals, true)
+	c.Check(s.cached("owned"), Equals, false)
+}
+
+func (s *cacheSuite) TestStats(c *C) {
+	stats, err := s.cm.Stats()
+	c.Assert(err, IsNil)
+	c.Check(stats, DeepEquals, &store.CacheStats{})
+
+	installed := s.makeTestFile(c, "installed", "1234")
+	c.Assert(s.cm.Put("installed", installed), IsNil)
+	s.putOwned(c, "owned", "12", time.Now())
+
+	stats, err = s.cm.Stats()
+	c.Assert(err, IsNil)
+	c.Check(stats, DeepEquals, &store.CacheStats{
+		Items:       2,
+		Size:        6,
+		PinnedItems: 1,
+		PinnedSize:  4,
+	})
+}
+
+func (s *cacheSuite) TestPrefill(c *C) {
+	dir := c.MkDir()
+	// a seed snap
+	s.makeTestFileInDir(c, dir, "foo_1.snap", "foo")
+	// an item from the cache of another device, named after the
+	// digest of its content
+	barDigest := "11cfca1618a8cfad41c77c8e4af303d6c98de7424116e87b5cd533920a17c96b4948e83a9b509a70aa6440e3a7ccc5cb"
+	s.makeTestFileInDir(c, dir, barDigest, "bar")
+	// ignored
+	s.makeTestFileInDir(c, dir, "README", "hello")
+	c.Assert(os.Mkdir(filepath.Join(dir, "sub.snap"), 0755), IsNil)
+
+	added, err := s.cm.Prefill(dir)
+	c.Assert(err, IsNil)
+	c.Check(added, Equals, 2)
+	c.Check(s.cm.Count(), Equals, 2)
+
+	fooDigest := "665551928d13b7d84ee02734502b018d896a0fb87eed5adb4c87ba91bbd6489410e11b0fbcc06ed7d0ebad559e5d3bb5"
+	c.Check(filepath.Join(s.cm.CacheDir(), fooDigest), testutil.FileEquals, "foo")
+	c.Check(filepath.Join(s.cm.CacheDir(), barDigest), testutil.FileEquals, "bar")
+
+	// the items are copies, so they are not pinned
+	stats, err := s.cm.Stats()
+	c.Assert(err, IsNil)
+	c.Check(stats.Items, Equals, 2)
+	c.Check(stats.PinnedItems, Equals, 0)
+	c.Check(osutil.FileExists(filepath.Join(s.cm.CacheDir(), fooDigest+".partial")), Equals, false)
+
+	// already there
+	added, err = s.cm.Prefill(dir)
+	c.Assert(err, IsNil)
+	c.Check(added, Equals, 0)
+	c.Check(s.cm.Count(), Equals, 2)
+}
+
+func (s *cacheSuite) TestPrefillEvicts(c *C) {
+	dir := c.MkDir()
+	s.makeTestFileInDir(c, dir, "foo_1.snap", "foo")
+	s.makeTestFileInDir(c, dir, "bar_1.snap", "bar")
+	s.cm.SetPolicy(func() store.CachePolicy {
+		return store.CachePolicy{MaxItems: 1}
+	})
+
+	// prefilled items are owned by the cache, so they are evicted to
+	// stay within the limits
+	added, err := s.cm.Prefill(dir)
+	c.Assert(err, IsNil)
+	c.Check(added, Equals, 2)
+	c.Check(s.cm.Count(), Equals, 1)
+}
+
+func (s *cacheSuite) TestStoreDownloadCachePolicy(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	cfg := store.DefaultConfig()
+	cfg.CacheDownloads = 3
+	cfg.CachePolicy = func() store.CachePolicy {
+		return store.CachePolicy{MaxSize: 1024}
+	}
+	sto := store.New(cfg, nil)
+	cm := sto.DownloadCache()
+	c.Assert(cm, NotNil)
+	c.Check(cm.CacheDir(), Equals, dirs.SnapDownloadCacheDir)
+	c.Check(cm.Policy(), Equals, store.CachePolicy{MaxItems: 3, MaxSize: 1024})
+
+	sto.SetCacheDownloads(0)
+	c.Check(sto.DownloadCache(), IsNil)
+}
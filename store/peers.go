@@ -0,0 +1,194 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+// PeerSnapsPath is the path under which peers serve the snaps of their
+// download cache, by sha3-384 digest.
+const PeerSnapsPath = "/v1/snaps/"
+
+// A Peer is a device of the local network serving the snaps of its
+// download cache.
+type Peer struct {
+	// Name identifies the peer in progress and logs
+	Name string
+	// URL is the base URL of the peer, e.g. http://192.168.1.10:8738
+	URL string
+}
+
+// A PeerSource finds the peers to try downloading snaps from before the
+// store.
+type PeerSource interface {
+	Peers(ctx context.Context) ([]Peer, error)
+}
+
+var errNoPeers = errors.New("no peers found")
+
+var peerHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+		}).DialContext,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+}
+
+// downloadFromPeers downloads the snap from the first peer that has it,
+// verifying its sha3-384 digest. The digest of the download info is the
+// one of the snap-revision assertion the snap is checked against once
+// downloaded.
+func downloadFromPeers(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, source PeerSource) error {
+	peers, err := source.Peers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(peers) == 0 {
+		return errNoPeers
+	}
+	if pbar == nil {
+		pbar = progress.Null
+	}
+	var errs []string
+	for _, peer := range peers {
+		err := downloadFromPeer(ctx, name, targetPath, downloadInfo, pbar, peer)
+		if err == nil {
+			pbar.Notify(fmt.Sprintf("Downloaded snap %q from peer %s", name, peer.Name))
+			return nil
+		}
+		logger.Debugf("Cannot download snap %q from peer %s: %v", name, peer.Name, err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", peer.Name, err))
+	}
+	return fmt.Errorf("cannot download from peers: %s", strings.Join(errs, "; "))
+}
+
+func downloadFromPeer(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, peer Peer) (err error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(peer.URL, "/")+PeerSnapsPath+downloadInfo.Sha3_384, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := peerHTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	if resp.ContentLength >= 0 && downloadInfo.Size > 0 && resp.ContentLength != downloadInfo.Size {
+		return fmt.Errorf("unexpected size %d, expected %d", resp.ContentLength, downloadInfo.Size)
+	}
+
+	// not the .partial file of store downloads, that they can resume
+	peerPath := targetPath + ".peer"
+	w, err := os.OpenFile(peerPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := w.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(peerPath)
+		}
+	}()
+
+	// the source of the download shows in the progress of the task
+	pbar.Start(fmt.Sprintf("%s (from peer %s)", name, peer.Name), float64(downloadInfo.Size))
+	h := crypto.SHA3_384.New()
+	body := io.Reader(resp.Body)
+	if downloadInfo.Size > 0 {
+		// do not let a peer fill the disk
+		body = io.LimitReader(body, downloadInfo.Size+1)
+	}
+	_, err = io.Copy(io.MultiWriter(w, h, pbar), body)
+	pbar.Finished()
+	if err != nil {
+		return err
+	}
+	actualSha3 := fmt.Sprintf("%x", h.Sum(nil))
+	if actualSha3 != downloadInfo.Sha3_384 {
+		return HashError{name, actualSha3, downloadInfo.Sha3_384}
+	}
+	if err := w.Sync(); err != nil {
+		return err
+	}
+	return os.Rename(peerPath, targetPath)
+}
+
+type peerHandler struct {
+	cacher downloadCache
+}
+
+// NewPeerHandler returns an http.Handler serving the snaps of the given
+// download cache to peers.
+func NewPeerHandler(cm *CacheManager) http.Handler {
+	return &peerHandler{cacher: cm}
+}
+
+func (h *peerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	digest := strings.TrimPrefix(r.URL.Path, PeerSnapsPath)
+	if digest == r.URL.Path || !validCacheKey.MatchString(digest) {
+		http.NotFound(w, r)
+		return
+	}
+	path := h.cacher.GetPath(digest)
+	if path == "" {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "cannot stat snap", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
@@ -0,0 +1,205 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2021 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	"golang.org/x/crypto/sha3"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/progress/progresstest"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type fakePeerSource struct {
+	peers []store.Peer
+	err   error
+}
+
+func (src *fakePeerSource) Peers(ctx context.Context) ([]store.Peer, error) {
+	return src.peers, src.err
+}
+
+func peerServer(content map[string][]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := content[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func peerTestSnap(content []byte) *snap.Info {
+	info := &snap.Info{}
+	info.RealName = "foo"
+	info.AnonDownloadURL = "anon-url"
+	info.Size = int64(len(content))
+	info.Sha3_384 = fmt.Sprintf("%x", sha3.Sum384(content))
+	return info
+}
+
+func (s *storeDownloadSuite) TestDownloadFromPeer(c *C) {
+	content := []byte("I was downloaded from a peer")
+	info := peerTestSnap(content)
+
+	srv := peerServer(map[string][]byte{store.PeerSnapsPath + info.Sha3_384: content})
+	defer srv.Close()
+
+	obs := &cacheObserver{inCache: map[string]bool{}}
+	restore := s.store.MockCacher(obs)
+	defer restore()
+	restore = store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		c.Fatalf("the store should not be used when a peer has the snap")
+		return nil
+	})
+	defer restore()
+
+	pbar := &progresstest.Meter{}
+	dlOpts := &store.DownloadOptions{
+		Peers: &fakePeerSource{peers: []store.Peer{{Name: "peer-1", URL: srv.URL}}},
+	}
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	err := s.store.Download(s.ctx, "foo", path, &info.DownloadInfo, pbar, nil, dlOpts)
+	c.Assert(err, IsNil)
+
+	c.Check(path, testutil.FileEquals, content)
+	c.Check(path+".peer", testutil.FileAbsent)
+	c.Check(pbar.Labels, DeepEquals, []string{"foo (from peer peer-1)"})
+	c.Check(pbar.Notices, DeepEquals, []string{`Downloaded snap "foo" from peer peer-1`})
+	c.Check(obs.puts, DeepEquals, []string{fmt.Sprintf("%s:%s", info.Sha3_384, path)})
+}
+
+func (s *storeDownloadSuite) TestDownloadFromPeerSkipsBadPeers(c *C) {
+	content := []byte("I was downloaded from a peer")
+	info := peerTestSnap(content)
+
+	bad := peerServer(map[string][]byte{store.PeerSnapsPath + info.Sha3_384: []byte("I was tamperd with in transit")})
+	defer bad.Close()
+	missing := peerServer(nil)
+	defer missing.Close()
+	good := peerServer(map[string][]byte{store.PeerSnapsPath + info.Sha3_384: content})
+	defer good.Close()
+
+	restore := store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		c.Fatalf("the store should not be used when a peer has the snap")
+		return nil
+	})
+	defer restore()
+
+	pbar := &progresstest.Meter{}
+	dlOpts := &store.DownloadOptions{
+		Peers: &fakePeerSource{peers: []store.Peer{
+			{Name: "bad", URL: bad.URL},
+			{Name: "missing", URL: missing.URL},
+			{Name: "good", URL: good.URL},
+		}},
+	}
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	err := s.store.Download(s.ctx, "foo", path, &info.DownloadInfo, pbar, nil, dlOpts)
+	c.Assert(err, IsNil)
+
+	c.Check(path, testutil.FileEquals, content)
+	c.Check(pbar.Notices, DeepEquals, []string{`Downloaded snap "foo" from peer good`})
+}
+
+func (s *storeDownloadSuite) TestDownloadFromPeersFallsBackToStore(c *C) {
+	content := []byte("I was downloaded from the store")
+	info := peerTestSnap(content)
+
+	bad := peerServer(map[string][]byte{store.PeerSnapsPath + info.Sha3_384: []byte("something else entirely")})
+	defer bad.Close()
+
+	downloadWasCalled := false
+	restore := store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		downloadWasCalled = true
+		c.Check(resume, Equals, int64(0))
+		w.Write(content)
+		return nil
+	})
+	defer restore()
+
+	for _, src := range []*fakePeerSource{
+		{},
+		{err: fmt.Errorf("cannot discover peers")},
+		{peers: []store.Peer{{Name: "bad", URL: bad.URL}}},
+	} {
+		downloadWasCalled = false
+		dlOpts := &store.DownloadOptions{Peers: src}
+		path := filepath.Join(c.MkDir(), "downloaded-file")
+		err := s.store.Download(s.ctx, "foo", path, &info.DownloadInfo, nil, nil, dlOpts)
+		c.Assert(err, IsNil)
+		c.Check(downloadWasCalled, Equals, true)
+		c.Check(path, testutil.FileEquals, content)
+		c.Check(path+".peer", testutil.FileAbsent)
+	}
+}
+
+func (s *storeDownloadSuite) TestPeerHandler(c *C) {
+	cacheDir := c.MkDir()
+	cm := store.NewCacheManager(cacheDir, 5)
+
+	content := []byte("cached snap")
+	digest := fmt.Sprintf("%x", sha3.Sum384(content))
+	src := filepath.Join(c.MkDir(), "foo.snap")
+	c.Assert(ioutil.WriteFile(src, content, 0644), IsNil)
+	c.Assert(cm.Put(digest, src), IsNil)
+
+	srv := httptest.NewServer(store.NewPeerHandler(cm))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + store.PeerSnapsPath + digest)
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Check(resp.StatusCode, Equals, 200)
+	c.Check(data, DeepEquals, content)
+
+	otherDigest := fmt.Sprintf("%x", sha3.Sum384([]byte("other")))
+	for _, path := range []string{
+		store.PeerSnapsPath + otherDigest,
+		store.PeerSnapsPath + "../../etc/passwd",
+		store.PeerSnapsPath + "not-a-digest",
+		"/" + digest,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		c.Assert(err, IsNil, Commentf("%s", path))
+		resp.Body.Close()
+		c.Check(resp.StatusCode, Equals, 404, Commentf("%s", path))
+	}
+
+	resp, err = http.Post(srv.URL+store.PeerSnapsPath+digest, "text/plain", nil)
+	c.Assert(err, IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, Equals, 405)
+}
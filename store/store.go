@@ -44,6 +44,7 @@ import (
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/metrics"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/release"
@@ -104,6 +105,9 @@ type Config struct {
 
 	// CacheDownloads is the number of downloads that should be cached
 	CacheDownloads int
+	// CachePolicy, if set, returns the policy to use on top of
+	// CacheDownloads when evicting downloads from the cache
+	CachePolicy func() CachePolicy
 
 	// Proxy returns the HTTP proxy to use when talking to the store
 	Proxy func(*http.Request) (*url.URL, error)
@@ -838,7 +842,9 @@ func (s *Store) doRequest(ctx context.Context, client *http.Client, reqOptions *
 			req = req.WithContext(ctx)
 		}
 
+		start := time.Now()
 		resp, err := client.Do(req)
+		metrics.StoreRequestDuration.ObserveSince(start)
 		if err != nil {
 			return nil, err
 		}
@@ -141,6 +141,8 @@ type DownloadOptions struct {
 	RateLimit           int64
 	IsAutoRefresh       bool
 	LeavePartialOnError bool
+	// Peers if set is tried for the snap before the store
+	Peers PeerSource
 }
 
 // Download downloads the snap addressed by download info and returns its
@@ -157,6 +159,22 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		return nil
 	}
 
+	if dlOpts != nil && dlOpts.Peers != nil {
+		err := downloadFromPeers(ctx, name, targetPath, downloadInfo, pbar, dlOpts.Peers)
+		if err == nil {
+			return s.cacher.Put(downloadInfo.Sha3_384, targetPath)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// We revert to store downloads if there is any error.
+		if err == errNoPeers {
+			logger.Debugf("Cannot download %s from peers: %v", name, err)
+		} else {
+			logger.Noticef("Cannot download %s from peers: %v", name, err)
+		}
+	}
+
 	if useDeltas() {
 		logger.Debugf("Available deltas returned by store: %v", downloadInfo.Deltas)
 
@@ -720,8 +738,17 @@ func (s *Store) CacheDownloads() int {
 func (s *Store) SetCacheDownloads(fileCount int) {
 	s.cfg.CacheDownloads = fileCount
 	if fileCount > 0 {
-		s.cacher = NewCacheManager(dirs.SnapDownloadCacheDir, fileCount)
+		cm := NewCacheManager(dirs.SnapDownloadCacheDir, fileCount)
+		cm.SetPolicy(s.cfg.CachePolicy)
+		s.cacher = cm
 	} else {
 		s.cacher = &nullCache{}
 	}
 }
+
+// DownloadCache returns the download cache, or nil if downloads are not
+// cached.
+func (s *Store) DownloadCache() *CacheManager {
+	cm, _ := s.cacher.(*CacheManager)
+	return cm
+}
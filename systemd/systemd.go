@@ -500,6 +500,11 @@ func (s *systemd) getUnitStatus(properties []string, unitNames []string) ([]*Uni
 	for _, bs := range statusregex.FindAllSubmatch(bs, -1) {
 		if len(bs[0]) == 0 {
 			// systemctl separates data pertaining to particular services by an empty line
+			if len(seen) == 0 {
+				// no data since the last separator, as with trailing
+				// empty lines
+				continue
+			}
 			unitType := filepath.Ext(cur.UnitName)
 			expected := unitProperties[unitType]
 			if expected == nil {
@@ -362,6 +362,38 @@ ActiveState=active
 	c.Check(out, IsNil)
 }
 
+func (s *SystemdTestSuite) TestStatusTrailingEmptyLines(c *C) {
+	s.outs = [][]byte{
+		[]byte(`
+Id=foo.timer
+ActiveState=active
+UnitFileState=enabled
+
+Id=bar.timer
+ActiveState=inactive
+UnitFileState=enabled
+
+
+`[1:]),
+	}
+	s.errors = []error{nil}
+	out, err := New(SystemMode, s.rep).Status("foo.timer", "bar.timer")
+	c.Assert(err, IsNil)
+	c.Check(out, DeepEquals, []*UnitStatus{
+		{
+			UnitName:  "foo.timer",
+			Active:    true,
+			Enabled:   true,
+			Installed: true,
+		}, {
+			UnitName:  "bar.timer",
+			Active:    false,
+			Enabled:   true,
+			Installed: true,
+		},
+	})
+}
+
 func (s *SystemdTestSuite) TestStatusDupeField(c *C) {
 	s.outs = [][]byte{
 		[]byte(`
@@ -86,22 +86,70 @@ Before=slices.target
 X-Snappy=yes
 
 [Slice]
-# Always enable memory accounting otherwise the MemoryMax setting does nothing.
+`
+	fmt.Fprintf(&buf, template, grp.Name)
+
+	if grp.CPULimit != nil {
+		cpuTemplate := `# Always enable cpu accounting, so the following cpu quota options have an effect
+CPUAccounting=true
+`
+		fmt.Fprint(&buf, cpuTemplate)
+		if grp.CPULimit.Percentage != 0 {
+			fmt.Fprintf(&buf, "CPUQuota=%d%%\n", grp.CPULimit.TotalPercentage())
+		}
+		if len(grp.CPULimit.AllowedCPUs) != 0 {
+			cpus := make([]string, 0, len(grp.CPULimit.AllowedCPUs))
+			for _, cpu := range grp.CPULimit.AllowedCPUs {
+				cpus = append(cpus, strconv.Itoa(cpu))
+			}
+			fmt.Fprintf(&buf, "AllowedCPUs=%s\n", strings.Join(cpus, ","))
+		}
+		fmt.Fprint(&buf, "\n")
+	}
+
+	if grp.MemoryLimit != 0 {
+		memoryTemplate := `# Always enable memory accounting otherwise the MemoryMax setting does nothing.
 MemoryAccounting=true
-MemoryMax=%[2]d
+MemoryMax=%[1]d
 # for compatibility with older versions of systemd
-MemoryLimit=%[2]d
+MemoryLimit=%[1]d
+
+`
+		fmt.Fprintf(&buf, memoryTemplate, grp.MemoryLimit)
+	}
 
-# Always enable task accounting in order to be able to count the processes/
+	tasksTemplate := `# Always enable task accounting in order to be able to count the processes/
 # threads, etc for a slice
 TasksAccounting=true
 `
-
-	fmt.Fprintf(&buf, template, grp.Name, grp.MemoryLimit)
+	fmt.Fprint(&buf, tasksTemplate)
+	if grp.ThreadLimit != 0 {
+		fmt.Fprintf(&buf, "TasksMax=%d\n", grp.ThreadLimit)
+	}
 
 	return buf.Bytes(), nil
 }
 
+// generateJournaldConfFile generates a journald configuration for the journal
+// namespace of the specified quota group.
+func generateJournaldConfFile(grp *quota.Group) []byte {
+	buf := bytes.Buffer{}
+
+	template := `# Journald configuration for snap quota group %s
+[Journal]
+Storage=auto
+`
+	fmt.Fprintf(&buf, template, grp.Name)
+	if grp.JournalLimit.Size != 0 {
+		fmt.Fprintf(&buf, "SystemMaxUse=%[1]d\nRuntimeMaxUse=%[1]d\n", grp.JournalLimit.Size)
+	}
+	if grp.JournalLimit.RateCount != 0 {
+		fmt.Fprintf(&buf, "RateLimitIntervalSec=%dus\nRateLimitBurst=%d\n", grp.JournalLimit.RatePeriod.Microseconds(), grp.JournalLimit.RateCount)
+	}
+
+	return buf.Bytes()
+}
+
 func stopUserServices(cli *client.Client, inter interacter, services ...string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout.DefaultTimeout))
 	defer cancel()
@@ -463,7 +511,8 @@ type SnapServiceOptions struct {
 
 // ObserveChangeCallback can be invoked by EnsureSnapServices to observe
 // the previous content of a unit and the new on a change.
-// unitType can be "service", "socket", "timer". name is empty for a timer.
+// unitType can be "service", "socket", "timer", "slice" or "journald". name is
+// empty for a timer.
 type ObserveChangeCallback func(app *snap.AppInfo, grp *quota.Group, unitType string, name, old, new string)
 
 // EnsureSnapServicesOptions is the set of options applying to the
@@ -678,6 +727,30 @@ func EnsureSnapServices(snaps map[*snap.Info]*SnapServiceOptions, opts *EnsureSn
 		return nil
 	}
 
+	handleJournaldModification := func(grp *quota.Group, path string, content []byte) error {
+		old, modifiedFile, err := tryFileUpdate(path, content)
+		if err != nil {
+			return err
+		}
+
+		if modifiedFile {
+			if observeChange != nil {
+				var oldContent []byte
+				if old != nil {
+					oldContent = old.Content
+				}
+				observeChange(nil, grp, "journald", grp.Name, string(oldContent), string(content))
+			}
+
+			// journald configuration files do not need a daemon-reload, the
+			// journal namespace needs to be restarted instead which is up to
+			// the caller
+			modifiedUnitsPreviousState[path] = old
+		}
+
+		return nil
+	}
+
 	// now make sure that all of the slice units exist
 	for _, grp := range neededQuotaGrps.AllQuotaGroups() {
 		content, err := generateGroupSliceFile(grp)
@@ -690,6 +763,15 @@ func EnsureSnapServices(snaps map[*snap.Info]*SnapServiceOptions, opts *EnsureSn
 		if err := handleSliceModification(grp, path, content); err != nil {
 			return err
 		}
+
+		// and the journald configuration of groups with a journal namespace
+		if grp.JournalLimit != nil {
+			content := generateJournaldConfFile(grp)
+			path := filepath.Join(dirs.SnapSystemdDir, grp.JournalConfFileName())
+			if err := handleJournaldModification(grp, path, content); err != nil {
+				return err
+			}
+		}
 	}
 
 	if !preseeding {
@@ -850,6 +932,14 @@ func RemoveQuotaGroup(grp *quota.Group, inter interacter) error {
 
 	systemSysd := systemd.New(systemd.SystemMode, inter)
 
+	// remove the journald configuration of the journal namespace, if any
+	if grp.JournalLimit != nil {
+		err := os.Remove(filepath.Join(dirs.SnapSystemdDir, grp.JournalConfFileName()))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
 	// remove the slice file
 	err := os.Remove(filepath.Join(dirs.SnapServicesDir, grp.SliceFileName()))
 	if err != nil && !os.IsNotExist(err) {
@@ -1063,6 +1153,9 @@ OOMScoreAdjust={{.OOMAdjustScore}}
 {{- if .SliceUnit}}
 Slice={{.SliceUnit}}
 {{- end}}
+{{- if .LogNamespace}}
+LogNamespace={{.LogNamespace}}
+{{- end}}
 {{- if not (or .App.Sockets .App.Timer .App.ActivatesOn) }}
 
 [Install]
@@ -1135,6 +1228,7 @@ WantedBy={{.ServicesTarget}}
 		After                    []string
 		InterfaceServiceSnippets string
 		SliceUnit                string
+		LogNamespace             string
 
 		Home    string
 		EnvVars string
@@ -1179,6 +1273,7 @@ WantedBy={{.ServicesTarget}}
 	// check the quota group slice
 	if opts.QuotaGroup != nil {
 		wrapperData.SliceUnit = opts.QuotaGroup.SliceFileName()
+		wrapperData.LogNamespace = opts.QuotaGroup.JournalNamespaceName()
 	}
 
 	// Add extra "After" targets
@@ -1652,3 +1747,46 @@ func QueryDisabledServices(info *snap.Info, pb progress.Meter) ([]string, error)
 
 	return disabledSnapSvcs, nil
 }
+
+// FailedServices returns the names of the enabled services of the snap that
+// are expected to be running but are not, because they failed or are being
+// restarted after failing. Services that are activated on demand (by
+// sockets, timers or D-Bus) and oneshot services are not considered.
+func FailedServices(info *snap.Info, inter interacter) ([]string, error) {
+	sysd := systemd.New(systemd.SystemMode, inter)
+
+	var names, units []string
+	for name, app := range info.Apps {
+		if !app.IsService() {
+			continue
+		}
+		// FIXME: handle user daemons
+		if app.DaemonScope != snap.SystemDaemon {
+			continue
+		}
+		if app.Daemon == "oneshot" || app.Timer != nil || len(app.Sockets) > 0 || len(app.ActivatesOn) > 0 {
+			continue
+		}
+		names = append(names, name)
+		units = append(units, app.ServiceName())
+	}
+	if len(units) == 0 {
+		return nil, nil
+	}
+
+	sts, err := sysd.Status(units...)
+	if err != nil {
+		return nil, err
+	}
+	var failed []string
+	for i, st := range sts {
+		if st.Enabled && !st.Active {
+			failed = append(failed, names[i])
+		}
+	}
+
+	// sort for easier testing
+	sort.Strings(failed)
+
+	return failed, nil
+}
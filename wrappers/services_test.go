@@ -219,7 +219,7 @@ func (s *servicesTestSuite) TestEnsureSnapServicesWithQuotas(c *C) {
 	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
 
 	memLimit := quantity.SizeGiB
-	grp, err := quota.NewGroup("foogroup", memLimit)
+	grp, err := quota.NewGroup("foogroup", quota.Resources{Memory: memLimit})
 	c.Assert(err, IsNil)
 
 	m := map[*snap.Info]*wrappers.SnapServiceOptions{
@@ -301,6 +301,137 @@ TasksAccounting=true
 	c.Assert(svcFile, testutil.FileEquals, svcContent)
 }
 
+func (s *servicesTestSuite) TestEnsureSnapServicesWithAllQuotaResources(c *C) {
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+	journalConfFile := filepath.Join(s.tempdir, "/etc/systemd/journald@snap-foogroup.conf")
+
+	grp, err := quota.NewGroup("foogroup", quota.Resources{
+		Memory: quantity.SizeGiB,
+		CPU: &quota.GroupQuotaCPU{
+			Count:       2,
+			Percentage:  50,
+			AllowedCPUs: []int{0, 1},
+		},
+		Threads: 32,
+		Journal: &quota.GroupQuotaJournal{
+			Size:       64 * quantity.SizeMiB,
+			RateCount:  100,
+			RatePeriod: 10 * time.Second,
+		},
+	})
+	c.Assert(err, IsNil)
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	dir := filepath.Join(dirs.SnapMountDir, "hello-snap", "12.mount")
+	svcContent := fmt.Sprintf(`[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application hello-snap.svc1
+Requires=%[1]s
+Wants=network.target
+After=%[1]s network.target snapd.apparmor.service
+X-Snappy=yes
+
+[Service]
+EnvironmentFile=-/etc/environment
+ExecStart=/usr/bin/snap run hello-snap.svc1
+SyslogIdentifier=hello-snap.svc1
+Restart=on-failure
+WorkingDirectory=%[2]s/var/snap/hello-snap/12
+ExecStop=/usr/bin/snap run --command=stop hello-snap.svc1
+ExecStopPost=/usr/bin/snap run --command=post-stop hello-snap.svc1
+TimeoutStopSec=30
+Type=forking
+Slice=snap.foogroup.slice
+LogNamespace=snap-foogroup
+
+[Install]
+WantedBy=multi-user.target
+`,
+		systemd.EscapeUnitNamePath(dir),
+		dirs.GlobalRootDir,
+	)
+
+	sliceContent := `[Unit]
+Description=Slice for snap quota group foogroup
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+# Always enable cpu accounting, so the following cpu quota options have an effect
+CPUAccounting=true
+CPUQuota=100%
+AllowedCPUs=0,1
+
+# Always enable memory accounting otherwise the MemoryMax setting does nothing.
+MemoryAccounting=true
+MemoryMax=1073741824
+# for compatibility with older versions of systemd
+MemoryLimit=1073741824
+
+# Always enable task accounting in order to be able to count the processes/
+# threads, etc for a slice
+TasksAccounting=true
+TasksMax=32
+`
+
+	journalContent := `# Journald configuration for snap quota group foogroup
+[Journal]
+Storage=auto
+SystemMaxUse=67108864
+RuntimeMaxUse=67108864
+RateLimitIntervalSec=10000000us
+RateLimitBurst=100
+`
+
+	exp := []changesObservation{
+		{
+			snapName: "hello-snap",
+			unitType: "service",
+			name:     "svc1",
+			old:      "",
+			new:      svcContent,
+		},
+		{
+			grp:      grp,
+			unitType: "slice",
+			new:      sliceContent,
+			old:      "",
+			name:     "foogroup",
+		},
+		{
+			grp:      grp,
+			unitType: "journald",
+			new:      journalContent,
+			old:      "",
+			name:     "foogroup",
+		},
+	}
+	r, observe := expChangeObserver(c, exp)
+	defer r()
+
+	err = wrappers.EnsureSnapServices(m, nil, observe, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+
+	c.Assert(svcFile, testutil.FileEquals, svcContent)
+	c.Assert(journalConfFile, testutil.FileEquals, journalContent)
+
+	// removing the group removes the journald configuration too
+	s.sysdLog = nil
+	err = wrappers.RemoveQuotaGroup(grp, progress.Null)
+	c.Assert(err, IsNil)
+	c.Assert(journalConfFile, testutil.FileAbsent)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+}
+
 type changesObservation struct {
 	snapName string
 	grp      *quota.Group
@@ -334,7 +465,7 @@ func expChangeObserver(c *C, exp []changesObservation) (restore func(), obs wrap
 		svcObservations := make([]changesObservation, 0, len(changesObserved))
 
 		for _, chg := range changesObserved {
-			if chg.unitType == "slice" {
+			if chg.unitType == "slice" || chg.unitType == "journald" {
 				groupObservations = append(groupObservations, chg)
 			} else {
 				svcObservations = append(svcObservations, chg)
@@ -421,7 +552,7 @@ WantedBy=multi-user.target
 	c.Assert(err, IsNil)
 
 	// use new memory limit
-	grp, err := quota.NewGroup("foogroup", memLimit2)
+	grp, err := quota.NewGroup("foogroup", quota.Resources{Memory: memLimit2})
 	c.Assert(err, IsNil)
 
 	m := map[*snap.Info]*wrappers.SnapServiceOptions{
@@ -515,7 +646,7 @@ WantedBy=multi-user.target
 	err = ioutil.WriteFile(svcFile, []byte(svcContent), 0644)
 	c.Assert(err, IsNil)
 
-	grp, err := quota.NewGroup("foogroup", memLimit)
+	grp, err := quota.NewGroup("foogroup", quota.Resources{Memory: memLimit})
 	c.Assert(err, IsNil)
 
 	m := map[*snap.Info]*wrappers.SnapServiceOptions{
@@ -538,7 +669,7 @@ WantedBy=multi-user.target
 
 func (s *servicesTestSuite) TestRemoveQuotaGroup(c *C) {
 	// create the group
-	grp, err := quota.NewGroup("foogroup", quantity.SizeKiB)
+	grp, err := quota.NewGroup("foogroup", quota.Resources{Memory: quantity.SizeKiB})
 	c.Assert(err, IsNil)
 
 	sliceFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.foogroup.slice")
@@ -608,12 +739,12 @@ apps:
 	var err error
 	memLimit := quantity.SizeGiB
 	// make a root quota group and add the first snap to it
-	grp, err := quota.NewGroup("foogroup", memLimit)
+	grp, err := quota.NewGroup("foogroup", quota.Resources{Memory: memLimit})
 	c.Assert(err, IsNil)
 
 	// the second group is a sub-group with the same limit, but is for the
 	// second snap
-	subgrp, err := grp.NewSubGroup("subgroup", memLimit)
+	subgrp, err := grp.NewSubGroup("subgroup", quota.Resources{Memory: memLimit})
 	c.Assert(err, IsNil)
 
 	sliceFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.foogroup.slice")
@@ -741,12 +872,12 @@ func (s *servicesTestSuite) TestEnsureSnapServicesWithSubGroupQuotaGroupsGenerat
 	var err error
 	memLimit := quantity.SizeGiB
 	// make a root quota group without any snaps in it
-	grp, err := quota.NewGroup("foogroup", memLimit)
+	grp, err := quota.NewGroup("foogroup", quota.Resources{Memory: memLimit})
 	c.Assert(err, IsNil)
 
 	// the second group is a sub-group with the same limit, but it is the one
 	// with the snap in it
-	subgrp, err := grp.NewSubGroup("subgroup", memLimit)
+	subgrp, err := grp.NewSubGroup("subgroup", quota.Resources{Memory: memLimit})
 	c.Assert(err, IsNil)
 
 	sliceFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.foogroup.slice")
@@ -1957,6 +2088,57 @@ func (s *servicesTestSuite) TestServicesEnableState(c *C) {
 	}
 }
 
+func (s *servicesTestSuite) TestFailedServices(c *C) {
+	info := snaptest.MockSnap(c, `name: test-snap
+version: 1.0
+apps:
+  running:
+    command: bin/running
+    daemon: simple
+  crashing:
+    command: bin/crashing
+    daemon: simple
+  disabled:
+    command: bin/disabled
+    daemon: simple
+  once:
+    command: bin/once
+    daemon: oneshot
+  socket-activated:
+    command: bin/sa
+    daemon: simple
+    sockets:
+      sock:
+        listen-stream: $SNAP_DATA/sock
+  app:
+    command: bin/app
+`, &snap.SideInfo{Revision: snap.R(12)})
+
+	s.systemctlRestorer()
+	s.systemctlRestorer = systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		c.Assert(cmd[0], Equals, "show")
+		var out []byte
+		for _, unit := range cmd[2:] {
+			active, enabled := "active", "enabled"
+			switch unit {
+			case "snap.test-snap.crashing.service":
+				active = "activating"
+			case "snap.test-snap.disabled.service":
+				active, enabled = "inactive", "disabled"
+			}
+			out = append(out, fmt.Sprintf("Id=%s\nType=simple\nActiveState=%s\nUnitFileState=%s\n\n", unit, active, enabled)...)
+		}
+		return out, nil
+	})
+
+	failed, err := wrappers.FailedServices(info, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(failed, DeepEquals, []string{"crashing"})
+	c.Assert(s.sysdLog, HasLen, 1)
+	c.Check(s.sysdLog[0][2:], HasLen, 3)
+}
+
 func (s *servicesTestSuite) TestServicesEnableStateFail(c *C) {
 	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
 	svc1File := "snap.hello-snap.svc1.service"